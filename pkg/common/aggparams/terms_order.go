@@ -0,0 +1,81 @@
+package aggparams
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultTermsSize is the number of buckets of a terms aggregation that does
+// not set size
+const DefaultTermsSize = 10
+
+// DefaultCompositeSize is the page size of a composite aggregation that does
+// not set size
+const DefaultCompositeSize = 10
+
+// TermsOrderKey is one criterion of a terms aggregation's bucket order
+type TermsOrderKey struct {
+	Path   string // _count, _key, or a metric sub-aggregation
+	Metric string // the metric of a multi-value sub-aggregation, e.g. "max" or "99"
+	Asc    bool
+}
+
+// TermsOrder is the bucket order of a terms aggregation, ending with the key
+// to break ties
+type TermsOrder []TermsOrderKey
+
+// ParseTermsOrder reads the order of a terms aggregation: an object such as
+// {"_count": "desc"}, {"_key": "asc"} or {"avg_price": "desc"}, or an array
+// of them. Multi-value metrics are selected with a path such as
+// "price_stats.max" or "latency.99". The default is by count, descending.
+func ParseTermsOrder(raw interface{}) (TermsOrder, error) {
+	var criteria []interface{}
+	switch v := raw.(type) {
+	case nil:
+	case []interface{}:
+		criteria = v
+	default:
+		criteria = []interface{}{v}
+	}
+	if len(criteria) == 0 {
+		criteria = []interface{}{map[string]interface{}{"_count": "desc"}}
+	}
+
+	var order TermsOrder
+	for _, criterion := range criteria {
+		obj, ok := criterion.(map[string]interface{})
+		if !ok || len(obj) != 1 {
+			return nil, fmt.Errorf("order must be an object with a single path")
+		}
+		for path, direction := range obj {
+			dir, _ := direction.(string)
+			dir = strings.ToLower(dir)
+			if dir != "asc" && dir != "desc" {
+				return nil, fmt.Errorf("unknown order direction [%v]", direction)
+			}
+			key := TermsOrderKey{Path: path, Asc: dir == "asc"}
+			switch path {
+			case "_count", "_key":
+			case "_term":
+				key.Path = "_key"
+			default:
+				key.Path, key.Metric, _ = strings.Cut(path, ".")
+			}
+			order = append(order, key)
+		}
+	}
+	if order[len(order)-1].Path != "_key" {
+		order = append(order, TermsOrderKey{Path: "_key", Asc: true})
+	}
+	return order, nil
+}
+
+// BySubAggregation reports whether the order reads sub-aggregation results
+func (o TermsOrder) BySubAggregation() bool {
+	for _, key := range o {
+		if key.Path != "_count" && key.Path != "_key" {
+			return true
+		}
+	}
+	return false
+}
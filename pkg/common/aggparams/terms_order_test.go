@@ -0,0 +1,40 @@
+package aggparams
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOrder(t *testing.T) {
+	order, err := ParseTermsOrder(nil)
+	require.NoError(t, err)
+	assert.Equal(t, TermsOrder{{Path: "_count"}, {Path: "_key", Asc: true}}, order)
+	assert.False(t, order.BySubAggregation())
+
+	order, err = ParseTermsOrder(map[string]interface{}{"_term": "DESC"})
+	require.NoError(t, err)
+	assert.Equal(t, TermsOrder{{Path: "_key"}}, order)
+
+	order, err = ParseTermsOrder([]interface{}{
+		map[string]interface{}{"latency.99": "asc"},
+		map[string]interface{}{"_count": "desc"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, TermsOrder{
+		{Path: "latency", Metric: "99", Asc: true},
+		{Path: "_count"},
+		{Path: "_key", Asc: true},
+	}, order)
+	assert.True(t, order.BySubAggregation())
+
+	for _, raw := range []interface{}{
+		"_count",
+		map[string]interface{}{"_count": "up"},
+		map[string]interface{}{"_count": "asc", "_key": "asc"},
+	} {
+		_, err := ParseTermsOrder(raw)
+		assert.Error(t, err, "%v", raw)
+	}
+}
@@ -18,6 +18,15 @@ type MasterConfig struct {
 	Peers       []string
 	LogLevel    string
 	MetricsPort int
+
+	// Node failure detection: a data node is degraded, then offline, after
+	// going that long without a heartbeat. The shards lost with an offline
+	// node are reallocated once DelayedAllocationTimeout has passed without
+	// it returning.
+	FailureCheckInterval     time.Duration
+	NodeDegradedTimeout      time.Duration
+	NodeOfflineTimeout       time.Duration
+	DelayedAllocationTimeout time.Duration
 }
 
 // CoordinationConfig holds configuration for coordination nodes
@@ -48,6 +57,13 @@ type DataNodeConfig struct {
 	LogLevel     string
 	MetricsPort  int
 	SIMDEnabled  bool
+
+	// Translog settings
+	TranslogDurability   string        // request (fsync before ack) or async
+	TranslogSyncInterval time.Duration // fsync interval when durability is async
+
+	// Most terms a prefix, wildcard or regexp query may expand to per shard
+	MaxTermExpansions int
 }
 
 // LoadMasterConfig loads master node configuration from file
@@ -62,6 +78,10 @@ func LoadMasterConfig(cfgFile string) (*MasterConfig, error) {
 	v.SetDefault("data_dir", "/var/lib/conjugate/master")
 	v.SetDefault("log_level", "info")
 	v.SetDefault("metrics_port", 9400)
+	v.SetDefault("failure_check_interval", "5s")
+	v.SetDefault("node_degraded_timeout", "30s")
+	v.SetDefault("node_offline_timeout", "60s")
+	v.SetDefault("delayed_allocation_timeout", "1m")
 
 	// Load config file
 	if cfgFile != "" {
@@ -93,6 +113,11 @@ func LoadMasterConfig(cfgFile string) (*MasterConfig, error) {
 		Peers:       v.GetStringSlice("peers"),
 		LogLevel:    v.GetString("log_level"),
 		MetricsPort: v.GetInt("metrics_port"),
+
+		FailureCheckInterval:     v.GetDuration("failure_check_interval"),
+		NodeDegradedTimeout:      v.GetDuration("node_degraded_timeout"),
+		NodeOfflineTimeout:       v.GetDuration("node_offline_timeout"),
+		DelayedAllocationTimeout: v.GetDuration("delayed_allocation_timeout"),
 	}
 
 	return cfg, nil
@@ -170,6 +195,9 @@ func LoadDataNodeConfig(cfgFile string) (*DataNodeConfig, error) {
 	v.SetDefault("log_level", "info")
 	v.SetDefault("metrics_port", 9402)
 	v.SetDefault("simd_enabled", true)
+	v.SetDefault("translog_durability", "request")
+	v.SetDefault("translog_sync_interval", "5s")
+	v.SetDefault("max_term_expansions", 1024)
 
 	// Load config file
 	if cfgFile != "" {
@@ -203,6 +231,11 @@ func LoadDataNodeConfig(cfgFile string) (*DataNodeConfig, error) {
 		LogLevel:    v.GetString("log_level"),
 		MetricsPort: v.GetInt("metrics_port"),
 		SIMDEnabled: v.GetBool("simd_enabled"),
+
+		TranslogDurability:   v.GetString("translog_durability"),
+		TranslogSyncInterval: v.GetDuration("translog_sync_interval"),
+
+		MaxTermExpansions: v.GetInt("max_term_expansions"),
 	}
 
 	return cfg, nil
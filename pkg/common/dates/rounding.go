@@ -0,0 +1,498 @@
+package dates
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	// Time zones must resolve on hosts without a zoneinfo database
+	_ "time/tzdata"
+)
+
+// layouts are the string formats accepted for dates
+var layouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// KeyLayout formats date_histogram bucket keys (key_as_string). Keys in UTC
+// end in Z, others in their offset, e.g. 2024-03-10T00:00:00.000-05:00.
+const KeyLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// calendarUnits maps calendar_interval spellings to their unit
+var calendarUnits = map[string]string{
+	"minute": "minute", "1m": "minute",
+	"hour": "hour", "1h": "hour",
+	"day": "day", "1d": "day",
+	"week": "week", "1w": "week",
+	"month": "month", "1M": "month",
+	"quarter": "quarter", "1q": "quarter",
+	"year": "year", "1y": "year",
+}
+
+// Parse parses a date: epoch millis or one of the accepted layouts. Dates
+// without an offset are taken to be in loc.
+func Parse(value string, loc *time.Location) (time.Time, bool) {
+	if ms, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.UnixMilli(int64(ms)).UTC(), true
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Rounding rounds instants down to the start of their date_histogram bucket:
+// a calendar unit or a fixed duration, in a time zone and shifted by an
+// offset. Calendar units follow the zone's wall clock, so a day bucket
+// starts at local midnight and is 23 or 25 hours long across a DST change.
+// The zero value rounds to the millisecond in UTC.
+type Rounding struct {
+	calendar string        // minute, hour, day, week, month, quarter or year
+	months   int           // months in a month bucket, if more than 1
+	fixed    time.Duration // when calendar is not set
+	location *time.Location
+	offset   time.Duration
+}
+
+// ParseRounding reads the interval of a date_histogram: calendar_interval,
+// fixed_interval or the legacy interval, with the optional time_zone and
+// offset
+func ParseRounding(body map[string]interface{}) (Rounding, error) {
+	var r Rounding
+	var err error
+	if value, ok := body["calendar_interval"].(string); ok {
+		unit, ok := calendarUnits[value]
+		if !ok {
+			return r, fmt.Errorf("unknown calendar_interval [%s]", value)
+		}
+		r.calendar = unit
+	} else if value, ok := body["fixed_interval"].(string); ok {
+		if r.fixed, err = parseDuration(value); err != nil {
+			return r, fmt.Errorf("invalid fixed_interval [%s]", value)
+		}
+	} else if value, ok := body["interval"].(string); ok {
+		if unit, ok := calendarUnits[value]; ok {
+			r.calendar = unit
+		} else if r.fixed, err = parseDuration(value); err != nil {
+			return r, fmt.Errorf("invalid interval [%s]", value)
+		}
+	} else {
+		return r, fmt.Errorf("calendar_interval or fixed_interval is required")
+	}
+
+	if value, ok := body["time_zone"].(string); ok {
+		if r.location, err = ParseTimeZone(value); err != nil {
+			return r, err
+		}
+	}
+	switch value := body["offset"].(type) {
+	case string:
+		if r.offset, err = ParseOffset(value); err != nil {
+			return r, err
+		}
+	case float64:
+		r.offset = time.Duration(value) * time.Millisecond
+	}
+	return r, nil
+}
+
+// ParseSpan returns the rounding of a PPL span such as 1d, 5m or 2mon.
+// Single minutes, hours, days and weeks and any number of months, quarters
+// and years follow the calendar; other spans are fixed.
+func ParseSpan(value int, unit string) (Rounding, error) {
+	if value <= 0 {
+		return Rounding{}, fmt.Errorf("span must be positive, got %d", value)
+	}
+	n := time.Duration(value)
+
+	switch strings.ToLower(unit) {
+	case "ms", "millisecond", "milliseconds":
+		return Rounding{fixed: n * time.Millisecond}, nil
+	case "s", "sec", "second", "seconds":
+		return Rounding{fixed: n * time.Second}, nil
+	case "m", "min", "minute", "minutes":
+		if value == 1 {
+			return Rounding{calendar: "minute"}, nil
+		}
+		return Rounding{fixed: n * time.Minute}, nil
+	case "h", "hr", "hour", "hours":
+		if value == 1 {
+			return Rounding{calendar: "hour"}, nil
+		}
+		return Rounding{fixed: n * time.Hour}, nil
+	case "d", "day", "days":
+		if value == 1 {
+			return Rounding{calendar: "day"}, nil
+		}
+		return Rounding{fixed: n * 24 * time.Hour}, nil
+	case "w", "week", "weeks":
+		if value == 1 {
+			return Rounding{calendar: "week"}, nil
+		}
+		return Rounding{fixed: n * 7 * 24 * time.Hour}, nil
+	case "mon", "month", "months":
+		return monthRounding(value), nil
+	case "q", "quarter", "quarters":
+		return monthRounding(3 * value), nil
+	case "y", "year", "years":
+		return monthRounding(12 * value), nil
+	}
+	return Rounding{}, fmt.Errorf("unknown span unit [%s]", unit)
+}
+
+// monthRounding returns the calendar rounding to buckets of the given
+// number of months
+func monthRounding(months int) Rounding {
+	switch months {
+	case 1:
+		return Rounding{calendar: "month"}
+	case 3:
+		return Rounding{calendar: "quarter"}
+	case 12:
+		return Rounding{calendar: "year"}
+	}
+	return Rounding{calendar: "month", months: months}
+}
+
+// ParseTimeZone reads a time_zone: an IANA name such as America/New_York,
+// or a UTC offset such as +05:30, -0800 or Z
+func ParseTimeZone(name string) (*time.Location, error) {
+	switch name {
+	case "", "Z", "UTC", "utc":
+		return time.UTC, nil
+	}
+	if name[0] == '+' || name[0] == '-' {
+		digits := strings.ReplaceAll(name[1:], ":", "")
+		hours, minutes := digits, "0"
+		if len(digits) > 2 {
+			hours, minutes = digits[:len(digits)-2], digits[len(digits)-2:]
+		}
+		h, errH := strconv.Atoi(hours)
+		m, errM := strconv.Atoi(minutes)
+		if errH != nil || errM != nil || h > 18 || m >= 60 {
+			return nil, fmt.Errorf("invalid time_zone offset [%s]", name)
+		}
+		seconds := h*3600 + m*60
+		if name[0] == '-' {
+			seconds = -seconds
+		}
+		return time.FixedZone(name, seconds), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time_zone [%s]", name)
+	}
+	return loc, nil
+}
+
+// ParseOffset reads a bucket offset such as +6h, -30m or 1d
+func ParseOffset(value string) (time.Duration, error) {
+	sign := time.Duration(1)
+	unsigned := value
+	switch {
+	case strings.HasPrefix(value, "-"):
+		sign, unsigned = -1, value[1:]
+	case strings.HasPrefix(value, "+"):
+		unsigned = value[1:]
+	}
+	d, err := parseDuration(unsigned)
+	if err != nil {
+		return 0, fmt.Errorf("invalid offset [%s]", value)
+	}
+	return sign * d, nil
+}
+
+// ParseTimeValue parses an OpenSearch time value such as 500ms, 30s or 1d,
+// as used by index settings
+func ParseTimeValue(value string) (time.Duration, error) {
+	return parseDuration(value)
+}
+
+// parseDuration parses durations such as 500ms, 30s, 5m, 2h or 1d
+func parseDuration(value string) (time.Duration, error) {
+	units := []struct {
+		suffix string
+		unit   time.Duration
+	}{
+		{"nanos", time.Nanosecond},
+		{"micros", time.Microsecond},
+		{"ms", time.Millisecond},
+		{"s", time.Second},
+		{"m", time.Minute},
+		{"h", time.Hour},
+		{"d", 24 * time.Hour},
+	}
+	for _, u := range units {
+		if !strings.HasSuffix(value, u.suffix) {
+			continue
+		}
+		n, err := strconv.ParseInt(strings.TrimSuffix(value, u.suffix), 10, 64)
+		if err != nil || n <= 0 {
+			break
+		}
+		return time.Duration(n) * u.unit, nil
+	}
+	return 0, fmt.Errorf("invalid duration [%s]", value)
+}
+
+// Location returns the rounding's time zone
+func (r Rounding) Location() *time.Location {
+	if r.location == nil {
+		return time.UTC
+	}
+	return r.location
+}
+
+// Params returns the rounding's interval as date_histogram parameters. A
+// calendar_interval is a single unit, so buckets of several months have
+// none.
+func (r Rounding) Params() (map[string]interface{}, error) {
+	if r.months > 1 {
+		return nil, fmt.Errorf("calendar interval of %d months is not supported by date_histogram", r.months)
+	}
+	if r.calendar != "" {
+		return map[string]interface{}{"calendar_interval": r.calendar}, nil
+	}
+	return map[string]interface{}{"fixed_interval": formatDuration(r.step())}, nil
+}
+
+// formatDuration formats a duration in its largest whole unit
+func formatDuration(d time.Duration) string {
+	units := []struct {
+		suffix string
+		unit   time.Duration
+	}{
+		{"d", 24 * time.Hour},
+		{"h", time.Hour},
+		{"m", time.Minute},
+		{"s", time.Second},
+	}
+	for _, u := range units {
+		if d%u.unit == 0 {
+			return fmt.Sprintf("%d%s", d/u.unit, u.suffix)
+		}
+	}
+	return fmt.Sprintf("%dms", d.Milliseconds())
+}
+
+// step is the length of a minute, hour or fixed bucket
+func (r Rounding) step() time.Duration {
+	switch r.calendar {
+	case "minute":
+		return time.Minute
+	case "hour":
+		return time.Hour
+	}
+	if r.fixed <= 0 {
+		return time.Millisecond
+	}
+	return r.fixed
+}
+
+// monthStep is the number of months in a month, quarter or year bucket
+func (r Rounding) monthStep() int {
+	switch r.calendar {
+	case "quarter":
+		return 3
+	case "year":
+		return 12
+	}
+	if r.months > 1 {
+		return r.months
+	}
+	return 1
+}
+
+// Round returns the start of the bucket containing t. Buckets of a day or
+// longer are shifted by the offset on the wall clock, so with an offset of
+// +6h days start at 06:00 local time on DST changes too; shorter buckets are
+// shifted by elapsed time.
+func (r Rounding) Round(t time.Time) time.Time {
+	loc := r.Location()
+	t = t.In(loc)
+
+	var start time.Time
+	switch r.calendar {
+	case "day", "week", "month", "quarter", "year":
+		t = wallAdd(t, -r.offset)
+		switch r.calendar {
+		case "day":
+			start = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		case "week":
+			day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+			start = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		case "month", "quarter", "year":
+			// Buckets of several months are counted from January 1970, so
+			// 6mon buckets start in January and July
+			step := r.monthStep()
+			month := (t.Year()-1970)*12 + int(t.Month()) - 1
+			month -= ((month % step) + step) % step
+			start = time.Date(1970, time.Month(month+1), 1, 0, 0, 0, 0, loc)
+		}
+		return wallAdd(start, r.offset)
+	}
+	return truncateWall(t.Add(-r.offset), r.step()).Add(r.offset)
+}
+
+// wallAdd moves t by d on its zone's wall clock
+func wallAdd(t time.Time, d time.Duration) time.Time {
+	if d == 0 {
+		return t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond()+int(d), t.Location())
+}
+
+// truncateWall truncates t to a multiple of d on its zone's wall clock,
+// keeping t's UTC offset, so the repeated hour when DST ends is a bucket of
+// its own rather than merged with the first
+func truncateWall(t time.Time, d time.Duration) time.Time {
+	_, zoneOffset := t.Zone()
+	wall := t.UnixNano() + int64(zoneOffset)*int64(time.Second)
+	rem := wall % int64(d)
+	if rem < 0 {
+		rem += int64(d)
+	}
+	return t.Add(-time.Duration(rem))
+}
+
+// Next returns the start of the bucket after the one starting at t
+func (r Rounding) Next(t time.Time) time.Time {
+	local := t.In(r.Location())
+	switch r.calendar {
+	case "day":
+		return r.Round(local.AddDate(0, 0, 1))
+	case "week":
+		return r.Round(local.AddDate(0, 0, 7))
+	case "month", "quarter", "year":
+		return r.Round(local.AddDate(0, r.monthStep(), 0))
+	}
+
+	// Across a DST change a wall-clock bucket is longer or shorter than its
+	// step, so one step on may still be in the same bucket
+	step := r.step()
+	next := r.Round(t.Add(step))
+	if !next.After(t) {
+		next = r.Round(t.Add(step + step/2))
+	}
+	return next
+}
+
+// FormatKey formats a bucket key as its key_as_string, in the rounding's
+// time zone
+func (r Rounding) FormatKey(t time.Time) string {
+	return t.In(r.Location()).Format(KeyLayout)
+}
+
+// Bounds are the extended_bounds or hard_bounds of a date_histogram, in
+// epoch millis (nil if open)
+type Bounds struct {
+	Min *int64
+	Max *int64
+}
+
+// ParseBounds reads extended_bounds or hard_bounds: min and max as epoch
+// millis or dates, those without an offset in the rounding's time zone.
+// It returns nil if raw is.
+func (r Rounding) ParseBounds(raw interface{}) (*Bounds, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	obj, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("bounds must be an object")
+	}
+	bounds := &Bounds{}
+	for name, target := range map[string]**int64{"min": &bounds.Min, "max": &bounds.Max} {
+		var ms int64
+		switch v := obj[name].(type) {
+		case nil:
+			continue
+		case float64:
+			ms = int64(v)
+		case string:
+			t, ok := Parse(v, r.Location())
+			if !ok {
+				return nil, fmt.Errorf("invalid bound [%s] for [%s]", v, name)
+			}
+			ms = t.UnixMilli()
+		default:
+			return nil, fmt.Errorf("invalid bound [%v] for [%s]", v, name)
+		}
+		*target = &ms
+	}
+	if bounds.Min != nil && bounds.Max != nil && *bounds.Min > *bounds.Max {
+		return nil, fmt.Errorf("bounds min [%d] is after max [%d]", *bounds.Min, *bounds.Max)
+	}
+	return bounds, nil
+}
+
+// BucketKeys returns the keys of a date_histogram's buckets in order, given
+// the keys of its non-empty buckets. With fill the empty buckets between
+// them, and out to the extended bounds, are added. Keys of buckets outside
+// the hard bounds are dropped. It fails if there would be more than limit
+// keys.
+func (r Rounding) BucketKeys(keys []int64, fill bool, extended, hard *Bounds, limit int) ([]int64, error) {
+	sorted := append([]int64(nil), keys...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	inHardBounds := func(key int64) bool {
+		if hard == nil {
+			return true
+		}
+		if hard.Min != nil && key < r.Round(time.UnixMilli(*hard.Min)).UnixMilli() {
+			return false
+		}
+		return hard.Max == nil || key <= *hard.Max
+	}
+
+	if fill {
+		var lo, hi *int64
+		if len(sorted) > 0 {
+			lo, hi = &sorted[0], &sorted[len(sorted)-1]
+		}
+		if extended != nil {
+			if extended.Min != nil && (lo == nil || *extended.Min < *lo) {
+				lo = extended.Min
+			}
+			if extended.Max != nil && (hi == nil || *extended.Max > *hi) {
+				hi = extended.Max
+			}
+		}
+		if hard != nil {
+			if hard.Min != nil && lo != nil && *hard.Min > *lo {
+				lo = hard.Min
+			}
+			if hard.Max != nil && hi != nil && *hard.Max < *hi {
+				hi = hard.Max
+			}
+		}
+		if lo != nil && hi != nil {
+			filled := make([]int64, 0, len(sorted))
+			for t := r.Round(time.UnixMilli(*lo)); t.UnixMilli() <= *hi; t = r.Next(t) {
+				if !inHardBounds(t.UnixMilli()) {
+					continue
+				}
+				if len(filled) >= limit {
+					return nil, fmt.Errorf("date_histogram would create more than %d buckets", limit)
+				}
+				filled = append(filled, t.UnixMilli())
+			}
+			return filled, nil
+		}
+	}
+
+	result := sorted[:0]
+	for _, key := range sorted {
+		if inHardBounds(key) {
+			result = append(result, key)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,223 @@
+package dates
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func utc(value string) time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func mustRounding(t *testing.T, body map[string]interface{}) Rounding {
+	t.Helper()
+	r, err := ParseRounding(body)
+	require.NoError(t, err)
+	return r
+}
+
+func TestRoundingUTC(t *testing.T) {
+	day := mustRounding(t, map[string]interface{}{"calendar_interval": "day"})
+	assert.Equal(t, utc("2024-01-15T00:00:00Z"), day.Round(utc("2024-01-15T10:00:00Z")).UTC())
+	assert.Equal(t, "2024-01-15T00:00:00.000Z", day.FormatKey(utc("2024-01-15T00:00:00Z")))
+
+	week := mustRounding(t, map[string]interface{}{"calendar_interval": "1w"})
+	// Weeks start on Monday
+	assert.Equal(t, utc("2024-01-15T00:00:00Z"), week.Round(utc("2024-01-21T23:00:00Z")).UTC())
+
+	fixed := mustRounding(t, map[string]interface{}{"fixed_interval": "90m"})
+	assert.Equal(t, utc("2024-01-15T09:00:00Z"), fixed.Round(utc("2024-01-15T10:00:00Z")).UTC())
+	assert.Equal(t, utc("2024-01-15T10:30:00Z"), fixed.Next(utc("2024-01-15T09:00:00Z")).UTC())
+}
+
+func TestRoundingMonths(t *testing.T) {
+	half, err := ParseSpan(6, "mon")
+	require.NoError(t, err)
+	assert.Equal(t, utc("2024-07-01T00:00:00Z"), half.Round(utc("2024-12-31T23:00:00Z")).UTC())
+	assert.Equal(t, utc("2025-01-01T00:00:00Z"), half.Next(utc("2024-07-01T00:00:00Z")).UTC())
+	assert.Equal(t, utc("1969-07-01T00:00:00Z"), half.Round(utc("1969-08-15T00:00:00Z")).UTC())
+
+	// Two-year buckets start in even years
+	twoYears, err := ParseSpan(2, "y")
+	require.NoError(t, err)
+	assert.Equal(t, utc("2024-01-01T00:00:00Z"), twoYears.Round(utc("2025-06-15T00:00:00Z")).UTC())
+	assert.Equal(t, utc("2026-01-01T00:00:00Z"), twoYears.Next(utc("2024-01-01T00:00:00Z")).UTC())
+}
+
+func TestRoundingTimeZone(t *testing.T) {
+	day := mustRounding(t, map[string]interface{}{"calendar_interval": "day", "time_zone": "America/New_York"})
+
+	// 08:00 EDT on the day DST starts is in the day from midnight EST, 23
+	// hours long
+	start := day.Round(utc("2024-03-10T12:00:00Z"))
+	assert.Equal(t, utc("2024-03-10T05:00:00Z"), start.UTC())
+	assert.Equal(t, "2024-03-10T00:00:00.000-05:00", day.FormatKey(start))
+	next := day.Next(start)
+	assert.Equal(t, utc("2024-03-11T04:00:00Z"), next.UTC())
+	assert.Equal(t, 23*time.Hour, next.Sub(start))
+
+	// The day DST ends is 25 hours long
+	start = day.Round(utc("2024-11-03T12:00:00Z"))
+	assert.Equal(t, 25*time.Hour, day.Next(start).Sub(start))
+
+	// Late evening local time is still the previous day
+	assert.Equal(t, utc("2024-01-14T05:00:00Z"), day.Round(utc("2024-01-15T03:00:00Z")).UTC())
+
+	month := mustRounding(t, map[string]interface{}{"calendar_interval": "month", "time_zone": "America/New_York"})
+	assert.Equal(t, utc("2024-01-01T05:00:00Z"), month.Round(utc("2024-02-01T03:00:00Z")).UTC())
+	assert.Equal(t, utc("2024-04-01T04:00:00Z"), month.Next(utc("2024-03-01T05:00:00Z")).UTC())
+}
+
+func TestRoundingRepeatedHour(t *testing.T) {
+	hour := mustRounding(t, map[string]interface{}{"calendar_interval": "hour", "time_zone": "America/New_York"})
+
+	// 01:30 EDT and 01:30 EST fall in different buckets
+	first := hour.Round(utc("2024-11-03T05:30:00Z"))
+	second := hour.Round(utc("2024-11-03T06:30:00Z"))
+	assert.Equal(t, utc("2024-11-03T05:00:00Z"), first.UTC())
+	assert.Equal(t, utc("2024-11-03T06:00:00Z"), second.UTC())
+	assert.Equal(t, second, hour.Next(first))
+	assert.Equal(t, "2024-11-03T01:00:00.000-04:00", hour.FormatKey(first))
+	assert.Equal(t, "2024-11-03T01:00:00.000-05:00", hour.FormatKey(second))
+}
+
+func TestRoundingOffsetAndFixedZone(t *testing.T) {
+	// Hours in a zone half an hour off UTC start on the local hour
+	hour := mustRounding(t, map[string]interface{}{"calendar_interval": "hour", "time_zone": "Asia/Kolkata"})
+	assert.Equal(t, utc("2024-01-01T09:30:00Z"), hour.Round(utc("2024-01-01T10:10:00Z")).UTC())
+
+	fixedZone := mustRounding(t, map[string]interface{}{"calendar_interval": "day", "time_zone": "+05:30"})
+	assert.Equal(t, utc("2023-12-31T18:30:00Z"), fixedZone.Round(utc("2024-01-01T10:10:00Z")).UTC())
+
+	// Days from 06:00
+	shifted := mustRounding(t, map[string]interface{}{"calendar_interval": "day", "offset": "+6h"})
+	assert.Equal(t, utc("2024-01-14T06:00:00Z"), shifted.Round(utc("2024-01-15T03:00:00Z")).UTC())
+	assert.Equal(t, utc("2024-01-15T06:00:00Z"), shifted.Next(utc("2024-01-14T06:00:00Z")).UTC())
+
+	// On the wall clock, also on the day DST starts
+	shifted = mustRounding(t, map[string]interface{}{"calendar_interval": "day", "offset": "+6h", "time_zone": "America/New_York"})
+	assert.Equal(t, utc("2024-03-10T10:00:00Z"), shifted.Round(utc("2024-03-10T12:00:00Z")).UTC())
+	assert.Equal(t, utc("2024-03-09T11:00:00Z"), shifted.Round(utc("2024-03-10T09:00:00Z")).UTC())
+
+	shifted = mustRounding(t, map[string]interface{}{"fixed_interval": "1h", "offset": "-15m"})
+	assert.Equal(t, utc("2024-01-15T02:45:00Z"), shifted.Round(utc("2024-01-15T03:00:00Z")).UTC())
+}
+
+func TestBucketKeys(t *testing.T) {
+	month := mustRounding(t, map[string]interface{}{"calendar_interval": "month", "time_zone": "America/New_York"})
+	ms := func(value string) int64 { return utc(value).UnixMilli() }
+	ptr := func(v int64) *int64 { return &v }
+
+	jan, feb, mar := ms("2024-01-01T05:00:00Z"), ms("2024-02-01T05:00:00Z"), ms("2024-03-01T05:00:00Z")
+	apr, may := ms("2024-04-01T04:00:00Z"), ms("2024-05-01T04:00:00Z")
+
+	keys, err := month.BucketKeys([]int64{mar, jan}, false, nil, nil, 100)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{jan, mar}, keys)
+
+	keys, err = month.BucketKeys([]int64{mar, jan}, true, nil, nil, 100)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{jan, feb, mar}, keys)
+
+	// Extended bounds widen the filled range, hard bounds clip it
+	extended := &Bounds{Max: ptr(ms("2024-05-15T00:00:00Z"))}
+	keys, err = month.BucketKeys([]int64{feb}, true, extended, nil, 100)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{feb, mar, apr, may}, keys)
+
+	hard := &Bounds{Min: ptr(ms("2024-02-10T00:00:00Z")), Max: ptr(ms("2024-04-10T00:00:00Z"))}
+	keys, err = month.BucketKeys([]int64{jan, may}, true, nil, hard, 100)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{feb, mar, apr}, keys)
+	keys, err = month.BucketKeys([]int64{jan, mar, may}, false, nil, hard, 100)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{mar}, keys)
+
+	// With no documents, the extended bounds alone set the range
+	keys, err = month.BucketKeys(nil, true, &Bounds{Min: ptr(jan), Max: ptr(feb)}, nil, 100)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{jan, feb}, keys)
+
+	_, err = month.BucketKeys([]int64{jan, may}, true, nil, nil, 3)
+	assert.Error(t, err)
+}
+
+func TestParseBounds(t *testing.T) {
+	r := mustRounding(t, map[string]interface{}{"calendar_interval": "day", "time_zone": "-05:00"})
+	bounds, err := r.ParseBounds(map[string]interface{}{"min": "2024-01-01", "max": 1704153600000.0})
+	require.NoError(t, err)
+	// A date without an offset is in the time zone
+	assert.Equal(t, utc("2024-01-01T05:00:00Z").UnixMilli(), *bounds.Min)
+	assert.Equal(t, int64(1704153600000), *bounds.Max)
+
+	bounds, err = r.ParseBounds(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, bounds)
+
+	for _, raw := range []interface{}{
+		"nope",
+		map[string]interface{}{"min": "yesterday"},
+		map[string]interface{}{"min": 2.0, "max": 1.0},
+	} {
+		_, err := r.ParseBounds(raw)
+		assert.Error(t, err, "%v", raw)
+	}
+}
+
+func TestParseRoundingErrors(t *testing.T) {
+	for _, body := range []map[string]interface{}{
+		{},
+		{"calendar_interval": "2d"},
+		{"fixed_interval": "1M"},
+		{"calendar_interval": "day", "time_zone": "Mars/Olympus"},
+		{"calendar_interval": "day", "time_zone": "+25:00"},
+		{"calendar_interval": "day", "offset": "6x"},
+	} {
+		_, err := ParseRounding(body)
+		assert.Error(t, err, "%v", body)
+	}
+}
+
+func TestParseSpan(t *testing.T) {
+	tests := []struct {
+		value  int
+		unit   string
+		params map[string]interface{}
+	}{
+		{1, "d", map[string]interface{}{"calendar_interval": "day"}},
+		{1, "h", map[string]interface{}{"calendar_interval": "hour"}},
+		{5, "m", map[string]interface{}{"fixed_interval": "5m"}},
+		{30, "s", map[string]interface{}{"fixed_interval": "30s"}},
+		{2, "w", map[string]interface{}{"fixed_interval": "14d"}},
+		{1, "mon", map[string]interface{}{"calendar_interval": "month"}},
+		{3, "months", map[string]interface{}{"calendar_interval": "quarter"}},
+		{1, "y", map[string]interface{}{"calendar_interval": "year"}},
+		{4, "q", map[string]interface{}{"calendar_interval": "year"}},
+	}
+	for _, tt := range tests {
+		r, err := ParseSpan(tt.value, tt.unit)
+		require.NoError(t, err, "%d%s", tt.value, tt.unit)
+		params, err := r.Params()
+		require.NoError(t, err, "%d%s", tt.value, tt.unit)
+		assert.Equal(t, tt.params, params, "%d%s", tt.value, tt.unit)
+	}
+
+	// Several months round from January 1970 but have no calendar_interval
+	for _, unit := range []string{"mon", "q", "y"} {
+		r, err := ParseSpan(2, unit)
+		require.NoError(t, err, unit)
+		_, err = r.Params()
+		assert.Error(t, err, unit)
+	}
+	_, err := ParseSpan(0, "d")
+	assert.Error(t, err)
+	_, err = ParseSpan(1, "fortnight")
+	assert.Error(t, err)
+}
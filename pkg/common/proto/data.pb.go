@@ -84,6 +84,7 @@ type CreateShardRequest struct {
 	ShardId       int32                  `protobuf:"varint,2,opt,name=shard_id,json=shardId,proto3" json:"shard_id,omitempty"`
 	IsPrimary     bool                   `protobuf:"varint,3,opt,name=is_primary,json=isPrimary,proto3" json:"is_primary,omitempty"`
 	Settings      map[string]string      `protobuf:"bytes,4,rep,name=settings,proto3" json:"settings,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	PrimaryTerm   int64                  `protobuf:"varint,5,opt,name=primary_term,json=primaryTerm,proto3" json:"primary_term,omitempty"` // Term of the shard's primary, from the master's routing
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -146,6 +147,13 @@ func (x *CreateShardRequest) GetSettings() map[string]string {
 	return nil
 }
 
+func (x *CreateShardRequest) GetPrimaryTerm() int64 {
+	if x != nil {
+		return x.PrimaryTerm
+	}
+	return 0
+}
+
 type CreateShardResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Acknowledged  bool                   `protobuf:"varint,1,opt,name=acknowledged,proto3" json:"acknowledged,omitempty"`
@@ -351,7 +359,7 @@ type ShardInfo struct {
 	IndexName     string                 `protobuf:"bytes,1,opt,name=index_name,json=indexName,proto3" json:"index_name,omitempty"`
 	ShardId       int32                  `protobuf:"varint,2,opt,name=shard_id,json=shardId,proto3" json:"shard_id,omitempty"`
 	IsPrimary     bool                   `protobuf:"varint,3,opt,name=is_primary,json=isPrimary,proto3" json:"is_primary,omitempty"`
-	State         ShardInfo_ShardState   `protobuf:"varint,4,opt,name=state,proto3,enum=quidditch.data.ShardInfo_ShardState" json:"state,omitempty"`
+	State         ShardInfo_ShardState   `protobuf:"varint,4,opt,name=state,proto3,enum=conjugate.data.ShardInfo_ShardState" json:"state,omitempty"`
 	DocsCount     int64                  `protobuf:"varint,5,opt,name=docs_count,json=docsCount,proto3" json:"docs_count,omitempty"`
 	SizeBytes     int64                  `protobuf:"varint,6,opt,name=size_bytes,json=sizeBytes,proto3" json:"size_bytes,omitempty"`
 	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
@@ -595,10 +603,11 @@ func (x *FlushShardRequest) GetShardId() int32 {
 }
 
 type FlushShardResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Acknowledged  bool                   `protobuf:"varint,1,opt,name=acknowledged,proto3" json:"acknowledged,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Acknowledged       bool                   `protobuf:"varint,1,opt,name=acknowledged,proto3" json:"acknowledged,omitempty"`
+	TranslogGeneration int64                  `protobuf:"varint,2,opt,name=translog_generation,json=translogGeneration,proto3" json:"translog_generation,omitempty"` // Translog generation after the flush
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
 }
 
 func (x *FlushShardResponse) Reset() {
@@ -638,19 +647,132 @@ func (x *FlushShardResponse) GetAcknowledged() bool {
 	return false
 }
 
-type IndexDocumentRequest struct {
+func (x *FlushShardResponse) GetTranslogGeneration() int64 {
+	if x != nil {
+		return x.TranslogGeneration
+	}
+	return 0
+}
+
+type UpdateShardSettingsRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	IndexName     string                 `protobuf:"bytes,1,opt,name=index_name,json=indexName,proto3" json:"index_name,omitempty"`
 	ShardId       int32                  `protobuf:"varint,2,opt,name=shard_id,json=shardId,proto3" json:"shard_id,omitempty"`
-	DocId         string                 `protobuf:"bytes,3,opt,name=doc_id,json=docId,proto3" json:"doc_id,omitempty"`
-	Document      *structpb.Struct       `protobuf:"bytes,4,opt,name=document,proto3" json:"document,omitempty"`
+	Settings      map[string]string      `protobuf:"bytes,3,rep,name=settings,proto3" json:"settings,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // refresh_interval, commit_interval, commit_batch_size
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateShardSettingsRequest) Reset() {
+	*x = UpdateShardSettingsRequest{}
+	mi := &file_pkg_common_proto_data_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateShardSettingsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateShardSettingsRequest) ProtoMessage() {}
+
+func (x *UpdateShardSettingsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_common_proto_data_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateShardSettingsRequest.ProtoReflect.Descriptor instead.
+func (*UpdateShardSettingsRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *UpdateShardSettingsRequest) GetIndexName() string {
+	if x != nil {
+		return x.IndexName
+	}
+	return ""
+}
+
+func (x *UpdateShardSettingsRequest) GetShardId() int32 {
+	if x != nil {
+		return x.ShardId
+	}
+	return 0
+}
+
+func (x *UpdateShardSettingsRequest) GetSettings() map[string]string {
+	if x != nil {
+		return x.Settings
+	}
+	return nil
+}
+
+type UpdateShardSettingsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Acknowledged  bool                   `protobuf:"varint,1,opt,name=acknowledged,proto3" json:"acknowledged,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
+func (x *UpdateShardSettingsResponse) Reset() {
+	*x = UpdateShardSettingsResponse{}
+	mi := &file_pkg_common_proto_data_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateShardSettingsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateShardSettingsResponse) ProtoMessage() {}
+
+func (x *UpdateShardSettingsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_common_proto_data_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateShardSettingsResponse.ProtoReflect.Descriptor instead.
+func (*UpdateShardSettingsResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *UpdateShardSettingsResponse) GetAcknowledged() bool {
+	if x != nil {
+		return x.Acknowledged
+	}
+	return false
+}
+
+type IndexDocumentRequest struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	IndexName           string                 `protobuf:"bytes,1,opt,name=index_name,json=indexName,proto3" json:"index_name,omitempty"`
+	ShardId             int32                  `protobuf:"varint,2,opt,name=shard_id,json=shardId,proto3" json:"shard_id,omitempty"`
+	DocId               string                 `protobuf:"bytes,3,opt,name=doc_id,json=docId,proto3" json:"doc_id,omitempty"`
+	Document            *structpb.Struct       `protobuf:"bytes,4,opt,name=document,proto3" json:"document,omitempty"`
+	Refresh             string                 `protobuf:"bytes,5,opt,name=refresh,proto3" json:"refresh,omitempty"`                                                        // "true", "wait_for" or "false" (default)
+	WaitForActiveShards string                 `protobuf:"bytes,6,opt,name=wait_for_active_shards,json=waitForActiveShards,proto3" json:"wait_for_active_shards,omitempty"` // Copies that must apply the write: a count or "all" (default 1)
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
 func (x *IndexDocumentRequest) Reset() {
 	*x = IndexDocumentRequest{}
-	mi := &file_pkg_common_proto_data_proto_msgTypes[10]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[12]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -662,7 +784,7 @@ func (x *IndexDocumentRequest) String() string {
 func (*IndexDocumentRequest) ProtoMessage() {}
 
 func (x *IndexDocumentRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_data_proto_msgTypes[10]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[12]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -675,7 +797,7 @@ func (x *IndexDocumentRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use IndexDocumentRequest.ProtoReflect.Descriptor instead.
 func (*IndexDocumentRequest) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{10}
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{12}
 }
 
 func (x *IndexDocumentRequest) GetIndexName() string {
@@ -706,18 +828,35 @@ func (x *IndexDocumentRequest) GetDocument() *structpb.Struct {
 	return nil
 }
 
+func (x *IndexDocumentRequest) GetRefresh() string {
+	if x != nil {
+		return x.Refresh
+	}
+	return ""
+}
+
+func (x *IndexDocumentRequest) GetWaitForActiveShards() string {
+	if x != nil {
+		return x.WaitForActiveShards
+	}
+	return ""
+}
+
 type IndexDocumentResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Acknowledged  bool                   `protobuf:"varint,1,opt,name=acknowledged,proto3" json:"acknowledged,omitempty"`
 	DocId         string                 `protobuf:"bytes,2,opt,name=doc_id,json=docId,proto3" json:"doc_id,omitempty"`
 	Version       int64                  `protobuf:"varint,3,opt,name=version,proto3" json:"version,omitempty"`
+	SeqNo         int64                  `protobuf:"varint,4,opt,name=seq_no,json=seqNo,proto3" json:"seq_no,omitempty"`
+	PrimaryTerm   int64                  `protobuf:"varint,5,opt,name=primary_term,json=primaryTerm,proto3" json:"primary_term,omitempty"`
+	Shards        *WriteShardsInfo       `protobuf:"bytes,6,opt,name=shards,proto3" json:"shards,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *IndexDocumentResponse) Reset() {
 	*x = IndexDocumentResponse{}
-	mi := &file_pkg_common_proto_data_proto_msgTypes[11]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[13]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -729,7 +868,7 @@ func (x *IndexDocumentResponse) String() string {
 func (*IndexDocumentResponse) ProtoMessage() {}
 
 func (x *IndexDocumentResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_data_proto_msgTypes[11]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[13]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -742,7 +881,7 @@ func (x *IndexDocumentResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use IndexDocumentResponse.ProtoReflect.Descriptor instead.
 func (*IndexDocumentResponse) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{11}
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{13}
 }
 
 func (x *IndexDocumentResponse) GetAcknowledged() bool {
@@ -766,6 +905,27 @@ func (x *IndexDocumentResponse) GetVersion() int64 {
 	return 0
 }
 
+func (x *IndexDocumentResponse) GetSeqNo() int64 {
+	if x != nil {
+		return x.SeqNo
+	}
+	return 0
+}
+
+func (x *IndexDocumentResponse) GetPrimaryTerm() int64 {
+	if x != nil {
+		return x.PrimaryTerm
+	}
+	return 0
+}
+
+func (x *IndexDocumentResponse) GetShards() *WriteShardsInfo {
+	if x != nil {
+		return x.Shards
+	}
+	return nil
+}
+
 type GetDocumentRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	IndexName     string                 `protobuf:"bytes,1,opt,name=index_name,json=indexName,proto3" json:"index_name,omitempty"`
@@ -777,7 +937,7 @@ type GetDocumentRequest struct {
 
 func (x *GetDocumentRequest) Reset() {
 	*x = GetDocumentRequest{}
-	mi := &file_pkg_common_proto_data_proto_msgTypes[12]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[14]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -789,7 +949,7 @@ func (x *GetDocumentRequest) String() string {
 func (*GetDocumentRequest) ProtoMessage() {}
 
 func (x *GetDocumentRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_data_proto_msgTypes[12]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[14]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -802,7 +962,7 @@ func (x *GetDocumentRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetDocumentRequest.ProtoReflect.Descriptor instead.
 func (*GetDocumentRequest) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{12}
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{14}
 }
 
 func (x *GetDocumentRequest) GetIndexName() string {
@@ -838,7 +998,7 @@ type GetDocumentResponse struct {
 
 func (x *GetDocumentResponse) Reset() {
 	*x = GetDocumentResponse{}
-	mi := &file_pkg_common_proto_data_proto_msgTypes[13]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[15]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -850,7 +1010,7 @@ func (x *GetDocumentResponse) String() string {
 func (*GetDocumentResponse) ProtoMessage() {}
 
 func (x *GetDocumentResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_data_proto_msgTypes[13]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[15]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -863,7 +1023,7 @@ func (x *GetDocumentResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetDocumentResponse.ProtoReflect.Descriptor instead.
 func (*GetDocumentResponse) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{13}
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{15}
 }
 
 func (x *GetDocumentResponse) GetFound() bool {
@@ -895,17 +1055,19 @@ func (x *GetDocumentResponse) GetVersion() int64 {
 }
 
 type DeleteDocumentRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	IndexName     string                 `protobuf:"bytes,1,opt,name=index_name,json=indexName,proto3" json:"index_name,omitempty"`
-	ShardId       int32                  `protobuf:"varint,2,opt,name=shard_id,json=shardId,proto3" json:"shard_id,omitempty"`
-	DocId         string                 `protobuf:"bytes,3,opt,name=doc_id,json=docId,proto3" json:"doc_id,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	IndexName           string                 `protobuf:"bytes,1,opt,name=index_name,json=indexName,proto3" json:"index_name,omitempty"`
+	ShardId             int32                  `protobuf:"varint,2,opt,name=shard_id,json=shardId,proto3" json:"shard_id,omitempty"`
+	DocId               string                 `protobuf:"bytes,3,opt,name=doc_id,json=docId,proto3" json:"doc_id,omitempty"`
+	Refresh             string                 `protobuf:"bytes,4,opt,name=refresh,proto3" json:"refresh,omitempty"`                                                        // "true", "wait_for" or "false" (default)
+	WaitForActiveShards string                 `protobuf:"bytes,5,opt,name=wait_for_active_shards,json=waitForActiveShards,proto3" json:"wait_for_active_shards,omitempty"` // Copies that must apply the write: a count or "all" (default 1)
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
 }
 
 func (x *DeleteDocumentRequest) Reset() {
 	*x = DeleteDocumentRequest{}
-	mi := &file_pkg_common_proto_data_proto_msgTypes[14]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[16]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -917,7 +1079,7 @@ func (x *DeleteDocumentRequest) String() string {
 func (*DeleteDocumentRequest) ProtoMessage() {}
 
 func (x *DeleteDocumentRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_data_proto_msgTypes[14]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[16]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -930,7 +1092,7 @@ func (x *DeleteDocumentRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DeleteDocumentRequest.ProtoReflect.Descriptor instead.
 func (*DeleteDocumentRequest) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{14}
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{16}
 }
 
 func (x *DeleteDocumentRequest) GetIndexName() string {
@@ -954,17 +1116,34 @@ func (x *DeleteDocumentRequest) GetDocId() string {
 	return ""
 }
 
+func (x *DeleteDocumentRequest) GetRefresh() string {
+	if x != nil {
+		return x.Refresh
+	}
+	return ""
+}
+
+func (x *DeleteDocumentRequest) GetWaitForActiveShards() string {
+	if x != nil {
+		return x.WaitForActiveShards
+	}
+	return ""
+}
+
 type DeleteDocumentResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Acknowledged  bool                   `protobuf:"varint,1,opt,name=acknowledged,proto3" json:"acknowledged,omitempty"`
 	Found         bool                   `protobuf:"varint,2,opt,name=found,proto3" json:"found,omitempty"`
+	SeqNo         int64                  `protobuf:"varint,3,opt,name=seq_no,json=seqNo,proto3" json:"seq_no,omitempty"`
+	PrimaryTerm   int64                  `protobuf:"varint,4,opt,name=primary_term,json=primaryTerm,proto3" json:"primary_term,omitempty"`
+	Shards        *WriteShardsInfo       `protobuf:"bytes,5,opt,name=shards,proto3" json:"shards,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *DeleteDocumentResponse) Reset() {
 	*x = DeleteDocumentResponse{}
-	mi := &file_pkg_common_proto_data_proto_msgTypes[15]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[17]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -976,7 +1155,83 @@ func (x *DeleteDocumentResponse) String() string {
 func (*DeleteDocumentResponse) ProtoMessage() {}
 
 func (x *DeleteDocumentResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_data_proto_msgTypes[15]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteDocumentResponse.ProtoReflect.Descriptor instead.
+func (*DeleteDocumentResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *DeleteDocumentResponse) GetAcknowledged() bool {
+	if x != nil {
+		return x.Acknowledged
+	}
+	return false
+}
+
+func (x *DeleteDocumentResponse) GetFound() bool {
+	if x != nil {
+		return x.Found
+	}
+	return false
+}
+
+func (x *DeleteDocumentResponse) GetSeqNo() int64 {
+	if x != nil {
+		return x.SeqNo
+	}
+	return 0
+}
+
+func (x *DeleteDocumentResponse) GetPrimaryTerm() int64 {
+	if x != nil {
+		return x.PrimaryTerm
+	}
+	return 0
+}
+
+func (x *DeleteDocumentResponse) GetShards() *WriteShardsInfo {
+	if x != nil {
+		return x.Shards
+	}
+	return nil
+}
+
+type BulkIndexRequest struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	IndexName           string                 `protobuf:"bytes,1,opt,name=index_name,json=indexName,proto3" json:"index_name,omitempty"`
+	ShardId             int32                  `protobuf:"varint,2,opt,name=shard_id,json=shardId,proto3" json:"shard_id,omitempty"`
+	Items               []*BulkIndexItem       `protobuf:"bytes,3,rep,name=items,proto3" json:"items,omitempty"`
+	Refresh             string                 `protobuf:"bytes,4,opt,name=refresh,proto3" json:"refresh,omitempty"`                                                        // "true", "wait_for" or "false" (default)
+	WaitForActiveShards string                 `protobuf:"bytes,5,opt,name=wait_for_active_shards,json=waitForActiveShards,proto3" json:"wait_for_active_shards,omitempty"` // Copies that must apply the writes: a count or "all" (default 1)
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *BulkIndexRequest) Reset() {
+	*x = BulkIndexRequest{}
+	mi := &file_pkg_common_proto_data_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BulkIndexRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BulkIndexRequest) ProtoMessage() {}
+
+func (x *BulkIndexRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_common_proto_data_proto_msgTypes[18]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -984,52 +1239,564 @@ func (x *DeleteDocumentResponse) ProtoReflect() protoreflect.Message {
 		}
 		return ms
 	}
-	return mi.MessageOf(x)
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BulkIndexRequest.ProtoReflect.Descriptor instead.
+func (*BulkIndexRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *BulkIndexRequest) GetIndexName() string {
+	if x != nil {
+		return x.IndexName
+	}
+	return ""
+}
+
+func (x *BulkIndexRequest) GetShardId() int32 {
+	if x != nil {
+		return x.ShardId
+	}
+	return 0
+}
+
+func (x *BulkIndexRequest) GetItems() []*BulkIndexItem {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+func (x *BulkIndexRequest) GetRefresh() string {
+	if x != nil {
+		return x.Refresh
+	}
+	return ""
+}
+
+func (x *BulkIndexRequest) GetWaitForActiveShards() string {
+	if x != nil {
+		return x.WaitForActiveShards
+	}
+	return ""
+}
+
+type BulkIndexItem struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DocId         string                 `protobuf:"bytes,1,opt,name=doc_id,json=docId,proto3" json:"doc_id,omitempty"`
+	Document      *structpb.Struct       `protobuf:"bytes,2,opt,name=document,proto3" json:"document,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BulkIndexItem) Reset() {
+	*x = BulkIndexItem{}
+	mi := &file_pkg_common_proto_data_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BulkIndexItem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BulkIndexItem) ProtoMessage() {}
+
+func (x *BulkIndexItem) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_common_proto_data_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BulkIndexItem.ProtoReflect.Descriptor instead.
+func (*BulkIndexItem) Descriptor() ([]byte, []int) {
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *BulkIndexItem) GetDocId() string {
+	if x != nil {
+		return x.DocId
+	}
+	return ""
+}
+
+func (x *BulkIndexItem) GetDocument() *structpb.Struct {
+	if x != nil {
+		return x.Document
+	}
+	return nil
+}
+
+type BulkIndexResponse struct {
+	state         protoimpl.MessageState   `protogen:"open.v1"`
+	HasErrors     bool                     `protobuf:"varint,1,opt,name=has_errors,json=hasErrors,proto3" json:"has_errors,omitempty"`
+	Items         []*BulkIndexItemResponse `protobuf:"bytes,2,rep,name=items,proto3" json:"items,omitempty"`
+	TookMillis    int64                    `protobuf:"varint,3,opt,name=took_millis,json=tookMillis,proto3" json:"took_millis,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BulkIndexResponse) Reset() {
+	*x = BulkIndexResponse{}
+	mi := &file_pkg_common_proto_data_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BulkIndexResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BulkIndexResponse) ProtoMessage() {}
+
+func (x *BulkIndexResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_common_proto_data_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BulkIndexResponse.ProtoReflect.Descriptor instead.
+func (*BulkIndexResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *BulkIndexResponse) GetHasErrors() bool {
+	if x != nil {
+		return x.HasErrors
+	}
+	return false
+}
+
+func (x *BulkIndexResponse) GetItems() []*BulkIndexItemResponse {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+func (x *BulkIndexResponse) GetTookMillis() int64 {
+	if x != nil {
+		return x.TookMillis
+	}
+	return 0
+}
+
+type BulkIndexItemResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Acknowledged  bool                   `protobuf:"varint,1,opt,name=acknowledged,proto3" json:"acknowledged,omitempty"`
+	DocId         string                 `protobuf:"bytes,2,opt,name=doc_id,json=docId,proto3" json:"doc_id,omitempty"`
+	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BulkIndexItemResponse) Reset() {
+	*x = BulkIndexItemResponse{}
+	mi := &file_pkg_common_proto_data_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BulkIndexItemResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BulkIndexItemResponse) ProtoMessage() {}
+
+func (x *BulkIndexItemResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_common_proto_data_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BulkIndexItemResponse.ProtoReflect.Descriptor instead.
+func (*BulkIndexItemResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *BulkIndexItemResponse) GetAcknowledged() bool {
+	if x != nil {
+		return x.Acknowledged
+	}
+	return false
+}
+
+func (x *BulkIndexItemResponse) GetDocId() string {
+	if x != nil {
+		return x.DocId
+	}
+	return ""
+}
+
+func (x *BulkIndexItemResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// WriteShardsInfo reports the shard copies a write was applied to
+type WriteShardsInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Total         int32                  `protobuf:"varint,1,opt,name=total,proto3" json:"total,omitempty"` // The primary and its in-sync replicas
+	Successful    int32                  `protobuf:"varint,2,opt,name=successful,proto3" json:"successful,omitempty"`
+	Failed        int32                  `protobuf:"varint,3,opt,name=failed,proto3" json:"failed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WriteShardsInfo) Reset() {
+	*x = WriteShardsInfo{}
+	mi := &file_pkg_common_proto_data_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WriteShardsInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WriteShardsInfo) ProtoMessage() {}
+
+func (x *WriteShardsInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_common_proto_data_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WriteShardsInfo.ProtoReflect.Descriptor instead.
+func (*WriteShardsInfo) Descriptor() ([]byte, []int) {
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *WriteShardsInfo) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *WriteShardsInfo) GetSuccessful() int32 {
+	if x != nil {
+		return x.Successful
+	}
+	return 0
+}
+
+func (x *WriteShardsInfo) GetFailed() int32 {
+	if x != nil {
+		return x.Failed
+	}
+	return 0
+}
+
+type ReplicateOperationsRequest struct {
+	state         protoimpl.MessageState  `protogen:"open.v1"`
+	IndexName     string                  `protobuf:"bytes,1,opt,name=index_name,json=indexName,proto3" json:"index_name,omitempty"`
+	ShardId       int32                   `protobuf:"varint,2,opt,name=shard_id,json=shardId,proto3" json:"shard_id,omitempty"`
+	PrimaryTerm   int64                   `protobuf:"varint,3,opt,name=primary_term,json=primaryTerm,proto3" json:"primary_term,omitempty"` // Term of the primary sending the operations
+	Operations    []*ReplicationOperation `protobuf:"bytes,4,rep,name=operations,proto3" json:"operations,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReplicateOperationsRequest) Reset() {
+	*x = ReplicateOperationsRequest{}
+	mi := &file_pkg_common_proto_data_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReplicateOperationsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReplicateOperationsRequest) ProtoMessage() {}
+
+func (x *ReplicateOperationsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_common_proto_data_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReplicateOperationsRequest.ProtoReflect.Descriptor instead.
+func (*ReplicateOperationsRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *ReplicateOperationsRequest) GetIndexName() string {
+	if x != nil {
+		return x.IndexName
+	}
+	return ""
+}
+
+func (x *ReplicateOperationsRequest) GetShardId() int32 {
+	if x != nil {
+		return x.ShardId
+	}
+	return 0
+}
+
+func (x *ReplicateOperationsRequest) GetPrimaryTerm() int64 {
+	if x != nil {
+		return x.PrimaryTerm
+	}
+	return 0
+}
+
+func (x *ReplicateOperationsRequest) GetOperations() []*ReplicationOperation {
+	if x != nil {
+		return x.Operations
+	}
+	return nil
+}
+
+type ReplicationOperation struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SeqNo         int64                  `protobuf:"varint,1,opt,name=seq_no,json=seqNo,proto3" json:"seq_no,omitempty"`
+	PrimaryTerm   int64                  `protobuf:"varint,2,opt,name=primary_term,json=primaryTerm,proto3" json:"primary_term,omitempty"`
+	Type          string                 `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"` // "index" or "delete"
+	DocId         string                 `protobuf:"bytes,4,opt,name=doc_id,json=docId,proto3" json:"doc_id,omitempty"`
+	Document      *structpb.Struct       `protobuf:"bytes,5,opt,name=document,proto3" json:"document,omitempty"` // Source of an index operation
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReplicationOperation) Reset() {
+	*x = ReplicationOperation{}
+	mi := &file_pkg_common_proto_data_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReplicationOperation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReplicationOperation) ProtoMessage() {}
+
+func (x *ReplicationOperation) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_common_proto_data_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReplicationOperation.ProtoReflect.Descriptor instead.
+func (*ReplicationOperation) Descriptor() ([]byte, []int) {
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *ReplicationOperation) GetSeqNo() int64 {
+	if x != nil {
+		return x.SeqNo
+	}
+	return 0
+}
+
+func (x *ReplicationOperation) GetPrimaryTerm() int64 {
+	if x != nil {
+		return x.PrimaryTerm
+	}
+	return 0
+}
+
+func (x *ReplicationOperation) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *ReplicationOperation) GetDocId() string {
+	if x != nil {
+		return x.DocId
+	}
+	return ""
+}
+
+func (x *ReplicationOperation) GetDocument() *structpb.Struct {
+	if x != nil {
+		return x.Document
+	}
+	return nil
+}
+
+type ReplicateOperationsResponse struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	LocalCheckpoint int64                  `protobuf:"varint,1,opt,name=local_checkpoint,json=localCheckpoint,proto3" json:"local_checkpoint,omitempty"` // Highest sequence number below which the replica has every operation
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *ReplicateOperationsResponse) Reset() {
+	*x = ReplicateOperationsResponse{}
+	mi := &file_pkg_common_proto_data_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReplicateOperationsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReplicateOperationsResponse) ProtoMessage() {}
+
+func (x *ReplicateOperationsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_common_proto_data_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReplicateOperationsResponse.ProtoReflect.Descriptor instead.
+func (*ReplicateOperationsResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *ReplicateOperationsResponse) GetLocalCheckpoint() int64 {
+	if x != nil {
+		return x.LocalCheckpoint
+	}
+	return 0
+}
+
+type RecoverShardRequest struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	IndexName       string                 `protobuf:"bytes,1,opt,name=index_name,json=indexName,proto3" json:"index_name,omitempty"`
+	ShardId         int32                  `protobuf:"varint,2,opt,name=shard_id,json=shardId,proto3" json:"shard_id,omitempty"`
+	TargetNodeId    string                 `protobuf:"bytes,3,opt,name=target_node_id,json=targetNodeId,proto3" json:"target_node_id,omitempty"`         // Node of the recovering replica
+	LocalCheckpoint int64                  `protobuf:"varint,4,opt,name=local_checkpoint,json=localCheckpoint,proto3" json:"local_checkpoint,omitempty"` // The replica has every operation up to this one
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *RecoverShardRequest) Reset() {
+	*x = RecoverShardRequest{}
+	mi := &file_pkg_common_proto_data_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecoverShardRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecoverShardRequest) ProtoMessage() {}
+
+func (x *RecoverShardRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_common_proto_data_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecoverShardRequest.ProtoReflect.Descriptor instead.
+func (*RecoverShardRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *RecoverShardRequest) GetIndexName() string {
+	if x != nil {
+		return x.IndexName
+	}
+	return ""
 }
 
-// Deprecated: Use DeleteDocumentResponse.ProtoReflect.Descriptor instead.
-func (*DeleteDocumentResponse) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{15}
+func (x *RecoverShardRequest) GetShardId() int32 {
+	if x != nil {
+		return x.ShardId
+	}
+	return 0
 }
 
-func (x *DeleteDocumentResponse) GetAcknowledged() bool {
+func (x *RecoverShardRequest) GetTargetNodeId() string {
 	if x != nil {
-		return x.Acknowledged
+		return x.TargetNodeId
 	}
-	return false
+	return ""
 }
 
-func (x *DeleteDocumentResponse) GetFound() bool {
+func (x *RecoverShardRequest) GetLocalCheckpoint() int64 {
 	if x != nil {
-		return x.Found
+		return x.LocalCheckpoint
 	}
-	return false
+	return 0
 }
 
-type BulkIndexRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	IndexName     string                 `protobuf:"bytes,1,opt,name=index_name,json=indexName,proto3" json:"index_name,omitempty"`
-	ShardId       int32                  `protobuf:"varint,2,opt,name=shard_id,json=shardId,proto3" json:"shard_id,omitempty"`
-	Items         []*BulkIndexItem       `protobuf:"bytes,3,rep,name=items,proto3" json:"items,omitempty"`
+// RecoverShardResponse is one message of a recovery stream: the plan comes
+// first, then the chunks of its files, then batches of operations
+type RecoverShardResponse struct {
+	state         protoimpl.MessageState  `protogen:"open.v1"`
+	Plan          *RecoveryPlan           `protobuf:"bytes,1,opt,name=plan,proto3" json:"plan,omitempty"`
+	FileChunk     *RecoveryFileChunk      `protobuf:"bytes,2,opt,name=file_chunk,json=fileChunk,proto3" json:"file_chunk,omitempty"`
+	Operations    []*ReplicationOperation `protobuf:"bytes,3,rep,name=operations,proto3" json:"operations,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *BulkIndexRequest) Reset() {
-	*x = BulkIndexRequest{}
-	mi := &file_pkg_common_proto_data_proto_msgTypes[16]
+func (x *RecoverShardResponse) Reset() {
+	*x = RecoverShardResponse{}
+	mi := &file_pkg_common_proto_data_proto_msgTypes[27]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *BulkIndexRequest) String() string {
+func (x *RecoverShardResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*BulkIndexRequest) ProtoMessage() {}
+func (*RecoverShardResponse) ProtoMessage() {}
 
-func (x *BulkIndexRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_data_proto_msgTypes[16]
+func (x *RecoverShardResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_common_proto_data_proto_msgTypes[27]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1040,55 +1807,57 @@ func (x *BulkIndexRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use BulkIndexRequest.ProtoReflect.Descriptor instead.
-func (*BulkIndexRequest) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{16}
+// Deprecated: Use RecoverShardResponse.ProtoReflect.Descriptor instead.
+func (*RecoverShardResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{27}
 }
 
-func (x *BulkIndexRequest) GetIndexName() string {
+func (x *RecoverShardResponse) GetPlan() *RecoveryPlan {
 	if x != nil {
-		return x.IndexName
+		return x.Plan
 	}
-	return ""
+	return nil
 }
 
-func (x *BulkIndexRequest) GetShardId() int32 {
+func (x *RecoverShardResponse) GetFileChunk() *RecoveryFileChunk {
 	if x != nil {
-		return x.ShardId
+		return x.FileChunk
 	}
-	return 0
+	return nil
 }
 
-func (x *BulkIndexRequest) GetItems() []*BulkIndexItem {
+func (x *RecoverShardResponse) GetOperations() []*ReplicationOperation {
 	if x != nil {
-		return x.Items
+		return x.Operations
 	}
 	return nil
 }
 
-type BulkIndexItem struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	DocId         string                 `protobuf:"bytes,1,opt,name=doc_id,json=docId,proto3" json:"doc_id,omitempty"`
-	Document      *structpb.Struct       `protobuf:"bytes,2,opt,name=document,proto3" json:"document,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+type RecoveryPlan struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Files           []*RecoveryFile        `protobuf:"bytes,1,rep,name=files,proto3" json:"files,omitempty"`                                         // Committed shard files, none when the operations are enough
+	StartingSeqNo   int64                  `protobuf:"varint,2,opt,name=starting_seq_no,json=startingSeqNo,proto3" json:"starting_seq_no,omitempty"` // Operations after this one follow the files
+	PrimaryTerm     int64                  `protobuf:"varint,3,opt,name=primary_term,json=primaryTerm,proto3" json:"primary_term,omitempty"`
+	TotalOperations int64                  `protobuf:"varint,4,opt,name=total_operations,json=totalOperations,proto3" json:"total_operations,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
 }
 
-func (x *BulkIndexItem) Reset() {
-	*x = BulkIndexItem{}
-	mi := &file_pkg_common_proto_data_proto_msgTypes[17]
+func (x *RecoveryPlan) Reset() {
+	*x = RecoveryPlan{}
+	mi := &file_pkg_common_proto_data_proto_msgTypes[28]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *BulkIndexItem) String() string {
+func (x *RecoveryPlan) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*BulkIndexItem) ProtoMessage() {}
+func (*RecoveryPlan) ProtoMessage() {}
 
-func (x *BulkIndexItem) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_data_proto_msgTypes[17]
+func (x *RecoveryPlan) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_common_proto_data_proto_msgTypes[28]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1099,49 +1868,62 @@ func (x *BulkIndexItem) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use BulkIndexItem.ProtoReflect.Descriptor instead.
-func (*BulkIndexItem) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{17}
+// Deprecated: Use RecoveryPlan.ProtoReflect.Descriptor instead.
+func (*RecoveryPlan) Descriptor() ([]byte, []int) {
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{28}
 }
 
-func (x *BulkIndexItem) GetDocId() string {
+func (x *RecoveryPlan) GetFiles() []*RecoveryFile {
 	if x != nil {
-		return x.DocId
+		return x.Files
 	}
-	return ""
+	return nil
 }
 
-func (x *BulkIndexItem) GetDocument() *structpb.Struct {
+func (x *RecoveryPlan) GetStartingSeqNo() int64 {
 	if x != nil {
-		return x.Document
+		return x.StartingSeqNo
 	}
-	return nil
+	return 0
 }
 
-type BulkIndexResponse struct {
-	state         protoimpl.MessageState   `protogen:"open.v1"`
-	HasErrors     bool                     `protobuf:"varint,1,opt,name=has_errors,json=hasErrors,proto3" json:"has_errors,omitempty"`
-	Items         []*BulkIndexItemResponse `protobuf:"bytes,2,rep,name=items,proto3" json:"items,omitempty"`
-	TookMillis    int64                    `protobuf:"varint,3,opt,name=took_millis,json=tookMillis,proto3" json:"took_millis,omitempty"`
+func (x *RecoveryPlan) GetPrimaryTerm() int64 {
+	if x != nil {
+		return x.PrimaryTerm
+	}
+	return 0
+}
+
+func (x *RecoveryPlan) GetTotalOperations() int64 {
+	if x != nil {
+		return x.TotalOperations
+	}
+	return 0
+}
+
+type RecoveryFile struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"` // Path relative to the shard directory
+	Size          int64                  `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *BulkIndexResponse) Reset() {
-	*x = BulkIndexResponse{}
-	mi := &file_pkg_common_proto_data_proto_msgTypes[18]
+func (x *RecoveryFile) Reset() {
+	*x = RecoveryFile{}
+	mi := &file_pkg_common_proto_data_proto_msgTypes[29]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *BulkIndexResponse) String() string {
+func (x *RecoveryFile) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*BulkIndexResponse) ProtoMessage() {}
+func (*RecoveryFile) ProtoMessage() {}
 
-func (x *BulkIndexResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_data_proto_msgTypes[18]
+func (x *RecoveryFile) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_common_proto_data_proto_msgTypes[29]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1152,56 +1934,49 @@ func (x *BulkIndexResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use BulkIndexResponse.ProtoReflect.Descriptor instead.
-func (*BulkIndexResponse) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{18}
-}
-
-func (x *BulkIndexResponse) GetHasErrors() bool {
-	if x != nil {
-		return x.HasErrors
-	}
-	return false
+// Deprecated: Use RecoveryFile.ProtoReflect.Descriptor instead.
+func (*RecoveryFile) Descriptor() ([]byte, []int) {
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{29}
 }
 
-func (x *BulkIndexResponse) GetItems() []*BulkIndexItemResponse {
+func (x *RecoveryFile) GetName() string {
 	if x != nil {
-		return x.Items
+		return x.Name
 	}
-	return nil
+	return ""
 }
 
-func (x *BulkIndexResponse) GetTookMillis() int64 {
+func (x *RecoveryFile) GetSize() int64 {
 	if x != nil {
-		return x.TookMillis
+		return x.Size
 	}
 	return 0
 }
 
-type BulkIndexItemResponse struct {
+type RecoveryFileChunk struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Acknowledged  bool                   `protobuf:"varint,1,opt,name=acknowledged,proto3" json:"acknowledged,omitempty"`
-	DocId         string                 `protobuf:"bytes,2,opt,name=doc_id,json=docId,proto3" json:"doc_id,omitempty"`
-	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Offset        int64                  `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	Data          []byte                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *BulkIndexItemResponse) Reset() {
-	*x = BulkIndexItemResponse{}
-	mi := &file_pkg_common_proto_data_proto_msgTypes[19]
+func (x *RecoveryFileChunk) Reset() {
+	*x = RecoveryFileChunk{}
+	mi := &file_pkg_common_proto_data_proto_msgTypes[30]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *BulkIndexItemResponse) String() string {
+func (x *RecoveryFileChunk) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*BulkIndexItemResponse) ProtoMessage() {}
+func (*RecoveryFileChunk) ProtoMessage() {}
 
-func (x *BulkIndexItemResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_data_proto_msgTypes[19]
+func (x *RecoveryFileChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_common_proto_data_proto_msgTypes[30]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1212,30 +1987,30 @@ func (x *BulkIndexItemResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use BulkIndexItemResponse.ProtoReflect.Descriptor instead.
-func (*BulkIndexItemResponse) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{19}
+// Deprecated: Use RecoveryFileChunk.ProtoReflect.Descriptor instead.
+func (*RecoveryFileChunk) Descriptor() ([]byte, []int) {
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{30}
 }
 
-func (x *BulkIndexItemResponse) GetAcknowledged() bool {
+func (x *RecoveryFileChunk) GetName() string {
 	if x != nil {
-		return x.Acknowledged
+		return x.Name
 	}
-	return false
+	return ""
 }
 
-func (x *BulkIndexItemResponse) GetDocId() string {
+func (x *RecoveryFileChunk) GetOffset() int64 {
 	if x != nil {
-		return x.DocId
+		return x.Offset
 	}
-	return ""
+	return 0
 }
 
-func (x *BulkIndexItemResponse) GetError() string {
+func (x *RecoveryFileChunk) GetData() []byte {
 	if x != nil {
-		return x.Error
+		return x.Data
 	}
-	return ""
+	return nil
 }
 
 type SearchRequest struct {
@@ -1248,13 +2023,14 @@ type SearchRequest struct {
 	Sort             []string               `protobuf:"bytes,6,rep,name=sort,proto3" json:"sort,omitempty"`
 	TrackTotalHits   bool                   `protobuf:"varint,7,opt,name=track_total_hits,json=trackTotalHits,proto3" json:"track_total_hits,omitempty"`
 	FilterExpression []byte                 `protobuf:"bytes,8,opt,name=filter_expression,json=filterExpression,proto3" json:"filter_expression,omitempty"` // Serialized expression tree for native C++ evaluation
+	Aggregations     []byte                 `protobuf:"bytes,9,opt,name=aggregations,proto3" json:"aggregations,omitempty"`                                 // Serialized aggs section of the search request
 	unknownFields    protoimpl.UnknownFields
 	sizeCache        protoimpl.SizeCache
 }
 
 func (x *SearchRequest) Reset() {
 	*x = SearchRequest{}
-	mi := &file_pkg_common_proto_data_proto_msgTypes[20]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[31]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1266,7 +2042,7 @@ func (x *SearchRequest) String() string {
 func (*SearchRequest) ProtoMessage() {}
 
 func (x *SearchRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_data_proto_msgTypes[20]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[31]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1279,7 +2055,7 @@ func (x *SearchRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SearchRequest.ProtoReflect.Descriptor instead.
 func (*SearchRequest) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{20}
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{31}
 }
 
 func (x *SearchRequest) GetIndexName() string {
@@ -1338,6 +2114,13 @@ func (x *SearchRequest) GetFilterExpression() []byte {
 	return nil
 }
 
+func (x *SearchRequest) GetAggregations() []byte {
+	if x != nil {
+		return x.Aggregations
+	}
+	return nil
+}
+
 type SearchResponse struct {
 	state         protoimpl.MessageState        `protogen:"open.v1"`
 	TookMillis    int64                         `protobuf:"varint,1,opt,name=took_millis,json=tookMillis,proto3" json:"took_millis,omitempty"`
@@ -1351,7 +2134,7 @@ type SearchResponse struct {
 
 func (x *SearchResponse) Reset() {
 	*x = SearchResponse{}
-	mi := &file_pkg_common_proto_data_proto_msgTypes[21]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[32]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1363,7 +2146,7 @@ func (x *SearchResponse) String() string {
 func (*SearchResponse) ProtoMessage() {}
 
 func (x *SearchResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_data_proto_msgTypes[21]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[32]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1376,7 +2159,7 @@ func (x *SearchResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SearchResponse.ProtoReflect.Descriptor instead.
 func (*SearchResponse) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{21}
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{32}
 }
 
 func (x *SearchResponse) GetTookMillis() int64 {
@@ -1425,7 +2208,7 @@ type ShardSearchStats struct {
 
 func (x *ShardSearchStats) Reset() {
 	*x = ShardSearchStats{}
-	mi := &file_pkg_common_proto_data_proto_msgTypes[22]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[33]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1437,7 +2220,7 @@ func (x *ShardSearchStats) String() string {
 func (*ShardSearchStats) ProtoMessage() {}
 
 func (x *ShardSearchStats) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_data_proto_msgTypes[22]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[33]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1450,7 +2233,7 @@ func (x *ShardSearchStats) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ShardSearchStats.ProtoReflect.Descriptor instead.
 func (*ShardSearchStats) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{22}
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{33}
 }
 
 func (x *ShardSearchStats) GetTotal() int32 {
@@ -1485,7 +2268,7 @@ type SearchHits struct {
 
 func (x *SearchHits) Reset() {
 	*x = SearchHits{}
-	mi := &file_pkg_common_proto_data_proto_msgTypes[23]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[34]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1497,7 +2280,7 @@ func (x *SearchHits) String() string {
 func (*SearchHits) ProtoMessage() {}
 
 func (x *SearchHits) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_data_proto_msgTypes[23]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[34]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1510,7 +2293,7 @@ func (x *SearchHits) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SearchHits.ProtoReflect.Descriptor instead.
 func (*SearchHits) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{23}
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{34}
 }
 
 func (x *SearchHits) GetTotal() *TotalHits {
@@ -1544,7 +2327,7 @@ type TotalHits struct {
 
 func (x *TotalHits) Reset() {
 	*x = TotalHits{}
-	mi := &file_pkg_common_proto_data_proto_msgTypes[24]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[35]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1556,7 +2339,7 @@ func (x *TotalHits) String() string {
 func (*TotalHits) ProtoMessage() {}
 
 func (x *TotalHits) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_data_proto_msgTypes[24]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[35]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1569,7 +2352,7 @@ func (x *TotalHits) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TotalHits.ProtoReflect.Descriptor instead.
 func (*TotalHits) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{24}
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{35}
 }
 
 func (x *TotalHits) GetValue() int64 {
@@ -1591,14 +2374,14 @@ type SearchHit struct {
 	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
 	Score         float64                `protobuf:"fixed64,2,opt,name=score,proto3" json:"score,omitempty"`
 	Source        *structpb.Struct       `protobuf:"bytes,3,opt,name=source,proto3" json:"source,omitempty"`
-	Sort          []float64              `protobuf:"fixed64,4,rep,packed,name=sort,proto3" json:"sort,omitempty"`
+	Sort          []*structpb.Value      `protobuf:"bytes,4,rep,name=sort,proto3" json:"sort,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *SearchHit) Reset() {
 	*x = SearchHit{}
-	mi := &file_pkg_common_proto_data_proto_msgTypes[25]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[36]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1610,7 +2393,7 @@ func (x *SearchHit) String() string {
 func (*SearchHit) ProtoMessage() {}
 
 func (x *SearchHit) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_data_proto_msgTypes[25]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[36]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1623,7 +2406,7 @@ func (x *SearchHit) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SearchHit.ProtoReflect.Descriptor instead.
 func (*SearchHit) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{25}
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{36}
 }
 
 func (x *SearchHit) GetId() string {
@@ -1647,7 +2430,7 @@ func (x *SearchHit) GetSource() *structpb.Struct {
 	return nil
 }
 
-func (x *SearchHit) GetSort() []float64 {
+func (x *SearchHit) GetSort() []*structpb.Value {
 	if x != nil {
 		return x.Sort
 	}
@@ -1656,8 +2439,8 @@ func (x *SearchHit) GetSort() []float64 {
 
 type AggregationResult struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
-	Type  string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"` // terms, stats, histogram, date_histogram, percentiles, cardinality, extended_stats
-	// Terms aggregation
+	Type  string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"` // terms, stats, histogram, date_histogram, percentiles, cardinality, extended_stats, avg, min, max, sum, value_count, range, filters
+	// Terms aggregation, Range aggregation, Filters aggregation
 	Buckets []*AggregationBucket `protobuf:"bytes,2,rep,name=buckets,proto3" json:"buckets,omitempty"`
 	// Stats/Extended Stats aggregation
 	Count                   int64   `protobuf:"varint,3,opt,name=count,proto3" json:"count,omitempty"`
@@ -1673,14 +2456,23 @@ type AggregationResult struct {
 	// Percentiles aggregation
 	Values map[string]float64 `protobuf:"bytes,13,rep,name=values,proto3" json:"values,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"fixed64,2,opt,name=value"` // percentile -> value
 	// Cardinality aggregation
-	Value         int64 `protobuf:"varint,14,opt,name=value,proto3" json:"value,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	Value int64 `protobuf:"varint,14,opt,name=value,proto3" json:"value,omitempty"`
+	// Serialized t-digest of a percentiles or percentile_ranks aggregation, or
+	// HyperLogLog++ sketch of a cardinality aggregation, merged by the
+	// coordinator
+	Sketch []byte `protobuf:"bytes,15,opt,name=sketch,proto3" json:"sketch,omitempty"`
+	// Terms aggregation: the most a bucket's count may be understated by the
+	// shards' top-N truncation (-1 if unbounded), and the count of documents in
+	// terms not returned
+	DocCountErrorUpperBound int64 `protobuf:"varint,16,opt,name=doc_count_error_upper_bound,json=docCountErrorUpperBound,proto3" json:"doc_count_error_upper_bound,omitempty"`
+	SumOtherDocCount        int64 `protobuf:"varint,17,opt,name=sum_other_doc_count,json=sumOtherDocCount,proto3" json:"sum_other_doc_count,omitempty"`
+	unknownFields           protoimpl.UnknownFields
+	sizeCache               protoimpl.SizeCache
 }
 
 func (x *AggregationResult) Reset() {
 	*x = AggregationResult{}
-	mi := &file_pkg_common_proto_data_proto_msgTypes[26]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[37]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1692,7 +2484,7 @@ func (x *AggregationResult) String() string {
 func (*AggregationResult) ProtoMessage() {}
 
 func (x *AggregationResult) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_data_proto_msgTypes[26]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[37]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1705,7 +2497,7 @@ func (x *AggregationResult) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AggregationResult.ProtoReflect.Descriptor instead.
 func (*AggregationResult) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{26}
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{37}
 }
 
 func (x *AggregationResult) GetType() string {
@@ -1806,19 +2598,46 @@ func (x *AggregationResult) GetValue() int64 {
 	return 0
 }
 
+func (x *AggregationResult) GetSketch() []byte {
+	if x != nil {
+		return x.Sketch
+	}
+	return nil
+}
+
+func (x *AggregationResult) GetDocCountErrorUpperBound() int64 {
+	if x != nil {
+		return x.DocCountErrorUpperBound
+	}
+	return 0
+}
+
+func (x *AggregationResult) GetSumOtherDocCount() int64 {
+	if x != nil {
+		return x.SumOtherDocCount
+	}
+	return 0
+}
+
 type AggregationBucket struct {
 	state           protoimpl.MessageState        `protogen:"open.v1"`
-	Key             string                        `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`                                   // For terms, date histogram key_as_string
+	Key             string                        `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`                                   // For terms, date histogram key_as_string, range
 	NumericKey      float64                       `protobuf:"fixed64,2,opt,name=numeric_key,json=numericKey,proto3" json:"numeric_key,omitempty"` // For histogram, date histogram timestamp
 	DocCount        int64                         `protobuf:"varint,3,opt,name=doc_count,json=docCount,proto3" json:"doc_count,omitempty"`
 	SubAggregations map[string]*AggregationResult `protobuf:"bytes,4,rep,name=sub_aggregations,json=subAggregations,proto3" json:"sub_aggregations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // For nested aggregations
-	unknownFields   protoimpl.UnknownFields
-	sizeCache       protoimpl.SizeCache
+	// Range aggregation fields
+	From *float64 `protobuf:"fixed64,5,opt,name=from,proto3,oneof" json:"from,omitempty"` // Lower bound for range (omitted if unbounded)
+	To   *float64 `protobuf:"fixed64,6,opt,name=to,proto3,oneof" json:"to,omitempty"`     // Upper bound for range (omitted if unbounded)
+	// Composite aggregation: the bucket's key as a JSON object of source name
+	// to value
+	CompositeKey  []byte `protobuf:"bytes,7,opt,name=composite_key,json=compositeKey,proto3" json:"composite_key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *AggregationBucket) Reset() {
 	*x = AggregationBucket{}
-	mi := &file_pkg_common_proto_data_proto_msgTypes[27]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[38]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1830,7 +2649,7 @@ func (x *AggregationBucket) String() string {
 func (*AggregationBucket) ProtoMessage() {}
 
 func (x *AggregationBucket) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_data_proto_msgTypes[27]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[38]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1843,7 +2662,7 @@ func (x *AggregationBucket) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AggregationBucket.ProtoReflect.Descriptor instead.
 func (*AggregationBucket) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{27}
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{38}
 }
 
 func (x *AggregationBucket) GetKey() string {
@@ -1874,6 +2693,27 @@ func (x *AggregationBucket) GetSubAggregations() map[string]*AggregationResult {
 	return nil
 }
 
+func (x *AggregationBucket) GetFrom() float64 {
+	if x != nil && x.From != nil {
+		return *x.From
+	}
+	return 0
+}
+
+func (x *AggregationBucket) GetTo() float64 {
+	if x != nil && x.To != nil {
+		return *x.To
+	}
+	return 0
+}
+
+func (x *AggregationBucket) GetCompositeKey() []byte {
+	if x != nil {
+		return x.CompositeKey
+	}
+	return nil
+}
+
 type CountRequest struct {
 	state            protoimpl.MessageState `protogen:"open.v1"`
 	IndexName        string                 `protobuf:"bytes,1,opt,name=index_name,json=indexName,proto3" json:"index_name,omitempty"`
@@ -1886,7 +2726,7 @@ type CountRequest struct {
 
 func (x *CountRequest) Reset() {
 	*x = CountRequest{}
-	mi := &file_pkg_common_proto_data_proto_msgTypes[28]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[39]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1898,7 +2738,7 @@ func (x *CountRequest) String() string {
 func (*CountRequest) ProtoMessage() {}
 
 func (x *CountRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_data_proto_msgTypes[28]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[39]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1911,7 +2751,7 @@ func (x *CountRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CountRequest.ProtoReflect.Descriptor instead.
 func (*CountRequest) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{28}
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{39}
 }
 
 func (x *CountRequest) GetIndexName() string {
@@ -1951,7 +2791,7 @@ type CountResponse struct {
 
 func (x *CountResponse) Reset() {
 	*x = CountResponse{}
-	mi := &file_pkg_common_proto_data_proto_msgTypes[29]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[40]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1963,7 +2803,7 @@ func (x *CountResponse) String() string {
 func (*CountResponse) ProtoMessage() {}
 
 func (x *CountResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_data_proto_msgTypes[29]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[40]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1976,7 +2816,7 @@ func (x *CountResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CountResponse.ProtoReflect.Descriptor instead.
 func (*CountResponse) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{29}
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{40}
 }
 
 func (x *CountResponse) GetCount() int64 {
@@ -1996,7 +2836,7 @@ type GetShardStatsRequest struct {
 
 func (x *GetShardStatsRequest) Reset() {
 	*x = GetShardStatsRequest{}
-	mi := &file_pkg_common_proto_data_proto_msgTypes[30]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[41]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2008,7 +2848,7 @@ func (x *GetShardStatsRequest) String() string {
 func (*GetShardStatsRequest) ProtoMessage() {}
 
 func (x *GetShardStatsRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_data_proto_msgTypes[30]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[41]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2021,7 +2861,7 @@ func (x *GetShardStatsRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetShardStatsRequest.ProtoReflect.Descriptor instead.
 func (*GetShardStatsRequest) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{30}
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{41}
 }
 
 func (x *GetShardStatsRequest) GetIndexName() string {
@@ -2056,7 +2896,7 @@ type ShardStats struct {
 
 func (x *ShardStats) Reset() {
 	*x = ShardStats{}
-	mi := &file_pkg_common_proto_data_proto_msgTypes[31]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[42]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2068,7 +2908,7 @@ func (x *ShardStats) String() string {
 func (*ShardStats) ProtoMessage() {}
 
 func (x *ShardStats) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_data_proto_msgTypes[31]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[42]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2081,7 +2921,7 @@ func (x *ShardStats) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ShardStats.ProtoReflect.Descriptor instead.
 func (*ShardStats) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{31}
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{42}
 }
 
 func (x *ShardStats) GetIndexName() string {
@@ -2163,7 +3003,7 @@ type GetNodeStatsRequest struct {
 
 func (x *GetNodeStatsRequest) Reset() {
 	*x = GetNodeStatsRequest{}
-	mi := &file_pkg_common_proto_data_proto_msgTypes[32]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[43]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2175,7 +3015,7 @@ func (x *GetNodeStatsRequest) String() string {
 func (*GetNodeStatsRequest) ProtoMessage() {}
 
 func (x *GetNodeStatsRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_data_proto_msgTypes[32]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[43]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2188,7 +3028,7 @@ func (x *GetNodeStatsRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetNodeStatsRequest.ProtoReflect.Descriptor instead.
 func (*GetNodeStatsRequest) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{32}
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{43}
 }
 
 func (x *GetNodeStatsRequest) GetIncludeShards() bool {
@@ -2215,7 +3055,7 @@ type DataNodeStats struct {
 
 func (x *DataNodeStats) Reset() {
 	*x = DataNodeStats{}
-	mi := &file_pkg_common_proto_data_proto_msgTypes[33]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[44]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2227,7 +3067,7 @@ func (x *DataNodeStats) String() string {
 func (*DataNodeStats) ProtoMessage() {}
 
 func (x *DataNodeStats) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_data_proto_msgTypes[33]
+	mi := &file_pkg_common_proto_data_proto_msgTypes[44]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2240,7 +3080,7 @@ func (x *DataNodeStats) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DataNodeStats.ProtoReflect.Descriptor instead.
 func (*DataNodeStats) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{33}
+	return file_pkg_common_proto_data_proto_rawDescGZIP(), []int{44}
 }
 
 func (x *DataNodeStats) GetNodeId() string {
@@ -2310,14 +3150,15 @@ var File_pkg_common_proto_data_proto protoreflect.FileDescriptor
 
 const file_pkg_common_proto_data_proto_rawDesc = "" +
 	"\n" +
-	"\x1bpkg/common/proto/data.proto\x12\x0equidditch.data\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xf8\x01\n" +
+	"\x1bpkg/common/proto/data.proto\x12\x0econjugate.data\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\x9b\x02\n" +
 	"\x12CreateShardRequest\x12\x1d\n" +
 	"\n" +
 	"index_name\x18\x01 \x01(\tR\tindexName\x12\x19\n" +
 	"\bshard_id\x18\x02 \x01(\x05R\ashardId\x12\x1d\n" +
 	"\n" +
 	"is_primary\x18\x03 \x01(\bR\tisPrimary\x12L\n" +
-	"\bsettings\x18\x04 \x03(\v20.quidditch.data.CreateShardRequest.SettingsEntryR\bsettings\x1a;\n" +
+	"\bsettings\x18\x04 \x03(\v20.conjugate.data.CreateShardRequest.SettingsEntryR\bsettings\x12!\n" +
+	"\fprimary_term\x18\x05 \x01(\x03R\vprimaryTerm\x1a;\n" +
 	"\rSettingsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
 	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"V\n" +
@@ -2340,7 +3181,7 @@ const file_pkg_common_proto_data_proto_rawDesc = "" +
 	"\bshard_id\x18\x02 \x01(\x05R\ashardId\x12\x1d\n" +
 	"\n" +
 	"is_primary\x18\x03 \x01(\bR\tisPrimary\x12:\n" +
-	"\x05state\x18\x04 \x01(\x0e2$.quidditch.data.ShardInfo.ShardStateR\x05state\x12\x1d\n" +
+	"\x05state\x18\x04 \x01(\x0e2$.conjugate.data.ShardInfo.ShardStateR\x05state\x12\x1d\n" +
 	"\n" +
 	"docs_count\x18\x05 \x01(\x03R\tdocsCount\x12\x1d\n" +
 	"\n" +
@@ -2364,19 +3205,35 @@ const file_pkg_common_proto_data_proto_rawDesc = "" +
 	"\x11FlushShardRequest\x12\x1d\n" +
 	"\n" +
 	"index_name\x18\x01 \x01(\tR\tindexName\x12\x19\n" +
-	"\bshard_id\x18\x02 \x01(\x05R\ashardId\"8\n" +
+	"\bshard_id\x18\x02 \x01(\x05R\ashardId\"i\n" +
 	"\x12FlushShardResponse\x12\"\n" +
-	"\facknowledged\x18\x01 \x01(\bR\facknowledged\"\x9c\x01\n" +
+	"\facknowledged\x18\x01 \x01(\bR\facknowledged\x12/\n" +
+	"\x13translog_generation\x18\x02 \x01(\x03R\x12translogGeneration\"\xe9\x01\n" +
+	"\x1aUpdateShardSettingsRequest\x12\x1d\n" +
+	"\n" +
+	"index_name\x18\x01 \x01(\tR\tindexName\x12\x19\n" +
+	"\bshard_id\x18\x02 \x01(\x05R\ashardId\x12T\n" +
+	"\bsettings\x18\x03 \x03(\v28.conjugate.data.UpdateShardSettingsRequest.SettingsEntryR\bsettings\x1a;\n" +
+	"\rSettingsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"A\n" +
+	"\x1bUpdateShardSettingsResponse\x12\"\n" +
+	"\facknowledged\x18\x01 \x01(\bR\facknowledged\"\xeb\x01\n" +
 	"\x14IndexDocumentRequest\x12\x1d\n" +
 	"\n" +
 	"index_name\x18\x01 \x01(\tR\tindexName\x12\x19\n" +
 	"\bshard_id\x18\x02 \x01(\x05R\ashardId\x12\x15\n" +
 	"\x06doc_id\x18\x03 \x01(\tR\x05docId\x123\n" +
-	"\bdocument\x18\x04 \x01(\v2\x17.google.protobuf.StructR\bdocument\"l\n" +
+	"\bdocument\x18\x04 \x01(\v2\x17.google.protobuf.StructR\bdocument\x12\x18\n" +
+	"\arefresh\x18\x05 \x01(\tR\arefresh\x123\n" +
+	"\x16wait_for_active_shards\x18\x06 \x01(\tR\x13waitForActiveShards\"\xdf\x01\n" +
 	"\x15IndexDocumentResponse\x12\"\n" +
 	"\facknowledged\x18\x01 \x01(\bR\facknowledged\x12\x15\n" +
 	"\x06doc_id\x18\x02 \x01(\tR\x05docId\x12\x18\n" +
-	"\aversion\x18\x03 \x01(\x03R\aversion\"e\n" +
+	"\aversion\x18\x03 \x01(\x03R\aversion\x12\x15\n" +
+	"\x06seq_no\x18\x04 \x01(\x03R\x05seqNo\x12!\n" +
+	"\fprimary_term\x18\x05 \x01(\x03R\vprimaryTerm\x127\n" +
+	"\x06shards\x18\x06 \x01(\v2\x1f.conjugate.data.WriteShardsInfoR\x06shards\"e\n" +
 	"\x12GetDocumentRequest\x12\x1d\n" +
 	"\n" +
 	"index_name\x18\x01 \x01(\tR\tindexName\x12\x19\n" +
@@ -2386,33 +3243,87 @@ const file_pkg_common_proto_data_proto_rawDesc = "" +
 	"\x05found\x18\x01 \x01(\bR\x05found\x12\x15\n" +
 	"\x06doc_id\x18\x02 \x01(\tR\x05docId\x123\n" +
 	"\bdocument\x18\x03 \x01(\v2\x17.google.protobuf.StructR\bdocument\x12\x18\n" +
-	"\aversion\x18\x04 \x01(\x03R\aversion\"h\n" +
+	"\aversion\x18\x04 \x01(\x03R\aversion\"\xb7\x01\n" +
 	"\x15DeleteDocumentRequest\x12\x1d\n" +
 	"\n" +
 	"index_name\x18\x01 \x01(\tR\tindexName\x12\x19\n" +
 	"\bshard_id\x18\x02 \x01(\x05R\ashardId\x12\x15\n" +
-	"\x06doc_id\x18\x03 \x01(\tR\x05docId\"R\n" +
+	"\x06doc_id\x18\x03 \x01(\tR\x05docId\x12\x18\n" +
+	"\arefresh\x18\x04 \x01(\tR\arefresh\x123\n" +
+	"\x16wait_for_active_shards\x18\x05 \x01(\tR\x13waitForActiveShards\"\xc5\x01\n" +
 	"\x16DeleteDocumentResponse\x12\"\n" +
 	"\facknowledged\x18\x01 \x01(\bR\facknowledged\x12\x14\n" +
-	"\x05found\x18\x02 \x01(\bR\x05found\"\x81\x01\n" +
+	"\x05found\x18\x02 \x01(\bR\x05found\x12\x15\n" +
+	"\x06seq_no\x18\x03 \x01(\x03R\x05seqNo\x12!\n" +
+	"\fprimary_term\x18\x04 \x01(\x03R\vprimaryTerm\x127\n" +
+	"\x06shards\x18\x05 \x01(\v2\x1f.conjugate.data.WriteShardsInfoR\x06shards\"\xd0\x01\n" +
 	"\x10BulkIndexRequest\x12\x1d\n" +
 	"\n" +
 	"index_name\x18\x01 \x01(\tR\tindexName\x12\x19\n" +
 	"\bshard_id\x18\x02 \x01(\x05R\ashardId\x123\n" +
-	"\x05items\x18\x03 \x03(\v2\x1d.quidditch.data.BulkIndexItemR\x05items\"[\n" +
+	"\x05items\x18\x03 \x03(\v2\x1d.conjugate.data.BulkIndexItemR\x05items\x12\x18\n" +
+	"\arefresh\x18\x04 \x01(\tR\arefresh\x123\n" +
+	"\x16wait_for_active_shards\x18\x05 \x01(\tR\x13waitForActiveShards\"[\n" +
 	"\rBulkIndexItem\x12\x15\n" +
 	"\x06doc_id\x18\x01 \x01(\tR\x05docId\x123\n" +
 	"\bdocument\x18\x02 \x01(\v2\x17.google.protobuf.StructR\bdocument\"\x90\x01\n" +
 	"\x11BulkIndexResponse\x12\x1d\n" +
 	"\n" +
 	"has_errors\x18\x01 \x01(\bR\thasErrors\x12;\n" +
-	"\x05items\x18\x02 \x03(\v2%.quidditch.data.BulkIndexItemResponseR\x05items\x12\x1f\n" +
+	"\x05items\x18\x02 \x03(\v2%.conjugate.data.BulkIndexItemResponseR\x05items\x12\x1f\n" +
 	"\vtook_millis\x18\x03 \x01(\x03R\n" +
 	"tookMillis\"h\n" +
 	"\x15BulkIndexItemResponse\x12\"\n" +
 	"\facknowledged\x18\x01 \x01(\bR\facknowledged\x12\x15\n" +
 	"\x06doc_id\x18\x02 \x01(\tR\x05docId\x12\x14\n" +
-	"\x05error\x18\x03 \x01(\tR\x05error\"\xf2\x01\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\"_\n" +
+	"\x0fWriteShardsInfo\x12\x14\n" +
+	"\x05total\x18\x01 \x01(\x05R\x05total\x12\x1e\n" +
+	"\n" +
+	"successful\x18\x02 \x01(\x05R\n" +
+	"successful\x12\x16\n" +
+	"\x06failed\x18\x03 \x01(\x05R\x06failed\"\xbf\x01\n" +
+	"\x1aReplicateOperationsRequest\x12\x1d\n" +
+	"\n" +
+	"index_name\x18\x01 \x01(\tR\tindexName\x12\x19\n" +
+	"\bshard_id\x18\x02 \x01(\x05R\ashardId\x12!\n" +
+	"\fprimary_term\x18\x03 \x01(\x03R\vprimaryTerm\x12D\n" +
+	"\n" +
+	"operations\x18\x04 \x03(\v2$.conjugate.data.ReplicationOperationR\n" +
+	"operations\"\xb0\x01\n" +
+	"\x14ReplicationOperation\x12\x15\n" +
+	"\x06seq_no\x18\x01 \x01(\x03R\x05seqNo\x12!\n" +
+	"\fprimary_term\x18\x02 \x01(\x03R\vprimaryTerm\x12\x12\n" +
+	"\x04type\x18\x03 \x01(\tR\x04type\x12\x15\n" +
+	"\x06doc_id\x18\x04 \x01(\tR\x05docId\x123\n" +
+	"\bdocument\x18\x05 \x01(\v2\x17.google.protobuf.StructR\bdocument\"H\n" +
+	"\x1bReplicateOperationsResponse\x12)\n" +
+	"\x10local_checkpoint\x18\x01 \x01(\x03R\x0flocalCheckpoint\"\xa0\x01\n" +
+	"\x13RecoverShardRequest\x12\x1d\n" +
+	"\n" +
+	"index_name\x18\x01 \x01(\tR\tindexName\x12\x19\n" +
+	"\bshard_id\x18\x02 \x01(\x05R\ashardId\x12$\n" +
+	"\x0etarget_node_id\x18\x03 \x01(\tR\ftargetNodeId\x12)\n" +
+	"\x10local_checkpoint\x18\x04 \x01(\x03R\x0flocalCheckpoint\"\xd0\x01\n" +
+	"\x14RecoverShardResponse\x120\n" +
+	"\x04plan\x18\x01 \x01(\v2\x1c.conjugate.data.RecoveryPlanR\x04plan\x12@\n" +
+	"\n" +
+	"file_chunk\x18\x02 \x01(\v2!.conjugate.data.RecoveryFileChunkR\tfileChunk\x12D\n" +
+	"\n" +
+	"operations\x18\x03 \x03(\v2$.conjugate.data.ReplicationOperationR\n" +
+	"operations\"\xb8\x01\n" +
+	"\fRecoveryPlan\x122\n" +
+	"\x05files\x18\x01 \x03(\v2\x1c.conjugate.data.RecoveryFileR\x05files\x12&\n" +
+	"\x0fstarting_seq_no\x18\x02 \x01(\x03R\rstartingSeqNo\x12!\n" +
+	"\fprimary_term\x18\x03 \x01(\x03R\vprimaryTerm\x12)\n" +
+	"\x10total_operations\x18\x04 \x01(\x03R\x0ftotalOperations\"6\n" +
+	"\fRecoveryFile\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\x03R\x04size\"S\n" +
+	"\x11RecoveryFileChunk\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x16\n" +
+	"\x06offset\x18\x02 \x01(\x03R\x06offset\x12\x12\n" +
+	"\x04data\x18\x03 \x01(\fR\x04data\"\x96\x02\n" +
 	"\rSearchRequest\x12\x1d\n" +
 	"\n" +
 	"index_name\x18\x01 \x01(\tR\tindexName\x12\x19\n" +
@@ -2422,17 +3333,18 @@ const file_pkg_common_proto_data_proto_rawDesc = "" +
 	"\x04size\x18\x05 \x01(\x05R\x04size\x12\x12\n" +
 	"\x04sort\x18\x06 \x03(\tR\x04sort\x12(\n" +
 	"\x10track_total_hits\x18\a \x01(\bR\x0etrackTotalHits\x12+\n" +
-	"\x11filter_expression\x18\b \x01(\fR\x10filterExpression\"\xf2\x02\n" +
+	"\x11filter_expression\x18\b \x01(\fR\x10filterExpression\x12\"\n" +
+	"\faggregations\x18\t \x01(\fR\faggregations\"\xf2\x02\n" +
 	"\x0eSearchResponse\x12\x1f\n" +
 	"\vtook_millis\x18\x01 \x01(\x03R\n" +
 	"tookMillis\x12\x1b\n" +
 	"\ttimed_out\x18\x02 \x01(\bR\btimedOut\x128\n" +
-	"\x06shards\x18\x03 \x01(\v2 .quidditch.data.ShardSearchStatsR\x06shards\x12.\n" +
-	"\x04hits\x18\x04 \x01(\v2\x1a.quidditch.data.SearchHitsR\x04hits\x12T\n" +
-	"\faggregations\x18\x05 \x03(\v20.quidditch.data.SearchResponse.AggregationsEntryR\faggregations\x1ab\n" +
+	"\x06shards\x18\x03 \x01(\v2 .conjugate.data.ShardSearchStatsR\x06shards\x12.\n" +
+	"\x04hits\x18\x04 \x01(\v2\x1a.conjugate.data.SearchHitsR\x04hits\x12T\n" +
+	"\faggregations\x18\x05 \x03(\v20.conjugate.data.SearchResponse.AggregationsEntryR\faggregations\x1ab\n" +
 	"\x11AggregationsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x127\n" +
-	"\x05value\x18\x02 \x01(\v2!.quidditch.data.AggregationResultR\x05value:\x028\x01\"`\n" +
+	"\x05value\x18\x02 \x01(\v2!.conjugate.data.AggregationResultR\x05value:\x028\x01\"`\n" +
 	"\x10ShardSearchStats\x12\x14\n" +
 	"\x05total\x18\x01 \x01(\x05R\x05total\x12\x1e\n" +
 	"\n" +
@@ -2441,20 +3353,20 @@ const file_pkg_common_proto_data_proto_rawDesc = "" +
 	"\x06failed\x18\x03 \x01(\x05R\x06failed\"\x89\x01\n" +
 	"\n" +
 	"SearchHits\x12/\n" +
-	"\x05total\x18\x01 \x01(\v2\x19.quidditch.data.TotalHitsR\x05total\x12\x1b\n" +
+	"\x05total\x18\x01 \x01(\v2\x19.conjugate.data.TotalHitsR\x05total\x12\x1b\n" +
 	"\tmax_score\x18\x02 \x01(\x01R\bmaxScore\x12-\n" +
-	"\x04hits\x18\x03 \x03(\v2\x19.quidditch.data.SearchHitR\x04hits\"=\n" +
+	"\x04hits\x18\x03 \x03(\v2\x19.conjugate.data.SearchHitR\x04hits\"=\n" +
 	"\tTotalHits\x12\x14\n" +
 	"\x05value\x18\x01 \x01(\x03R\x05value\x12\x1a\n" +
-	"\brelation\x18\x02 \x01(\tR\brelation\"v\n" +
+	"\brelation\x18\x02 \x01(\tR\brelation\"\x8e\x01\n" +
 	"\tSearchHit\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
 	"\x05score\x18\x02 \x01(\x01R\x05score\x12/\n" +
-	"\x06source\x18\x03 \x01(\v2\x17.google.protobuf.StructR\x06source\x12\x12\n" +
-	"\x04sort\x18\x04 \x03(\x01R\x04sort\"\xbb\x04\n" +
+	"\x06source\x18\x03 \x01(\v2\x17.google.protobuf.StructR\x06source\x12*\n" +
+	"\x04sort\x18\x04 \x03(\v2\x16.google.protobuf.ValueR\x04sort\"\xc0\x05\n" +
 	"\x11AggregationResult\x12\x12\n" +
 	"\x04type\x18\x01 \x01(\tR\x04type\x12;\n" +
-	"\abuckets\x18\x02 \x03(\v2!.quidditch.data.AggregationBucketR\abuckets\x12\x14\n" +
+	"\abuckets\x18\x02 \x03(\v2!.conjugate.data.AggregationBucketR\abuckets\x12\x14\n" +
 	"\x05count\x18\x03 \x01(\x03R\x05count\x12\x10\n" +
 	"\x03min\x18\x04 \x01(\x01R\x03min\x12\x10\n" +
 	"\x03max\x18\x05 \x01(\x01R\x03max\x12\x10\n" +
@@ -2466,20 +3378,28 @@ const file_pkg_common_proto_data_proto_rawDesc = "" +
 	" \x01(\x01R\fstdDeviation\x12;\n" +
 	"\x1astd_deviation_bounds_upper\x18\v \x01(\x01R\x17stdDeviationBoundsUpper\x12;\n" +
 	"\x1astd_deviation_bounds_lower\x18\f \x01(\x01R\x17stdDeviationBoundsLower\x12E\n" +
-	"\x06values\x18\r \x03(\v2-.quidditch.data.AggregationResult.ValuesEntryR\x06values\x12\x14\n" +
-	"\x05value\x18\x0e \x01(\x03R\x05value\x1a9\n" +
+	"\x06values\x18\r \x03(\v2-.conjugate.data.AggregationResult.ValuesEntryR\x06values\x12\x14\n" +
+	"\x05value\x18\x0e \x01(\x03R\x05value\x12\x16\n" +
+	"\x06sketch\x18\x0f \x01(\fR\x06sketch\x12<\n" +
+	"\x1bdoc_count_error_upper_bound\x18\x10 \x01(\x03R\x17docCountErrorUpperBound\x12-\n" +
+	"\x13sum_other_doc_count\x18\x11 \x01(\x03R\x10sumOtherDocCount\x1a9\n" +
 	"\vValuesEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\x01R\x05value:\x028\x01\"\xad\x02\n" +
+	"\x05value\x18\x02 \x01(\x01R\x05value:\x028\x01\"\x90\x03\n" +
 	"\x11AggregationBucket\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x1f\n" +
 	"\vnumeric_key\x18\x02 \x01(\x01R\n" +
 	"numericKey\x12\x1b\n" +
 	"\tdoc_count\x18\x03 \x01(\x03R\bdocCount\x12a\n" +
-	"\x10sub_aggregations\x18\x04 \x03(\v26.quidditch.data.AggregationBucket.SubAggregationsEntryR\x0fsubAggregations\x1ae\n" +
+	"\x10sub_aggregations\x18\x04 \x03(\v26.conjugate.data.AggregationBucket.SubAggregationsEntryR\x0fsubAggregations\x12\x17\n" +
+	"\x04from\x18\x05 \x01(\x01H\x00R\x04from\x88\x01\x01\x12\x13\n" +
+	"\x02to\x18\x06 \x01(\x01H\x01R\x02to\x88\x01\x01\x12#\n" +
+	"\rcomposite_key\x18\a \x01(\fR\fcompositeKey\x1ae\n" +
 	"\x14SubAggregationsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x127\n" +
-	"\x05value\x18\x02 \x01(\v2!.quidditch.data.AggregationResultR\x05value:\x028\x01\"\x8b\x01\n" +
+	"\x05value\x18\x02 \x01(\v2!.conjugate.data.AggregationResultR\x05value:\x028\x01B\a\n" +
+	"\x05_fromB\x05\n" +
+	"\x03_to\"\x8b\x01\n" +
 	"\fCountRequest\x12\x1d\n" +
 	"\n" +
 	"index_name\x18\x01 \x01(\tR\tindexName\x12\x19\n" +
@@ -2521,22 +3441,25 @@ const file_pkg_common_proto_data_proto_rawDesc = "" +
 	"\x14memory_usage_percent\x18\x06 \x01(\x01R\x12memoryUsagePercent\x12,\n" +
 	"\x12disk_usage_percent\x18\a \x01(\x01R\x10diskUsagePercent\x12%\n" +
 	"\x0euptime_seconds\x18\b \x01(\x03R\ruptimeSeconds\x122\n" +
-	"\x06shards\x18\t \x03(\v2\x1a.quidditch.data.ShardStatsR\x06shards2\xdc\b\n" +
+	"\x06shards\x18\t \x03(\v2\x1a.conjugate.data.ShardStatsR\x06shards2\x99\v\n" +
 	"\vDataService\x12V\n" +
-	"\vCreateShard\x12\".quidditch.data.CreateShardRequest\x1a#.quidditch.data.CreateShardResponse\x12V\n" +
-	"\vDeleteShard\x12\".quidditch.data.DeleteShardRequest\x1a#.quidditch.data.DeleteShardResponse\x12N\n" +
-	"\fGetShardInfo\x12#.quidditch.data.GetShardInfoRequest\x1a\x19.quidditch.data.ShardInfo\x12Y\n" +
-	"\fRefreshShard\x12#.quidditch.data.RefreshShardRequest\x1a$.quidditch.data.RefreshShardResponse\x12S\n" +
+	"\vCreateShard\x12\".conjugate.data.CreateShardRequest\x1a#.conjugate.data.CreateShardResponse\x12V\n" +
+	"\vDeleteShard\x12\".conjugate.data.DeleteShardRequest\x1a#.conjugate.data.DeleteShardResponse\x12N\n" +
+	"\fGetShardInfo\x12#.conjugate.data.GetShardInfoRequest\x1a\x19.conjugate.data.ShardInfo\x12Y\n" +
+	"\fRefreshShard\x12#.conjugate.data.RefreshShardRequest\x1a$.conjugate.data.RefreshShardResponse\x12S\n" +
 	"\n" +
-	"FlushShard\x12!.quidditch.data.FlushShardRequest\x1a\".quidditch.data.FlushShardResponse\x12\\\n" +
-	"\rIndexDocument\x12$.quidditch.data.IndexDocumentRequest\x1a%.quidditch.data.IndexDocumentResponse\x12V\n" +
-	"\vGetDocument\x12\".quidditch.data.GetDocumentRequest\x1a#.quidditch.data.GetDocumentResponse\x12_\n" +
-	"\x0eDeleteDocument\x12%.quidditch.data.DeleteDocumentRequest\x1a&.quidditch.data.DeleteDocumentResponse\x12P\n" +
-	"\tBulkIndex\x12 .quidditch.data.BulkIndexRequest\x1a!.quidditch.data.BulkIndexResponse\x12G\n" +
-	"\x06Search\x12\x1d.quidditch.data.SearchRequest\x1a\x1e.quidditch.data.SearchResponse\x12D\n" +
-	"\x05Count\x12\x1c.quidditch.data.CountRequest\x1a\x1d.quidditch.data.CountResponse\x12Q\n" +
-	"\rGetShardStats\x12$.quidditch.data.GetShardStatsRequest\x1a\x1a.quidditch.data.ShardStats\x12R\n" +
-	"\fGetNodeStats\x12#.quidditch.data.GetNodeStatsRequest\x1a\x1d.quidditch.data.DataNodeStatsB1Z/github.com/conjugate/conjugate/pkg/common/protob\x06proto3"
+	"FlushShard\x12!.conjugate.data.FlushShardRequest\x1a\".conjugate.data.FlushShardResponse\x12n\n" +
+	"\x13UpdateShardSettings\x12*.conjugate.data.UpdateShardSettingsRequest\x1a+.conjugate.data.UpdateShardSettingsResponse\x12\\\n" +
+	"\rIndexDocument\x12$.conjugate.data.IndexDocumentRequest\x1a%.conjugate.data.IndexDocumentResponse\x12V\n" +
+	"\vGetDocument\x12\".conjugate.data.GetDocumentRequest\x1a#.conjugate.data.GetDocumentResponse\x12_\n" +
+	"\x0eDeleteDocument\x12%.conjugate.data.DeleteDocumentRequest\x1a&.conjugate.data.DeleteDocumentResponse\x12P\n" +
+	"\tBulkIndex\x12 .conjugate.data.BulkIndexRequest\x1a!.conjugate.data.BulkIndexResponse\x12n\n" +
+	"\x13ReplicateOperations\x12*.conjugate.data.ReplicateOperationsRequest\x1a+.conjugate.data.ReplicateOperationsResponse\x12[\n" +
+	"\fRecoverShard\x12#.conjugate.data.RecoverShardRequest\x1a$.conjugate.data.RecoverShardResponse0\x01\x12G\n" +
+	"\x06Search\x12\x1d.conjugate.data.SearchRequest\x1a\x1e.conjugate.data.SearchResponse\x12D\n" +
+	"\x05Count\x12\x1c.conjugate.data.CountRequest\x1a\x1d.conjugate.data.CountResponse\x12Q\n" +
+	"\rGetShardStats\x12$.conjugate.data.GetShardStatsRequest\x1a\x1a.conjugate.data.ShardStats\x12R\n" +
+	"\fGetNodeStats\x12#.conjugate.data.GetNodeStatsRequest\x1a\x1d.conjugate.data.DataNodeStatsB1Z/github.com/conjugate/conjugate/pkg/common/protob\x06proto3"
 
 var (
 	file_pkg_common_proto_data_proto_rawDescOnce sync.Once
@@ -2551,103 +3474,132 @@ func file_pkg_common_proto_data_proto_rawDescGZIP() []byte {
 }
 
 var file_pkg_common_proto_data_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_pkg_common_proto_data_proto_msgTypes = make([]protoimpl.MessageInfo, 38)
+var file_pkg_common_proto_data_proto_msgTypes = make([]protoimpl.MessageInfo, 50)
 var file_pkg_common_proto_data_proto_goTypes = []any{
-	(ShardInfo_ShardState)(0),      // 0: quidditch.data.ShardInfo.ShardState
-	(*CreateShardRequest)(nil),     // 1: quidditch.data.CreateShardRequest
-	(*CreateShardResponse)(nil),    // 2: quidditch.data.CreateShardResponse
-	(*DeleteShardRequest)(nil),     // 3: quidditch.data.DeleteShardRequest
-	(*DeleteShardResponse)(nil),    // 4: quidditch.data.DeleteShardResponse
-	(*GetShardInfoRequest)(nil),    // 5: quidditch.data.GetShardInfoRequest
-	(*ShardInfo)(nil),              // 6: quidditch.data.ShardInfo
-	(*RefreshShardRequest)(nil),    // 7: quidditch.data.RefreshShardRequest
-	(*RefreshShardResponse)(nil),   // 8: quidditch.data.RefreshShardResponse
-	(*FlushShardRequest)(nil),      // 9: quidditch.data.FlushShardRequest
-	(*FlushShardResponse)(nil),     // 10: quidditch.data.FlushShardResponse
-	(*IndexDocumentRequest)(nil),   // 11: quidditch.data.IndexDocumentRequest
-	(*IndexDocumentResponse)(nil),  // 12: quidditch.data.IndexDocumentResponse
-	(*GetDocumentRequest)(nil),     // 13: quidditch.data.GetDocumentRequest
-	(*GetDocumentResponse)(nil),    // 14: quidditch.data.GetDocumentResponse
-	(*DeleteDocumentRequest)(nil),  // 15: quidditch.data.DeleteDocumentRequest
-	(*DeleteDocumentResponse)(nil), // 16: quidditch.data.DeleteDocumentResponse
-	(*BulkIndexRequest)(nil),       // 17: quidditch.data.BulkIndexRequest
-	(*BulkIndexItem)(nil),          // 18: quidditch.data.BulkIndexItem
-	(*BulkIndexResponse)(nil),      // 19: quidditch.data.BulkIndexResponse
-	(*BulkIndexItemResponse)(nil),  // 20: quidditch.data.BulkIndexItemResponse
-	(*SearchRequest)(nil),          // 21: quidditch.data.SearchRequest
-	(*SearchResponse)(nil),         // 22: quidditch.data.SearchResponse
-	(*ShardSearchStats)(nil),       // 23: quidditch.data.ShardSearchStats
-	(*SearchHits)(nil),             // 24: quidditch.data.SearchHits
-	(*TotalHits)(nil),              // 25: quidditch.data.TotalHits
-	(*SearchHit)(nil),              // 26: quidditch.data.SearchHit
-	(*AggregationResult)(nil),      // 27: quidditch.data.AggregationResult
-	(*AggregationBucket)(nil),      // 28: quidditch.data.AggregationBucket
-	(*CountRequest)(nil),           // 29: quidditch.data.CountRequest
-	(*CountResponse)(nil),          // 30: quidditch.data.CountResponse
-	(*GetShardStatsRequest)(nil),   // 31: quidditch.data.GetShardStatsRequest
-	(*ShardStats)(nil),             // 32: quidditch.data.ShardStats
-	(*GetNodeStatsRequest)(nil),    // 33: quidditch.data.GetNodeStatsRequest
-	(*DataNodeStats)(nil),          // 34: quidditch.data.DataNodeStats
-	nil,                            // 35: quidditch.data.CreateShardRequest.SettingsEntry
-	nil,                            // 36: quidditch.data.SearchResponse.AggregationsEntry
-	nil,                            // 37: quidditch.data.AggregationResult.ValuesEntry
-	nil,                            // 38: quidditch.data.AggregationBucket.SubAggregationsEntry
-	(*timestamppb.Timestamp)(nil),  // 39: google.protobuf.Timestamp
-	(*structpb.Struct)(nil),        // 40: google.protobuf.Struct
+	(ShardInfo_ShardState)(0),           // 0: conjugate.data.ShardInfo.ShardState
+	(*CreateShardRequest)(nil),          // 1: conjugate.data.CreateShardRequest
+	(*CreateShardResponse)(nil),         // 2: conjugate.data.CreateShardResponse
+	(*DeleteShardRequest)(nil),          // 3: conjugate.data.DeleteShardRequest
+	(*DeleteShardResponse)(nil),         // 4: conjugate.data.DeleteShardResponse
+	(*GetShardInfoRequest)(nil),         // 5: conjugate.data.GetShardInfoRequest
+	(*ShardInfo)(nil),                   // 6: conjugate.data.ShardInfo
+	(*RefreshShardRequest)(nil),         // 7: conjugate.data.RefreshShardRequest
+	(*RefreshShardResponse)(nil),        // 8: conjugate.data.RefreshShardResponse
+	(*FlushShardRequest)(nil),           // 9: conjugate.data.FlushShardRequest
+	(*FlushShardResponse)(nil),          // 10: conjugate.data.FlushShardResponse
+	(*UpdateShardSettingsRequest)(nil),  // 11: conjugate.data.UpdateShardSettingsRequest
+	(*UpdateShardSettingsResponse)(nil), // 12: conjugate.data.UpdateShardSettingsResponse
+	(*IndexDocumentRequest)(nil),        // 13: conjugate.data.IndexDocumentRequest
+	(*IndexDocumentResponse)(nil),       // 14: conjugate.data.IndexDocumentResponse
+	(*GetDocumentRequest)(nil),          // 15: conjugate.data.GetDocumentRequest
+	(*GetDocumentResponse)(nil),         // 16: conjugate.data.GetDocumentResponse
+	(*DeleteDocumentRequest)(nil),       // 17: conjugate.data.DeleteDocumentRequest
+	(*DeleteDocumentResponse)(nil),      // 18: conjugate.data.DeleteDocumentResponse
+	(*BulkIndexRequest)(nil),            // 19: conjugate.data.BulkIndexRequest
+	(*BulkIndexItem)(nil),               // 20: conjugate.data.BulkIndexItem
+	(*BulkIndexResponse)(nil),           // 21: conjugate.data.BulkIndexResponse
+	(*BulkIndexItemResponse)(nil),       // 22: conjugate.data.BulkIndexItemResponse
+	(*WriteShardsInfo)(nil),             // 23: conjugate.data.WriteShardsInfo
+	(*ReplicateOperationsRequest)(nil),  // 24: conjugate.data.ReplicateOperationsRequest
+	(*ReplicationOperation)(nil),        // 25: conjugate.data.ReplicationOperation
+	(*ReplicateOperationsResponse)(nil), // 26: conjugate.data.ReplicateOperationsResponse
+	(*RecoverShardRequest)(nil),         // 27: conjugate.data.RecoverShardRequest
+	(*RecoverShardResponse)(nil),        // 28: conjugate.data.RecoverShardResponse
+	(*RecoveryPlan)(nil),                // 29: conjugate.data.RecoveryPlan
+	(*RecoveryFile)(nil),                // 30: conjugate.data.RecoveryFile
+	(*RecoveryFileChunk)(nil),           // 31: conjugate.data.RecoveryFileChunk
+	(*SearchRequest)(nil),               // 32: conjugate.data.SearchRequest
+	(*SearchResponse)(nil),              // 33: conjugate.data.SearchResponse
+	(*ShardSearchStats)(nil),            // 34: conjugate.data.ShardSearchStats
+	(*SearchHits)(nil),                  // 35: conjugate.data.SearchHits
+	(*TotalHits)(nil),                   // 36: conjugate.data.TotalHits
+	(*SearchHit)(nil),                   // 37: conjugate.data.SearchHit
+	(*AggregationResult)(nil),           // 38: conjugate.data.AggregationResult
+	(*AggregationBucket)(nil),           // 39: conjugate.data.AggregationBucket
+	(*CountRequest)(nil),                // 40: conjugate.data.CountRequest
+	(*CountResponse)(nil),               // 41: conjugate.data.CountResponse
+	(*GetShardStatsRequest)(nil),        // 42: conjugate.data.GetShardStatsRequest
+	(*ShardStats)(nil),                  // 43: conjugate.data.ShardStats
+	(*GetNodeStatsRequest)(nil),         // 44: conjugate.data.GetNodeStatsRequest
+	(*DataNodeStats)(nil),               // 45: conjugate.data.DataNodeStats
+	nil,                                 // 46: conjugate.data.CreateShardRequest.SettingsEntry
+	nil,                                 // 47: conjugate.data.UpdateShardSettingsRequest.SettingsEntry
+	nil,                                 // 48: conjugate.data.SearchResponse.AggregationsEntry
+	nil,                                 // 49: conjugate.data.AggregationResult.ValuesEntry
+	nil,                                 // 50: conjugate.data.AggregationBucket.SubAggregationsEntry
+	(*timestamppb.Timestamp)(nil),       // 51: google.protobuf.Timestamp
+	(*structpb.Struct)(nil),             // 52: google.protobuf.Struct
+	(*structpb.Value)(nil),              // 53: google.protobuf.Value
 }
 var file_pkg_common_proto_data_proto_depIdxs = []int32{
-	35, // 0: quidditch.data.CreateShardRequest.settings:type_name -> quidditch.data.CreateShardRequest.SettingsEntry
-	0,  // 1: quidditch.data.ShardInfo.state:type_name -> quidditch.data.ShardInfo.ShardState
-	39, // 2: quidditch.data.ShardInfo.created_at:type_name -> google.protobuf.Timestamp
-	39, // 3: quidditch.data.ShardInfo.last_updated:type_name -> google.protobuf.Timestamp
-	40, // 4: quidditch.data.IndexDocumentRequest.document:type_name -> google.protobuf.Struct
-	40, // 5: quidditch.data.GetDocumentResponse.document:type_name -> google.protobuf.Struct
-	18, // 6: quidditch.data.BulkIndexRequest.items:type_name -> quidditch.data.BulkIndexItem
-	40, // 7: quidditch.data.BulkIndexItem.document:type_name -> google.protobuf.Struct
-	20, // 8: quidditch.data.BulkIndexResponse.items:type_name -> quidditch.data.BulkIndexItemResponse
-	23, // 9: quidditch.data.SearchResponse.shards:type_name -> quidditch.data.ShardSearchStats
-	24, // 10: quidditch.data.SearchResponse.hits:type_name -> quidditch.data.SearchHits
-	36, // 11: quidditch.data.SearchResponse.aggregations:type_name -> quidditch.data.SearchResponse.AggregationsEntry
-	25, // 12: quidditch.data.SearchHits.total:type_name -> quidditch.data.TotalHits
-	26, // 13: quidditch.data.SearchHits.hits:type_name -> quidditch.data.SearchHit
-	40, // 14: quidditch.data.SearchHit.source:type_name -> google.protobuf.Struct
-	28, // 15: quidditch.data.AggregationResult.buckets:type_name -> quidditch.data.AggregationBucket
-	37, // 16: quidditch.data.AggregationResult.values:type_name -> quidditch.data.AggregationResult.ValuesEntry
-	38, // 17: quidditch.data.AggregationBucket.sub_aggregations:type_name -> quidditch.data.AggregationBucket.SubAggregationsEntry
-	32, // 18: quidditch.data.DataNodeStats.shards:type_name -> quidditch.data.ShardStats
-	27, // 19: quidditch.data.SearchResponse.AggregationsEntry.value:type_name -> quidditch.data.AggregationResult
-	27, // 20: quidditch.data.AggregationBucket.SubAggregationsEntry.value:type_name -> quidditch.data.AggregationResult
-	1,  // 21: quidditch.data.DataService.CreateShard:input_type -> quidditch.data.CreateShardRequest
-	3,  // 22: quidditch.data.DataService.DeleteShard:input_type -> quidditch.data.DeleteShardRequest
-	5,  // 23: quidditch.data.DataService.GetShardInfo:input_type -> quidditch.data.GetShardInfoRequest
-	7,  // 24: quidditch.data.DataService.RefreshShard:input_type -> quidditch.data.RefreshShardRequest
-	9,  // 25: quidditch.data.DataService.FlushShard:input_type -> quidditch.data.FlushShardRequest
-	11, // 26: quidditch.data.DataService.IndexDocument:input_type -> quidditch.data.IndexDocumentRequest
-	13, // 27: quidditch.data.DataService.GetDocument:input_type -> quidditch.data.GetDocumentRequest
-	15, // 28: quidditch.data.DataService.DeleteDocument:input_type -> quidditch.data.DeleteDocumentRequest
-	17, // 29: quidditch.data.DataService.BulkIndex:input_type -> quidditch.data.BulkIndexRequest
-	21, // 30: quidditch.data.DataService.Search:input_type -> quidditch.data.SearchRequest
-	29, // 31: quidditch.data.DataService.Count:input_type -> quidditch.data.CountRequest
-	31, // 32: quidditch.data.DataService.GetShardStats:input_type -> quidditch.data.GetShardStatsRequest
-	33, // 33: quidditch.data.DataService.GetNodeStats:input_type -> quidditch.data.GetNodeStatsRequest
-	2,  // 34: quidditch.data.DataService.CreateShard:output_type -> quidditch.data.CreateShardResponse
-	4,  // 35: quidditch.data.DataService.DeleteShard:output_type -> quidditch.data.DeleteShardResponse
-	6,  // 36: quidditch.data.DataService.GetShardInfo:output_type -> quidditch.data.ShardInfo
-	8,  // 37: quidditch.data.DataService.RefreshShard:output_type -> quidditch.data.RefreshShardResponse
-	10, // 38: quidditch.data.DataService.FlushShard:output_type -> quidditch.data.FlushShardResponse
-	12, // 39: quidditch.data.DataService.IndexDocument:output_type -> quidditch.data.IndexDocumentResponse
-	14, // 40: quidditch.data.DataService.GetDocument:output_type -> quidditch.data.GetDocumentResponse
-	16, // 41: quidditch.data.DataService.DeleteDocument:output_type -> quidditch.data.DeleteDocumentResponse
-	19, // 42: quidditch.data.DataService.BulkIndex:output_type -> quidditch.data.BulkIndexResponse
-	22, // 43: quidditch.data.DataService.Search:output_type -> quidditch.data.SearchResponse
-	30, // 44: quidditch.data.DataService.Count:output_type -> quidditch.data.CountResponse
-	32, // 45: quidditch.data.DataService.GetShardStats:output_type -> quidditch.data.ShardStats
-	34, // 46: quidditch.data.DataService.GetNodeStats:output_type -> quidditch.data.DataNodeStats
-	34, // [34:47] is the sub-list for method output_type
-	21, // [21:34] is the sub-list for method input_type
-	21, // [21:21] is the sub-list for extension type_name
-	21, // [21:21] is the sub-list for extension extendee
-	0,  // [0:21] is the sub-list for field type_name
+	46, // 0: conjugate.data.CreateShardRequest.settings:type_name -> conjugate.data.CreateShardRequest.SettingsEntry
+	0,  // 1: conjugate.data.ShardInfo.state:type_name -> conjugate.data.ShardInfo.ShardState
+	51, // 2: conjugate.data.ShardInfo.created_at:type_name -> google.protobuf.Timestamp
+	51, // 3: conjugate.data.ShardInfo.last_updated:type_name -> google.protobuf.Timestamp
+	47, // 4: conjugate.data.UpdateShardSettingsRequest.settings:type_name -> conjugate.data.UpdateShardSettingsRequest.SettingsEntry
+	52, // 5: conjugate.data.IndexDocumentRequest.document:type_name -> google.protobuf.Struct
+	23, // 6: conjugate.data.IndexDocumentResponse.shards:type_name -> conjugate.data.WriteShardsInfo
+	52, // 7: conjugate.data.GetDocumentResponse.document:type_name -> google.protobuf.Struct
+	23, // 8: conjugate.data.DeleteDocumentResponse.shards:type_name -> conjugate.data.WriteShardsInfo
+	20, // 9: conjugate.data.BulkIndexRequest.items:type_name -> conjugate.data.BulkIndexItem
+	52, // 10: conjugate.data.BulkIndexItem.document:type_name -> google.protobuf.Struct
+	22, // 11: conjugate.data.BulkIndexResponse.items:type_name -> conjugate.data.BulkIndexItemResponse
+	25, // 12: conjugate.data.ReplicateOperationsRequest.operations:type_name -> conjugate.data.ReplicationOperation
+	52, // 13: conjugate.data.ReplicationOperation.document:type_name -> google.protobuf.Struct
+	29, // 14: conjugate.data.RecoverShardResponse.plan:type_name -> conjugate.data.RecoveryPlan
+	31, // 15: conjugate.data.RecoverShardResponse.file_chunk:type_name -> conjugate.data.RecoveryFileChunk
+	25, // 16: conjugate.data.RecoverShardResponse.operations:type_name -> conjugate.data.ReplicationOperation
+	30, // 17: conjugate.data.RecoveryPlan.files:type_name -> conjugate.data.RecoveryFile
+	34, // 18: conjugate.data.SearchResponse.shards:type_name -> conjugate.data.ShardSearchStats
+	35, // 19: conjugate.data.SearchResponse.hits:type_name -> conjugate.data.SearchHits
+	48, // 20: conjugate.data.SearchResponse.aggregations:type_name -> conjugate.data.SearchResponse.AggregationsEntry
+	36, // 21: conjugate.data.SearchHits.total:type_name -> conjugate.data.TotalHits
+	37, // 22: conjugate.data.SearchHits.hits:type_name -> conjugate.data.SearchHit
+	52, // 23: conjugate.data.SearchHit.source:type_name -> google.protobuf.Struct
+	53, // 24: conjugate.data.SearchHit.sort:type_name -> google.protobuf.Value
+	39, // 25: conjugate.data.AggregationResult.buckets:type_name -> conjugate.data.AggregationBucket
+	49, // 26: conjugate.data.AggregationResult.values:type_name -> conjugate.data.AggregationResult.ValuesEntry
+	50, // 27: conjugate.data.AggregationBucket.sub_aggregations:type_name -> conjugate.data.AggregationBucket.SubAggregationsEntry
+	43, // 28: conjugate.data.DataNodeStats.shards:type_name -> conjugate.data.ShardStats
+	38, // 29: conjugate.data.SearchResponse.AggregationsEntry.value:type_name -> conjugate.data.AggregationResult
+	38, // 30: conjugate.data.AggregationBucket.SubAggregationsEntry.value:type_name -> conjugate.data.AggregationResult
+	1,  // 31: conjugate.data.DataService.CreateShard:input_type -> conjugate.data.CreateShardRequest
+	3,  // 32: conjugate.data.DataService.DeleteShard:input_type -> conjugate.data.DeleteShardRequest
+	5,  // 33: conjugate.data.DataService.GetShardInfo:input_type -> conjugate.data.GetShardInfoRequest
+	7,  // 34: conjugate.data.DataService.RefreshShard:input_type -> conjugate.data.RefreshShardRequest
+	9,  // 35: conjugate.data.DataService.FlushShard:input_type -> conjugate.data.FlushShardRequest
+	11, // 36: conjugate.data.DataService.UpdateShardSettings:input_type -> conjugate.data.UpdateShardSettingsRequest
+	13, // 37: conjugate.data.DataService.IndexDocument:input_type -> conjugate.data.IndexDocumentRequest
+	15, // 38: conjugate.data.DataService.GetDocument:input_type -> conjugate.data.GetDocumentRequest
+	17, // 39: conjugate.data.DataService.DeleteDocument:input_type -> conjugate.data.DeleteDocumentRequest
+	19, // 40: conjugate.data.DataService.BulkIndex:input_type -> conjugate.data.BulkIndexRequest
+	24, // 41: conjugate.data.DataService.ReplicateOperations:input_type -> conjugate.data.ReplicateOperationsRequest
+	27, // 42: conjugate.data.DataService.RecoverShard:input_type -> conjugate.data.RecoverShardRequest
+	32, // 43: conjugate.data.DataService.Search:input_type -> conjugate.data.SearchRequest
+	40, // 44: conjugate.data.DataService.Count:input_type -> conjugate.data.CountRequest
+	42, // 45: conjugate.data.DataService.GetShardStats:input_type -> conjugate.data.GetShardStatsRequest
+	44, // 46: conjugate.data.DataService.GetNodeStats:input_type -> conjugate.data.GetNodeStatsRequest
+	2,  // 47: conjugate.data.DataService.CreateShard:output_type -> conjugate.data.CreateShardResponse
+	4,  // 48: conjugate.data.DataService.DeleteShard:output_type -> conjugate.data.DeleteShardResponse
+	6,  // 49: conjugate.data.DataService.GetShardInfo:output_type -> conjugate.data.ShardInfo
+	8,  // 50: conjugate.data.DataService.RefreshShard:output_type -> conjugate.data.RefreshShardResponse
+	10, // 51: conjugate.data.DataService.FlushShard:output_type -> conjugate.data.FlushShardResponse
+	12, // 52: conjugate.data.DataService.UpdateShardSettings:output_type -> conjugate.data.UpdateShardSettingsResponse
+	14, // 53: conjugate.data.DataService.IndexDocument:output_type -> conjugate.data.IndexDocumentResponse
+	16, // 54: conjugate.data.DataService.GetDocument:output_type -> conjugate.data.GetDocumentResponse
+	18, // 55: conjugate.data.DataService.DeleteDocument:output_type -> conjugate.data.DeleteDocumentResponse
+	21, // 56: conjugate.data.DataService.BulkIndex:output_type -> conjugate.data.BulkIndexResponse
+	26, // 57: conjugate.data.DataService.ReplicateOperations:output_type -> conjugate.data.ReplicateOperationsResponse
+	28, // 58: conjugate.data.DataService.RecoverShard:output_type -> conjugate.data.RecoverShardResponse
+	33, // 59: conjugate.data.DataService.Search:output_type -> conjugate.data.SearchResponse
+	41, // 60: conjugate.data.DataService.Count:output_type -> conjugate.data.CountResponse
+	43, // 61: conjugate.data.DataService.GetShardStats:output_type -> conjugate.data.ShardStats
+	45, // 62: conjugate.data.DataService.GetNodeStats:output_type -> conjugate.data.DataNodeStats
+	47, // [47:63] is the sub-list for method output_type
+	31, // [31:47] is the sub-list for method input_type
+	31, // [31:31] is the sub-list for extension type_name
+	31, // [31:31] is the sub-list for extension extendee
+	0,  // [0:31] is the sub-list for field type_name
 }
 
 func init() { file_pkg_common_proto_data_proto_init() }
@@ -2655,13 +3607,14 @@ func file_pkg_common_proto_data_proto_init() {
 	if File_pkg_common_proto_data_proto != nil {
 		return
 	}
+	file_pkg_common_proto_data_proto_msgTypes[38].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pkg_common_proto_data_proto_rawDesc), len(file_pkg_common_proto_data_proto_rawDesc)),
 			NumEnums:      1,
-			NumMessages:   38,
+			NumMessages:   50,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
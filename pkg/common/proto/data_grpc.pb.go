@@ -19,19 +19,22 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	DataService_CreateShard_FullMethodName    = "/conjugate.data.DataService/CreateShard"
-	DataService_DeleteShard_FullMethodName    = "/conjugate.data.DataService/DeleteShard"
-	DataService_GetShardInfo_FullMethodName   = "/conjugate.data.DataService/GetShardInfo"
-	DataService_RefreshShard_FullMethodName   = "/conjugate.data.DataService/RefreshShard"
-	DataService_FlushShard_FullMethodName     = "/conjugate.data.DataService/FlushShard"
-	DataService_IndexDocument_FullMethodName  = "/conjugate.data.DataService/IndexDocument"
-	DataService_GetDocument_FullMethodName    = "/conjugate.data.DataService/GetDocument"
-	DataService_DeleteDocument_FullMethodName = "/conjugate.data.DataService/DeleteDocument"
-	DataService_BulkIndex_FullMethodName      = "/conjugate.data.DataService/BulkIndex"
-	DataService_Search_FullMethodName         = "/conjugate.data.DataService/Search"
-	DataService_Count_FullMethodName          = "/conjugate.data.DataService/Count"
-	DataService_GetShardStats_FullMethodName  = "/conjugate.data.DataService/GetShardStats"
-	DataService_GetNodeStats_FullMethodName   = "/conjugate.data.DataService/GetNodeStats"
+	DataService_CreateShard_FullMethodName         = "/conjugate.data.DataService/CreateShard"
+	DataService_DeleteShard_FullMethodName         = "/conjugate.data.DataService/DeleteShard"
+	DataService_GetShardInfo_FullMethodName        = "/conjugate.data.DataService/GetShardInfo"
+	DataService_RefreshShard_FullMethodName        = "/conjugate.data.DataService/RefreshShard"
+	DataService_FlushShard_FullMethodName          = "/conjugate.data.DataService/FlushShard"
+	DataService_UpdateShardSettings_FullMethodName = "/conjugate.data.DataService/UpdateShardSettings"
+	DataService_IndexDocument_FullMethodName       = "/conjugate.data.DataService/IndexDocument"
+	DataService_GetDocument_FullMethodName         = "/conjugate.data.DataService/GetDocument"
+	DataService_DeleteDocument_FullMethodName      = "/conjugate.data.DataService/DeleteDocument"
+	DataService_BulkIndex_FullMethodName           = "/conjugate.data.DataService/BulkIndex"
+	DataService_ReplicateOperations_FullMethodName = "/conjugate.data.DataService/ReplicateOperations"
+	DataService_RecoverShard_FullMethodName        = "/conjugate.data.DataService/RecoverShard"
+	DataService_Search_FullMethodName              = "/conjugate.data.DataService/Search"
+	DataService_Count_FullMethodName               = "/conjugate.data.DataService/Count"
+	DataService_GetShardStats_FullMethodName       = "/conjugate.data.DataService/GetShardStats"
+	DataService_GetNodeStats_FullMethodName        = "/conjugate.data.DataService/GetNodeStats"
 )
 
 // DataServiceClient is the client API for DataService service.
@@ -46,11 +49,16 @@ type DataServiceClient interface {
 	GetShardInfo(ctx context.Context, in *GetShardInfoRequest, opts ...grpc.CallOption) (*ShardInfo, error)
 	RefreshShard(ctx context.Context, in *RefreshShardRequest, opts ...grpc.CallOption) (*RefreshShardResponse, error)
 	FlushShard(ctx context.Context, in *FlushShardRequest, opts ...grpc.CallOption) (*FlushShardResponse, error)
+	UpdateShardSettings(ctx context.Context, in *UpdateShardSettingsRequest, opts ...grpc.CallOption) (*UpdateShardSettingsResponse, error)
 	// Document operations
 	IndexDocument(ctx context.Context, in *IndexDocumentRequest, opts ...grpc.CallOption) (*IndexDocumentResponse, error)
 	GetDocument(ctx context.Context, in *GetDocumentRequest, opts ...grpc.CallOption) (*GetDocumentResponse, error)
 	DeleteDocument(ctx context.Context, in *DeleteDocumentRequest, opts ...grpc.CallOption) (*DeleteDocumentResponse, error)
 	BulkIndex(ctx context.Context, in *BulkIndexRequest, opts ...grpc.CallOption) (*BulkIndexResponse, error)
+	// Replication from a primary to its replicas
+	ReplicateOperations(ctx context.Context, in *ReplicateOperationsRequest, opts ...grpc.CallOption) (*ReplicateOperationsResponse, error)
+	// Peer recovery of a replica from its primary
+	RecoverShard(ctx context.Context, in *RecoverShardRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RecoverShardResponse], error)
 	// Search operations
 	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
 	Count(ctx context.Context, in *CountRequest, opts ...grpc.CallOption) (*CountResponse, error)
@@ -117,6 +125,16 @@ func (c *dataServiceClient) FlushShard(ctx context.Context, in *FlushShardReques
 	return out, nil
 }
 
+func (c *dataServiceClient) UpdateShardSettings(ctx context.Context, in *UpdateShardSettingsRequest, opts ...grpc.CallOption) (*UpdateShardSettingsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateShardSettingsResponse)
+	err := c.cc.Invoke(ctx, DataService_UpdateShardSettings_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *dataServiceClient) IndexDocument(ctx context.Context, in *IndexDocumentRequest, opts ...grpc.CallOption) (*IndexDocumentResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(IndexDocumentResponse)
@@ -157,6 +175,35 @@ func (c *dataServiceClient) BulkIndex(ctx context.Context, in *BulkIndexRequest,
 	return out, nil
 }
 
+func (c *dataServiceClient) ReplicateOperations(ctx context.Context, in *ReplicateOperationsRequest, opts ...grpc.CallOption) (*ReplicateOperationsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReplicateOperationsResponse)
+	err := c.cc.Invoke(ctx, DataService_ReplicateOperations_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dataServiceClient) RecoverShard(ctx context.Context, in *RecoverShardRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RecoverShardResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &DataService_ServiceDesc.Streams[0], DataService_RecoverShard_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[RecoverShardRequest, RecoverShardResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DataService_RecoverShardClient = grpc.ServerStreamingClient[RecoverShardResponse]
+
 func (c *dataServiceClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(SearchResponse)
@@ -209,11 +256,16 @@ type DataServiceServer interface {
 	GetShardInfo(context.Context, *GetShardInfoRequest) (*ShardInfo, error)
 	RefreshShard(context.Context, *RefreshShardRequest) (*RefreshShardResponse, error)
 	FlushShard(context.Context, *FlushShardRequest) (*FlushShardResponse, error)
+	UpdateShardSettings(context.Context, *UpdateShardSettingsRequest) (*UpdateShardSettingsResponse, error)
 	// Document operations
 	IndexDocument(context.Context, *IndexDocumentRequest) (*IndexDocumentResponse, error)
 	GetDocument(context.Context, *GetDocumentRequest) (*GetDocumentResponse, error)
 	DeleteDocument(context.Context, *DeleteDocumentRequest) (*DeleteDocumentResponse, error)
 	BulkIndex(context.Context, *BulkIndexRequest) (*BulkIndexResponse, error)
+	// Replication from a primary to its replicas
+	ReplicateOperations(context.Context, *ReplicateOperationsRequest) (*ReplicateOperationsResponse, error)
+	// Peer recovery of a replica from its primary
+	RecoverShard(*RecoverShardRequest, grpc.ServerStreamingServer[RecoverShardResponse]) error
 	// Search operations
 	Search(context.Context, *SearchRequest) (*SearchResponse, error)
 	Count(context.Context, *CountRequest) (*CountResponse, error)
@@ -245,6 +297,9 @@ func (UnimplementedDataServiceServer) RefreshShard(context.Context, *RefreshShar
 func (UnimplementedDataServiceServer) FlushShard(context.Context, *FlushShardRequest) (*FlushShardResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method FlushShard not implemented")
 }
+func (UnimplementedDataServiceServer) UpdateShardSettings(context.Context, *UpdateShardSettingsRequest) (*UpdateShardSettingsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method UpdateShardSettings not implemented")
+}
 func (UnimplementedDataServiceServer) IndexDocument(context.Context, *IndexDocumentRequest) (*IndexDocumentResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method IndexDocument not implemented")
 }
@@ -257,6 +312,12 @@ func (UnimplementedDataServiceServer) DeleteDocument(context.Context, *DeleteDoc
 func (UnimplementedDataServiceServer) BulkIndex(context.Context, *BulkIndexRequest) (*BulkIndexResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method BulkIndex not implemented")
 }
+func (UnimplementedDataServiceServer) ReplicateOperations(context.Context, *ReplicateOperationsRequest) (*ReplicateOperationsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ReplicateOperations not implemented")
+}
+func (UnimplementedDataServiceServer) RecoverShard(*RecoverShardRequest, grpc.ServerStreamingServer[RecoverShardResponse]) error {
+	return status.Error(codes.Unimplemented, "method RecoverShard not implemented")
+}
 func (UnimplementedDataServiceServer) Search(context.Context, *SearchRequest) (*SearchResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method Search not implemented")
 }
@@ -380,6 +441,24 @@ func _DataService_FlushShard_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _DataService_UpdateShardSettings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateShardSettingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DataServiceServer).UpdateShardSettings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DataService_UpdateShardSettings_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DataServiceServer).UpdateShardSettings(ctx, req.(*UpdateShardSettingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _DataService_IndexDocument_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(IndexDocumentRequest)
 	if err := dec(in); err != nil {
@@ -452,6 +531,35 @@ func _DataService_BulkIndex_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _DataService_ReplicateOperations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReplicateOperationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DataServiceServer).ReplicateOperations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DataService_ReplicateOperations_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DataServiceServer).ReplicateOperations(ctx, req.(*ReplicateOperationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DataService_RecoverShard_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RecoverShardRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DataServiceServer).RecoverShard(m, &grpc.GenericServerStream[RecoverShardRequest, RecoverShardResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DataService_RecoverShardServer = grpc.ServerStreamingServer[RecoverShardResponse]
+
 func _DataService_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(SearchRequest)
 	if err := dec(in); err != nil {
@@ -528,7 +636,7 @@ func _DataService_GetNodeStats_Handler(srv interface{}, ctx context.Context, dec
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
 var DataService_ServiceDesc = grpc.ServiceDesc{
-	ServiceName: "quidditch.data.DataService",
+	ServiceName: "conjugate.data.DataService",
 	HandlerType: (*DataServiceServer)(nil),
 	Methods: []grpc.MethodDesc{
 		{
@@ -551,6 +659,10 @@ var DataService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "FlushShard",
 			Handler:    _DataService_FlushShard_Handler,
 		},
+		{
+			MethodName: "UpdateShardSettings",
+			Handler:    _DataService_UpdateShardSettings_Handler,
+		},
 		{
 			MethodName: "IndexDocument",
 			Handler:    _DataService_IndexDocument_Handler,
@@ -567,6 +679,10 @@ var DataService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "BulkIndex",
 			Handler:    _DataService_BulkIndex_Handler,
 		},
+		{
+			MethodName: "ReplicateOperations",
+			Handler:    _DataService_ReplicateOperations_Handler,
+		},
 		{
 			MethodName: "Search",
 			Handler:    _DataService_Search_Handler,
@@ -584,6 +700,12 @@ var DataService_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _DataService_GetNodeStats_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "RecoverShard",
+			Handler:       _DataService_RecoverShard_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "pkg/common/proto/data.proto",
 }
@@ -187,34 +187,46 @@ func (NodeStatus) EnumDescriptor() ([]byte, []int) {
 type ClusterStateEvent_EventType int32
 
 const (
-	ClusterStateEvent_EVENT_TYPE_UNKNOWN         ClusterStateEvent_EventType = 0
-	ClusterStateEvent_EVENT_TYPE_INDEX_CREATED   ClusterStateEvent_EventType = 1
-	ClusterStateEvent_EVENT_TYPE_INDEX_DELETED   ClusterStateEvent_EventType = 2
-	ClusterStateEvent_EVENT_TYPE_SHARD_ALLOCATED ClusterStateEvent_EventType = 3
-	ClusterStateEvent_EVENT_TYPE_SHARD_RELOCATED ClusterStateEvent_EventType = 4
-	ClusterStateEvent_EVENT_TYPE_NODE_JOINED     ClusterStateEvent_EventType = 5
-	ClusterStateEvent_EVENT_TYPE_NODE_LEFT       ClusterStateEvent_EventType = 6
+	ClusterStateEvent_EVENT_TYPE_UNKNOWN           ClusterStateEvent_EventType = 0
+	ClusterStateEvent_EVENT_TYPE_INDEX_CREATED     ClusterStateEvent_EventType = 1
+	ClusterStateEvent_EVENT_TYPE_INDEX_DELETED     ClusterStateEvent_EventType = 2
+	ClusterStateEvent_EVENT_TYPE_SHARD_ALLOCATED   ClusterStateEvent_EventType = 3
+	ClusterStateEvent_EVENT_TYPE_SHARD_RELOCATED   ClusterStateEvent_EventType = 4
+	ClusterStateEvent_EVENT_TYPE_NODE_JOINED       ClusterStateEvent_EventType = 5
+	ClusterStateEvent_EVENT_TYPE_NODE_LEFT         ClusterStateEvent_EventType = 6
+	ClusterStateEvent_EVENT_TYPE_INDEX_UPDATED     ClusterStateEvent_EventType = 7
+	ClusterStateEvent_EVENT_TYPE_NODE_UPDATED      ClusterStateEvent_EventType = 8
+	ClusterStateEvent_EVENT_TYPE_SHARD_UPDATED     ClusterStateEvent_EventType = 9
+	ClusterStateEvent_EVENT_TYPE_SHARD_DEALLOCATED ClusterStateEvent_EventType = 10
 )
 
 // Enum value maps for ClusterStateEvent_EventType.
 var (
 	ClusterStateEvent_EventType_name = map[int32]string{
-		0: "EVENT_TYPE_UNKNOWN",
-		1: "EVENT_TYPE_INDEX_CREATED",
-		2: "EVENT_TYPE_INDEX_DELETED",
-		3: "EVENT_TYPE_SHARD_ALLOCATED",
-		4: "EVENT_TYPE_SHARD_RELOCATED",
-		5: "EVENT_TYPE_NODE_JOINED",
-		6: "EVENT_TYPE_NODE_LEFT",
+		0:  "EVENT_TYPE_UNKNOWN",
+		1:  "EVENT_TYPE_INDEX_CREATED",
+		2:  "EVENT_TYPE_INDEX_DELETED",
+		3:  "EVENT_TYPE_SHARD_ALLOCATED",
+		4:  "EVENT_TYPE_SHARD_RELOCATED",
+		5:  "EVENT_TYPE_NODE_JOINED",
+		6:  "EVENT_TYPE_NODE_LEFT",
+		7:  "EVENT_TYPE_INDEX_UPDATED",
+		8:  "EVENT_TYPE_NODE_UPDATED",
+		9:  "EVENT_TYPE_SHARD_UPDATED",
+		10: "EVENT_TYPE_SHARD_DEALLOCATED",
 	}
 	ClusterStateEvent_EventType_value = map[string]int32{
-		"EVENT_TYPE_UNKNOWN":         0,
-		"EVENT_TYPE_INDEX_CREATED":   1,
-		"EVENT_TYPE_INDEX_DELETED":   2,
-		"EVENT_TYPE_SHARD_ALLOCATED": 3,
-		"EVENT_TYPE_SHARD_RELOCATED": 4,
-		"EVENT_TYPE_NODE_JOINED":     5,
-		"EVENT_TYPE_NODE_LEFT":       6,
+		"EVENT_TYPE_UNKNOWN":           0,
+		"EVENT_TYPE_INDEX_CREATED":     1,
+		"EVENT_TYPE_INDEX_DELETED":     2,
+		"EVENT_TYPE_SHARD_ALLOCATED":   3,
+		"EVENT_TYPE_SHARD_RELOCATED":   4,
+		"EVENT_TYPE_NODE_JOINED":       5,
+		"EVENT_TYPE_NODE_LEFT":         6,
+		"EVENT_TYPE_INDEX_UPDATED":     7,
+		"EVENT_TYPE_NODE_UPDATED":      8,
+		"EVENT_TYPE_SHARD_UPDATED":     9,
+		"EVENT_TYPE_SHARD_DEALLOCATED": 10,
 	}
 )
 
@@ -352,7 +364,7 @@ func (x ShardAllocation_ShardState) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use ShardAllocation_ShardState.Descriptor instead.
 func (ShardAllocation_ShardState) EnumDescriptor() ([]byte, []int) {
-	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{25, 0}
+	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{29, 0}
 }
 
 // Cluster State
@@ -421,7 +433,7 @@ type ClusterStateResponse struct {
 	Version       int64                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
 	ClusterName   string                 `protobuf:"bytes,2,opt,name=cluster_name,json=clusterName,proto3" json:"cluster_name,omitempty"`
 	ClusterUuid   string                 `protobuf:"bytes,3,opt,name=cluster_uuid,json=clusterUuid,proto3" json:"cluster_uuid,omitempty"`
-	Status        ClusterStatus          `protobuf:"varint,4,opt,name=status,proto3,enum=quidditch.master.ClusterStatus" json:"status,omitempty"`
+	Status        ClusterStatus          `protobuf:"varint,4,opt,name=status,proto3,enum=conjugate.master.ClusterStatus" json:"status,omitempty"`
 	Indices       []*IndexMetadata       `protobuf:"bytes,5,rep,name=indices,proto3" json:"indices,omitempty"`
 	RoutingTable  *RoutingTable          `protobuf:"bytes,6,opt,name=routing_table,json=routingTable,proto3" json:"routing_table,omitempty"`
 	Nodes         []*NodeInfo            `protobuf:"bytes,7,rep,name=nodes,proto3" json:"nodes,omitempty"`
@@ -563,8 +575,8 @@ func (x *WatchClusterStateRequest) GetFromVersion() int64 {
 type ClusterStateEvent struct {
 	state         protoimpl.MessageState      `protogen:"open.v1"`
 	Version       int64                       `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
-	Type          ClusterStateEvent_EventType `protobuf:"varint,2,opt,name=type,proto3,enum=quidditch.master.ClusterStateEvent_EventType" json:"type,omitempty"`
-	Payload       []byte                      `protobuf:"bytes,3,opt,name=payload,proto3" json:"payload,omitempty"`
+	Type          ClusterStateEvent_EventType `protobuf:"varint,2,opt,name=type,proto3,enum=conjugate.master.ClusterStateEvent_EventType" json:"type,omitempty"`
+	Payload       []byte                      `protobuf:"bytes,3,opt,name=payload,proto3" json:"payload,omitempty"` // IndexMetadata, NodeInfo or IndexRoutingTable of one shard, by type
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -840,7 +852,7 @@ func (x *DeleteIndexResponse) GetAcknowledged() bool {
 type UpdateIndexSettingsRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	IndexName     string                 `protobuf:"bytes,1,opt,name=index_name,json=indexName,proto3" json:"index_name,omitempty"`
-	Settings      *IndexSettings         `protobuf:"bytes,2,opt,name=settings,proto3" json:"settings,omitempty"`
+	Settings      *IndexSettings         `protobuf:"bytes,2,opt,name=settings,proto3" json:"settings,omitempty"` // All dynamic settings; number_of_shards must be 0 or unchanged
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -1029,7 +1041,7 @@ type IndexMetadata struct {
 	Settings      *IndexSettings           `protobuf:"bytes,4,opt,name=settings,proto3" json:"settings,omitempty"`
 	Mappings      map[string]*FieldMapping `protobuf:"bytes,5,rep,name=mappings,proto3" json:"mappings,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 	Aliases       map[string]string        `protobuf:"bytes,6,rep,name=aliases,proto3" json:"aliases,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
-	State         IndexMetadata_IndexState `protobuf:"varint,7,opt,name=state,proto3,enum=quidditch.master.IndexMetadata_IndexState" json:"state,omitempty"`
+	State         IndexMetadata_IndexState `protobuf:"varint,7,opt,name=state,proto3,enum=conjugate.master.IndexMetadata_IndexState" json:"state,omitempty"`
 	CreatedAt     *timestamppb.Timestamp   `protobuf:"bytes,8,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
@@ -1128,6 +1140,8 @@ type IndexSettings struct {
 	RefreshInterval  string                 `protobuf:"bytes,3,opt,name=refresh_interval,json=refreshInterval,proto3" json:"refresh_interval,omitempty"`
 	Compression      *CompressionSettings   `protobuf:"bytes,4,opt,name=compression,proto3" json:"compression,omitempty"`
 	Tiering          *TieringSettings       `protobuf:"bytes,5,opt,name=tiering,proto3" json:"tiering,omitempty"`
+	CommitInterval   string                 `protobuf:"bytes,6,opt,name=commit_interval,json=commitInterval,proto3" json:"commit_interval,omitempty"`
+	CommitBatchSize  int32                  `protobuf:"varint,7,opt,name=commit_batch_size,json=commitBatchSize,proto3" json:"commit_batch_size,omitempty"`
 	unknownFields    protoimpl.UnknownFields
 	sizeCache        protoimpl.SizeCache
 }
@@ -1197,6 +1211,20 @@ func (x *IndexSettings) GetTiering() *TieringSettings {
 	return nil
 }
 
+func (x *IndexSettings) GetCommitInterval() string {
+	if x != nil {
+		return x.CommitInterval
+	}
+	return ""
+}
+
+func (x *IndexSettings) GetCommitBatchSize() int32 {
+	if x != nil {
+		return x.CommitBatchSize
+	}
+	return 0
+}
+
 type CompressionSettings struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Codec         string                 `protobuf:"bytes,1,opt,name=codec,proto3" json:"codec,omitempty"` // lz4, zstd, deflate
@@ -1308,6 +1336,8 @@ type FieldMapping struct {
 	Store         bool                     `protobuf:"varint,3,opt,name=store,proto3" json:"store,omitempty"`
 	Analyzer      string                   `protobuf:"bytes,4,opt,name=analyzer,proto3" json:"analyzer,omitempty"`
 	Properties    map[string]*FieldMapping `protobuf:"bytes,5,rep,name=properties,proto3" json:"properties,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Dimension     int32                    `protobuf:"varint,6,opt,name=dimension,proto3" json:"dimension,omitempty"`                 // knn_vector only
+	SpaceType     string                   `protobuf:"bytes,7,opt,name=space_type,json=spaceType,proto3" json:"space_type,omitempty"` // knn_vector only: l2, cosinesimil, innerproduct
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -1377,6 +1407,20 @@ func (x *FieldMapping) GetProperties() map[string]*FieldMapping {
 	return nil
 }
 
+func (x *FieldMapping) GetDimension() int32 {
+	if x != nil {
+		return x.Dimension
+	}
+	return 0
+}
+
+func (x *FieldMapping) GetSpaceType() string {
+	if x != nil {
+		return x.SpaceType
+	}
+	return ""
+}
+
 // Shard Allocation
 type AllocateShardRequest struct {
 	state           protoimpl.MessageState `protogen:"open.v1"`
@@ -1670,6 +1714,291 @@ func (x *ShardRelocation) GetToNode() string {
 	return ""
 }
 
+// FailShardRequest is sent by a primary whose replica failed a replicated
+// write, to take the replica out of the in-sync set
+type FailShardRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	IndexName     string                 `protobuf:"bytes,1,opt,name=index_name,json=indexName,proto3" json:"index_name,omitempty"`
+	ShardId       int32                  `protobuf:"varint,2,opt,name=shard_id,json=shardId,proto3" json:"shard_id,omitempty"`
+	NodeId        string                 `protobuf:"bytes,3,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`                 // Node of the failed replica
+	PrimaryTerm   int64                  `protobuf:"varint,4,opt,name=primary_term,json=primaryTerm,proto3" json:"primary_term,omitempty"` // Term of the primary reporting the failure
+	Reason        string                 `protobuf:"bytes,5,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FailShardRequest) Reset() {
+	*x = FailShardRequest{}
+	mi := &file_pkg_common_proto_master_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FailShardRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FailShardRequest) ProtoMessage() {}
+
+func (x *FailShardRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_common_proto_master_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FailShardRequest.ProtoReflect.Descriptor instead.
+func (*FailShardRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *FailShardRequest) GetIndexName() string {
+	if x != nil {
+		return x.IndexName
+	}
+	return ""
+}
+
+func (x *FailShardRequest) GetShardId() int32 {
+	if x != nil {
+		return x.ShardId
+	}
+	return 0
+}
+
+func (x *FailShardRequest) GetNodeId() string {
+	if x != nil {
+		return x.NodeId
+	}
+	return ""
+}
+
+func (x *FailShardRequest) GetPrimaryTerm() int64 {
+	if x != nil {
+		return x.PrimaryTerm
+	}
+	return 0
+}
+
+func (x *FailShardRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type FailShardResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Acknowledged  bool                   `protobuf:"varint,1,opt,name=acknowledged,proto3" json:"acknowledged,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FailShardResponse) Reset() {
+	*x = FailShardResponse{}
+	mi := &file_pkg_common_proto_master_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FailShardResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FailShardResponse) ProtoMessage() {}
+
+func (x *FailShardResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_common_proto_master_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FailShardResponse.ProtoReflect.Descriptor instead.
+func (*FailShardResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *FailShardResponse) GetAcknowledged() bool {
+	if x != nil {
+		return x.Acknowledged
+	}
+	return false
+}
+
+// ReportShardRecoveryRequest is sent by a replica recovering from its
+// primary as it moves through the recovery stages. The copy starts once the
+// replica reports the done stage.
+type ReportShardRecoveryRequest struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	IndexName           string                 `protobuf:"bytes,1,opt,name=index_name,json=indexName,proto3" json:"index_name,omitempty"`
+	ShardId             int32                  `protobuf:"varint,2,opt,name=shard_id,json=shardId,proto3" json:"shard_id,omitempty"`
+	NodeId              string                 `protobuf:"bytes,3,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`                     // Node of the recovering replica
+	Stage               string                 `protobuf:"bytes,4,opt,name=stage,proto3" json:"stage,omitempty"`                                     // "index", "translog", "done" or "failed"
+	SourceNodeId        string                 `protobuf:"bytes,5,opt,name=source_node_id,json=sourceNodeId,proto3" json:"source_node_id,omitempty"` // Node of the primary recovered from
+	FilesTotal          int32                  `protobuf:"varint,6,opt,name=files_total,json=filesTotal,proto3" json:"files_total,omitempty"`
+	BytesTotal          int64                  `protobuf:"varint,7,opt,name=bytes_total,json=bytesTotal,proto3" json:"bytes_total,omitempty"`
+	BytesRecovered      int64                  `protobuf:"varint,8,opt,name=bytes_recovered,json=bytesRecovered,proto3" json:"bytes_recovered,omitempty"`
+	OperationsRecovered int64                  `protobuf:"varint,9,opt,name=operations_recovered,json=operationsRecovered,proto3" json:"operations_recovered,omitempty"`
+	Failure             string                 `protobuf:"bytes,10,opt,name=failure,proto3" json:"failure,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *ReportShardRecoveryRequest) Reset() {
+	*x = ReportShardRecoveryRequest{}
+	mi := &file_pkg_common_proto_master_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReportShardRecoveryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReportShardRecoveryRequest) ProtoMessage() {}
+
+func (x *ReportShardRecoveryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_common_proto_master_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReportShardRecoveryRequest.ProtoReflect.Descriptor instead.
+func (*ReportShardRecoveryRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *ReportShardRecoveryRequest) GetIndexName() string {
+	if x != nil {
+		return x.IndexName
+	}
+	return ""
+}
+
+func (x *ReportShardRecoveryRequest) GetShardId() int32 {
+	if x != nil {
+		return x.ShardId
+	}
+	return 0
+}
+
+func (x *ReportShardRecoveryRequest) GetNodeId() string {
+	if x != nil {
+		return x.NodeId
+	}
+	return ""
+}
+
+func (x *ReportShardRecoveryRequest) GetStage() string {
+	if x != nil {
+		return x.Stage
+	}
+	return ""
+}
+
+func (x *ReportShardRecoveryRequest) GetSourceNodeId() string {
+	if x != nil {
+		return x.SourceNodeId
+	}
+	return ""
+}
+
+func (x *ReportShardRecoveryRequest) GetFilesTotal() int32 {
+	if x != nil {
+		return x.FilesTotal
+	}
+	return 0
+}
+
+func (x *ReportShardRecoveryRequest) GetBytesTotal() int64 {
+	if x != nil {
+		return x.BytesTotal
+	}
+	return 0
+}
+
+func (x *ReportShardRecoveryRequest) GetBytesRecovered() int64 {
+	if x != nil {
+		return x.BytesRecovered
+	}
+	return 0
+}
+
+func (x *ReportShardRecoveryRequest) GetOperationsRecovered() int64 {
+	if x != nil {
+		return x.OperationsRecovered
+	}
+	return 0
+}
+
+func (x *ReportShardRecoveryRequest) GetFailure() string {
+	if x != nil {
+		return x.Failure
+	}
+	return ""
+}
+
+type ReportShardRecoveryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Acknowledged  bool                   `protobuf:"varint,1,opt,name=acknowledged,proto3" json:"acknowledged,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReportShardRecoveryResponse) Reset() {
+	*x = ReportShardRecoveryResponse{}
+	mi := &file_pkg_common_proto_master_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReportShardRecoveryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReportShardRecoveryResponse) ProtoMessage() {}
+
+func (x *ReportShardRecoveryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_common_proto_master_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReportShardRecoveryResponse.ProtoReflect.Descriptor instead.
+func (*ReportShardRecoveryResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *ReportShardRecoveryResponse) GetAcknowledged() bool {
+	if x != nil {
+		return x.Acknowledged
+	}
+	return false
+}
+
 // Routing Table
 type RoutingTable struct {
 	state         protoimpl.MessageState        `protogen:"open.v1"`
@@ -1681,7 +2010,7 @@ type RoutingTable struct {
 
 func (x *RoutingTable) Reset() {
 	*x = RoutingTable{}
-	mi := &file_pkg_common_proto_master_proto_msgTypes[22]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[26]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1693,7 +2022,7 @@ func (x *RoutingTable) String() string {
 func (*RoutingTable) ProtoMessage() {}
 
 func (x *RoutingTable) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_master_proto_msgTypes[22]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[26]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1706,7 +2035,7 @@ func (x *RoutingTable) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RoutingTable.ProtoReflect.Descriptor instead.
 func (*RoutingTable) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{22}
+	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{26}
 }
 
 func (x *RoutingTable) GetVersion() int64 {
@@ -1733,7 +2062,7 @@ type IndexRoutingTable struct {
 
 func (x *IndexRoutingTable) Reset() {
 	*x = IndexRoutingTable{}
-	mi := &file_pkg_common_proto_master_proto_msgTypes[23]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[27]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1745,7 +2074,7 @@ func (x *IndexRoutingTable) String() string {
 func (*IndexRoutingTable) ProtoMessage() {}
 
 func (x *IndexRoutingTable) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_master_proto_msgTypes[23]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[27]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1758,7 +2087,7 @@ func (x *IndexRoutingTable) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use IndexRoutingTable.ProtoReflect.Descriptor instead.
 func (*IndexRoutingTable) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{23}
+	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{27}
 }
 
 func (x *IndexRoutingTable) GetIndexName() string {
@@ -1780,13 +2109,14 @@ type ShardRouting struct {
 	ShardId       int32                  `protobuf:"varint,1,opt,name=shard_id,json=shardId,proto3" json:"shard_id,omitempty"`
 	IsPrimary     bool                   `protobuf:"varint,2,opt,name=is_primary,json=isPrimary,proto3" json:"is_primary,omitempty"`
 	Allocation    *ShardAllocation       `protobuf:"bytes,3,opt,name=allocation,proto3" json:"allocation,omitempty"`
+	Replicas      []*ShardAllocation     `protobuf:"bytes,4,rep,name=replicas,proto3" json:"replicas,omitempty"` // The shard's replicas, on the primary's routing
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ShardRouting) Reset() {
 	*x = ShardRouting{}
-	mi := &file_pkg_common_proto_master_proto_msgTypes[24]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[28]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1798,7 +2128,7 @@ func (x *ShardRouting) String() string {
 func (*ShardRouting) ProtoMessage() {}
 
 func (x *ShardRouting) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_master_proto_msgTypes[24]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[28]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1811,7 +2141,7 @@ func (x *ShardRouting) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ShardRouting.ProtoReflect.Descriptor instead.
 func (*ShardRouting) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{24}
+	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{28}
 }
 
 func (x *ShardRouting) GetShardId() int32 {
@@ -1835,18 +2165,27 @@ func (x *ShardRouting) GetAllocation() *ShardAllocation {
 	return nil
 }
 
+func (x *ShardRouting) GetReplicas() []*ShardAllocation {
+	if x != nil {
+		return x.Replicas
+	}
+	return nil
+}
+
 type ShardAllocation struct {
 	state         protoimpl.MessageState     `protogen:"open.v1"`
 	NodeId        string                     `protobuf:"bytes,1,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
-	State         ShardAllocation_ShardState `protobuf:"varint,2,opt,name=state,proto3,enum=quidditch.master.ShardAllocation_ShardState" json:"state,omitempty"`
+	State         ShardAllocation_ShardState `protobuf:"varint,2,opt,name=state,proto3,enum=conjugate.master.ShardAllocation_ShardState" json:"state,omitempty"`
 	AllocatedAt   *timestamppb.Timestamp     `protobuf:"bytes,3,opt,name=allocated_at,json=allocatedAt,proto3" json:"allocated_at,omitempty"`
+	InSync        bool                       `protobuf:"varint,4,opt,name=in_sync,json=inSync,proto3" json:"in_sync,omitempty"`                // The copy has every write acknowledged by the primary
+	PrimaryTerm   int64                      `protobuf:"varint,5,opt,name=primary_term,json=primaryTerm,proto3" json:"primary_term,omitempty"` // Set on a primary: bumped each time the shard gets a new primary
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ShardAllocation) Reset() {
 	*x = ShardAllocation{}
-	mi := &file_pkg_common_proto_master_proto_msgTypes[25]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[29]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1858,7 +2197,7 @@ func (x *ShardAllocation) String() string {
 func (*ShardAllocation) ProtoMessage() {}
 
 func (x *ShardAllocation) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_master_proto_msgTypes[25]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[29]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1871,7 +2210,7 @@ func (x *ShardAllocation) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ShardAllocation.ProtoReflect.Descriptor instead.
 func (*ShardAllocation) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{25}
+	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{29}
 }
 
 func (x *ShardAllocation) GetNodeId() string {
@@ -1895,21 +2234,36 @@ func (x *ShardAllocation) GetAllocatedAt() *timestamppb.Timestamp {
 	return nil
 }
 
+func (x *ShardAllocation) GetInSync() bool {
+	if x != nil {
+		return x.InSync
+	}
+	return false
+}
+
+func (x *ShardAllocation) GetPrimaryTerm() int64 {
+	if x != nil {
+		return x.PrimaryTerm
+	}
+	return 0
+}
+
 // Node Management
 type RegisterNodeRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	NodeId        string                 `protobuf:"bytes,1,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
-	NodeType      NodeType               `protobuf:"varint,2,opt,name=node_type,json=nodeType,proto3,enum=quidditch.master.NodeType" json:"node_type,omitempty"`
+	NodeType      NodeType               `protobuf:"varint,2,opt,name=node_type,json=nodeType,proto3,enum=conjugate.master.NodeType" json:"node_type,omitempty"`
 	BindAddr      string                 `protobuf:"bytes,3,opt,name=bind_addr,json=bindAddr,proto3" json:"bind_addr,omitempty"`
 	GrpcPort      int32                  `protobuf:"varint,4,opt,name=grpc_port,json=grpcPort,proto3" json:"grpc_port,omitempty"`
 	Attributes    *NodeAttributes        `protobuf:"bytes,5,opt,name=attributes,proto3" json:"attributes,omitempty"`
+	Shards        []*ShardReport         `protobuf:"bytes,6,rep,name=shards,proto3" json:"shards,omitempty"` // Shards the node recovered from local disk
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *RegisterNodeRequest) Reset() {
 	*x = RegisterNodeRequest{}
-	mi := &file_pkg_common_proto_master_proto_msgTypes[26]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[30]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1921,7 +2275,7 @@ func (x *RegisterNodeRequest) String() string {
 func (*RegisterNodeRequest) ProtoMessage() {}
 
 func (x *RegisterNodeRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_master_proto_msgTypes[26]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[30]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1934,7 +2288,7 @@ func (x *RegisterNodeRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RegisterNodeRequest.ProtoReflect.Descriptor instead.
 func (*RegisterNodeRequest) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{26}
+	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{30}
 }
 
 func (x *RegisterNodeRequest) GetNodeId() string {
@@ -1972,6 +2326,13 @@ func (x *RegisterNodeRequest) GetAttributes() *NodeAttributes {
 	return nil
 }
 
+func (x *RegisterNodeRequest) GetShards() []*ShardReport {
+	if x != nil {
+		return x.Shards
+	}
+	return nil
+}
+
 type RegisterNodeResponse struct {
 	state          protoimpl.MessageState `protogen:"open.v1"`
 	Acknowledged   bool                   `protobuf:"varint,1,opt,name=acknowledged,proto3" json:"acknowledged,omitempty"`
@@ -1982,7 +2343,7 @@ type RegisterNodeResponse struct {
 
 func (x *RegisterNodeResponse) Reset() {
 	*x = RegisterNodeResponse{}
-	mi := &file_pkg_common_proto_master_proto_msgTypes[27]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[31]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1994,7 +2355,7 @@ func (x *RegisterNodeResponse) String() string {
 func (*RegisterNodeResponse) ProtoMessage() {}
 
 func (x *RegisterNodeResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_master_proto_msgTypes[27]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[31]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2007,7 +2368,7 @@ func (x *RegisterNodeResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RegisterNodeResponse.ProtoReflect.Descriptor instead.
 func (*RegisterNodeResponse) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{27}
+	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{31}
 }
 
 func (x *RegisterNodeResponse) GetAcknowledged() bool {
@@ -2033,7 +2394,7 @@ type UnregisterNodeRequest struct {
 
 func (x *UnregisterNodeRequest) Reset() {
 	*x = UnregisterNodeRequest{}
-	mi := &file_pkg_common_proto_master_proto_msgTypes[28]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[32]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2045,7 +2406,7 @@ func (x *UnregisterNodeRequest) String() string {
 func (*UnregisterNodeRequest) ProtoMessage() {}
 
 func (x *UnregisterNodeRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_master_proto_msgTypes[28]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[32]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2058,7 +2419,7 @@ func (x *UnregisterNodeRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UnregisterNodeRequest.ProtoReflect.Descriptor instead.
 func (*UnregisterNodeRequest) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{28}
+	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{32}
 }
 
 func (x *UnregisterNodeRequest) GetNodeId() string {
@@ -2077,7 +2438,7 @@ type UnregisterNodeResponse struct {
 
 func (x *UnregisterNodeResponse) Reset() {
 	*x = UnregisterNodeResponse{}
-	mi := &file_pkg_common_proto_master_proto_msgTypes[29]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[33]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2089,7 +2450,7 @@ func (x *UnregisterNodeResponse) String() string {
 func (*UnregisterNodeResponse) ProtoMessage() {}
 
 func (x *UnregisterNodeResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_master_proto_msgTypes[29]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[33]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2102,7 +2463,7 @@ func (x *UnregisterNodeResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UnregisterNodeResponse.ProtoReflect.Descriptor instead.
 func (*UnregisterNodeResponse) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{29}
+	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{33}
 }
 
 func (x *UnregisterNodeResponse) GetAcknowledged() bool {
@@ -2116,13 +2477,14 @@ type NodeHeartbeatRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	NodeId        string                 `protobuf:"bytes,1,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
 	Stats         *NodeStats             `protobuf:"bytes,2,opt,name=stats,proto3" json:"stats,omitempty"`
+	Shards        []*ShardReport         `protobuf:"bytes,3,rep,name=shards,proto3" json:"shards,omitempty"` // Shards currently hosted by the node
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *NodeHeartbeatRequest) Reset() {
 	*x = NodeHeartbeatRequest{}
-	mi := &file_pkg_common_proto_master_proto_msgTypes[30]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[34]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2134,7 +2496,7 @@ func (x *NodeHeartbeatRequest) String() string {
 func (*NodeHeartbeatRequest) ProtoMessage() {}
 
 func (x *NodeHeartbeatRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_master_proto_msgTypes[30]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[34]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2147,7 +2509,7 @@ func (x *NodeHeartbeatRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use NodeHeartbeatRequest.ProtoReflect.Descriptor instead.
 func (*NodeHeartbeatRequest) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{30}
+	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{34}
 }
 
 func (x *NodeHeartbeatRequest) GetNodeId() string {
@@ -2164,6 +2526,13 @@ func (x *NodeHeartbeatRequest) GetStats() *NodeStats {
 	return nil
 }
 
+func (x *NodeHeartbeatRequest) GetShards() []*ShardReport {
+	if x != nil {
+		return x.Shards
+	}
+	return nil
+}
+
 type NodeHeartbeatResponse struct {
 	state          protoimpl.MessageState `protogen:"open.v1"`
 	Acknowledged   bool                   `protobuf:"varint,1,opt,name=acknowledged,proto3" json:"acknowledged,omitempty"`
@@ -2174,7 +2543,7 @@ type NodeHeartbeatResponse struct {
 
 func (x *NodeHeartbeatResponse) Reset() {
 	*x = NodeHeartbeatResponse{}
-	mi := &file_pkg_common_proto_master_proto_msgTypes[31]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[35]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2186,7 +2555,7 @@ func (x *NodeHeartbeatResponse) String() string {
 func (*NodeHeartbeatResponse) ProtoMessage() {}
 
 func (x *NodeHeartbeatResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_master_proto_msgTypes[31]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[35]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2199,7 +2568,7 @@ func (x *NodeHeartbeatResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use NodeHeartbeatResponse.ProtoReflect.Descriptor instead.
 func (*NodeHeartbeatResponse) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{31}
+	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{35}
 }
 
 func (x *NodeHeartbeatResponse) GetAcknowledged() bool {
@@ -2220,11 +2589,11 @@ type NodeInfo struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	NodeId        string                 `protobuf:"bytes,1,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
 	NodeName      string                 `protobuf:"bytes,2,opt,name=node_name,json=nodeName,proto3" json:"node_name,omitempty"`
-	NodeType      NodeType               `protobuf:"varint,3,opt,name=node_type,json=nodeType,proto3,enum=quidditch.master.NodeType" json:"node_type,omitempty"`
+	NodeType      NodeType               `protobuf:"varint,3,opt,name=node_type,json=nodeType,proto3,enum=conjugate.master.NodeType" json:"node_type,omitempty"`
 	BindAddr      string                 `protobuf:"bytes,4,opt,name=bind_addr,json=bindAddr,proto3" json:"bind_addr,omitempty"`
 	GrpcPort      int32                  `protobuf:"varint,5,opt,name=grpc_port,json=grpcPort,proto3" json:"grpc_port,omitempty"`
 	Attributes    *NodeAttributes        `protobuf:"bytes,6,opt,name=attributes,proto3" json:"attributes,omitempty"`
-	Status        NodeStatus             `protobuf:"varint,7,opt,name=status,proto3,enum=quidditch.master.NodeStatus" json:"status,omitempty"`
+	Status        NodeStatus             `protobuf:"varint,7,opt,name=status,proto3,enum=conjugate.master.NodeStatus" json:"status,omitempty"`
 	JoinedAt      *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=joined_at,json=joinedAt,proto3" json:"joined_at,omitempty"`
 	LastSeen      *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=last_seen,json=lastSeen,proto3" json:"last_seen,omitempty"`
 	unknownFields protoimpl.UnknownFields
@@ -2233,7 +2602,7 @@ type NodeInfo struct {
 
 func (x *NodeInfo) Reset() {
 	*x = NodeInfo{}
-	mi := &file_pkg_common_proto_master_proto_msgTypes[32]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[36]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2245,7 +2614,7 @@ func (x *NodeInfo) String() string {
 func (*NodeInfo) ProtoMessage() {}
 
 func (x *NodeInfo) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_master_proto_msgTypes[32]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[36]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2258,7 +2627,7 @@ func (x *NodeInfo) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use NodeInfo.ProtoReflect.Descriptor instead.
 func (*NodeInfo) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{32}
+	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{36}
 }
 
 func (x *NodeInfo) GetNodeId() string {
@@ -2337,7 +2706,7 @@ type NodeAttributes struct {
 
 func (x *NodeAttributes) Reset() {
 	*x = NodeAttributes{}
-	mi := &file_pkg_common_proto_master_proto_msgTypes[33]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[37]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2349,7 +2718,7 @@ func (x *NodeAttributes) String() string {
 func (*NodeAttributes) ProtoMessage() {}
 
 func (x *NodeAttributes) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_master_proto_msgTypes[33]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[37]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2362,7 +2731,7 @@ func (x *NodeAttributes) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use NodeAttributes.ProtoReflect.Descriptor instead.
 func (*NodeAttributes) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{33}
+	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{37}
 }
 
 func (x *NodeAttributes) GetStorageTier() string {
@@ -2416,7 +2785,7 @@ type NodeStats struct {
 
 func (x *NodeStats) Reset() {
 	*x = NodeStats{}
-	mi := &file_pkg_common_proto_master_proto_msgTypes[34]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[38]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2428,7 +2797,7 @@ func (x *NodeStats) String() string {
 func (*NodeStats) ProtoMessage() {}
 
 func (x *NodeStats) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_master_proto_msgTypes[34]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[38]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2441,7 +2810,7 @@ func (x *NodeStats) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use NodeStats.ProtoReflect.Descriptor instead.
 func (*NodeStats) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{34}
+	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{38}
 }
 
 func (x *NodeStats) GetTotalShards() int64 {
@@ -2500,6 +2869,91 @@ func (x *NodeStats) GetIndexingRatePerSec() int64 {
 	return 0
 }
 
+// ShardReport describes a shard copy hosted by a data node
+type ShardReport struct {
+	state         protoimpl.MessageState     `protogen:"open.v1"`
+	IndexName     string                     `protobuf:"bytes,1,opt,name=index_name,json=indexName,proto3" json:"index_name,omitempty"`
+	ShardId       int32                      `protobuf:"varint,2,opt,name=shard_id,json=shardId,proto3" json:"shard_id,omitempty"`
+	IsPrimary     bool                       `protobuf:"varint,3,opt,name=is_primary,json=isPrimary,proto3" json:"is_primary,omitempty"`
+	State         ShardAllocation_ShardState `protobuf:"varint,4,opt,name=state,proto3,enum=conjugate.master.ShardAllocation_ShardState" json:"state,omitempty"`
+	DocsCount     int64                      `protobuf:"varint,5,opt,name=docs_count,json=docsCount,proto3" json:"docs_count,omitempty"`
+	SizeBytes     int64                      `protobuf:"varint,6,opt,name=size_bytes,json=sizeBytes,proto3" json:"size_bytes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ShardReport) Reset() {
+	*x = ShardReport{}
+	mi := &file_pkg_common_proto_master_proto_msgTypes[39]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ShardReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShardReport) ProtoMessage() {}
+
+func (x *ShardReport) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_common_proto_master_proto_msgTypes[39]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShardReport.ProtoReflect.Descriptor instead.
+func (*ShardReport) Descriptor() ([]byte, []int) {
+	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *ShardReport) GetIndexName() string {
+	if x != nil {
+		return x.IndexName
+	}
+	return ""
+}
+
+func (x *ShardReport) GetShardId() int32 {
+	if x != nil {
+		return x.ShardId
+	}
+	return 0
+}
+
+func (x *ShardReport) GetIsPrimary() bool {
+	if x != nil {
+		return x.IsPrimary
+	}
+	return false
+}
+
+func (x *ShardReport) GetState() ShardAllocation_ShardState {
+	if x != nil {
+		return x.State
+	}
+	return ShardAllocation_SHARD_STATE_UNKNOWN
+}
+
+func (x *ShardReport) GetDocsCount() int64 {
+	if x != nil {
+		return x.DocsCount
+	}
+	return 0
+}
+
+func (x *ShardReport) GetSizeBytes() int64 {
+	if x != nil {
+		return x.SizeBytes
+	}
+	return 0
+}
+
 type MasterNode struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	NodeId        string                 `protobuf:"bytes,1,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
@@ -2512,7 +2966,7 @@ type MasterNode struct {
 
 func (x *MasterNode) Reset() {
 	*x = MasterNode{}
-	mi := &file_pkg_common_proto_master_proto_msgTypes[35]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[40]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2524,7 +2978,7 @@ func (x *MasterNode) String() string {
 func (*MasterNode) ProtoMessage() {}
 
 func (x *MasterNode) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_common_proto_master_proto_msgTypes[35]
+	mi := &file_pkg_common_proto_master_proto_msgTypes[40]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2537,7 +2991,7 @@ func (x *MasterNode) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use MasterNode.ProtoReflect.Descriptor instead.
 func (*MasterNode) Descriptor() ([]byte, []int) {
-	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{35}
+	return file_pkg_common_proto_master_proto_rawDescGZIP(), []int{40}
 }
 
 func (x *MasterNode) GetNodeId() string {
@@ -2572,7 +3026,7 @@ var File_pkg_common_proto_master_proto protoreflect.FileDescriptor
 
 const file_pkg_common_proto_master_proto_rawDesc = "" +
 	"\n" +
-	"\x1dpkg/common/proto/master.proto\x12\x10quidditch.master\x1a\x1fgoogle/protobuf/timestamp.proto\"\x8f\x01\n" +
+	"\x1dpkg/common/proto/master.proto\x12\x10conjugate.master\x1a\x1fgoogle/protobuf/timestamp.proto\"\x8f\x01\n" +
 	"\x16GetClusterStateRequest\x12'\n" +
 	"\x0finclude_routing\x18\x01 \x01(\bR\x0eincludeRouting\x12#\n" +
 	"\rinclude_nodes\x18\x02 \x01(\bR\fincludeNodes\x12'\n" +
@@ -2581,18 +3035,18 @@ const file_pkg_common_proto_master_proto_rawDesc = "" +
 	"\aversion\x18\x01 \x01(\x03R\aversion\x12!\n" +
 	"\fcluster_name\x18\x02 \x01(\tR\vclusterName\x12!\n" +
 	"\fcluster_uuid\x18\x03 \x01(\tR\vclusterUuid\x127\n" +
-	"\x06status\x18\x04 \x01(\x0e2\x1f.quidditch.master.ClusterStatusR\x06status\x129\n" +
-	"\aindices\x18\x05 \x03(\v2\x1f.quidditch.master.IndexMetadataR\aindices\x12C\n" +
-	"\rrouting_table\x18\x06 \x01(\v2\x1e.quidditch.master.RoutingTableR\froutingTable\x120\n" +
-	"\x05nodes\x18\a \x03(\v2\x1a.quidditch.master.NodeInfoR\x05nodes\x12=\n" +
-	"\vmaster_node\x18\b \x01(\v2\x1c.quidditch.master.MasterNodeR\n" +
+	"\x06status\x18\x04 \x01(\x0e2\x1f.conjugate.master.ClusterStatusR\x06status\x129\n" +
+	"\aindices\x18\x05 \x03(\v2\x1f.conjugate.master.IndexMetadataR\aindices\x12C\n" +
+	"\rrouting_table\x18\x06 \x01(\v2\x1e.conjugate.master.RoutingTableR\froutingTable\x120\n" +
+	"\x05nodes\x18\a \x03(\v2\x1a.conjugate.master.NodeInfoR\x05nodes\x12=\n" +
+	"\vmaster_node\x18\b \x01(\v2\x1c.conjugate.master.MasterNodeR\n" +
 	"masterNode\"=\n" +
 	"\x18WatchClusterStateRequest\x12!\n" +
-	"\ffrom_version\x18\x01 \x01(\x03R\vfromVersion\"\xe2\x02\n" +
+	"\ffrom_version\x18\x01 \x01(\x03R\vfromVersion\"\xdd\x03\n" +
 	"\x11ClusterStateEvent\x12\x18\n" +
 	"\aversion\x18\x01 \x01(\x03R\aversion\x12A\n" +
-	"\x04type\x18\x02 \x01(\x0e2-.quidditch.master.ClusterStateEvent.EventTypeR\x04type\x12\x18\n" +
-	"\apayload\x18\x03 \x01(\fR\apayload\"\xd5\x01\n" +
+	"\x04type\x18\x02 \x01(\x0e2-.conjugate.master.ClusterStateEvent.EventTypeR\x04type\x12\x18\n" +
+	"\apayload\x18\x03 \x01(\fR\apayload\"\xd0\x02\n" +
 	"\tEventType\x12\x16\n" +
 	"\x12EVENT_TYPE_UNKNOWN\x10\x00\x12\x1c\n" +
 	"\x18EVENT_TYPE_INDEX_CREATED\x10\x01\x12\x1c\n" +
@@ -2600,16 +3054,21 @@ const file_pkg_common_proto_master_proto_rawDesc = "" +
 	"\x1aEVENT_TYPE_SHARD_ALLOCATED\x10\x03\x12\x1e\n" +
 	"\x1aEVENT_TYPE_SHARD_RELOCATED\x10\x04\x12\x1a\n" +
 	"\x16EVENT_TYPE_NODE_JOINED\x10\x05\x12\x18\n" +
-	"\x14EVENT_TYPE_NODE_LEFT\x10\x06\"\xa6\x03\n" +
+	"\x14EVENT_TYPE_NODE_LEFT\x10\x06\x12\x1c\n" +
+	"\x18EVENT_TYPE_INDEX_UPDATED\x10\a\x12\x1b\n" +
+	"\x17EVENT_TYPE_NODE_UPDATED\x10\b\x12\x1c\n" +
+	"\x18EVENT_TYPE_SHARD_UPDATED\x10\t\x12 \n" +
+	"\x1cEVENT_TYPE_SHARD_DEALLOCATED\x10\n" +
+	"\"\xa6\x03\n" +
 	"\x12CreateIndexRequest\x12\x1d\n" +
 	"\n" +
 	"index_name\x18\x01 \x01(\tR\tindexName\x12;\n" +
-	"\bsettings\x18\x02 \x01(\v2\x1f.quidditch.master.IndexSettingsR\bsettings\x12N\n" +
-	"\bmappings\x18\x03 \x03(\v22.quidditch.master.CreateIndexRequest.MappingsEntryR\bmappings\x12K\n" +
-	"\aaliases\x18\x04 \x03(\v21.quidditch.master.CreateIndexRequest.AliasesEntryR\aaliases\x1a[\n" +
+	"\bsettings\x18\x02 \x01(\v2\x1f.conjugate.master.IndexSettingsR\bsettings\x12N\n" +
+	"\bmappings\x18\x03 \x03(\v22.conjugate.master.CreateIndexRequest.MappingsEntryR\bmappings\x12K\n" +
+	"\aaliases\x18\x04 \x03(\v21.conjugate.master.CreateIndexRequest.AliasesEntryR\aaliases\x1a[\n" +
 	"\rMappingsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x124\n" +
-	"\x05value\x18\x02 \x01(\v2\x1e.quidditch.master.FieldMappingR\x05value:\x028\x01\x1a:\n" +
+	"\x05value\x18\x02 \x01(\v2\x1e.conjugate.master.FieldMappingR\x05value:\x028\x01\x1a:\n" +
 	"\fAliasesEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
 	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"r\n" +
@@ -2626,29 +3085,29 @@ const file_pkg_common_proto_master_proto_rawDesc = "" +
 	"\x1aUpdateIndexSettingsRequest\x12\x1d\n" +
 	"\n" +
 	"index_name\x18\x01 \x01(\tR\tindexName\x12;\n" +
-	"\bsettings\x18\x02 \x01(\v2\x1f.quidditch.master.IndexSettingsR\bsettings\"A\n" +
+	"\bsettings\x18\x02 \x01(\v2\x1f.conjugate.master.IndexSettingsR\bsettings\"A\n" +
 	"\x1bUpdateIndexSettingsResponse\x12\"\n" +
 	"\facknowledged\x18\x01 \x01(\bR\facknowledged\"8\n" +
 	"\x17GetIndexMetadataRequest\x12\x1d\n" +
 	"\n" +
 	"index_name\x18\x01 \x01(\tR\tindexName\"T\n" +
 	"\x15IndexMetadataResponse\x12;\n" +
-	"\bmetadata\x18\x01 \x01(\v2\x1f.quidditch.master.IndexMetadataR\bmetadata\"\xd7\x05\n" +
+	"\bmetadata\x18\x01 \x01(\v2\x1f.conjugate.master.IndexMetadataR\bmetadata\"\xd7\x05\n" +
 	"\rIndexMetadata\x12\x1d\n" +
 	"\n" +
 	"index_name\x18\x01 \x01(\tR\tindexName\x12\x1d\n" +
 	"\n" +
 	"index_uuid\x18\x02 \x01(\tR\tindexUuid\x12\x18\n" +
 	"\aversion\x18\x03 \x01(\x03R\aversion\x12;\n" +
-	"\bsettings\x18\x04 \x01(\v2\x1f.quidditch.master.IndexSettingsR\bsettings\x12I\n" +
-	"\bmappings\x18\x05 \x03(\v2-.quidditch.master.IndexMetadata.MappingsEntryR\bmappings\x12F\n" +
-	"\aaliases\x18\x06 \x03(\v2,.quidditch.master.IndexMetadata.AliasesEntryR\aaliases\x12@\n" +
-	"\x05state\x18\a \x01(\x0e2*.quidditch.master.IndexMetadata.IndexStateR\x05state\x129\n" +
+	"\bsettings\x18\x04 \x01(\v2\x1f.conjugate.master.IndexSettingsR\bsettings\x12I\n" +
+	"\bmappings\x18\x05 \x03(\v2-.conjugate.master.IndexMetadata.MappingsEntryR\bmappings\x12F\n" +
+	"\aaliases\x18\x06 \x03(\v2,.conjugate.master.IndexMetadata.AliasesEntryR\aaliases\x12@\n" +
+	"\x05state\x18\a \x01(\x0e2*.conjugate.master.IndexMetadata.IndexStateR\x05state\x129\n" +
 	"\n" +
 	"created_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x1a[\n" +
 	"\rMappingsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x124\n" +
-	"\x05value\x18\x02 \x01(\v2\x1e.quidditch.master.FieldMappingR\x05value:\x028\x01\x1a:\n" +
+	"\x05value\x18\x02 \x01(\v2\x1e.conjugate.master.FieldMappingR\x05value:\x028\x01\x1a:\n" +
 	"\fAliasesEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
 	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x87\x01\n" +
@@ -2658,34 +3117,39 @@ const file_pkg_common_proto_master_proto_rawDesc = "" +
 	"\x14INDEX_STATE_CREATING\x10\x01\x12\x14\n" +
 	"\x10INDEX_STATE_OPEN\x10\x02\x12\x16\n" +
 	"\x12INDEX_STATE_CLOSED\x10\x03\x12\x18\n" +
-	"\x14INDEX_STATE_DELETING\x10\x04\"\x98\x02\n" +
+	"\x14INDEX_STATE_DELETING\x10\x04\"\xed\x02\n" +
 	"\rIndexSettings\x12(\n" +
 	"\x10number_of_shards\x18\x01 \x01(\x05R\x0enumberOfShards\x12,\n" +
 	"\x12number_of_replicas\x18\x02 \x01(\x05R\x10numberOfReplicas\x12)\n" +
 	"\x10refresh_interval\x18\x03 \x01(\tR\x0frefreshInterval\x12G\n" +
-	"\vcompression\x18\x04 \x01(\v2%.quidditch.master.CompressionSettingsR\vcompression\x12;\n" +
-	"\atiering\x18\x05 \x01(\v2!.quidditch.master.TieringSettingsR\atiering\"A\n" +
+	"\vcompression\x18\x04 \x01(\v2%.conjugate.master.CompressionSettingsR\vcompression\x12;\n" +
+	"\atiering\x18\x05 \x01(\v2!.conjugate.master.TieringSettingsR\atiering\x12'\n" +
+	"\x0fcommit_interval\x18\x06 \x01(\tR\x0ecommitInterval\x12*\n" +
+	"\x11commit_batch_size\x18\a \x01(\x05R\x0fcommitBatchSize\"A\n" +
 	"\x13CompressionSettings\x12\x14\n" +
 	"\x05codec\x18\x01 \x01(\tR\x05codec\x12\x14\n" +
 	"\x05level\x18\x02 \x01(\x05R\x05level\"\xc3\x01\n" +
 	"\x0fTieringSettings\x12!\n" +
 	"\fdefault_tier\x18\x01 \x01(\tR\vdefaultTier\x12O\n" +
 	"\n" +
-	"tier_rules\x18\x02 \x03(\v20.quidditch.master.TieringSettings.TierRulesEntryR\ttierRules\x1a<\n" +
+	"tier_rules\x18\x02 \x03(\v20.conjugate.master.TieringSettings.TierRulesEntryR\ttierRules\x1a<\n" +
 	"\x0eTierRulesEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x99\x02\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xd6\x02\n" +
 	"\fFieldMapping\x12\x12\n" +
 	"\x04type\x18\x01 \x01(\tR\x04type\x12\x14\n" +
 	"\x05index\x18\x02 \x01(\bR\x05index\x12\x14\n" +
 	"\x05store\x18\x03 \x01(\bR\x05store\x12\x1a\n" +
 	"\banalyzer\x18\x04 \x01(\tR\banalyzer\x12N\n" +
 	"\n" +
-	"properties\x18\x05 \x03(\v2..quidditch.master.FieldMapping.PropertiesEntryR\n" +
-	"properties\x1a]\n" +
+	"properties\x18\x05 \x03(\v2..conjugate.master.FieldMapping.PropertiesEntryR\n" +
+	"properties\x12\x1c\n" +
+	"\tdimension\x18\x06 \x01(\x05R\tdimension\x12\x1d\n" +
+	"\n" +
+	"space_type\x18\a \x01(\tR\tspaceType\x1a]\n" +
 	"\x0fPropertiesEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x124\n" +
-	"\x05value\x18\x02 \x01(\v2\x1e.quidditch.master.FieldMappingR\x05value:\x028\x01\"\x9b\x01\n" +
+	"\x05value\x18\x02 \x01(\v2\x1e.conjugate.master.FieldMappingR\x05value:\x028\x01\"\x9b\x01\n" +
 	"\x14AllocateShardRequest\x12\x1d\n" +
 	"\n" +
 	"index_name\x18\x01 \x01(\tR\tindexName\x12\x19\n" +
@@ -2697,82 +3161,113 @@ const file_pkg_common_proto_master_proto_rawDesc = "" +
 	"\facknowledged\x18\x01 \x01(\bR\facknowledged\x12\x17\n" +
 	"\anode_id\x18\x02 \x01(\tR\x06nodeId\x12A\n" +
 	"\n" +
-	"allocation\x18\x03 \x01(\v2!.quidditch.master.ShardAllocationR\n" +
+	"allocation\x18\x03 \x01(\v2!.conjugate.master.ShardAllocationR\n" +
 	"allocation\"R\n" +
 	"\x16RebalanceShardsRequest\x12\x1f\n" +
 	"\vindex_names\x18\x01 \x03(\tR\n" +
 	"indexNames\x12\x17\n" +
 	"\adry_run\x18\x02 \x01(\bR\x06dryRun\"^\n" +
 	"\x17RebalanceShardsResponse\x12C\n" +
-	"\vrelocations\x18\x01 \x03(\v2!.quidditch.master.ShardRelocationR\vrelocations\"\x81\x01\n" +
+	"\vrelocations\x18\x01 \x03(\v2!.conjugate.master.ShardRelocationR\vrelocations\"\x81\x01\n" +
 	"\x0fShardRelocation\x12\x1d\n" +
 	"\n" +
 	"index_name\x18\x01 \x01(\tR\tindexName\x12\x19\n" +
 	"\bshard_id\x18\x02 \x01(\x05R\ashardId\x12\x1b\n" +
 	"\tfrom_node\x18\x03 \x01(\tR\bfromNode\x12\x17\n" +
-	"\ato_node\x18\x04 \x01(\tR\x06toNode\"\xd0\x01\n" +
+	"\ato_node\x18\x04 \x01(\tR\x06toNode\"\xa0\x01\n" +
+	"\x10FailShardRequest\x12\x1d\n" +
+	"\n" +
+	"index_name\x18\x01 \x01(\tR\tindexName\x12\x19\n" +
+	"\bshard_id\x18\x02 \x01(\x05R\ashardId\x12\x17\n" +
+	"\anode_id\x18\x03 \x01(\tR\x06nodeId\x12!\n" +
+	"\fprimary_term\x18\x04 \x01(\x03R\vprimaryTerm\x12\x16\n" +
+	"\x06reason\x18\x05 \x01(\tR\x06reason\"7\n" +
+	"\x11FailShardResponse\x12\"\n" +
+	"\facknowledged\x18\x01 \x01(\bR\facknowledged\"\xe3\x02\n" +
+	"\x1aReportShardRecoveryRequest\x12\x1d\n" +
+	"\n" +
+	"index_name\x18\x01 \x01(\tR\tindexName\x12\x19\n" +
+	"\bshard_id\x18\x02 \x01(\x05R\ashardId\x12\x17\n" +
+	"\anode_id\x18\x03 \x01(\tR\x06nodeId\x12\x14\n" +
+	"\x05stage\x18\x04 \x01(\tR\x05stage\x12$\n" +
+	"\x0esource_node_id\x18\x05 \x01(\tR\fsourceNodeId\x12\x1f\n" +
+	"\vfiles_total\x18\x06 \x01(\x05R\n" +
+	"filesTotal\x12\x1f\n" +
+	"\vbytes_total\x18\a \x01(\x03R\n" +
+	"bytesTotal\x12'\n" +
+	"\x0fbytes_recovered\x18\b \x01(\x03R\x0ebytesRecovered\x121\n" +
+	"\x14operations_recovered\x18\t \x01(\x03R\x13operationsRecovered\x12\x18\n" +
+	"\afailure\x18\n" +
+	" \x01(\tR\afailure\"A\n" +
+	"\x1bReportShardRecoveryResponse\x12\"\n" +
+	"\facknowledged\x18\x01 \x01(\bR\facknowledged\"\xd0\x01\n" +
 	"\fRoutingTable\x12\x18\n" +
 	"\aversion\x18\x01 \x01(\x03R\aversion\x12E\n" +
-	"\aindices\x18\x02 \x03(\v2+.quidditch.master.RoutingTable.IndicesEntryR\aindices\x1a_\n" +
+	"\aindices\x18\x02 \x03(\v2+.conjugate.master.RoutingTable.IndicesEntryR\aindices\x1a_\n" +
 	"\fIndicesEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x129\n" +
-	"\x05value\x18\x02 \x01(\v2#.quidditch.master.IndexRoutingTableR\x05value:\x028\x01\"\xd6\x01\n" +
+	"\x05value\x18\x02 \x01(\v2#.conjugate.master.IndexRoutingTableR\x05value:\x028\x01\"\xd6\x01\n" +
 	"\x11IndexRoutingTable\x12\x1d\n" +
 	"\n" +
 	"index_name\x18\x01 \x01(\tR\tindexName\x12G\n" +
-	"\x06shards\x18\x02 \x03(\v2/.quidditch.master.IndexRoutingTable.ShardsEntryR\x06shards\x1aY\n" +
+	"\x06shards\x18\x02 \x03(\v2/.conjugate.master.IndexRoutingTable.ShardsEntryR\x06shards\x1aY\n" +
 	"\vShardsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\x05R\x03key\x124\n" +
-	"\x05value\x18\x02 \x01(\v2\x1e.quidditch.master.ShardRoutingR\x05value:\x028\x01\"\x8b\x01\n" +
+	"\x05value\x18\x02 \x01(\v2\x1e.conjugate.master.ShardRoutingR\x05value:\x028\x01\"\xca\x01\n" +
 	"\fShardRouting\x12\x19\n" +
 	"\bshard_id\x18\x01 \x01(\x05R\ashardId\x12\x1d\n" +
 	"\n" +
 	"is_primary\x18\x02 \x01(\bR\tisPrimary\x12A\n" +
 	"\n" +
-	"allocation\x18\x03 \x01(\v2!.quidditch.master.ShardAllocationR\n" +
-	"allocation\"\xc4\x02\n" +
+	"allocation\x18\x03 \x01(\v2!.conjugate.master.ShardAllocationR\n" +
+	"allocation\x12=\n" +
+	"\breplicas\x18\x04 \x03(\v2!.conjugate.master.ShardAllocationR\breplicas\"\x80\x03\n" +
 	"\x0fShardAllocation\x12\x17\n" +
 	"\anode_id\x18\x01 \x01(\tR\x06nodeId\x12B\n" +
-	"\x05state\x18\x02 \x01(\x0e2,.quidditch.master.ShardAllocation.ShardStateR\x05state\x12=\n" +
-	"\fallocated_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\vallocatedAt\"\x94\x01\n" +
+	"\x05state\x18\x02 \x01(\x0e2,.conjugate.master.ShardAllocation.ShardStateR\x05state\x12=\n" +
+	"\fallocated_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\vallocatedAt\x12\x17\n" +
+	"\ain_sync\x18\x04 \x01(\bR\x06inSync\x12!\n" +
+	"\fprimary_term\x18\x05 \x01(\x03R\vprimaryTerm\"\x94\x01\n" +
 	"\n" +
 	"ShardState\x12\x17\n" +
 	"\x13SHARD_STATE_UNKNOWN\x10\x00\x12\x1c\n" +
 	"\x18SHARD_STATE_INITIALIZING\x10\x01\x12\x17\n" +
 	"\x13SHARD_STATE_STARTED\x10\x02\x12\x1a\n" +
 	"\x16SHARD_STATE_RELOCATING\x10\x03\x12\x1a\n" +
-	"\x16SHARD_STATE_UNASSIGNED\x10\x04\"\xe3\x01\n" +
+	"\x16SHARD_STATE_UNASSIGNED\x10\x04\"\x9a\x02\n" +
 	"\x13RegisterNodeRequest\x12\x17\n" +
 	"\anode_id\x18\x01 \x01(\tR\x06nodeId\x127\n" +
-	"\tnode_type\x18\x02 \x01(\x0e2\x1a.quidditch.master.NodeTypeR\bnodeType\x12\x1b\n" +
+	"\tnode_type\x18\x02 \x01(\x0e2\x1a.conjugate.master.NodeTypeR\bnodeType\x12\x1b\n" +
 	"\tbind_addr\x18\x03 \x01(\tR\bbindAddr\x12\x1b\n" +
 	"\tgrpc_port\x18\x04 \x01(\x05R\bgrpcPort\x12@\n" +
 	"\n" +
-	"attributes\x18\x05 \x01(\v2 .quidditch.master.NodeAttributesR\n" +
-	"attributes\"c\n" +
+	"attributes\x18\x05 \x01(\v2 .conjugate.master.NodeAttributesR\n" +
+	"attributes\x125\n" +
+	"\x06shards\x18\x06 \x03(\v2\x1d.conjugate.master.ShardReportR\x06shards\"c\n" +
 	"\x14RegisterNodeResponse\x12\"\n" +
 	"\facknowledged\x18\x01 \x01(\bR\facknowledged\x12'\n" +
 	"\x0fcluster_version\x18\x02 \x01(\x03R\x0eclusterVersion\"0\n" +
 	"\x15UnregisterNodeRequest\x12\x17\n" +
 	"\anode_id\x18\x01 \x01(\tR\x06nodeId\"<\n" +
 	"\x16UnregisterNodeResponse\x12\"\n" +
-	"\facknowledged\x18\x01 \x01(\bR\facknowledged\"b\n" +
+	"\facknowledged\x18\x01 \x01(\bR\facknowledged\"\x99\x01\n" +
 	"\x14NodeHeartbeatRequest\x12\x17\n" +
 	"\anode_id\x18\x01 \x01(\tR\x06nodeId\x121\n" +
-	"\x05stats\x18\x02 \x01(\v2\x1b.quidditch.master.NodeStatsR\x05stats\"d\n" +
+	"\x05stats\x18\x02 \x01(\v2\x1b.conjugate.master.NodeStatsR\x05stats\x125\n" +
+	"\x06shards\x18\x03 \x03(\v2\x1d.conjugate.master.ShardReportR\x06shards\"d\n" +
 	"\x15NodeHeartbeatResponse\x12\"\n" +
 	"\facknowledged\x18\x01 \x01(\bR\facknowledged\x12'\n" +
 	"\x0fcluster_version\x18\x02 \x01(\x03R\x0eclusterVersion\"\x9d\x03\n" +
 	"\bNodeInfo\x12\x17\n" +
 	"\anode_id\x18\x01 \x01(\tR\x06nodeId\x12\x1b\n" +
 	"\tnode_name\x18\x02 \x01(\tR\bnodeName\x127\n" +
-	"\tnode_type\x18\x03 \x01(\x0e2\x1a.quidditch.master.NodeTypeR\bnodeType\x12\x1b\n" +
+	"\tnode_type\x18\x03 \x01(\x0e2\x1a.conjugate.master.NodeTypeR\bnodeType\x12\x1b\n" +
 	"\tbind_addr\x18\x04 \x01(\tR\bbindAddr\x12\x1b\n" +
 	"\tgrpc_port\x18\x05 \x01(\x05R\bgrpcPort\x12@\n" +
 	"\n" +
-	"attributes\x18\x06 \x01(\v2 .quidditch.master.NodeAttributesR\n" +
+	"attributes\x18\x06 \x01(\v2 .conjugate.master.NodeAttributesR\n" +
 	"attributes\x124\n" +
-	"\x06status\x18\a \x01(\x0e2\x1c.quidditch.master.NodeStatusR\x06status\x127\n" +
+	"\x06status\x18\a \x01(\x0e2\x1c.conjugate.master.NodeStatusR\x06status\x127\n" +
 	"\tjoined_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\bjoinedAt\x127\n" +
 	"\tlast_seen\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\blastSeen\"\x90\x02\n" +
 	"\x0eNodeAttributes\x12!\n" +
@@ -2781,7 +3276,7 @@ const file_pkg_common_proto_master_proto_rawDesc = "" +
 	"max_shards\x18\x02 \x01(\x05R\tmaxShards\x12!\n" +
 	"\fsimd_enabled\x18\x03 \x01(\bR\vsimdEnabled\x12\x18\n" +
 	"\aversion\x18\x04 \x01(\tR\aversion\x12D\n" +
-	"\x06labels\x18\x05 \x03(\v2,.quidditch.master.NodeAttributes.LabelsEntryR\x06labels\x1a9\n" +
+	"\x06labels\x18\x05 \x03(\v2,.conjugate.master.NodeAttributes.LabelsEntryR\x06labels\x1a9\n" +
 	"\vLabelsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
 	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xeb\x02\n" +
@@ -2794,7 +3289,18 @@ const file_pkg_common_proto_master_proto_rawDesc = "" +
 	"\x14memory_usage_percent\x18\x05 \x01(\x01R\x12memoryUsagePercent\x12,\n" +
 	"\x12disk_usage_percent\x18\x06 \x01(\x01R\x10diskUsagePercent\x123\n" +
 	"\x16search_queries_per_sec\x18\a \x01(\x03R\x13searchQueriesPerSec\x121\n" +
-	"\x15indexing_rate_per_sec\x18\b \x01(\x03R\x12indexingRatePerSec\"\x91\x01\n" +
+	"\x15indexing_rate_per_sec\x18\b \x01(\x03R\x12indexingRatePerSec\"\xe8\x01\n" +
+	"\vShardReport\x12\x1d\n" +
+	"\n" +
+	"index_name\x18\x01 \x01(\tR\tindexName\x12\x19\n" +
+	"\bshard_id\x18\x02 \x01(\x05R\ashardId\x12\x1d\n" +
+	"\n" +
+	"is_primary\x18\x03 \x01(\bR\tisPrimary\x12B\n" +
+	"\x05state\x18\x04 \x01(\x0e2,.conjugate.master.ShardAllocation.ShardStateR\x05state\x12\x1d\n" +
+	"\n" +
+	"docs_count\x18\x05 \x01(\x03R\tdocsCount\x12\x1d\n" +
+	"\n" +
+	"size_bytes\x18\x06 \x01(\x03R\tsizeBytes\"\x91\x01\n" +
 	"\n" +
 	"MasterNode\x12\x17\n" +
 	"\anode_id\x18\x01 \x01(\tR\x06nodeId\x12\x1b\n" +
@@ -2819,19 +3325,22 @@ const file_pkg_common_proto_master_proto_rawDesc = "" +
 	"\x13NODE_STATUS_HEALTHY\x10\x01\x12\x18\n" +
 	"\x14NODE_STATUS_DEGRADED\x10\x02\x12\x19\n" +
 	"\x15NODE_STATUS_UNHEALTHY\x10\x03\x12\x17\n" +
-	"\x13NODE_STATUS_OFFLINE\x10\x042\xe0\b\n" +
+	"\x13NODE_STATUS_OFFLINE\x10\x042\xaa\n" +
+	"\n" +
 	"\rMasterService\x12c\n" +
-	"\x0fGetClusterState\x12(.quidditch.master.GetClusterStateRequest\x1a&.quidditch.master.ClusterStateResponse\x12f\n" +
-	"\x11WatchClusterState\x12*.quidditch.master.WatchClusterStateRequest\x1a#.quidditch.master.ClusterStateEvent0\x01\x12Z\n" +
-	"\vCreateIndex\x12$.quidditch.master.CreateIndexRequest\x1a%.quidditch.master.CreateIndexResponse\x12Z\n" +
-	"\vDeleteIndex\x12$.quidditch.master.DeleteIndexRequest\x1a%.quidditch.master.DeleteIndexResponse\x12r\n" +
-	"\x13UpdateIndexSettings\x12,.quidditch.master.UpdateIndexSettingsRequest\x1a-.quidditch.master.UpdateIndexSettingsResponse\x12f\n" +
-	"\x10GetIndexMetadata\x12).quidditch.master.GetIndexMetadataRequest\x1a'.quidditch.master.IndexMetadataResponse\x12`\n" +
-	"\rAllocateShard\x12&.quidditch.master.AllocateShardRequest\x1a'.quidditch.master.AllocateShardResponse\x12f\n" +
-	"\x0fRebalanceShards\x12(.quidditch.master.RebalanceShardsRequest\x1a).quidditch.master.RebalanceShardsResponse\x12]\n" +
-	"\fRegisterNode\x12%.quidditch.master.RegisterNodeRequest\x1a&.quidditch.master.RegisterNodeResponse\x12c\n" +
-	"\x0eUnregisterNode\x12'.quidditch.master.UnregisterNodeRequest\x1a(.quidditch.master.UnregisterNodeResponse\x12`\n" +
-	"\rNodeHeartbeat\x12&.quidditch.master.NodeHeartbeatRequest\x1a'.quidditch.master.NodeHeartbeatResponseB1Z/github.com/conjugate/conjugate/pkg/common/protob\x06proto3"
+	"\x0fGetClusterState\x12(.conjugate.master.GetClusterStateRequest\x1a&.conjugate.master.ClusterStateResponse\x12f\n" +
+	"\x11WatchClusterState\x12*.conjugate.master.WatchClusterStateRequest\x1a#.conjugate.master.ClusterStateEvent0\x01\x12Z\n" +
+	"\vCreateIndex\x12$.conjugate.master.CreateIndexRequest\x1a%.conjugate.master.CreateIndexResponse\x12Z\n" +
+	"\vDeleteIndex\x12$.conjugate.master.DeleteIndexRequest\x1a%.conjugate.master.DeleteIndexResponse\x12r\n" +
+	"\x13UpdateIndexSettings\x12,.conjugate.master.UpdateIndexSettingsRequest\x1a-.conjugate.master.UpdateIndexSettingsResponse\x12f\n" +
+	"\x10GetIndexMetadata\x12).conjugate.master.GetIndexMetadataRequest\x1a'.conjugate.master.IndexMetadataResponse\x12`\n" +
+	"\rAllocateShard\x12&.conjugate.master.AllocateShardRequest\x1a'.conjugate.master.AllocateShardResponse\x12f\n" +
+	"\x0fRebalanceShards\x12(.conjugate.master.RebalanceShardsRequest\x1a).conjugate.master.RebalanceShardsResponse\x12T\n" +
+	"\tFailShard\x12\".conjugate.master.FailShardRequest\x1a#.conjugate.master.FailShardResponse\x12r\n" +
+	"\x13ReportShardRecovery\x12,.conjugate.master.ReportShardRecoveryRequest\x1a-.conjugate.master.ReportShardRecoveryResponse\x12]\n" +
+	"\fRegisterNode\x12%.conjugate.master.RegisterNodeRequest\x1a&.conjugate.master.RegisterNodeResponse\x12c\n" +
+	"\x0eUnregisterNode\x12'.conjugate.master.UnregisterNodeRequest\x1a(.conjugate.master.UnregisterNodeResponse\x12`\n" +
+	"\rNodeHeartbeat\x12&.conjugate.master.NodeHeartbeatRequest\x1a'.conjugate.master.NodeHeartbeatResponseB1Z/github.com/conjugate/conjugate/pkg/common/protob\x06proto3"
 
 var (
 	file_pkg_common_proto_master_proto_rawDescOnce sync.Once
@@ -2846,131 +3355,144 @@ func file_pkg_common_proto_master_proto_rawDescGZIP() []byte {
 }
 
 var file_pkg_common_proto_master_proto_enumTypes = make([]protoimpl.EnumInfo, 6)
-var file_pkg_common_proto_master_proto_msgTypes = make([]protoimpl.MessageInfo, 45)
+var file_pkg_common_proto_master_proto_msgTypes = make([]protoimpl.MessageInfo, 50)
 var file_pkg_common_proto_master_proto_goTypes = []any{
-	(ClusterStatus)(0),                  // 0: quidditch.master.ClusterStatus
-	(NodeType)(0),                       // 1: quidditch.master.NodeType
-	(NodeStatus)(0),                     // 2: quidditch.master.NodeStatus
-	(ClusterStateEvent_EventType)(0),    // 3: quidditch.master.ClusterStateEvent.EventType
-	(IndexMetadata_IndexState)(0),       // 4: quidditch.master.IndexMetadata.IndexState
-	(ShardAllocation_ShardState)(0),     // 5: quidditch.master.ShardAllocation.ShardState
-	(*GetClusterStateRequest)(nil),      // 6: quidditch.master.GetClusterStateRequest
-	(*ClusterStateResponse)(nil),        // 7: quidditch.master.ClusterStateResponse
-	(*WatchClusterStateRequest)(nil),    // 8: quidditch.master.WatchClusterStateRequest
-	(*ClusterStateEvent)(nil),           // 9: quidditch.master.ClusterStateEvent
-	(*CreateIndexRequest)(nil),          // 10: quidditch.master.CreateIndexRequest
-	(*CreateIndexResponse)(nil),         // 11: quidditch.master.CreateIndexResponse
-	(*DeleteIndexRequest)(nil),          // 12: quidditch.master.DeleteIndexRequest
-	(*DeleteIndexResponse)(nil),         // 13: quidditch.master.DeleteIndexResponse
-	(*UpdateIndexSettingsRequest)(nil),  // 14: quidditch.master.UpdateIndexSettingsRequest
-	(*UpdateIndexSettingsResponse)(nil), // 15: quidditch.master.UpdateIndexSettingsResponse
-	(*GetIndexMetadataRequest)(nil),     // 16: quidditch.master.GetIndexMetadataRequest
-	(*IndexMetadataResponse)(nil),       // 17: quidditch.master.IndexMetadataResponse
-	(*IndexMetadata)(nil),               // 18: quidditch.master.IndexMetadata
-	(*IndexSettings)(nil),               // 19: quidditch.master.IndexSettings
-	(*CompressionSettings)(nil),         // 20: quidditch.master.CompressionSettings
-	(*TieringSettings)(nil),             // 21: quidditch.master.TieringSettings
-	(*FieldMapping)(nil),                // 22: quidditch.master.FieldMapping
-	(*AllocateShardRequest)(nil),        // 23: quidditch.master.AllocateShardRequest
-	(*AllocateShardResponse)(nil),       // 24: quidditch.master.AllocateShardResponse
-	(*RebalanceShardsRequest)(nil),      // 25: quidditch.master.RebalanceShardsRequest
-	(*RebalanceShardsResponse)(nil),     // 26: quidditch.master.RebalanceShardsResponse
-	(*ShardRelocation)(nil),             // 27: quidditch.master.ShardRelocation
-	(*RoutingTable)(nil),                // 28: quidditch.master.RoutingTable
-	(*IndexRoutingTable)(nil),           // 29: quidditch.master.IndexRoutingTable
-	(*ShardRouting)(nil),                // 30: quidditch.master.ShardRouting
-	(*ShardAllocation)(nil),             // 31: quidditch.master.ShardAllocation
-	(*RegisterNodeRequest)(nil),         // 32: quidditch.master.RegisterNodeRequest
-	(*RegisterNodeResponse)(nil),        // 33: quidditch.master.RegisterNodeResponse
-	(*UnregisterNodeRequest)(nil),       // 34: quidditch.master.UnregisterNodeRequest
-	(*UnregisterNodeResponse)(nil),      // 35: quidditch.master.UnregisterNodeResponse
-	(*NodeHeartbeatRequest)(nil),        // 36: quidditch.master.NodeHeartbeatRequest
-	(*NodeHeartbeatResponse)(nil),       // 37: quidditch.master.NodeHeartbeatResponse
-	(*NodeInfo)(nil),                    // 38: quidditch.master.NodeInfo
-	(*NodeAttributes)(nil),              // 39: quidditch.master.NodeAttributes
-	(*NodeStats)(nil),                   // 40: quidditch.master.NodeStats
-	(*MasterNode)(nil),                  // 41: quidditch.master.MasterNode
-	nil,                                 // 42: quidditch.master.CreateIndexRequest.MappingsEntry
-	nil,                                 // 43: quidditch.master.CreateIndexRequest.AliasesEntry
-	nil,                                 // 44: quidditch.master.IndexMetadata.MappingsEntry
-	nil,                                 // 45: quidditch.master.IndexMetadata.AliasesEntry
-	nil,                                 // 46: quidditch.master.TieringSettings.TierRulesEntry
-	nil,                                 // 47: quidditch.master.FieldMapping.PropertiesEntry
-	nil,                                 // 48: quidditch.master.RoutingTable.IndicesEntry
-	nil,                                 // 49: quidditch.master.IndexRoutingTable.ShardsEntry
-	nil,                                 // 50: quidditch.master.NodeAttributes.LabelsEntry
-	(*timestamppb.Timestamp)(nil),       // 51: google.protobuf.Timestamp
+	(ClusterStatus)(0),                  // 0: conjugate.master.ClusterStatus
+	(NodeType)(0),                       // 1: conjugate.master.NodeType
+	(NodeStatus)(0),                     // 2: conjugate.master.NodeStatus
+	(ClusterStateEvent_EventType)(0),    // 3: conjugate.master.ClusterStateEvent.EventType
+	(IndexMetadata_IndexState)(0),       // 4: conjugate.master.IndexMetadata.IndexState
+	(ShardAllocation_ShardState)(0),     // 5: conjugate.master.ShardAllocation.ShardState
+	(*GetClusterStateRequest)(nil),      // 6: conjugate.master.GetClusterStateRequest
+	(*ClusterStateResponse)(nil),        // 7: conjugate.master.ClusterStateResponse
+	(*WatchClusterStateRequest)(nil),    // 8: conjugate.master.WatchClusterStateRequest
+	(*ClusterStateEvent)(nil),           // 9: conjugate.master.ClusterStateEvent
+	(*CreateIndexRequest)(nil),          // 10: conjugate.master.CreateIndexRequest
+	(*CreateIndexResponse)(nil),         // 11: conjugate.master.CreateIndexResponse
+	(*DeleteIndexRequest)(nil),          // 12: conjugate.master.DeleteIndexRequest
+	(*DeleteIndexResponse)(nil),         // 13: conjugate.master.DeleteIndexResponse
+	(*UpdateIndexSettingsRequest)(nil),  // 14: conjugate.master.UpdateIndexSettingsRequest
+	(*UpdateIndexSettingsResponse)(nil), // 15: conjugate.master.UpdateIndexSettingsResponse
+	(*GetIndexMetadataRequest)(nil),     // 16: conjugate.master.GetIndexMetadataRequest
+	(*IndexMetadataResponse)(nil),       // 17: conjugate.master.IndexMetadataResponse
+	(*IndexMetadata)(nil),               // 18: conjugate.master.IndexMetadata
+	(*IndexSettings)(nil),               // 19: conjugate.master.IndexSettings
+	(*CompressionSettings)(nil),         // 20: conjugate.master.CompressionSettings
+	(*TieringSettings)(nil),             // 21: conjugate.master.TieringSettings
+	(*FieldMapping)(nil),                // 22: conjugate.master.FieldMapping
+	(*AllocateShardRequest)(nil),        // 23: conjugate.master.AllocateShardRequest
+	(*AllocateShardResponse)(nil),       // 24: conjugate.master.AllocateShardResponse
+	(*RebalanceShardsRequest)(nil),      // 25: conjugate.master.RebalanceShardsRequest
+	(*RebalanceShardsResponse)(nil),     // 26: conjugate.master.RebalanceShardsResponse
+	(*ShardRelocation)(nil),             // 27: conjugate.master.ShardRelocation
+	(*FailShardRequest)(nil),            // 28: conjugate.master.FailShardRequest
+	(*FailShardResponse)(nil),           // 29: conjugate.master.FailShardResponse
+	(*ReportShardRecoveryRequest)(nil),  // 30: conjugate.master.ReportShardRecoveryRequest
+	(*ReportShardRecoveryResponse)(nil), // 31: conjugate.master.ReportShardRecoveryResponse
+	(*RoutingTable)(nil),                // 32: conjugate.master.RoutingTable
+	(*IndexRoutingTable)(nil),           // 33: conjugate.master.IndexRoutingTable
+	(*ShardRouting)(nil),                // 34: conjugate.master.ShardRouting
+	(*ShardAllocation)(nil),             // 35: conjugate.master.ShardAllocation
+	(*RegisterNodeRequest)(nil),         // 36: conjugate.master.RegisterNodeRequest
+	(*RegisterNodeResponse)(nil),        // 37: conjugate.master.RegisterNodeResponse
+	(*UnregisterNodeRequest)(nil),       // 38: conjugate.master.UnregisterNodeRequest
+	(*UnregisterNodeResponse)(nil),      // 39: conjugate.master.UnregisterNodeResponse
+	(*NodeHeartbeatRequest)(nil),        // 40: conjugate.master.NodeHeartbeatRequest
+	(*NodeHeartbeatResponse)(nil),       // 41: conjugate.master.NodeHeartbeatResponse
+	(*NodeInfo)(nil),                    // 42: conjugate.master.NodeInfo
+	(*NodeAttributes)(nil),              // 43: conjugate.master.NodeAttributes
+	(*NodeStats)(nil),                   // 44: conjugate.master.NodeStats
+	(*ShardReport)(nil),                 // 45: conjugate.master.ShardReport
+	(*MasterNode)(nil),                  // 46: conjugate.master.MasterNode
+	nil,                                 // 47: conjugate.master.CreateIndexRequest.MappingsEntry
+	nil,                                 // 48: conjugate.master.CreateIndexRequest.AliasesEntry
+	nil,                                 // 49: conjugate.master.IndexMetadata.MappingsEntry
+	nil,                                 // 50: conjugate.master.IndexMetadata.AliasesEntry
+	nil,                                 // 51: conjugate.master.TieringSettings.TierRulesEntry
+	nil,                                 // 52: conjugate.master.FieldMapping.PropertiesEntry
+	nil,                                 // 53: conjugate.master.RoutingTable.IndicesEntry
+	nil,                                 // 54: conjugate.master.IndexRoutingTable.ShardsEntry
+	nil,                                 // 55: conjugate.master.NodeAttributes.LabelsEntry
+	(*timestamppb.Timestamp)(nil),       // 56: google.protobuf.Timestamp
 }
 var file_pkg_common_proto_master_proto_depIdxs = []int32{
-	0,  // 0: quidditch.master.ClusterStateResponse.status:type_name -> quidditch.master.ClusterStatus
-	18, // 1: quidditch.master.ClusterStateResponse.indices:type_name -> quidditch.master.IndexMetadata
-	28, // 2: quidditch.master.ClusterStateResponse.routing_table:type_name -> quidditch.master.RoutingTable
-	38, // 3: quidditch.master.ClusterStateResponse.nodes:type_name -> quidditch.master.NodeInfo
-	41, // 4: quidditch.master.ClusterStateResponse.master_node:type_name -> quidditch.master.MasterNode
-	3,  // 5: quidditch.master.ClusterStateEvent.type:type_name -> quidditch.master.ClusterStateEvent.EventType
-	19, // 6: quidditch.master.CreateIndexRequest.settings:type_name -> quidditch.master.IndexSettings
-	42, // 7: quidditch.master.CreateIndexRequest.mappings:type_name -> quidditch.master.CreateIndexRequest.MappingsEntry
-	43, // 8: quidditch.master.CreateIndexRequest.aliases:type_name -> quidditch.master.CreateIndexRequest.AliasesEntry
-	19, // 9: quidditch.master.UpdateIndexSettingsRequest.settings:type_name -> quidditch.master.IndexSettings
-	18, // 10: quidditch.master.IndexMetadataResponse.metadata:type_name -> quidditch.master.IndexMetadata
-	19, // 11: quidditch.master.IndexMetadata.settings:type_name -> quidditch.master.IndexSettings
-	44, // 12: quidditch.master.IndexMetadata.mappings:type_name -> quidditch.master.IndexMetadata.MappingsEntry
-	45, // 13: quidditch.master.IndexMetadata.aliases:type_name -> quidditch.master.IndexMetadata.AliasesEntry
-	4,  // 14: quidditch.master.IndexMetadata.state:type_name -> quidditch.master.IndexMetadata.IndexState
-	51, // 15: quidditch.master.IndexMetadata.created_at:type_name -> google.protobuf.Timestamp
-	20, // 16: quidditch.master.IndexSettings.compression:type_name -> quidditch.master.CompressionSettings
-	21, // 17: quidditch.master.IndexSettings.tiering:type_name -> quidditch.master.TieringSettings
-	46, // 18: quidditch.master.TieringSettings.tier_rules:type_name -> quidditch.master.TieringSettings.TierRulesEntry
-	47, // 19: quidditch.master.FieldMapping.properties:type_name -> quidditch.master.FieldMapping.PropertiesEntry
-	31, // 20: quidditch.master.AllocateShardResponse.allocation:type_name -> quidditch.master.ShardAllocation
-	27, // 21: quidditch.master.RebalanceShardsResponse.relocations:type_name -> quidditch.master.ShardRelocation
-	48, // 22: quidditch.master.RoutingTable.indices:type_name -> quidditch.master.RoutingTable.IndicesEntry
-	49, // 23: quidditch.master.IndexRoutingTable.shards:type_name -> quidditch.master.IndexRoutingTable.ShardsEntry
-	31, // 24: quidditch.master.ShardRouting.allocation:type_name -> quidditch.master.ShardAllocation
-	5,  // 25: quidditch.master.ShardAllocation.state:type_name -> quidditch.master.ShardAllocation.ShardState
-	51, // 26: quidditch.master.ShardAllocation.allocated_at:type_name -> google.protobuf.Timestamp
-	1,  // 27: quidditch.master.RegisterNodeRequest.node_type:type_name -> quidditch.master.NodeType
-	39, // 28: quidditch.master.RegisterNodeRequest.attributes:type_name -> quidditch.master.NodeAttributes
-	40, // 29: quidditch.master.NodeHeartbeatRequest.stats:type_name -> quidditch.master.NodeStats
-	1,  // 30: quidditch.master.NodeInfo.node_type:type_name -> quidditch.master.NodeType
-	39, // 31: quidditch.master.NodeInfo.attributes:type_name -> quidditch.master.NodeAttributes
-	2,  // 32: quidditch.master.NodeInfo.status:type_name -> quidditch.master.NodeStatus
-	51, // 33: quidditch.master.NodeInfo.joined_at:type_name -> google.protobuf.Timestamp
-	51, // 34: quidditch.master.NodeInfo.last_seen:type_name -> google.protobuf.Timestamp
-	50, // 35: quidditch.master.NodeAttributes.labels:type_name -> quidditch.master.NodeAttributes.LabelsEntry
-	51, // 36: quidditch.master.MasterNode.elected_at:type_name -> google.protobuf.Timestamp
-	22, // 37: quidditch.master.CreateIndexRequest.MappingsEntry.value:type_name -> quidditch.master.FieldMapping
-	22, // 38: quidditch.master.IndexMetadata.MappingsEntry.value:type_name -> quidditch.master.FieldMapping
-	22, // 39: quidditch.master.FieldMapping.PropertiesEntry.value:type_name -> quidditch.master.FieldMapping
-	29, // 40: quidditch.master.RoutingTable.IndicesEntry.value:type_name -> quidditch.master.IndexRoutingTable
-	30, // 41: quidditch.master.IndexRoutingTable.ShardsEntry.value:type_name -> quidditch.master.ShardRouting
-	6,  // 42: quidditch.master.MasterService.GetClusterState:input_type -> quidditch.master.GetClusterStateRequest
-	8,  // 43: quidditch.master.MasterService.WatchClusterState:input_type -> quidditch.master.WatchClusterStateRequest
-	10, // 44: quidditch.master.MasterService.CreateIndex:input_type -> quidditch.master.CreateIndexRequest
-	12, // 45: quidditch.master.MasterService.DeleteIndex:input_type -> quidditch.master.DeleteIndexRequest
-	14, // 46: quidditch.master.MasterService.UpdateIndexSettings:input_type -> quidditch.master.UpdateIndexSettingsRequest
-	16, // 47: quidditch.master.MasterService.GetIndexMetadata:input_type -> quidditch.master.GetIndexMetadataRequest
-	23, // 48: quidditch.master.MasterService.AllocateShard:input_type -> quidditch.master.AllocateShardRequest
-	25, // 49: quidditch.master.MasterService.RebalanceShards:input_type -> quidditch.master.RebalanceShardsRequest
-	32, // 50: quidditch.master.MasterService.RegisterNode:input_type -> quidditch.master.RegisterNodeRequest
-	34, // 51: quidditch.master.MasterService.UnregisterNode:input_type -> quidditch.master.UnregisterNodeRequest
-	36, // 52: quidditch.master.MasterService.NodeHeartbeat:input_type -> quidditch.master.NodeHeartbeatRequest
-	7,  // 53: quidditch.master.MasterService.GetClusterState:output_type -> quidditch.master.ClusterStateResponse
-	9,  // 54: quidditch.master.MasterService.WatchClusterState:output_type -> quidditch.master.ClusterStateEvent
-	11, // 55: quidditch.master.MasterService.CreateIndex:output_type -> quidditch.master.CreateIndexResponse
-	13, // 56: quidditch.master.MasterService.DeleteIndex:output_type -> quidditch.master.DeleteIndexResponse
-	15, // 57: quidditch.master.MasterService.UpdateIndexSettings:output_type -> quidditch.master.UpdateIndexSettingsResponse
-	17, // 58: quidditch.master.MasterService.GetIndexMetadata:output_type -> quidditch.master.IndexMetadataResponse
-	24, // 59: quidditch.master.MasterService.AllocateShard:output_type -> quidditch.master.AllocateShardResponse
-	26, // 60: quidditch.master.MasterService.RebalanceShards:output_type -> quidditch.master.RebalanceShardsResponse
-	33, // 61: quidditch.master.MasterService.RegisterNode:output_type -> quidditch.master.RegisterNodeResponse
-	35, // 62: quidditch.master.MasterService.UnregisterNode:output_type -> quidditch.master.UnregisterNodeResponse
-	37, // 63: quidditch.master.MasterService.NodeHeartbeat:output_type -> quidditch.master.NodeHeartbeatResponse
-	53, // [53:64] is the sub-list for method output_type
-	42, // [42:53] is the sub-list for method input_type
-	42, // [42:42] is the sub-list for extension type_name
-	42, // [42:42] is the sub-list for extension extendee
-	0,  // [0:42] is the sub-list for field type_name
+	0,  // 0: conjugate.master.ClusterStateResponse.status:type_name -> conjugate.master.ClusterStatus
+	18, // 1: conjugate.master.ClusterStateResponse.indices:type_name -> conjugate.master.IndexMetadata
+	32, // 2: conjugate.master.ClusterStateResponse.routing_table:type_name -> conjugate.master.RoutingTable
+	42, // 3: conjugate.master.ClusterStateResponse.nodes:type_name -> conjugate.master.NodeInfo
+	46, // 4: conjugate.master.ClusterStateResponse.master_node:type_name -> conjugate.master.MasterNode
+	3,  // 5: conjugate.master.ClusterStateEvent.type:type_name -> conjugate.master.ClusterStateEvent.EventType
+	19, // 6: conjugate.master.CreateIndexRequest.settings:type_name -> conjugate.master.IndexSettings
+	47, // 7: conjugate.master.CreateIndexRequest.mappings:type_name -> conjugate.master.CreateIndexRequest.MappingsEntry
+	48, // 8: conjugate.master.CreateIndexRequest.aliases:type_name -> conjugate.master.CreateIndexRequest.AliasesEntry
+	19, // 9: conjugate.master.UpdateIndexSettingsRequest.settings:type_name -> conjugate.master.IndexSettings
+	18, // 10: conjugate.master.IndexMetadataResponse.metadata:type_name -> conjugate.master.IndexMetadata
+	19, // 11: conjugate.master.IndexMetadata.settings:type_name -> conjugate.master.IndexSettings
+	49, // 12: conjugate.master.IndexMetadata.mappings:type_name -> conjugate.master.IndexMetadata.MappingsEntry
+	50, // 13: conjugate.master.IndexMetadata.aliases:type_name -> conjugate.master.IndexMetadata.AliasesEntry
+	4,  // 14: conjugate.master.IndexMetadata.state:type_name -> conjugate.master.IndexMetadata.IndexState
+	56, // 15: conjugate.master.IndexMetadata.created_at:type_name -> google.protobuf.Timestamp
+	20, // 16: conjugate.master.IndexSettings.compression:type_name -> conjugate.master.CompressionSettings
+	21, // 17: conjugate.master.IndexSettings.tiering:type_name -> conjugate.master.TieringSettings
+	51, // 18: conjugate.master.TieringSettings.tier_rules:type_name -> conjugate.master.TieringSettings.TierRulesEntry
+	52, // 19: conjugate.master.FieldMapping.properties:type_name -> conjugate.master.FieldMapping.PropertiesEntry
+	35, // 20: conjugate.master.AllocateShardResponse.allocation:type_name -> conjugate.master.ShardAllocation
+	27, // 21: conjugate.master.RebalanceShardsResponse.relocations:type_name -> conjugate.master.ShardRelocation
+	53, // 22: conjugate.master.RoutingTable.indices:type_name -> conjugate.master.RoutingTable.IndicesEntry
+	54, // 23: conjugate.master.IndexRoutingTable.shards:type_name -> conjugate.master.IndexRoutingTable.ShardsEntry
+	35, // 24: conjugate.master.ShardRouting.allocation:type_name -> conjugate.master.ShardAllocation
+	35, // 25: conjugate.master.ShardRouting.replicas:type_name -> conjugate.master.ShardAllocation
+	5,  // 26: conjugate.master.ShardAllocation.state:type_name -> conjugate.master.ShardAllocation.ShardState
+	56, // 27: conjugate.master.ShardAllocation.allocated_at:type_name -> google.protobuf.Timestamp
+	1,  // 28: conjugate.master.RegisterNodeRequest.node_type:type_name -> conjugate.master.NodeType
+	43, // 29: conjugate.master.RegisterNodeRequest.attributes:type_name -> conjugate.master.NodeAttributes
+	45, // 30: conjugate.master.RegisterNodeRequest.shards:type_name -> conjugate.master.ShardReport
+	44, // 31: conjugate.master.NodeHeartbeatRequest.stats:type_name -> conjugate.master.NodeStats
+	45, // 32: conjugate.master.NodeHeartbeatRequest.shards:type_name -> conjugate.master.ShardReport
+	1,  // 33: conjugate.master.NodeInfo.node_type:type_name -> conjugate.master.NodeType
+	43, // 34: conjugate.master.NodeInfo.attributes:type_name -> conjugate.master.NodeAttributes
+	2,  // 35: conjugate.master.NodeInfo.status:type_name -> conjugate.master.NodeStatus
+	56, // 36: conjugate.master.NodeInfo.joined_at:type_name -> google.protobuf.Timestamp
+	56, // 37: conjugate.master.NodeInfo.last_seen:type_name -> google.protobuf.Timestamp
+	55, // 38: conjugate.master.NodeAttributes.labels:type_name -> conjugate.master.NodeAttributes.LabelsEntry
+	5,  // 39: conjugate.master.ShardReport.state:type_name -> conjugate.master.ShardAllocation.ShardState
+	56, // 40: conjugate.master.MasterNode.elected_at:type_name -> google.protobuf.Timestamp
+	22, // 41: conjugate.master.CreateIndexRequest.MappingsEntry.value:type_name -> conjugate.master.FieldMapping
+	22, // 42: conjugate.master.IndexMetadata.MappingsEntry.value:type_name -> conjugate.master.FieldMapping
+	22, // 43: conjugate.master.FieldMapping.PropertiesEntry.value:type_name -> conjugate.master.FieldMapping
+	33, // 44: conjugate.master.RoutingTable.IndicesEntry.value:type_name -> conjugate.master.IndexRoutingTable
+	34, // 45: conjugate.master.IndexRoutingTable.ShardsEntry.value:type_name -> conjugate.master.ShardRouting
+	6,  // 46: conjugate.master.MasterService.GetClusterState:input_type -> conjugate.master.GetClusterStateRequest
+	8,  // 47: conjugate.master.MasterService.WatchClusterState:input_type -> conjugate.master.WatchClusterStateRequest
+	10, // 48: conjugate.master.MasterService.CreateIndex:input_type -> conjugate.master.CreateIndexRequest
+	12, // 49: conjugate.master.MasterService.DeleteIndex:input_type -> conjugate.master.DeleteIndexRequest
+	14, // 50: conjugate.master.MasterService.UpdateIndexSettings:input_type -> conjugate.master.UpdateIndexSettingsRequest
+	16, // 51: conjugate.master.MasterService.GetIndexMetadata:input_type -> conjugate.master.GetIndexMetadataRequest
+	23, // 52: conjugate.master.MasterService.AllocateShard:input_type -> conjugate.master.AllocateShardRequest
+	25, // 53: conjugate.master.MasterService.RebalanceShards:input_type -> conjugate.master.RebalanceShardsRequest
+	28, // 54: conjugate.master.MasterService.FailShard:input_type -> conjugate.master.FailShardRequest
+	30, // 55: conjugate.master.MasterService.ReportShardRecovery:input_type -> conjugate.master.ReportShardRecoveryRequest
+	36, // 56: conjugate.master.MasterService.RegisterNode:input_type -> conjugate.master.RegisterNodeRequest
+	38, // 57: conjugate.master.MasterService.UnregisterNode:input_type -> conjugate.master.UnregisterNodeRequest
+	40, // 58: conjugate.master.MasterService.NodeHeartbeat:input_type -> conjugate.master.NodeHeartbeatRequest
+	7,  // 59: conjugate.master.MasterService.GetClusterState:output_type -> conjugate.master.ClusterStateResponse
+	9,  // 60: conjugate.master.MasterService.WatchClusterState:output_type -> conjugate.master.ClusterStateEvent
+	11, // 61: conjugate.master.MasterService.CreateIndex:output_type -> conjugate.master.CreateIndexResponse
+	13, // 62: conjugate.master.MasterService.DeleteIndex:output_type -> conjugate.master.DeleteIndexResponse
+	15, // 63: conjugate.master.MasterService.UpdateIndexSettings:output_type -> conjugate.master.UpdateIndexSettingsResponse
+	17, // 64: conjugate.master.MasterService.GetIndexMetadata:output_type -> conjugate.master.IndexMetadataResponse
+	24, // 65: conjugate.master.MasterService.AllocateShard:output_type -> conjugate.master.AllocateShardResponse
+	26, // 66: conjugate.master.MasterService.RebalanceShards:output_type -> conjugate.master.RebalanceShardsResponse
+	29, // 67: conjugate.master.MasterService.FailShard:output_type -> conjugate.master.FailShardResponse
+	31, // 68: conjugate.master.MasterService.ReportShardRecovery:output_type -> conjugate.master.ReportShardRecoveryResponse
+	37, // 69: conjugate.master.MasterService.RegisterNode:output_type -> conjugate.master.RegisterNodeResponse
+	39, // 70: conjugate.master.MasterService.UnregisterNode:output_type -> conjugate.master.UnregisterNodeResponse
+	41, // 71: conjugate.master.MasterService.NodeHeartbeat:output_type -> conjugate.master.NodeHeartbeatResponse
+	59, // [59:72] is the sub-list for method output_type
+	46, // [46:59] is the sub-list for method input_type
+	46, // [46:46] is the sub-list for extension type_name
+	46, // [46:46] is the sub-list for extension extendee
+	0,  // [0:46] is the sub-list for field type_name
 }
 
 func init() { file_pkg_common_proto_master_proto_init() }
@@ -2984,7 +3506,7 @@ func file_pkg_common_proto_master_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pkg_common_proto_master_proto_rawDesc), len(file_pkg_common_proto_master_proto_rawDesc)),
 			NumEnums:      6,
-			NumMessages:   45,
+			NumMessages:   50,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
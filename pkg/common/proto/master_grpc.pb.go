@@ -27,6 +27,8 @@ const (
 	MasterService_GetIndexMetadata_FullMethodName    = "/conjugate.master.MasterService/GetIndexMetadata"
 	MasterService_AllocateShard_FullMethodName       = "/conjugate.master.MasterService/AllocateShard"
 	MasterService_RebalanceShards_FullMethodName     = "/conjugate.master.MasterService/RebalanceShards"
+	MasterService_FailShard_FullMethodName           = "/conjugate.master.MasterService/FailShard"
+	MasterService_ReportShardRecovery_FullMethodName = "/conjugate.master.MasterService/ReportShardRecovery"
 	MasterService_RegisterNode_FullMethodName        = "/conjugate.master.MasterService/RegisterNode"
 	MasterService_UnregisterNode_FullMethodName      = "/conjugate.master.MasterService/UnregisterNode"
 	MasterService_NodeHeartbeat_FullMethodName       = "/conjugate.master.MasterService/NodeHeartbeat"
@@ -49,6 +51,8 @@ type MasterServiceClient interface {
 	// Shard allocation
 	AllocateShard(ctx context.Context, in *AllocateShardRequest, opts ...grpc.CallOption) (*AllocateShardResponse, error)
 	RebalanceShards(ctx context.Context, in *RebalanceShardsRequest, opts ...grpc.CallOption) (*RebalanceShardsResponse, error)
+	FailShard(ctx context.Context, in *FailShardRequest, opts ...grpc.CallOption) (*FailShardResponse, error)
+	ReportShardRecovery(ctx context.Context, in *ReportShardRecoveryRequest, opts ...grpc.CallOption) (*ReportShardRecoveryResponse, error)
 	// Node registration
 	RegisterNode(ctx context.Context, in *RegisterNodeRequest, opts ...grpc.CallOption) (*RegisterNodeResponse, error)
 	UnregisterNode(ctx context.Context, in *UnregisterNodeRequest, opts ...grpc.CallOption) (*UnregisterNodeResponse, error)
@@ -152,6 +156,26 @@ func (c *masterServiceClient) RebalanceShards(ctx context.Context, in *Rebalance
 	return out, nil
 }
 
+func (c *masterServiceClient) FailShard(ctx context.Context, in *FailShardRequest, opts ...grpc.CallOption) (*FailShardResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FailShardResponse)
+	err := c.cc.Invoke(ctx, MasterService_FailShard_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *masterServiceClient) ReportShardRecovery(ctx context.Context, in *ReportShardRecoveryRequest, opts ...grpc.CallOption) (*ReportShardRecoveryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReportShardRecoveryResponse)
+	err := c.cc.Invoke(ctx, MasterService_ReportShardRecovery_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *masterServiceClient) RegisterNode(ctx context.Context, in *RegisterNodeRequest, opts ...grpc.CallOption) (*RegisterNodeResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(RegisterNodeResponse)
@@ -199,6 +223,8 @@ type MasterServiceServer interface {
 	// Shard allocation
 	AllocateShard(context.Context, *AllocateShardRequest) (*AllocateShardResponse, error)
 	RebalanceShards(context.Context, *RebalanceShardsRequest) (*RebalanceShardsResponse, error)
+	FailShard(context.Context, *FailShardRequest) (*FailShardResponse, error)
+	ReportShardRecovery(context.Context, *ReportShardRecoveryRequest) (*ReportShardRecoveryResponse, error)
 	// Node registration
 	RegisterNode(context.Context, *RegisterNodeRequest) (*RegisterNodeResponse, error)
 	UnregisterNode(context.Context, *UnregisterNodeRequest) (*UnregisterNodeResponse, error)
@@ -237,6 +263,12 @@ func (UnimplementedMasterServiceServer) AllocateShard(context.Context, *Allocate
 func (UnimplementedMasterServiceServer) RebalanceShards(context.Context, *RebalanceShardsRequest) (*RebalanceShardsResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method RebalanceShards not implemented")
 }
+func (UnimplementedMasterServiceServer) FailShard(context.Context, *FailShardRequest) (*FailShardResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method FailShard not implemented")
+}
+func (UnimplementedMasterServiceServer) ReportShardRecovery(context.Context, *ReportShardRecoveryRequest) (*ReportShardRecoveryResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ReportShardRecovery not implemented")
+}
 func (UnimplementedMasterServiceServer) RegisterNode(context.Context, *RegisterNodeRequest) (*RegisterNodeResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method RegisterNode not implemented")
 }
@@ -404,6 +436,42 @@ func _MasterService_RebalanceShards_Handler(srv interface{}, ctx context.Context
 	return interceptor(ctx, in, info, handler)
 }
 
+func _MasterService_FailShard_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FailShardRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MasterServiceServer).FailShard(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MasterService_FailShard_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MasterServiceServer).FailShard(ctx, req.(*FailShardRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MasterService_ReportShardRecovery_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReportShardRecoveryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MasterServiceServer).ReportShardRecovery(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MasterService_ReportShardRecovery_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MasterServiceServer).ReportShardRecovery(ctx, req.(*ReportShardRecoveryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _MasterService_RegisterNode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(RegisterNodeRequest)
 	if err := dec(in); err != nil {
@@ -462,7 +530,7 @@ func _MasterService_NodeHeartbeat_Handler(srv interface{}, ctx context.Context,
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
 var MasterService_ServiceDesc = grpc.ServiceDesc{
-	ServiceName: "quidditch.master.MasterService",
+	ServiceName: "conjugate.master.MasterService",
 	HandlerType: (*MasterServiceServer)(nil),
 	Methods: []grpc.MethodDesc{
 		{
@@ -493,6 +561,14 @@ var MasterService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "RebalanceShards",
 			Handler:    _MasterService_RebalanceShards_Handler,
 		},
+		{
+			MethodName: "FailShard",
+			Handler:    _MasterService_FailShard_Handler,
+		},
+		{
+			MethodName: "ReportShardRecovery",
+			Handler:    _MasterService_ReportShardRecovery_Handler,
+		},
 		{
 			MethodName: "RegisterNode",
 			Handler:    _MasterService_RegisterNode_Handler,
@@ -0,0 +1,279 @@
+package sketch
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// Precision bounds of a HyperLogLog sketch, as in OpenSearch
+const (
+	MinPrecision = 4
+	MaxPrecision = 18
+)
+
+// DefaultPrecisionThreshold is the count below which a cardinality is exact
+// unless the request sets one, and MaxPrecisionThreshold the largest
+// threshold honored, as in OpenSearch
+const (
+	DefaultPrecisionThreshold = 3000
+	MaxPrecisionThreshold     = 40000
+)
+
+// hllVersion identifies the serialized HyperLogLog layout
+const hllVersion = 1
+
+// Representations of a serialized HyperLogLog
+const (
+	hllSparse byte = 0
+	hllDense  byte = 1
+)
+
+// HyperLogLog counts distinct values approximately (HyperLogLog++). Values
+// are hashed to 64 bits. Small sets keep their hashes and count exactly;
+// past a limit set by the precision they switch to 2^precision registers,
+// estimated with Ertl's improved estimator, which needs none of HyperLogLog++'s
+// empirical bias tables. Sketches of the same precision merge into a sketch
+// of the union of their values.
+type HyperLogLog struct {
+	precision uint8
+	sparse    map[uint64]struct{} // nil once dense
+	registers []uint8
+}
+
+// PrecisionFromThreshold returns the precision whose sketch counts exactly up
+// to about threshold distinct values
+func PrecisionFromThreshold(threshold int) uint8 {
+	if threshold > MaxPrecisionThreshold {
+		threshold = MaxPrecisionThreshold
+	}
+	precision := MinPrecision
+	for precision < MaxPrecision && sparseLimit(uint8(precision)) < threshold {
+		precision++
+	}
+	return uint8(precision)
+}
+
+// sparseLimit is the number of hashes a sketch keeps before switching to
+// registers: as many as fit in the registers' memory at a 3/4 load factor
+func sparseLimit(precision uint8) int {
+	return 3 * (1 << precision) / 16
+}
+
+// NewHyperLogLog creates an empty sketch of the given precision, clamped to
+// [MinPrecision, MaxPrecision]
+func NewHyperLogLog(precision uint8) *HyperLogLog {
+	if precision < MinPrecision {
+		precision = MinPrecision
+	}
+	if precision > MaxPrecision {
+		precision = MaxPrecision
+	}
+	return &HyperLogLog{precision: precision, sparse: make(map[uint64]struct{})}
+}
+
+// Precision returns the sketch's precision
+func (h *HyperLogLog) Precision() uint8 {
+	return h.precision
+}
+
+// hashValue hashes a value to 64 bits: FNV-1a, then the SplitMix64 finalizer
+// so every bit depends on the whole value
+func hashValue(value string) uint64 {
+	f := fnv.New64a()
+	f.Write([]byte(value))
+	x := f.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// Add adds a value
+func (h *HyperLogLog) Add(value string) {
+	h.addHash(hashValue(value))
+}
+
+func (h *HyperLogLog) addHash(hash uint64) {
+	if h.sparse == nil {
+		h.addRegister(hash)
+		return
+	}
+	h.sparse[hash] = struct{}{}
+	if len(h.sparse) > sparseLimit(h.precision) {
+		h.toDense()
+	}
+}
+
+// addRegister records a hash in the register its top bits select, keeping
+// the largest position of the first 1 bit among the remaining bits
+func (h *HyperLogLog) addRegister(hash uint64) {
+	index := hash >> (64 - h.precision)
+	rest := hash << h.precision
+	rank := uint8(bits.LeadingZeros64(rest)) + 1
+	if limit := 64 - h.precision + 1; rank > limit {
+		rank = limit
+	}
+	if rank > h.registers[index] {
+		h.registers[index] = rank
+	}
+}
+
+// toDense moves the kept hashes into registers
+func (h *HyperLogLog) toDense() {
+	h.registers = make([]uint8, 1<<h.precision)
+	for hash := range h.sparse {
+		h.addRegister(hash)
+	}
+	h.sparse = nil
+}
+
+// Merge adds every value counted by other, which must have the same precision
+func (h *HyperLogLog) Merge(other *HyperLogLog) error {
+	if other.precision != h.precision {
+		return fmt.Errorf("cannot merge HyperLogLog of precision %d into precision %d", other.precision, h.precision)
+	}
+	if other.sparse != nil {
+		for hash := range other.sparse {
+			h.addHash(hash)
+		}
+		return nil
+	}
+	if h.sparse != nil {
+		h.toDense()
+	}
+	for i, rank := range other.registers {
+		if rank > h.registers[i] {
+			h.registers[i] = rank
+		}
+	}
+	return nil
+}
+
+// Cardinality returns the number of distinct values added, exact while the
+// sketch keeps hashes and estimated from the registers after
+func (h *HyperLogLog) Cardinality() int64 {
+	if h.sparse != nil {
+		return int64(len(h.sparse))
+	}
+
+	// Ertl, "New cardinality estimation algorithms for HyperLogLog
+	// sketches" (2017), section 3.2
+	q := 64 - int(h.precision)
+	m := float64(len(h.registers))
+	counts := make([]float64, q+2)
+	for _, rank := range h.registers {
+		counts[rank]++
+	}
+	z := m * hllTau(1-counts[q+1]/m)
+	for k := q; k >= 1; k-- {
+		z = 0.5 * (z + counts[k])
+	}
+	z += m * hllSigma(counts[0]/m)
+	return int64(math.Round(m * m / (2 * math.Ln2 * z)))
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y, z := 1.0, x
+	for {
+		x *= x
+		previous := z
+		z += x * y
+		y *= 2
+		if z == previous {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		previous := z
+		y *= 0.5
+		z -= (1 - x) * (1 - x) * y
+		if z == previous {
+			return z / 3
+		}
+	}
+}
+
+// MarshalBinary serializes the sketch: a version byte, the precision and the
+// representation, then either the number of kept hashes and the sorted
+// hashes, little-endian, or one byte per register
+func (h *HyperLogLog) MarshalBinary() ([]byte, error) {
+	if h.sparse == nil {
+		buf := make([]byte, 0, 3+len(h.registers))
+		buf = append(buf, hllVersion, h.precision, hllDense)
+		return append(buf, h.registers...), nil
+	}
+
+	hashes := make([]uint64, 0, len(h.sparse))
+	for hash := range h.sparse {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+	buf := make([]byte, 0, 7+8*len(hashes))
+	buf = append(buf, hllVersion, h.precision, hllSparse)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(hashes)))
+	for _, hash := range hashes {
+		buf = binary.LittleEndian.AppendUint64(buf, hash)
+	}
+	return buf, nil
+}
+
+// UnmarshalHyperLogLog deserializes a sketch written by MarshalBinary
+func UnmarshalHyperLogLog(data []byte) (*HyperLogLog, error) {
+	if len(data) < 3 {
+		return nil, errors.New("HyperLogLog is truncated")
+	}
+	if data[0] != hllVersion {
+		return nil, fmt.Errorf("unknown HyperLogLog version %d", data[0])
+	}
+	precision := data[1]
+	if precision < MinPrecision || precision > MaxPrecision {
+		return nil, fmt.Errorf("invalid HyperLogLog precision %d", precision)
+	}
+
+	h := &HyperLogLog{precision: precision}
+	switch data[2] {
+	case hllDense:
+		if len(data) != 3+1<<precision {
+			return nil, errors.New("HyperLogLog is truncated")
+		}
+		h.registers = append([]uint8(nil), data[3:]...)
+		for _, rank := range h.registers {
+			if rank > 64-precision+1 {
+				return nil, errors.New("HyperLogLog registers are invalid")
+			}
+		}
+	case hllSparse:
+		if len(data) < 7 {
+			return nil, errors.New("HyperLogLog is truncated")
+		}
+		n := int(binary.LittleEndian.Uint32(data[3:]))
+		if n > sparseLimit(precision) || len(data) != 7+8*n {
+			return nil, errors.New("HyperLogLog is truncated")
+		}
+		h.sparse = make(map[uint64]struct{}, n)
+		for i := 0; i < n; i++ {
+			h.sparse[binary.LittleEndian.Uint64(data[7+8*i:])] = struct{}{}
+		}
+	default:
+		return nil, fmt.Errorf("unknown HyperLogLog representation %d", data[2])
+	}
+	return h, nil
+}
@@ -0,0 +1,94 @@
+package sketch
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrecisionFromThreshold(t *testing.T) {
+	assert.Equal(t, uint8(MinPrecision), PrecisionFromThreshold(0))
+	assert.Equal(t, uint8(14), PrecisionFromThreshold(DefaultPrecisionThreshold))
+	assert.Equal(t, uint8(MaxPrecision), PrecisionFromThreshold(MaxPrecisionThreshold))
+	assert.Equal(t, uint8(MaxPrecision), PrecisionFromThreshold(1<<30))
+}
+
+func TestHyperLogLogExactBelowThreshold(t *testing.T) {
+	h := NewHyperLogLog(PrecisionFromThreshold(100))
+	for i := 0; i < 1000; i++ {
+		h.Add(strconv.Itoa(i % 100))
+	}
+	assert.Equal(t, int64(100), h.Cardinality())
+	assert.NotNil(t, h.sparse)
+}
+
+func TestHyperLogLogAccuracy(t *testing.T) {
+	for _, n := range []int{1000, 5000, 50000, 1000000} {
+		h := NewHyperLogLog(14)
+		for i := 0; i < n; i++ {
+			h.Add("user-" + strconv.Itoa(i))
+		}
+		// The standard error at precision 14 is 1.04/sqrt(2^14), under 1%
+		relative := math.Abs(float64(h.Cardinality())-float64(n)) / float64(n)
+		assert.Less(t, relative, 0.03, "cardinality of %d values", n)
+	}
+}
+
+func TestHyperLogLogMerge(t *testing.T) {
+	// Three shards hold overlapping users
+	merged := NewHyperLogLog(14)
+	for shard := 0; shard < 3; shard++ {
+		h := NewHyperLogLog(14)
+		for i := shard * 10000; i < shard*10000+20000; i++ {
+			h.Add("user-" + strconv.Itoa(i))
+		}
+		data, err := h.MarshalBinary()
+		require.NoError(t, err)
+		decoded, err := UnmarshalHyperLogLog(data)
+		require.NoError(t, err)
+		require.NoError(t, merged.Merge(decoded))
+	}
+	assert.InDelta(t, 40000, merged.Cardinality(), 1200)
+
+	// Sparse sketches merge exactly until they outgrow the limit
+	a, b := NewHyperLogLog(10), NewHyperLogLog(10)
+	for i := 0; i < 150; i++ {
+		a.Add(strconv.Itoa(i))
+		b.Add(strconv.Itoa(i + 100))
+	}
+	require.NoError(t, a.Merge(b))
+	assert.InDelta(t, 250, a.Cardinality(), 10)
+	assert.Nil(t, a.sparse)
+
+	assert.Error(t, a.Merge(NewHyperLogLog(12)))
+}
+
+func TestHyperLogLogMarshal(t *testing.T) {
+	sparse := NewHyperLogLog(12)
+	sparse.Add("a")
+	sparse.Add("b")
+	data, err := sparse.MarshalBinary()
+	require.NoError(t, err)
+	decoded, err := UnmarshalHyperLogLog(data)
+	require.NoError(t, err)
+	assert.Equal(t, uint8(12), decoded.Precision())
+	assert.Equal(t, int64(2), decoded.Cardinality())
+
+	dense := NewHyperLogLog(4)
+	for i := 0; i < 100; i++ {
+		dense.Add(strconv.Itoa(i))
+	}
+	data, err = dense.MarshalBinary()
+	require.NoError(t, err)
+	decoded, err = UnmarshalHyperLogLog(data)
+	require.NoError(t, err)
+	assert.Equal(t, dense.Cardinality(), decoded.Cardinality())
+
+	_, err = UnmarshalHyperLogLog(data[:len(data)-1])
+	assert.Error(t, err)
+	_, err = UnmarshalHyperLogLog([]byte{hllVersion, 30, hllDense})
+	assert.Error(t, err)
+}
@@ -0,0 +1,272 @@
+// Package sketch provides mergeable summaries of value sets that shards build
+// over their own documents and the coordinator combines
+package sketch
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// DefaultCompression is the compression of a t-digest unless the request
+// sets one, as in OpenSearch
+const DefaultCompression = 100
+
+// tdigestVersion identifies the serialized t-digest layout
+const tdigestVersion = 1
+
+// centroid is a cluster of values summarized by their mean and count
+type centroid struct {
+	mean   float64
+	weight float64
+}
+
+// TDigest estimates quantiles of a stream of values (Dunning's merging
+// t-digest). Values near the tails are kept in small clusters and those in
+// the middle in larger ones, so extreme quantiles stay accurate. Digests of
+// disjoint value sets merge into a digest of their union.
+type TDigest struct {
+	compression float64
+	centroids   []centroid // sorted by mean once compressed
+	unmerged    []centroid
+	count       float64
+	min, max    float64
+}
+
+// NewTDigest creates an empty t-digest. Higher compression keeps more
+// centroids, about compression of them, for more accurate quantiles.
+func NewTDigest(compression float64) *TDigest {
+	if compression < 20 {
+		compression = 20
+	}
+	return &TDigest{
+		compression: compression,
+		min:         math.Inf(1),
+		max:         math.Inf(-1),
+	}
+}
+
+// Compression returns the digest's compression
+func (t *TDigest) Compression() float64 {
+	return t.compression
+}
+
+// Count returns the number of values added
+func (t *TDigest) Count() int64 {
+	return int64(t.count)
+}
+
+// Add adds a value. NaN values are ignored.
+func (t *TDigest) Add(value float64) {
+	if math.IsNaN(value) {
+		return
+	}
+	t.add(centroid{mean: value, weight: 1})
+	t.min = math.Min(t.min, value)
+	t.max = math.Max(t.max, value)
+}
+
+func (t *TDigest) add(c centroid) {
+	t.unmerged = append(t.unmerged, c)
+	t.count += c.weight
+	if len(t.unmerged) >= int(5*t.compression) {
+		t.compress()
+	}
+}
+
+// Merge adds every value summarized by other
+func (t *TDigest) Merge(other *TDigest) {
+	if other == nil || other.count == 0 {
+		return
+	}
+	for _, c := range other.centroids {
+		t.add(c)
+	}
+	for _, c := range other.unmerged {
+		t.add(c)
+	}
+	t.min = math.Min(t.min, other.min)
+	t.max = math.Max(t.max, other.max)
+}
+
+// compress merges the buffered values into the centroids. Neighboring
+// centroids are combined while the combined cluster stays within the size
+// the k1 scale function allows at its quantile. Up to compression centroids
+// are kept as they are, so small value sets stay exact.
+func (t *TDigest) compress() {
+	if len(t.unmerged) == 0 {
+		return
+	}
+	all := append(t.centroids, t.unmerged...)
+	t.unmerged = nil
+	sort.SliceStable(all, func(i, j int) bool { return all[i].mean < all[j].mean })
+	if len(all) <= int(t.compression) {
+		t.centroids = all
+		return
+	}
+
+	// k1 maps a quantile to an index whose unit steps bound cluster sizes
+	scale := t.compression / (2 * math.Pi)
+	k := func(q float64) float64 { return scale * math.Asin(2*q-1) }
+	kInverse := func(index float64) float64 {
+		return (math.Sin(math.Min(index, k(1))/scale) + 1) / 2
+	}
+
+	merged := make([]centroid, 0, int(t.compression))
+	current := all[0]
+	weightSoFar := 0.0
+	limit := t.count * kInverse(k(0)+1)
+	for _, next := range all[1:] {
+		if weightSoFar+current.weight+next.weight <= limit {
+			total := current.weight + next.weight
+			current.mean += (next.mean - current.mean) * next.weight / total
+			current.weight = total
+			continue
+		}
+		weightSoFar += current.weight
+		merged = append(merged, current)
+		limit = t.count * kInverse(k(weightSoFar/t.count)+1)
+		current = next
+	}
+	t.centroids = append(merged, current)
+}
+
+// centers returns the centroids with the 0-based rank of their center among
+// all values. Singleton centroids sit at their own rank.
+func (t *TDigest) centers() ([]centroid, []float64) {
+	t.compress()
+	ranks := make([]float64, len(t.centroids))
+	cumulative := 0.0
+	for i, c := range t.centroids {
+		ranks[i] = cumulative + (c.weight-1)/2
+		cumulative += c.weight
+	}
+	return t.centroids, ranks
+}
+
+// Quantile estimates the value below which a fraction q of the values fall,
+// interpolating linearly between centroid centers. With one value per
+// centroid it is exact, interpolating between the closest ranks. It returns
+// NaN for an empty digest.
+func (t *TDigest) Quantile(q float64) float64 {
+	if t.count == 0 {
+		return math.NaN()
+	}
+	q = math.Max(0, math.Min(1, q))
+	centroids, ranks := t.centers()
+	rank := q * (t.count - 1)
+
+	// Between the smallest value, at rank 0, and the first center
+	if rank <= ranks[0] {
+		if ranks[0] == 0 {
+			return centroids[0].mean
+		}
+		return t.min + (centroids[0].mean-t.min)*rank/ranks[0]
+	}
+	last := len(centroids) - 1
+	if rank >= ranks[last] {
+		if ranks[last] == t.count-1 {
+			return centroids[last].mean
+		}
+		return centroids[last].mean + (t.max-centroids[last].mean)*(rank-ranks[last])/(t.count-1-ranks[last])
+	}
+
+	i := sort.SearchFloat64s(ranks, rank)
+	if ranks[i] == rank {
+		return centroids[i].mean
+	}
+	lower, upper := centroids[i-1], centroids[i]
+	return lower.mean + (upper.mean-lower.mean)*(rank-ranks[i-1])/(ranks[i]-ranks[i-1])
+}
+
+// CDF estimates the fraction of values less than or equal to value, the
+// inverse of Quantile. It returns NaN for an empty digest.
+func (t *TDigest) CDF(value float64) float64 {
+	if t.count == 0 {
+		return math.NaN()
+	}
+	if value < t.min {
+		return 0
+	}
+	if value >= t.max {
+		return 1
+	}
+	centroids, ranks := t.centers()
+
+	// The last center at or below the value, if any
+	i := sort.Search(len(centroids), func(i int) bool { return centroids[i].mean > value }) - 1
+	var rank float64
+	switch {
+	case i < 0:
+		rank = ranks[0] * (value - t.min) / (centroids[0].mean - t.min)
+	case i == len(centroids)-1:
+		rank = ranks[i] + (t.count-1-ranks[i])*(value-centroids[i].mean)/(t.max-centroids[i].mean)
+	default:
+		lower, upper := centroids[i], centroids[i+1]
+		rank = ranks[i] + (ranks[i+1]-ranks[i])*(value-lower.mean)/(upper.mean-lower.mean)
+	}
+	return math.Min(1, (rank+1)/t.count)
+}
+
+// MarshalBinary serializes the digest: a version byte, the compression,
+// count, min and max, the number of centroids and each centroid's mean and
+// weight, little-endian
+func (t *TDigest) MarshalBinary() ([]byte, error) {
+	t.compress()
+	buf := make([]byte, 0, 1+4*8+4+len(t.centroids)*16)
+	buf = append(buf, tdigestVersion)
+	for _, v := range []float64{t.compression, t.count, t.min, t.max} {
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(v))
+	}
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(t.centroids)))
+	for _, c := range t.centroids {
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(c.mean))
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(c.weight))
+	}
+	return buf, nil
+}
+
+// UnmarshalTDigest deserializes a digest written by MarshalBinary
+func UnmarshalTDigest(data []byte) (*TDigest, error) {
+	const header = 1 + 4*8 + 4
+	if len(data) < header {
+		return nil, errors.New("t-digest is truncated")
+	}
+	if data[0] != tdigestVersion {
+		return nil, fmt.Errorf("unknown t-digest version %d", data[0])
+	}
+	float := func(offset int) float64 {
+		return math.Float64frombits(binary.LittleEndian.Uint64(data[offset:]))
+	}
+
+	t := &TDigest{
+		compression: float(1),
+		count:       float(9),
+		min:         float(17),
+		max:         float(25),
+	}
+	n := int(binary.LittleEndian.Uint32(data[33:]))
+	if len(data) != header+n*16 {
+		return nil, errors.New("t-digest is truncated")
+	}
+	if t.compression < 20 || math.IsNaN(t.compression) {
+		return nil, fmt.Errorf("invalid t-digest compression %v", t.compression)
+	}
+
+	t.centroids = make([]centroid, n)
+	total := 0.0
+	for i := range t.centroids {
+		c := centroid{mean: float(header + i*16), weight: float(header + i*16 + 8)}
+		if !(c.weight > 0) || math.IsNaN(c.mean) || (i > 0 && c.mean < t.centroids[i-1].mean) {
+			return nil, errors.New("t-digest centroids are invalid")
+		}
+		t.centroids[i] = c
+		total += c.weight
+	}
+	if total != t.count {
+		return nil, errors.New("t-digest count does not match its centroids")
+	}
+	return t, nil
+}
@@ -0,0 +1,122 @@
+package sketch
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// exactQuantile interpolates linearly between the closest ranks of sorted
+func exactQuantile(sorted []float64, q float64) float64 {
+	rank := q * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+func TestTDigestSmallSetsAreExact(t *testing.T) {
+	digest := NewTDigest(DefaultCompression)
+	assert.True(t, math.IsNaN(digest.Quantile(0.5)))
+	assert.True(t, math.IsNaN(digest.CDF(1)))
+
+	for _, v := range []float64{4, 1, 3, 2, 1} {
+		digest.Add(v)
+	}
+	assert.Equal(t, int64(5), digest.Count())
+	assert.Equal(t, 1.0, digest.Quantile(0))
+	assert.Equal(t, 1.0, digest.Quantile(0.25))
+	assert.Equal(t, 2.0, digest.Quantile(0.5))
+	assert.Equal(t, 3.5, digest.Quantile(0.875))
+	assert.Equal(t, 4.0, digest.Quantile(1))
+
+	assert.Equal(t, 0.0, digest.CDF(0.5))
+	assert.Equal(t, 0.4, digest.CDF(1))
+	assert.Equal(t, 0.6, digest.CDF(2))
+	assert.InDelta(t, 0.7, digest.CDF(2.5), 1e-9)
+	assert.Equal(t, 1.0, digest.CDF(4))
+}
+
+func TestTDigestAccuracy(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	values := make([]float64, 100000)
+	digest := NewTDigest(DefaultCompression)
+	for i := range values {
+		// Log-normal, skewed like request latencies
+		values[i] = math.Exp(rng.NormFloat64())
+		digest.Add(values[i])
+	}
+	sort.Float64s(values)
+
+	assert.LessOrEqual(t, len(digest.centroids)+len(digest.unmerged), 6*DefaultCompression)
+	for _, q := range []float64{0.001, 0.01, 0.25, 0.5, 0.75, 0.99, 0.999} {
+		estimate := digest.Quantile(q)
+		// Accuracy is measured in rank: the estimate's true quantile
+		rank := float64(sort.SearchFloat64s(values, estimate)) / float64(len(values))
+		assert.InDelta(t, q, rank, 0.005, "quantile %v", q)
+		assert.InDelta(t, q, digest.CDF(exactQuantile(values, q)), 0.005, "cdf at quantile %v", q)
+	}
+	assert.Equal(t, values[0], digest.Quantile(0))
+	assert.Equal(t, values[len(values)-1], digest.Quantile(1))
+}
+
+func TestTDigestMerge(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	whole := NewTDigest(DefaultCompression)
+	merged := NewTDigest(DefaultCompression)
+
+	// Shards hold differently distributed values
+	var values []float64
+	for shard := 0; shard < 5; shard++ {
+		part := NewTDigest(DefaultCompression)
+		for i := 0; i < 20000; i++ {
+			v := rng.ExpFloat64() * float64(shard+1)
+			values = append(values, v)
+			part.Add(v)
+			whole.Add(v)
+		}
+		data, err := part.MarshalBinary()
+		require.NoError(t, err)
+		decoded, err := UnmarshalTDigest(data)
+		require.NoError(t, err)
+		merged.Merge(decoded)
+	}
+	sort.Float64s(values)
+
+	assert.Equal(t, whole.Count(), merged.Count())
+	for _, q := range []float64{0.01, 0.5, 0.9, 0.99} {
+		rank := float64(sort.SearchFloat64s(values, merged.Quantile(q))) / float64(len(values))
+		assert.InDelta(t, q, rank, 0.005, "quantile %v", q)
+	}
+}
+
+func TestTDigestMarshal(t *testing.T) {
+	digest := NewTDigest(200)
+	for i := 0; i < 1000; i++ {
+		digest.Add(float64(i))
+	}
+	data, err := digest.MarshalBinary()
+	require.NoError(t, err)
+
+	decoded, err := UnmarshalTDigest(data)
+	require.NoError(t, err)
+	assert.Equal(t, 200.0, decoded.Compression())
+	assert.Equal(t, digest.Count(), decoded.Count())
+	assert.Equal(t, digest.Quantile(0.3), decoded.Quantile(0.3))
+
+	empty, err := NewTDigest(DefaultCompression).MarshalBinary()
+	require.NoError(t, err)
+	decoded, err = UnmarshalTDigest(empty)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), decoded.Count())
+
+	_, err = UnmarshalTDigest(data[:len(data)-1])
+	assert.Error(t, err)
+	corrupt := append([]byte{}, data...)
+	corrupt[0] = 9
+	_, err = UnmarshalTDigest(corrupt)
+	assert.Error(t, err)
+}
@@ -34,13 +34,15 @@ type BulkRequest struct {
 
 // BulkItemResult represents the result of a single bulk operation
 type BulkItemResult struct {
-	Index   string                 `json:"_index"`
-	ID      string                 `json:"_id"`
-	Version int64                  `json:"_version,omitempty"`
-	Result  string                 `json:"result,omitempty"`
-	Status  int                    `json:"status"`
-	Error   *BulkItemError         `json:"error,omitempty"`
-	Shards  *BulkItemShards        `json:"_shards,omitempty"`
+	Index       string          `json:"_index"`
+	ID          string          `json:"_id"`
+	Version     int64           `json:"_version,omitempty"`
+	Result      string          `json:"result,omitempty"`
+	Status      int             `json:"status"`
+	Error       *BulkItemError  `json:"error,omitempty"`
+	Shards      *BulkItemShards `json:"_shards,omitempty"`
+	SeqNo       int64           `json:"_seq_no,omitempty"`
+	PrimaryTerm int64           `json:"_primary_term,omitempty"`
 }
 
 // BulkItemError represents an error for a bulk operation
@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/conjugate/conjugate/pkg/coordination/parser"
@@ -294,6 +295,12 @@ func (qc *QueryCache) Stats() map[CacheType]CacheStats {
 
 // generateLogicalPlanKey creates a cache key for a logical plan
 func (qc *QueryCache) generateLogicalPlanKey(indexName string, searchReq *parser.SearchRequest, shardIDs []int32) string {
+	// "aggs" is an alias for "aggregations"
+	aggregations := searchReq.Aggregations
+	if aggregations == nil {
+		aggregations = searchReq.Aggs
+	}
+
 	// Create a normalized representation of the search request
 	keyData := struct {
 		Index        string
@@ -306,7 +313,7 @@ func (qc *QueryCache) generateLogicalPlanKey(indexName string, searchReq *parser
 	}{
 		Index:        indexName,
 		Query:        normalizeQuery(searchReq.ParsedQuery),
-		Aggregations: searchReq.Aggregations, // Use raw aggregations map
+		Aggregations: aggregations, // Use raw aggregations map
 		Size:         searchReq.Size,
 		From:         searchReq.From,
 		Sort:         searchReq.Sort, // Use raw sort slice
@@ -327,8 +334,8 @@ func (qc *QueryCache) generateLogicalPlanKey(indexName string, searchReq *parser
 
 // generatePhysicalPlanKey creates a cache key for a physical plan
 func (qc *QueryCache) generatePhysicalPlanKey(indexName string, logicalPlan planner.LogicalPlan) string {
-	// Use the logical plan's string representation as part of the key
-	planStr := logicalPlan.String()
+	// Use the whole plan tree's string representation as part of the key
+	planStr := planTreeString(logicalPlan)
 	keyStr := fmt.Sprintf("%s:%s", indexName, planStr)
 
 	// Hash the key
@@ -336,6 +343,19 @@ func (qc *QueryCache) generatePhysicalPlanKey(indexName string, logicalPlan plan
 	return "physical:" + hex.EncodeToString(hash[:])
 }
 
+// planTreeString describes a plan and all of its descendants. A node's
+// String only covers the node itself, so plans that differ below the root
+// (e.g. in the pushed-down filter or aggregations) would share a key.
+func planTreeString(plan planner.LogicalPlan) string {
+	var b strings.Builder
+	b.WriteString(plan.String())
+	for _, child := range plan.Children() {
+		b.WriteString(" <- ")
+		b.WriteString(planTreeString(child))
+	}
+	return b.String()
+}
+
 // normalizeQuery normalizes a query for consistent caching
 func normalizeQuery(query parser.Query) interface{} {
 	if query == nil {
@@ -391,6 +411,13 @@ func normalizeQuery(query parser.Query) interface{} {
 			"field": q.Field,
 			"value": q.Value,
 		}
+	case *parser.RegexpQuery:
+		return map[string]interface{}{
+			"type":             "regexp",
+			"field":            q.Field,
+			"value":            q.Value,
+			"case_insensitive": q.CaseInsensitive,
+		}
 	case *parser.FuzzyQuery:
 		return map[string]interface{}{
 			"type":      "fuzzy",
@@ -398,6 +425,33 @@ func normalizeQuery(query parser.Query) interface{} {
 			"value":     q.Value,
 			"fuzziness": q.Fuzziness,
 		}
+	case *parser.NeuralSparseQuery:
+		return map[string]interface{}{
+			"type":         "neural_sparse",
+			"field":        q.Field,
+			"query_tokens": q.QueryTokens,
+			"boost":        q.Boost,
+		}
+	case *parser.KNNQuery:
+		normalized := map[string]interface{}{
+			"type":           "knn",
+			"field":          q.Field,
+			"query_vector":   q.QueryVector,
+			"k":              q.K,
+			"num_candidates": q.NumCandidates,
+			"boost":          q.Boost,
+		}
+		if q.Filter != nil {
+			normalized["filter"] = normalizeQuery(q.Filter)
+		}
+		return normalized
+	case *parser.HybridQuery:
+		return map[string]interface{}{
+			"type":             "hybrid",
+			"queries":          normalizeQueryList(q.Queries),
+			"pagination_depth": q.PaginationDepth,
+			"fusion":           q.Fusion,
+		}
 	case *parser.ExistsQuery:
 		return map[string]interface{}{
 			"type":  "exists",
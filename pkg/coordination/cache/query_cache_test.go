@@ -198,6 +198,56 @@ func TestQueryCache_PhysicalPlan_PutAndGet(t *testing.T) {
 	assert.Equal(t, shardIDs, scan.Shards)
 }
 
+func TestQueryCache_PhysicalPlan_DifferentAggregations(t *testing.T) {
+	cache := NewQueryCache(DefaultQueryCacheConfig())
+
+	indexName := "products"
+	shardIDs := []int32{0, 1, 2}
+
+	// The plans only differ in the aggregations pushed into the scan
+	newPlan := func(field string) planner.LogicalPlan {
+		return &planner.LogicalAggregate{
+			Aggregations: []*planner.Aggregation{{Name: "by_field", Type: planner.AggTypeTerms, Field: field}},
+			Child: &planner.LogicalScan{
+				IndexName: indexName,
+				Shards:    shardIDs,
+				Aggregations: map[string]interface{}{
+					"by_field": map[string]interface{}{"terms": map[string]interface{}{"field": field}},
+				},
+			},
+		}
+	}
+
+	cache.PutPhysicalPlan(indexName, newPlan("category"), &planner.PhysicalScan{IndexName: indexName})
+
+	_, found := cache.GetPhysicalPlan(indexName, newPlan("category"))
+	assert.True(t, found)
+
+	_, found = cache.GetPhysicalPlan(indexName, newPlan("brand"))
+	assert.False(t, found)
+}
+
+func TestQueryCache_LogicalPlan_AggsAlias(t *testing.T) {
+	cache := NewQueryCache(DefaultQueryCacheConfig())
+
+	indexName := "products"
+	shardIDs := []int32{0}
+
+	searchReq1 := &parser.SearchRequest{
+		ParsedQuery: &parser.MatchAllQuery{},
+		Aggs:        map[string]interface{}{"a": map[string]interface{}{"avg": map[string]interface{}{"field": "price"}}},
+	}
+	searchReq2 := &parser.SearchRequest{
+		ParsedQuery: &parser.MatchAllQuery{},
+		Aggs:        map[string]interface{}{"a": map[string]interface{}{"max": map[string]interface{}{"field": "price"}}},
+	}
+
+	cache.PutLogicalPlan(indexName, searchReq1, shardIDs, &planner.LogicalScan{IndexName: indexName})
+
+	_, found := cache.GetLogicalPlan(indexName, searchReq2, shardIDs)
+	assert.False(t, found, "requests with different aggs must not share a plan")
+}
+
 func TestQueryCache_InvalidateIndex(t *testing.T) {
 	cache := NewQueryCache(DefaultQueryCacheConfig())
 
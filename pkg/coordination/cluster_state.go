@@ -0,0 +1,426 @@
+package coordination
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	pb "github.com/conjugate/conjugate/pkg/common/proto"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// watchRetryMin and watchRetryMax bound the backoff between attempts to
+	// reload or watch the cluster state
+	watchRetryMin = 500 * time.Millisecond
+	watchRetryMax = 30 * time.Second
+)
+
+// clusterStateSource is the part of the master client the cache is fed from
+type clusterStateSource interface {
+	GetClusterState(ctx context.Context, includeRouting, includeNodes, includeIndices bool) (*pb.ClusterStateResponse, error)
+	GetIndexMetadata(ctx context.Context, indexName string) (*pb.IndexMetadataResponse, error)
+	GetShardRouting(ctx context.Context, indexName string) (map[int32]*pb.ShardRouting, error)
+	WatchClusterState(ctx context.Context, fromVersion int64) (pb.MasterService_WatchClusterStateClient, error)
+}
+
+// NodeChangeFunc is called when a node joins, changes or leaves the cluster
+type NodeChangeFunc func(node *pb.NodeInfo, removed bool)
+
+// ClusterStateCache is a local copy of the cluster state, loaded from the
+// master once and then kept current from its WatchClusterState stream. It
+// serves the index metadata and shard routing lookups of the router, the
+// planner and the executor without a round trip to the master.
+//
+// Until the first load, and for indices it does not know (for example one
+// created a moment ago whose event has not arrived yet), lookups go to the
+// master. Once loaded, the cache keeps serving its last known state while the
+// stream is down.
+type ClusterStateCache struct {
+	master clusterStateSource
+	logger *zap.Logger
+
+	mu      sync.RWMutex
+	loaded  bool
+	version int64
+	indices map[string]*pb.IndexMetadata
+	routing map[string]map[int32]*pb.ShardRouting // index -> shard -> routing
+	nodes   map[string]*pb.NodeInfo
+
+	onNodeChange NodeChangeFunc
+}
+
+// NewClusterStateCache creates an empty cluster state cache
+func NewClusterStateCache(master clusterStateSource, logger *zap.Logger) *ClusterStateCache {
+	return &ClusterStateCache{
+		master:  master,
+		logger:  logger,
+		indices: make(map[string]*pb.IndexMetadata),
+		routing: make(map[string]map[int32]*pb.ShardRouting),
+		nodes:   make(map[string]*pb.NodeInfo),
+	}
+}
+
+// OnNodeChange sets the function called for each node that joins, changes or
+// leaves. It is called from the goroutine that updates the cache, without
+// the cache locked.
+func (c *ClusterStateCache) OnNodeChange(fn NodeChangeFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onNodeChange = fn
+}
+
+// Version returns the version of the cached state, 0 before the first load
+func (c *ClusterStateCache) Version() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.version
+}
+
+// Loaded reports whether the cache holds a cluster state
+func (c *ClusterStateCache) Loaded() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.loaded
+}
+
+// Nodes returns the known nodes
+func (c *ClusterStateCache) Nodes() []*pb.NodeInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	nodes := make([]*pb.NodeInfo, 0, len(c.nodes))
+	for _, node := range c.nodes {
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// GetIndexMetadata returns the metadata of an index
+func (c *ClusterStateCache) GetIndexMetadata(ctx context.Context, indexName string) (*pb.IndexMetadataResponse, error) {
+	c.mu.RLock()
+	metadata, exists := c.indices[indexName]
+	loaded := c.loaded
+	c.mu.RUnlock()
+
+	if loaded && exists {
+		return &pb.IndexMetadataResponse{Metadata: metadata}, nil
+	}
+	return c.master.GetIndexMetadata(ctx, indexName)
+}
+
+// GetShardRouting returns the routing of each shard of an index. The map is
+// the caller's to keep; the routings in it are shared and must not be
+// modified.
+func (c *ClusterStateCache) GetShardRouting(ctx context.Context, indexName string) (map[int32]*pb.ShardRouting, error) {
+	c.mu.RLock()
+	shards, exists := c.routing[indexName]
+	if c.loaded && exists {
+		routing := make(map[int32]*pb.ShardRouting, len(shards))
+		for shardID, shard := range shards {
+			routing[shardID] = shard
+		}
+		c.mu.RUnlock()
+		return routing, nil
+	}
+	c.mu.RUnlock()
+
+	return c.master.GetShardRouting(ctx, indexName)
+}
+
+// Load replaces the cached state with the master's current cluster state
+func (c *ClusterStateCache) Load(ctx context.Context) error {
+	state, err := c.master.GetClusterState(ctx, true, true, true)
+	if err != nil {
+		return fmt.Errorf("failed to get cluster state: %w", err)
+	}
+	c.Reset(state)
+	return nil
+}
+
+// Reset replaces the cached state with a full cluster state, reporting the
+// nodes that joined, changed or left since the previous one
+func (c *ClusterStateCache) Reset(state *pb.ClusterStateResponse) {
+	indices := make(map[string]*pb.IndexMetadata, len(state.Indices))
+	for _, index := range state.Indices {
+		indices[index.IndexName] = index
+	}
+
+	routing := make(map[string]map[int32]*pb.ShardRouting)
+	if state.RoutingTable != nil {
+		for indexName, table := range state.RoutingTable.Indices {
+			shards := make(map[int32]*pb.ShardRouting, len(table.Shards))
+			for shardID, shard := range table.Shards {
+				shards[shardID] = shard
+			}
+			routing[indexName] = shards
+		}
+	}
+
+	nodes := make(map[string]*pb.NodeInfo, len(state.Nodes))
+	for _, node := range state.Nodes {
+		nodes[node.NodeId] = node
+	}
+
+	c.mu.Lock()
+	var changes []nodeChange
+	for nodeID, node := range nodes {
+		if previous, exists := c.nodes[nodeID]; !exists || !proto.Equal(previous, node) {
+			changes = append(changes, nodeChange{node: node})
+		}
+	}
+	for nodeID, node := range c.nodes {
+		if _, exists := nodes[nodeID]; !exists {
+			changes = append(changes, nodeChange{node: node, removed: true})
+		}
+	}
+
+	c.loaded = true
+	c.version = state.Version
+	c.indices = indices
+	c.routing = routing
+	c.nodes = nodes
+	onNodeChange := c.onNodeChange
+	c.mu.Unlock()
+
+	c.logger.Info("Loaded cluster state",
+		zap.Int64("version", state.Version),
+		zap.Int("indices", len(indices)),
+		zap.Int("nodes", len(nodes)))
+
+	c.notify(onNodeChange, changes)
+}
+
+// Apply applies an event from the watch stream. Events at or below the
+// cached version are already reflected and ignored.
+func (c *ClusterStateCache) Apply(event *pb.ClusterStateEvent) error {
+	var change *nodeChange
+
+	c.mu.Lock()
+	if event.Version <= c.version {
+		c.mu.Unlock()
+		return nil
+	}
+
+	switch event.Type {
+	case pb.ClusterStateEvent_EVENT_TYPE_INDEX_CREATED,
+		pb.ClusterStateEvent_EVENT_TYPE_INDEX_UPDATED,
+		pb.ClusterStateEvent_EVENT_TYPE_INDEX_DELETED:
+		index := &pb.IndexMetadata{}
+		if err := proto.Unmarshal(event.Payload, index); err != nil {
+			c.mu.Unlock()
+			return fmt.Errorf("invalid %s payload: %w", event.Type, err)
+		}
+		if event.Type == pb.ClusterStateEvent_EVENT_TYPE_INDEX_DELETED {
+			delete(c.indices, index.IndexName)
+			delete(c.routing, index.IndexName)
+		} else {
+			c.indices[index.IndexName] = index
+		}
+
+	case pb.ClusterStateEvent_EVENT_TYPE_NODE_JOINED,
+		pb.ClusterStateEvent_EVENT_TYPE_NODE_UPDATED,
+		pb.ClusterStateEvent_EVENT_TYPE_NODE_LEFT:
+		node := &pb.NodeInfo{}
+		if err := proto.Unmarshal(event.Payload, node); err != nil {
+			c.mu.Unlock()
+			return fmt.Errorf("invalid %s payload: %w", event.Type, err)
+		}
+		removed := event.Type == pb.ClusterStateEvent_EVENT_TYPE_NODE_LEFT
+		if removed {
+			delete(c.nodes, node.NodeId)
+		} else {
+			c.nodes[node.NodeId] = node
+		}
+		change = &nodeChange{node: node, removed: removed}
+
+	case pb.ClusterStateEvent_EVENT_TYPE_SHARD_ALLOCATED,
+		pb.ClusterStateEvent_EVENT_TYPE_SHARD_UPDATED,
+		pb.ClusterStateEvent_EVENT_TYPE_SHARD_RELOCATED,
+		pb.ClusterStateEvent_EVENT_TYPE_SHARD_DEALLOCATED:
+		table := &pb.IndexRoutingTable{}
+		if err := proto.Unmarshal(event.Payload, table); err != nil {
+			c.mu.Unlock()
+			return fmt.Errorf("invalid %s payload: %w", event.Type, err)
+		}
+		shards := c.routing[table.IndexName]
+		if shards == nil {
+			shards = make(map[int32]*pb.ShardRouting)
+			c.routing[table.IndexName] = shards
+		}
+		removed := event.Type == pb.ClusterStateEvent_EVENT_TYPE_SHARD_DEALLOCATED
+		for shardID, shard := range table.Shards {
+			if shard.IsPrimary {
+				applyPrimaryChange(shards, shardID, shard, removed)
+			} else {
+				applyReplicaChange(shards, shardID, shard.Allocation, removed)
+			}
+		}
+
+	default:
+		c.logger.Warn("Ignoring unknown cluster state event",
+			zap.String("type", event.Type.String()),
+			zap.Int64("version", event.Version))
+	}
+
+	c.version = event.Version
+	onNodeChange := c.onNodeChange
+	c.mu.Unlock()
+
+	if change != nil {
+		c.notify(onNodeChange, []nodeChange{*change})
+	}
+	return nil
+}
+
+// applyPrimaryChange sets or removes the primary of a shard, keeping the
+// shard's replicas
+func applyPrimaryChange(shards map[int32]*pb.ShardRouting, shardID int32, primary *pb.ShardRouting, removed bool) {
+	current := shards[shardID]
+	if removed {
+		if current == nil || len(current.Replicas) == 0 {
+			delete(shards, shardID)
+			return
+		}
+		primary = &pb.ShardRouting{ShardId: shardID, IsPrimary: true}
+	}
+	if current != nil {
+		primary.Replicas = current.Replicas
+	}
+	shards[shardID] = primary
+}
+
+// applyReplicaChange sets or removes the replica of a shard on the
+// allocation's node. Routings are shared with callers of GetShardRouting, so
+// the shard's routing is replaced rather than modified.
+func applyReplicaChange(shards map[int32]*pb.ShardRouting, shardID int32, replica *pb.ShardAllocation, removed bool) {
+	if replica == nil {
+		return
+	}
+
+	shard := &pb.ShardRouting{ShardId: shardID, IsPrimary: true}
+	if current := shards[shardID]; current != nil {
+		shard = proto.Clone(current).(*pb.ShardRouting)
+	}
+
+	replicas := shard.Replicas[:0]
+	for _, existing := range shard.Replicas {
+		if existing.NodeId != replica.NodeId {
+			replicas = append(replicas, existing)
+		}
+	}
+	if !removed {
+		replicas = append(replicas, replica)
+		sort.Slice(replicas, func(i, j int) bool {
+			return replicas[i].NodeId < replicas[j].NodeId
+		})
+	}
+	shard.Replicas = replicas
+
+	if shard.Allocation == nil && len(shard.Replicas) == 0 {
+		delete(shards, shardID)
+		return
+	}
+	shards[shardID] = shard
+}
+
+// Run keeps the cache current until ctx is done: it loads the cluster state
+// if it has none, then applies the events watched from its version. When the
+// master no longer has the events after that version the state is reloaded;
+// other failures are retried with backoff.
+func (c *ClusterStateCache) Run(ctx context.Context) {
+	c.logger.Info("Starting cluster state watch")
+
+	retry := watchRetryMin
+	for {
+		var err error
+		if !c.Loaded() {
+			err = c.Load(ctx)
+		} else {
+			var applied int
+			applied, err = c.watch(ctx)
+			if applied > 0 {
+				retry = watchRetryMin
+			}
+			if status.Code(err) == codes.OutOfRange {
+				c.logger.Info("Cluster state events unavailable, reloading", zap.Error(err))
+				c.mu.Lock()
+				c.loaded = false
+				c.mu.Unlock()
+				continue
+			}
+		}
+
+		if ctx.Err() != nil {
+			c.logger.Info("Stopping cluster state watch")
+			return
+		}
+		if err == nil {
+			retry = watchRetryMin
+			continue
+		}
+
+		c.logger.Warn("Cluster state watch failed, retrying",
+			zap.Duration("retry_in", retry),
+			zap.Error(err))
+		select {
+		case <-ctx.Done():
+			c.logger.Info("Stopping cluster state watch")
+			return
+		case <-time.After(retry):
+		}
+		retry *= 2
+		if retry > watchRetryMax {
+			retry = watchRetryMax
+		}
+	}
+}
+
+// watch applies the events watched from the cached version until the stream
+// fails, returning how many it applied. An event that cannot be applied
+// leaves the cache to be reloaded.
+func (c *ClusterStateCache) watch(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.master.WatchClusterState(ctx, c.Version())
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			return applied, err
+		}
+		if err := c.Apply(event); err != nil {
+			// The cache missed the event, so it must be reloaded
+			c.mu.Lock()
+			c.loaded = false
+			c.mu.Unlock()
+			return applied, err
+		}
+		applied++
+	}
+}
+
+// nodeChange is a node reported to the NodeChangeFunc
+type nodeChange struct {
+	node    *pb.NodeInfo
+	removed bool
+}
+
+func (c *ClusterStateCache) notify(fn NodeChangeFunc, changes []nodeChange) {
+	if fn == nil {
+		return
+	}
+	for _, change := range changes {
+		fn(change.node, change.removed)
+	}
+}
@@ -0,0 +1,318 @@
+package coordination
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/conjugate/conjugate/pkg/common/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeWatchStream replays events, then fails with err or blocks until the
+// watch is cancelled
+type fakeWatchStream struct {
+	grpc.ClientStream
+	ctx    context.Context
+	events []*pb.ClusterStateEvent
+	err    error
+}
+
+func (s *fakeWatchStream) Recv() (*pb.ClusterStateEvent, error) {
+	if len(s.events) > 0 {
+		event := s.events[0]
+		s.events = s.events[1:]
+		return event, nil
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	<-s.ctx.Done()
+	return nil, status.FromContextError(s.ctx.Err()).Err()
+}
+
+// fakeClusterStateSource serves successive cluster states and watch streams
+type fakeClusterStateSource struct {
+	mu       sync.Mutex
+	states   []*pb.ClusterStateResponse
+	streams  []*fakeWatchStream
+	watched  []int64 // from_version of each watch
+	fallback int     // lookups that reached the master
+}
+
+func (f *fakeClusterStateSource) GetClusterState(ctx context.Context, includeRouting, includeNodes, includeIndices bool) (*pb.ClusterStateResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	state := f.states[0]
+	if len(f.states) > 1 {
+		f.states = f.states[1:]
+	}
+	return state, nil
+}
+
+func (f *fakeClusterStateSource) GetIndexMetadata(ctx context.Context, indexName string) (*pb.IndexMetadataResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fallback++
+	return &pb.IndexMetadataResponse{Metadata: &pb.IndexMetadata{IndexName: indexName}}, nil
+}
+
+func (f *fakeClusterStateSource) GetShardRouting(ctx context.Context, indexName string) (map[int32]*pb.ShardRouting, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fallback++
+	return map[int32]*pb.ShardRouting{}, nil
+}
+
+func (f *fakeClusterStateSource) WatchClusterState(ctx context.Context, fromVersion int64) (pb.MasterService_WatchClusterStateClient, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.watched = append(f.watched, fromVersion)
+	stream := &fakeWatchStream{ctx: ctx}
+	if len(f.streams) > 0 {
+		stream = f.streams[0]
+		stream.ctx = ctx
+		f.streams = f.streams[1:]
+	}
+	return stream, nil
+}
+
+func testEvent(t *testing.T, version int64, eventType pb.ClusterStateEvent_EventType, payload proto.Message) *pb.ClusterStateEvent {
+	data, err := proto.Marshal(payload)
+	require.NoError(t, err)
+	return &pb.ClusterStateEvent{Version: version, Type: eventType, Payload: data}
+}
+
+func testShardEvent(t *testing.T, version int64, eventType pb.ClusterStateEvent_EventType, indexName string, shardID int32, nodeID string) *pb.ClusterStateEvent {
+	return testEvent(t, version, eventType, &pb.IndexRoutingTable{
+		IndexName: indexName,
+		Shards: map[int32]*pb.ShardRouting{
+			shardID: {
+				ShardId:    shardID,
+				IsPrimary:  true,
+				Allocation: &pb.ShardAllocation{NodeId: nodeID, State: pb.ShardAllocation_SHARD_STATE_STARTED},
+			},
+		},
+	})
+}
+
+func testClusterState(version int64, nodeIDs ...string) *pb.ClusterStateResponse {
+	state := &pb.ClusterStateResponse{
+		Version: version,
+		Indices: []*pb.IndexMetadata{
+			{IndexName: "logs", Settings: &pb.IndexSettings{NumberOfShards: 1}},
+		},
+		RoutingTable: &pb.RoutingTable{
+			Indices: map[string]*pb.IndexRoutingTable{
+				"logs": {
+					IndexName: "logs",
+					Shards: map[int32]*pb.ShardRouting{
+						0: {ShardId: 0, IsPrimary: true, Allocation: &pb.ShardAllocation{NodeId: "data-1"}},
+					},
+				},
+			},
+		},
+	}
+	for _, nodeID := range nodeIDs {
+		state.Nodes = append(state.Nodes, &pb.NodeInfo{NodeId: nodeID, NodeType: pb.NodeType_NODE_TYPE_DATA})
+	}
+	return state
+}
+
+func TestClusterStateCache_Apply(t *testing.T) {
+	ctx := context.Background()
+	master := &fakeClusterStateSource{states: []*pb.ClusterStateResponse{testClusterState(5, "data-1")}}
+	cache := NewClusterStateCache(master, zap.NewNop())
+
+	var changes []string
+	cache.OnNodeChange(func(node *pb.NodeInfo, removed bool) {
+		if removed {
+			changes = append(changes, "-"+node.NodeId)
+		} else {
+			changes = append(changes, "+"+node.NodeId)
+		}
+	})
+
+	require.NoError(t, cache.Load(ctx))
+	assert.Equal(t, int64(5), cache.Version())
+	assert.Equal(t, []string{"+data-1"}, changes)
+
+	metadata, err := cache.GetIndexMetadata(ctx, "logs")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), metadata.Metadata.Settings.NumberOfShards)
+
+	events := []*pb.ClusterStateEvent{
+		testEvent(t, 6, pb.ClusterStateEvent_EVENT_TYPE_NODE_JOINED, &pb.NodeInfo{NodeId: "data-2", NodeType: pb.NodeType_NODE_TYPE_DATA}),
+		testEvent(t, 7, pb.ClusterStateEvent_EVENT_TYPE_INDEX_CREATED, &pb.IndexMetadata{IndexName: "metrics", Settings: &pb.IndexSettings{NumberOfShards: 2}}),
+		testShardEvent(t, 8, pb.ClusterStateEvent_EVENT_TYPE_SHARD_ALLOCATED, "metrics", 0, "data-1"),
+		testShardEvent(t, 9, pb.ClusterStateEvent_EVENT_TYPE_SHARD_ALLOCATED, "metrics", 1, "data-1"),
+		testShardEvent(t, 10, pb.ClusterStateEvent_EVENT_TYPE_SHARD_RELOCATED, "metrics", 1, "data-2"),
+		testShardEvent(t, 11, pb.ClusterStateEvent_EVENT_TYPE_SHARD_DEALLOCATED, "logs", 0, "data-1"),
+		testEvent(t, 12, pb.ClusterStateEvent_EVENT_TYPE_NODE_LEFT, &pb.NodeInfo{NodeId: "data-1", NodeType: pb.NodeType_NODE_TYPE_DATA}),
+	}
+	for _, event := range events {
+		require.NoError(t, cache.Apply(event))
+	}
+	assert.Equal(t, int64(12), cache.Version())
+	assert.Equal(t, []string{"+data-1", "+data-2", "-data-1"}, changes)
+
+	routing, err := cache.GetShardRouting(ctx, "metrics")
+	require.NoError(t, err)
+	require.Len(t, routing, 2)
+	assert.Equal(t, "data-1", routing[0].Allocation.NodeId)
+	assert.Equal(t, "data-2", routing[1].Allocation.NodeId)
+
+	routing, err = cache.GetShardRouting(ctx, "logs")
+	require.NoError(t, err)
+	assert.Empty(t, routing)
+
+	// Events already reflected are ignored
+	require.NoError(t, cache.Apply(testEvent(t, 12, pb.ClusterStateEvent_EVENT_TYPE_INDEX_DELETED, &pb.IndexMetadata{IndexName: "metrics"})))
+	metadata, err = cache.GetIndexMetadata(ctx, "metrics")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), metadata.Metadata.Settings.NumberOfShards)
+
+	require.NoError(t, cache.Apply(testEvent(t, 13, pb.ClusterStateEvent_EVENT_TYPE_INDEX_DELETED, &pb.IndexMetadata{IndexName: "metrics"})))
+	assert.Equal(t, 0, master.fallback)
+
+	// An index the cache does not know is looked up on the master
+	_, err = cache.GetShardRouting(ctx, "metrics")
+	require.NoError(t, err)
+	assert.Equal(t, 1, master.fallback)
+
+	// A malformed payload is an error
+	assert.Error(t, cache.Apply(&pb.ClusterStateEvent{Version: 14, Type: pb.ClusterStateEvent_EVENT_TYPE_NODE_JOINED, Payload: []byte{0xff}}))
+}
+
+func testReplicaEvent(t *testing.T, version int64, eventType pb.ClusterStateEvent_EventType, indexName string, shardID int32, nodeID string) *pb.ClusterStateEvent {
+	return testEvent(t, version, eventType, &pb.IndexRoutingTable{
+		IndexName: indexName,
+		Shards: map[int32]*pb.ShardRouting{
+			shardID: {
+				ShardId:    shardID,
+				Allocation: &pb.ShardAllocation{NodeId: nodeID, State: pb.ShardAllocation_SHARD_STATE_STARTED},
+			},
+		},
+	})
+}
+
+func TestClusterStateCache_ApplyReplicas(t *testing.T) {
+	ctx := context.Background()
+	master := &fakeClusterStateSource{states: []*pb.ClusterStateResponse{testClusterState(5, "data-1")}}
+	cache := NewClusterStateCache(master, zap.NewNop())
+	require.NoError(t, cache.Load(ctx))
+
+	events := []*pb.ClusterStateEvent{
+		testReplicaEvent(t, 6, pb.ClusterStateEvent_EVENT_TYPE_SHARD_ALLOCATED, "logs", 0, "data-3"),
+		testReplicaEvent(t, 7, pb.ClusterStateEvent_EVENT_TYPE_SHARD_ALLOCATED, "logs", 0, "data-2"),
+		testShardEvent(t, 8, pb.ClusterStateEvent_EVENT_TYPE_SHARD_UPDATED, "logs", 0, "data-1"),
+	}
+	for _, event := range events {
+		require.NoError(t, cache.Apply(event))
+	}
+
+	routing, err := cache.GetShardRouting(ctx, "logs")
+	require.NoError(t, err)
+	shard := routing[0]
+	assert.Equal(t, "data-1", shard.Allocation.NodeId)
+	require.Len(t, shard.Replicas, 2)
+	assert.Equal(t, "data-2", shard.Replicas[0].NodeId)
+	assert.Equal(t, "data-3", shard.Replicas[1].NodeId)
+
+	// Routings already handed out are not modified
+	require.NoError(t, cache.Apply(testReplicaEvent(t, 9, pb.ClusterStateEvent_EVENT_TYPE_SHARD_DEALLOCATED, "logs", 0, "data-3")))
+	assert.Len(t, shard.Replicas, 2)
+
+	routing, err = cache.GetShardRouting(ctx, "logs")
+	require.NoError(t, err)
+	require.Len(t, routing[0].Replicas, 1)
+	assert.Equal(t, "data-2", routing[0].Replicas[0].NodeId)
+
+	// A shard whose primary is gone keeps its replicas
+	require.NoError(t, cache.Apply(testShardEvent(t, 10, pb.ClusterStateEvent_EVENT_TYPE_SHARD_DEALLOCATED, "logs", 0, "data-1")))
+	routing, err = cache.GetShardRouting(ctx, "logs")
+	require.NoError(t, err)
+	assert.Nil(t, routing[0].Allocation)
+	require.Len(t, routing[0].Replicas, 1)
+
+	require.NoError(t, cache.Apply(testReplicaEvent(t, 11, pb.ClusterStateEvent_EVENT_TYPE_SHARD_DEALLOCATED, "logs", 0, "data-2")))
+	routing, err = cache.GetShardRouting(ctx, "logs")
+	require.NoError(t, err)
+	assert.Empty(t, routing)
+}
+
+func TestClusterStateCache_LookupsBeforeLoad(t *testing.T) {
+	ctx := context.Background()
+	master := &fakeClusterStateSource{}
+	cache := NewClusterStateCache(master, zap.NewNop())
+
+	_, err := cache.GetIndexMetadata(ctx, "logs")
+	require.NoError(t, err)
+	_, err = cache.GetShardRouting(ctx, "logs")
+	require.NoError(t, err)
+	assert.Equal(t, 2, master.fallback)
+	assert.False(t, cache.Loaded())
+}
+
+func TestClusterStateCache_Run(t *testing.T) {
+	master := &fakeClusterStateSource{
+		states: []*pb.ClusterStateResponse{
+			testClusterState(5, "data-1"),
+			testClusterState(20, "data-2"),
+		},
+		streams: []*fakeWatchStream{
+			{
+				// The master loses the events after version 6
+				events: []*pb.ClusterStateEvent{
+					testEvent(t, 6, pb.ClusterStateEvent_EVENT_TYPE_INDEX_CREATED, &pb.IndexMetadata{IndexName: "metrics"}),
+				},
+				err: status.Error(codes.OutOfRange, "version 6 is not available"),
+			},
+		},
+	}
+	cache := NewClusterStateCache(master, zap.NewNop())
+
+	var mu sync.Mutex
+	var changes []string
+	cache.OnNodeChange(func(node *pb.NodeInfo, removed bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if removed {
+			changes = append(changes, "-"+node.NodeId)
+		} else {
+			changes = append(changes, "+"+node.NodeId)
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		cache.Run(ctx)
+		close(done)
+	}()
+
+	// Loads, watches from 5, reloads at 20 and watches from there
+	require.Eventually(t, func() bool {
+		master.mu.Lock()
+		defer master.mu.Unlock()
+		return len(master.watched) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not stop")
+	}
+
+	assert.Equal(t, []int64{5, 20}, master.watched)
+	assert.Equal(t, int64(20), cache.Version())
+	assert.Equal(t, []string{"+data-1", "+data-2", "-data-1"}, changes)
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -22,6 +25,8 @@ import (
 	"github.com/conjugate/conjugate/pkg/coordination/router"
 	"github.com/conjugate/conjugate/pkg/wasm"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // CoordinationNode represents a coordination node in the CONJUGATE cluster
@@ -31,6 +36,7 @@ type CoordinationNode struct {
 	ginRouter     *gin.Engine
 	httpServer    *http.Server
 	masterClient  *MasterClient
+	clusterState  *ClusterStateCache
 	queryExecutor *executor.QueryExecutor
 	queryPlanner  *planner.QueryPlanner
 	queryService  *QueryService // New: Complete planner pipeline
@@ -68,22 +74,26 @@ func NewCoordinationNode(cfg *config.CoordinationConfig, logger *zap.Logger) (*C
 	// Create master client
 	masterClient := NewMasterClient(cfg.MasterAddr, logger)
 
+	// Create the cluster state cache, kept current from the master's watch
+	// stream, which serves the routing and metadata lookups below
+	clusterState := NewClusterStateCache(masterClient, logger)
+
 	// Create data clients map
 	dataClients := make(map[string]*DataNodeClient)
 
 	// Create query executor
-	queryExecutor := executor.NewQueryExecutor(masterClient, logger)
+	queryExecutor := executor.NewQueryExecutor(clusterState, logger)
 
 	// Create query planner
-	queryPlanner := planner.NewQueryPlanner(masterClient, logger)
+	queryPlanner := planner.NewQueryPlanner(clusterState, logger)
 
 	// Create query service with complete planner pipeline
-	queryService := NewQueryService(queryExecutor, masterClient, logger)
+	queryService := NewQueryService(queryExecutor, clusterState, logger)
 
 	// Create document router
 	// We'll convert dataClients to the interface type needed by router
 	dataClientInterfaces := make(map[string]router.DataNodeClient)
-	docRouter := router.NewDocumentRouter(masterClient, dataClientInterfaces, logger)
+	docRouter := router.NewDocumentRouter(clusterState, dataClientInterfaces, logger)
 
 	// Initialize WASM runtime and UDF registry
 	wasmConfig := &wasm.Config{
@@ -130,6 +140,7 @@ func NewCoordinationNode(cfg *config.CoordinationConfig, logger *zap.Logger) (*C
 		logger:           logger,
 		ginRouter:        ginRouter,
 		masterClient:     masterClient,
+		clusterState:     clusterState,
 		queryExecutor:    queryExecutor,
 		queryPlanner:     queryPlanner,
 		queryService:     queryService,
@@ -143,6 +154,9 @@ func NewCoordinationNode(cfg *config.CoordinationConfig, logger *zap.Logger) (*C
 		pipelineExecutor: pipelineExecutor,
 	}
 
+	// Keep the data node clients in step with the cluster's nodes
+	clusterState.OnNodeChange(node.handleNodeChange)
+
 	// Set up routes
 	node.setupRoutes()
 
@@ -160,14 +174,14 @@ func (c *CoordinationNode) Start(ctx context.Context) error {
 		return fmt.Errorf("failed to connect to master: %w", err)
 	}
 
-	// Discover and register data nodes
-	if err := c.discoverDataNodes(ctx); err != nil {
-		c.logger.Warn("Failed to discover data nodes", zap.Error(err))
-		// Don't fail startup - data nodes can be discovered later
+	// Load the cluster state, registering the data nodes
+	if err := c.clusterState.Load(ctx); err != nil {
+		c.logger.Warn("Failed to load cluster state", zap.Error(err))
+		// Don't fail startup - the watch keeps retrying the load
 	}
 
-	// Start continuous data node discovery in background
-	go c.continuousDataNodeDiscovery(ctx)
+	// Keep the cluster state current from the master's watch stream
+	go c.clusterState.Run(ctx)
 
 	// Start HTTP server
 	c.httpServer = &http.Server{
@@ -506,8 +520,16 @@ func (c *CoordinationNode) handleCreateIndex(ctx *gin.Context) {
 		NumberOfReplicas: numReplicas,
 	}
 
-	// TODO: Parse mappings from body
-	var mappings map[string]*pb.FieldMapping
+	mappings, err := parseMappings(body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"type":   "mapper_parsing_exception",
+				"reason": err.Error(),
+			},
+		})
+		return
+	}
 
 	// Call master to create index
 	resp, err := c.masterClient.CreateIndex(ctx.Request.Context(), indexName, settings, mappings)
@@ -651,11 +673,130 @@ func (c *CoordinationNode) handleCloseIndex(ctx *gin.Context) {
 }
 
 func (c *CoordinationNode) handleRefreshIndex(ctx *gin.Context) {
-	ctx.JSON(http.StatusOK, gin.H{"_shards": gin.H{"total": 1, "successful": 1, "failed": 0}})
+	indexName := ctx.Param("index")
+
+	total, successful, err := c.refreshIndex(ctx.Request.Context(), indexName)
+	if err != nil {
+		c.logger.Error("Failed to get shard routing", zap.String("index", indexName), zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"type":   "refresh_failed_exception",
+				"reason": fmt.Sprintf("Failed to get shard routing: %v", err),
+			},
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"_shards": gin.H{
+			"total":      total,
+			"successful": successful,
+			"failed":     total - successful,
+		},
+	})
+}
+
+// refreshIndex refreshes every started shard of an index so that all
+// acknowledged writes become searchable. It returns the number of shards
+// and how many of them were refreshed.
+func (c *CoordinationNode) refreshIndex(ctx context.Context, indexName string) (int, int, error) {
+	routing, err := c.clusterState.GetShardRouting(ctx, indexName)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	successful := 0
+	for shardID, shard := range routing {
+		if shard.Allocation == nil || shard.Allocation.State != pb.ShardAllocation_SHARD_STATE_STARTED {
+			continue
+		}
+
+		nodeID := shard.Allocation.NodeId
+		c.dataClientsMu.RLock()
+		client, exists := c.dataClients[nodeID]
+		c.dataClientsMu.RUnlock()
+		if !exists {
+			c.logger.Warn("No client for data node", zap.String("node_id", nodeID))
+			continue
+		}
+
+		if _, err := client.RefreshShard(ctx, indexName, shardID); err != nil {
+			c.logger.Warn("Failed to refresh shard",
+				zap.String("index", indexName),
+				zap.Int32("shard_id", shardID),
+				zap.Error(err))
+			continue
+		}
+		successful++
+	}
+
+	return len(routing), successful, nil
 }
 
 func (c *CoordinationNode) handleFlushIndex(ctx *gin.Context) {
-	ctx.JSON(http.StatusOK, gin.H{"_shards": gin.H{"total": 1, "successful": 1, "failed": 0}})
+	indexName := ctx.Param("index")
+
+	routing, err := c.clusterState.GetShardRouting(ctx.Request.Context(), indexName)
+	if err != nil {
+		c.logger.Error("Failed to get shard routing", zap.String("index", indexName), zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"type":   "flush_failed_exception",
+				"reason": fmt.Sprintf("Failed to get shard routing: %v", err),
+			},
+		})
+		return
+	}
+
+	shardIDs := make([]int32, 0, len(routing))
+	for shardID := range routing {
+		shardIDs = append(shardIDs, shardID)
+	}
+	sort.Slice(shardIDs, func(i, j int) bool { return shardIDs[i] < shardIDs[j] })
+
+	// Flush every started shard and report its translog generation
+	successful := 0
+	shards := make([]gin.H, 0, len(shardIDs))
+	for _, shardID := range shardIDs {
+		shard := routing[shardID]
+		if shard.Allocation == nil || shard.Allocation.State != pb.ShardAllocation_SHARD_STATE_STARTED {
+			continue
+		}
+
+		nodeID := shard.Allocation.NodeId
+		c.dataClientsMu.RLock()
+		client, exists := c.dataClients[nodeID]
+		c.dataClientsMu.RUnlock()
+		if !exists {
+			c.logger.Warn("No client for data node", zap.String("node_id", nodeID))
+			continue
+		}
+
+		resp, err := client.FlushShard(ctx.Request.Context(), indexName, shardID)
+		if err != nil {
+			c.logger.Warn("Failed to flush shard",
+				zap.String("index", indexName),
+				zap.Int32("shard_id", shardID),
+				zap.Error(err))
+			continue
+		}
+
+		successful++
+		shards = append(shards, gin.H{
+			"shard":               shardID,
+			"node":                nodeID,
+			"translog_generation": resp.TranslogGeneration,
+		})
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"_shards": gin.H{
+			"total":      len(shardIDs),
+			"successful": successful,
+			"failed":     len(shardIDs) - successful,
+		},
+		"shards": shards,
+	})
 }
 
 func (c *CoordinationNode) handleGetMapping(ctx *gin.Context) {
@@ -677,6 +818,22 @@ func (c *CoordinationNode) handleGetSettings(ctx *gin.Context) {
 		"number_of_shards":   "1",
 		"number_of_replicas": "0",
 	}
+	if c.clusterState != nil {
+		if resp, err := c.clusterState.GetIndexMetadata(ctx.Request.Context(), indexName); err == nil && resp.Metadata.GetSettings() != nil {
+			settings := resp.Metadata.Settings
+			indexSettings["number_of_shards"] = strconv.Itoa(int(settings.NumberOfShards))
+			indexSettings["number_of_replicas"] = strconv.Itoa(int(settings.NumberOfReplicas))
+			if settings.RefreshInterval != "" {
+				indexSettings["refresh_interval"] = settings.RefreshInterval
+			}
+			if settings.CommitInterval != "" {
+				indexSettings["commit_interval"] = settings.CommitInterval
+			}
+			if settings.CommitBatchSize > 0 {
+				indexSettings["commit_batch_size"] = strconv.Itoa(int(settings.CommitBatchSize))
+			}
+		}
+	}
 
 	// Add pipeline settings if pipelines are associated
 	if queryPipeline, err := c.pipelineRegistry.GetPipelineForIndex(indexName, pipeline.PipelineTypeQuery); err == nil {
@@ -720,6 +877,20 @@ func (c *CoordinationNode) handlePutSettings(ctx *gin.Context) {
 		return
 	}
 
+	// Settings may be nested under "index" or given at the top level
+	indexSettings, _ := body["index"].(map[string]interface{})
+	values := make(map[string]interface{})
+	for _, source := range []map[string]interface{}{body, indexSettings} {
+		for _, name := range dynamicIndexSettingNames {
+			if value, ok := source[name]; ok {
+				values[name] = value
+			}
+		}
+	}
+	if len(values) > 0 && !c.updateDynamicSettings(ctx, indexName, values) {
+		return
+	}
+
 	// Extract pipeline settings
 	if settingsMap, ok := body["index"].(map[string]interface{}); ok {
 		// Update query pipeline
@@ -792,11 +963,232 @@ func (c *CoordinationNode) handlePutSettings(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"acknowledged": true})
 }
 
+// dynamicIndexSettingNames are the index settings that can change on an
+// existing index, through the master
+var dynamicIndexSettingNames = []string{"number_of_replicas", "refresh_interval", "commit_interval", "commit_batch_size"}
+
+// updateDynamicSettings applies dynamic settings on top of the index's
+// current settings and sends them to the master, writing the error response
+// and returning false if that fails
+func (c *CoordinationNode) updateDynamicSettings(ctx *gin.Context, indexName string, values map[string]interface{}) bool {
+	metadata, err := c.masterClient.GetIndexMetadata(ctx.Request.Context(), indexName)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"type":   "index_not_found_exception",
+				"reason": fmt.Sprintf("Index %s not found: %v", indexName, err),
+			},
+		})
+		return false
+	}
+
+	settings, err := mergeIndexSettings(metadata.Metadata.GetSettings(), values)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"type":   "illegal_argument_exception",
+				"reason": err.Error(),
+			},
+		})
+		return false
+	}
+
+	if _, err := c.masterClient.UpdateIndexSettings(ctx.Request.Context(), indexName, settings); err != nil {
+		c.logger.Error("Failed to update index settings", zap.String("index", indexName), zap.Error(err))
+		code, errorType := http.StatusInternalServerError, "update_settings_exception"
+		switch status.Code(err) {
+		case codes.NotFound:
+			code, errorType = http.StatusNotFound, "index_not_found_exception"
+		case codes.InvalidArgument:
+			code, errorType = http.StatusBadRequest, "illegal_argument_exception"
+		}
+		ctx.JSON(code, gin.H{
+			"error": gin.H{
+				"type":   errorType,
+				"reason": fmt.Sprintf("Failed to update index settings: %v", err),
+			},
+		})
+		return false
+	}
+
+	c.logger.Info("Updated index settings",
+		zap.String("index", indexName),
+		zap.Any("settings", values))
+	return true
+}
+
+// mergeIndexSettings returns the current settings with the given dynamic
+// settings applied. Numbers may be given as JSON numbers or strings.
+func mergeIndexSettings(current *pb.IndexSettings, values map[string]interface{}) (*pb.IndexSettings, error) {
+	settings := &pb.IndexSettings{}
+	if current != nil {
+		settings = &pb.IndexSettings{
+			NumberOfShards:   current.NumberOfShards,
+			NumberOfReplicas: current.NumberOfReplicas,
+			RefreshInterval:  current.RefreshInterval,
+			CommitInterval:   current.CommitInterval,
+			CommitBatchSize:  current.CommitBatchSize,
+		}
+	}
+
+	for name, value := range values {
+		switch name {
+		case "number_of_replicas", "commit_batch_size":
+			n, err := settingInt(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", name, err)
+			}
+			if name == "number_of_replicas" {
+				settings.NumberOfReplicas = n
+			} else {
+				settings.CommitBatchSize = n
+			}
+		case "refresh_interval", "commit_interval":
+			str, ok := value.(string)
+			if n, isNumber := value.(float64); isNumber && n == -1 && name == "refresh_interval" {
+				str, ok = "-1", true
+			}
+			if !ok {
+				return nil, fmt.Errorf("invalid %s: expected a duration string, got %v", name, value)
+			}
+			if name == "refresh_interval" {
+				settings.RefreshInterval = str
+			} else {
+				settings.CommitInterval = str
+			}
+		}
+	}
+
+	return settings, nil
+}
+
+// settingInt reads an integer setting given as a JSON number or a string
+func settingInt(value interface{}) (int32, error) {
+	switch v := value.(type) {
+	case float64:
+		if v != float64(int32(v)) {
+			return 0, fmt.Errorf("expected an integer, got %v", v)
+		}
+		return int32(v), nil
+	case string:
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("expected an integer, got %q", v)
+		}
+		return int32(n), nil
+	default:
+		return 0, fmt.Errorf("expected an integer, got %v", value)
+	}
+}
+
+// refreshParam reads the refresh parameter of a write request. A bare
+// ?refresh means "true"; "false" and an absent parameter both map to "".
+func refreshParam(ctx *gin.Context) (string, error) {
+	value, present := ctx.GetQuery("refresh")
+	if !present {
+		return "", nil
+	}
+
+	switch value {
+	case "", "true":
+		return "true", nil
+	case "false":
+		return "", nil
+	case "wait_for":
+		return "wait_for", nil
+	}
+	return "", fmt.Errorf("unknown value for refresh: [%s], expected [true], [false] or [wait_for]", value)
+}
+
+// waitForActiveShardsParam reads the wait_for_active_shards parameter of a
+// write request: "all" or a number of shard copies. The data node checks the
+// number against the index's copies; an absent parameter maps to "".
+func waitForActiveShardsParam(ctx *gin.Context) (string, error) {
+	value := ctx.Query("wait_for_active_shards")
+	if value == "" || value == "all" {
+		return value, nil
+	}
+	if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+		return value, nil
+	}
+	return "", fmt.Errorf("unknown value for wait_for_active_shards: [%s], expected [all] or a non-negative number", value)
+}
+
+// writeParams reads the refresh and wait_for_active_shards parameters of a
+// write request
+func writeParams(ctx *gin.Context) (refresh, waitForActiveShards string, err error) {
+	if refresh, err = refreshParam(ctx); err != nil {
+		return "", "", err
+	}
+	if waitForActiveShards, err = waitForActiveShardsParam(ctx); err != nil {
+		return "", "", err
+	}
+	return refresh, waitForActiveShards, nil
+}
+
+// respondInvalidWriteParam reports an invalid refresh or
+// wait_for_active_shards parameter
+func respondInvalidWriteParam(ctx *gin.Context, err error) {
+	ctx.JSON(http.StatusBadRequest, gin.H{
+		"error": gin.H{
+			"type":   "illegal_argument_exception",
+			"reason": err.Error(),
+		},
+	})
+}
+
+// writeFailure returns the HTTP status and error type of a failed write,
+// defaulting to a server error of errorType
+func writeFailure(err error, errorType string) (int, string) {
+	switch status.Code(err) {
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable, "unavailable_shards_exception"
+	case codes.InvalidArgument:
+		return http.StatusBadRequest, "illegal_argument_exception"
+	}
+	return http.StatusInternalServerError, errorType
+}
+
+// addWriteResult adds the sequence number and primary term a write was
+// assigned, and the shard copies it reached, to a response body
+func addWriteResult(body gin.H, seqNo, primaryTerm int64, shards *pb.WriteShardsInfo) {
+	if seqNo > 0 {
+		body["_seq_no"] = seqNo
+		body["_primary_term"] = primaryTerm
+	}
+	if shards != nil {
+		body["_shards"] = gin.H{
+			"total":      shards.Total,
+			"successful": shards.Successful,
+			"failed":     shards.Failed,
+		}
+	}
+}
+
+// setBulkWriteResult is addWriteResult for a bulk item
+func setBulkWriteResult(item *bulk.BulkItemResult, seqNo, primaryTerm int64, shards *pb.WriteShardsInfo) {
+	item.SeqNo = seqNo
+	item.PrimaryTerm = primaryTerm
+	if shards != nil {
+		item.Shards = &bulk.BulkItemShards{
+			Total:      shards.Total,
+			Successful: shards.Successful,
+			Failed:     shards.Failed,
+		}
+	}
+}
+
 func (c *CoordinationNode) handleIndexDocument(ctx *gin.Context) {
 	c.logger.Info("==> handleIndexDocument ENTRY POINT")
 	indexName := ctx.Param("index")
 	docID := ctx.Param("id")
 
+	refresh, waitForActiveShards, err := writeParams(ctx)
+	if err != nil {
+		respondInvalidWriteParam(ctx, err)
+		return
+	}
+
 	c.logger.Info("handleIndexDocument called",
 		zap.String("index", indexName),
 		zap.String("doc_id", docID),
@@ -832,16 +1224,17 @@ func (c *CoordinationNode) handleIndexDocument(ctx *gin.Context) {
 		zap.String("doc_id", docID))
 
 	// Route to appropriate data node
-	resp, err := c.docRouter.RouteIndexDocument(ctx.Request.Context(), indexName, docID, document)
+	resp, err := c.docRouter.RouteIndexDocument(ctx.Request.Context(), indexName, docID, document, refresh, waitForActiveShards)
 	if err != nil {
 		c.logger.Error("Failed to index document",
 			zap.String("index", indexName),
 			zap.String("doc_id", docID),
 			zap.Error(err))
 
-		ctx.JSON(http.StatusInternalServerError, gin.H{
+		code, errorType := writeFailure(err, "index_failed_exception")
+		ctx.JSON(code, gin.H{
 			"error": gin.H{
-				"type":   "index_failed_exception",
+				"type":   errorType,
 				"reason": fmt.Sprintf("Failed to index document: %v", err),
 			},
 		})
@@ -856,13 +1249,17 @@ func (c *CoordinationNode) handleIndexDocument(ctx *gin.Context) {
 		statusCode = http.StatusOK
 	}
 
-	ctx.JSON(statusCode, gin.H{
+	body := gin.H{
 		"_index":   indexName,
 		"_id":      docID,
 		"_version": resp.Version,
 		"result":   result,
-		// TODO: Add shard information once proto is updated with Shards field
-	})
+	}
+	addWriteResult(body, resp.SeqNo, resp.PrimaryTerm, resp.Shards)
+	if refresh == "true" {
+		body["forced_refresh"] = true
+	}
+	ctx.JSON(statusCode, body)
 }
 
 func (c *CoordinationNode) handleGetDocument(ctx *gin.Context) {
@@ -910,8 +1307,14 @@ func (c *CoordinationNode) handleDeleteDocument(ctx *gin.Context) {
 	indexName := ctx.Param("index")
 	docID := ctx.Param("id")
 
+	refresh, waitForActiveShards, err := writeParams(ctx)
+	if err != nil {
+		respondInvalidWriteParam(ctx, err)
+		return
+	}
+
 	// Route to appropriate data node
-	resp, err := c.docRouter.RouteDeleteDocument(ctx.Request.Context(), indexName, docID)
+	resp, err := c.docRouter.RouteDeleteDocument(ctx.Request.Context(), indexName, docID, refresh, waitForActiveShards)
 	if err != nil {
 		c.logger.Error("Failed to delete document",
 			zap.String("index", indexName),
@@ -928,9 +1331,10 @@ func (c *CoordinationNode) handleDeleteDocument(ctx *gin.Context) {
 			return
 		}
 
-		ctx.JSON(http.StatusInternalServerError, gin.H{
+		code, errorType := writeFailure(err, "delete_failed_exception")
+		ctx.JSON(code, gin.H{
 			"error": gin.H{
-				"type":   "delete_failed_exception",
+				"type":   errorType,
 				"reason": fmt.Sprintf("Failed to delete document: %v", err),
 			},
 		})
@@ -938,17 +1342,24 @@ func (c *CoordinationNode) handleDeleteDocument(ctx *gin.Context) {
 	}
 
 	// Return success response
+	statusCode := http.StatusOK
 	result := "deleted"
 	if !resp.Found {
+		statusCode = http.StatusNotFound
 		result = "not_found"
 	}
-	ctx.JSON(http.StatusOK, gin.H{
+	body := gin.H{
 		"_index": indexName,
 		"_id":    docID,
 		"result": result,
 		"found":  resp.Found,
-		// TODO: Add version and shard information once proto is updated
-	})
+		// TODO: Add version information once documents are versioned
+	}
+	addWriteResult(body, resp.SeqNo, resp.PrimaryTerm, resp.Shards)
+	if refresh == "true" && resp.Found {
+		body["forced_refresh"] = true
+	}
+	ctx.JSON(statusCode, body)
 }
 
 // executeDocumentPipeline executes the document pipeline for an index if configured
@@ -1003,6 +1414,12 @@ func (c *CoordinationNode) handleUpdateDocument(ctx *gin.Context) {
 	indexName := ctx.Param("index")
 	docID := ctx.Param("id")
 
+	refresh, waitForActiveShards, err := writeParams(ctx)
+	if err != nil {
+		respondInvalidWriteParam(ctx, err)
+		return
+	}
+
 	// Parse update request body
 	var updateReq struct {
 		Doc            map[string]interface{} `json:"doc"`
@@ -1034,16 +1451,17 @@ func (c *CoordinationNode) handleUpdateDocument(ctx *gin.Context) {
 	}
 
 	// Route to appropriate data node
-	resp, err := c.docRouter.RouteIndexDocument(ctx.Request.Context(), indexName, docID, document)
+	resp, err := c.docRouter.RouteIndexDocument(ctx.Request.Context(), indexName, docID, document, refresh, waitForActiveShards)
 	if err != nil {
 		c.logger.Error("Failed to update document",
 			zap.String("index", indexName),
 			zap.String("doc_id", docID),
 			zap.Error(err))
 
-		ctx.JSON(http.StatusInternalServerError, gin.H{
+		code, errorType := writeFailure(err, "update_failed_exception")
+		ctx.JSON(code, gin.H{
 			"error": gin.H{
-				"type":   "update_failed_exception",
+				"type":   errorType,
 				"reason": fmt.Sprintf("Failed to update document: %v", err),
 			},
 		})
@@ -1051,18 +1469,35 @@ func (c *CoordinationNode) handleUpdateDocument(ctx *gin.Context) {
 	}
 
 	// Return success response
-	ctx.JSON(http.StatusOK, gin.H{
+	body := gin.H{
 		"_index":   indexName,
 		"_id":      docID,
 		"_version": resp.Version,
 		"result":   "updated",
-		// TODO: Add shard information once proto is updated with Shards field
-	})
+	}
+	addWriteResult(body, resp.SeqNo, resp.PrimaryTerm, resp.Shards)
+	if refresh == "true" {
+		body["forced_refresh"] = true
+	}
+	ctx.JSON(http.StatusOK, body)
 }
 
 func (c *CoordinationNode) handleBulk(ctx *gin.Context) {
 	startTime := time.Now()
 
+	refresh, waitForActiveShards, err := writeParams(ctx)
+	if err != nil {
+		respondInvalidWriteParam(ctx, err)
+		return
+	}
+
+	// refresh=true refreshes each index once after all operations;
+	// wait_for is handled per operation by the data nodes
+	opRefresh := refresh
+	if refresh == "true" {
+		opRefresh = ""
+	}
+
 	// Read request body
 	body, err := io.ReadAll(ctx.Request.Body)
 	if err != nil {
@@ -1107,7 +1542,7 @@ func (c *CoordinationNode) handleBulk(ctx *gin.Context) {
 			defer func() { <-semaphore }()
 
 			// Execute operation
-			result := c.executeBulkOperation(ctx.Request.Context(), operation)
+			result := c.executeBulkOperation(ctx.Request.Context(), operation, opRefresh, waitForActiveShards)
 			results[idx] = result
 		}(i, op)
 	}
@@ -1115,6 +1550,21 @@ func (c *CoordinationNode) handleBulk(ctx *gin.Context) {
 	// Wait for all operations to complete
 	wg.Wait()
 
+	if refresh == "true" {
+		refreshed := make(map[string]bool)
+		for _, op := range bulkReq.Operations {
+			if op.Index == "" || refreshed[op.Index] {
+				continue
+			}
+			refreshed[op.Index] = true
+			if _, _, err := c.refreshIndex(ctx.Request.Context(), op.Index); err != nil {
+				c.logger.Warn("Failed to refresh index after bulk",
+					zap.String("index", op.Index),
+					zap.Error(err))
+			}
+		}
+	}
+
 	// Build response maintaining order
 	for i, result := range results {
 		response.AddItem(bulkReq.Operations[i].Type, result.itemResult)
@@ -1135,8 +1585,9 @@ type bulkOperationResult struct {
 	itemResult *bulk.BulkItemResult
 }
 
-// executeBulkOperation executes a single bulk operation
-func (c *CoordinationNode) executeBulkOperation(ctx context.Context, op *bulk.BulkOperation) *bulkOperationResult {
+// executeBulkOperation executes a single bulk operation with the given
+// refresh policy and wait_for_active_shards
+func (c *CoordinationNode) executeBulkOperation(ctx context.Context, op *bulk.BulkOperation, refresh, waitForActiveShards string) *bulkOperationResult {
 	result := &bulkOperationResult{
 		itemResult: &bulk.BulkItemResult{
 			Index: op.Index,
@@ -1147,16 +1598,17 @@ func (c *CoordinationNode) executeBulkOperation(ctx context.Context, op *bulk.Bu
 	switch op.Type {
 	case bulk.OperationIndex, bulk.OperationCreate:
 		// Index or create document
-		resp, err := c.docRouter.RouteIndexDocument(ctx, op.Index, op.ID, op.Document)
+		resp, err := c.docRouter.RouteIndexDocument(ctx, op.Index, op.ID, op.Document, refresh, waitForActiveShards)
 		if err != nil {
 			c.logger.Error("Bulk index operation failed",
 				zap.String("index", op.Index),
 				zap.String("doc_id", op.ID),
 				zap.Error(err))
 
-			result.itemResult.Status = http.StatusInternalServerError
+			code, errorType := writeFailure(err, "index_failed_exception")
+			result.itemResult.Status = code
 			result.itemResult.Error = &bulk.BulkItemError{
-				Type:   "index_failed_exception",
+				Type:   errorType,
 				Reason: err.Error(),
 			}
 		} else {
@@ -1168,12 +1620,7 @@ func (c *CoordinationNode) executeBulkOperation(ctx context.Context, op *bulk.Bu
 				result.itemResult.Result = "created"
 			}
 			result.itemResult.Version = resp.Version
-			// TODO: Add shard information once proto is updated with Shards field
-			// result.itemResult.Shards = &bulk.BulkItemShards{
-			// 	Total:      1,
-			// 	Successful: 1,
-			// 	Failed:     0,
-			// }
+			setBulkWriteResult(result.itemResult, resp.SeqNo, resp.PrimaryTerm, resp.Shards)
 		}
 
 	case bulk.OperationUpdate:
@@ -1183,33 +1630,29 @@ func (c *CoordinationNode) executeBulkOperation(ctx context.Context, op *bulk.Bu
 			document = op.Document
 		}
 
-		resp, err := c.docRouter.RouteIndexDocument(ctx, op.Index, op.ID, document)
+		resp, err := c.docRouter.RouteIndexDocument(ctx, op.Index, op.ID, document, refresh, waitForActiveShards)
 		if err != nil {
 			c.logger.Error("Bulk update operation failed",
 				zap.String("index", op.Index),
 				zap.String("doc_id", op.ID),
 				zap.Error(err))
 
-			result.itemResult.Status = http.StatusInternalServerError
+			code, errorType := writeFailure(err, "update_failed_exception")
+			result.itemResult.Status = code
 			result.itemResult.Error = &bulk.BulkItemError{
-				Type:   "update_failed_exception",
+				Type:   errorType,
 				Reason: err.Error(),
 			}
 		} else {
 			result.itemResult.Status = http.StatusOK
 			result.itemResult.Result = "updated"
 			result.itemResult.Version = resp.Version
-			// TODO: Add shard information once proto is updated with Shards field
-			// result.itemResult.Shards = &bulk.BulkItemShards{
-			// 	Total:      1,
-			// 	Successful: 1,
-			// 	Failed:     0,
-			// }
+			setBulkWriteResult(result.itemResult, resp.SeqNo, resp.PrimaryTerm, resp.Shards)
 		}
 
 	case bulk.OperationDelete:
 		// Delete document
-		resp, err := c.docRouter.RouteDeleteDocument(ctx, op.Index, op.ID)
+		resp, err := c.docRouter.RouteDeleteDocument(ctx, op.Index, op.ID, refresh, waitForActiveShards)
 		if err != nil {
 			c.logger.Error("Bulk delete operation failed",
 				zap.String("index", op.Index),
@@ -1221,9 +1664,10 @@ func (c *CoordinationNode) executeBulkOperation(ctx context.Context, op *bulk.Bu
 				result.itemResult.Status = http.StatusNotFound
 				result.itemResult.Result = "not_found"
 			} else {
-				result.itemResult.Status = http.StatusInternalServerError
+				code, errorType := writeFailure(err, "delete_failed_exception")
+				result.itemResult.Status = code
 				result.itemResult.Error = &bulk.BulkItemError{
-					Type:   "delete_failed_exception",
+					Type:   errorType,
 					Reason: err.Error(),
 				}
 			}
@@ -1236,12 +1680,8 @@ func (c *CoordinationNode) executeBulkOperation(ctx context.Context, op *bulk.Bu
 				result.itemResult.Status = http.StatusOK
 				result.itemResult.Result = "deleted"
 			}
-			// TODO: Add version and shard information once proto is updated
-			// result.itemResult.Shards = &bulk.BulkItemShards{
-			// 	Total:      1,
-			// 	Successful: 1,
-			// 	Failed:     0,
-			// }
+			// TODO: Add version information once documents are versioned
+			setBulkWriteResult(result.itemResult, resp.SeqNo, resp.PrimaryTerm, resp.Shards)
 		}
 
 	default:
@@ -1323,11 +1763,15 @@ func (c *CoordinationNode) convertSearchResultToResponse(result *SearchResult) g
 	// Convert hits
 	hits := make([]gin.H, 0, len(result.Hits))
 	for _, hit := range result.Hits {
-		hits = append(hits, gin.H{
+		hitData := gin.H{
 			"_id":     hit.ID,
 			"_score":  hit.Score,
 			"_source": hit.Source,
-		})
+		}
+		if hit.Sort != nil {
+			hitData["sort"] = hit.Sort
+		}
+		hits = append(hits, hitData)
 	}
 
 	response := gin.H{
@@ -1366,37 +1810,108 @@ func (c *CoordinationNode) convertAggregationToResponse(agg *AggregationResult)
 	result := gin.H{}
 
 	switch agg.Type {
-	case "terms", "histogram", "date_histogram":
-		// Bucket aggregations
+	case "terms", "histogram", "date_histogram", "composite":
+		// Bucket aggregations; composite keys are objects of source values
 		buckets := make([]gin.H, 0, len(agg.Buckets))
 		for _, bucket := range agg.Buckets {
 			bucketData := gin.H{
 				"key":       bucket.Key,
 				"doc_count": bucket.DocCount,
 			}
-
-			// Add sub-aggregations if present
-			if len(bucket.SubAggs) > 0 {
-				for subName, subAgg := range bucket.SubAggs {
-					bucketData[subName] = c.convertAggregationToResponse(subAgg)
-				}
+			if bucket.KeyAsString != "" {
+				bucketData["key_as_string"] = bucket.KeyAsString
 			}
+			c.addSubAggregationsToResponse(bucketData, bucket)
+			buckets = append(buckets, bucketData)
+		}
+		result["buckets"] = buckets
+		if agg.Type == "terms" {
+			result["doc_count_error_upper_bound"] = agg.DocCountErrorUpperBound
+			result["sum_other_doc_count"] = agg.SumOtherDocCount
+		}
+		if agg.Type == "composite" && agg.AfterKey != nil {
+			result["after_key"] = agg.AfterKey
+		}
 
+	case "range":
+		// Range buckets keep request order and carry their bounds
+		buckets := make([]gin.H, 0, len(agg.Buckets))
+		for _, bucket := range agg.Buckets {
+			bucketData := gin.H{
+				"key":       bucket.Key,
+				"doc_count": bucket.DocCount,
+			}
+			if bucket.From != nil {
+				bucketData["from"] = *bucket.From
+			}
+			if bucket.To != nil {
+				bucketData["to"] = *bucket.To
+			}
+			c.addSubAggregationsToResponse(bucketData, bucket)
 			buckets = append(buckets, bucketData)
 		}
 		result["buckets"] = buckets
 
-	case "stats", "extended_stats":
+	case "filters":
+		// Filters buckets are keyed by filter name
+		buckets := make(gin.H, len(agg.Buckets))
+		for _, bucket := range agg.Buckets {
+			bucketData := gin.H{
+				"doc_count": bucket.DocCount,
+			}
+			c.addSubAggregationsToResponse(bucketData, bucket)
+			buckets[fmt.Sprintf("%v", bucket.Key)] = bucketData
+		}
+		result["buckets"] = buckets
+
+	case "stats", "extended_stats", "stats_bucket":
 		// Stats aggregations
 		result["count"] = agg.Count
 		result["min"] = agg.Min
 		result["max"] = agg.Max
 		result["avg"] = agg.Avg
 		result["sum"] = agg.Sum
+		if agg.Count == 0 {
+			result["min"], result["max"], result["avg"] = nil, nil, nil
+		}
 
-	case "sum", "avg", "min", "max", "cardinality":
-		// Single-value aggregations
-		result["value"] = agg.Value
+		if agg.Type == "extended_stats" {
+			result["sum_of_squares"] = agg.SumOfSquares
+			result["variance"] = agg.Variance
+			result["std_deviation"] = agg.StdDeviation
+			result["std_deviation_bounds"] = gin.H{
+				"upper": agg.StdDeviationBoundsUpper,
+				"lower": agg.StdDeviationBoundsLower,
+			}
+		}
+
+	case "percentiles", "percentile_ranks":
+		values := make(gin.H, len(agg.Values))
+		for percent, value := range agg.Values {
+			values[percent] = value
+		}
+		result["values"] = values
+
+	case "sum", "avg", "min", "max", "cardinality", "value_count":
+		// Single-value aggregations; avg, min and max without values are null
+		result["value"] = nil
+		if !math.IsNaN(agg.Value) {
+			result["value"] = agg.Value
+		}
+
+	case "derivative", "cumulative_sum", "moving_fn", "serial_diff", "bucket_script",
+		"avg_bucket", "max_bucket", "min_bucket", "sum_bucket":
+		// Pipeline aggregations; a pipeline without a value renders null
+		result["value"] = nil
+		if !math.IsNaN(agg.Value) && !math.IsInf(agg.Value, 0) {
+			result["value"] = agg.Value
+		}
+		if agg.NormalizedValue != nil && !math.IsNaN(*agg.NormalizedValue) && !math.IsInf(*agg.NormalizedValue, 0) {
+			result["normalized_value"] = *agg.NormalizedValue
+		}
+		if agg.Type == "max_bucket" || agg.Type == "min_bucket" {
+			result["keys"] = agg.Keys
+		}
 
 	default:
 		// Unknown aggregation type - return as-is
@@ -1406,6 +1921,14 @@ func (c *CoordinationNode) convertAggregationToResponse(agg *AggregationResult)
 	return result
 }
 
+// addSubAggregationsToResponse renders a bucket's sub-aggregations as named
+// fields of the bucket, as OpenSearch does
+func (c *CoordinationNode) addSubAggregationsToResponse(bucketData gin.H, bucket *AggregationBucket) {
+	for subName, subAgg := range bucket.SubAggs {
+		bucketData[subName] = c.convertAggregationToResponse(subAgg)
+	}
+}
+
 func (c *CoordinationNode) handleMultiSearch(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{
 		"responses": []gin.H{},
@@ -1505,145 +2028,58 @@ func (c *CoordinationNode) handleHealthCheck(ctx *gin.Context) {
 	})
 }
 
-// discoverDataNodes discovers data nodes from master and registers them with query executor
-func (c *CoordinationNode) discoverDataNodes(ctx context.Context) error {
-	c.logger.Info("Discovering data nodes from master")
-
-	// Get cluster state from master
-	state, err := c.masterClient.GetClusterState(ctx, false, true, false)
-	if err != nil {
-		return fmt.Errorf("failed to get cluster state: %w", err)
-	}
-
-	// Register data node clients
-	dataNodeCount := 0
-	dataClientInterfaces := make(map[string]router.DataNodeClient)
-
-	for _, node := range state.Nodes {
-		if node.NodeType == pb.NodeType_NODE_TYPE_DATA {
-			// Construct data node address
-			address := fmt.Sprintf("%s:%d", node.BindAddr, node.GrpcPort)
-
-			// Create data node client
-			dataClient := NewDataNodeClient(node.NodeId, address, c.logger)
-
-			// Store in coordination node
-			c.dataClientsMu.Lock()
-			c.dataClients[node.NodeId] = dataClient
-			c.dataClientsMu.Unlock()
-
-			// Register with query executor
-			c.queryExecutor.RegisterDataNode(dataClient)
-
-			// Add to interface map for document router
-			dataClientInterfaces[node.NodeId] = dataClient
-
-			c.logger.Info("Registered data node",
-				zap.String("node_id", node.NodeId),
-				zap.String("address", address))
-			dataNodeCount++
-		}
-	}
-
-	// Update document router with data clients
-	c.docRouter.SetDataClients(dataClientInterfaces)
-
-	c.logger.Info("Data node discovery complete",
-		zap.Int("data_nodes", dataNodeCount))
-
-	return nil
-}
-
-// continuousDataNodeDiscovery periodically discovers new data nodes joining the cluster
-func (c *CoordinationNode) continuousDataNodeDiscovery(ctx context.Context) {
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
-
-	c.logger.Info("Starting continuous data node discovery (every 30s)")
-
-	for {
-		select {
-		case <-ctx.Done():
-			c.logger.Info("Stopping continuous data node discovery")
-			return
-		case <-ticker.C:
-			c.refreshDataNodeClients(ctx)
-		}
+// handleNodeChange registers a data node client for each data node that
+// joins the cluster and removes the client of each that leaves
+func (c *CoordinationNode) handleNodeChange(node *pb.NodeInfo, removed bool) {
+	if node.NodeType != pb.NodeType_NODE_TYPE_DATA {
+		return
 	}
-}
 
-// refreshDataNodeClients discovers new data nodes and registers them with the query executor
-func (c *CoordinationNode) refreshDataNodeClients(ctx context.Context) {
-	c.logger.Debug("Refreshing data node clients")
+	nodeID := node.NodeId
+	address := fmt.Sprintf("%s:%d", node.BindAddr, node.GrpcPort)
 
-	// Get cluster state from master
-	state, err := c.masterClient.GetClusterState(ctx, false, true, false)
-	if err != nil {
-		c.logger.Error("Failed to get cluster state for refresh", zap.Error(err))
+	c.dataClientsMu.Lock()
+	existing, exists := c.dataClients[nodeID]
+	switch {
+	case removed && !exists:
+		c.dataClientsMu.Unlock()
 		return
+	case removed:
+		delete(c.dataClients, nodeID)
+	case exists && existing.address == address:
+		// Already registered at this address
+		c.dataClientsMu.Unlock()
+		return
+	default:
+		c.dataClients[nodeID] = NewDataNodeClient(nodeID, address, c.logger)
 	}
+	dataClient := c.dataClients[nodeID]
+	dataClientInterfaces := make(map[string]router.DataNodeClient, len(c.dataClients))
+	for id, client := range c.dataClients {
+		dataClientInterfaces[id] = client
+	}
+	c.dataClientsMu.Unlock()
 
-	// Track newly discovered nodes
-	newNodes := 0
-
-	// Register any new data node clients
-	for _, node := range state.Nodes {
-		if node.NodeType != pb.NodeType_NODE_TYPE_DATA {
-			continue
-		}
-
-		nodeID := node.NodeId
-
-		// Check if client already exists
-		c.dataClientsMu.RLock()
-		_, exists := c.dataClients[nodeID]
-		c.dataClientsMu.RUnlock()
-
-		if exists {
-			continue // Already registered
-		}
-
-		// Construct data node address
-		address := fmt.Sprintf("%s:%d", node.BindAddr, node.GrpcPort)
-
-		// Create data node client
-		dataClient := NewDataNodeClient(nodeID, address, c.logger)
-
-		// Connect to the new data node
-		if err := dataClient.Connect(ctx); err != nil {
-			c.logger.Error("Failed to connect to new data node",
+	// Close the client this one replaces, or of the node that left
+	if exists {
+		if err := existing.Disconnect(); err != nil {
+			c.logger.Warn("Failed to disconnect data node client",
 				zap.String("node_id", nodeID),
-				zap.String("address", address),
 				zap.Error(err))
-			continue
 		}
+	}
 
-		// Store in coordination node
-		c.dataClientsMu.Lock()
-		c.dataClients[nodeID] = dataClient
-		c.dataClientsMu.Unlock()
-
-		// Register with query executor
+	// Update query executor and document router
+	if removed {
+		c.queryExecutor.UnregisterDataNode(nodeID)
+		c.logger.Info("Removed data node", zap.String("node_id", nodeID))
+	} else {
 		c.queryExecutor.RegisterDataNode(dataClient)
-
-		// Update document router
-		c.dataClientsMu.RLock()
-		dataClientInterfaces := make(map[string]router.DataNodeClient)
-		for id, client := range c.dataClients {
-			dataClientInterfaces[id] = client
-		}
-		c.dataClientsMu.RUnlock()
-		c.docRouter.SetDataClients(dataClientInterfaces)
-
-		c.logger.Info("Registered new data node",
+		c.logger.Info("Registered data node",
 			zap.String("node_id", nodeID),
 			zap.String("address", address))
-		newNodes++
-	}
-
-	if newNodes > 0 {
-		c.logger.Info("Discovered new data nodes", zap.Int("count", newNodes))
 	}
+	c.docRouter.SetDataClients(dataClientInterfaces)
 }
 
 // ginLogger creates a Gin middleware that logs requests using zap
@@ -96,8 +96,10 @@ func (dc *DataNodeClient) IsConnected() bool {
 	return dc.connected
 }
 
-// Search executes a search query on a specific shard
-func (dc *DataNodeClient) Search(ctx context.Context, indexName string, shardID int32, query []byte, filterExpression []byte) (*pb.SearchResponse, error) {
+// Search executes a search query on a specific shard, returning hits
+// [from, from+size) of the shard's ranking and its aggregation results.
+// Non-empty sort clauses rank hits by field values instead of score.
+func (dc *DataNodeClient) Search(ctx context.Context, indexName string, shardID int32, query []byte, filterExpression []byte, aggs []byte, sort []string, from, size int32) (*pb.SearchResponse, error) {
 	dc.mu.RLock()
 	if !dc.connected {
 		dc.mu.RUnlock()
@@ -110,7 +112,11 @@ func (dc *DataNodeClient) Search(ctx context.Context, indexName string, shardID
 		IndexName:        indexName,
 		ShardId:          shardID,
 		Query:            query,
+		From:             from,
+		Size:             size,
+		Sort:             sort,
 		FilterExpression: filterExpression,
+		Aggregations:     aggs,
 	}
 
 	resp, err := client.Search(ctx, req)
@@ -146,8 +152,10 @@ func (dc *DataNodeClient) Count(ctx context.Context, indexName string, shardID i
 	return resp, nil
 }
 
-// IndexDocument indexes a document on a specific shard
-func (dc *DataNodeClient) IndexDocument(ctx context.Context, indexName string, shardID int32, docID string, document map[string]interface{}) (*pb.IndexDocumentResponse, error) {
+// IndexDocument indexes a document on a specific shard. refresh is the
+// write's refresh policy ("true", "wait_for", or "" for none), and
+// waitForActiveShards the number of active copies it needs.
+func (dc *DataNodeClient) IndexDocument(ctx context.Context, indexName string, shardID int32, docID string, document map[string]interface{}, refresh, waitForActiveShards string) (*pb.IndexDocumentResponse, error) {
 	dc.mu.RLock()
 	if !dc.connected {
 		dc.mu.RUnlock()
@@ -163,10 +171,12 @@ func (dc *DataNodeClient) IndexDocument(ctx context.Context, indexName string, s
 	}
 
 	req := &pb.IndexDocumentRequest{
-		IndexName: indexName,
-		ShardId:   shardID,
-		DocId:     docID,
-		Document:  docStruct,
+		IndexName:           indexName,
+		ShardId:             shardID,
+		DocId:               docID,
+		Document:            docStruct,
+		Refresh:             refresh,
+		WaitForActiveShards: waitForActiveShards,
 	}
 
 	resp, err := client.IndexDocument(ctx, req)
@@ -202,7 +212,7 @@ func (dc *DataNodeClient) GetDocument(ctx context.Context, indexName string, sha
 }
 
 // DeleteDocument deletes a document by ID from a specific shard
-func (dc *DataNodeClient) DeleteDocument(ctx context.Context, indexName string, shardID int32, docID string) (*pb.DeleteDocumentResponse, error) {
+func (dc *DataNodeClient) DeleteDocument(ctx context.Context, indexName string, shardID int32, docID string, refresh, waitForActiveShards string) (*pb.DeleteDocumentResponse, error) {
 	dc.mu.RLock()
 	if !dc.connected {
 		dc.mu.RUnlock()
@@ -212,9 +222,11 @@ func (dc *DataNodeClient) DeleteDocument(ctx context.Context, indexName string,
 	dc.mu.RUnlock()
 
 	req := &pb.DeleteDocumentRequest{
-		IndexName: indexName,
-		ShardId:   shardID,
-		DocId:     docID,
+		IndexName:           indexName,
+		ShardId:             shardID,
+		DocId:               docID,
+		Refresh:             refresh,
+		WaitForActiveShards: waitForActiveShards,
 	}
 
 	resp, err := client.DeleteDocument(ctx, req)
@@ -248,6 +260,52 @@ func (dc *DataNodeClient) GetShardStats(ctx context.Context, indexName string, s
 	return resp, nil
 }
 
+// RefreshShard makes every acknowledged write on a shard searchable
+func (dc *DataNodeClient) RefreshShard(ctx context.Context, indexName string, shardID int32) (*pb.RefreshShardResponse, error) {
+	dc.mu.RLock()
+	if !dc.connected {
+		dc.mu.RUnlock()
+		return nil, fmt.Errorf("not connected to data node %s", dc.nodeID)
+	}
+	client := dc.client
+	dc.mu.RUnlock()
+
+	req := &pb.RefreshShardRequest{
+		IndexName: indexName,
+		ShardId:   shardID,
+	}
+
+	resp, err := client.RefreshShard(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("refresh failed on node %s shard %d: %w", dc.nodeID, shardID, err)
+	}
+
+	return resp, nil
+}
+
+// FlushShard commits a shard on the data node and truncates its translog
+func (dc *DataNodeClient) FlushShard(ctx context.Context, indexName string, shardID int32) (*pb.FlushShardResponse, error) {
+	dc.mu.RLock()
+	if !dc.connected {
+		dc.mu.RUnlock()
+		return nil, fmt.Errorf("not connected to data node %s", dc.nodeID)
+	}
+	client := dc.client
+	dc.mu.RUnlock()
+
+	req := &pb.FlushShardRequest{
+		IndexName: indexName,
+		ShardId:   shardID,
+	}
+
+	resp, err := client.FlushShard(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("flush failed on node %s shard %d: %w", dc.nodeID, shardID, err)
+	}
+
+	return resp, nil
+}
+
 // NodeID returns the node ID
 func (dc *DataNodeClient) NodeID() string {
 	return dc.nodeID
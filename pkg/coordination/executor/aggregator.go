@@ -1,16 +1,27 @@
 package executor
 
 import (
+	"encoding/json"
 	"math"
 	"sort"
+	"strconv"
 	"time"
 
+	"github.com/conjugate/conjugate/pkg/common/dates"
 	pb "github.com/conjugate/conjugate/pkg/common/proto"
+	"github.com/conjugate/conjugate/pkg/common/sketch"
 	"go.uber.org/zap"
 )
 
-// aggregateSearchResults merges search results from multiple shards
-func (qe *QueryExecutor) aggregateSearchResults(responses []*pb.SearchResponse, from, size int) *SearchResult {
+// maxBuckets caps the buckets a merged histogram is filled out to, like the
+// data nodes' search.max_buckets
+const maxBuckets = 65535
+
+// aggregateSearchResults merges search results from multiple shards. Hits
+// are ordered by sortFields when given, by score otherwise. aggs is the aggs
+// section of the request, whose parameters the merge of some aggregations
+// needs.
+func (qe *QueryExecutor) aggregateSearchResults(responses []*pb.SearchResponse, aggs []byte, sortFields []*SortField, from, size, maxHits int) *SearchResult {
 	if len(responses) == 0 {
 		return &SearchResult{
 			TotalHits: 0,
@@ -42,19 +53,41 @@ func (qe *QueryExecutor) aggregateSearchResults(responses []*pb.SearchResponse,
 				if hit.Source != nil {
 					sourceMap = hit.Source.AsMap()
 				}
+				var sortValues []interface{}
+				for _, value := range hit.Sort {
+					sortValues = append(sortValues, value.AsInterface())
+				}
 				allHits = append(allHits, &SearchHit{
 					ID:     hit.Id,
 					Score:  hit.Score,
 					Source: sourceMap,
+					Sort:   sortValues,
 				})
 			}
 		}
 	}
 
-	// Sort hits by score (descending)
-	sort.Slice(allHits, func(i, j int) bool {
-		return allHits[i].Score > allHits[j].Score
-	})
+	// Merge-sort hits by their sort values, or by score (descending). The
+	// sort is stable so ties keep shard order and each shard's own ranking.
+	if len(sortFields) > 0 {
+		sort.SliceStable(allHits, func(i, j int) bool {
+			return compareSortValues(sortFields, allHits[i].Sort, allHits[j].Sort) < 0
+		})
+	} else {
+		sort.SliceStable(allHits, func(i, j int) bool {
+			return allHits[i].Score > allHits[j].Score
+		})
+	}
+
+	// Only the best maxHits hits match at all, if it is set
+	if maxHits > 0 {
+		if len(allHits) > maxHits {
+			allHits = allHits[:maxHits]
+		}
+		if totalHits > int64(maxHits) {
+			totalHits = int64(maxHits)
+		}
+	}
 
 	// Apply pagination (from/size)
 	start := from
@@ -70,7 +103,7 @@ func (qe *QueryExecutor) aggregateSearchResults(responses []*pb.SearchResponse,
 	paginatedHits := allHits[start:end]
 
 	// Merge aggregations from all shards
-	aggregations := qe.mergeAggregations(responses)
+	aggregations := qe.mergeAggregations(responses, aggs)
 
 	return &SearchResult{
 		TotalHits:    totalHits,
@@ -90,18 +123,32 @@ func aggregateCountResults(responses []*pb.CountResponse) int64 {
 }
 
 // mergeAggregations merges aggregations from multiple shard responses
-func (qe *QueryExecutor) mergeAggregations(responses []*pb.SearchResponse) map[string]*AggregationResult {
+func (qe *QueryExecutor) mergeAggregations(responses []*pb.SearchResponse, aggs []byte) map[string]*AggregationResult {
 	if len(responses) == 0 {
 		return nil
 	}
 
+	// The shards already rejected an invalid request
+	var defs map[string]interface{}
+	if len(aggs) > 0 {
+		_ = json.Unmarshal(aggs, &defs)
+	}
+
+	shardAggs := make([]map[string]*pb.AggregationResult, 0, len(responses))
+	for _, resp := range responses {
+		shardAggs = append(shardAggs, resp.Aggregations)
+	}
+	return qe.mergeAggregationSets(shardAggs, defs)
+}
+
+// mergeAggregationSets merges named aggregations from multiple shards, given
+// their definitions in the request. It is applied recursively to the
+// sub-aggregations of matching buckets.
+func (qe *QueryExecutor) mergeAggregationSets(shardAggs []map[string]*pb.AggregationResult, defs map[string]interface{}) map[string]*AggregationResult {
 	// Group aggregations by name across all shards
 	aggsByName := make(map[string][]*pb.AggregationResult)
-	for _, resp := range responses {
-		if resp.Aggregations == nil {
-			continue
-		}
-		for name, agg := range resp.Aggregations {
+	for _, aggs := range shardAggs {
+		for name, agg := range aggs {
 			aggsByName[name] = append(aggsByName[name], agg)
 		}
 	}
@@ -122,15 +169,20 @@ func (qe *QueryExecutor) mergeAggregations(responses []*pb.SearchResponse) map[s
 		// Track aggregation merge time
 		mergeStartTime := time.Now()
 		var result *AggregationResult
+		body, subDefs := aggDefinition(defs, name)
 
 		switch aggType {
-		case "terms", "histogram", "date_histogram", "range", "filters":
-			result = qe.mergeBucketAggregation(aggs)
+		case "terms":
+			result = qe.mergeTermsAggregation(aggs, body, subDefs)
+		case "composite":
+			result = qe.mergeCompositeAggregation(aggs, body, subDefs)
+		case "histogram", "date_histogram", "range", "filters":
+			result = qe.mergeBucketAggregation(aggs, body, subDefs)
 		case "stats":
 			result = qe.mergeStatsAggregation(aggs, false)
 		case "extended_stats":
 			result = qe.mergeStatsAggregation(aggs, true)
-		case "percentiles":
+		case "percentiles", "percentile_ranks":
 			result = qe.mergePercentilesAggregation(aggs)
 		case "cardinality":
 			result = qe.mergeCardinalityAggregation(aggs)
@@ -152,8 +204,25 @@ func (qe *QueryExecutor) mergeAggregations(responses []*pb.SearchResponse) map[s
 	return merged
 }
 
-// mergeBucketAggregation merges bucket-based aggregations (terms, histogram, date_histogram, range)
-func (qe *QueryExecutor) mergeBucketAggregation(aggs []*pb.AggregationResult) *AggregationResult {
+// aggDefinition returns the body and the sub-aggregation definitions of the
+// named aggregation among a request's aggregation definitions
+func aggDefinition(defs map[string]interface{}, name string) (map[string]interface{}, map[string]interface{}) {
+	def, _ := defs[name].(map[string]interface{})
+	var body, subDefs map[string]interface{}
+	for key, value := range def {
+		switch key {
+		case "aggs", "aggregations":
+			subDefs, _ = value.(map[string]interface{})
+		case "meta":
+		default:
+			body, _ = value.(map[string]interface{})
+		}
+	}
+	return body, subDefs
+}
+
+// mergeBucketAggregation merges bucket-based aggregations (histogram, date_histogram, range, filters)
+func (qe *QueryExecutor) mergeBucketAggregation(aggs []*pb.AggregationResult, body, subDefs map[string]interface{}) *AggregationResult {
 	if len(aggs) == 0 {
 		return nil
 	}
@@ -162,54 +231,55 @@ func (qe *QueryExecutor) mergeBucketAggregation(aggs []*pb.AggregationResult) *A
 
 	// For range and filters aggregations, preserve bucket order and metadata
 	if aggType == "range" {
-		return qe.mergeRangeAggregation(aggs)
+		return qe.mergeRangeAggregation(aggs, subDefs)
 	}
 	if aggType == "filters" {
-		return qe.mergeFiltersAggregation(aggs)
+		return qe.mergeFiltersAggregation(aggs, subDefs)
 	}
 
 	// Sum bucket counts across all shards
-	bucketCounts := make(map[string]int64)      // for string keys (terms, date_histogram)
-	numericBucketCounts := make(map[float64]int64) // for numeric keys (histogram)
+	numericBucketCounts := make(map[float64]int64) // for numeric keys (histogram, date_histogram)
+	numericBucketKeys := make(map[float64]string)  // key_as_string of date_histogram buckets
 
-	isNumeric := aggType == "histogram"
+	// Sub-aggregations of each bucket, grouped by bucket key
+	numericSubAggs := make(map[float64][]map[string]*pb.AggregationResult)
 
 	for _, agg := range aggs {
 		for _, bucket := range agg.Buckets {
-			if isNumeric {
-				numericBucketCounts[bucket.NumericKey] += bucket.DocCount
-			} else {
-				bucketCounts[bucket.Key] += bucket.DocCount
+			numericBucketCounts[bucket.NumericKey] += bucket.DocCount
+			numericBucketKeys[bucket.NumericKey] = bucket.Key
+			if len(bucket.SubAggregations) > 0 {
+				numericSubAggs[bucket.NumericKey] = append(numericSubAggs[bucket.NumericKey], bucket.SubAggregations)
 			}
 		}
 	}
 
-	// Convert to result buckets
-	var buckets []*AggregationBucket
+	keys := make([]float64, 0, len(numericBucketCounts))
+	for key := range numericBucketCounts {
+		keys = append(keys, key)
+	}
+	keys, keyAsString := qe.histogramBucketKeys(aggType, keys, body)
+	minDocCount := int64(0)
+	if v, ok := body["min_doc_count"].(float64); ok && v > 0 {
+		minDocCount = int64(v)
+	}
 
-	if isNumeric {
-		// Numeric buckets (histogram)
-		for key, count := range numericBucketCounts {
-			buckets = append(buckets, &AggregationBucket{
-				NumericKey: key,
-				DocCount:   count,
-			})
-		}
-		// Sort by numeric key
-		sort.Slice(buckets, func(i, j int) bool {
-			return buckets[i].NumericKey < buckets[j].NumericKey
-		})
-	} else {
-		// String buckets (terms, date_histogram)
-		for key, count := range bucketCounts {
-			buckets = append(buckets, &AggregationBucket{
-				Key:      key,
-				DocCount: count,
-			})
-		}
-		// Sort by doc_count descending
-		sort.Slice(buckets, func(i, j int) bool {
-			return buckets[i].DocCount > buckets[j].DocCount
+	// Convert to result buckets, in key order
+	var buckets []*AggregationBucket
+	for _, key := range keys {
+		count := numericBucketCounts[key]
+		if count < minDocCount {
+			continue
+		}
+		bucketKey, ok := numericBucketKeys[key]
+		if !ok && keyAsString != nil {
+			bucketKey = keyAsString(key)
+		}
+		buckets = append(buckets, &AggregationBucket{
+			Key:             bucketKey,
+			NumericKey:      key,
+			DocCount:        count,
+			SubAggregations: qe.mergeSubAggregations(numericSubAggs[key], subDefs),
 		})
 	}
 
@@ -219,8 +289,66 @@ func (qe *QueryExecutor) mergeBucketAggregation(aggs []*pb.AggregationResult) *A
 	}
 }
 
+// histogramBucketKeys orders the keys of a merged histogram or
+// date_histogram. With min_doc_count 0 the gaps between them are filled, as
+// each shard filled only its own range, out to a date_histogram's
+// extended_bounds; keys outside its hard_bounds are dropped. It also returns
+// how to format the key_as_string of a filled date_histogram bucket.
+func (qe *QueryExecutor) histogramBucketKeys(aggType string, keys []float64, body map[string]interface{}) ([]float64, func(float64) string) {
+	sort.Float64s(keys)
+	fill := true
+	if v, ok := body["min_doc_count"].(float64); ok && v > 0 {
+		fill = false
+	}
+
+	if aggType == "date_histogram" {
+		// The data nodes validated the parameters
+		rounding, err := dates.ParseRounding(body)
+		if err != nil {
+			return keys, nil
+		}
+		extendedBounds, _ := rounding.ParseBounds(body["extended_bounds"])
+		hardBounds, _ := rounding.ParseBounds(body["hard_bounds"])
+		millis := make([]int64, len(keys))
+		for i, key := range keys {
+			millis[i] = int64(key)
+		}
+		millis, err = rounding.BucketKeys(millis, fill, extendedBounds, hardBounds, maxBuckets)
+		if err != nil {
+			qe.logger.Warn("Not filling date_histogram gaps", zap.Error(err))
+			return keys, nil
+		}
+		filled := make([]float64, len(millis))
+		for i, ms := range millis {
+			filled[i] = float64(ms)
+		}
+		return filled, func(key float64) string {
+			return rounding.FormatKey(time.UnixMilli(int64(key)))
+		}
+	}
+
+	interval, _ := body["interval"].(float64)
+	if !fill || interval <= 0 || len(keys) < 2 {
+		return keys, nil
+	}
+	// Keys are computed from their interval index as on the data nodes, so
+	// filled keys equal theirs exactly
+	offset, _ := body["offset"].(float64)
+	first := int64(math.Floor((keys[0]-offset)/interval + 0.5))
+	last := int64(math.Floor((keys[len(keys)-1]-offset)/interval + 0.5))
+	if last-first >= maxBuckets {
+		qe.logger.Warn("Not filling histogram gaps", zap.Int64("buckets", last-first+1))
+		return keys, nil
+	}
+	filled := make([]float64, 0, last-first+1)
+	for index := first; index <= last; index++ {
+		filled = append(filled, float64(index)*interval+offset)
+	}
+	return filled, nil
+}
+
 // mergeRangeAggregation merges range aggregations preserving bucket order and metadata
-func (qe *QueryExecutor) mergeRangeAggregation(aggs []*pb.AggregationResult) *AggregationResult {
+func (qe *QueryExecutor) mergeRangeAggregation(aggs []*pb.AggregationResult, subDefs map[string]interface{}) *AggregationResult {
 	if len(aggs) == 0 {
 		return nil
 	}
@@ -234,22 +362,12 @@ func (qe *QueryExecutor) mergeRangeAggregation(aggs []*pb.AggregationResult) *Ag
 		buckets[i] = &AggregationBucket{
 			Key:      bucket.Key,
 			DocCount: bucket.DocCount,
+			From:     bucket.From,
+			To:       bucket.To,
 		}
-		// Note: from/to fields will be added when protobuf is regenerated
 	}
 
-	// Sum counts from remaining shards (matching by key)
-	for shardIdx := 1; shardIdx < len(aggs); shardIdx++ {
-		for _, bucket := range aggs[shardIdx].Buckets {
-			// Find matching bucket by key
-			for i, resultBucket := range buckets {
-				if resultBucket.Key == bucket.Key {
-					buckets[i].DocCount += bucket.DocCount
-					break
-				}
-			}
-		}
-	}
+	qe.sumKeyedBuckets(buckets, aggs, subDefs)
 
 	return &AggregationResult{
 		Type:    "range",
@@ -258,7 +376,7 @@ func (qe *QueryExecutor) mergeRangeAggregation(aggs []*pb.AggregationResult) *Ag
 }
 
 // mergeFiltersAggregation merges filters aggregations preserving bucket order
-func (qe *QueryExecutor) mergeFiltersAggregation(aggs []*pb.AggregationResult) *AggregationResult {
+func (qe *QueryExecutor) mergeFiltersAggregation(aggs []*pb.AggregationResult, subDefs map[string]interface{}) *AggregationResult {
 	if len(aggs) == 0 {
 		return nil
 	}
@@ -275,25 +393,54 @@ func (qe *QueryExecutor) mergeFiltersAggregation(aggs []*pb.AggregationResult) *
 		}
 	}
 
-	// Sum counts from remaining shards (matching by key)
+	qe.sumKeyedBuckets(buckets, aggs, subDefs)
+
+	return &AggregationResult{
+		Type:    "filters",
+		Buckets: buckets,
+	}
+}
+
+// sumKeyedBuckets adds the counts of the remaining shards to buckets
+// initialized from the first shard (matching by key) and merges the
+// sub-aggregations of each bucket
+func (qe *QueryExecutor) sumKeyedBuckets(buckets []*AggregationBucket, aggs []*pb.AggregationResult, subDefs map[string]interface{}) {
+	subAggs := make([][]map[string]*pb.AggregationResult, len(buckets))
+	for i, bucket := range aggs[0].Buckets {
+		if len(bucket.SubAggregations) > 0 {
+			subAggs[i] = append(subAggs[i], bucket.SubAggregations)
+		}
+	}
+
 	for shardIdx := 1; shardIdx < len(aggs); shardIdx++ {
 		for _, bucket := range aggs[shardIdx].Buckets {
 			// Find matching bucket by key
 			for i, resultBucket := range buckets {
 				if resultBucket.Key == bucket.Key {
 					buckets[i].DocCount += bucket.DocCount
+					if len(bucket.SubAggregations) > 0 {
+						subAggs[i] = append(subAggs[i], bucket.SubAggregations)
+					}
 					break
 				}
 			}
 		}
 	}
 
-	return &AggregationResult{
-		Type:    "filters",
-		Buckets: buckets,
+	for i := range buckets {
+		buckets[i].SubAggregations = qe.mergeSubAggregations(subAggs[i], subDefs)
 	}
 }
 
+// mergeSubAggregations merges the sub-aggregations a bucket received from
+// each shard, returning nil when there are none
+func (qe *QueryExecutor) mergeSubAggregations(shardAggs []map[string]*pb.AggregationResult, subDefs map[string]interface{}) map[string]*AggregationResult {
+	if len(shardAggs) == 0 {
+		return nil
+	}
+	return qe.mergeAggregationSets(shardAggs, subDefs)
+}
+
 // mergeStatsAggregation merges stats and extended_stats aggregations
 func (qe *QueryExecutor) mergeStatsAggregation(aggs []*pb.AggregationResult, extended bool) *AggregationResult {
 	if len(aggs) == 0 {
@@ -302,8 +449,6 @@ func (qe *QueryExecutor) mergeStatsAggregation(aggs []*pb.AggregationResult, ext
 
 	result := &AggregationResult{
 		Type: aggs[0].Type,
-		Min:  aggs[0].Min,
-		Max:  aggs[0].Max,
 	}
 
 	var totalCount int64
@@ -311,17 +456,22 @@ func (qe *QueryExecutor) mergeStatsAggregation(aggs []*pb.AggregationResult, ext
 	var totalSumOfSquares float64
 
 	for _, agg := range aggs {
-		totalCount += agg.Count
-		totalSum += agg.Sum
+		// A shard without values reports a min and max of 0
+		if agg.Count == 0 {
+			continue
+		}
 
 		// Track global min/max
-		if agg.Min < result.Min {
+		if totalCount == 0 || agg.Min < result.Min {
 			result.Min = agg.Min
 		}
-		if agg.Max > result.Max {
+		if totalCount == 0 || agg.Max > result.Max {
 			result.Max = agg.Max
 		}
 
+		totalCount += agg.Count
+		totalSum += agg.Sum
+
 		if extended {
 			totalSumOfSquares += agg.SumOfSquares
 		}
@@ -349,44 +499,62 @@ func (qe *QueryExecutor) mergeStatsAggregation(aggs []*pb.AggregationResult, ext
 	return result
 }
 
-// mergePercentilesAggregation merges percentiles aggregations
-// Note: This is approximate - collecting all values would be expensive
-// For now, we average the percentile values from each shard
+// mergePercentilesAggregation merges percentiles and percentile_ranks
+// aggregations. The shards' t-digests are merged into one digest of all
+// their values, which answers for the percents (or values) the shards
+// reported.
 func (qe *QueryExecutor) mergePercentilesAggregation(aggs []*pb.AggregationResult) *AggregationResult {
 	if len(aggs) == 0 {
 		return nil
 	}
 
 	result := &AggregationResult{
-		Type:   "percentiles",
+		Type:   aggs[0].Type,
 		Values: make(map[string]float64),
 	}
 
-	// Track percentile sums and counts for averaging
-	percentileSums := make(map[string]float64)
-	percentileCounts := make(map[string]int)
-
+	var merged *sketch.TDigest
+	points := make(map[string]struct{})
 	for _, agg := range aggs {
-		for percentile, value := range agg.Values {
-			percentileSums[percentile] += value
-			percentileCounts[percentile]++
+		for point := range agg.Values {
+			points[point] = struct{}{}
 		}
+		if len(agg.Sketch) == 0 {
+			continue
+		}
+		digest, err := sketch.UnmarshalTDigest(agg.Sketch)
+		if err != nil {
+			qe.logger.Warn("Skipping invalid percentiles digest", zap.Error(err))
+			continue
+		}
+		if merged == nil {
+			merged = sketch.NewTDigest(digest.Compression())
+		}
+		merged.Merge(digest)
+	}
+	if merged == nil || merged.Count() == 0 {
+		return result
 	}
+	result.Count = merged.Count()
 
-	// Average the percentiles
-	for percentile, sum := range percentileSums {
-		count := percentileCounts[percentile]
-		if count > 0 {
-			result.Values[percentile] = sum / float64(count)
+	for point := range points {
+		value, err := strconv.ParseFloat(point, 64)
+		if err != nil {
+			continue
+		}
+		if result.Type == "percentile_ranks" {
+			result.Values[point] = merged.CDF(value) * 100
+		} else {
+			result.Values[point] = merged.Quantile(value / 100)
 		}
 	}
 
 	return result
 }
 
-// mergeCardinalityAggregation merges cardinality aggregations
-// Note: This is approximate - true cardinality would require HyperLogLog
-// For now, we sum the cardinalities (which may overcount)
+// mergeCardinalityAggregation merges cardinality aggregations by the union
+// of the shards' HyperLogLog++ sketches, so values found on several shards
+// are counted once. A shard without a sketch can only add its own count.
 func (qe *QueryExecutor) mergeCardinalityAggregation(aggs []*pb.AggregationResult) *AggregationResult {
 	if len(aggs) == 0 {
 		return nil
@@ -396,19 +564,35 @@ func (qe *QueryExecutor) mergeCardinalityAggregation(aggs []*pb.AggregationResul
 		Type: "cardinality",
 	}
 
-	// Sum cardinalities from all shards
-	// Note: This overcounts if same values appear on multiple shards
-	var total int64
+	var union *sketch.HyperLogLog
 	for _, agg := range aggs {
-		total += agg.Value
+		if len(agg.Sketch) == 0 {
+			result.Value += agg.Value
+			continue
+		}
+		hll, err := sketch.UnmarshalHyperLogLog(agg.Sketch)
+		if err == nil && union != nil {
+			err = union.Merge(hll)
+		}
+		if err != nil {
+			qe.logger.Warn("Counting cardinality sketch without union", zap.Error(err))
+			result.Value += agg.Value
+			continue
+		}
+		if union == nil {
+			union = hll
+		}
+	}
+	if union != nil {
+		result.Value += union.Cardinality()
 	}
-
-	result.Value = total
 
 	return result
 }
 
-// mergeSimpleMetricAggregation merges simple metric aggregations (avg, min, max, sum, value_count)
+// mergeSimpleMetricAggregation merges simple metric aggregations (avg, min,
+// max, sum, value_count). Shards without values are left out of avg, min and
+// max, which are NaN, rendered null, when no shard has values.
 func (qe *QueryExecutor) mergeSimpleMetricAggregation(aggs []*pb.AggregationResult) *AggregationResult {
 	if len(aggs) == 0 {
 		return nil
@@ -419,31 +603,41 @@ func (qe *QueryExecutor) mergeSimpleMetricAggregation(aggs []*pb.AggregationResu
 		Type: aggType,
 	}
 
+	// The number of values, which tells an empty metric from a zero one
+	for _, agg := range aggs {
+		result.Count += agg.Count
+	}
+
 	switch aggType {
 	case "avg":
-		// Average: compute weighted average across shards
-		// We don't have document counts per shard, so we average the averages (approximation)
+		// Average: the sum of all values over their count, so that shards
+		// weigh by their number of values
 		var sum float64
 		for _, agg := range aggs {
-			sum += agg.Avg
+			sum += agg.Sum
 		}
-		result.Avg = sum / float64(len(aggs))
+		if result.Count > 0 {
+			result.Avg = sum / float64(result.Count)
+		}
+		result.Sum = sum
 
 	case "min":
 		// Minimum: take global minimum
-		result.Min = aggs[0].Min
+		found := false
 		for _, agg := range aggs {
-			if agg.Min < result.Min {
+			if agg.Count > 0 && (!found || agg.Min < result.Min) {
 				result.Min = agg.Min
+				found = true
 			}
 		}
 
 	case "max":
 		// Maximum: take global maximum
-		result.Max = aggs[0].Max
+		found := false
 		for _, agg := range aggs {
-			if agg.Max > result.Max {
+			if agg.Count > 0 && (!found || agg.Max > result.Max) {
 				result.Max = agg.Max
+				found = true
 			}
 		}
 
@@ -456,12 +650,18 @@ func (qe *QueryExecutor) mergeSimpleMetricAggregation(aggs []*pb.AggregationResu
 		result.Sum = sum
 
 	case "value_count":
-		// Value count: sum across all shards
-		var total int64
-		for _, agg := range aggs {
-			total += agg.Count
+		// Value count: the count summed above
+	}
+
+	if result.Count == 0 {
+		switch aggType {
+		case "avg":
+			result.Avg = math.NaN()
+		case "min":
+			result.Min = math.NaN()
+		case "max":
+			result.Max = math.NaN()
 		}
-		result.Count = total
 	}
 
 	return result
@@ -0,0 +1,149 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/conjugate/conjugate/pkg/common/aggparams"
+	pb "github.com/conjugate/conjugate/pkg/common/proto"
+)
+
+// compositeSourceOrder is the name and direction of one source of a
+// composite aggregation's key
+type compositeSourceOrder struct {
+	name string
+	desc bool
+}
+
+// parseCompositeSources reads the source order of a composite aggregation.
+// The data nodes validate the sources.
+func parseCompositeSources(body map[string]interface{}) []compositeSourceOrder {
+	raw, _ := body["sources"].([]interface{})
+	sources := make([]compositeSourceOrder, 0, len(raw))
+	for _, entry := range raw {
+		obj, _ := entry.(map[string]interface{})
+		for name, def := range obj {
+			source := compositeSourceOrder{name: name}
+			defMap, _ := def.(map[string]interface{})
+			for _, sourceBody := range defMap {
+				bodyMap, _ := sourceBody.(map[string]interface{})
+				source.desc = bodyMap["order"] == "desc"
+			}
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// compareCompositeKeys orders composite keys as the data nodes do, source by
+// source: a missing value first, then numbers, then strings
+func compareCompositeKeys(sources []compositeSourceOrder, a, b map[string]interface{}) int {
+	for _, source := range sources {
+		c := compareCompositeValues(a[source.name], b[source.name])
+		if source.desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func compareCompositeValues(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	fa, aNum := a.(float64)
+	fb, bNum := b.(float64)
+	switch {
+	case aNum && bNum:
+		return compareFloats(fa, fb)
+	case aNum:
+		return -1
+	case bNum:
+		return 1
+	}
+	return compareTermKeys(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// mergeCompositeAggregation k-way merges the shards' composite pages. Each
+// shard returned its first size keys after the request's after key, sorted,
+// so the first size keys of the merge are exact: any of them is preceded by
+// fewer than size keys on every shard that has it. after_key is the key of
+// the last bucket, from which the next page continues.
+func (qe *QueryExecutor) mergeCompositeAggregation(aggs []*pb.AggregationResult, body, subDefs map[string]interface{}) *AggregationResult {
+	if len(aggs) == 0 {
+		return nil
+	}
+
+	size := aggparams.DefaultCompositeSize
+	if v, ok := body["size"].(float64); ok && v > 0 {
+		size = int(v)
+	}
+	sources := parseCompositeSources(body)
+
+	// Decode each shard's keys; a bucket without a valid key is dropped
+	type shardBucket struct {
+		key    map[string]interface{}
+		bucket *pb.AggregationBucket
+	}
+	shards := make([][]shardBucket, 0, len(aggs))
+	for _, agg := range aggs {
+		var buckets []shardBucket
+		for _, bucket := range agg.Buckets {
+			var key map[string]interface{}
+			if err := json.Unmarshal(bucket.CompositeKey, &key); err != nil {
+				continue
+			}
+			buckets = append(buckets, shardBucket{key: key, bucket: bucket})
+		}
+		shards = append(shards, buckets)
+	}
+
+	result := &AggregationResult{Type: "composite"}
+	cursors := make([]int, len(shards))
+	for len(result.Buckets) < size {
+		// The smallest key at the head of any shard
+		var next map[string]interface{}
+		for i, buckets := range shards {
+			if cursors[i] < len(buckets) {
+				if key := buckets[cursors[i]].key; next == nil || compareCompositeKeys(sources, key, next) < 0 {
+					next = key
+				}
+			}
+		}
+		if next == nil {
+			break
+		}
+
+		// Combine it from every shard that has it
+		bucket := &AggregationBucket{CompositeKey: next}
+		var subAggs []map[string]*pb.AggregationResult
+		for i, buckets := range shards {
+			if cursors[i] < len(buckets) && compareCompositeKeys(sources, buckets[cursors[i]].key, next) == 0 {
+				shardBucket := buckets[cursors[i]].bucket
+				bucket.DocCount += shardBucket.DocCount
+				if bucket.Key == "" {
+					bucket.Key = string(shardBucket.CompositeKey)
+				}
+				if len(shardBucket.SubAggregations) > 0 {
+					subAggs = append(subAggs, shardBucket.SubAggregations)
+				}
+				cursors[i]++
+			}
+		}
+		bucket.SubAggregations = qe.mergeSubAggregations(subAggs, subDefs)
+		result.Buckets = append(result.Buckets, bucket)
+	}
+
+	if len(result.Buckets) > 0 {
+		result.AfterKey = result.Buckets[len(result.Buckets)-1].CompositeKey
+	}
+	return result
+}
@@ -3,6 +3,7 @@ package executor
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -74,9 +75,13 @@ var (
 	)
 )
 
+// MaxResultWindow is the largest from+size a search may request. Deeper
+// pages would make every shard collect and ship that many hits.
+const MaxResultWindow = 10000
+
 // DataNodeClient interface for communication with data nodes
 type DataNodeClient interface {
-	Search(ctx context.Context, indexName string, shardID int32, query []byte, filterExpression []byte) (*pb.SearchResponse, error)
+	Search(ctx context.Context, indexName string, shardID int32, query []byte, filterExpression []byte, aggs []byte, sort []string, from, size int32) (*pb.SearchResponse, error)
 	Count(ctx context.Context, indexName string, shardID int32, query []byte, filterExpression []byte) (*pb.CountResponse, error)
 	IsConnected() bool
 	Connect(ctx context.Context) error
@@ -129,8 +134,11 @@ func (qe *QueryExecutor) HasDataNodeClient(nodeID string) bool {
 	return exists
 }
 
-// ExecuteSearch executes a search query across all relevant shards
-func (qe *QueryExecutor) ExecuteSearch(ctx context.Context, indexName string, query []byte, filterExpression []byte, from, size int) (*SearchResult, error) {
+// ExecuteSearch executes a search query across all relevant shards. aggs is
+// the serialized aggs section of the request; every shard evaluates it over
+// its matches and the results are merged. If sortFields is not empty, hits
+// are ordered by those fields instead of score.
+func (qe *QueryExecutor) ExecuteSearch(ctx context.Context, indexName string, query []byte, filterExpression []byte, aggs []byte, sortFields []*SortField, from, size int) (*SearchResult, error) {
 	startTime := time.Now()
 
 	qe.logger.Info("==> ExecuteSearch ENTRY",
@@ -139,6 +147,44 @@ func (qe *QueryExecutor) ExecuteSearch(ctx context.Context, indexName string, qu
 		zap.Int("size", size),
 		zap.String("query", string(query)))
 
+	if from < 0 || size < 0 {
+		return nil, fmt.Errorf("from and size must not be negative")
+	}
+	if from+size > MaxResultWindow {
+		return nil, fmt.Errorf("result window is too large, from + size must be less than or equal to [%d] but was [%d]",
+			MaxResultWindow, from+size)
+	}
+
+	// Pipeline aggregations read the merged results of the others, so the
+	// shards only compute those
+	shardAggs, pipelineDefs, err := splitPipelineAggregations(aggs)
+	if err != nil {
+		return nil, err
+	}
+
+	maxHits := 0
+	switch clause, body := queryClause(query); clause {
+	case "hybrid":
+		return qe.executeHybridSearch(ctx, indexName, body, filterExpression, aggs, sortFields, from, size)
+	case "knn":
+		// Each shard returns its k nearest, of which the k nearest overall
+		// are the hits. Scores fall with distance, so merging by score keeps
+		// those; a sort on fields orders the shards' hits otherwise, so they
+		// are all kept.
+		if len(sortFields) == 0 {
+			maxHits = knnLimit(body)
+		}
+	}
+
+	// Every shard may hold any of the top from+size hits, so each one returns
+	// its own top from+size and the pages are cut after the merge
+	shardSize := int32(from + size)
+
+	sortClauses, err := encodeSortClauses(sortFields)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get shard routing from master
 	routing, err := qe.masterClient.GetShardRouting(ctx, indexName)
 	if err != nil {
@@ -181,7 +227,7 @@ func (qe *QueryExecutor) ExecuteSearch(ctx context.Context, indexName string, qu
 			qe.logger.Warn("Skipping shard - not started",
 				zap.String("index", indexName),
 				zap.Int32("shard_id", shardID),
-				zap.String("state", shard.Allocation.State.String()))
+				zap.String("state", shard.Allocation.GetState().String()))
 			continue
 		}
 
@@ -261,7 +307,7 @@ func (qe *QueryExecutor) ExecuteSearch(ctx context.Context, indexName string, qu
 				zap.String("index", indexName),
 				zap.String("query", string(query)))
 
-			resp, err := client.Search(ctx, indexName, sid, query, filterExpression)
+			resp, err := client.Search(ctx, indexName, sid, query, filterExpression, shardAggs, sortClauses, 0, shardSize)
 
 			qe.logger.Info("DEBUG: client.Search returned",
 				zap.Int32("shard_id", sid),
@@ -307,7 +353,7 @@ func (qe *QueryExecutor) ExecuteSearch(ctx context.Context, indexName string, qu
 	}()
 
 	// Collect results
-	var shardResponses []*pb.SearchResponse
+	var succeeded []shardResult
 	var errors []error
 
 	for result := range resultsChan {
@@ -318,6 +364,15 @@ func (qe *QueryExecutor) ExecuteSearch(ctx context.Context, indexName string, qu
 			errors = append(errors, result.err)
 			continue
 		}
+		succeeded = append(succeeded, result)
+	}
+
+	// Order by shard so that hits with equal scores merge deterministically
+	sort.Slice(succeeded, func(i, j int) bool {
+		return succeeded[i].shardID < succeeded[j].shardID
+	})
+	shardResponses := make([]*pb.SearchResponse, 0, len(succeeded))
+	for _, result := range succeeded {
 		shardResponses = append(shardResponses, result.response)
 	}
 
@@ -330,7 +385,15 @@ func (qe *QueryExecutor) ExecuteSearch(ctx context.Context, indexName string, qu
 	}
 
 	// Aggregate results
-	aggregatedResult := qe.aggregateSearchResults(shardResponses, from, size)
+	aggregatedResult := qe.aggregateSearchResults(shardResponses, aggs, sortFields, from, size, maxHits)
+	if pipelineDefs != nil {
+		if aggregatedResult.Aggregations == nil {
+			aggregatedResult.Aggregations = make(map[string]*AggregationResult)
+		}
+		if err := applyPipelineAggregations(pipelineDefs, aggregatedResult.Aggregations); err != nil {
+			return nil, err
+		}
+	}
 	aggregatedResult.TookMillis = time.Since(startTime).Milliseconds()
 
 	// Record metrics
@@ -457,11 +520,28 @@ type AggregationResult struct {
 	StdDeviationBoundsUpper   float64
 	StdDeviationBoundsLower   float64
 
-	// Percentiles field
+	// Percentiles and percentile ranks field
 	Values map[string]float64
 
 	// Cardinality field
 	Value int64
+
+	// Terms fields: the most a bucket's count may be understated by the
+	// shards' shard_size cut (-1 if unbounded), and the count of documents
+	// in terms not returned
+	DocCountErrorUpperBound int64
+	SumOtherDocCount        int64
+
+	// Pipeline fields: the value of a single-value pipeline (NaN if it has
+	// none), a derivative per unit, and the keys of the buckets max_bucket
+	// or min_bucket selected. stats_bucket uses the stats fields.
+	PipelineValue   float64
+	NormalizedValue *float64
+	Keys            []string
+
+	// Composite field: the key of the last bucket, to request the next page
+	// after
+	AfterKey map[string]interface{}
 }
 
 // AggregationBucket represents a bucket in a bucket aggregation
@@ -469,6 +549,16 @@ type AggregationBucket struct {
 	Key        string
 	NumericKey float64
 	DocCount   int64
+
+	// Range bucket bounds (nil if unbounded)
+	From *float64
+	To   *float64
+
+	// Composite bucket key: source name to value (Key holds it as JSON)
+	CompositeKey map[string]interface{}
+
+	// Nested aggregations computed over the bucket's documents
+	SubAggregations map[string]*AggregationResult
 }
 
 // SearchHit represents a single search hit
@@ -476,4 +566,12 @@ type SearchHit struct {
 	ID     string
 	Score  float64
 	Source map[string]interface{}
+	Sort   []interface{} // Sort values of a sorted search (nil entries are missing)
+}
+
+// SortField is one key of a sorted search
+type SortField struct {
+	Field      string // Field name, or "_score" / "_doc"
+	Descending bool
+	Missing    interface{} // "_last" (default), "_first" or a value to use instead
 }
@@ -2,14 +2,20 @@ package executor
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"math"
 	"testing"
+	"time"
 
 	pb "github.com/conjugate/conjugate/pkg/common/proto"
+	"github.com/conjugate/conjugate/pkg/common/sketch"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 // MockDataNodeClient is a mock implementation of DataNodeClient
@@ -18,8 +24,8 @@ type MockDataNodeClient struct {
 	nodeID string
 }
 
-func (m *MockDataNodeClient) Search(ctx context.Context, indexName string, shardID int32, query []byte, filterExpression []byte) (*pb.SearchResponse, error) {
-	args := m.Called(ctx, indexName, shardID, query, filterExpression)
+func (m *MockDataNodeClient) Search(ctx context.Context, indexName string, shardID int32, query []byte, filterExpression []byte, aggs []byte, sort []string, from, size int32) (*pb.SearchResponse, error) {
+	args := m.Called(ctx, indexName, shardID, query, filterExpression, aggs, sort, from, size)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
@@ -109,7 +115,7 @@ func TestQueryExecutorSearchTwoShards(t *testing.T) {
 	// Setup mock data node clients
 	node1 := &MockDataNodeClient{nodeID: "node1"}
 	node1.On("IsConnected").Return(true)
-	node1.On("Search", ctx, "test-index", int32(0), mock.Anything, mock.Anything).Return(
+	node1.On("Search", ctx, "test-index", int32(0), mock.Anything, mock.Anything, mock.Anything, mock.Anything, int32(0), mock.Anything).Return(
 		&pb.SearchResponse{
 			TookMillis: 10,
 			Hits: &pb.SearchHits{
@@ -126,7 +132,7 @@ func TestQueryExecutorSearchTwoShards(t *testing.T) {
 
 	node2 := &MockDataNodeClient{nodeID: "node2"}
 	node2.On("IsConnected").Return(true)
-	node2.On("Search", ctx, "test-index", int32(1), mock.Anything, mock.Anything).Return(
+	node2.On("Search", ctx, "test-index", int32(1), mock.Anything, mock.Anything, mock.Anything, mock.Anything, int32(0), mock.Anything).Return(
 		&pb.SearchResponse{
 			TookMillis: 12,
 			Hits: &pb.SearchHits{
@@ -148,7 +154,7 @@ func TestQueryExecutorSearchTwoShards(t *testing.T) {
 
 	// Execute search
 	query := []byte(`{"match_all": {}}`)
-	result, err := executor.ExecuteSearch(ctx, "test-index", query, nil, 0, 10)
+	result, err := executor.ExecuteSearch(ctx, "test-index", query, nil, nil, nil, 0, 10)
 
 	// Verify results
 	require.NoError(t, err)
@@ -172,6 +178,171 @@ func TestQueryExecutorSearchTwoShards(t *testing.T) {
 	node2.AssertExpectations(t)
 }
 
+// TestQueryExecutorSearchWithAggregations tests that the aggs section reaches
+// every shard and that range buckets keep their bounds through the merge
+func TestQueryExecutorSearchWithAggregations(t *testing.T) {
+	logger := zap.NewNop()
+	ctx := context.Background()
+
+	masterClient := new(MockMasterClient)
+	masterClient.On("GetShardRouting", ctx, "test-index").Return(
+		map[int32]*pb.ShardRouting{
+			0: {ShardId: 0, Allocation: &pb.ShardAllocation{NodeId: "node1", State: pb.ShardAllocation_SHARD_STATE_STARTED}},
+			1: {ShardId: 1, Allocation: &pb.ShardAllocation{NodeId: "node2", State: pb.ShardAllocation_SHARD_STATE_STARTED}},
+		},
+		nil,
+	)
+
+	aggs := []byte(`{"prices":{"range":{"field":"price","ranges":[{"to":100},{"from":100}]}}}`)
+	hundred := 100.0
+	shardResponse := func(below, above int64) *pb.SearchResponse {
+		return &pb.SearchResponse{
+			Hits: &pb.SearchHits{Total: &pb.TotalHits{Value: below + above, Relation: "eq"}},
+			Aggregations: map[string]*pb.AggregationResult{
+				"prices": {
+					Type: "range",
+					Buckets: []*pb.AggregationBucket{
+						{Key: "*-100.0", To: &hundred, DocCount: below},
+						{Key: "100.0-*", From: &hundred, DocCount: above},
+					},
+				},
+			},
+		}
+	}
+
+	node1 := &MockDataNodeClient{nodeID: "node1"}
+	node1.On("IsConnected").Return(true)
+	node1.On("Search", ctx, "test-index", int32(0), mock.Anything, mock.Anything, aggs, mock.Anything, int32(0), mock.Anything).Return(
+		shardResponse(3, 1), nil,
+	)
+
+	node2 := &MockDataNodeClient{nodeID: "node2"}
+	node2.On("IsConnected").Return(true)
+	node2.On("Search", ctx, "test-index", int32(1), mock.Anything, mock.Anything, aggs, mock.Anything, int32(0), mock.Anything).Return(
+		shardResponse(2, 4), nil,
+	)
+
+	executor := NewQueryExecutor(masterClient, logger)
+	executor.RegisterDataNode(node1)
+	executor.RegisterDataNode(node2)
+
+	result, err := executor.ExecuteSearch(ctx, "test-index", []byte(`{"match_all": {}}`), nil, aggs, nil, 0, 0)
+	require.NoError(t, err)
+
+	prices, ok := result.Aggregations["prices"]
+	require.True(t, ok)
+	require.Len(t, prices.Buckets, 2)
+
+	assert.Equal(t, "*-100.0", prices.Buckets[0].Key)
+	assert.Equal(t, int64(5), prices.Buckets[0].DocCount)
+	assert.Nil(t, prices.Buckets[0].From)
+	require.NotNil(t, prices.Buckets[0].To)
+	assert.Equal(t, 100.0, *prices.Buckets[0].To)
+
+	assert.Equal(t, "100.0-*", prices.Buckets[1].Key)
+	assert.Equal(t, int64(5), prices.Buckets[1].DocCount)
+	require.NotNil(t, prices.Buckets[1].From)
+	assert.Equal(t, 100.0, *prices.Buckets[1].From)
+	assert.Nil(t, prices.Buckets[1].To)
+
+	node1.AssertExpectations(t)
+	node2.AssertExpectations(t)
+}
+
+func TestQueryExecutorSearchWithSubAggregations(t *testing.T) {
+	logger := zap.NewNop()
+	ctx := context.Background()
+
+	masterClient := new(MockMasterClient)
+	masterClient.On("GetShardRouting", ctx, "test-index").Return(
+		map[int32]*pb.ShardRouting{
+			0: {ShardId: 0, Allocation: &pb.ShardAllocation{NodeId: "node1", State: pb.ShardAllocation_SHARD_STATE_STARTED}},
+			1: {ShardId: 1, Allocation: &pb.ShardAllocation{NodeId: "node2", State: pb.ShardAllocation_SHARD_STATE_STARTED}},
+		},
+		nil,
+	)
+
+	const jan, feb = 1704067200000.0, 1706745600000.0
+	month := func(key float64, name string, count int64, sum float64) *pb.AggregationBucket {
+		return &pb.AggregationBucket{
+			Key:        name,
+			NumericKey: key,
+			DocCount:   count,
+			SubAggregations: map[string]*pb.AggregationResult{
+				"total": {Type: "sum", Sum: sum, Count: count},
+			},
+		}
+	}
+	category := func(key string, count int64, months ...*pb.AggregationBucket) *pb.AggregationBucket {
+		return &pb.AggregationBucket{
+			Key:      key,
+			DocCount: count,
+			SubAggregations: map[string]*pb.AggregationResult{
+				"months": {Type: "date_histogram", Buckets: months},
+			},
+		}
+	}
+
+	node1 := &MockDataNodeClient{nodeID: "node1"}
+	node1.On("IsConnected").Return(true)
+	node1.On("Search", ctx, "test-index", int32(0), mock.Anything, mock.Anything, mock.Anything, mock.Anything, int32(0), mock.Anything).Return(
+		&pb.SearchResponse{
+			Hits: &pb.SearchHits{Total: &pb.TotalHits{Value: 3, Relation: "eq"}},
+			Aggregations: map[string]*pb.AggregationResult{
+				"categories": {Type: "terms", Buckets: []*pb.AggregationBucket{
+					category("a", 3, month(jan, "2024-01-01T00:00:00.000Z", 3, 30)),
+				}},
+			},
+		}, nil,
+	)
+
+	node2 := &MockDataNodeClient{nodeID: "node2"}
+	node2.On("IsConnected").Return(true)
+	node2.On("Search", ctx, "test-index", int32(1), mock.Anything, mock.Anything, mock.Anything, mock.Anything, int32(0), mock.Anything).Return(
+		&pb.SearchResponse{
+			Hits: &pb.SearchHits{Total: &pb.TotalHits{Value: 6, Relation: "eq"}},
+			Aggregations: map[string]*pb.AggregationResult{
+				"categories": {Type: "terms", Buckets: []*pb.AggregationBucket{
+					category("b", 4, month(jan, "2024-01-01T00:00:00.000Z", 4, 8)),
+					category("a", 2,
+						month(jan, "2024-01-01T00:00:00.000Z", 1, 5),
+						month(feb, "2024-02-01T00:00:00.000Z", 1, 7)),
+				}},
+			},
+		}, nil,
+	)
+
+	executor := NewQueryExecutor(masterClient, logger)
+	executor.RegisterDataNode(node1)
+	executor.RegisterDataNode(node2)
+
+	result, err := executor.ExecuteSearch(ctx, "test-index", []byte(`{"match_all": {}}`), nil, nil, nil, 0, 0)
+	require.NoError(t, err)
+
+	categories := result.Aggregations["categories"]
+	require.NotNil(t, categories)
+	require.Len(t, categories.Buckets, 2)
+
+	a := categories.Buckets[0]
+	assert.Equal(t, "a", a.Key)
+	assert.Equal(t, int64(5), a.DocCount)
+
+	// Date buckets from both shards are merged by key and kept in date order
+	months := a.SubAggregations["months"]
+	require.NotNil(t, months)
+	require.Len(t, months.Buckets, 2)
+	assert.Equal(t, jan, months.Buckets[0].NumericKey)
+	assert.Equal(t, "2024-01-01T00:00:00.000Z", months.Buckets[0].Key)
+	assert.Equal(t, int64(4), months.Buckets[0].DocCount)
+	assert.Equal(t, 35.0, months.Buckets[0].SubAggregations["total"].Sum)
+	assert.Equal(t, feb, months.Buckets[1].NumericKey)
+	assert.Equal(t, 7.0, months.Buckets[1].SubAggregations["total"].Sum)
+
+	b := categories.Buckets[1]
+	assert.Equal(t, "b", b.Key)
+	assert.Equal(t, 8.0, b.SubAggregations["months"].Buckets[0].SubAggregations["total"].Sum)
+}
+
 // TestQueryExecutorSearchWithPagination tests global pagination
 func TestQueryExecutorSearchWithPagination(t *testing.T) {
 	logger := zap.NewNop()
@@ -198,7 +369,8 @@ func TestQueryExecutorSearchWithPagination(t *testing.T) {
 	}
 
 	node1.On("IsConnected").Return(true)
-	node1.On("Search", ctx, "test-index", int32(0), mock.Anything, mock.Anything).Return(
+	// The shard is asked for its top from+size hits
+	node1.On("Search", ctx, "test-index", int32(0), mock.Anything, mock.Anything, mock.Anything, mock.Anything, int32(0), int32(15)).Return(
 		&pb.SearchResponse{
 			TookMillis: 5,
 			Hits: &pb.SearchHits{
@@ -215,7 +387,7 @@ func TestQueryExecutorSearchWithPagination(t *testing.T) {
 	executor.RegisterDataNode(node1)
 
 	// Test pagination: from=10, size=5
-	result, err := executor.ExecuteSearch(ctx, "test-index", []byte(`{"match_all": {}}`), nil, 10, 5)
+	result, err := executor.ExecuteSearch(ctx, "test-index", []byte(`{"match_all": {}}`), nil, nil, nil, 10, 5)
 
 	// Verify results
 	require.NoError(t, err)
@@ -251,7 +423,7 @@ func TestQueryExecutorPartialShardFailure(t *testing.T) {
 	// Setup mock data nodes
 	node1 := &MockDataNodeClient{nodeID: "node1"}
 	node1.On("IsConnected").Return(true)
-	node1.On("Search", ctx, "test-index", int32(0), mock.Anything, mock.Anything).Return(
+	node1.On("Search", ctx, "test-index", int32(0), mock.Anything, mock.Anything, mock.Anything, mock.Anything, int32(0), mock.Anything).Return(
 		&pb.SearchResponse{
 			Hits: &pb.SearchHits{
 				Total: &pb.TotalHits{Value: 30, Relation: "eq"},
@@ -264,14 +436,14 @@ func TestQueryExecutorPartialShardFailure(t *testing.T) {
 	node2 := &MockDataNodeClient{nodeID: "node2"}
 	node2.On("IsConnected").Return(true)
 	// Node2 fails
-	node2.On("Search", ctx, "test-index", int32(1), mock.Anything, mock.Anything).Return(
+	node2.On("Search", ctx, "test-index", int32(1), mock.Anything, mock.Anything, mock.Anything, mock.Anything, int32(0), mock.Anything).Return(
 		(*pb.SearchResponse)(nil),
 		errors.New("connection timeout"),
 	)
 
 	node3 := &MockDataNodeClient{nodeID: "node3"}
 	node3.On("IsConnected").Return(true)
-	node3.On("Search", ctx, "test-index", int32(2), mock.Anything, mock.Anything).Return(
+	node3.On("Search", ctx, "test-index", int32(2), mock.Anything, mock.Anything, mock.Anything, mock.Anything, int32(0), mock.Anything).Return(
 		&pb.SearchResponse{
 			Hits: &pb.SearchHits{
 				Total: &pb.TotalHits{Value: 35, Relation: "eq"},
@@ -288,7 +460,7 @@ func TestQueryExecutorPartialShardFailure(t *testing.T) {
 	executor.RegisterDataNode(node3)
 
 	// Execute search (should succeed with partial results)
-	result, err := executor.ExecuteSearch(ctx, "test-index", []byte(`{"match_all": {}}`), nil, 0, 10)
+	result, err := executor.ExecuteSearch(ctx, "test-index", []byte(`{"match_all": {}}`), nil, nil, nil, 0, 10)
 
 	// Verify graceful degradation
 	require.NoError(t, err, "Search should succeed despite partial shard failure")
@@ -318,7 +490,7 @@ func TestQueryExecutorNoDataNodes(t *testing.T) {
 	executor := NewQueryExecutor(masterClient, logger)
 
 	// Execute search (should fail)
-	_, err := executor.ExecuteSearch(ctx, "test-index", []byte(`{"match_all": {}}`), nil, 0, 10)
+	_, err := executor.ExecuteSearch(ctx, "test-index", []byte(`{"match_all": {}}`), nil, nil, nil, 0, 10)
 
 	// Verify error
 	assert.Error(t, err, "Search should fail with no data nodes")
@@ -327,6 +499,891 @@ func TestQueryExecutorNoDataNodes(t *testing.T) {
 	masterClient.AssertExpectations(t)
 }
 
+// TestQueryExecutorPaginationAcrossShards tests that pages are cut from the
+// merged ranking rather than from each shard
+func TestQueryExecutorPaginationAcrossShards(t *testing.T) {
+	logger := zap.NewNop()
+	ctx := context.Background()
+
+	masterClient := new(MockMasterClient)
+	masterClient.On("GetShardRouting", ctx, "test-index").Return(
+		map[int32]*pb.ShardRouting{
+			0: {ShardId: 0, Allocation: &pb.ShardAllocation{NodeId: "node1", State: pb.ShardAllocation_SHARD_STATE_STARTED}},
+			1: {ShardId: 1, Allocation: &pb.ShardAllocation{NodeId: "node2", State: pb.ShardAllocation_SHARD_STATE_STARTED}},
+		},
+		nil,
+	)
+
+	// Shard 0 holds even scores, shard 1 odd scores, interleaving on merge
+	shardHits := func(offset int) []*pb.SearchHit {
+		hits := make([]*pb.SearchHit, 0, 4)
+		for i := 0; i < 4; i++ {
+			score := float64(8 - 2*i - offset)
+			hits = append(hits, &pb.SearchHit{Id: fmt.Sprintf("s%d-%d", offset, i), Score: score})
+		}
+		return hits
+	}
+
+	node1 := &MockDataNodeClient{nodeID: "node1"}
+	node1.On("IsConnected").Return(true)
+	node1.On("Search", ctx, "test-index", int32(0), mock.Anything, mock.Anything, mock.Anything, mock.Anything, int32(0), int32(4)).Return(
+		&pb.SearchResponse{Hits: &pb.SearchHits{Total: &pb.TotalHits{Value: 20}, MaxScore: 8, Hits: shardHits(0)}},
+		nil,
+	)
+
+	node2 := &MockDataNodeClient{nodeID: "node2"}
+	node2.On("IsConnected").Return(true)
+	node2.On("Search", ctx, "test-index", int32(1), mock.Anything, mock.Anything, mock.Anything, mock.Anything, int32(0), int32(4)).Return(
+		&pb.SearchResponse{Hits: &pb.SearchHits{Total: &pb.TotalHits{Value: 20}, MaxScore: 7, Hits: shardHits(1)}},
+		nil,
+	)
+
+	executor := NewQueryExecutor(masterClient, logger)
+	executor.RegisterDataNode(node1)
+	executor.RegisterDataNode(node2)
+
+	// Second page of two: scores 6 and 5
+	result, err := executor.ExecuteSearch(ctx, "test-index", []byte(`{"match_all": {}}`), nil, nil, nil, 2, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(40), result.TotalHits)
+	require.Len(t, result.Hits, 2)
+	assert.Equal(t, "s0-1", result.Hits[0].ID)
+	assert.Equal(t, "s1-1", result.Hits[1].ID)
+
+	node1.AssertExpectations(t)
+	node2.AssertExpectations(t)
+}
+
+// TestQueryExecutorSortedSearch tests that sorted shard pages are merged by
+// their sort values and the sort is forwarded to every shard
+func TestQueryExecutorSortedSearch(t *testing.T) {
+	logger := zap.NewNop()
+	ctx := context.Background()
+
+	masterClient := new(MockMasterClient)
+	masterClient.On("GetShardRouting", ctx, "test-index").Return(
+		map[int32]*pb.ShardRouting{
+			0: {ShardId: 0, Allocation: &pb.ShardAllocation{NodeId: "node1", State: pb.ShardAllocation_SHARD_STATE_STARTED}},
+			1: {ShardId: 1, Allocation: &pb.ShardAllocation{NodeId: "node2", State: pb.ShardAllocation_SHARD_STATE_STARTED}},
+		},
+		nil,
+	)
+
+	hit := func(id string, score float64, sortValues ...interface{}) *pb.SearchHit {
+		h := &pb.SearchHit{Id: id, Score: score}
+		for _, v := range sortValues {
+			value, err := structpb.NewValue(v)
+			require.NoError(t, err)
+			h.Sort = append(h.Sort, value)
+		}
+		return h
+	}
+
+	sortFields := []*SortField{
+		{Field: "@timestamp", Descending: true},
+		{Field: "host", Missing: "_first"},
+	}
+	clauses := []string{
+		`{"@timestamp":{"order":"desc"}}`,
+		`{"host":{"missing":"_first","order":"asc"}}`,
+	}
+
+	// Each shard returns its own page in sort order, missing timestamps last
+	node1 := &MockDataNodeClient{nodeID: "node1"}
+	node1.On("IsConnected").Return(true)
+	node1.On("Search", ctx, "test-index", int32(0), mock.Anything, mock.Anything, mock.Anything, clauses, int32(0), int32(4)).Return(
+		&pb.SearchResponse{Hits: &pb.SearchHits{Total: &pb.TotalHits{Value: 3}, Hits: []*pb.SearchHit{
+			hit("a", 9, 3000.0, "web-2"),
+			hit("b", 1, 1000.0, "web-1"),
+			hit("c", 5, nil, "web-1"),
+		}}},
+		nil,
+	)
+
+	node2 := &MockDataNodeClient{nodeID: "node2"}
+	node2.On("IsConnected").Return(true)
+	node2.On("Search", ctx, "test-index", int32(1), mock.Anything, mock.Anything, mock.Anything, clauses, int32(0), int32(4)).Return(
+		&pb.SearchResponse{Hits: &pb.SearchHits{Total: &pb.TotalHits{Value: 3}, Hits: []*pb.SearchHit{
+			hit("d", 2, 3000.0, nil),
+			hit("e", 7, 3000.0, "web-1"),
+			hit("f", 3, 2000.0, "web-3"),
+		}}},
+		nil,
+	)
+
+	executor := NewQueryExecutor(masterClient, logger)
+	executor.RegisterDataNode(node1)
+	executor.RegisterDataNode(node2)
+
+	result, err := executor.ExecuteSearch(ctx, "test-index", []byte(`{"match_all": {}}`), nil, nil, sortFields, 0, 4)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), result.TotalHits)
+
+	ids := make([]string, len(result.Hits))
+	for i, h := range result.Hits {
+		ids[i] = h.ID
+	}
+	assert.Equal(t, []string{"d", "e", "a", "f"}, ids)
+	assert.Equal(t, []interface{}{3000.0, nil}, result.Hits[0].Sort)
+	assert.Equal(t, []interface{}{3000.0, "web-2"}, result.Hits[2].Sort)
+
+	node1.AssertExpectations(t)
+	node2.AssertExpectations(t)
+}
+
+// TestQueryExecutorKNNSearch tests that only the k nearest across the shards
+// are hits of a knn search
+func TestQueryExecutorKNNSearch(t *testing.T) {
+	logger := zap.NewNop()
+	ctx := context.Background()
+
+	masterClient := new(MockMasterClient)
+	masterClient.On("GetShardRouting", ctx, "test-index").Return(
+		map[int32]*pb.ShardRouting{
+			0: {ShardId: 0, Allocation: &pb.ShardAllocation{NodeId: "node1", State: pb.ShardAllocation_SHARD_STATE_STARTED}},
+			1: {ShardId: 1, Allocation: &pb.ShardAllocation{NodeId: "node2", State: pb.ShardAllocation_SHARD_STATE_STARTED}},
+		},
+		nil,
+	)
+
+	// Each shard returns its three nearest
+	node1 := &MockDataNodeClient{nodeID: "node1"}
+	node1.On("IsConnected").Return(true)
+	node1.On("Search", ctx, "test-index", int32(0), mock.Anything, mock.Anything, mock.Anything, mock.Anything, int32(0), mock.Anything).Return(
+		&pb.SearchResponse{Hits: &pb.SearchHits{Total: &pb.TotalHits{Value: 3}, Hits: []*pb.SearchHit{
+			{Id: "a", Score: 0.9}, {Id: "b", Score: 0.6}, {Id: "c", Score: 0.3},
+		}}},
+		nil,
+	)
+	node2 := &MockDataNodeClient{nodeID: "node2"}
+	node2.On("IsConnected").Return(true)
+	node2.On("Search", ctx, "test-index", int32(1), mock.Anything, mock.Anything, mock.Anything, mock.Anything, int32(0), mock.Anything).Return(
+		&pb.SearchResponse{Hits: &pb.SearchHits{Total: &pb.TotalHits{Value: 3}, Hits: []*pb.SearchHit{
+			{Id: "d", Score: 0.8}, {Id: "e", Score: 0.5}, {Id: "f", Score: 0.1},
+		}}},
+		nil,
+	)
+
+	executor := NewQueryExecutor(masterClient, logger)
+	executor.RegisterDataNode(node1)
+	executor.RegisterDataNode(node2)
+
+	query := []byte(`{"knn":{"field":"embedding","query_vector":[0.1,0.2],"k":3,"num_candidates":10}}`)
+	result, err := executor.ExecuteSearch(ctx, "test-index", query, nil, nil, nil, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), result.TotalHits)
+	require.Len(t, result.Hits, 3)
+	assert.Equal(t, []string{"a", "d", "b"}, []string{result.Hits[0].ID, result.Hits[1].ID, result.Hits[2].ID})
+
+	// Pages are cut from the k nearest
+	result, err = executor.ExecuteSearch(ctx, "test-index", query, nil, nil, nil, 2, 10)
+	require.NoError(t, err)
+	require.Len(t, result.Hits, 1)
+	assert.Equal(t, "b", result.Hits[0].ID)
+
+	// Sorting on fields keeps every shard's nearest
+	result, err = executor.ExecuteSearch(ctx, "test-index", query, nil, nil, []*SortField{{Field: "price"}}, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), result.TotalHits)
+	assert.Len(t, result.Hits, 6)
+}
+
+// TestQueryExecutorHybridSearch tests that the sub-queries of a hybrid search
+// run separately and their hits are fused on the coordinator
+func TestQueryExecutorHybridSearch(t *testing.T) {
+	logger := zap.NewNop()
+	ctx := context.Background()
+
+	masterClient := new(MockMasterClient)
+	masterClient.On("GetShardRouting", ctx, "test-index").Return(
+		map[int32]*pb.ShardRouting{
+			0: {ShardId: 0, Allocation: &pb.ShardAllocation{NodeId: "node1", State: pb.ShardAllocation_SHARD_STATE_STARTED}},
+		},
+		nil,
+	)
+
+	match := `{"match":{"title":"laptop"}}`
+	knn := `{"knn":{"field":"embedding","query_vector":[0.1,0.2],"k":2}}`
+	isQuery := func(query string) interface{} {
+		return mock.MatchedBy(func(q []byte) bool { return string(q) == query })
+	}
+	filter := []byte{1, 2, 3}
+
+	// Every sub-query fetches pagination_depth hits, with its own expr filter
+	node1 := &MockDataNodeClient{nodeID: "node1"}
+	node1.On("IsConnected").Return(true)
+	node1.On("Search", ctx, "test-index", int32(0), isQuery(match), filter, mock.Anything, mock.Anything, int32(0), int32(10)).Return(
+		&pb.SearchResponse{Hits: &pb.SearchHits{Total: &pb.TotalHits{Value: 3}, Hits: []*pb.SearchHit{
+			{Id: "a", Score: 4}, {Id: "b", Score: 2}, {Id: "c", Score: 1},
+		}}},
+		nil,
+	)
+	node1.On("Search", ctx, "test-index", int32(0), isQuery(knn), []byte(nil), mock.Anything, mock.Anything, int32(0), int32(10)).Return(
+		&pb.SearchResponse{Hits: &pb.SearchHits{Total: &pb.TotalHits{Value: 5}, Hits: []*pb.SearchHit{
+			{Id: "c", Score: 0.9}, {Id: "d", Score: 0.5}, {Id: "e", Score: 0.2},
+		}}},
+		nil,
+	)
+
+	executor := NewQueryExecutor(masterClient, logger)
+	executor.RegisterDataNode(node1)
+
+	hybridQuery := func(fusion string) []byte {
+		return []byte(`{"hybrid":{"queries":[` + match + `,` + knn + `],"filter_expressions":["AQID",null],` +
+			`"pagination_depth":10,"fusion":` + fusion + `}}`)
+	}
+	ids := func(hits []*SearchHit) []string {
+		result := make([]string, len(hits))
+		for i, hit := range hits {
+			result[i] = hit.ID
+		}
+		return result
+	}
+
+	// Min-max normalized scores are averaged; a and c tie at 0.5 and keep
+	// the order they first appear in
+	result, err := executor.ExecuteSearch(ctx, "test-index", hybridQuery(`{"normalization":"min_max","combination":"arithmetic_mean"}`), nil, nil, nil, 0, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "c", "b"}, ids(result.Hits))
+	assert.InDelta(t, 0.5, result.MaxScore, 1e-9)
+	assert.InDelta(t, 1.0/6, result.Hits[2].Score, 1e-9)
+	// The knn sub-query matches only its k nearest
+	assert.Equal(t, int64(3), result.TotalHits)
+
+	// Weights favor the knn sub-query
+	result, err = executor.ExecuteSearch(ctx, "test-index", hybridQuery(`{"normalization":"min_max","combination":"arithmetic_mean","weights":[0.2,0.8]}`), nil, nil, nil, 0, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "a", "b"}, ids(result.Hits))
+	assert.InDelta(t, 0.8, result.MaxScore, 1e-9)
+
+	// Reciprocal rank fusion ignores scores
+	result, err = executor.ExecuteSearch(ctx, "test-index", hybridQuery(`{"combination":"rrf","rank_constant":60}`), nil, nil, nil, 1, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "d"}, ids(result.Hits))
+	assert.InDelta(t, 1.0/61, result.Hits[0].Score, 1e-9)
+	assert.InDelta(t, 1.0/61+1.0/63, result.MaxScore, 1e-9)
+
+	_, err = executor.ExecuteSearch(ctx, "test-index", hybridQuery(`{"combination":"rrf","weights":[1]}`), nil, nil, nil, 0, 3)
+	assert.Error(t, err)
+	_, err = executor.ExecuteSearch(ctx, "test-index", hybridQuery(`{"combination":"rrf"}`), nil, nil, []*SortField{{Field: "price"}}, 0, 3)
+	assert.Error(t, err)
+	_, err = executor.ExecuteSearch(ctx, "test-index", hybridQuery(`{"combination":"rrf"}`), nil, []byte(`{"n":{"value_count":{"field":"id"}}}`), nil, 0, 3)
+	assert.Error(t, err)
+}
+
+func TestNormalizeScores(t *testing.T) {
+	hits := []*SearchHit{{Score: 3}, {Score: 4}, {Score: 0}}
+	assert.Equal(t, []float64{0.75, 1, 0}, normalizeScores(hits, normalizationMinMax))
+	assert.Equal(t, []float64{0.6, 0.8, 0}, normalizeScores(hits, normalizationL2))
+
+	same := []*SearchHit{{Score: 2}, {Score: 2}}
+	assert.Equal(t, []float64{1, 1}, normalizeScores(same, normalizationMinMax))
+	assert.Empty(t, normalizeScores(nil, normalizationL2))
+}
+
+// TestMergePercentilesAggregation tests that percentiles come from the merged
+// shard digests rather than from averaging each shard's percentiles
+func TestMergePercentilesAggregation(t *testing.T) {
+	executor := NewQueryExecutor(new(MockMasterClient), zap.NewNop())
+
+	// One shard holds the fast requests and the other the slow ones
+	shard := func(aggType string, values map[string]float64, from, to int) *pb.AggregationResult {
+		digest := sketch.NewTDigest(sketch.DefaultCompression)
+		for v := from; v < to; v++ {
+			digest.Add(float64(v))
+		}
+		data, err := digest.MarshalBinary()
+		require.NoError(t, err)
+		return &pb.AggregationResult{Type: aggType, Count: digest.Count(), Values: values, Sketch: data}
+	}
+
+	result := executor.mergePercentilesAggregation([]*pb.AggregationResult{
+		shard("percentiles", map[string]float64{"50.0": 44.5, "99.0": 88.11}, 0, 90),
+		shard("percentiles", map[string]float64{"50.0": 954.5, "99.0": 999.09}, 900, 1010),
+		{Type: "percentiles"},
+	})
+	assert.Equal(t, int64(200), result.Count)
+	require.Len(t, result.Values, 2)
+	// The median of the union lies between the shards, not at their average
+	assert.InDelta(t, 904.5, result.Values["50.0"], 5)
+	assert.InDelta(t, 1007, result.Values["99.0"], 2)
+
+	ranks := executor.mergePercentilesAggregation([]*pb.AggregationResult{
+		shard("percentile_ranks", map[string]float64{"50.0": 56.7}, 0, 90),
+		shard("percentile_ranks", map[string]float64{"50.0": 0}, 900, 1010),
+	})
+	assert.Equal(t, "percentile_ranks", ranks.Type)
+	assert.InDelta(t, 25.5, ranks.Values["50.0"], 1)
+
+	empty := executor.mergePercentilesAggregation([]*pb.AggregationResult{{Type: "percentiles"}})
+	assert.Empty(t, empty.Values)
+}
+
+// TestMergeCardinalityAggregation tests that values seen by several shards
+// are counted once
+func TestMergeCardinalityAggregation(t *testing.T) {
+	executor := NewQueryExecutor(new(MockMasterClient), zap.NewNop())
+
+	shard := func(from, to int) *pb.AggregationResult {
+		hll := sketch.NewHyperLogLog(sketch.PrecisionFromThreshold(sketch.DefaultPrecisionThreshold))
+		for i := from; i < to; i++ {
+			hll.Add(fmt.Sprintf("user-%d", i))
+		}
+		data, err := hll.MarshalBinary()
+		require.NoError(t, err)
+		return &pb.AggregationResult{Type: "cardinality", Value: hll.Cardinality(), Sketch: data}
+	}
+
+	// Below the precision threshold the union is exact
+	result := executor.mergeCardinalityAggregation([]*pb.AggregationResult{shard(0, 1000), shard(500, 1500), shard(0, 1500)})
+	assert.Equal(t, int64(1500), result.Value)
+
+	result = executor.mergeCardinalityAggregation([]*pb.AggregationResult{shard(0, 20000), shard(10000, 30000)})
+	assert.InDelta(t, 30000, result.Value, 600)
+
+	// Shards without a sketch add their count
+	result = executor.mergeCardinalityAggregation([]*pb.AggregationResult{shard(0, 10), {Type: "cardinality", Value: 5}})
+	assert.Equal(t, int64(15), result.Value)
+}
+
+// TestMergeMetricAggregationsWithEmptyShard tests that a shard without
+// values does not pull min, max, avg or stats towards its zero defaults
+func TestMergeMetricAggregationsWithEmptyShard(t *testing.T) {
+	executor := NewQueryExecutor(new(MockMasterClient), zap.NewNop())
+
+	positive := []*pb.AggregationResult{
+		{Type: "min", Count: 2, Min: 5},
+		{Type: "min", Count: 0},
+	}
+	result := executor.mergeSimpleMetricAggregation(positive)
+	assert.Equal(t, int64(2), result.Count)
+	assert.Equal(t, 5.0, result.Min)
+
+	negative := []*pb.AggregationResult{
+		{Type: "max", Count: 0},
+		{Type: "max", Count: 3, Max: -4},
+	}
+	result = executor.mergeSimpleMetricAggregation(negative)
+	assert.Equal(t, -4.0, result.Max)
+
+	avg := []*pb.AggregationResult{
+		{Type: "avg", Count: 2, Sum: 20, Avg: 10},
+		{Type: "avg", Count: 0},
+	}
+	result = executor.mergeSimpleMetricAggregation(avg)
+	assert.Equal(t, 10.0, result.Avg)
+
+	stats := executor.mergeStatsAggregation([]*pb.AggregationResult{
+		{Type: "stats", Count: 0},
+		{Type: "stats", Count: 2, Min: 3, Max: 7, Sum: 10, Avg: 5},
+	}, false)
+	assert.Equal(t, int64(2), stats.Count)
+	assert.Equal(t, 3.0, stats.Min)
+	assert.Equal(t, 7.0, stats.Max)
+	assert.Equal(t, 5.0, stats.Avg)
+
+	// Without values on any shard the merged metric has none either
+	result = executor.mergeSimpleMetricAggregation([]*pb.AggregationResult{{Type: "min"}, {Type: "min"}})
+	assert.Equal(t, int64(0), result.Count)
+	assert.True(t, math.IsNaN(result.Min))
+	result = executor.mergeSimpleMetricAggregation([]*pb.AggregationResult{{Type: "avg"}})
+	assert.True(t, math.IsNaN(result.Avg))
+	stats = executor.mergeStatsAggregation([]*pb.AggregationResult{{Type: "stats"}}, false)
+	assert.Equal(t, int64(0), stats.Count)
+}
+
+// TestMergeAvgAggregationWithUnevenShards tests that avg weighs each shard
+// by its number of values rather than averaging the shard averages
+func TestMergeAvgAggregationWithUnevenShards(t *testing.T) {
+	executor := NewQueryExecutor(new(MockMasterClient), zap.NewNop())
+
+	result := executor.mergeSimpleMetricAggregation([]*pb.AggregationResult{
+		{Type: "avg", Count: 1, Sum: 100, Avg: 100},
+		{Type: "avg", Count: 9, Sum: 0, Avg: 0},
+	})
+	assert.Equal(t, int64(10), result.Count)
+	assert.Equal(t, 10.0, result.Avg)
+
+	stats := executor.mergeStatsAggregation([]*pb.AggregationResult{
+		{Type: "stats", Count: 1, Min: 100, Max: 100, Sum: 100, Avg: 100},
+		{Type: "stats", Count: 3, Min: 1, Max: 3, Sum: 6, Avg: 2},
+	}, false)
+	assert.Equal(t, 26.5, stats.Avg)
+	assert.Equal(t, 1.0, stats.Min)
+	assert.Equal(t, 100.0, stats.Max)
+}
+
+// TestMergeTermsAggregation tests that terms buckets cut by the shards'
+// shard_size are summed, ordered and cut to size with their error bounds
+func TestMergeTermsAggregation(t *testing.T) {
+	executor := NewQueryExecutor(new(MockMasterClient), zap.NewNop())
+
+	bucket := func(key string, count int64, maxPrice float64) *pb.AggregationBucket {
+		return &pb.AggregationBucket{
+			Key:      key,
+			DocCount: count,
+			SubAggregations: map[string]*pb.AggregationResult{
+				"max_price": {Type: "max", Max: maxPrice, Count: count},
+			},
+		}
+	}
+	shards := []*pb.AggregationResult{
+		{Type: "terms", DocCountErrorUpperBound: 4, SumOtherDocCount: 6,
+			Buckets: []*pb.AggregationBucket{bucket("a", 10, 5), bucket("b", 8, 30), bucket("c", 4, 1)}},
+		{Type: "terms", DocCountErrorUpperBound: 3, SumOtherDocCount: 2,
+			Buckets: []*pb.AggregationBucket{bucket("c", 9, 2), bucket("a", 5, 7), bucket("d", 3, 50)}},
+		{Type: "terms", Buckets: []*pb.AggregationBucket{bucket("d", 1, 40)}},
+	}
+	keys := func(result *AggregationResult) []string {
+		var keys []string
+		for _, bucket := range result.Buckets {
+			keys = append(keys, bucket.Key)
+		}
+		return keys
+	}
+
+	result := executor.mergeTermsAggregation(shards, map[string]interface{}{"size": 2.0}, nil)
+	assert.Equal(t, []string{"a", "c"}, keys(result))
+	assert.Equal(t, int64(15), result.Buckets[0].DocCount)
+	assert.Equal(t, 7.0, result.Buckets[0].SubAggregations["max_price"].Max)
+	assert.Equal(t, int64(7), result.DocCountErrorUpperBound)
+	// The shards' own other docs plus b and d, cut here
+	assert.Equal(t, int64(8+8+4), result.SumOtherDocCount)
+
+	result = executor.mergeTermsAggregation(shards, map[string]interface{}{
+		"order":         map[string]interface{}{"max_price": "desc"},
+		"min_doc_count": 5.0,
+	}, nil)
+	assert.Equal(t, []string{"b", "a", "c"}, keys(result))
+
+	result = executor.mergeTermsAggregation(shards, map[string]interface{}{
+		"order": []interface{}{map[string]interface{}{"_count": "asc"}},
+	}, nil)
+	assert.Equal(t, []string{"d", "b", "c", "a"}, keys(result))
+
+	// One shard with an unbounded error makes the whole error unbounded
+	shards[2].DocCountErrorUpperBound = -1
+	result = executor.mergeTermsAggregation(shards, nil, nil)
+	assert.Equal(t, int64(-1), result.DocCountErrorUpperBound)
+
+	assert.Negative(t, compareTermKeys("9", "10"))
+	assert.Negative(t, compareTermKeys("10", "a"))
+}
+
+func TestMergeHistogramAggregation(t *testing.T) {
+	executor := NewQueryExecutor(new(MockMasterClient), zap.NewNop())
+	day := func(date string) float64 {
+		parsed, err := time.Parse(time.RFC3339, date)
+		require.NoError(t, err)
+		return float64(parsed.UnixMilli())
+	}
+	bucket := func(key float64, keyAsString string, count int64) *pb.AggregationBucket {
+		return &pb.AggregationBucket{NumericKey: key, Key: keyAsString, DocCount: count}
+	}
+	keys := func(result *AggregationResult) []string {
+		var keys []string
+		for _, bucket := range result.Buckets {
+			keys = append(keys, fmt.Sprintf("%s:%d", bucket.Key, bucket.DocCount))
+		}
+		return keys
+	}
+
+	// New York days across the start of DST; each shard filled only its
+	// own range
+	shards := []*pb.AggregationResult{
+		{Type: "date_histogram", Buckets: []*pb.AggregationBucket{
+			bucket(day("2024-03-09T05:00:00Z"), "2024-03-09T00:00:00.000-05:00", 2)}},
+		{Type: "date_histogram", Buckets: []*pb.AggregationBucket{
+			bucket(day("2024-03-11T04:00:00Z"), "2024-03-11T00:00:00.000-04:00", 1)}},
+	}
+	body := map[string]interface{}{"calendar_interval": "day", "time_zone": "America/New_York"}
+	result := executor.mergeBucketAggregation(shards, body, nil)
+	assert.Equal(t, []string{
+		"2024-03-09T00:00:00.000-05:00:2",
+		"2024-03-10T00:00:00.000-05:00:0",
+		"2024-03-11T00:00:00.000-04:00:1",
+	}, keys(result))
+	assert.Equal(t, day("2024-03-10T05:00:00Z"), result.Buckets[1].NumericKey)
+
+	body["extended_bounds"] = map[string]interface{}{"max": "2024-03-12"}
+	body["hard_bounds"] = map[string]interface{}{"min": "2024-03-10"}
+	result = executor.mergeBucketAggregation(shards, body, nil)
+	assert.Equal(t, []string{
+		"2024-03-10T00:00:00.000-05:00:0",
+		"2024-03-11T00:00:00.000-04:00:1",
+		"2024-03-12T00:00:00.000-04:00:0",
+	}, keys(result))
+
+	// min_doc_count applies to the summed counts
+	shards[1].Buckets = append(shards[1].Buckets, bucket(day("2024-03-09T05:00:00Z"), "2024-03-09T00:00:00.000-05:00", 1))
+	result = executor.mergeBucketAggregation(shards, map[string]interface{}{
+		"calendar_interval": "day", "time_zone": "America/New_York", "min_doc_count": 2.0,
+	}, nil)
+	assert.Equal(t, []string{"2024-03-09T00:00:00.000-05:00:3"}, keys(result))
+
+	histograms := []*pb.AggregationResult{
+		{Type: "histogram", Buckets: []*pb.AggregationBucket{bucket(5, "", 1), bucket(15, "", 2)}},
+		{Type: "histogram", Buckets: []*pb.AggregationBucket{bucket(35, "", 1), bucket(15, "", 1)}},
+	}
+	result = executor.mergeBucketAggregation(histograms, map[string]interface{}{"interval": 10.0, "offset": 5.0}, nil)
+	var numericKeys []float64
+	for _, bucket := range result.Buckets {
+		numericKeys = append(numericKeys, bucket.NumericKey)
+	}
+	assert.Equal(t, []float64{5, 15, 25, 35}, numericKeys)
+
+	result = executor.mergeBucketAggregation(histograms, map[string]interface{}{"interval": 10.0, "offset": 5.0, "min_doc_count": 2.0}, nil)
+	require.Len(t, result.Buckets, 1)
+	assert.Equal(t, int64(3), result.Buckets[0].DocCount)
+}
+
+func TestMergeCompositeAggregation(t *testing.T) {
+	executor := NewQueryExecutor(new(MockMasterClient), zap.NewNop())
+
+	bucket := func(key string, count int64) *pb.AggregationBucket {
+		return &pb.AggregationBucket{
+			CompositeKey: []byte(key),
+			DocCount:     count,
+			SubAggregations: map[string]*pb.AggregationResult{
+				"total": {Type: "sum", Sum: float64(count)},
+			},
+		}
+	}
+	// Each shard's page holds its first two keys, host descending
+	shards := []*pb.AggregationResult{
+		{Type: "composite", Buckets: []*pb.AggregationBucket{
+			bucket(`{"day":1,"host":"b"}`, 3), bucket(`{"day":2,"host":"c"}`, 1)}},
+		{Type: "composite", Buckets: []*pb.AggregationBucket{
+			bucket(`{"day":1,"host":"b"}`, 2), bucket(`{"day":1,"host":"a"}`, 4)}},
+		{Type: "composite", Buckets: []*pb.AggregationBucket{
+			bucket(`{"day":null,"host":"z"}`, 5)}},
+	}
+	body := map[string]interface{}{
+		"size": 2.0,
+		"sources": []interface{}{
+			map[string]interface{}{"day": map[string]interface{}{"histogram": map[string]interface{}{"field": "day", "interval": 1.0, "missing_bucket": true}}},
+			map[string]interface{}{"host": map[string]interface{}{"terms": map[string]interface{}{"field": "host", "order": "desc"}}},
+		},
+	}
+
+	result := executor.mergeCompositeAggregation(shards, body, nil)
+	require.Len(t, result.Buckets, 2)
+	// The missing day sorts first
+	assert.Equal(t, map[string]interface{}{"day": nil, "host": "z"}, result.Buckets[0].CompositeKey)
+	assert.Equal(t, `{"day":null,"host":"z"}`, result.Buckets[0].Key)
+	assert.Equal(t, map[string]interface{}{"day": 1.0, "host": "b"}, result.Buckets[1].CompositeKey)
+	assert.Equal(t, int64(5), result.Buckets[1].DocCount)
+	assert.Equal(t, 5.0, result.Buckets[1].SubAggregations["total"].Sum)
+	assert.Equal(t, result.Buckets[1].CompositeKey, result.AfterKey)
+
+	// The default size takes every key the shards returned
+	delete(body, "size")
+	result = executor.mergeCompositeAggregation(shards, body, nil)
+	var counts []int64
+	for _, bucket := range result.Buckets {
+		counts = append(counts, bucket.DocCount)
+	}
+	assert.Equal(t, []int64{5, 5, 4, 1}, counts)
+	assert.Equal(t, map[string]interface{}{"day": 2.0, "host": "c"}, result.AfterKey)
+
+	// No buckets, no after_key
+	result = executor.mergeCompositeAggregation([]*pb.AggregationResult{{Type: "composite"}}, body, nil)
+	assert.Empty(t, result.Buckets)
+	assert.Nil(t, result.AfterKey)
+}
+
+func TestQueryExecutorSearchWithPipelineAggregations(t *testing.T) {
+	ctx := context.Background()
+
+	masterClient := new(MockMasterClient)
+	masterClient.On("GetShardRouting", ctx, "test-index").Return(
+		map[int32]*pb.ShardRouting{
+			0: {ShardId: 0, Allocation: &pb.ShardAllocation{NodeId: "node1", State: pb.ShardAllocation_SHARD_STATE_STARTED}},
+		},
+		nil,
+	)
+
+	aggs := []byte(`{
+		"per_day": {
+			"date_histogram": {"field": "ts", "fixed_interval": "1d"},
+			"aggs": {
+				"sales": {"sum": {"field": "price"}},
+				"sales_deriv": {"derivative": {"buckets_path": "sales", "unit": "1h"}}
+			}
+		},
+		"best_day": {"max_bucket": {"buckets_path": "per_day>sales"}}
+	}`)
+	// The shard is sent the aggregations without their pipelines
+	shardAggs := mock.MatchedBy(func(raw []byte) bool {
+		var defs map[string]map[string]interface{}
+		if err := json.Unmarshal(raw, &defs); err != nil {
+			return false
+		}
+		_, hasBest := defs["best_day"]
+		subAggs, _ := defs["per_day"]["aggs"].(map[string]interface{})
+		_, hasDeriv := subAggs["sales_deriv"]
+		return len(defs) == 1 && len(subAggs) == 1 && !hasBest && !hasDeriv
+	})
+
+	day := func(n int, sales float64) *pb.AggregationBucket {
+		return &pb.AggregationBucket{
+			Key:        fmt.Sprintf("2024-01-0%dT00:00:00.000Z", n),
+			NumericKey: float64(n * 24 * 60 * 60 * 1000),
+			DocCount:   1,
+			SubAggregations: map[string]*pb.AggregationResult{
+				"sales": {Type: "sum", Sum: sales, Count: 1},
+			},
+		}
+	}
+	node1 := &MockDataNodeClient{nodeID: "node1"}
+	node1.On("IsConnected").Return(true)
+	node1.On("Search", ctx, "test-index", int32(0), mock.Anything, mock.Anything, shardAggs, mock.Anything, int32(0), mock.Anything).Return(
+		&pb.SearchResponse{
+			Hits: &pb.SearchHits{Total: &pb.TotalHits{Value: 3, Relation: "eq"}},
+			Aggregations: map[string]*pb.AggregationResult{
+				"per_day": {Type: "date_histogram", Buckets: []*pb.AggregationBucket{day(1, 24), day(2, 72), day(3, 48)}},
+			},
+		}, nil,
+	)
+
+	executor := NewQueryExecutor(masterClient, zap.NewNop())
+	executor.RegisterDataNode(node1)
+
+	result, err := executor.ExecuteSearch(ctx, "test-index", []byte(`{"match_all": {}}`), nil, aggs, nil, 0, 0)
+	require.NoError(t, err)
+
+	buckets := result.Aggregations["per_day"].Buckets
+	require.Len(t, buckets, 3)
+	assert.NotContains(t, buckets[0].SubAggregations, "sales_deriv")
+	deriv := buckets[1].SubAggregations["sales_deriv"]
+	require.NotNil(t, deriv)
+	assert.Equal(t, 48.0, deriv.PipelineValue)
+	require.NotNil(t, deriv.NormalizedValue)
+	assert.Equal(t, 2.0, *deriv.NormalizedValue)
+	assert.Equal(t, -24.0, buckets[2].SubAggregations["sales_deriv"].PipelineValue)
+
+	best := result.Aggregations["best_day"]
+	require.NotNil(t, best)
+	assert.Equal(t, 72.0, best.PipelineValue)
+	assert.Equal(t, []string{"2024-01-02T00:00:00.000Z"}, best.Keys)
+
+	// Pipelines that cannot be evaluated where they are declared fail the
+	// search before the shards are asked
+	_, err = executor.ExecuteSearch(ctx, "test-index", []byte(`{"match_all": {}}`), nil,
+		[]byte(`{"d": {"derivative": {"buckets_path": "_count"}}}`), nil, 0, 0)
+	assert.ErrorContains(t, err, "must be declared inside a multi-bucket aggregation")
+	node1.AssertNumberOfCalls(t, "Search", 1)
+}
+
+func TestSplitPipelineAggregations(t *testing.T) {
+	shardAggs, defs, err := splitPipelineAggregations([]byte(`{"a": {"avg": {"field": "x"}}}`))
+	require.NoError(t, err)
+	assert.Nil(t, defs)
+	assert.JSONEq(t, `{"a": {"avg": {"field": "x"}}}`, string(shardAggs))
+
+	shardAggs, defs, err = splitPipelineAggregations([]byte(`{
+		"t": {"terms": {"field": "f"}, "aggs": {"keep": {"bucket_selector": {"buckets_path": {"c": "_count"}, "script": "params.c > 1"}}}},
+		"total": {"sum_bucket": {"buckets_path": "t>_count"}}
+	}`))
+	require.NoError(t, err)
+	assert.NotNil(t, defs)
+	assert.JSONEq(t, `{"t": {"terms": {"field": "f"}}}`, string(shardAggs))
+
+	for aggs, want := range map[string]string{
+		`{"c": {"cumulative_sum": {"buckets_path": "_count"}}}`:                                       "must be declared inside",
+		`{"t": {"terms": {"field": "f"}, "aggs": {"d": {"derivative": {"buckets_path": "_count"}}}}}`: "histogram or date_histogram as parent",
+		`{"s": {"stats": {"field": "f"}, "aggs": {"b": {"bucket_sort": {"size": 1}}}}}`:               "multi-bucket aggregation as parent",
+	} {
+		_, _, err := splitPipelineAggregations([]byte(aggs))
+		assert.ErrorContains(t, err, want, aggs)
+	}
+}
+
+// pipelineTestHistogram is a histogram over five buckets whose "sales"
+// sums are 10, none (an empty bucket), 30, 20 and 60
+func pipelineTestHistogram() map[string]*AggregationResult {
+	sales := []float64{10, 0, 30, 20, 60}
+	histogram := &AggregationResult{Type: "histogram"}
+	for i, sum := range sales {
+		count := int64(i + 1)
+		if i == 1 {
+			count = 0
+		}
+		histogram.Buckets = append(histogram.Buckets, &AggregationBucket{
+			NumericKey: float64(i * 10),
+			DocCount:   count,
+			SubAggregations: map[string]*AggregationResult{
+				"sales": {Type: "sum", Sum: sum, Count: count},
+			},
+		})
+	}
+	return map[string]*AggregationResult{"histo": histogram}
+}
+
+// pipelineValues returns a pipeline's value in each bucket: nil where there
+// is none, "NaN" where it is NaN
+func pipelineValues(agg *AggregationResult, name string) []interface{} {
+	values := make([]interface{}, len(agg.Buckets))
+	for i, bucket := range agg.Buckets {
+		switch result := bucket.SubAggregations[name]; {
+		case result == nil:
+		case math.IsNaN(result.PipelineValue):
+			values[i] = "NaN"
+		default:
+			values[i] = result.PipelineValue
+		}
+	}
+	return values
+}
+
+func applyTestPipelines(t *testing.T, pipelines string) map[string]*AggregationResult {
+	t.Helper()
+	var defs map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"histo": {"histogram": {"field": "x", "interval": 10}, "aggs": {
+		"sales": {"sum": {"field": "price"}}, `+pipelines+`}}}`), &defs))
+	results := pipelineTestHistogram()
+	require.NoError(t, applyPipelineAggregations(defs, results))
+	return results
+}
+
+func TestParentPipelineAggregations(t *testing.T) {
+	results := applyTestPipelines(t, `
+		"deriv": {"derivative": {"buckets_path": "sales"}},
+		"deriv_zeros": {"derivative": {"buckets_path": "sales", "gap_policy": "insert_zeros"}},
+		"second_deriv": {"derivative": {"buckets_path": "deriv"}},
+		"cumulative": {"cumulative_sum": {"buckets_path": "sales"}},
+		"diff": {"serial_diff": {"buckets_path": "sales", "lag": 2}},
+		"moving_avg": {"moving_fn": {"buckets_path": "sales", "window": 2, "script": "MovingFunctions.unweightedAvg(values)"}},
+		"ratio": {"bucket_script": {"buckets_path": {"s": "sales", "c": "_count"}, "script": {"source": "params.s / params.c * params.k", "params": {"k": 2}}}}`)
+	histo := results["histo"]
+
+	// Gaps are skipped: the derivative after one is against the last value
+	assert.Equal(t, []interface{}{nil, nil, 20.0, -10.0, 40.0}, pipelineValues(histo, "deriv"))
+	assert.Equal(t, []interface{}{nil, -10.0, 30.0, -10.0, 40.0}, pipelineValues(histo, "deriv_zeros"))
+	assert.Equal(t, []interface{}{nil, nil, nil, -30.0, 50.0}, pipelineValues(histo, "second_deriv"))
+	assert.Equal(t, []interface{}{10.0, 10.0, 40.0, 60.0, 120.0}, pipelineValues(histo, "cumulative"))
+	assert.Equal(t, []interface{}{nil, nil, 20.0, nil, 30.0}, pipelineValues(histo, "diff"))
+	// The window is the two values before each bucket, empty for the first
+	assert.Equal(t, []interface{}{"NaN", nil, 10.0, 20.0, 25.0}, pipelineValues(histo, "moving_avg"))
+	assert.Equal(t, []interface{}{20.0, nil, 20.0, 10.0, 24.0}, pipelineValues(histo, "ratio"))
+}
+
+func TestBucketSelectorAndSort(t *testing.T) {
+	keys := func(agg *AggregationResult) []float64 {
+		var keys []float64
+		for _, bucket := range agg.Buckets {
+			keys = append(keys, bucket.NumericKey)
+		}
+		return keys
+	}
+
+	results := applyTestPipelines(t, `
+		"big": {"bucket_selector": {"buckets_path": {"s": "sales"}, "script": "params.s >= 20"}}`)
+	assert.Equal(t, []float64{20, 30, 40}, keys(results["histo"]))
+
+	results = applyTestPipelines(t, `
+		"top": {"bucket_sort": {"sort": [{"sales": {"order": "desc"}}], "size": 2}}`)
+	assert.Equal(t, []float64{40, 20}, keys(results["histo"]))
+
+	// Sorting by a pipeline, which runs first; the empty bucket has no
+	// sales and is dropped
+	results = applyTestPipelines(t, `
+		"page": {"bucket_sort": {"sort": ["neg", {"_key": "desc"}], "from": 1}},
+		"neg": {"bucket_script": {"buckets_path": {"s": "sales"}, "script": "-params.s"}}`)
+	assert.Equal(t, []float64{20, 30, 0}, keys(results["histo"]))
+}
+
+func TestSiblingPipelineAggregations(t *testing.T) {
+	var defs map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"histo": {"histogram": {"field": "x", "interval": 10}, "aggs": {"sales": {"sum": {"field": "price"}}}},
+		"avg": {"avg_bucket": {"buckets_path": "histo>sales"}},
+		"max": {"max_bucket": {"buckets_path": "histo>sales"}},
+		"min": {"min_bucket": {"buckets_path": "histo>sales", "gap_policy": "insert_zeros"}},
+		"sum": {"sum_bucket": {"buckets_path": "histo>_count"}},
+		"stats": {"stats_bucket": {"buckets_path": "histo>sales"}}
+	}`), &defs))
+	results := pipelineTestHistogram()
+	require.NoError(t, applyPipelineAggregations(defs, results))
+
+	assert.Equal(t, 30.0, results["avg"].PipelineValue)
+	assert.Equal(t, 60.0, results["max"].PipelineValue)
+	assert.Equal(t, []string{"40.0"}, results["max"].Keys)
+	assert.Equal(t, 0.0, results["min"].PipelineValue)
+	assert.Equal(t, []string{"10.0"}, results["min"].Keys)
+	assert.Equal(t, 1.0+3+4+5, results["sum"].PipelineValue)
+
+	stats := results["stats"]
+	assert.Equal(t, int64(4), stats.Count)
+	assert.Equal(t, 10.0, stats.Min)
+	assert.Equal(t, 60.0, stats.Max)
+	assert.Equal(t, 120.0, stats.Sum)
+	assert.Equal(t, 30.0, stats.Avg)
+}
+
+func TestPipelineAggregationErrors(t *testing.T) {
+	for pipelines, want := range map[string]string{
+		`"d": {"derivative": {"buckets_path": "nope"}}`:                                          "no aggregation found",
+		`"d": {"derivative": {"buckets_path": "sales", "gap_policy": "fill"}}`:                   "unknown gap_policy",
+		`"m": {"moving_fn": {"buckets_path": "sales", "window": 2, "script": "values[0]"}}`:      "MovingFunctions",
+		`"b": {"bucket_script": {"buckets_path": {"s": "sales"}, "script": "params.s +"}}`:       "invalid script",
+		`"a": {"derivative": {"buckets_path": "b"}}, "b": {"derivative": {"buckets_path": "a"}}`: "cycle",
+	} {
+		var defs map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(`{"histo": {"histogram": {"field": "x", "interval": 10}, "aggs": {
+			"sales": {"sum": {"field": "price"}}, `+pipelines+`}}}`), &defs))
+		err := applyPipelineAggregations(defs, pipelineTestHistogram())
+		assert.ErrorContains(t, err, want, pipelines)
+	}
+}
+
+func TestMovingFunctions(t *testing.T) {
+	values := []float64{2, 4, 6, 8}
+	for script, want := range map[string]float64{
+		"MovingFunctions.max(values)":                                                   8,
+		"MovingFunctions.min(values)":                                                   2,
+		"MovingFunctions.sum(values)":                                                   20,
+		"MovingFunctions.unweightedAvg(values)":                                         5,
+		"MovingFunctions.linearWeightedAvg(values)":                                     (2*1 + 4*2 + 6*3 + 8*4) / 11.0,
+		"MovingFunctions.ewma(values, 0.5)":                                             6.25,
+		"MovingFunctions.holt(values, 1, 1)":                                            8,
+		"return MovingFunctions.stdDev(values, MovingFunctions.unweightedAvg(values));": math.Sqrt(5),
+		"MovingFunctions.ewma(values, params.alpha)":                                    6.25,
+	} {
+		fn, err := parseMovingFunction(script, map[string]interface{}{"alpha": 0.5})
+		require.NoError(t, err, script)
+		assert.InDelta(t, want, fn.eval(values), 1e-9, script)
+	}
+
+	fn, err := parseMovingFunction("MovingFunctions.sum(values)", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, fn.eval(nil))
+	fn, err = parseMovingFunction("MovingFunctions.max(values)", nil)
+	require.NoError(t, err)
+	assert.True(t, math.IsNaN(fn.eval(nil)))
+
+	for _, script := range []string{"MovingFunctions.median(values)", "MovingFunctions.ewma(values)", "MovingFunctions.max(1)"} {
+		_, err := parseMovingFunction(script, nil)
+		assert.Error(t, err, script)
+	}
+}
+
+// TestQueryExecutorResultWindowTooLarge tests that deep pages beyond the
+// result window are rejected
+func TestQueryExecutorResultWindowTooLarge(t *testing.T) {
+	logger := zap.NewNop()
+	ctx := context.Background()
+
+	masterClient := new(MockMasterClient)
+	executor := NewQueryExecutor(masterClient, logger)
+
+	_, err := executor.ExecuteSearch(ctx, "test-index", []byte(`{"match_all": {}}`), nil, nil, nil, MaxResultWindow, 1)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "result window is too large")
+
+	masterClient.AssertNotCalled(t, "GetShardRouting", mock.Anything, mock.Anything)
+}
+
 // TestQueryExecutorMasterClientError tests master client failure
 func TestQueryExecutorMasterClientError(t *testing.T) {
 	logger := zap.NewNop()
@@ -343,7 +1400,7 @@ func TestQueryExecutorMasterClientError(t *testing.T) {
 	executor := NewQueryExecutor(masterClient, logger)
 
 	// Execute search (should fail)
-	_, err := executor.ExecuteSearch(ctx, "test-index", []byte(`{"match_all": {}}`), nil, 0, 10)
+	_, err := executor.ExecuteSearch(ctx, "test-index", []byte(`{"match_all": {}}`), nil, nil, nil, 0, 10)
 
 	// Verify error
 	assert.Error(t, err, "Search should fail when master is unavailable")
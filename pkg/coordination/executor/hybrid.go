@@ -0,0 +1,304 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultKNNK is the k of a knn query that does not set one, as on the data
+// nodes
+const defaultKNNK = 10
+
+// Fusion techniques of a hybrid search, named as in the search request
+const (
+	normalizationMinMax       = "min_max"
+	normalizationL2           = "l2"
+	combinationArithmeticMean = "arithmetic_mean"
+	combinationRRF            = "rrf"
+	defaultRankConstant       = 60
+)
+
+// hybridSearch is the body of a {"hybrid": {...}} query. Every sub-query runs
+// as a search of its own and their ranked hits are fused here.
+type hybridSearch struct {
+	Queries []json.RawMessage `json:"queries"`
+	// FilterExpressions holds the serialized expr filter of each sub-query
+	// (nil where it has none)
+	FilterExpressions [][]byte     `json:"filter_expressions,omitempty"`
+	Fusion            hybridFusion `json:"fusion"`
+	PaginationDepth   int          `json:"pagination_depth,omitempty"`
+}
+
+// hybridFusion says how the ranked lists of a hybrid search are combined
+type hybridFusion struct {
+	Normalization string    `json:"normalization,omitempty"`
+	Combination   string    `json:"combination"`
+	Weights       []float64 `json:"weights,omitempty"`
+	RankConstant  int       `json:"rank_constant,omitempty"`
+}
+
+// queryClause returns the name and body of a query's top-level clause, or ""
+// if the query is not a single clause
+func queryClause(query []byte) (string, json.RawMessage) {
+	var clause map[string]json.RawMessage
+	if err := json.Unmarshal(query, &clause); err != nil || len(clause) != 1 {
+		return "", nil
+	}
+	for name, body := range clause {
+		return name, body
+	}
+	return "", nil
+}
+
+// knnLimit returns the k of a knn query body
+func knnLimit(body json.RawMessage) int {
+	var knn struct {
+		K int `json:"k"`
+	}
+	if err := json.Unmarshal(body, &knn); err != nil || knn.K <= 0 {
+		return defaultKNNK
+	}
+	return knn.K
+}
+
+// validate checks the fusion of a hybrid search of numQueries sub-queries
+func (f *hybridFusion) validate(numQueries int) error {
+	switch f.Combination {
+	case combinationArithmeticMean:
+		if f.Normalization != normalizationMinMax && f.Normalization != normalizationL2 {
+			return fmt.Errorf("unknown normalization technique [%s]", f.Normalization)
+		}
+	case combinationRRF:
+		if f.RankConstant < 0 {
+			return fmt.Errorf("rank_constant must be positive")
+		}
+	default:
+		return fmt.Errorf("unknown combination technique [%s]", f.Combination)
+	}
+	if len(f.Weights) > 0 && len(f.Weights) != numQueries {
+		return fmt.Errorf("hybrid query has %d sub-queries but %d weights", numQueries, len(f.Weights))
+	}
+	return nil
+}
+
+// executeHybridSearch runs the sub-queries of a hybrid search in parallel,
+// each for the top max(from+size, pagination_depth) hits, and fuses the
+// ranked lists into one. Aggregations run once over the union of the
+// sub-queries.
+func (qe *QueryExecutor) executeHybridSearch(ctx context.Context, indexName string, body json.RawMessage, filterExpression []byte, aggs []byte, sortFields []*SortField, from, size int) (*SearchResult, error) {
+	startTime := time.Now()
+
+	var hybrid hybridSearch
+	if err := json.Unmarshal(body, &hybrid); err != nil {
+		return nil, fmt.Errorf("invalid hybrid query: %w", err)
+	}
+	if len(hybrid.Queries) == 0 {
+		return nil, fmt.Errorf("hybrid query requires at least one sub-query")
+	}
+	if len(sortFields) > 0 {
+		return nil, fmt.Errorf("hybrid queries are ranked by their fused scores and cannot be sorted")
+	}
+	if hybrid.Fusion.Combination == "" {
+		hybrid.Fusion = hybridFusion{Normalization: normalizationMinMax, Combination: combinationArithmeticMean}
+	}
+	if err := hybrid.Fusion.validate(len(hybrid.Queries)); err != nil {
+		return nil, err
+	}
+
+	depth := from + size
+	if hybrid.PaginationDepth > depth {
+		depth = hybrid.PaginationDepth
+	}
+
+	filters := make([][]byte, len(hybrid.Queries))
+	hasSubFilters := false
+	for i := range hybrid.Queries {
+		filters[i] = filterExpression
+		if i < len(hybrid.FilterExpressions) && hybrid.FilterExpressions[i] != nil {
+			if filterExpression != nil {
+				return nil, fmt.Errorf("hybrid sub-queries cannot have expr filters alongside a filter on the whole query")
+			}
+			filters[i] = hybrid.FilterExpressions[i]
+			hasSubFilters = true
+		}
+	}
+
+	var union []byte
+	if len(aggs) > 0 {
+		// Aggregations run over every document any sub-query matches
+		if hasSubFilters {
+			return nil, fmt.Errorf("hybrid queries with aggregations cannot have expr filters in their sub-queries")
+		}
+		var err error
+		union, err = json.Marshal(map[string]interface{}{
+			"bool": map[string]interface{}{"should": hybrid.Queries},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize hybrid sub-queries: %w", err)
+		}
+	}
+
+	results := make([]*SearchResult, len(hybrid.Queries))
+	errs := make([]error, len(hybrid.Queries)+1)
+	var aggResult *SearchResult
+	var wg sync.WaitGroup
+
+	for i, query := range hybrid.Queries {
+		wg.Add(1)
+		go func(i int, query []byte) {
+			defer wg.Done()
+			results[i], errs[i] = qe.ExecuteSearch(ctx, indexName, query, filters[i], nil, nil, 0, depth)
+		}(i, query)
+	}
+	if union != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			aggResult, errs[len(hybrid.Queries)] = qe.ExecuteSearch(ctx, indexName, union, filterExpression, aggs, nil, 0, 0)
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if i == len(hybrid.Queries) {
+			return nil, fmt.Errorf("hybrid aggregations failed: %w", err)
+		}
+		return nil, fmt.Errorf("hybrid sub-query %d failed: %w", i, err)
+	}
+
+	result := &SearchResult{Hits: []*SearchHit{}}
+	lists := make([][]*SearchHit, len(results))
+	for i, sub := range results {
+		lists[i] = sub.Hits
+		// A hit of any sub-query is a hit of the hybrid query, so the largest
+		// total is a lower bound of the true one
+		if sub.TotalHits > result.TotalHits {
+			result.TotalHits = sub.TotalHits
+		}
+	}
+	if aggResult != nil {
+		result.Aggregations = aggResult.Aggregations
+	}
+
+	fused := fuseHybridHits(lists, hybrid.Fusion)
+	if len(fused) > 0 {
+		result.MaxScore = fused[0].Score
+	}
+
+	start := from
+	if start > len(fused) {
+		start = len(fused)
+	}
+	end := start + size
+	if end > len(fused) {
+		end = len(fused)
+	}
+	result.Hits = fused[start:end]
+	result.TookMillis = time.Since(startTime).Milliseconds()
+	return result, nil
+}
+
+// fuseHybridHits combines the ranked hit lists of a hybrid search into one,
+// best first. Scores are either normalized per list and averaged by weight,
+// with a document missing from a list scoring 0 there, or replaced by
+// reciprocal ranks. Equal scores keep the order documents first appear in.
+func fuseHybridHits(lists [][]*SearchHit, fusion hybridFusion) []*SearchHit {
+	weights := fusion.Weights
+	if len(weights) == 0 {
+		weights = make([]float64, len(lists))
+		for i := range weights {
+			weights[i] = 1
+		}
+	}
+	rankConstant := fusion.RankConstant
+	if rankConstant == 0 {
+		rankConstant = defaultRankConstant
+	}
+
+	fused := []*SearchHit{}
+	byID := make(map[string]*SearchHit)
+	for i, hits := range lists {
+		var scores []float64
+		if fusion.Combination != combinationRRF {
+			scores = normalizeScores(hits, fusion.Normalization)
+		}
+		for rank, hit := range hits {
+			var score float64
+			if fusion.Combination == combinationRRF {
+				score = weights[i] / float64(rankConstant+rank+1)
+			} else {
+				score = weights[i] * scores[rank]
+			}
+
+			if existing, ok := byID[hit.ID]; ok {
+				existing.Score += score
+				continue
+			}
+			merged := *hit
+			merged.Score = score
+			byID[hit.ID] = &merged
+			fused = append(fused, &merged)
+		}
+	}
+
+	if fusion.Combination == combinationArithmeticMean {
+		var total float64
+		for _, w := range weights {
+			total += w
+		}
+		for _, hit := range fused {
+			hit.Score /= total
+		}
+	}
+
+	sort.SliceStable(fused, func(i, j int) bool {
+		return fused[i].Score > fused[j].Score
+	})
+	return fused
+}
+
+// normalizeScores scales the scores of a ranked list into [0, 1]: linearly
+// between its lowest and highest score for min_max, or by the list's L2 norm
+// for l2. Under min_max a list whose scores are all equal normalizes to 1.
+func normalizeScores(hits []*SearchHit, technique string) []float64 {
+	scores := make([]float64, len(hits))
+	if len(hits) == 0 {
+		return scores
+	}
+
+	if technique == normalizationL2 {
+		var sumOfSquares float64
+		for _, hit := range hits {
+			sumOfSquares += hit.Score * hit.Score
+		}
+		norm := math.Sqrt(sumOfSquares)
+		for i, hit := range hits {
+			if norm > 0 {
+				scores[i] = hit.Score / norm
+			}
+		}
+		return scores
+	}
+
+	min, max := hits[0].Score, hits[0].Score
+	for _, hit := range hits[1:] {
+		min = math.Min(min, hit.Score)
+		max = math.Max(max, hit.Score)
+	}
+	for i, hit := range hits {
+		if max == min {
+			scores[i] = 1
+		} else {
+			scores[i] = (hit.Score - min) / (max - min)
+		}
+	}
+	return scores
+}
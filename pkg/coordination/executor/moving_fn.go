@@ -0,0 +1,225 @@
+package executor
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// movingFunction is a parsed moving_fn script: a call of one of the
+// MovingFunctions over the window's values, e.g.
+//
+//	MovingFunctions.ewma(values, 0.3)
+//	MovingFunctions.stdDev(values, MovingFunctions.unweightedAvg(values))
+type movingFunction struct {
+	name string
+	args []movingFnArg
+}
+
+// movingFnArg is an argument of a moving function: the window's values, a
+// number, or a nested call
+type movingFnArg struct {
+	values bool
+	number float64
+	call   *movingFunction
+}
+
+// movingFunctionArity is the number of arguments of each moving function
+var movingFunctionArity = map[string]int{
+	"max":               1,
+	"min":               1,
+	"sum":               1,
+	"unweightedAvg":     1,
+	"linearWeightedAvg": 1,
+	"stdDev":            2,
+	"ewma":              2,
+	"holt":              3,
+}
+
+// parseMovingFunction parses a moving_fn script. Numeric arguments may be
+// given as params.name, read from the script's params.
+func parseMovingFunction(source string, params map[string]interface{}) (*movingFunction, error) {
+	source = strings.TrimSpace(source)
+	source = strings.TrimSpace(strings.TrimPrefix(source, "return "))
+	source = strings.TrimSpace(strings.TrimRight(source, "; \t\n"))
+
+	fn, rest, err := parseMovingFnCall(source, params)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(rest) != "" {
+		return nil, fmt.Errorf("unexpected [%s] in script", strings.TrimSpace(rest))
+	}
+	if !fn.args[0].values {
+		return nil, fmt.Errorf("the first argument of MovingFunctions.%s must be values", fn.name)
+	}
+	return fn, nil
+}
+
+// parseMovingFnCall parses a call at the start of s, returning the input
+// after it
+func parseMovingFnCall(s string, params map[string]interface{}) (*movingFunction, string, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "MovingFunctions.") {
+		return nil, "", fmt.Errorf("script must call one of the MovingFunctions, got [%s]", s)
+	}
+	s = strings.TrimPrefix(s, "MovingFunctions.")
+	open := strings.IndexByte(s, '(')
+	if open < 0 {
+		return nil, "", fmt.Errorf("expected ( after MovingFunctions.%s", s)
+	}
+	fn := &movingFunction{name: strings.TrimSpace(s[:open])}
+	arity, ok := movingFunctionArity[fn.name]
+	if !ok {
+		return nil, "", fmt.Errorf("unknown function MovingFunctions.%s", fn.name)
+	}
+
+	s = s[open+1:]
+	for {
+		s = strings.TrimSpace(s)
+		if strings.HasPrefix(s, ")") {
+			s = s[1:]
+			break
+		}
+		if len(fn.args) > 0 {
+			if !strings.HasPrefix(s, ",") {
+				return nil, "", fmt.Errorf("expected , or ) in the arguments of MovingFunctions.%s", fn.name)
+			}
+			s = strings.TrimSpace(s[1:])
+		}
+
+		var arg movingFnArg
+		switch {
+		case strings.HasPrefix(s, "MovingFunctions."):
+			call, rest, err := parseMovingFnCall(s, params)
+			if err != nil {
+				return nil, "", err
+			}
+			arg.call, s = call, rest
+		default:
+			end := strings.IndexAny(s, ",)")
+			if end < 0 {
+				return nil, "", fmt.Errorf("unterminated arguments of MovingFunctions.%s", fn.name)
+			}
+			token := strings.TrimSpace(s[:end])
+			s = s[end:]
+			switch {
+			case token == "values":
+				arg.values = true
+			case strings.HasPrefix(token, "params."):
+				value, ok := toFloat64(params[strings.TrimPrefix(token, "params.")])
+				if !ok {
+					return nil, "", fmt.Errorf("unknown or non-numeric parameter [%s]", token)
+				}
+				arg.number = value
+			default:
+				value, err := strconv.ParseFloat(token, 64)
+				if err != nil {
+					return nil, "", fmt.Errorf("invalid argument [%s] of MovingFunctions.%s", token, fn.name)
+				}
+				arg.number = value
+			}
+		}
+		fn.args = append(fn.args, arg)
+	}
+
+	if len(fn.args) != arity {
+		return nil, "", fmt.Errorf("MovingFunctions.%s takes %d arguments, got %d", fn.name, arity, len(fn.args))
+	}
+	return fn, s, nil
+}
+
+func (a movingFnArg) eval(values []float64) float64 {
+	if a.call != nil {
+		return a.call.eval(values)
+	}
+	return a.number
+}
+
+// eval applies the function to a window of values. As in OpenSearch, an
+// empty window has no value, except for sum, which is zero.
+func (f *movingFunction) eval(values []float64) float64 {
+	switch f.name {
+	case "max", "min":
+		result := math.NaN()
+		for _, v := range values {
+			if math.IsNaN(result) || (f.name == "max" && v > result) || (f.name == "min" && v < result) {
+				result = v
+			}
+		}
+		return result
+
+	case "sum":
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		return sum
+
+	case "unweightedAvg":
+		if len(values) == 0 {
+			return math.NaN()
+		}
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		return sum / float64(len(values))
+
+	case "linearWeightedAvg":
+		// Weights grow by one per value, from a total weight of one
+		avg, totalWeight, current := 0.0, 1.0, 1.0
+		for _, v := range values {
+			avg += v * current
+			totalWeight += current
+			current++
+		}
+		if totalWeight == 1 {
+			return math.NaN()
+		}
+		return avg / totalWeight
+
+	case "stdDev":
+		avg := f.args[1].eval(values)
+		if math.IsNaN(avg) || len(values) == 0 {
+			return math.NaN()
+		}
+		variance := 0.0
+		for _, v := range values {
+			variance += (v - avg) * (v - avg)
+		}
+		return math.Sqrt(variance / float64(len(values)))
+
+	case "ewma":
+		alpha := f.args[1].eval(values)
+		avg := math.NaN()
+		for i, v := range values {
+			if i == 0 {
+				avg = v
+			} else {
+				avg = v*alpha + avg*(1-alpha)
+			}
+		}
+		return avg
+
+	case "holt":
+		// Double exponential smoothing: the level forecast one step ahead
+		alpha, beta := f.args[1].eval(values), f.args[2].eval(values)
+		if len(values) == 0 {
+			return math.NaN()
+		}
+		var s, b, lastS, lastB float64
+		for i, v := range values {
+			if i == 0 {
+				s, b = v, 0
+			} else {
+				s = alpha*v + (1-alpha)*(lastS+lastB)
+				b = beta*(s-lastS) + (1-beta)*lastB
+			}
+			lastS, lastB = s, b
+		}
+		return s
+	}
+	return math.NaN()
+}
@@ -0,0 +1,911 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/conjugate/conjugate/pkg/coordination/expressions"
+)
+
+// Pipeline aggregations compute over the merged results of other
+// aggregations rather than over documents, so the shards never see them: they
+// are stripped from the request sent to the shards and evaluated here after
+// the merge.
+
+// parentPipelineTypes are declared among the sub-aggregations of a
+// multi-bucket aggregation and work across its buckets
+var parentPipelineTypes = map[string]bool{
+	"derivative":      true,
+	"cumulative_sum":  true,
+	"moving_fn":       true,
+	"serial_diff":     true,
+	"bucket_script":   true,
+	"bucket_selector": true,
+	"bucket_sort":     true,
+}
+
+// histogramPipelineTypes read their parent's buckets as a series, so the
+// parent must be a histogram or date_histogram
+var histogramPipelineTypes = map[string]bool{
+	"derivative":     true,
+	"cumulative_sum": true,
+	"moving_fn":      true,
+	"serial_diff":    true,
+}
+
+// siblingPipelineTypes compute one value over the buckets of a sibling
+// multi-bucket aggregation
+var siblingPipelineTypes = map[string]bool{
+	"avg_bucket":   true,
+	"max_bucket":   true,
+	"min_bucket":   true,
+	"sum_bucket":   true,
+	"stats_bucket": true,
+}
+
+// multiBucketAggTypes are the aggregations pipelines may read buckets of
+var multiBucketAggTypes = map[string]bool{
+	"terms":          true,
+	"histogram":      true,
+	"date_histogram": true,
+	"range":          true,
+	"filters":        true,
+	"composite":      true,
+}
+
+func isPipelineAggregation(aggType string) bool {
+	return parentPipelineTypes[aggType] || siblingPipelineTypes[aggType]
+}
+
+// aggDefinitionType returns the type of the named aggregation among a
+// request's aggregation definitions
+func aggDefinitionType(defs map[string]interface{}, name string) string {
+	def, _ := defs[name].(map[string]interface{})
+	for key := range def {
+		switch key {
+		case "aggs", "aggregations", "meta":
+		default:
+			return key
+		}
+	}
+	return ""
+}
+
+// splitPipelineAggregations removes the pipeline aggregations from the aggs
+// section of a request, at any depth, returning what the shards are to
+// compute and the full definitions if there were any pipelines (nil
+// otherwise). Pipelines declared where they cannot be evaluated are rejected.
+func splitPipelineAggregations(aggs []byte) ([]byte, map[string]interface{}, error) {
+	if len(aggs) == 0 {
+		return aggs, nil, nil
+	}
+	var defs map[string]interface{}
+	if err := json.Unmarshal(aggs, &defs); err != nil {
+		// The shards report the malformed request
+		return aggs, nil, nil
+	}
+
+	shardDefs, found, err := stripPipelineAggregations(defs, "")
+	if err != nil || !found {
+		return aggs, nil, err
+	}
+	if len(shardDefs) == 0 {
+		return nil, defs, nil
+	}
+	shardAggs, err := json.Marshal(shardDefs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to serialize aggregations: %w", err)
+	}
+	return shardAggs, defs, nil
+}
+
+// stripPipelineAggregations copies aggregation definitions without their
+// pipelines, given the type of the aggregation they are sub-aggregations of
+// ("" at the top level), and reports whether it found any
+func stripPipelineAggregations(defs map[string]interface{}, parentType string) (map[string]interface{}, bool, error) {
+	stripped := make(map[string]interface{}, len(defs))
+	found := false
+	for name, raw := range defs {
+		aggType := aggDefinitionType(defs, name)
+		if isPipelineAggregation(aggType) {
+			found = true
+			switch {
+			case parentPipelineTypes[aggType] && parentType == "":
+				return nil, false, fmt.Errorf("%s aggregation [%s] must be declared inside a multi-bucket aggregation", aggType, name)
+			case histogramPipelineTypes[aggType] && parentType != "histogram" && parentType != "date_histogram":
+				return nil, false, fmt.Errorf("%s aggregation [%s] must have a histogram or date_histogram as parent", aggType, name)
+			case parentPipelineTypes[aggType] && !multiBucketAggTypes[parentType]:
+				return nil, false, fmt.Errorf("%s aggregation [%s] must have a multi-bucket aggregation as parent, not [%s]", aggType, name, parentType)
+			}
+			continue
+		}
+
+		def, _ := raw.(map[string]interface{})
+		_, subDefs := aggDefinition(defs, name)
+		if len(subDefs) == 0 {
+			stripped[name] = raw
+			continue
+		}
+		subStripped, subFound, err := stripPipelineAggregations(subDefs, aggType)
+		if err != nil {
+			return nil, false, err
+		}
+		if !subFound {
+			stripped[name] = raw
+			continue
+		}
+		found = true
+		copied := make(map[string]interface{}, len(def))
+		for key, value := range def {
+			switch key {
+			case "aggs", "aggregations":
+				if len(subStripped) > 0 {
+					copied[key] = subStripped
+				}
+			default:
+				copied[key] = value
+			}
+		}
+		stripped[name] = copied
+	}
+	return stripped, found, nil
+}
+
+// applyPipelineAggregations evaluates the pipeline aggregations among defs
+// over the merged results of one level of aggregations, in place. Deeper
+// levels go first, so that a pipeline may read the results of pipelines
+// below it; the parent pipelines of each multi-bucket aggregation then run
+// across its buckets, and last the sibling pipelines of this level.
+func applyPipelineAggregations(defs map[string]interface{}, results map[string]*AggregationResult) error {
+	names := sortedNames(defs)
+	for _, name := range names {
+		aggType := aggDefinitionType(defs, name)
+		agg := results[name]
+		if isPipelineAggregation(aggType) || agg == nil {
+			continue
+		}
+		_, subDefs := aggDefinition(defs, name)
+		if !containsPipelineAggregations(subDefs) {
+			continue
+		}
+		for _, bucket := range agg.Buckets {
+			if bucket.SubAggregations == nil {
+				bucket.SubAggregations = make(map[string]*AggregationResult)
+			}
+			if err := applyPipelineAggregations(subDefs, bucket.SubAggregations); err != nil {
+				return err
+			}
+		}
+		if err := applyParentPipelines(agg, subDefs); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range names {
+		aggType := aggDefinitionType(defs, name)
+		if !siblingPipelineTypes[aggType] {
+			continue
+		}
+		body, _ := aggDefinition(defs, name)
+		result, err := siblingPipeline(name, aggType, body, defs, results)
+		if err != nil {
+			return err
+		}
+		results[name] = result
+	}
+	return nil
+}
+
+func containsPipelineAggregations(defs map[string]interface{}) bool {
+	for name := range defs {
+		if isPipelineAggregation(aggDefinitionType(defs, name)) {
+			return true
+		}
+		if _, subDefs := aggDefinition(defs, name); containsPipelineAggregations(subDefs) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedNames(defs map[string]interface{}) []string {
+	names := make([]string, 0, len(defs))
+	for name := range defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyParentPipelines runs the parent pipelines among a multi-bucket
+// aggregation's sub-aggregations across its buckets. A pipeline reading
+// another runs after it; those that drop or reorder buckets run last.
+func applyParentPipelines(agg *AggregationResult, subDefs map[string]interface{}) error {
+	stage := func(aggType string) int {
+		switch aggType {
+		case "bucket_selector":
+			return 1
+		case "bucket_sort":
+			return 2
+		}
+		return 0
+	}
+
+	pending := make(map[string][]string)
+	for _, name := range sortedNames(subDefs) {
+		aggType := aggDefinitionType(subDefs, name)
+		if !parentPipelineTypes[aggType] {
+			continue
+		}
+		body, _ := aggDefinition(subDefs, name)
+		var deps []string
+		for _, path := range bucketsPaths(body) {
+			first := parsePipelinePath(path).aggs[0]
+			if parentPipelineTypes[aggDefinitionType(subDefs, first)] {
+				deps = append(deps, first)
+			}
+		}
+		pending[name] = deps
+	}
+
+	for len(pending) > 0 {
+		next := ""
+		for name, deps := range pending {
+			ready := true
+			for _, dep := range deps {
+				if _, waiting := pending[dep]; waiting {
+					ready = false
+					break
+				}
+			}
+			if !ready {
+				continue
+			}
+			if next == "" || stage(aggDefinitionType(subDefs, name)) < stage(aggDefinitionType(subDefs, next)) ||
+				(stage(aggDefinitionType(subDefs, name)) == stage(aggDefinitionType(subDefs, next)) && name < next) {
+				next = name
+			}
+		}
+		if next == "" {
+			waiting := make([]string, 0, len(pending))
+			for name := range pending {
+				waiting = append(waiting, name)
+			}
+			sort.Strings(waiting)
+			return fmt.Errorf("pipeline aggregations [%s] reference each other in a cycle", strings.Join(waiting, ", "))
+		}
+		delete(pending, next)
+
+		body, _ := aggDefinition(subDefs, next)
+		if err := applyParentPipeline(next, aggDefinitionType(subDefs, next), body, agg, subDefs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyParentPipeline runs one parent pipeline across the buckets of agg
+func applyParentPipeline(name, pipelineType string, body map[string]interface{}, agg *AggregationResult, subDefs map[string]interface{}) error {
+	policy, err := parseGapPolicy(body)
+	if err != nil {
+		return fmt.Errorf("%s aggregation [%s]: %w", pipelineType, name, err)
+	}
+
+	switch pipelineType {
+	case "bucket_script", "bucket_selector":
+		return applyBucketScript(name, pipelineType, body, policy, agg, subDefs)
+	case "bucket_sort":
+		return applyBucketSort(name, body, policy, agg, subDefs)
+	}
+
+	path, err := singleBucketsPath(name, pipelineType, body, subDefs)
+	if err != nil {
+		return err
+	}
+	values := make([]float64, len(agg.Buckets))
+	for i, bucket := range agg.Buckets {
+		values[i] = resolveBucketValue(agg.Type, bucket, path, policy)
+	}
+	setValue := func(bucket *AggregationBucket, value float64) *AggregationResult {
+		result := &AggregationResult{Type: pipelineType, PipelineValue: value}
+		bucket.SubAggregations[name] = result
+		return result
+	}
+
+	switch pipelineType {
+	case "derivative":
+		// The change from the last bucket with a value; with a unit, also
+		// per unit of the key, e.g. per second of a date_histogram
+		var unit float64
+		if raw, ok := body["unit"].(string); ok {
+			if unit, err = derivativeUnitMillis(raw); err != nil {
+				return fmt.Errorf("derivative aggregation [%s]: %w", name, err)
+			}
+		}
+		last := -1
+		for i, bucket := range agg.Buckets {
+			if math.IsNaN(values[i]) {
+				continue
+			}
+			if last >= 0 {
+				result := setValue(bucket, values[i]-values[last])
+				if unit > 0 {
+					normalized := result.PipelineValue / ((bucket.NumericKey - agg.Buckets[last].NumericKey) / unit)
+					result.NormalizedValue = &normalized
+				}
+			}
+			last = i
+		}
+
+	case "cumulative_sum":
+		// Gaps add nothing to the running total
+		sum := 0.0
+		for _, bucket := range agg.Buckets {
+			value := resolveBucketValue(agg.Type, bucket, path, gapInsertZeros)
+			if !math.IsNaN(value) && !math.IsInf(value, 0) {
+				sum += value
+			}
+			setValue(bucket, sum)
+		}
+
+	case "serial_diff":
+		lag := 1
+		if v, ok := body["lag"].(float64); ok {
+			lag = int(v)
+		}
+		if lag <= 0 {
+			return fmt.Errorf("serial_diff aggregation [%s]: [lag] must be a positive integer", name)
+		}
+		for i, bucket := range agg.Buckets {
+			if i >= lag && !math.IsNaN(values[i]) && !math.IsNaN(values[i-lag]) {
+				setValue(bucket, values[i]-values[i-lag])
+			}
+		}
+
+	case "moving_fn":
+		window, ok := body["window"].(float64)
+		if !ok || window <= 0 {
+			return fmt.Errorf("moving_fn aggregation [%s]: [window] must be a positive integer", name)
+		}
+		shift, _ := body["shift"].(float64)
+		source, params, err := scriptSource(body["script"])
+		if err != nil {
+			return fmt.Errorf("moving_fn aggregation [%s]: %w", name, err)
+		}
+		fn, err := parseMovingFunction(source, params)
+		if err != nil {
+			return fmt.Errorf("moving_fn aggregation [%s]: %w", name, err)
+		}
+
+		// The window slides over the buckets with values only
+		series := make([]float64, 0, len(values))
+		for _, value := range values {
+			if !math.IsNaN(value) {
+				series = append(series, value)
+			}
+		}
+		clamp := func(index int) int {
+			return min(max(index, 0), len(series))
+		}
+		index := 0
+		for i, bucket := range agg.Buckets {
+			if math.IsNaN(values[i]) {
+				continue
+			}
+			from := clamp(index - int(window) + int(shift))
+			to := clamp(index + int(shift))
+			if from > to {
+				from = to
+			}
+			setValue(bucket, fn.eval(series[from:to]))
+			index++
+		}
+	}
+	return nil
+}
+
+// applyBucketScript runs a bucket_script, which adds the value of a script
+// over the buckets_path variables to each bucket, or a bucket_selector, which
+// keeps only the buckets the script is true for
+func applyBucketScript(name, pipelineType string, body map[string]interface{}, policy gapPolicy, agg *AggregationResult, subDefs map[string]interface{}) error {
+	vars, err := bucketsPathVariables(name, pipelineType, body, subDefs)
+	if err != nil {
+		return err
+	}
+	source, params, err := scriptSource(body["script"])
+	if err != nil {
+		return fmt.Errorf("%s aggregation [%s]: %w", pipelineType, name, err)
+	}
+	expr, err := expressions.ParseScript(source)
+	if err != nil {
+		return fmt.Errorf("%s aggregation [%s]: invalid script: %w", pipelineType, name, err)
+	}
+	evaluator := expressions.NewEvaluator(expr)
+
+	kept := agg.Buckets[:0]
+	for _, bucket := range agg.Buckets {
+		values := make(map[string]interface{}, len(vars)+len(params))
+		for key, value := range params {
+			values[key] = value
+		}
+		skip := false
+		for variable, path := range vars {
+			value := resolveBucketValue(agg.Type, bucket, path, policy)
+			if math.IsNaN(value) && policy == gapSkip {
+				skip = true
+			}
+			values[variable] = value
+		}
+		source := func(path string) (interface{}, bool) {
+			value, ok := values[path]
+			return value, ok
+		}
+
+		if pipelineType == "bucket_selector" {
+			result, err := evaluator.Evaluate(source)
+			if err != nil {
+				return fmt.Errorf("bucket_selector aggregation [%s]: %w", name, err)
+			}
+			keep, isBool := result.(bool)
+			if result != nil && !isBool {
+				return fmt.Errorf("bucket_selector aggregation [%s]: script must return a boolean, got %v", name, result)
+			}
+			if keep {
+				kept = append(kept, bucket)
+			}
+			continue
+		}
+
+		kept = append(kept, bucket)
+		if skip {
+			continue
+		}
+		result, err := evaluator.Evaluate(source)
+		if err != nil {
+			return fmt.Errorf("bucket_script aggregation [%s]: %w", name, err)
+		}
+		if result == nil {
+			continue
+		}
+		value, ok := toFloat64(result)
+		if !ok {
+			return fmt.Errorf("bucket_script aggregation [%s]: script must return a number, got %v", name, result)
+		}
+		bucket.SubAggregations[name] = &AggregationResult{Type: pipelineType, PipelineValue: value}
+	}
+	agg.Buckets = kept
+	return nil
+}
+
+// applyBucketSort sorts a multi-bucket aggregation's buckets by the given
+// paths and keeps the page from..from+size. Buckets missing a sort value
+// are dropped.
+func applyBucketSort(name string, body map[string]interface{}, policy gapPolicy, agg *AggregationResult, subDefs map[string]interface{}) error {
+	type sortKey struct {
+		path pipelinePath
+		asc  bool
+	}
+	var criteria []interface{}
+	switch v := body["sort"].(type) {
+	case nil:
+	case []interface{}:
+		criteria = v
+	default:
+		criteria = []interface{}{v}
+	}
+	var keys []sortKey
+	for _, criterion := range criteria {
+		switch c := criterion.(type) {
+		case string:
+			keys = append(keys, sortKey{path: parsePipelinePath(c), asc: true})
+		case map[string]interface{}:
+			for path, spec := range c {
+				order, _ := spec.(string)
+				if obj, ok := spec.(map[string]interface{}); ok {
+					order, _ = obj["order"].(string)
+				}
+				keys = append(keys, sortKey{path: parsePipelinePath(path), asc: !strings.EqualFold(order, "desc")})
+			}
+		default:
+			return fmt.Errorf("bucket_sort aggregation [%s]: invalid sort %v", name, criterion)
+		}
+	}
+	for _, key := range keys {
+		if err := validatePipelinePath(key.path, subDefs); err != nil {
+			return fmt.Errorf("bucket_sort aggregation [%s]: %w", name, err)
+		}
+	}
+	from := 0
+	if v, ok := body["from"].(float64); ok {
+		if v < 0 {
+			return fmt.Errorf("bucket_sort aggregation [%s]: [from] must not be negative", name)
+		}
+		from = int(v)
+	}
+	size := -1
+	if v, ok := body["size"].(float64); ok {
+		if v < 1 {
+			return fmt.Errorf("bucket_sort aggregation [%s]: [size] must be greater than 0", name)
+		}
+		size = int(v)
+	}
+
+	buckets := agg.Buckets
+	if len(keys) > 0 {
+		type sortable struct {
+			bucket *AggregationBucket
+			values []float64
+		}
+		rows := make([]sortable, 0, len(buckets))
+	buckets:
+		for _, bucket := range buckets {
+			row := sortable{bucket: bucket, values: make([]float64, len(keys))}
+			for i, key := range keys {
+				if isKeyPath(key.path) && !numericKeyed(agg.Type) {
+					continue
+				}
+				row.values[i] = resolveBucketValue(agg.Type, bucket, key.path, policy)
+				if math.IsNaN(row.values[i]) {
+					continue buckets
+				}
+			}
+			rows = append(rows, row)
+		}
+		sort.SliceStable(rows, func(i, j int) bool {
+			for k, key := range keys {
+				var c int
+				if isKeyPath(key.path) && !numericKeyed(agg.Type) {
+					c = compareTermKeys(rows[i].bucket.Key, rows[j].bucket.Key)
+				} else {
+					c = compareFloats(rows[i].values[k], rows[j].values[k])
+				}
+				if c != 0 {
+					return (c < 0) == key.asc
+				}
+			}
+			return false
+		})
+		buckets = make([]*AggregationBucket, len(rows))
+		for i, row := range rows {
+			buckets[i] = row.bucket
+		}
+	}
+
+	if from >= len(buckets) {
+		buckets = nil
+	} else {
+		buckets = buckets[from:]
+	}
+	if size >= 0 && len(buckets) > size {
+		buckets = buckets[:size]
+	}
+	agg.Buckets = buckets
+	return nil
+}
+
+// siblingPipeline computes a sibling pipeline over the buckets of the
+// multi-bucket aggregation its buckets_path starts with, e.g. the maximum
+// of "sales_per_month>sales"
+func siblingPipeline(name, pipelineType string, body, defs map[string]interface{}, results map[string]*AggregationResult) (*AggregationResult, error) {
+	policy, err := parseGapPolicy(body)
+	if err != nil {
+		return nil, fmt.Errorf("%s aggregation [%s]: %w", pipelineType, name, err)
+	}
+	raw, _ := body["buckets_path"].(string)
+	if raw == "" {
+		return nil, fmt.Errorf("%s aggregation [%s] requires a [buckets_path]", pipelineType, name)
+	}
+	path := parsePipelinePath(raw)
+	target := path.aggs[0]
+	if len(path.aggs) < 2 || !multiBucketAggTypes[aggDefinitionType(defs, target)] {
+		return nil, fmt.Errorf("%s aggregation [%s]: buckets_path [%s] must start with a sibling multi-bucket aggregation, as in histogram>metric", pipelineType, name, raw)
+	}
+	inner := pipelinePath{aggs: path.aggs[1:], metric: path.metric}
+	_, subDefs := aggDefinition(defs, target)
+	if err := validatePipelinePath(inner, subDefs); err != nil {
+		return nil, fmt.Errorf("%s aggregation [%s]: %w", pipelineType, name, err)
+	}
+
+	result := &AggregationResult{Type: pipelineType, PipelineValue: math.NaN()}
+	agg := results[target]
+	if agg == nil {
+		agg = &AggregationResult{}
+	}
+	for _, bucket := range agg.Buckets {
+		value := resolveBucketValue(agg.Type, bucket, inner, policy)
+		if math.IsNaN(value) {
+			continue
+		}
+		key := bucketKeyString(agg.Type, bucket)
+		switch {
+		case result.Count == 0:
+			result.Min, result.Max = value, value
+			result.Keys = []string{key}
+		case pipelineType == "max_bucket" && value > result.Max, pipelineType == "min_bucket" && value < result.Min:
+			result.Keys = []string{key}
+		case pipelineType == "max_bucket" && value == result.Max, pipelineType == "min_bucket" && value == result.Min:
+			result.Keys = append(result.Keys, key)
+		}
+		result.Min = math.Min(result.Min, value)
+		result.Max = math.Max(result.Max, value)
+		result.Sum += value
+		result.Count++
+	}
+	if result.Count > 0 {
+		result.Avg = result.Sum / float64(result.Count)
+	}
+
+	switch pipelineType {
+	case "avg_bucket":
+		if result.Count > 0 {
+			result.PipelineValue = result.Avg
+		}
+	case "sum_bucket":
+		result.PipelineValue = result.Sum
+	case "max_bucket", "min_bucket":
+		if result.Keys == nil {
+			result.Keys = []string{}
+		}
+		if result.Count > 0 {
+			result.PipelineValue = result.Max
+			if pipelineType == "min_bucket" {
+				result.PipelineValue = result.Min
+			}
+		}
+	}
+	return result, nil
+}
+
+// gapPolicy is how a pipeline treats a bucket without a value, or any value
+// of an empty bucket
+type gapPolicy int
+
+const (
+	gapSkip        gapPolicy = iota // leave the bucket out
+	gapInsertZeros                  // take the value as zero
+	gapKeepValues                   // use a value if there is one, otherwise skip
+)
+
+func parseGapPolicy(body map[string]interface{}) (gapPolicy, error) {
+	switch raw, _ := body["gap_policy"].(string); raw {
+	case "", "skip":
+		return gapSkip, nil
+	case "insert_zeros":
+		return gapInsertZeros, nil
+	case "keep_values":
+		return gapKeepValues, nil
+	default:
+		return gapSkip, fmt.Errorf("unknown gap_policy [%s]", raw)
+	}
+}
+
+// pipelinePath is a parsed buckets_path: aggregation names separated by '>',
+// the last optionally followed by '.metric' or '[metric]', or one of the
+// special paths _count and _key
+type pipelinePath struct {
+	aggs   []string
+	metric string
+}
+
+func parsePipelinePath(path string) pipelinePath {
+	elements := strings.Split(path, ">")
+	last := elements[len(elements)-1]
+	var metric string
+	if i := strings.IndexByte(last, '['); i >= 0 && strings.HasSuffix(last, "]") {
+		last, metric = last[:i], last[i+1:len(last)-1]
+	} else {
+		last, metric, _ = strings.Cut(last, ".")
+	}
+	elements[len(elements)-1] = last
+	return pipelinePath{aggs: elements, metric: metric}
+}
+
+func (p pipelinePath) String() string {
+	s := strings.Join(p.aggs, ">")
+	if p.metric != "" {
+		s += "." + p.metric
+	}
+	return s
+}
+
+func isKeyPath(path pipelinePath) bool {
+	return len(path.aggs) == 1 && path.aggs[0] == "_key" && path.metric == ""
+}
+
+// validatePipelinePath checks that a path within a bucket reaches its doc
+// count, its key, or one of the sub-aggregations defs declares
+func validatePipelinePath(path pipelinePath, defs map[string]interface{}) error {
+	name := path.aggs[0]
+	switch {
+	case len(path.aggs) > 1:
+		return fmt.Errorf("buckets_path [%s] is not supported: paths may not pass through a multi-bucket aggregation", path)
+	case (name == "_count" || name == "_key") && path.metric == "":
+		return nil
+	case aggDefinitionType(defs, name) == "":
+		return fmt.Errorf("no aggregation found for buckets_path [%s]", path)
+	}
+	return nil
+}
+
+// bucketsPaths returns the paths of a pipeline's buckets_path, a single path
+// or a map of script variables to paths
+func bucketsPaths(body map[string]interface{}) []string {
+	switch v := body["buckets_path"].(type) {
+	case string:
+		return []string{v}
+	case map[string]interface{}:
+		paths := make([]string, 0, len(v))
+		for _, variable := range sortedNames(v) {
+			if path, ok := v[variable].(string); ok {
+				paths = append(paths, path)
+			}
+		}
+		return paths
+	}
+	return nil
+}
+
+func singleBucketsPath(name, pipelineType string, body, subDefs map[string]interface{}) (pipelinePath, error) {
+	raw, _ := body["buckets_path"].(string)
+	if raw == "" {
+		return pipelinePath{}, fmt.Errorf("%s aggregation [%s] requires a [buckets_path]", pipelineType, name)
+	}
+	path := parsePipelinePath(raw)
+	if err := validatePipelinePath(path, subDefs); err != nil {
+		return pipelinePath{}, fmt.Errorf("%s aggregation [%s]: %w", pipelineType, name, err)
+	}
+	return path, nil
+}
+
+// bucketsPathVariables reads the script variables of a bucket_script or
+// bucket_selector; a single path is the variable _value
+func bucketsPathVariables(name, pipelineType string, body, subDefs map[string]interface{}) (map[string]pipelinePath, error) {
+	vars := make(map[string]pipelinePath)
+	switch v := body["buckets_path"].(type) {
+	case string:
+		vars["_value"] = parsePipelinePath(v)
+	case map[string]interface{}:
+		for variable, raw := range v {
+			path, ok := raw.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s aggregation [%s]: buckets_path of [%s] must be a string", pipelineType, name, variable)
+			}
+			vars[variable] = parsePipelinePath(path)
+		}
+	}
+	if len(vars) == 0 {
+		return nil, fmt.Errorf("%s aggregation [%s] requires a [buckets_path]", pipelineType, name)
+	}
+	for _, path := range vars {
+		if err := validatePipelinePath(path, subDefs); err != nil {
+			return nil, fmt.Errorf("%s aggregation [%s]: %w", pipelineType, name, err)
+		}
+	}
+	return vars, nil
+}
+
+// resolveBucketValue reads a path within a bucket and applies the gap
+// policy: a missing value, or any value but the doc count of an empty
+// bucket, is a gap. NaN means the bucket is skipped.
+func resolveBucketValue(aggType string, bucket *AggregationBucket, path pipelinePath, policy gapPolicy) float64 {
+	value := bucketValue(aggType, bucket, path)
+	isCount := len(path.aggs) == 1 && path.aggs[0] == "_count"
+	if !math.IsNaN(value) && !math.IsInf(value, 0) && (bucket.DocCount > 0 || isCount) {
+		return value
+	}
+	switch policy {
+	case gapInsertZeros:
+		return 0
+	case gapKeepValues:
+		if math.IsInf(value, 0) {
+			return math.NaN()
+		}
+		return value
+	}
+	return math.NaN()
+}
+
+func bucketValue(aggType string, bucket *AggregationBucket, path pipelinePath) float64 {
+	name := path.aggs[0]
+	if path.metric == "" {
+		switch name {
+		case "_count":
+			return float64(bucket.DocCount)
+		case "_key":
+			if numericKeyed(aggType) {
+				return bucket.NumericKey
+			}
+			if key, err := strconv.ParseFloat(bucket.Key, 64); err == nil {
+				return key
+			}
+			return math.NaN()
+		}
+	}
+	agg := bucket.SubAggregations[name]
+	if agg == nil {
+		return math.NaN()
+	}
+	if path.metric == "_bucket_count" {
+		return float64(len(agg.Buckets))
+	}
+	return orderMetricValue(agg, path.metric)
+}
+
+func numericKeyed(aggType string) bool {
+	return aggType == "histogram" || aggType == "date_histogram"
+}
+
+// bucketKeyString is the key a max_bucket or min_bucket reports for a
+// bucket: date_histogram and string keys as they are, histogram keys as
+// decimals
+func bucketKeyString(aggType string, bucket *AggregationBucket) string {
+	if bucket.Key != "" || !numericKeyed(aggType) {
+		return bucket.Key
+	}
+	s := strconv.FormatFloat(bucket.NumericKey, 'f', -1, 64)
+	if !strings.ContainsAny(s, ".eE") {
+		s += ".0"
+	}
+	return s
+}
+
+// derivativeUnitMillis reads the unit of a derivative over a date_histogram,
+// such as "1s", "5m" or "day", in milliseconds
+func derivativeUnitMillis(unit string) (float64, error) {
+	units := map[string]float64{
+		"ms": 1, "s": 1000, "m": 60 * 1000, "h": 60 * 60 * 1000, "d": 24 * 60 * 60 * 1000, "w": 7 * 24 * 60 * 60 * 1000,
+	}
+	words := map[string]string{
+		"second": "1s", "minute": "1m", "hour": "1h", "day": "1d", "week": "1w",
+	}
+	if expanded, ok := words[unit]; ok {
+		unit = expanded
+	}
+	i := strings.IndexFunc(unit, func(r rune) bool { return r < '0' || r > '9' })
+	if i <= 0 {
+		return 0, fmt.Errorf("invalid unit [%s]", unit)
+	}
+	n, err := strconv.Atoi(unit[:i])
+	millis, ok := units[unit[i:]]
+	if err != nil || !ok || n <= 0 {
+		return 0, fmt.Errorf("invalid unit [%s]", unit)
+	}
+	return float64(n) * millis, nil
+}
+
+// scriptSource reads a pipeline's script: its source, either the script
+// itself or {"source": ..., "params": {...}}, and its params
+func scriptSource(raw interface{}) (string, map[string]interface{}, error) {
+	switch v := raw.(type) {
+	case string:
+		return v, nil, nil
+	case map[string]interface{}:
+		source, _ := v["source"].(string)
+		if source == "" {
+			source, _ = v["inline"].(string)
+		}
+		if source == "" {
+			return "", nil, fmt.Errorf("[script] requires a [source]")
+		}
+		if lang, ok := v["lang"].(string); ok && lang != "painless" && lang != "expression" {
+			return "", nil, fmt.Errorf("script lang [%s] is not supported", lang)
+		}
+		params, _ := v["params"].(map[string]interface{})
+		return source, params, nil
+	}
+	return "", nil, fmt.Errorf("[script] is required")
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,92 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// encodeSortClauses serializes sort fields into the clauses sent to the
+// shards, one {"<field>": {"order": ..., "missing": ...}} object per field
+func encodeSortClauses(sortFields []*SortField) ([]string, error) {
+	if len(sortFields) == 0 {
+		return nil, nil
+	}
+
+	clauses := make([]string, 0, len(sortFields))
+	for _, sf := range sortFields {
+		options := map[string]interface{}{"order": "asc"}
+		if sf.Descending {
+			options["order"] = "desc"
+		}
+		if sf.Missing != nil {
+			options["missing"] = sf.Missing
+		}
+
+		clause, err := json.Marshal(map[string]interface{}{sf.Field: options})
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize sort on [%s]: %w", sf.Field, err)
+		}
+		clauses = append(clauses, string(clause))
+	}
+	return clauses, nil
+}
+
+// compareSortValues orders two hits by the sort values the shards returned,
+// the same way the shards ordered them: numbers before strings, and missing
+// (nil) values last unless the field asks for them first
+func compareSortValues(sortFields []*SortField, a, b []interface{}) int {
+	for i, sf := range sortFields {
+		var av, bv interface{}
+		if i < len(a) {
+			av = a[i]
+		}
+		if i < len(b) {
+			bv = b[i]
+		}
+
+		if av == nil || bv == nil {
+			if av == nil && bv == nil {
+				continue
+			}
+			cmp := 1
+			if sf.Missing == "_first" {
+				cmp = -1
+			}
+			if bv == nil {
+				cmp = -cmp
+			}
+			return cmp
+		}
+
+		cmp := compareSortValue(av, bv)
+		if cmp != 0 {
+			if sf.Descending {
+				return -cmp
+			}
+			return cmp
+		}
+	}
+	return 0
+}
+
+// compareSortValue orders two non-nil sort values
+func compareSortValue(a, b interface{}) int {
+	af, aNum := a.(float64)
+	bf, bNum := b.(float64)
+	switch {
+	case aNum && bNum:
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		}
+		return 0
+	case aNum:
+		return -1
+	case bNum:
+		return 1
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
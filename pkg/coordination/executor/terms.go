@@ -0,0 +1,208 @@
+package executor
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/conjugate/conjugate/pkg/common/aggparams"
+	pb "github.com/conjugate/conjugate/pkg/common/proto"
+)
+
+// lessBucket reports whether bucket a sorts before bucket b in the order.
+// Metrics without a value sort last in either direction.
+func lessBucket(order aggparams.TermsOrder, a, b *AggregationBucket) bool {
+	for _, key := range order {
+		var c int
+		switch key.Path {
+		case "_count":
+			c = compareFloats(float64(a.DocCount), float64(b.DocCount))
+		case "_key":
+			c = compareTermKeys(a.Key, b.Key)
+		default:
+			va := orderMetricValue(a.SubAggregations[key.Path], key.Metric)
+			vb := orderMetricValue(b.SubAggregations[key.Path], key.Metric)
+			if math.IsNaN(va) || math.IsNaN(vb) {
+				if math.IsNaN(va) != math.IsNaN(vb) {
+					return math.IsNaN(vb)
+				}
+				continue
+			}
+			c = compareFloats(va, vb)
+		}
+		if c != 0 {
+			return (c < 0) == key.Asc
+		}
+	}
+	return false
+}
+
+// orderMetricValue returns the metric of a merged sub-aggregation an order
+// or buckets_path selects, or NaN if it has no value. Single-value metrics
+// and pipelines need no metric name.
+func orderMetricValue(agg *AggregationResult, metric string) float64 {
+	if agg == nil {
+		return math.NaN()
+	}
+	switch agg.Type {
+	case "percentiles", "percentile_ranks":
+		point, err := strconv.ParseFloat(metric, 64)
+		if err != nil {
+			return math.NaN()
+		}
+		for key, value := range agg.Values {
+			if p, err := strconv.ParseFloat(key, 64); err == nil && p == point {
+				return value
+			}
+		}
+		return math.NaN()
+	case "value_count":
+		return float64(agg.Count)
+	case "cardinality":
+		return float64(agg.Value)
+	case "avg", "sum", "min", "max":
+		metric = agg.Type
+	case "derivative", "cumulative_sum", "moving_fn", "serial_diff", "bucket_script",
+		"avg_bucket", "max_bucket", "min_bucket", "sum_bucket":
+		if metric == "normalized_value" && agg.NormalizedValue != nil {
+			return *agg.NormalizedValue
+		}
+		return agg.PipelineValue
+	}
+
+	switch metric {
+	case "count":
+		return float64(agg.Count)
+	case "sum":
+		return agg.Sum
+	}
+	if agg.Count == 0 {
+		return math.NaN()
+	}
+	switch metric {
+	case "min":
+		return agg.Min
+	case "max":
+		return agg.Max
+	case "avg":
+		return agg.Avg
+	case "sum_of_squares":
+		return agg.SumOfSquares
+	case "variance":
+		return agg.Variance
+	case "std_deviation":
+		return agg.StdDeviation
+	}
+	return math.NaN()
+}
+
+// compareTermKeys orders bucket keys as the data nodes do: numbers
+// numerically, before any other keys, which compare as strings
+func compareTermKeys(a, b string) int {
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	switch {
+	case errA == nil && errB == nil:
+		return compareFloats(fa, fb)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// mergeTermsAggregation merges terms aggregations. Each shard returned only
+// its top shard_size buckets, so a term's count sums the shards that returned
+// it. Terms under min_doc_count are dropped and the rest ordered as requested
+// and cut to size, their documents adding to sum_other_doc_count.
+// doc_count_error_upper_bound sums the shards' bounds on what their own cut
+// missed, and is -1 if any of them is unbounded.
+func (qe *QueryExecutor) mergeTermsAggregation(aggs []*pb.AggregationResult, body, subDefs map[string]interface{}) *AggregationResult {
+	if len(aggs) == 0 {
+		return nil
+	}
+
+	size := aggparams.DefaultTermsSize
+	if v, ok := body["size"].(float64); ok && v > 0 {
+		size = int(v)
+	}
+	minDocCount := int64(1)
+	if v, ok := body["min_doc_count"].(float64); ok && v >= 0 {
+		minDocCount = int64(v)
+	}
+	// The data nodes reject an invalid order, so there are no results to
+	// merge for one
+	order, err := aggparams.ParseTermsOrder(body["order"])
+	if err != nil {
+		return nil
+	}
+
+	result := &AggregationResult{Type: "terms"}
+	counts := make(map[string]int64)
+	subAggs := make(map[string][]map[string]*pb.AggregationResult)
+	var keys []string
+	for _, agg := range aggs {
+		result.SumOtherDocCount += agg.SumOtherDocCount
+		switch {
+		case result.DocCountErrorUpperBound < 0:
+		case agg.DocCountErrorUpperBound < 0:
+			result.DocCountErrorUpperBound = -1
+		default:
+			result.DocCountErrorUpperBound += agg.DocCountErrorUpperBound
+		}
+
+		for _, bucket := range agg.Buckets {
+			if _, ok := counts[bucket.Key]; !ok {
+				keys = append(keys, bucket.Key)
+			}
+			counts[bucket.Key] += bucket.DocCount
+			if len(bucket.SubAggregations) > 0 {
+				subAggs[bucket.Key] = append(subAggs[bucket.Key], bucket.SubAggregations)
+			}
+		}
+	}
+
+	// Sub-aggregations are merged before ranking only if the order reads
+	// them; otherwise just for the buckets that make the cut
+	bySubAggregation := order.BySubAggregation()
+	buckets := make([]*AggregationBucket, 0, len(keys))
+	for _, key := range keys {
+		if counts[key] < minDocCount {
+			continue
+		}
+		bucket := &AggregationBucket{Key: key, DocCount: counts[key]}
+		if bySubAggregation {
+			bucket.SubAggregations = qe.mergeSubAggregations(subAggs[key], subDefs)
+		}
+		buckets = append(buckets, bucket)
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return lessBucket(order, buckets[i], buckets[j])
+	})
+
+	if len(buckets) > size {
+		for _, bucket := range buckets[size:] {
+			result.SumOtherDocCount += bucket.DocCount
+		}
+		buckets = buckets[:size]
+	}
+	if !bySubAggregation {
+		for _, bucket := range buckets {
+			bucket.SubAggregations = qe.mergeSubAggregations(subAggs[bucket.Key], subDefs)
+		}
+	}
+	result.Buckets = buckets
+	return result
+}
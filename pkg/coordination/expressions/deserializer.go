@@ -0,0 +1,298 @@
+package expressions
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// maxDeserializeDepth bounds the nesting of a deserialized expression so a
+// malformed payload cannot exhaust the stack
+const maxDeserializeDepth = 256
+
+// Deserializer reads expression ASTs from the binary format written by
+// Serializer. Data nodes use it to rebuild filter expressions.
+type Deserializer struct {
+	r     *bytes.Reader
+	depth int
+}
+
+// NewDeserializer creates a new expression deserializer
+func NewDeserializer() *Deserializer {
+	return &Deserializer{}
+}
+
+// Deserialize decodes an expression from bytes
+func (d *Deserializer) Deserialize(data []byte) (Expression, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("expression data is empty")
+	}
+
+	d.r = bytes.NewReader(data)
+	d.depth = 0
+
+	expr, err := d.deserializeNode()
+	if err != nil {
+		return nil, err
+	}
+
+	if d.r.Len() > 0 {
+		return nil, fmt.Errorf("%d trailing bytes after expression", d.r.Len())
+	}
+
+	return expr, nil
+}
+
+// deserializeNode decodes a single expression node
+func (d *Deserializer) deserializeNode() (Expression, error) {
+	d.depth++
+	defer func() { d.depth-- }()
+	if d.depth > maxDeserializeDepth {
+		return nil, fmt.Errorf("expression nesting exceeds %d levels", maxDeserializeDepth)
+	}
+
+	exprType, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch ExpressionType(exprType) {
+	case ExprTypeConst:
+		return d.deserializeConst()
+	case ExprTypeField:
+		return d.deserializeField()
+	case ExprTypeBinaryOp:
+		return d.deserializeBinaryOp()
+	case ExprTypeUnaryOp:
+		return d.deserializeUnaryOp()
+	case ExprTypeTernary:
+		return d.deserializeTernary()
+	case ExprTypeFunction:
+		return d.deserializeFunction()
+	default:
+		return nil, fmt.Errorf("unknown expression type: %d", exprType)
+	}
+}
+
+// deserializeConst decodes a constant expression
+func (d *Deserializer) deserializeConst() (Expression, error) {
+	dataType, err := d.readDataType()
+	if err != nil {
+		return nil, err
+	}
+
+	switch dataType {
+	case DataTypeBool:
+		val, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+		return NewConstBool(val != 0), nil
+
+	case DataTypeInt64:
+		val, err := d.readUint64()
+		if err != nil {
+			return nil, err
+		}
+		return NewConstInt(int64(val)), nil
+
+	case DataTypeFloat64:
+		val, err := d.readUint64()
+		if err != nil {
+			return nil, err
+		}
+		return NewConstFloat(math.Float64frombits(val)), nil
+
+	case DataTypeString:
+		val, err := d.readString()
+		if err != nil {
+			return nil, err
+		}
+		return NewConstString(val), nil
+
+	default:
+		return nil, fmt.Errorf("unknown data type: %v", dataType)
+	}
+}
+
+// deserializeField decodes a field expression
+func (d *Deserializer) deserializeField() (Expression, error) {
+	dataType, err := d.readDataType()
+	if err != nil {
+		return nil, err
+	}
+
+	fieldPath, err := d.readString()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewField(fieldPath, dataType), nil
+}
+
+// deserializeBinaryOp decodes a binary operation expression
+func (d *Deserializer) deserializeBinaryOp() (Expression, error) {
+	op, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	operator := BinaryOperator(op)
+	if operator <= OpUnknown || operator > OpOr {
+		return nil, fmt.Errorf("unknown binary operator: %d", op)
+	}
+
+	dataType, err := d.readDataType()
+	if err != nil {
+		return nil, err
+	}
+
+	left, err := d.deserializeNode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to deserialize left operand: %w", err)
+	}
+
+	right, err := d.deserializeNode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to deserialize right operand: %w", err)
+	}
+
+	return NewBinaryOp(operator, left, right, dataType), nil
+}
+
+// deserializeUnaryOp decodes a unary operation expression
+func (d *Deserializer) deserializeUnaryOp() (Expression, error) {
+	op, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	operator := UnaryOperator(op)
+	if operator != OpNegate && operator != OpNot {
+		return nil, fmt.Errorf("unknown unary operator: %d", op)
+	}
+
+	dataType, err := d.readDataType()
+	if err != nil {
+		return nil, err
+	}
+
+	operand, err := d.deserializeNode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to deserialize operand: %w", err)
+	}
+
+	return NewUnaryOp(operator, operand, dataType), nil
+}
+
+// deserializeTernary decodes a ternary expression
+func (d *Deserializer) deserializeTernary() (Expression, error) {
+	dataType, err := d.readDataType()
+	if err != nil {
+		return nil, err
+	}
+
+	condition, err := d.deserializeNode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to deserialize condition: %w", err)
+	}
+
+	trueValue, err := d.deserializeNode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to deserialize true value: %w", err)
+	}
+
+	falseValue, err := d.deserializeNode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to deserialize false value: %w", err)
+	}
+
+	return NewTernary(condition, trueValue, falseValue, dataType), nil
+}
+
+// deserializeFunction decodes a function expression
+func (d *Deserializer) deserializeFunction() (Expression, error) {
+	fn, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	function := FunctionName(fn)
+	if function <= FuncUnknown || function > FuncTan {
+		return nil, fmt.Errorf("unknown function: %d", fn)
+	}
+
+	dataType, err := d.readDataType()
+	if err != nil {
+		return nil, err
+	}
+
+	argCount, err := d.readUint32()
+	if err != nil {
+		return nil, err
+	}
+	// Every argument takes at least two bytes
+	if int(argCount) > d.r.Len()/2 {
+		return nil, fmt.Errorf("function %s declares %d arguments but data is too short", function, argCount)
+	}
+
+	args := make([]Expression, 0, argCount)
+	for i := 0; i < int(argCount); i++ {
+		arg, err := d.deserializeNode()
+		if err != nil {
+			return nil, fmt.Errorf("failed to deserialize argument %d: %w", i, err)
+		}
+		args = append(args, arg)
+	}
+
+	return NewFunction(function, args, dataType), nil
+}
+
+// Low-level read methods
+
+func (d *Deserializer) readByte() (byte, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return 0, fmt.Errorf("unexpected end of expression data")
+	}
+	return b, nil
+}
+
+func (d *Deserializer) readDataType() (DataType, error) {
+	b, err := d.readByte()
+	if err != nil {
+		return DataTypeUnknown, err
+	}
+	return DataType(b), nil
+}
+
+func (d *Deserializer) readUint32() (uint32, error) {
+	var val uint32
+	if err := binary.Read(d.r, binary.LittleEndian, &val); err != nil {
+		return 0, fmt.Errorf("unexpected end of expression data")
+	}
+	return val, nil
+}
+
+func (d *Deserializer) readUint64() (uint64, error) {
+	var val uint64
+	if err := binary.Read(d.r, binary.LittleEndian, &val); err != nil {
+		return 0, fmt.Errorf("unexpected end of expression data")
+	}
+	return val, nil
+}
+
+func (d *Deserializer) readString() (string, error) {
+	length, err := d.readUint32()
+	if err != nil {
+		return "", err
+	}
+	if int64(length) > int64(d.r.Len()) {
+		return "", fmt.Errorf("string length %d exceeds remaining expression data", length)
+	}
+
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(d.r, buf); err != nil {
+		return "", fmt.Errorf("unexpected end of expression data")
+	}
+	return string(buf), nil
+}
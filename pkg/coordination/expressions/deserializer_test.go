@@ -0,0 +1,93 @@
+package expressions
+
+import (
+	"testing"
+)
+
+func TestDeserializeRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		expr Expression
+	}{
+		{"bool constant", NewConstBool(true)},
+		{"int constant", NewConstInt(-42)},
+		{"float constant", NewConstFloat(3.14)},
+		{"string constant", NewConstString("hello")},
+		{"field", NewField("metadata.price", DataTypeFloat64)},
+		{
+			"comparison",
+			NewBinaryOp(OpGreaterThan,
+				NewBinaryOp(OpMultiply, NewField("price", DataTypeFloat64), NewConstFloat(1.2), DataTypeFloat64),
+				NewConstFloat(100),
+				DataTypeBool),
+		},
+		{"not", NewUnaryOp(OpNot, NewField("active", DataTypeBool), DataTypeBool)},
+		{
+			"ternary",
+			NewTernary(NewField("active", DataTypeBool), NewConstInt(1), NewConstInt(0), DataTypeInt64),
+		},
+		{
+			"function",
+			NewFunction(FuncMax, []Expression{NewField("a", DataTypeInt64), NewConstInt(3), NewConstInt(7)}, DataTypeInt64),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := NewSerializer().Serialize(tt.expr)
+			if err != nil {
+				t.Fatalf("Serialize failed: %v", err)
+			}
+
+			decoded, err := NewDeserializer().Deserialize(data)
+			if err != nil {
+				t.Fatalf("Deserialize failed: %v", err)
+			}
+
+			if decoded.String() != tt.expr.String() {
+				t.Errorf("Expected %s, got %s", tt.expr, decoded)
+			}
+			if decoded.DataType() != tt.expr.DataType() {
+				t.Errorf("Expected data type %s, got %s", tt.expr.DataType(), decoded.DataType())
+			}
+
+			// Re-serializing the decoded tree must give identical bytes
+			again, err := NewSerializer().Serialize(decoded)
+			if err != nil {
+				t.Fatalf("Serialize of decoded expression failed: %v", err)
+			}
+			if string(again) != string(data) {
+				t.Errorf("Round trip changed the encoding")
+			}
+		})
+	}
+}
+
+func TestDeserializeInvalid(t *testing.T) {
+	valid, err := NewSerializer().Serialize(
+		NewBinaryOp(OpEqual, NewField("status", DataTypeString), NewConstString("active"), DataTypeBool))
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"unknown expression type", []byte{99}},
+		{"truncated", valid[:len(valid)-3]},
+		{"trailing bytes", append(append([]byte{}, valid...), 0)},
+		{"unknown operator", []byte{byte(ExprTypeBinaryOp), 200, byte(DataTypeBool)}},
+		{"string length too long", []byte{byte(ExprTypeConst), byte(DataTypeString), 0xff, 0xff, 0xff, 0x7f}},
+		{"too many arguments", []byte{byte(ExprTypeFunction), byte(FuncMax), byte(DataTypeInt64), 0xff, 0xff, 0xff, 0xff}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewDeserializer().Deserialize(tt.data); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
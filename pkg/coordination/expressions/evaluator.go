@@ -0,0 +1,545 @@
+package expressions
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// FieldSource looks up a document field by path. Values may be bool, any Go
+// numeric type or a string (stored fields); they are converted to the type
+// the field expression declares.
+type FieldSource func(path string) (interface{}, bool)
+
+// Evaluator evaluates an expression AST against documents. Missing fields and
+// values that cannot be converted to the declared type evaluate to null,
+// which propagates through operators and functions. A null filter result
+// rejects the document.
+type Evaluator struct {
+	expr Expression
+}
+
+// NewEvaluator creates an evaluator for expr
+func NewEvaluator(expr Expression) *Evaluator {
+	return &Evaluator{expr: expr}
+}
+
+// Evaluate evaluates the expression and returns a bool, int64, float64,
+// string or nil (null)
+func (e *Evaluator) Evaluate(doc FieldSource) (interface{}, error) {
+	return e.evaluateNode(e.expr, doc)
+}
+
+// EvaluateBool evaluates a filter expression. Null is false.
+func (e *Evaluator) EvaluateBool(doc FieldSource) (bool, error) {
+	val, err := e.Evaluate(doc)
+	if err != nil {
+		return false, err
+	}
+	switch v := val.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	default:
+		return false, fmt.Errorf("filter expression returned %T, expected bool", val)
+	}
+}
+
+// Fields returns the distinct field paths an expression reads, in the order
+// they first appear
+func Fields(expr Expression) []string {
+	seen := make(map[string]struct{})
+	var fields []string
+	var walk func(Expression)
+	walk = func(expr Expression) {
+		switch e := expr.(type) {
+		case *FieldExpression:
+			if _, ok := seen[e.FieldPath]; !ok {
+				seen[e.FieldPath] = struct{}{}
+				fields = append(fields, e.FieldPath)
+			}
+		case *BinaryOpExpression:
+			walk(e.Left)
+			walk(e.Right)
+		case *UnaryOpExpression:
+			walk(e.Operand)
+		case *TernaryExpression:
+			walk(e.Condition)
+			walk(e.TrueValue)
+			walk(e.FalseValue)
+		case *FunctionExpression:
+			for _, arg := range e.Args {
+				walk(arg)
+			}
+		}
+	}
+	walk(expr)
+	return fields
+}
+
+// evaluateNode evaluates a single expression node
+func (e *Evaluator) evaluateNode(expr Expression, doc FieldSource) (interface{}, error) {
+	switch n := expr.(type) {
+	case *ConstExpression:
+		return n.Value, nil
+	case *FieldExpression:
+		return e.evaluateField(n, doc), nil
+	case *BinaryOpExpression:
+		return e.evaluateBinaryOp(n, doc)
+	case *UnaryOpExpression:
+		return e.evaluateUnaryOp(n, doc)
+	case *TernaryExpression:
+		return e.evaluateTernary(n, doc)
+	case *FunctionExpression:
+		return e.evaluateFunction(n, doc)
+	default:
+		return nil, fmt.Errorf("unknown expression type: %T", expr)
+	}
+}
+
+// evaluateField reads a field and converts it to the declared type
+func (e *Evaluator) evaluateField(expr *FieldExpression, doc FieldSource) interface{} {
+	if doc == nil {
+		return nil
+	}
+	raw, ok := doc(expr.FieldPath)
+	if !ok || raw == nil {
+		return nil
+	}
+	return convertValue(raw, expr.DataTyp)
+}
+
+// evaluateBinaryOp evaluates a binary operation
+func (e *Evaluator) evaluateBinaryOp(expr *BinaryOpExpression, doc FieldSource) (interface{}, error) {
+	left, err := e.evaluateNode(expr.Left, doc)
+	if err != nil {
+		return nil, err
+	}
+
+	// Logical operators short-circuit; null && false is false, null || true is true
+	if expr.Operator.IsLogical() {
+		if b, ok := left.(bool); ok {
+			if expr.Operator == OpAnd && !b {
+				return false, nil
+			}
+			if expr.Operator == OpOr && b {
+				return true, nil
+			}
+		}
+		right, err := e.evaluateNode(expr.Right, doc)
+		if err != nil {
+			return nil, err
+		}
+		rb, rok := right.(bool)
+		if rok && expr.Operator == OpAnd && !rb {
+			return false, nil
+		}
+		if rok && expr.Operator == OpOr && rb {
+			return true, nil
+		}
+		if left == nil || right == nil {
+			return nil, nil
+		}
+		return rb, nil
+	}
+
+	right, err := e.evaluateNode(expr.Right, doc)
+	if err != nil {
+		return nil, err
+	}
+	if left == nil || right == nil {
+		return nil, nil
+	}
+
+	if expr.Operator.IsComparison() {
+		return compareValues(expr.Operator, left, right)
+	}
+	return arithmetic(expr.Operator, left, right, expr.DataTyp)
+}
+
+// evaluateUnaryOp evaluates a unary operation
+func (e *Evaluator) evaluateUnaryOp(expr *UnaryOpExpression, doc FieldSource) (interface{}, error) {
+	operand, err := e.evaluateNode(expr.Operand, doc)
+	if err != nil || operand == nil {
+		return nil, err
+	}
+
+	switch expr.Operator {
+	case OpNot:
+		b, ok := operand.(bool)
+		if !ok {
+			return nil, fmt.Errorf("operand of ! must be bool, got %T", operand)
+		}
+		return !b, nil
+	case OpNegate:
+		if i, ok := operand.(int64); ok {
+			return castNumber(float64(-i), -i, true, expr.DataTyp), nil
+		}
+		f, ok := toFloat(operand)
+		if !ok {
+			return nil, fmt.Errorf("operand of - must be numeric, got %T", operand)
+		}
+		return castNumber(-f, 0, false, expr.DataTyp), nil
+	default:
+		return nil, fmt.Errorf("unknown unary operator: %s", expr.Operator)
+	}
+}
+
+// evaluateTernary evaluates a conditional; a null condition takes the false branch
+func (e *Evaluator) evaluateTernary(expr *TernaryExpression, doc FieldSource) (interface{}, error) {
+	condition, err := e.evaluateNode(expr.Condition, doc)
+	if err != nil {
+		return nil, err
+	}
+
+	branch := expr.FalseValue
+	if b, _ := condition.(bool); b {
+		branch = expr.TrueValue
+	}
+
+	val, err := e.evaluateNode(branch, doc)
+	if err != nil || val == nil {
+		return nil, err
+	}
+	if _, isNumber := toFloat(val); isNumber {
+		return convertValue(val, expr.DataTyp), nil
+	}
+	return val, nil
+}
+
+// evaluateFunction evaluates a built-in function call
+func (e *Evaluator) evaluateFunction(expr *FunctionExpression, doc FieldSource) (interface{}, error) {
+	args := make([]float64, len(expr.Args))
+	allInts := true
+	for i, arg := range expr.Args {
+		val, err := e.evaluateNode(arg, doc)
+		if err != nil || val == nil {
+			return nil, err
+		}
+		f, ok := toFloat(val)
+		if !ok {
+			return nil, fmt.Errorf("argument %d of %s must be numeric, got %T", i, expr.Function, val)
+		}
+		if _, isInt := val.(int64); !isInt {
+			allInts = false
+		}
+		args[i] = f
+	}
+
+	arity := func(n int) error {
+		if len(args) != n {
+			return fmt.Errorf("%s requires %d arguments, got %d", expr.Function, n, len(args))
+		}
+		return nil
+	}
+
+	var result float64
+	switch expr.Function {
+	case FuncAbs, FuncSqrt, FuncFloor, FuncCeil, FuncRound, FuncLog, FuncLog10, FuncExp, FuncSin, FuncCos, FuncTan:
+		if err := arity(1); err != nil {
+			return nil, err
+		}
+		x := args[0]
+		switch expr.Function {
+		case FuncAbs:
+			result = math.Abs(x)
+		case FuncSqrt:
+			result = math.Sqrt(x)
+		case FuncFloor:
+			result = math.Floor(x)
+		case FuncCeil:
+			result = math.Ceil(x)
+		case FuncRound:
+			result = math.Round(x)
+		case FuncLog:
+			result = math.Log(x)
+		case FuncLog10:
+			result = math.Log10(x)
+		case FuncExp:
+			result = math.Exp(x)
+		case FuncSin:
+			result = math.Sin(x)
+		case FuncCos:
+			result = math.Cos(x)
+		case FuncTan:
+			result = math.Tan(x)
+		}
+
+	case FuncPow:
+		if err := arity(2); err != nil {
+			return nil, err
+		}
+		result = math.Pow(args[0], args[1])
+
+	case FuncMin, FuncMax:
+		if len(args) == 0 {
+			return nil, fmt.Errorf("%s requires at least 1 argument", expr.Function)
+		}
+		result = args[0]
+		for _, x := range args[1:] {
+			if expr.Function == FuncMin {
+				result = math.Min(result, x)
+			} else {
+				result = math.Max(result, x)
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown function: %s", expr.Function)
+	}
+
+	// abs, min and max of integers stay exact
+	if allInts && (expr.Function == FuncAbs || expr.Function == FuncMin || expr.Function == FuncMax) {
+		return castNumber(result, int64(result), true, expr.DataTyp), nil
+	}
+	return castNumber(result, 0, false, expr.DataTyp), nil
+}
+
+// compareValues applies a comparison operator
+func compareValues(op BinaryOperator, left, right interface{}) (interface{}, error) {
+	var cmp int
+
+	switch l := left.(type) {
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare string with %T", right)
+		}
+		switch {
+		case l < r:
+			cmp = -1
+		case l > r:
+			cmp = 1
+		}
+
+	case bool:
+		r, ok := right.(bool)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare bool with %T", right)
+		}
+		switch op {
+		case OpEqual:
+			return l == r, nil
+		case OpNotEqual:
+			return l != r, nil
+		default:
+			return nil, fmt.Errorf("bool values only support == and !=")
+		}
+
+	default:
+		li, lInt := left.(int64)
+		ri, rInt := right.(int64)
+		if lInt && rInt {
+			switch {
+			case li < ri:
+				cmp = -1
+			case li > ri:
+				cmp = 1
+			}
+			break
+		}
+
+		lf, lok := toFloat(left)
+		rf, rok := toFloat(right)
+		if !lok || !rok {
+			return nil, fmt.Errorf("cannot compare %T with %T", left, right)
+		}
+		if math.IsNaN(lf) || math.IsNaN(rf) {
+			return op == OpNotEqual, nil
+		}
+		switch {
+		case lf < rf:
+			cmp = -1
+		case lf > rf:
+			cmp = 1
+		}
+	}
+
+	switch op {
+	case OpEqual:
+		return cmp == 0, nil
+	case OpNotEqual:
+		return cmp != 0, nil
+	case OpLessThan:
+		return cmp < 0, nil
+	case OpLessEqual:
+		return cmp <= 0, nil
+	case OpGreaterThan:
+		return cmp > 0, nil
+	case OpGreaterEqual:
+		return cmp >= 0, nil
+	default:
+		return nil, fmt.Errorf("unknown comparison operator: %s", op)
+	}
+}
+
+// arithmetic applies an arithmetic operator. Integer operands use integer
+// arithmetic; integer division or modulo by zero yields null.
+func arithmetic(op BinaryOperator, left, right interface{}, resultType DataType) (interface{}, error) {
+	li, lInt := left.(int64)
+	ri, rInt := right.(int64)
+	if lInt && rInt && op != OpPower {
+		var result int64
+		switch op {
+		case OpAdd:
+			result = li + ri
+		case OpSubtract:
+			result = li - ri
+		case OpMultiply:
+			result = li * ri
+		case OpDivide:
+			if ri == 0 {
+				return nil, nil
+			}
+			if resultType == DataTypeFloat64 {
+				return float64(li) / float64(ri), nil
+			}
+			result = li / ri
+		case OpModulo:
+			if ri == 0 {
+				return nil, nil
+			}
+			result = li % ri
+		default:
+			return nil, fmt.Errorf("unknown arithmetic operator: %s", op)
+		}
+		return castNumber(float64(result), result, true, resultType), nil
+	}
+
+	lf, lok := toFloat(left)
+	rf, rok := toFloat(right)
+	if !lok || !rok {
+		return nil, fmt.Errorf("operands of %s must be numeric, got %T and %T", op, left, right)
+	}
+
+	var result float64
+	switch op {
+	case OpAdd:
+		result = lf + rf
+	case OpSubtract:
+		result = lf - rf
+	case OpMultiply:
+		result = lf * rf
+	case OpDivide:
+		result = lf / rf
+	case OpModulo:
+		result = math.Mod(lf, rf)
+	case OpPower:
+		result = math.Pow(lf, rf)
+	default:
+		return nil, fmt.Errorf("unknown arithmetic operator: %s", op)
+	}
+	return castNumber(result, 0, false, resultType), nil
+}
+
+// castNumber returns a numeric result as the declared type. exact carries
+// the integer result when isInt is set, so large values are not rounded
+// through float64.
+func castNumber(f float64, exact int64, isInt bool, dataType DataType) interface{} {
+	switch dataType {
+	case DataTypeInt64:
+		if isInt {
+			return exact
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil
+		}
+		return int64(f)
+	case DataTypeFloat64:
+		return f
+	default:
+		if isInt {
+			return exact
+		}
+		return f
+	}
+}
+
+// convertValue converts a document or intermediate value to dataType,
+// returning nil if it cannot be represented
+func convertValue(val interface{}, dataType DataType) interface{} {
+	switch dataType {
+	case DataTypeBool:
+		switch v := val.(type) {
+		case bool:
+			return v
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b
+			}
+		}
+		return nil
+
+	case DataTypeInt64:
+		switch v := val.(type) {
+		case int64:
+			return v
+		case string:
+			if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return i
+			}
+			if f, err := strconv.ParseFloat(v, 64); err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+				return int64(f)
+			}
+			return nil
+		}
+		if f, ok := toFloat(val); ok && !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return int64(f)
+		}
+		return nil
+
+	case DataTypeFloat64:
+		if s, ok := val.(string); ok {
+			if f, err := strconv.ParseFloat(s, 64); err == nil {
+				return f
+			}
+			return nil
+		}
+		if f, ok := toFloat(val); ok {
+			return f
+		}
+		return nil
+
+	case DataTypeString:
+		if s, ok := val.(string); ok {
+			return s
+		}
+		return fmt.Sprintf("%v", val)
+
+	default:
+		return nil
+	}
+}
+
+// toFloat converts any Go numeric value to float64
+func toFloat(val interface{}) (float64, bool) {
+	switch v := val.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
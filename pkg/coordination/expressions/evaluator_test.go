@@ -0,0 +1,135 @@
+package expressions
+
+import (
+	"testing"
+)
+
+func mapSource(doc map[string]interface{}) FieldSource {
+	return func(path string) (interface{}, bool) {
+		val, ok := doc[path]
+		return val, ok
+	}
+}
+
+func TestEvaluateFilter(t *testing.T) {
+	parser := NewParser()
+
+	// (price * 1.2) > 100 && status == "active"
+	expr, err := parser.Parse(map[string]interface{}{
+		"op": "&&",
+		"left": map[string]interface{}{
+			"op": ">",
+			"left": map[string]interface{}{
+				"op":    "*",
+				"left":  map[string]interface{}{"field": "price", "type": "float64"},
+				"right": map[string]interface{}{"const": 1.2},
+			},
+			"right": map[string]interface{}{"const": 100.0},
+		},
+		"right": map[string]interface{}{
+			"op":    "==",
+			"left":  map[string]interface{}{"field": "status", "type": "string"},
+			"right": map[string]interface{}{"const": "active"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	evaluator := NewEvaluator(expr)
+
+	tests := []struct {
+		name     string
+		doc      map[string]interface{}
+		expected bool
+	}{
+		{"matches", map[string]interface{}{"price": 90.0, "status": "active"}, true},
+		{"too cheap", map[string]interface{}{"price": 80.0, "status": "active"}, false},
+		{"wrong status", map[string]interface{}{"price": 90.0, "status": "inactive"}, false},
+		{"stored strings", map[string]interface{}{"price": "90.000000", "status": "active"}, true},
+		{"missing field", map[string]interface{}{"status": "active"}, false},
+		{"unparsable field", map[string]interface{}{"price": "n/a", "status": "active"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := evaluator.EvaluateBool(mapSource(tt.doc))
+			if err != nil {
+				t.Fatalf("EvaluateBool failed: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestEvaluateNullLogic(t *testing.T) {
+	missing := NewBinaryOp(OpGreaterThan, NewField("missing", DataTypeInt64), NewConstInt(0), DataTypeBool)
+	doc := mapSource(map[string]interface{}{})
+
+	// null || true is true, null && true is null (rejected)
+	or := NewEvaluator(NewBinaryOp(OpOr, missing, NewConstBool(true), DataTypeBool))
+	if got, _ := or.EvaluateBool(doc); !got {
+		t.Error("Expected null || true to be true")
+	}
+	and := NewEvaluator(NewBinaryOp(OpAnd, missing, NewConstBool(true), DataTypeBool))
+	if got, _ := and.Evaluate(doc); got != nil {
+		t.Errorf("Expected null && true to be null, got %v", got)
+	}
+	not := NewEvaluator(NewUnaryOp(OpNot, missing, DataTypeBool))
+	if got, _ := not.EvaluateBool(doc); got {
+		t.Error("Expected !null to reject the document")
+	}
+}
+
+func TestEvaluateArithmeticAndFunctions(t *testing.T) {
+	doc := mapSource(map[string]interface{}{"a": int64(7), "b": 2, "x": -2.5})
+
+	tests := []struct {
+		name     string
+		expr     Expression
+		expected interface{}
+	}{
+		{"int division", NewBinaryOp(OpDivide, NewField("a", DataTypeInt64), NewField("b", DataTypeInt64), DataTypeInt64), int64(3)},
+		{"float division", NewBinaryOp(OpDivide, NewField("a", DataTypeInt64), NewField("b", DataTypeInt64), DataTypeFloat64), 3.5},
+		{"modulo", NewBinaryOp(OpModulo, NewField("a", DataTypeInt64), NewConstInt(4), DataTypeInt64), int64(3)},
+		{"division by zero", NewBinaryOp(OpDivide, NewField("a", DataTypeInt64), NewConstInt(0), DataTypeInt64), nil},
+		{"power", NewBinaryOp(OpPower, NewField("b", DataTypeInt64), NewConstInt(10), DataTypeFloat64), 1024.0},
+		{"negate", NewUnaryOp(OpNegate, NewField("x", DataTypeFloat64), DataTypeFloat64), 2.5},
+		{"abs", NewFunction(FuncAbs, []Expression{NewField("x", DataTypeFloat64)}, DataTypeFloat64), 2.5},
+		{"round", NewFunction(FuncRound, []Expression{NewField("x", DataTypeFloat64)}, DataTypeInt64), int64(-3)},
+		{"max", NewFunction(FuncMax, []Expression{NewField("a", DataTypeInt64), NewConstInt(9)}, DataTypeInt64), int64(9)},
+		{"sqrt", NewFunction(FuncSqrt, []Expression{NewConstFloat(16)}, DataTypeFloat64), 4.0},
+		{
+			"ternary",
+			NewTernary(
+				NewBinaryOp(OpGreaterThan, NewField("a", DataTypeInt64), NewConstInt(5), DataTypeBool),
+				NewConstString("big"), NewConstString("small"), DataTypeString),
+			"big",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewEvaluator(tt.expr).Evaluate(doc)
+			if err != nil {
+				t.Fatalf("Evaluate failed: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %v (%T), got %v (%T)", tt.expected, tt.expected, got, got)
+			}
+		})
+	}
+}
+
+func TestFields(t *testing.T) {
+	expr := NewBinaryOp(OpAnd,
+		NewBinaryOp(OpGreaterThan, NewField("price", DataTypeFloat64), NewConstFloat(1), DataTypeBool),
+		NewBinaryOp(OpLessThan, NewField("price", DataTypeFloat64), NewField("limit", DataTypeFloat64), DataTypeBool),
+		DataTypeBool)
+
+	fields := Fields(expr)
+	if len(fields) != 2 || fields[0] != "price" || fields[1] != "limit" {
+		t.Errorf("Expected [price limit], got %v", fields)
+	}
+}
@@ -0,0 +1,320 @@
+package expressions
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ParseScript parses an infix script in the Painless subset bucket_script and
+// bucket_selector aggregations use, e.g.
+//
+//	params.sales / params.count * 100
+//	return params['total'] > 1000 && Math.abs(params.delta) < 5;
+//
+// Script variables (params.name or params['name']) become float64 fields;
+// Math functions map to the built-in functions. A leading return and trailing
+// semicolons are accepted; anything beyond a single expression is not.
+func ParseScript(source string) (Expression, error) {
+	tokens, err := tokenizeScript(source)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) > 0 && tokens[0].kind == tokIdent && tokens[0].text == "return" {
+		tokens = tokens[1:]
+	}
+	for len(tokens) > 0 && tokens[len(tokens)-1].text == ";" {
+		tokens = tokens[:len(tokens)-1]
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty script")
+	}
+
+	sp := &scriptParser{tokens: tokens, parser: NewParser()}
+	expr, err := sp.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if sp.pos < len(sp.tokens) {
+		return nil, fmt.Errorf("unexpected %q at offset %d", sp.tokens[sp.pos].text, sp.tokens[sp.pos].offset)
+	}
+	return expr, nil
+}
+
+type scriptTokenKind int
+
+const (
+	tokNumber scriptTokenKind = iota
+	tokString
+	tokIdent
+	tokSymbol
+)
+
+type scriptToken struct {
+	kind   scriptTokenKind
+	text   string
+	offset int
+}
+
+// scriptSymbols are the operators and punctuation, longest first
+var scriptSymbols = []string{
+	"==", "!=", "<=", ">=", "&&", "||",
+	"+", "-", "*", "/", "%", "<", ">", "!", "?", ":", "(", ")", "[", "]", ",", ";",
+}
+
+func tokenizeScript(source string) ([]scriptToken, error) {
+	var tokens []scriptToken
+	for i := 0; i < len(source); {
+		c := rune(source[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+
+		case unicode.IsDigit(c) || (c == '.' && i+1 < len(source) && unicode.IsDigit(rune(source[i+1]))):
+			start := i
+			for i < len(source) && (unicode.IsDigit(rune(source[i])) || source[i] == '.') {
+				i++
+			}
+			if i < len(source) && (source[i] == 'e' || source[i] == 'E') {
+				i++
+				if i < len(source) && (source[i] == '+' || source[i] == '-') {
+					i++
+				}
+				for i < len(source) && unicode.IsDigit(rune(source[i])) {
+					i++
+				}
+			}
+			tokens = append(tokens, scriptToken{kind: tokNumber, text: source[start:i], offset: start})
+
+		case c == '\'' || c == '"':
+			start := i
+			end := strings.IndexByte(source[i+1:], source[i])
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at offset %d", start)
+			}
+			i += end + 2
+			tokens = append(tokens, scriptToken{kind: tokString, text: source[start+1 : i-1], offset: start})
+
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(source) && (unicode.IsLetter(rune(source[i])) || unicode.IsDigit(rune(source[i])) || source[i] == '_' || source[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, scriptToken{kind: tokIdent, text: source[start:i], offset: start})
+
+		default:
+			matched := false
+			for _, sym := range scriptSymbols {
+				if strings.HasPrefix(source[i:], sym) {
+					tokens = append(tokens, scriptToken{kind: tokSymbol, text: sym, offset: i})
+					i += len(sym)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at offset %d", c, i)
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// scriptParser is a precedence-climbing parser over script tokens
+type scriptParser struct {
+	tokens []scriptToken
+	pos    int
+	parser *Parser
+}
+
+// scriptBinaryLevels are the binary operators by increasing precedence
+var scriptBinaryLevels = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (sp *scriptParser) peek() string {
+	if sp.pos < len(sp.tokens) && sp.tokens[sp.pos].kind == tokSymbol {
+		return sp.tokens[sp.pos].text
+	}
+	return ""
+}
+
+func (sp *scriptParser) expect(symbol string) error {
+	if sp.peek() != symbol {
+		if sp.pos < len(sp.tokens) {
+			return fmt.Errorf("expected %q at offset %d, got %q", symbol, sp.tokens[sp.pos].offset, sp.tokens[sp.pos].text)
+		}
+		return fmt.Errorf("expected %q at end of script", symbol)
+	}
+	sp.pos++
+	return nil
+}
+
+func (sp *scriptParser) parseTernary() (Expression, error) {
+	condition, err := sp.parseBinary(0)
+	if err != nil || sp.peek() != "?" {
+		return condition, err
+	}
+	sp.pos++
+	trueValue, err := sp.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if err := sp.expect(":"); err != nil {
+		return nil, err
+	}
+	falseValue, err := sp.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+
+	resultType := trueValue.DataType()
+	if resultType != falseValue.DataType() && isNumericType(resultType) && isNumericType(falseValue.DataType()) {
+		resultType = DataTypeFloat64
+	}
+	return NewTernary(condition, trueValue, falseValue, resultType), nil
+}
+
+func (sp *scriptParser) parseBinary(level int) (Expression, error) {
+	if level == len(scriptBinaryLevels) {
+		return sp.parseUnary()
+	}
+	left, err := sp.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		symbol := sp.peek()
+		if !containsString(scriptBinaryLevels[level], symbol) {
+			return left, nil
+		}
+		sp.pos++
+		right, err := sp.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		op := sp.parser.parseBinaryOperator(symbol)
+		left = NewBinaryOp(op, left, right, sp.parser.inferBinaryOpResultType(op, left.DataType(), right.DataType()))
+	}
+}
+
+func (sp *scriptParser) parseUnary() (Expression, error) {
+	switch sp.peek() {
+	case "-":
+		sp.pos++
+		operand, err := sp.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return NewUnaryOp(OpNegate, operand, operand.DataType()), nil
+	case "!":
+		sp.pos++
+		operand, err := sp.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return NewUnaryOp(OpNot, operand, DataTypeBool), nil
+	case "+":
+		sp.pos++
+		return sp.parseUnary()
+	}
+	return sp.parsePrimary()
+}
+
+func (sp *scriptParser) parsePrimary() (Expression, error) {
+	if sp.pos >= len(sp.tokens) {
+		return nil, fmt.Errorf("unexpected end of script")
+	}
+	tok := sp.tokens[sp.pos]
+	sp.pos++
+
+	switch tok.kind {
+	case tokNumber:
+		if i, err := strconv.ParseInt(tok.text, 10, 64); err == nil {
+			return NewConstInt(i), nil
+		}
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at offset %d", tok.text, tok.offset)
+		}
+		return NewConstFloat(f), nil
+
+	case tokString:
+		return NewConstString(tok.text), nil
+
+	case tokSymbol:
+		if tok.text != "(" {
+			return nil, fmt.Errorf("unexpected %q at offset %d", tok.text, tok.offset)
+		}
+		expr, err := sp.parseTernary()
+		if err != nil {
+			return nil, err
+		}
+		return expr, sp.expect(")")
+	}
+
+	switch {
+	case tok.text == "true" || tok.text == "false":
+		return NewConstBool(tok.text == "true"), nil
+	case tok.text == "params":
+		// params['name']
+		if err := sp.expect("["); err != nil {
+			return nil, err
+		}
+		if sp.pos >= len(sp.tokens) || sp.tokens[sp.pos].kind != tokString {
+			return nil, fmt.Errorf("expected a quoted parameter name after params[ at offset %d", tok.offset)
+		}
+		name := sp.tokens[sp.pos].text
+		sp.pos++
+		return NewField(name, DataTypeFloat64), sp.expect("]")
+	case strings.HasPrefix(tok.text, "params."):
+		return NewField(strings.TrimPrefix(tok.text, "params."), DataTypeFloat64), nil
+	case sp.peek() == "(":
+		return sp.parseCall(tok)
+	}
+	return nil, fmt.Errorf("unknown identifier %q at offset %d", tok.text, tok.offset)
+}
+
+// parseCall parses a Math function call such as Math.max(a, b)
+func (sp *scriptParser) parseCall(tok scriptToken) (Expression, error) {
+	fn := sp.parser.parseFunctionName(strings.TrimPrefix(tok.text, "Math."))
+	if fn == FuncUnknown {
+		return nil, fmt.Errorf("unknown function %q at offset %d", tok.text, tok.offset)
+	}
+	sp.pos++ // (
+
+	var args []Expression
+	for sp.peek() != ")" {
+		if len(args) > 0 {
+			if err := sp.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := sp.parseTernary()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	sp.pos++ // )
+	return NewFunction(fn, args, sp.parser.inferFunctionResultType(fn, args)), nil
+}
+
+func isNumericType(dt DataType) bool {
+	return dt == DataTypeInt64 || dt == DataTypeFloat64
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package expressions
+
+import (
+	"testing"
+)
+
+func TestParseScript(t *testing.T) {
+	vars := mapSource(map[string]interface{}{
+		"sales": 200.0,
+		"count": 8.0,
+		"delta": -3.0,
+	})
+
+	tests := []struct {
+		script string
+		want   interface{}
+	}{
+		{"params.sales / params.count", 25.0},
+		{"params.sales / params.count * 100 - 1", 2499.0},
+		{"params['sales'] + params[\"count\"]", 208.0},
+		{"return params.sales > 100 && params.count < 10;", true},
+		{"params.sales > 100 && !(params.count < 10)", false},
+		{"-params.delta * 2", 6.0},
+		{"Math.abs(params.delta) + Math.max(params.count, 10)", 13.0},
+		{"Math.pow(2, 3) + 1", 9.0},
+		{"params.count > 5 ? params.sales : 0", 200.0},
+		{"(1 + 2) * 3 % 4", int64(1)},
+		{"params.missing > 1", nil},
+		{"1.5e2", 150.0},
+	}
+
+	for _, tt := range tests {
+		expr, err := ParseScript(tt.script)
+		if err != nil {
+			t.Fatalf("ParseScript(%q): %v", tt.script, err)
+		}
+		got, err := NewEvaluator(expr).Evaluate(vars)
+		if err != nil {
+			t.Fatalf("Evaluate(%q): %v", tt.script, err)
+		}
+		if got != tt.want {
+			t.Errorf("%q = %v (%T), want %v (%T)", tt.script, got, got, tt.want, tt.want)
+		}
+	}
+}
+
+func TestParseScript_Errors(t *testing.T) {
+	for _, script := range []string{
+		"",
+		"params.a +",
+		"(params.a",
+		"params[a]",
+		"Math.nope(1)",
+		"doc.price",
+		"params.a # 2",
+		"'open",
+		"params.a; params.b",
+	} {
+		if _, err := ParseScript(script); err == nil {
+			t.Errorf("ParseScript(%q) succeeded, want error", script)
+		}
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	pb "github.com/conjugate/conjugate/pkg/common/proto"
 	"github.com/conjugate/conjugate/pkg/coordination/pipeline"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -429,3 +430,33 @@ func TestIndexSettingsHTTP_GetSettings_MultipleIndexes(t *testing.T) {
 	_, hasQuery := indexSettings2["query"]
 	assert.False(t, hasQuery)
 }
+
+func TestMergeIndexSettings(t *testing.T) {
+	current := &pb.IndexSettings{
+		NumberOfShards:   3,
+		NumberOfReplicas: 1,
+		RefreshInterval:  "1s",
+	}
+
+	settings, err := mergeIndexSettings(current, map[string]interface{}{
+		"number_of_replicas": "2",
+		"commit_interval":    "5s",
+		"commit_batch_size":  float64(500),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), settings.NumberOfShards)
+	assert.Equal(t, int32(2), settings.NumberOfReplicas)
+	assert.Equal(t, "1s", settings.RefreshInterval)
+	assert.Equal(t, "5s", settings.CommitInterval)
+	assert.Equal(t, int32(500), settings.CommitBatchSize)
+
+	// The current settings are left alone
+	assert.Equal(t, int32(1), current.NumberOfReplicas)
+
+	_, err = mergeIndexSettings(current, map[string]interface{}{"number_of_replicas": "two"})
+	assert.Error(t, err)
+	_, err = mergeIndexSettings(current, map[string]interface{}{"number_of_replicas": 1.5})
+	assert.Error(t, err)
+	_, err = mergeIndexSettings(current, map[string]interface{}{"refresh_interval": float64(1)})
+	assert.Error(t, err)
+}
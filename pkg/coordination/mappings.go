@@ -0,0 +1,138 @@
+package coordination
+
+import (
+	"fmt"
+
+	pb "github.com/conjugate/conjugate/pkg/common/proto"
+)
+
+// fieldTypes are the field types index mappings accept
+var fieldTypes = map[string]bool{
+	"text":          true,
+	"keyword":       true,
+	"long":          true,
+	"integer":       true,
+	"short":         true,
+	"byte":          true,
+	"double":        true,
+	"float":         true,
+	"half_float":    true,
+	"date":          true,
+	"boolean":       true,
+	"binary":        true,
+	"ip":            true,
+	"geo_point":     true,
+	"object":        true,
+	"nested":        true,
+	"sparse_vector": true,
+	"knn_vector":    true,
+}
+
+// spaceTypes are the distance functions knn_vector fields accept
+var spaceTypes = map[string]bool{
+	"l2":           true,
+	"cosinesimil":  true,
+	"innerproduct": true,
+}
+
+// parseMappings parses the mappings of a create index request body:
+// {"properties": {"field": {"type": "text", ...}, ...}}. A field with
+// properties and no type is an object field.
+func parseMappings(body map[string]interface{}) (map[string]*pb.FieldMapping, error) {
+	raw, ok := body["mappings"]
+	if !ok {
+		return nil, nil
+	}
+	mappings, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("mappings must be an object")
+	}
+	properties, ok := mappings["properties"]
+	if !ok {
+		return nil, nil
+	}
+	return parseProperties("", properties)
+}
+
+// parseProperties parses the field mappings under a properties object
+func parseProperties(prefix string, raw interface{}) (map[string]*pb.FieldMapping, error) {
+	properties, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("properties of [%s] must be an object", prefix)
+	}
+
+	fields := make(map[string]*pb.FieldMapping, len(properties))
+	for name, rawField := range properties {
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		field, ok := rawField.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("mapping of field [%s] must be an object", path)
+		}
+
+		mapping := &pb.FieldMapping{Type: "object", Index: true}
+		if fieldType, ok := field["type"]; ok {
+			if mapping.Type, ok = fieldType.(string); !ok || !fieldTypes[mapping.Type] {
+				return nil, fmt.Errorf("no handler for type [%v] declared on field [%s]", fieldType, path)
+			}
+		}
+		if index, ok := field["index"]; ok {
+			if mapping.Index, ok = index.(bool); !ok {
+				return nil, fmt.Errorf("[index] of field [%s] must be a boolean", path)
+			}
+		}
+		if store, ok := field["store"]; ok {
+			if mapping.Store, ok = store.(bool); !ok {
+				return nil, fmt.Errorf("[store] of field [%s] must be a boolean", path)
+			}
+		}
+		if analyzer, ok := field["analyzer"]; ok {
+			if mapping.Analyzer, ok = analyzer.(string); !ok {
+				return nil, fmt.Errorf("[analyzer] of field [%s] must be a string", path)
+			}
+		}
+		if mapping.Type == "knn_vector" {
+			if err := parseKNNVectorMapping(path, field, mapping); err != nil {
+				return nil, err
+			}
+		}
+		if sub, ok := field["properties"]; ok {
+			if mapping.Type != "object" && mapping.Type != "nested" {
+				return nil, fmt.Errorf("field [%s] of type [%s] cannot have properties", path, mapping.Type)
+			}
+			props, err := parseProperties(path, sub)
+			if err != nil {
+				return nil, err
+			}
+			mapping.Properties = props
+		}
+
+		fields[name] = mapping
+	}
+	return fields, nil
+}
+
+// parseKNNVectorMapping parses the dimension and space type of a knn_vector
+// field. The space type may be given at the top level or, as in OpenSearch,
+// in the field's method, and defaults to l2.
+func parseKNNVectorMapping(path string, field map[string]interface{}, mapping *pb.FieldMapping) error {
+	dimension, ok := field["dimension"].(float64)
+	if !ok || dimension < 1 || dimension != float64(int32(dimension)) {
+		return fmt.Errorf("[dimension] of knn_vector field [%s] must be a positive integer", path)
+	}
+	mapping.Dimension = int32(dimension)
+
+	spaceType, ok := field["space_type"]
+	if method, isObject := field["method"].(map[string]interface{}); !ok && isObject {
+		spaceType, ok = method["space_type"]
+	}
+	mapping.SpaceType = "l2"
+	if ok {
+		if mapping.SpaceType, ok = spaceType.(string); !ok || !spaceTypes[mapping.SpaceType] {
+			return fmt.Errorf("unsupported [space_type] [%v] of knn_vector field [%s]", spaceType, path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package coordination
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseMappingsJSON(t *testing.T, body string) map[string]interface{} {
+	t.Helper()
+	var obj map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(body), &obj))
+	return obj
+}
+
+func TestParseMappings(t *testing.T) {
+	body := parseMappingsJSON(t, `{
+		"mappings": {
+			"properties": {
+				"title": {"type": "text", "analyzer": "standard"},
+				"tags": {"type": "keyword", "store": true, "index": false},
+				"embedding": {"type": "sparse_vector"},
+				"dense": {"type": "knn_vector", "dimension": 3, "method": {"name": "hnsw", "space_type": "cosinesimil"}},
+				"image": {"type": "knn_vector", "dimension": 128},
+				"author": {"properties": {"name": {"type": "keyword"}}}
+			}
+		}
+	}`)
+
+	mappings, err := parseMappings(body)
+	require.NoError(t, err)
+	require.Len(t, mappings, 6)
+
+	assert.Equal(t, "text", mappings["title"].Type)
+	assert.Equal(t, "standard", mappings["title"].Analyzer)
+	assert.True(t, mappings["title"].Index)
+
+	assert.True(t, mappings["tags"].Store)
+	assert.False(t, mappings["tags"].Index)
+
+	assert.Equal(t, "sparse_vector", mappings["embedding"].Type)
+
+	assert.Equal(t, "knn_vector", mappings["dense"].Type)
+	assert.Equal(t, int32(3), mappings["dense"].Dimension)
+	assert.Equal(t, "cosinesimil", mappings["dense"].SpaceType)
+	assert.Equal(t, int32(128), mappings["image"].Dimension)
+	assert.Equal(t, "l2", mappings["image"].SpaceType)
+
+	assert.Equal(t, "object", mappings["author"].Type)
+	require.Contains(t, mappings["author"].Properties, "name")
+	assert.Equal(t, "keyword", mappings["author"].Properties["name"].Type)
+}
+
+func TestParseMappings_None(t *testing.T) {
+	mappings, err := parseMappings(map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Nil(t, mappings)
+}
+
+func TestParseMappings_Invalid(t *testing.T) {
+	for _, raw := range []string{
+		`{"mappings": "text"}`,
+		`{"mappings": {"properties": {"title": "text"}}}`,
+		`{"mappings": {"properties": {"title": {"type": "unknown"}}}}`,
+		`{"mappings": {"properties": {"title": {"type": "text", "index": "yes"}}}}`,
+		`{"mappings": {"properties": {"title": {"type": "text", "properties": {}}}}}`,
+		`{"mappings": {"properties": {"author": {"properties": {"age": {"type": 1}}}}}}`,
+		`{"mappings": {"properties": {"dense": {"type": "knn_vector"}}}}`,
+		`{"mappings": {"properties": {"dense": {"type": "knn_vector", "dimension": 2.5}}}}`,
+		`{"mappings": {"properties": {"dense": {"type": "knn_vector", "dimension": 3, "space_type": "hamming"}}}}`,
+	} {
+		body := parseMappingsJSON(t, raw)
+		_, err := parseMappings(body)
+		assert.Error(t, err, raw)
+	}
+}
@@ -229,6 +229,24 @@ func (mc *MasterClient) GetClusterState(ctx context.Context, includeRouting, inc
 	return resp, nil
 }
 
+// WatchClusterState opens a stream of the cluster state events after
+// fromVersion. The stream fails with codes.OutOfRange when the master no
+// longer has the events after fromVersion; the caller must then reload the
+// full cluster state and watch from its version.
+func (mc *MasterClient) WatchClusterState(ctx context.Context, fromVersion int64) (pb.MasterService_WatchClusterStateClient, error) {
+	mc.mu.RLock()
+	if !mc.connected {
+		mc.mu.RUnlock()
+		return nil, fmt.Errorf("not connected to master")
+	}
+	client := mc.client
+	mc.mu.RUnlock()
+
+	mc.logger.Debug("Watching cluster state", zap.Int64("from_version", fromVersion))
+
+	return client.WatchClusterState(ctx, &pb.WatchClusterStateRequest{FromVersion: fromVersion})
+}
+
 // GetShardRouting retrieves shard routing information for an index
 func (mc *MasterClient) GetShardRouting(ctx context.Context, indexName string) (map[int32]*pb.ShardRouting, error) {
 	// Get cluster state with routing information
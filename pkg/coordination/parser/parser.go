@@ -31,6 +31,10 @@ func (p *QueryParser) ParseSearchRequest(body []byte) (*SearchRequest, error) {
 		req.ParsedQuery = parsedQuery
 	}
 
+	if err := p.parseHybridFusion(&req); err != nil {
+		return nil, err
+	}
+
 	return &req, nil
 }
 
@@ -69,10 +73,18 @@ func (p *QueryParser) ParseQuery(queryMap map[string]interface{}) (Query, error)
 			return p.parsePrefixQuery(queryBody)
 		case "wildcard":
 			return p.parseWildcardQuery(queryBody)
+		case "regexp":
+			return p.parseRegexpQuery(queryBody)
 		case "fuzzy":
 			return p.parseFuzzyQuery(queryBody)
 		case "query_string":
 			return p.parseQueryStringQuery(queryBody)
+		case "neural_sparse":
+			return p.parseNeuralSparseQuery(queryBody)
+		case "knn":
+			return p.parseKNNQuery(queryBody)
+		case "hybrid":
+			return p.parseHybridQuery(queryBody)
 		case "expr":
 			return p.parseExpressionQuery(queryBody)
 		case "wasm_udf":
@@ -115,6 +127,12 @@ func (p *QueryParser) parseMatchQuery(body interface{}) (Query, error) {
 			if analyzer, ok := v["analyzer"].(string); ok {
 				query.Analyzer = analyzer
 			}
+			switch minMatch := v["minimum_should_match"].(type) {
+			case float64:
+				query.MinimumShouldMatch = int(minMatch)
+			case string:
+				query.MinimumShouldMatchStr = minMatch
+			}
 		default:
 			return nil, fmt.Errorf("invalid match query value type")
 		}
@@ -465,6 +483,41 @@ func (p *QueryParser) parseWildcardQuery(body interface{}) (Query, error) {
 	return nil, fmt.Errorf("wildcard query must have a field")
 }
 
+// parseRegexpQuery parses a regexp query
+func (p *QueryParser) parseRegexpQuery(body interface{}) (Query, error) {
+	bodyMap, ok := body.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("regexp query body must be an object")
+	}
+
+	for field, value := range bodyMap {
+		query := &RegexpQuery{
+			Field: field,
+		}
+
+		switch v := value.(type) {
+		case string:
+			query.Value = v
+		case map[string]interface{}:
+			if val, ok := v["value"].(string); ok {
+				query.Value = val
+			}
+			if caseInsensitive, ok := v["case_insensitive"].(bool); ok {
+				query.CaseInsensitive = caseInsensitive
+			}
+		default:
+			return nil, fmt.Errorf("invalid regexp query value type")
+		}
+
+		if query.Value == "" {
+			return nil, fmt.Errorf("regexp query for field [%s] requires a value", field)
+		}
+		return query, nil
+	}
+
+	return nil, fmt.Errorf("regexp query must have a field")
+}
+
 // parseFuzzyQuery parses a fuzzy query
 func (p *QueryParser) parseFuzzyQuery(body interface{}) (Query, error) {
 	bodyMap, ok := body.(map[string]interface{})
@@ -484,8 +537,11 @@ func (p *QueryParser) parseFuzzyQuery(body interface{}) (Query, error) {
 			if val, ok := v["value"].(string); ok {
 				query.Value = val
 			}
-			if fuzziness, ok := v["fuzziness"].(string); ok {
+			switch fuzziness := v["fuzziness"].(type) {
+			case string:
 				query.Fuzziness = fuzziness
+			case float64:
+				query.Fuzziness = fmt.Sprintf("%v", fuzziness)
 			}
 		default:
 			return nil, fmt.Errorf("invalid fuzzy query value type")
@@ -528,6 +584,350 @@ func (p *QueryParser) parseQueryStringQuery(body interface{}) (Query, error) {
 	return query, nil
 }
 
+// parseNeuralSparseQuery parses a neural_sparse query
+func (p *QueryParser) parseNeuralSparseQuery(body interface{}) (Query, error) {
+	bodyMap, ok := body.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("neural_sparse query body must be an object")
+	}
+
+	for field, value := range bodyMap {
+		v, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("neural_sparse query for field [%s] must be an object", field)
+		}
+
+		rawTokens, ok := v["query_tokens"].(map[string]interface{})
+		if !ok {
+			if _, hasText := v["query_text"]; hasText {
+				return nil, fmt.Errorf("neural_sparse query for field [%s]: query_text is not supported without a sparse encoder, use query_tokens", field)
+			}
+			return nil, fmt.Errorf("neural_sparse query for field [%s] requires query_tokens", field)
+		}
+
+		query := &NeuralSparseQuery{
+			Field:       field,
+			QueryTokens: make(map[string]float64, len(rawTokens)),
+			Boost:       1.0,
+		}
+		for token, rawWeight := range rawTokens {
+			weight, ok := rawWeight.(float64)
+			if !ok || weight < 0 {
+				return nil, fmt.Errorf("weight of token [%s] in neural_sparse query must be a non-negative number", token)
+			}
+			query.QueryTokens[token] = weight
+		}
+		if boost, ok := v["boost"].(float64); ok {
+			query.Boost = boost
+		}
+		return query, nil
+	}
+
+	return nil, fmt.Errorf("neural_sparse query must have a field")
+}
+
+// parseKNNQuery parses a knn query. num_candidates defaults to 1.5 times k,
+// as in Elasticsearch.
+func (p *QueryParser) parseKNNQuery(body interface{}) (Query, error) {
+	bodyMap, ok := body.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("knn query body must be an object")
+	}
+
+	query := &KNNQuery{K: DefaultKNNK, Boost: 1.0}
+	if query.Field, ok = bodyMap["field"].(string); !ok || query.Field == "" {
+		return nil, fmt.Errorf("knn query requires [field]")
+	}
+
+	rawVector, ok := bodyMap["query_vector"].([]interface{})
+	if !ok || len(rawVector) == 0 {
+		return nil, fmt.Errorf("knn query for field [%s] requires [query_vector] as an array of numbers", query.Field)
+	}
+	query.QueryVector = make([]float64, len(rawVector))
+	for i, raw := range rawVector {
+		if query.QueryVector[i], ok = raw.(float64); !ok {
+			return nil, fmt.Errorf("knn query for field [%s] requires [query_vector] as an array of numbers", query.Field)
+		}
+	}
+
+	positiveInt := func(name string) (int, bool, error) {
+		raw, ok := bodyMap[name]
+		if !ok {
+			return 0, false, nil
+		}
+		n, ok := raw.(float64)
+		if !ok || n < 1 || n != float64(int(n)) {
+			return 0, false, fmt.Errorf("[%s] in knn query for field [%s] must be a positive integer", name, query.Field)
+		}
+		return int(n), true, nil
+	}
+	k, hasK, err := positiveInt("k")
+	if err != nil {
+		return nil, err
+	}
+	if hasK {
+		query.K = k
+	}
+	numCandidates, hasCandidates, err := positiveInt("num_candidates")
+	if err != nil {
+		return nil, err
+	}
+	if hasCandidates {
+		query.NumCandidates = numCandidates
+	} else {
+		query.NumCandidates = min(max(query.K*3/2, query.K), MaxKNNCandidates)
+	}
+	if query.NumCandidates > MaxKNNCandidates {
+		return nil, fmt.Errorf("[num_candidates] in knn query for field [%s] cannot exceed %d", query.Field, MaxKNNCandidates)
+	}
+	if query.NumCandidates < query.K {
+		return nil, fmt.Errorf("[num_candidates] in knn query for field [%s] cannot be less than [k]", query.Field)
+	}
+
+	if rawFilter, ok := bodyMap["filter"]; ok {
+		filterMap, ok := rawFilter.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("[filter] in knn query for field [%s] must be a query object", query.Field)
+		}
+		if query.Filter, err = p.ParseQuery(filterMap); err != nil {
+			return nil, fmt.Errorf("invalid filter in knn query for field [%s]: %w", query.Field, err)
+		}
+	}
+	if boost, ok := bodyMap["boost"].(float64); ok {
+		query.Boost = boost
+	}
+	return query, nil
+}
+
+// parseHybridQuery parses a hybrid query
+func (p *QueryParser) parseHybridQuery(body interface{}) (Query, error) {
+	bodyMap, ok := body.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("hybrid query body must be an object")
+	}
+
+	rawQueries, ok := bodyMap["queries"].([]interface{})
+	if !ok || len(rawQueries) == 0 {
+		return nil, fmt.Errorf("hybrid query requires [queries] as a non-empty array")
+	}
+	if len(rawQueries) > MaxHybridQueries {
+		return nil, fmt.Errorf("hybrid query cannot have more than %d sub-queries, got %d", MaxHybridQueries, len(rawQueries))
+	}
+
+	query := &HybridQuery{Queries: make([]Query, len(rawQueries))}
+	for i, raw := range rawQueries {
+		queryMap, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("sub-query %d of hybrid query must be an object", i)
+		}
+		subQuery, err := p.ParseQuery(queryMap)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sub-query %d of hybrid query: %w", i, err)
+		}
+		if containsHybrid(subQuery) {
+			return nil, fmt.Errorf("hybrid query cannot be nested in another hybrid query")
+		}
+		query.Queries[i] = subQuery
+	}
+
+	if raw, ok := bodyMap["pagination_depth"]; ok {
+		depth, ok := raw.(float64)
+		if !ok || depth < 1 || depth != float64(int(depth)) {
+			return nil, fmt.Errorf("[pagination_depth] in hybrid query must be a positive integer")
+		}
+		query.PaginationDepth = int(depth)
+	}
+
+	return query, nil
+}
+
+// containsHybrid reports whether a query is or contains a hybrid query
+func containsHybrid(q Query) bool {
+	switch query := q.(type) {
+	case *HybridQuery:
+		return true
+	case *BoolQuery:
+		for _, clauses := range [][]Query{query.Must, query.Should, query.MustNot, query.Filter} {
+			for _, clause := range clauses {
+				if containsHybrid(clause) {
+					return true
+				}
+			}
+		}
+	case *KNNQuery:
+		return query.Filter != nil && containsHybrid(query.Filter)
+	}
+	return false
+}
+
+// parseHybridFusion checks that a hybrid query is the top-level query and
+// applies the fusion configured by the request's search pipeline to it
+func (p *QueryParser) parseHybridFusion(req *SearchRequest) error {
+	hybrid, isHybrid := req.ParsedQuery.(*HybridQuery)
+	if !isHybrid && req.ParsedQuery != nil && containsHybrid(req.ParsedQuery) {
+		return fmt.Errorf("hybrid query must be the top-level query")
+	}
+	if req.SearchPipeline == nil {
+		return nil
+	}
+
+	fusion, err := ParseSearchPipelineFusion(req.SearchPipeline)
+	if err != nil {
+		return fmt.Errorf("invalid search_pipeline: %w", err)
+	}
+	if isHybrid && fusion != nil {
+		if err := fusion.Validate(len(hybrid.Queries)); err != nil {
+			return err
+		}
+		hybrid.Fusion = fusion
+	}
+	return nil
+}
+
+// Search pipeline processors configuring hybrid fusion, as in OpenSearch
+const (
+	NormalizationProcessor = "normalization-processor"
+	ScoreRankerProcessor   = "score-ranker-processor"
+)
+
+// ParseSearchPipelineFusion returns the fusion configured by the phase
+// results processors of a search pipeline, or nil if there is none:
+// {"phase_results_processors": [{"normalization-processor": {...}}]}
+func ParseSearchPipelineFusion(pipeline map[string]interface{}) (*HybridFusion, error) {
+	raw, ok := pipeline["phase_results_processors"]
+	if !ok {
+		return nil, nil
+	}
+	processors, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("[phase_results_processors] must be an array")
+	}
+
+	var fusion *HybridFusion
+	for _, rawProcessor := range processors {
+		processor, ok := rawProcessor.(map[string]interface{})
+		if !ok || len(processor) != 1 {
+			return nil, fmt.Errorf("each phase results processor must be an object with one processor")
+		}
+		for name, rawBody := range processor {
+			body, ok := rawBody.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("[%s] must be an object", name)
+			}
+			if fusion != nil {
+				return nil, fmt.Errorf("only one of [%s] and [%s] may be given", NormalizationProcessor, ScoreRankerProcessor)
+			}
+			var err error
+			if fusion, err = ParseFusionProcessor(name, body); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return fusion, nil
+}
+
+// ParseFusionProcessor parses the body of a hybrid fusion processor. A
+// normalization-processor takes {"normalization": {"technique": "min_max"},
+// "combination": {"technique": "arithmetic_mean", "parameters": {"weights":
+// [...]}}}, a score-ranker-processor {"combination": {"technique": "rrf",
+// "rank_constant": 60}}.
+func ParseFusionProcessor(name string, body map[string]interface{}) (*HybridFusion, error) {
+	var fusion *HybridFusion
+	switch name {
+	case NormalizationProcessor:
+		fusion = DefaultHybridFusion()
+	case ScoreRankerProcessor:
+		fusion = &HybridFusion{Combination: CombinationRRF, RankConstant: DefaultRankConstant}
+	default:
+		return nil, fmt.Errorf("unknown phase results processor [%s]", name)
+	}
+
+	technique := func(section string) (string, map[string]interface{}, error) {
+		raw, ok := body[section]
+		if !ok {
+			return "", nil, nil
+		}
+		obj, ok := raw.(map[string]interface{})
+		if !ok {
+			return "", nil, fmt.Errorf("[%s] of [%s] must be an object", section, name)
+		}
+		if raw, ok := obj["technique"]; ok {
+			t, ok := raw.(string)
+			if !ok {
+				return "", nil, fmt.Errorf("[%s.technique] of [%s] must be a string", section, name)
+			}
+			return t, obj, nil
+		}
+		return "", obj, nil
+	}
+
+	normalization, _, err := technique("normalization")
+	if err != nil {
+		return nil, err
+	}
+	if normalization != "" {
+		if name != NormalizationProcessor {
+			return nil, fmt.Errorf("[%s] does not normalize scores", name)
+		}
+		if normalization != NormalizationMinMax && normalization != NormalizationL2 {
+			return nil, fmt.Errorf("unsupported normalization technique [%s], expected [%s] or [%s]",
+				normalization, NormalizationMinMax, NormalizationL2)
+		}
+		fusion.Normalization = normalization
+	}
+
+	combination, combinationObj, err := technique("combination")
+	if err != nil {
+		return nil, err
+	}
+	if combination != "" && combination != fusion.Combination {
+		return nil, fmt.Errorf("unsupported combination technique [%s] for [%s], expected [%s]",
+			combination, name, fusion.Combination)
+	}
+	if raw, ok := combinationObj["rank_constant"]; ok {
+		rankConstant, ok := raw.(float64)
+		if name != ScoreRankerProcessor || !ok || rankConstant < 1 || rankConstant != float64(int(rankConstant)) {
+			return nil, fmt.Errorf("[rank_constant] of [%s] must be a positive integer", name)
+		}
+		fusion.RankConstant = int(rankConstant)
+	}
+	if raw, ok := combinationObj["parameters"]; ok {
+		parameters, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("[combination.parameters] of [%s] must be an object", name)
+		}
+		if raw, ok := parameters["weights"]; ok {
+			if fusion.Weights, err = parseFusionWeights(raw); err != nil {
+				return nil, fmt.Errorf("invalid weights of [%s]: %w", name, err)
+			}
+		}
+	}
+	return fusion, nil
+}
+
+// parseFusionWeights parses the per sub-query weights of a fusion, which
+// must be non-negative and not all zero
+func parseFusionWeights(raw interface{}) ([]float64, error) {
+	rawWeights, ok := raw.([]interface{})
+	if !ok || len(rawWeights) == 0 {
+		return nil, fmt.Errorf("weights must be a non-empty array of numbers")
+	}
+	weights := make([]float64, len(rawWeights))
+	var sum float64
+	for i, rawWeight := range rawWeights {
+		weight, ok := rawWeight.(float64)
+		if !ok || weight < 0 {
+			return nil, fmt.Errorf("weights must be non-negative numbers")
+		}
+		weights[i] = weight
+		sum += weight
+	}
+	if sum == 0 {
+		return nil, fmt.Errorf("weights cannot all be zero")
+	}
+	return weights, nil
+}
+
 // parseExpressionQuery parses an expression query
 func (p *QueryParser) parseExpressionQuery(body interface{}) (Query, error) {
 	bodyMap, ok := body.(map[string]interface{})
@@ -662,6 +1062,17 @@ func (p *QueryParser) Validate(query Query) error {
 		if q.Name == "" {
 			return fmt.Errorf("wasm_udf query has no name")
 		}
+	case *HybridQuery:
+		for _, subQuery := range q.Queries {
+			if err := p.Validate(subQuery); err != nil {
+				return err
+			}
+		}
+		if q.Fusion != nil {
+			if err := q.Fusion.Validate(len(q.Queries)); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"encoding/json"
+	"reflect"
 	"testing"
 )
 
@@ -36,6 +38,20 @@ func TestParseMatchQuery(t *testing.T) {
 			}`,
 			wantErr: false,
 		},
+		{
+			name: "match query with minimum_should_match",
+			query: `{
+				"query": {
+					"match": {
+						"title": {
+							"query": "fast search engine",
+							"minimum_should_match": "75%"
+						}
+					}
+				}
+			}`,
+			wantErr: false,
+		},
 	}
 
 	parser := NewQueryParser()
@@ -68,6 +84,26 @@ func TestParseMatchQuery(t *testing.T) {
 	}
 }
 
+func TestParseMatchQueryMinimumShouldMatch(t *testing.T) {
+	parser := NewQueryParser()
+
+	req, err := parser.ParseSearchRequest([]byte(`{"query":{"match":{"title":{"query":"a b c","minimum_should_match":2}}}}`))
+	if err != nil {
+		t.Fatalf("ParseSearchRequest() error = %v", err)
+	}
+	if q := req.ParsedQuery.(*MatchQuery); q.MinimumShouldMatch != 2 || q.MinimumShouldMatchStr != "" {
+		t.Errorf("Expected minimum_should_match=2, got %d / %q", q.MinimumShouldMatch, q.MinimumShouldMatchStr)
+	}
+
+	req, err = parser.ParseSearchRequest([]byte(`{"query":{"match":{"title":{"query":"a b c","minimum_should_match":"-25%"}}}}`))
+	if err != nil {
+		t.Fatalf("ParseSearchRequest() error = %v", err)
+	}
+	if q := req.ParsedQuery.(*MatchQuery); q.MinimumShouldMatchStr != "-25%" {
+		t.Errorf("Expected minimum_should_match=-25%%, got %q", q.MinimumShouldMatchStr)
+	}
+}
+
 func TestParseTermQuery(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -359,6 +395,266 @@ func TestParseExistsQuery(t *testing.T) {
 	}
 }
 
+func TestParseRegexpQuery(t *testing.T) {
+	query := `{
+		"query": {
+			"regexp": {
+				"user": {"value": "k.*y", "case_insensitive": true}
+			}
+		}
+	}`
+
+	parser := NewQueryParser()
+	req, err := parser.ParseSearchRequest([]byte(query))
+	if err != nil {
+		t.Fatalf("ParseSearchRequest() error = %v", err)
+	}
+
+	regexpQuery, ok := req.ParsedQuery.(*RegexpQuery)
+	if !ok {
+		t.Fatalf("Expected RegexpQuery, got %T", req.ParsedQuery)
+	}
+
+	if regexpQuery.Field != "user" || regexpQuery.Value != "k.*y" || !regexpQuery.CaseInsensitive {
+		t.Errorf("Unexpected regexp query: %+v", regexpQuery)
+	}
+	if !IsTermLevelQuery(regexpQuery) {
+		t.Error("Expected regexp to be a term-level query")
+	}
+
+	req, err = parser.ParseSearchRequest([]byte(`{"query": {"regexp": {"user": "k.*y"}}}`))
+	if err != nil {
+		t.Fatalf("ParseSearchRequest() error = %v", err)
+	}
+	if regexpQuery := req.ParsedQuery.(*RegexpQuery); regexpQuery.Value != "k.*y" || regexpQuery.CaseInsensitive {
+		t.Errorf("Unexpected regexp query: %+v", regexpQuery)
+	}
+
+	if _, err := parser.ParseSearchRequest([]byte(`{"query": {"regexp": {"user": {"flags": "ALL"}}}}`)); err == nil {
+		t.Error("Expected error for regexp query without a value")
+	}
+}
+
+func TestParseFuzzyQueryNumericFuzziness(t *testing.T) {
+	parser := NewQueryParser()
+	req, err := parser.ParseSearchRequest([]byte(`{"query": {"fuzzy": {"user": {"value": "kimchy", "fuzziness": 1}}}}`))
+	if err != nil {
+		t.Fatalf("ParseSearchRequest() error = %v", err)
+	}
+
+	fuzzyQuery, ok := req.ParsedQuery.(*FuzzyQuery)
+	if !ok {
+		t.Fatalf("Expected FuzzyQuery, got %T", req.ParsedQuery)
+	}
+	if fuzzyQuery.Fuzziness != "1" {
+		t.Errorf("Expected fuzziness '1', got '%s'", fuzzyQuery.Fuzziness)
+	}
+}
+
+func TestParseNeuralSparseQuery(t *testing.T) {
+	query := `{
+		"query": {
+			"neural_sparse": {
+				"embedding": {"query_tokens": {"search": 1.2, "engine": 0.4}, "boost": 2}
+			}
+		}
+	}`
+
+	parser := NewQueryParser()
+	req, err := parser.ParseSearchRequest([]byte(query))
+	if err != nil {
+		t.Fatalf("ParseSearchRequest() error = %v", err)
+	}
+
+	sparseQuery, ok := req.ParsedQuery.(*NeuralSparseQuery)
+	if !ok {
+		t.Fatalf("Expected NeuralSparseQuery, got %T", req.ParsedQuery)
+	}
+	if sparseQuery.Field != "embedding" || sparseQuery.Boost != 2 ||
+		sparseQuery.QueryTokens["search"] != 1.2 || sparseQuery.QueryTokens["engine"] != 0.4 {
+		t.Errorf("Unexpected neural_sparse query: %+v", sparseQuery)
+	}
+	if fields := GetQueryFields(sparseQuery); len(fields) != 1 || fields[0] != "embedding" {
+		t.Errorf("Unexpected query fields: %v", fields)
+	}
+
+	for _, body := range []string{
+		`{"query": {"neural_sparse": {"embedding": "search"}}}`,
+		`{"query": {"neural_sparse": {"embedding": {"query_text": "search engine"}}}}`,
+		`{"query": {"neural_sparse": {"embedding": {"query_tokens": {"search": -1}}}}}`,
+	} {
+		if _, err := parser.ParseSearchRequest([]byte(body)); err == nil {
+			t.Errorf("Expected error for %s", body)
+		}
+	}
+}
+
+func TestParseKNNQuery(t *testing.T) {
+	query := `{
+		"query": {
+			"knn": {
+				"field": "embedding",
+				"query_vector": [0.1, 0.2, 0.3],
+				"k": 5,
+				"filter": {"term": {"category": "books"}}
+			}
+		}
+	}`
+
+	parser := NewQueryParser()
+	req, err := parser.ParseSearchRequest([]byte(query))
+	if err != nil {
+		t.Fatalf("ParseSearchRequest() error = %v", err)
+	}
+
+	knnQuery, ok := req.ParsedQuery.(*KNNQuery)
+	if !ok {
+		t.Fatalf("Expected KNNQuery, got %T", req.ParsedQuery)
+	}
+	if knnQuery.Field != "embedding" || knnQuery.K != 5 || knnQuery.NumCandidates != 7 ||
+		knnQuery.Boost != 1 || len(knnQuery.QueryVector) != 3 {
+		t.Errorf("Unexpected knn query: %+v", knnQuery)
+	}
+	if _, ok := knnQuery.Filter.(*TermQuery); !ok {
+		t.Errorf("Expected TermQuery filter, got %T", knnQuery.Filter)
+	}
+	if fields := GetQueryFields(knnQuery); len(fields) != 2 || fields[0] != "embedding" || fields[1] != "category" {
+		t.Errorf("Unexpected query fields: %v", fields)
+	}
+
+	for _, body := range []string{
+		`{"query": {"knn": {"query_vector": [1, 2]}}}`,
+		`{"query": {"knn": {"field": "embedding", "query_vector": []}}}`,
+		`{"query": {"knn": {"field": "embedding", "query_vector": [1, "2"]}}}`,
+		`{"query": {"knn": {"field": "embedding", "query_vector": [1, 2], "k": 0}}}`,
+		`{"query": {"knn": {"field": "embedding", "query_vector": [1, 2], "k": 10, "num_candidates": 5}}}`,
+		`{"query": {"knn": {"field": "embedding", "query_vector": [1, 2], "num_candidates": 20000}}}`,
+		`{"query": {"knn": {"field": "embedding", "query_vector": [1, 2], "filter": {"unknown": {}}}}}`,
+	} {
+		if _, err := parser.ParseSearchRequest([]byte(body)); err == nil {
+			t.Errorf("Expected error for %s", body)
+		}
+	}
+}
+
+func TestParseHybridQuery(t *testing.T) {
+	query := `{
+		"query": {
+			"hybrid": {
+				"queries": [
+					{"match": {"title": "search engine"}},
+					{"knn": {"field": "embedding", "query_vector": [0.1, 0.2], "k": 5}}
+				],
+				"pagination_depth": 20
+			}
+		},
+		"search_pipeline": {
+			"phase_results_processors": [{
+				"normalization-processor": {
+					"normalization": {"technique": "l2"},
+					"combination": {"technique": "arithmetic_mean", "parameters": {"weights": [0.3, 0.7]}}
+				}
+			}]
+		}
+	}`
+
+	parser := NewQueryParser()
+	req, err := parser.ParseSearchRequest([]byte(query))
+	if err != nil {
+		t.Fatalf("ParseSearchRequest() error = %v", err)
+	}
+
+	hybridQuery, ok := req.ParsedQuery.(*HybridQuery)
+	if !ok {
+		t.Fatalf("Expected HybridQuery, got %T", req.ParsedQuery)
+	}
+	if len(hybridQuery.Queries) != 2 || hybridQuery.PaginationDepth != 20 {
+		t.Fatalf("Unexpected hybrid query: %+v", hybridQuery)
+	}
+	if _, ok := hybridQuery.Queries[1].(*KNNQuery); !ok {
+		t.Errorf("Expected KNNQuery, got %T", hybridQuery.Queries[1])
+	}
+	want := &HybridFusion{Normalization: NormalizationL2, Combination: CombinationArithmeticMean, Weights: []float64{0.3, 0.7}}
+	if !reflect.DeepEqual(hybridQuery.Fusion, want) {
+		t.Errorf("Fusion = %+v, want %+v", hybridQuery.Fusion, want)
+	}
+
+	// Without a search pipeline the fusion is left to the index's
+	req, err = parser.ParseSearchRequest([]byte(`{"query": {"hybrid": {"queries": [{"match_all": {}}]}}}`))
+	if err != nil {
+		t.Fatalf("ParseSearchRequest() error = %v", err)
+	}
+	if req.ParsedQuery.(*HybridQuery).Fusion != nil {
+		t.Errorf("Expected no fusion, got %+v", req.ParsedQuery.(*HybridQuery).Fusion)
+	}
+
+	for _, body := range []string{
+		`{"query": {"hybrid": {"queries": []}}}`,
+		`{"query": {"hybrid": {"queries": [{"match_all": {}}], "pagination_depth": 0}}}`,
+		`{"query": {"hybrid": {"queries": [{}, {}, {}, {}, {}, {}]}}}`,
+		`{"query": {"hybrid": {"queries": [{"hybrid": {"queries": [{"match_all": {}}]}}]}}}`,
+		`{"query": {"bool": {"must": [{"hybrid": {"queries": [{"match_all": {}}]}}]}}}`,
+		`{"query": {"hybrid": {"queries": [{"match_all": {}}]}},
+		  "search_pipeline": {"phase_results_processors": [{"normalization-processor": {"combination": {"parameters": {"weights": [0.5, 0.5]}}}}]}}`,
+	} {
+		if _, err := parser.ParseSearchRequest([]byte(body)); err == nil {
+			t.Errorf("Expected error for %s", body)
+		}
+	}
+}
+
+func TestParseFusionProcessor(t *testing.T) {
+	tests := []struct {
+		name      string
+		processor string
+		body      string
+		want      *HybridFusion
+		wantErr   bool
+	}{
+		{
+			name:      "normalization defaults",
+			processor: NormalizationProcessor,
+			body:      `{}`,
+			want:      DefaultHybridFusion(),
+		},
+		{
+			name:      "rrf",
+			processor: ScoreRankerProcessor,
+			body:      `{"combination": {"technique": "rrf", "rank_constant": 40}}`,
+			want:      &HybridFusion{Combination: CombinationRRF, RankConstant: 40},
+		},
+		{
+			name:      "rrf defaults",
+			processor: ScoreRankerProcessor,
+			body:      `{}`,
+			want:      &HybridFusion{Combination: CombinationRRF, RankConstant: DefaultRankConstant},
+		},
+		{name: "unknown processor", processor: "rerank", body: `{}`, wantErr: true},
+		{name: "unknown normalization", processor: NormalizationProcessor, body: `{"normalization": {"technique": "z_score"}}`, wantErr: true},
+		{name: "rrf in normalization", processor: NormalizationProcessor, body: `{"combination": {"technique": "rrf"}}`, wantErr: true},
+		{name: "normalizing ranks", processor: ScoreRankerProcessor, body: `{"normalization": {"technique": "l2"}}`, wantErr: true},
+		{name: "negative weight", processor: NormalizationProcessor, body: `{"combination": {"parameters": {"weights": [1, -1]}}}`, wantErr: true},
+		{name: "zero weights", processor: NormalizationProcessor, body: `{"combination": {"parameters": {"weights": [0, 0]}}}`, wantErr: true},
+		{name: "bad rank constant", processor: ScoreRankerProcessor, body: `{"combination": {"rank_constant": 0}}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.body), &body); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ParseFusionProcessor(tt.processor, body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFusionProcessor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseFusionProcessor() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestParseMatchAllQuery(t *testing.T) {
 	query := `{
 		"query": {
@@ -1,5 +1,7 @@
 package parser
 
+import "fmt"
+
 // SearchRequest represents a complete search request
 type SearchRequest struct {
 	Query       map[string]interface{}   `json:"query,omitempty"`
@@ -12,6 +14,10 @@ type SearchRequest struct {
 	Highlight   map[string]interface{}   `json:"highlight,omitempty"`
 	Timeout     string                   `json:"timeout,omitempty"`
 
+	// Inline search pipeline, whose phase results processors configure how
+	// a hybrid query fuses its sub-queries' hits
+	SearchPipeline map[string]interface{} `json:"search_pipeline,omitempty"`
+
 	// Parsed query (not from JSON)
 	ParsedQuery Query `json:"-"`
 }
@@ -27,11 +33,13 @@ type Query interface {
 
 // MatchQuery represents a match query
 type MatchQuery struct {
-	Field    string
-	Query    string
-	Operator string  // "and" or "or"
-	Boost    float64
-	Analyzer string
+	Field                 string
+	Query                 string
+	Operator              string // "and" or "or"
+	Boost                 float64
+	Analyzer              string
+	MinimumShouldMatch    int
+	MinimumShouldMatchStr string // Can be "75%" or "-1"
 }
 
 func (q *MatchQuery) QueryType() string { return "match" }
@@ -119,6 +127,15 @@ type WildcardQuery struct {
 
 func (q *WildcardQuery) QueryType() string { return "wildcard" }
 
+// RegexpQuery represents a regexp query
+type RegexpQuery struct {
+	Field           string
+	Value           string // Matched against whole terms
+	CaseInsensitive bool
+}
+
+func (q *RegexpQuery) QueryType() string { return "regexp" }
+
 // FuzzyQuery represents a fuzzy query
 type FuzzyQuery struct {
 	Field      string
@@ -151,6 +168,95 @@ type MatchAllQuery struct {
 
 func (q *MatchAllQuery) QueryType() string { return "match_all" }
 
+// ============================================================================
+// Vector Queries
+// ============================================================================
+
+// NeuralSparseQuery represents a neural_sparse query, scoring a sparse_vector
+// field by its dot product with token weights from a sparse encoder
+type NeuralSparseQuery struct {
+	Field       string
+	QueryTokens map[string]float64
+	Boost       float64
+}
+
+func (q *NeuralSparseQuery) QueryType() string { return "neural_sparse" }
+
+// Limits of knn queries, matching those of the data nodes
+const (
+	DefaultKNNK      = 10
+	MaxKNNCandidates = 10000
+)
+
+// KNNQuery represents a knn query, finding the K vectors of a knn_vector
+// field nearest to QueryVector among NumCandidates per shard. Filter, if
+// set, restricts the search to the documents it matches.
+type KNNQuery struct {
+	Field         string
+	QueryVector   []float64
+	K             int
+	NumCandidates int
+	Filter        Query
+	Boost         float64
+}
+
+func (q *KNNQuery) QueryType() string { return "knn" }
+
+// ============================================================================
+// Hybrid Query
+// ============================================================================
+
+// MaxHybridQueries is how many sub-queries a hybrid query may have
+const MaxHybridQueries = 5
+
+// HybridQuery represents a hybrid query. Each sub-query is searched on its
+// own and their ranked hits are fused on the coordinator. A hybrid query
+// must be the top-level query.
+type HybridQuery struct {
+	Queries         []Query
+	PaginationDepth int           // Hits fetched per sub-query (0 = from+size)
+	Fusion          *HybridFusion // nil until configured; see DefaultHybridFusion
+}
+
+func (q *HybridQuery) QueryType() string { return "hybrid" }
+
+// Techniques of hybrid score normalization and combination
+const (
+	NormalizationMinMax       = "min_max"
+	NormalizationL2           = "l2"
+	CombinationArithmeticMean = "arithmetic_mean"
+	CombinationRRF            = "rrf"
+)
+
+// DefaultRankConstant is the rank constant of reciprocal rank fusion
+const DefaultRankConstant = 60
+
+// HybridFusion configures how a hybrid query fuses its sub-queries' hits:
+// either by normalizing each list's scores and combining them with a
+// weighted arithmetic mean, or by reciprocal rank fusion
+type HybridFusion struct {
+	Normalization string    `json:"normalization,omitempty"` // Unused by rrf
+	Combination   string    `json:"combination"`
+	Weights       []float64 `json:"weights,omitempty"`       // Per sub-query; equal if empty
+	RankConstant  int       `json:"rank_constant,omitempty"` // rrf only
+}
+
+// DefaultHybridFusion returns the fusion of hybrid queries that configure
+// none: min-max normalization and an unweighted arithmetic mean
+func DefaultHybridFusion() *HybridFusion {
+	return &HybridFusion{Normalization: NormalizationMinMax, Combination: CombinationArithmeticMean}
+}
+
+// Validate checks that the fusion applies to a hybrid query of numQueries
+// sub-queries
+func (f *HybridFusion) Validate(numQueries int) error {
+	if len(f.Weights) > 0 && len(f.Weights) != numQueries {
+		return fmt.Errorf("number of weights [%d] must match number of sub-queries [%d] in hybrid query",
+			len(f.Weights), numQueries)
+	}
+	return nil
+}
+
 // ============================================================================
 // Expression Query (Custom Filter)
 // ============================================================================
@@ -198,7 +304,7 @@ func IsBoolQuery(q Query) bool {
 // IsTermLevelQuery checks if a query is a term-level query
 func IsTermLevelQuery(q Query) bool {
 	switch q.(type) {
-	case *TermQuery, *TermsQuery, *RangeQuery, *ExistsQuery, *PrefixQuery, *WildcardQuery, *ExpressionQuery, *WasmUDFQuery:
+	case *TermQuery, *TermsQuery, *RangeQuery, *ExistsQuery, *PrefixQuery, *WildcardQuery, *RegexpQuery, *ExpressionQuery, *WasmUDFQuery:
 		return true
 	default:
 		return false
@@ -238,8 +344,21 @@ func GetQueryFields(q Query) []string {
 		fields = append(fields, query.Field)
 	case *WildcardQuery:
 		fields = append(fields, query.Field)
+	case *RegexpQuery:
+		fields = append(fields, query.Field)
 	case *FuzzyQuery:
 		fields = append(fields, query.Field)
+	case *NeuralSparseQuery:
+		fields = append(fields, query.Field)
+	case *KNNQuery:
+		fields = append(fields, query.Field)
+		if query.Filter != nil {
+			fields = append(fields, GetQueryFields(query.Filter)...)
+		}
+	case *HybridQuery:
+		for _, subQuery := range query.Queries {
+			fields = append(fields, GetQueryFields(subQuery)...)
+		}
 	case *BoolQuery:
 		for _, subQuery := range query.Must {
 			fields = append(fields, GetQueryFields(subQuery)...)
@@ -278,10 +397,24 @@ func EstimateComplexity(q Query) int {
 		return 50
 	case *MultiMatchQuery:
 		return 50 * len(query.Fields)
-	case *PrefixQuery, *WildcardQuery:
+	case *PrefixQuery, *WildcardQuery, *RegexpQuery:
 		return 100
 	case *FuzzyQuery:
 		return 200
+	case *NeuralSparseQuery:
+		return 20 * len(query.QueryTokens)
+	case *KNNQuery:
+		complexity := query.NumCandidates / 10
+		if query.Filter != nil {
+			complexity += EstimateComplexity(query.Filter)
+		}
+		return complexity
+	case *HybridQuery:
+		complexity := 0
+		for _, subQuery := range query.Queries {
+			complexity += EstimateComplexity(subQuery)
+		}
+		return complexity
 	case *BoolQuery:
 		complexity := 0
 		for _, subQuery := range query.Must {
@@ -56,6 +56,8 @@ func (c *Converter) ConvertSearchRequest(req *parser.SearchRequest, indexName st
 		if err != nil {
 			return nil, fmt.Errorf("failed to convert aggregations: %w", err)
 		}
+		// The shards evaluate the aggregations over their own matches
+		scan.Aggregations = aggregations
 		plan = agg
 	}
 
@@ -76,6 +78,8 @@ func (c *Converter) ConvertSearchRequest(req *parser.SearchRequest, indexName st
 		if err != nil {
 			return nil, fmt.Errorf("failed to convert sort: %w", err)
 		}
+		// The shards rank their matches by the sort keys, so hits arrive in order
+		scan.Sort = sort.SortFields
 		plan = sort
 	}
 
@@ -103,6 +107,13 @@ func (c *Converter) ConvertQuery(q parser.Query) (*Expression, error) {
 			Type: ExprTypeMatchAll,
 		}, nil
 
+	case *parser.ExpressionQuery:
+		// Evaluated by the data nodes as a filter over the query's matches
+		return &Expression{
+			Type:  ExprTypeExpr,
+			Value: query.SerializedExpression,
+		}, nil
+
 	case *parser.TermQuery:
 		return &Expression{
 			Type:  ExprTypeTerm,
@@ -166,18 +177,33 @@ func (c *Converter) ConvertQuery(q parser.Query) (*Expression, error) {
 			Value: query.Value,
 		}, nil
 
+	case *parser.RegexpQuery:
+		var value interface{} = query.Value
+		if query.CaseInsensitive {
+			value = map[string]interface{}{"value": query.Value, "case_insensitive": true}
+		}
+		return &Expression{
+			Type:  ExprTypeRegexp,
+			Field: query.Field,
+			Value: value,
+		}, nil
+
 	case *parser.MatchQuery:
 		return &Expression{
 			Type:  ExprTypeMatch,
 			Field: query.Field,
-			Value: query.Query,
+			Value: matchValue(query),
 		}, nil
 
 	case *parser.MatchPhraseQuery:
+		var value interface{} = query.Query
+		if query.Slop > 0 {
+			value = map[string]interface{}{"query": query.Query, "slop": query.Slop}
+		}
 		return &Expression{
-			Type:  ExprTypeMatch, // Match phrase treated as match for now
+			Type:  ExprTypeMatchPhrase,
 			Field: query.Field,
-			Value: query.Query,
+			Value: value,
 		}, nil
 
 	case *parser.BoolQuery:
@@ -199,13 +225,73 @@ func (c *Converter) ConvertQuery(q parser.Query) (*Expression, error) {
 		}, nil
 
 	case *parser.FuzzyQuery:
-		// Treat fuzzy as wildcard for logical planning
+		var value interface{} = query.Value
+		if query.Fuzziness != "" {
+			value = map[string]interface{}{"value": query.Value, "fuzziness": query.Fuzziness}
+		}
 		return &Expression{
-			Type:  ExprTypeWildcard,
+			Type:  ExprTypeFuzzy,
 			Field: query.Field,
-			Value: query.Value,
+			Value: value,
 		}, nil
 
+	case *parser.NeuralSparseQuery:
+		value := map[string]interface{}{"query_tokens": query.QueryTokens}
+		if query.Boost != 1.0 {
+			value["boost"] = query.Boost
+		}
+		return &Expression{
+			Type:  ExprTypeSparse,
+			Field: query.Field,
+			Value: value,
+		}, nil
+
+	case *parser.KNNQuery:
+		value := map[string]interface{}{
+			"query_vector":   query.QueryVector,
+			"k":              query.K,
+			"num_candidates": query.NumCandidates,
+		}
+		if query.Boost != 1.0 {
+			value["boost"] = query.Boost
+		}
+		expr := &Expression{
+			Type:  ExprTypeKNN,
+			Field: query.Field,
+			Value: value,
+		}
+		if query.Filter != nil {
+			filter, err := c.ConvertQuery(query.Filter)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert knn filter: %w", err)
+			}
+			expr.Children = []*Expression{filter}
+		}
+		return expr, nil
+
+	case *parser.HybridQuery:
+		fusion := query.Fusion
+		if fusion == nil {
+			fusion = parser.DefaultHybridFusion()
+		}
+		value := map[string]interface{}{"fusion": fusion}
+		if query.PaginationDepth > 0 {
+			value["pagination_depth"] = query.PaginationDepth
+		}
+		expr := &Expression{
+			Type:     ExprTypeHybrid,
+			Value:    value,
+			Children: make([]*Expression, len(query.Queries)),
+		}
+		for i, subQuery := range query.Queries {
+			child, err := c.ConvertQuery(subQuery)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert hybrid sub-query %d: %w", i, err)
+			}
+			expr.Children[i] = child
+		}
+		return expr, nil
+
 	case *parser.QueryStringQuery:
 		// Query string is complex - for now treat as match on default field
 		field := query.DefaultField
@@ -226,6 +312,24 @@ func (c *Converter) ConvertQuery(q parser.Query) (*Expression, error) {
 	}
 }
 
+// matchValue returns the query text of a match query, or an options object
+// when the query sets operator or minimum_should_match
+func matchValue(query *parser.MatchQuery) interface{} {
+	opts := map[string]interface{}{"query": query.Query}
+	if query.Operator != "" {
+		opts["operator"] = query.Operator
+	}
+	if query.MinimumShouldMatchStr != "" {
+		opts["minimum_should_match"] = query.MinimumShouldMatchStr
+	} else if query.MinimumShouldMatch != 0 {
+		opts["minimum_should_match"] = query.MinimumShouldMatch
+	}
+	if len(opts) == 1 {
+		return query.Query
+	}
+	return opts
+}
+
 // convertBoolQuery converts a bool query to an expression
 func (c *Converter) convertBoolQuery(q *parser.BoolQuery) (*Expression, error) {
 	// Bool query combines multiple clauses with AND (must/filter) and OR (should)
@@ -295,6 +399,26 @@ func (c *Converter) convertBoolQuery(q *parser.BoolQuery) (*Expression, error) {
 
 // convertAggregations converts aggregations to a LogicalAggregate node
 func (c *Converter) convertAggregations(aggs map[string]interface{}, child LogicalPlan) (*LogicalAggregate, error) {
+	aggregations, err := c.convertAggregationDefs(aggs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(aggregations) == 0 {
+		return nil, fmt.Errorf("no valid aggregations found")
+	}
+
+	return &LogicalAggregate{
+		GroupBy:      []string{}, // TODO: Extract group by from terms agg
+		Aggregations: aggregations,
+		Child:        child,
+		OutputSchema: &Schema{Fields: []*Field{}}, // TODO: Build schema
+	}, nil
+}
+
+// convertAggregationDefs converts a map of named aggregations, including
+// their sub-aggregations
+func (c *Converter) convertAggregationDefs(aggs map[string]interface{}) ([]*Aggregation, error) {
 	aggregations := make([]*Aggregation, 0, len(aggs))
 
 	for name, aggDef := range aggs {
@@ -303,25 +427,40 @@ func (c *Converter) convertAggregations(aggs map[string]interface{}, child Logic
 			continue
 		}
 
+		var agg *Aggregation
+		var subAggs []*Aggregation
 		for aggType, aggBody := range aggMap {
-			agg, err := c.convertAggregation(name, aggType, aggBody)
+			switch aggType {
+			case "aggs", "aggregations":
+				subMap, ok := aggBody.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("sub-aggregations of %s must be an object", name)
+				}
+				converted, err := c.convertAggregationDefs(subMap)
+				if err != nil {
+					return nil, err
+				}
+				subAggs = converted
+				continue
+			case "meta":
+				continue
+			}
+
+			converted, err := c.convertAggregation(name, aggType, aggBody)
 			if err != nil {
 				return nil, err
 			}
-			aggregations = append(aggregations, agg)
+			agg = converted
 		}
-	}
 
-	if len(aggregations) == 0 {
-		return nil, fmt.Errorf("no valid aggregations found")
+		if agg == nil {
+			return nil, fmt.Errorf("aggregation %s has no type", name)
+		}
+		agg.SubAggregations = subAggs
+		aggregations = append(aggregations, agg)
 	}
 
-	return &LogicalAggregate{
-		GroupBy:      []string{}, // TODO: Extract group by from terms agg
-		Aggregations: aggregations,
-		Child:        child,
-		OutputSchema: &Schema{Fields: []*Field{}}, // TODO: Build schema
-	}, nil
+	return aggregations, nil
 }
 
 // convertAggregation converts a single aggregation
@@ -350,6 +489,15 @@ func (c *Converter) convertAggregation(name, aggType string, body interface{}) (
 		} else {
 			agg.Params["size"] = 10 // Default
 		}
+		if shardSize, ok := bodyMap["shard_size"].(float64); ok {
+			agg.Params["shard_size"] = int(shardSize)
+		}
+		if minDocCount, ok := bodyMap["min_doc_count"].(float64); ok {
+			agg.Params["min_doc_count"] = int(minDocCount)
+		}
+		if order, ok := bodyMap["order"]; ok {
+			agg.Params["order"] = order
+		}
 
 	case "stats":
 		agg.Type = AggTypeStats
@@ -372,8 +520,14 @@ func (c *Converter) convertAggregation(name, aggType string, body interface{}) (
 	case "count":
 		agg.Type = AggTypeCount
 
+	case "value_count":
+		agg.Type = AggTypeValueCount
+
 	case "cardinality":
 		agg.Type = AggTypeCardinality
+		if threshold, ok := bodyMap["precision_threshold"].(float64); ok {
+			agg.Params["precision_threshold"] = int(threshold)
+		}
 
 	case "percentiles":
 		agg.Type = AggTypePercentiles
@@ -381,11 +535,20 @@ func (c *Converter) convertAggregation(name, aggType string, body interface{}) (
 			agg.Params["percents"] = percents
 		}
 
+	case "percentile_ranks":
+		agg.Type = AggTypePercentileRanks
+		values, ok := bodyMap["values"].([]interface{})
+		if !ok || len(values) == 0 {
+			return nil, fmt.Errorf("percentile_ranks aggregation [%s] requires values", name)
+		}
+		agg.Params["values"] = values
+
 	case "histogram":
 		agg.Type = AggTypeHistogram
 		if interval, ok := bodyMap["interval"].(float64); ok {
 			agg.Params["interval"] = interval
 		}
+		copyHistogramParams(agg.Params, bodyMap)
 
 	case "date_histogram":
 		agg.Type = AggTypeDateHistogram
@@ -398,14 +561,69 @@ func (c *Converter) convertAggregation(name, aggType string, body interface{}) (
 		if fixedInterval, ok := bodyMap["fixed_interval"].(string); ok {
 			agg.Params["fixed_interval"] = fixedInterval
 		}
+		if timeZone, ok := bodyMap["time_zone"].(string); ok {
+			agg.Params["time_zone"] = timeZone
+		}
+		copyHistogramParams(agg.Params, bodyMap)
+
+	case "range":
+		agg.Type = AggTypeRange
+		if ranges, ok := bodyMap["ranges"].([]interface{}); ok {
+			agg.Params["ranges"] = ranges
+		}
+
+	case "filters":
+		agg.Type = AggTypeFilters
+		if filters, ok := bodyMap["filters"]; ok {
+			agg.Params["filters"] = filters
+		}
+
+	case "composite":
+		agg.Type = AggTypeComposite
+		sources, ok := bodyMap["sources"].([]interface{})
+		if !ok || len(sources) == 0 {
+			return nil, fmt.Errorf("composite aggregation [%s] requires sources", name)
+		}
+		agg.Params["sources"] = sources
+		if size, ok := bodyMap["size"].(float64); ok {
+			agg.Params["size"] = int(size)
+		}
+		if after, ok := bodyMap["after"].(map[string]interface{}); ok {
+			agg.Params["after"] = after
+		}
 
 	default:
-		return nil, fmt.Errorf("unsupported aggregation type: %s", aggType)
+		if !pipelineAggTypes[AggregationType(aggType)] {
+			return nil, fmt.Errorf("unsupported aggregation type: %s", aggType)
+		}
+		// The executor evaluates pipelines from the raw definitions; the
+		// paths they read are kept for the plan
+		agg.Type = AggregationType(aggType)
+		bucketsPath, ok := bodyMap["buckets_path"]
+		if !ok && agg.Type != AggTypeBucketSort {
+			return nil, fmt.Errorf("%s aggregation [%s] requires buckets_path", aggType, name)
+		}
+		if ok {
+			agg.Params["buckets_path"] = bucketsPath
+		}
 	}
 
 	return agg, nil
 }
 
+// copyHistogramParams keeps the offset, min_doc_count and bounds of a
+// histogram or date_histogram, which the data nodes and the merge apply
+func copyHistogramParams(params, bodyMap map[string]interface{}) {
+	for _, key := range []string{"offset", "extended_bounds", "hard_bounds"} {
+		if value, ok := bodyMap[key]; ok {
+			params[key] = value
+		}
+	}
+	if minDocCount, ok := bodyMap["min_doc_count"].(float64); ok {
+		params["min_doc_count"] = int(minDocCount)
+	}
+}
+
 // convertSource converts _source field specification to projection
 func (c *Converter) convertSource(source interface{}, child LogicalPlan) (*LogicalProject, error) {
 	var fields []string
@@ -456,24 +674,31 @@ func (c *Converter) convertSort(sort []map[string]interface{}, child LogicalPlan
 
 	for _, sortSpec := range sort {
 		for field, order := range sortSpec {
+			// Scores sort descending by default, fields ascending
 			sf := &SortField{
 				Field:      field,
-				Descending: false,
+				Descending: field == "_score",
 			}
 
 			// Parse order
+			var orderStr string
 			switch orderValue := order.(type) {
 			case string:
-				if strings.ToLower(orderValue) == "desc" {
-					sf.Descending = true
-				}
+				orderStr = orderValue
 
 			case map[string]interface{}:
-				if orderStr, ok := orderValue["order"].(string); ok {
-					if strings.ToLower(orderStr) == "desc" {
-						sf.Descending = true
-					}
-				}
+				orderStr, _ = orderValue["order"].(string)
+				sf.Missing = orderValue["missing"]
+			}
+
+			switch strings.ToLower(orderStr) {
+			case "":
+			case "asc":
+				sf.Descending = false
+			case "desc":
+				sf.Descending = true
+			default:
+				return nil, fmt.Errorf("invalid sort order [%s] for field [%s]", orderStr, field)
 			}
 
 			sortFields = append(sortFields, sf)
@@ -509,12 +734,26 @@ func (c *Converter) estimateSelectivity(q parser.Query) float64 {
 	case *parser.ExistsQuery:
 		return 0.8 // Assume field exists in 80% of documents
 
-	case *parser.PrefixQuery, *parser.WildcardQuery:
-		return 0.2 // Prefix/wildcard more selective
+	case *parser.PrefixQuery, *parser.WildcardQuery, *parser.RegexpQuery, *parser.FuzzyQuery:
+		return 0.2 // Multi-term queries more selective
 
 	case *parser.MatchQuery, *parser.MatchPhraseQuery:
 		return 0.15 // Text queries moderately selective
 
+	case *parser.NeuralSparseQuery:
+		return 0.3 // Matches documents sharing any query token
+
+	case *parser.KNNQuery:
+		return 0.01 // Matches at most k documents per shard
+
+	case *parser.HybridQuery:
+		// Hits of any sub-query, capped at 1.0
+		selectivity := 0.0
+		for _, subQuery := range query.Queries {
+			selectivity += c.estimateSelectivity(subQuery)
+		}
+		return min(1.0, selectivity)
+
 	case *parser.BoolQuery:
 		// Combine selectivities
 		selectivity := 1.0
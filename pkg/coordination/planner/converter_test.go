@@ -123,6 +123,100 @@ func TestConvertWildcardQuery(t *testing.T) {
 	assert.Equal(t, "jo*n", expr.Value)
 }
 
+func TestConvertRegexpQuery(t *testing.T) {
+	converter := NewConverter()
+
+	expr, err := converter.ConvertQuery(&parser.RegexpQuery{Field: "name", Value: "jo.*n"})
+	require.NoError(t, err)
+	assert.Equal(t, ExprTypeRegexp, expr.Type)
+	assert.Equal(t, "name", expr.Field)
+	assert.Equal(t, "jo.*n", expr.Value)
+
+	expr, err = converter.ConvertQuery(&parser.RegexpQuery{Field: "name", Value: "jo.*n", CaseInsensitive: true})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"value": "jo.*n", "case_insensitive": true}, expr.Value)
+}
+
+func TestConvertFuzzyQuery(t *testing.T) {
+	converter := NewConverter()
+
+	expr, err := converter.ConvertQuery(&parser.FuzzyQuery{Field: "name", Value: "jon"})
+	require.NoError(t, err)
+	assert.Equal(t, ExprTypeFuzzy, expr.Type)
+	assert.Equal(t, "jon", expr.Value)
+
+	expr, err = converter.ConvertQuery(&parser.FuzzyQuery{Field: "name", Value: "jon", Fuzziness: "AUTO"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"value": "jon", "fuzziness": "AUTO"}, expr.Value)
+}
+
+func TestConvertNeuralSparseQuery(t *testing.T) {
+	converter := NewConverter()
+	tokens := map[string]float64{"search": 1.2, "engine": 0.4}
+
+	expr, err := converter.ConvertQuery(&parser.NeuralSparseQuery{Field: "embedding", QueryTokens: tokens, Boost: 1.0})
+	require.NoError(t, err)
+	assert.Equal(t, ExprTypeSparse, expr.Type)
+	assert.Equal(t, "embedding", expr.Field)
+	assert.Equal(t, map[string]interface{}{"query_tokens": tokens}, expr.Value)
+
+	expr, err = converter.ConvertQuery(&parser.NeuralSparseQuery{Field: "embedding", QueryTokens: tokens, Boost: 2})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"query_tokens": tokens, "boost": 2.0}, expr.Value)
+}
+
+func TestConvertKNNQuery(t *testing.T) {
+	converter := NewConverter()
+
+	expr, err := converter.ConvertQuery(&parser.KNNQuery{
+		Field:         "embedding",
+		QueryVector:   []float64{0.1, 0.2},
+		K:             5,
+		NumCandidates: 50,
+		Filter:        &parser.TermQuery{Field: "category", Value: "books"},
+		Boost:         1.0,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ExprTypeKNN, expr.Type)
+	assert.Equal(t, "embedding", expr.Field)
+	assert.Equal(t, map[string]interface{}{
+		"query_vector":   []float64{0.1, 0.2},
+		"k":              5,
+		"num_candidates": 50,
+	}, expr.Value)
+	require.Len(t, expr.Children, 1)
+	assert.Equal(t, ExprTypeTerm, expr.Children[0].Type)
+}
+
+func TestConvertHybridQuery(t *testing.T) {
+	converter := NewConverter()
+
+	query := &parser.HybridQuery{
+		Queries: []parser.Query{
+			&parser.MatchQuery{Field: "title", Query: "laptop"},
+			&parser.TermQuery{Field: "brand", Value: "acme"},
+		},
+		PaginationDepth: 50,
+	}
+	expr, err := converter.ConvertQuery(query)
+	require.NoError(t, err)
+	assert.Equal(t, ExprTypeHybrid, expr.Type)
+	require.Len(t, expr.Children, 2)
+	assert.Equal(t, ExprTypeMatch, expr.Children[0].Type)
+	assert.Equal(t, ExprTypeTerm, expr.Children[1].Type)
+
+	// Hybrid queries without a fusion get the default one
+	assert.Equal(t, map[string]interface{}{
+		"fusion":           parser.DefaultHybridFusion(),
+		"pagination_depth": 50,
+	}, expr.Value)
+
+	query.Fusion = &parser.HybridFusion{Combination: parser.CombinationRRF, RankConstant: 60}
+	expr, err = converter.ConvertQuery(query)
+	require.NoError(t, err)
+	assert.Equal(t, query.Fusion, expr.Value.(map[string]interface{})["fusion"])
+}
+
 func TestConvertMatchQuery(t *testing.T) {
 	converter := NewConverter()
 
@@ -139,6 +233,57 @@ func TestConvertMatchQuery(t *testing.T) {
 	assert.Equal(t, "search engine", expr.Value)
 }
 
+func TestConvertMatchQueryOptions(t *testing.T) {
+	converter := NewConverter()
+
+	expr, err := converter.ConvertQuery(&parser.MatchQuery{
+		Field:                 "title",
+		Query:                 "fast search engine",
+		Operator:              "or",
+		MinimumShouldMatchStr: "75%",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, ExprTypeMatch, expr.Type)
+	assert.Equal(t, map[string]interface{}{
+		"query":                "fast search engine",
+		"operator":             "or",
+		"minimum_should_match": "75%",
+	}, expr.Value)
+}
+
+func TestConvertMatchPhraseQuery(t *testing.T) {
+	converter := NewConverter()
+
+	expr, err := converter.ConvertQuery(&parser.MatchPhraseQuery{Field: "title", Query: "search engine"})
+	require.NoError(t, err)
+	assert.Equal(t, ExprTypeMatchPhrase, expr.Type)
+	assert.Equal(t, "search engine", expr.Value)
+
+	expr, err = converter.ConvertQuery(&parser.MatchPhraseQuery{Field: "title", Query: "search engine", Slop: 2})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"query": "search engine", "slop": 2}, expr.Value)
+}
+
+func TestConvertExpressionQuery(t *testing.T) {
+	converter := NewConverter()
+
+	p := parser.NewQueryParser()
+	query, err := p.ParseQuery(map[string]interface{}{
+		"expr": map[string]interface{}{
+			"op":    ">",
+			"left":  map[string]interface{}{"field": "price", "type": "float64"},
+			"right": map[string]interface{}{"const": 100.0},
+		},
+	})
+	require.NoError(t, err)
+
+	expr, err := converter.ConvertQuery(query)
+	require.NoError(t, err)
+	assert.Equal(t, ExprTypeExpr, expr.Type)
+	assert.Equal(t, query.(*parser.ExpressionQuery).SerializedExpression, expr.Value)
+}
+
 func TestConvertBoolQueryMust(t *testing.T) {
 	converter := NewConverter()
 
@@ -359,6 +504,47 @@ func TestConvertSearchRequestWithAggregations(t *testing.T) {
 	assert.Equal(t, "price", avgAgg.Field)
 }
 
+func TestConvertSearchRequestWithSubAggregations(t *testing.T) {
+	converter := NewConverter()
+
+	reqJSON := `{
+		"aggs": {
+			"categories": {
+				"terms": {"field": "category"},
+				"aggs": {
+					"months": {
+						"date_histogram": {"field": "timestamp", "calendar_interval": "month"},
+						"aggs": {"avg_price": {"avg": {"field": "price"}}}
+					}
+				}
+			}
+		},
+		"size": 0
+	}`
+
+	p := parser.NewQueryParser()
+	req, err := p.ParseSearchRequest([]byte(reqJSON))
+	require.NoError(t, err)
+
+	plan, err := converter.ConvertSearchRequest(req, "products", []int32{0})
+	require.NoError(t, err)
+
+	agg, ok := plan.(*LogicalAggregate)
+	require.True(t, ok)
+	require.Len(t, agg.Aggregations, 1)
+
+	categories := agg.Aggregations[0]
+	assert.Equal(t, AggTypeTerms, categories.Type)
+	require.Len(t, categories.SubAggregations, 1)
+
+	months := categories.SubAggregations[0]
+	assert.Equal(t, "months", months.Name)
+	assert.Equal(t, AggTypeDateHistogram, months.Type)
+	require.Len(t, months.SubAggregations, 1)
+	assert.Equal(t, AggTypeAvg, months.SubAggregations[0].Type)
+	assert.Equal(t, "price", months.SubAggregations[0].Field)
+}
+
 func TestConvertSearchRequestWithProjection(t *testing.T) {
 	converter := NewConverter()
 
@@ -464,6 +650,7 @@ func TestConvertAllAggregationTypes(t *testing.T) {
 		{"count_agg", "count", "_id", AggTypeCount},
 		{"cardinality_agg", "cardinality", "user_id", AggTypeCardinality},
 		{"percentiles_agg", "percentiles", "response_time", AggTypePercentiles},
+		{"percentile_ranks_agg", "percentile_ranks", "response_time", AggTypePercentileRanks},
 		{"histogram_agg", "histogram", "price", AggTypeHistogram},
 		{"date_histogram_agg", "date_histogram", "timestamp", AggTypeDateHistogram},
 	}
@@ -481,6 +668,8 @@ func TestConvertAllAggregationTypes(t *testing.T) {
 				body["interval"] = 100
 			} else if tt.aggType == "date_histogram" {
 				body["interval"] = "1d"
+			} else if tt.aggType == "percentile_ranks" {
+				body["values"] = []interface{}{100.0, 500.0}
 			}
 
 			agg, err := converter.convertAggregation(tt.name, tt.aggType, body)
@@ -493,6 +682,92 @@ func TestConvertAllAggregationTypes(t *testing.T) {
 	}
 }
 
+func TestConvertTermsAggregationParams(t *testing.T) {
+	converter := NewConverter()
+
+	agg, err := converter.convertAggregation("tags", "terms", map[string]interface{}{
+		"field":         "tag",
+		"size":          5.0,
+		"shard_size":    50.0,
+		"min_doc_count": 2.0,
+		"order":         map[string]interface{}{"_key": "asc"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 5, agg.Params["size"])
+	assert.Equal(t, 50, agg.Params["shard_size"])
+	assert.Equal(t, 2, agg.Params["min_doc_count"])
+	assert.Equal(t, map[string]interface{}{"_key": "asc"}, agg.Params["order"])
+}
+
+func TestConvertDateHistogramParams(t *testing.T) {
+	converter := NewConverter()
+
+	agg, err := converter.convertAggregation("per_day", "date_histogram", map[string]interface{}{
+		"field":             "ts",
+		"calendar_interval": "day",
+		"time_zone":         "America/New_York",
+		"offset":            "+6h",
+		"min_doc_count":     0.0,
+		"extended_bounds":   map[string]interface{}{"min": "2024-01-01", "max": "2024-01-31"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "day", agg.Params["calendar_interval"])
+	assert.Equal(t, "America/New_York", agg.Params["time_zone"])
+	assert.Equal(t, "+6h", agg.Params["offset"])
+	assert.Equal(t, 0, agg.Params["min_doc_count"])
+	assert.Equal(t, map[string]interface{}{"min": "2024-01-01", "max": "2024-01-31"}, agg.Params["extended_bounds"])
+}
+
+func TestConvertPipelineAggregations(t *testing.T) {
+	converter := NewConverter()
+
+	aggs, err := converter.convertAggregationDefs(map[string]interface{}{
+		"per_day": map[string]interface{}{
+			"date_histogram": map[string]interface{}{"field": "ts", "calendar_interval": "day"},
+			"aggs": map[string]interface{}{
+				"deriv": map[string]interface{}{"derivative": map[string]interface{}{"buckets_path": "_count"}},
+				"top":   map[string]interface{}{"bucket_sort": map[string]interface{}{"size": 3.0}},
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, aggs, 1)
+
+	subAggs := make(map[string]*Aggregation)
+	for _, sub := range aggs[0].SubAggregations {
+		subAggs[sub.Name] = sub
+	}
+	assert.Equal(t, AggTypeDerivative, subAggs["deriv"].Type)
+	assert.Equal(t, "_count", subAggs["deriv"].Params["buckets_path"])
+	assert.Equal(t, AggTypeBucketSort, subAggs["top"].Type)
+
+	_, err = converter.convertAggregation("best", "max_bucket", map[string]interface{}{})
+	assert.ErrorContains(t, err, "requires buckets_path")
+}
+
+func TestConvertCompositeAggregation(t *testing.T) {
+	converter := NewConverter()
+
+	sources := []interface{}{
+		map[string]interface{}{"host": map[string]interface{}{"terms": map[string]interface{}{"field": "host"}}},
+	}
+	agg, err := converter.convertAggregation("by_host", "composite", map[string]interface{}{
+		"sources": sources,
+		"size":    100.0,
+		"after":   map[string]interface{}{"host": "web-1"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, AggTypeComposite, agg.Type)
+	assert.Equal(t, sources, agg.Params["sources"])
+	assert.Equal(t, 100, agg.Params["size"])
+	assert.Equal(t, map[string]interface{}{"host": "web-1"}, agg.Params["after"])
+
+	_, err = converter.convertAggregation("by_host", "composite", map[string]interface{}{})
+	assert.ErrorContains(t, err, "requires sources")
+}
+
 func TestEstimateSelectivity(t *testing.T) {
 	converter := NewConverter()
 
@@ -624,6 +899,46 @@ func TestConvertSortComplex(t *testing.T) {
 	assert.True(t, sort.SortFields[2].Descending)
 }
 
+func TestConvertSortPushedToScan(t *testing.T) {
+	converter := NewConverter()
+
+	reqJSON := `{
+		"query": {"match_all": {}},
+		"sort": [
+			{"@timestamp": {"order": "desc", "missing": "_first"}},
+			{"_score": {}}
+		],
+		"from": 5,
+		"size": 10
+	}`
+
+	p := parser.NewQueryParser()
+	req, err := p.ParseSearchRequest([]byte(reqJSON))
+	require.NoError(t, err)
+
+	plan, err := converter.ConvertSearchRequest(req, "logs", []int32{0})
+	require.NoError(t, err)
+
+	sort := plan.(*LogicalLimit).Child.(*LogicalSort)
+	scan, ok := sort.Child.(*LogicalScan)
+	require.True(t, ok)
+	assert.Equal(t, sort.SortFields, scan.Sort)
+	assert.Equal(t, "_first", scan.Sort[0].Missing)
+	assert.True(t, scan.Sort[1].Descending, "_score sorts descending by default")
+
+	// The shards return rows in sort order, so only from+size are fetched
+	optimizer := NewOptimizer()
+	optimizer.RuleSet = NewRuleSet(GetDefaultRules()...)
+	optimized, err := optimizer.Optimize(plan)
+	require.NoError(t, err)
+	topN, ok := optimized.(*LogicalTopN)
+	require.True(t, ok)
+	assert.Equal(t, int64(15), topN.Child.(*LogicalScan).Limit)
+
+	_, err = converter.convertSort([]map[string]interface{}{{"price": "sideways"}}, scan)
+	assert.Error(t, err)
+}
+
 func TestConvertWithOptimization(t *testing.T) {
 	converter := NewConverter()
 
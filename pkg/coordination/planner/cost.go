@@ -122,7 +122,7 @@ func (cm *CostModel) estimateFilterExpressionCost(expr *Expression, cardinality
 	}
 
 	switch expr.Type {
-	case ExprTypeTerm, ExprTypeMatch:
+	case ExprTypeTerm, ExprTypeMatch, ExprTypeMatchPhrase:
 		// Simple comparison: one comparison per row
 		return cardinality * cm.ComparisonCost
 
@@ -138,10 +138,30 @@ func (cm *CostModel) estimateFilterExpressionCost(expr *Expression, cardinality
 		}
 		return cost
 
-	case ExprTypeWildcard, ExprTypePrefix:
+	case ExprTypeWildcard, ExprTypePrefix, ExprTypeRegexp, ExprTypeFuzzy:
 		// More expensive: pattern matching
 		return cardinality * cm.ComparisonCost * 5
 
+	case ExprTypeSparse:
+		// Dot product with the query vector per row
+		return cardinality * cm.ComparisonCost * 2
+
+	case ExprTypeKNN:
+		// Distance computations for the graph walk, plus the filter's cost
+		cost := cardinality * cm.ComparisonCost * 3
+		for _, child := range expr.Children {
+			cost += cm.estimateFilterExpressionCost(child, cardinality)
+		}
+		return cost
+
+	case ExprTypeHybrid:
+		// Each sub-query is a search of its own
+		cost := 0.0
+		for _, child := range expr.Children {
+			cost += cm.estimateFilterExpressionCost(child, cardinality)
+		}
+		return cost
+
 	case ExprTypeMatchAll:
 		// Free: matches everything
 		return 0
@@ -7,12 +7,13 @@ import (
 	"sort"
 
 	"github.com/conjugate/conjugate/pkg/coordination/executor"
+	"github.com/conjugate/conjugate/pkg/coordination/expressions"
 	"go.uber.org/zap"
 )
 
 // QueryExecutorInterface defines the interface for query execution
 type QueryExecutorInterface interface {
-	ExecuteSearch(ctx context.Context, indexName string, query []byte, filterExpr []byte, from, size int) (*executor.SearchResult, error)
+	ExecuteSearch(ctx context.Context, indexName string, query []byte, filterExpr []byte, aggs []byte, sort []*executor.SortField, from, size int) (*executor.SearchResult, error)
 }
 
 // ExecutionContext provides the execution environment for physical plans
@@ -58,6 +59,9 @@ func convertExecutorResultToExecution(result *executor.SearchResult) *ExecutionR
 		}
 		row["_id"] = hit.ID
 		row["_score"] = hit.Score
+		if hit.Sort != nil {
+			row["_sort"] = hit.Sort
+		}
 		execResult.Rows[i] = row
 	}
 
@@ -78,25 +82,47 @@ func convertExecutorAggregation(agg *executor.AggregationResult) *AggregationRes
 
 	// Convert buckets
 	for i, bucket := range agg.Buckets {
-		key := bucket.Key
-		if key == "" {
+		var key interface{} = bucket.Key
+		var keyAsString string
+		if agg.Type == "histogram" {
+			key = bucket.NumericKey
+		} else if agg.Type == "date_histogram" {
+			// Date buckets are keyed by epoch millis with the formatted date alongside
+			key = bucket.NumericKey
+			keyAsString = bucket.Key
+		} else if agg.Type == "composite" {
+			key = bucket.CompositeKey
+		} else if bucket.Key == "" {
 			key = fmt.Sprintf("%v", bucket.NumericKey)
 		}
 		result.Buckets[i] = &Bucket{
-			Key:      key,
-			DocCount: bucket.DocCount,
-			SubAggs:  make(map[string]*AggregationResult),
+			Key:         key,
+			KeyAsString: keyAsString,
+			DocCount:    bucket.DocCount,
+			SubAggs:     make(map[string]*AggregationResult, len(bucket.SubAggregations)),
+			From:        bucket.From,
+			To:          bucket.To,
+		}
+
+		// Convert sub-aggregations recursively
+		for subName, subAgg := range bucket.SubAggregations {
+			result.Buckets[i].SubAggs[subName] = convertExecutorAggregation(subAgg)
 		}
 	}
 
 	// For stats aggregations
-	if agg.Type == "stats" || agg.Type == "extended_stats" {
+	if agg.Type == "stats" || agg.Type == "extended_stats" || agg.Type == "stats_bucket" {
 		result.Stats = &Stats{
-			Count: agg.Count,
-			Min:   agg.Min,
-			Max:   agg.Max,
-			Avg:   agg.Avg,
-			Sum:   agg.Sum,
+			Count:                   agg.Count,
+			Min:                     agg.Min,
+			Max:                     agg.Max,
+			Avg:                     agg.Avg,
+			Sum:                     agg.Sum,
+			SumOfSquares:            agg.SumOfSquares,
+			Variance:                agg.Variance,
+			StdDeviation:            agg.StdDeviation,
+			StdDeviationBoundsUpper: agg.StdDeviationBoundsUpper,
+			StdDeviationBoundsLower: agg.StdDeviationBoundsLower,
 		}
 	}
 
@@ -116,6 +142,30 @@ func convertExecutorAggregation(agg *executor.AggregationResult) *AggregationRes
 		result.Value = float64(agg.Value)
 	}
 
+	if agg.Type == "value_count" {
+		result.Value = float64(agg.Count)
+	}
+
+	if agg.Type == "percentiles" || agg.Type == "percentile_ranks" {
+		result.Values = agg.Values
+	}
+
+	if agg.Type == "terms" {
+		result.DocCountErrorUpperBound = agg.DocCountErrorUpperBound
+		result.SumOtherDocCount = agg.SumOtherDocCount
+	}
+
+	if agg.Type == "composite" {
+		result.AfterKey = agg.AfterKey
+	}
+
+	// For single-value pipelines
+	if pipelineAggTypes[result.Type] && result.Type != AggTypeStatsBucket {
+		result.Value = agg.PipelineValue
+		result.NormalizedValue = agg.NormalizedValue
+		result.Keys = agg.Keys
+	}
+
 	return result
 }
 
@@ -129,6 +179,115 @@ func expressionToJSON(expr *Expression) ([]byte, error) {
 	return json.Marshal(query)
 }
 
+// hybridToJSON converts a hybrid query to JSON query bytes. The expr clauses
+// of each sub-query only restrict that sub-query's hits, so they are split
+// off into its own entry of filter_expressions.
+func hybridToJSON(expr *Expression) ([]byte, error) {
+	hybrid := expressionToMap(expr)["hybrid"].(map[string]interface{})
+	queries := make([]interface{}, len(expr.Children))
+	filterExpressions := make([][]byte, len(expr.Children))
+	hasFilterExpressions := false
+	for i, child := range expr.Children {
+		query, filterExpression, err := splitFilterExpression(child)
+		if err != nil {
+			return nil, fmt.Errorf("hybrid sub-query %d: %w", i, err)
+		}
+		if query == nil {
+			queries[i] = map[string]interface{}{"match_all": map[string]interface{}{}}
+		} else {
+			queries[i] = expressionToMap(query)
+		}
+		filterExpressions[i] = filterExpression
+		hasFilterExpressions = hasFilterExpressions || filterExpression != nil
+	}
+	hybrid["queries"] = queries
+	if hasFilterExpressions {
+		hybrid["filter_expressions"] = filterExpressions
+	}
+	return json.Marshal(map[string]interface{}{"hybrid": hybrid})
+}
+
+// splitFilterExpression separates the expr clauses of a filter from the
+// query the data nodes can run. It returns the remaining query (nil if
+// nothing is left) and the expr clauses ANDed into one serialized expression
+// tree. An expr clause is applied as a required filter wherever it appears;
+// negating one is not supported.
+func splitFilterExpression(expr *Expression) (*Expression, []byte, error) {
+	var trees [][]byte
+	var split func(expr *Expression, negated bool) (*Expression, error)
+	split = func(expr *Expression, negated bool) (*Expression, error) {
+		switch expr.Type {
+		case ExprTypeExpr:
+			if negated {
+				return nil, fmt.Errorf("expr queries are not supported in must_not")
+			}
+			data, ok := expr.Value.([]byte)
+			if !ok || len(data) == 0 {
+				return nil, fmt.Errorf("expr query has no serialized expression")
+			}
+			trees = append(trees, data)
+			return nil, nil
+
+		case ExprTypeBool:
+			negated = negated || expr.Value == "must_not"
+			children := make([]*Expression, 0, len(expr.Children))
+			for _, child := range expr.Children {
+				remaining, err := split(child, negated)
+				if err != nil {
+					return nil, err
+				}
+				if remaining != nil {
+					children = append(children, remaining)
+				}
+			}
+			if len(children) == 0 {
+				return nil, nil
+			}
+			if len(children) == len(expr.Children) {
+				return expr, nil
+			}
+			return &Expression{Type: ExprTypeBool, Value: expr.Value, Children: children}, nil
+
+		default:
+			return expr, nil
+		}
+	}
+
+	if expr == nil {
+		return nil, nil, nil
+	}
+	query, err := split(expr, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch len(trees) {
+	case 0:
+		return query, nil, nil
+	case 1:
+		return query, trees[0], nil
+	}
+
+	// Several expr clauses must all hold
+	var combined expressions.Expression
+	for _, data := range trees {
+		tree, err := expressions.NewDeserializer().Deserialize(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid expr query: %w", err)
+		}
+		if combined == nil {
+			combined = tree
+		} else {
+			combined = expressions.NewBinaryOp(expressions.OpAnd, combined, tree, expressions.DataTypeBool)
+		}
+	}
+	data, err := expressions.NewSerializer().Serialize(combined)
+	if err != nil {
+		return nil, nil, err
+	}
+	return query, data, nil
+}
+
 // expressionToMap converts an Expression to a map for JSON serialization
 func expressionToMap(expr *Expression) map[string]interface{} {
 	switch expr.Type {
@@ -151,6 +310,13 @@ func expressionToMap(expr *Expression) map[string]interface{} {
 			},
 		}
 
+	case ExprTypeMatchPhrase:
+		return map[string]interface{}{
+			"match_phrase": map[string]interface{}{
+				expr.Field: expr.Value,
+			},
+		}
+
 	case ExprTypeRange:
 		return map[string]interface{}{
 			"range": map[string]interface{}{
@@ -179,6 +345,53 @@ func expressionToMap(expr *Expression) map[string]interface{} {
 			},
 		}
 
+	case ExprTypeRegexp:
+		return map[string]interface{}{
+			"regexp": map[string]interface{}{
+				expr.Field: expr.Value,
+			},
+		}
+
+	case ExprTypeFuzzy:
+		return map[string]interface{}{
+			"fuzzy": map[string]interface{}{
+				expr.Field: expr.Value,
+			},
+		}
+
+	case ExprTypeSparse:
+		return map[string]interface{}{
+			"neural_sparse": map[string]interface{}{
+				expr.Field: expr.Value,
+			},
+		}
+
+	case ExprTypeKNN:
+		knn := map[string]interface{}{"field": expr.Field}
+		if options, ok := expr.Value.(map[string]interface{}); ok {
+			for name, option := range options {
+				knn[name] = option
+			}
+		}
+		if len(expr.Children) == 1 {
+			knn["filter"] = expressionToMap(expr.Children[0])
+		}
+		return map[string]interface{}{"knn": knn}
+
+	case ExprTypeHybrid:
+		hybrid := make(map[string]interface{})
+		if options, ok := expr.Value.(map[string]interface{}); ok {
+			for name, option := range options {
+				hybrid[name] = option
+			}
+		}
+		queries := make([]interface{}, len(expr.Children))
+		for i, child := range expr.Children {
+			queries[i] = expressionToMap(child)
+		}
+		hybrid["queries"] = queries
+		return map[string]interface{}{"hybrid": hybrid}
+
 	case ExprTypeBool:
 		boolQuery := make(map[string]interface{})
 
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/conjugate/conjugate/pkg/coordination/executor"
+	"github.com/conjugate/conjugate/pkg/coordination/expressions"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
@@ -13,9 +14,11 @@ import (
 // Mock QueryExecutor for testing
 type mockQueryExecutor struct {
 	searchFunc func(ctx context.Context, indexName string, query []byte, filterExpr []byte, from, size int) (*executor.SearchResult, error)
+	lastSort   []*executor.SortField // Sort of the last search
 }
 
-func (m *mockQueryExecutor) ExecuteSearch(ctx context.Context, indexName string, query []byte, filterExpr []byte, from, size int) (*executor.SearchResult, error) {
+func (m *mockQueryExecutor) ExecuteSearch(ctx context.Context, indexName string, query []byte, filterExpr []byte, aggs []byte, sort []*executor.SortField, from, size int) (*executor.SearchResult, error) {
+	m.lastSort = sort
 	if m.searchFunc != nil {
 		return m.searchFunc(ctx, indexName, query, filterExpr, from, size)
 	}
@@ -134,6 +137,106 @@ func TestConvertExecutorResultToExecution(t *testing.T) {
 	assert.Equal(t, 5000.0, statsAgg.Stats.Sum)
 }
 
+func TestConvertExecutorAggregationNested(t *testing.T) {
+	agg := &executor.AggregationResult{
+		Type: "terms",
+		Buckets: []*executor.AggregationBucket{
+			{
+				Key:      "cat1",
+				DocCount: 5,
+				SubAggregations: map[string]*executor.AggregationResult{
+					"months": {
+						Type: "date_histogram",
+						Buckets: []*executor.AggregationBucket{
+							{
+								Key:        "2024-01-01T00:00:00.000Z",
+								NumericKey: 1704067200000,
+								DocCount:   5,
+								SubAggregations: map[string]*executor.AggregationResult{
+									"avg_price": {Type: "avg", Avg: 12.5},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	result := convertExecutorAggregation(agg)
+
+	require.Len(t, result.Buckets, 1)
+	months := result.Buckets[0].SubAggs["months"]
+	require.NotNil(t, months)
+	require.Len(t, months.Buckets, 1)
+
+	// Date buckets are keyed by epoch millis and keep the formatted date
+	assert.Equal(t, 1704067200000.0, months.Buckets[0].Key)
+	assert.Equal(t, "2024-01-01T00:00:00.000Z", months.Buckets[0].KeyAsString)
+	assert.Equal(t, 12.5, months.Buckets[0].SubAggs["avg_price"].Value)
+}
+
+func TestSplitFilterExpression(t *testing.T) {
+	serialize := func(expr expressions.Expression) []byte {
+		data, err := expressions.NewSerializer().Serialize(expr)
+		require.NoError(t, err)
+		return append([]byte{}, data...)
+	}
+	priceAbove := serialize(expressions.NewBinaryOp(expressions.OpGreaterThan,
+		expressions.NewField("price", expressions.DataTypeFloat64), expressions.NewConstFloat(10), expressions.DataTypeBool))
+	inStock := serialize(expressions.NewField("in_stock", expressions.DataTypeBool))
+
+	// No expr clauses: the filter is unchanged
+	term := &Expression{Type: ExprTypeTerm, Field: "status", Value: "active"}
+	query, filter, err := splitFilterExpression(term)
+	require.NoError(t, err)
+	assert.Equal(t, term, query)
+	assert.Nil(t, filter)
+
+	// A lone expr clause leaves no query
+	query, filter, err = splitFilterExpression(&Expression{Type: ExprTypeExpr, Value: priceAbove})
+	require.NoError(t, err)
+	assert.Nil(t, query)
+	assert.Equal(t, priceAbove, filter)
+
+	// Several expr clauses are ANDed together
+	query, filter, err = splitFilterExpression(&Expression{
+		Type: ExprTypeBool,
+		Children: []*Expression{
+			term,
+			{Type: ExprTypeExpr, Value: priceAbove},
+			{Type: ExprTypeExpr, Value: inStock},
+		},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, query)
+	assert.Equal(t, []*Expression{term}, query.Children)
+
+	combined, err := expressions.NewDeserializer().Deserialize(filter)
+	require.NoError(t, err)
+	evaluator := expressions.NewEvaluator(combined)
+	doc := map[string]interface{}{"price": 20.0, "in_stock": false}
+	source := func(path string) (interface{}, bool) {
+		val, ok := doc[path]
+		return val, ok
+	}
+	matches, err := evaluator.EvaluateBool(source)
+	require.NoError(t, err)
+	assert.False(t, matches)
+	doc["in_stock"] = true
+	matches, err = evaluator.EvaluateBool(source)
+	require.NoError(t, err)
+	assert.True(t, matches)
+
+	// Negated expr clauses are rejected
+	_, _, err = splitFilterExpression(&Expression{
+		Type:     ExprTypeBool,
+		Value:    "must_not",
+		Children: []*Expression{{Type: ExprTypeExpr, Value: priceAbove}},
+	})
+	assert.Error(t, err)
+}
+
 func TestExpressionToJSON(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -165,6 +268,73 @@ func TestExpressionToJSON(t *testing.T) {
 			},
 			expected: `{"match":{"title":"search engine"}}`,
 		},
+		{
+			name: "match with options",
+			expr: &Expression{
+				Type:  ExprTypeMatch,
+				Field: "title",
+				Value: map[string]interface{}{"query": "search engine", "operator": "and"},
+			},
+			expected: `{"match":{"title":{"query":"search engine","operator":"and"}}}`,
+		},
+		{
+			name: "match_phrase",
+			expr: &Expression{
+				Type:  ExprTypeMatchPhrase,
+				Field: "title",
+				Value: map[string]interface{}{"query": "search engine", "slop": 1},
+			},
+			expected: `{"match_phrase":{"title":{"query":"search engine","slop":1}}}`,
+		},
+		{
+			name: "regexp",
+			expr: &Expression{
+				Type:  ExprTypeRegexp,
+				Field: "name",
+				Value: "jo.*n",
+			},
+			expected: `{"regexp":{"name":"jo.*n"}}`,
+		},
+		{
+			name: "fuzzy",
+			expr: &Expression{
+				Type:  ExprTypeFuzzy,
+				Field: "name",
+				Value: map[string]interface{}{"value": "jon", "fuzziness": "AUTO"},
+			},
+			expected: `{"fuzzy":{"name":{"value":"jon","fuzziness":"AUTO"}}}`,
+		},
+		{
+			name: "neural_sparse",
+			expr: &Expression{
+				Type:  ExprTypeSparse,
+				Field: "embedding",
+				Value: map[string]interface{}{"query_tokens": map[string]float64{"search": 1.2}},
+			},
+			expected: `{"neural_sparse":{"embedding":{"query_tokens":{"search":1.2}}}}`,
+		},
+		{
+			name: "knn",
+			expr: &Expression{
+				Type:     ExprTypeKNN,
+				Field:    "embedding",
+				Value:    map[string]interface{}{"query_vector": []float64{0.1, 0.2}, "k": 5, "num_candidates": 50},
+				Children: []*Expression{{Type: ExprTypeTerm, Field: "category", Value: "books"}},
+			},
+			expected: `{"knn":{"field":"embedding","query_vector":[0.1,0.2],"k":5,"num_candidates":50,"filter":{"term":{"category":"books"}}}}`,
+		},
+		{
+			name: "hybrid",
+			expr: &Expression{
+				Type:  ExprTypeHybrid,
+				Value: map[string]interface{}{"pagination_depth": 20},
+				Children: []*Expression{
+					{Type: ExprTypeMatch, Field: "title", Value: "laptop"},
+					{Type: ExprTypeTerm, Field: "brand", Value: "acme"},
+				},
+			},
+			expected: `{"hybrid":{"pagination_depth":20,"queries":[{"match":{"title":"laptop"}},{"term":{"brand":"acme"}}]}}`,
+		},
 		{
 			name: "exists",
 			expr: &Expression{
@@ -69,6 +69,9 @@ type LogicalScan struct {
 	Shards           []int32
 	Filter           *Expression // Optional filter expression (pushdown)
 	EstimatedRows    int64       // Estimated number of rows
+	Limit            int64       // Max rows to fetch in ranking order (0 = no limit, set by limit pushdown)
+	Aggregations     map[string]interface{} // Raw aggs DSL evaluated by the shards (pushdown)
+	Sort             []*SortField           // Sort keys the shards rank their matches by (pushdown)
 }
 
 func (s *LogicalScan) Type() PlanType               { return PlanTypeScan }
@@ -82,6 +85,9 @@ func (s *LogicalScan) Schema() *Schema {
 }
 func (s *LogicalScan) Cardinality() int64 { return s.EstimatedRows }
 func (s *LogicalScan) String() string {
+	if len(s.Aggregations) > 0 {
+		return fmt.Sprintf("Scan(index=%s, shards=%v, filter=%v, aggs=%v)", s.IndexName, s.Shards, s.Filter, s.Aggregations)
+	}
 	return fmt.Sprintf("Scan(index=%s, shards=%v, filter=%v)", s.IndexName, s.Shards, s.Filter)
 }
 
@@ -133,26 +139,63 @@ func (p *LogicalProject) String() string {
 type AggregationType string
 
 const (
-	AggTypeCount          AggregationType = "count"
-	AggTypeSum            AggregationType = "sum"
-	AggTypeAvg            AggregationType = "avg"
-	AggTypeMin            AggregationType = "min"
-	AggTypeMax            AggregationType = "max"
-	AggTypeTerms          AggregationType = "terms"
-	AggTypeStats          AggregationType = "stats"
-	AggTypeHistogram      AggregationType = "histogram"
-	AggTypeDateHistogram  AggregationType = "date_histogram"
-	AggTypePercentiles    AggregationType = "percentiles"
-	AggTypeCardinality    AggregationType = "cardinality"
-	AggTypeExtendedStats  AggregationType = "extended_stats"
+	AggTypeCount           AggregationType = "count"
+	AggTypeSum             AggregationType = "sum"
+	AggTypeAvg             AggregationType = "avg"
+	AggTypeMin             AggregationType = "min"
+	AggTypeMax             AggregationType = "max"
+	AggTypeTerms           AggregationType = "terms"
+	AggTypeStats           AggregationType = "stats"
+	AggTypeHistogram       AggregationType = "histogram"
+	AggTypeDateHistogram   AggregationType = "date_histogram"
+	AggTypePercentiles     AggregationType = "percentiles"
+	AggTypePercentileRanks AggregationType = "percentile_ranks"
+	AggTypeCardinality     AggregationType = "cardinality"
+	AggTypeExtendedStats   AggregationType = "extended_stats"
+	AggTypeValueCount      AggregationType = "value_count"
+	AggTypeRange           AggregationType = "range"
+	AggTypeFilters         AggregationType = "filters"
+	AggTypeComposite       AggregationType = "composite"
+
+	// Pipeline aggregations, evaluated over the results of the others
+	AggTypeDerivative     AggregationType = "derivative"
+	AggTypeCumulativeSum  AggregationType = "cumulative_sum"
+	AggTypeMovingFn       AggregationType = "moving_fn"
+	AggTypeSerialDiff     AggregationType = "serial_diff"
+	AggTypeBucketScript   AggregationType = "bucket_script"
+	AggTypeBucketSelector AggregationType = "bucket_selector"
+	AggTypeBucketSort     AggregationType = "bucket_sort"
+	AggTypeAvgBucket      AggregationType = "avg_bucket"
+	AggTypeMaxBucket      AggregationType = "max_bucket"
+	AggTypeMinBucket      AggregationType = "min_bucket"
+	AggTypeSumBucket      AggregationType = "sum_bucket"
+	AggTypeStatsBucket    AggregationType = "stats_bucket"
 )
 
+// pipelineAggTypes are the pipeline aggregation types
+var pipelineAggTypes = map[AggregationType]bool{
+	AggTypeDerivative:     true,
+	AggTypeCumulativeSum:  true,
+	AggTypeMovingFn:       true,
+	AggTypeSerialDiff:     true,
+	AggTypeBucketScript:   true,
+	AggTypeBucketSelector: true,
+	AggTypeBucketSort:     true,
+	AggTypeAvgBucket:      true,
+	AggTypeMaxBucket:      true,
+	AggTypeMinBucket:      true,
+	AggTypeSumBucket:      true,
+	AggTypeStatsBucket:    true,
+}
+
 // Aggregation represents an aggregation operation
 type Aggregation struct {
 	Name   string
 	Type   AggregationType
 	Field  string
 	Params map[string]interface{} // Additional parameters (e.g., size for terms, interval for histogram)
+
+	SubAggregations []*Aggregation // Aggregations computed within each bucket
 }
 
 // LogicalAggregate represents an aggregation operation
@@ -192,6 +235,7 @@ type LogicalSort struct {
 type SortField struct {
 	Field      string
 	Descending bool
+	Missing    interface{} // "_last" (default), "_first" or a value to use instead
 }
 
 func (s *LogicalSort) Type() PlanType          { return PlanTypeSort }
@@ -288,14 +332,21 @@ type Expression struct {
 type ExpressionType string
 
 const (
-	ExprTypeTerm       ExpressionType = "term"
-	ExprTypeMatch      ExpressionType = "match"
-	ExprTypeRange      ExpressionType = "range"
-	ExprTypeBool       ExpressionType = "bool"
-	ExprTypeWildcard   ExpressionType = "wildcard"
-	ExprTypePrefix     ExpressionType = "prefix"
-	ExprTypeExists     ExpressionType = "exists"
-	ExprTypeMatchAll   ExpressionType = "match_all"
+	ExprTypeTerm        ExpressionType = "term"
+	ExprTypeMatch       ExpressionType = "match"        // Value holds the query text or an options object
+	ExprTypeMatchPhrase ExpressionType = "match_phrase" // Value holds the query text or an options object
+	ExprTypeRange       ExpressionType = "range"
+	ExprTypeBool        ExpressionType = "bool"
+	ExprTypeWildcard    ExpressionType = "wildcard"
+	ExprTypePrefix      ExpressionType = "prefix"
+	ExprTypeRegexp      ExpressionType = "regexp" // Value holds the pattern or an options object
+	ExprTypeFuzzy       ExpressionType = "fuzzy"  // Value holds the term or an options object
+	ExprTypeExists      ExpressionType = "exists"
+	ExprTypeSparse      ExpressionType = "neural_sparse" // Value holds an options object with query_tokens
+	ExprTypeKNN         ExpressionType = "knn"           // Value holds an options object with query_vector and k; Children the filter, if any
+	ExprTypeHybrid      ExpressionType = "hybrid"        // Value holds an options object with the fusion; Children the sub-queries
+	ExprTypeMatchAll    ExpressionType = "match_all"
+	ExprTypeExpr        ExpressionType = "expr" // Value holds the serialized expression tree
 )
 
 func (e *Expression) String() string {
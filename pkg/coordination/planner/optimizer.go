@@ -1,5 +1,7 @@
 package planner
 
+import "reflect"
+
 // Rule represents an optimization rule that transforms a logical plan
 type Rule interface {
 	// Name returns the rule name
@@ -157,6 +159,9 @@ func (r *FilterPushdownRule) Apply(plan LogicalPlan) (LogicalPlan, bool) {
 		Shards:        scan.Shards,
 		Filter:        r.combineFilters(scan.Filter, filter.Condition),
 		EstimatedRows: filter.EstimatedRows,
+		Limit:         scan.Limit,
+		Aggregations:  scan.Aggregations,
+		Sort:          scan.Sort,
 	}
 
 	return newScan, true
@@ -220,10 +225,84 @@ func NewLimitPushdownRule() *LimitPushdownRule {
 }
 
 func (r *LimitPushdownRule) Apply(plan LogicalPlan) (LogicalPlan, bool) {
-	// TODO: Implement limit pushdown
+	switch node := plan.(type) {
+	case *LogicalLimit:
+		newChild, ok := r.pushToScan(node.Child, node.Offset+node.Limit, nil)
+		if !ok {
+			return nil, false
+		}
+		return &LogicalLimit{
+			Offset: node.Offset,
+			Limit:  node.Limit,
+			Child:  newChild,
+		}, true
+
+	case *LogicalTopN:
+		// Only a scan the shards sort by the same keys returns rows in
+		// TopN order
+		newChild, ok := r.pushToScan(node.Child, node.Offset+node.N, node.SortFields)
+		if !ok {
+			return nil, false
+		}
+		return &LogicalTopN{
+			N:          node.N,
+			Offset:     node.Offset,
+			SortFields: node.SortFields,
+			Child:      newChild,
+		}, true
+	}
 	return nil, false
 }
 
+// pushToScan sets fetch as the limit of the scan under child. The scan
+// returns rows in ranking order (or in sortFields order, if given), so only
+// the first fetch rows can reach the output. A projection in between keeps
+// row order.
+func (r *LimitPushdownRule) pushToScan(child LogicalPlan, fetch int64, sortFields []*SortField) (LogicalPlan, bool) {
+	project, ok := child.(*LogicalProject)
+	if ok {
+		child = project.Child
+	}
+
+	scan, ok := child.(*LogicalScan)
+	if !ok {
+		return nil, false
+	}
+	if sortFields != nil && !sameSortFields(scan.Sort, sortFields) {
+		return nil, false
+	}
+
+	if scan.Limit == fetch {
+		return nil, false // Already pushed down
+	}
+
+	newScan := *scan
+	newScan.Limit = fetch
+
+	if project == nil {
+		return &newScan, true
+	}
+	return &LogicalProject{
+		Fields:       project.Fields,
+		Child:        &newScan,
+		OutputSchema: project.OutputSchema,
+	}, true
+}
+
+// sameSortFields reports whether two sort specifications are equal
+func sameSortFields(a, b []*SortField) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Field != b[i].Field || a[i].Descending != b[i].Descending ||
+			!reflect.DeepEqual(a[i].Missing, b[i].Missing) {
+			return false
+		}
+	}
+	return true
+}
+
 // RedundantFilterEliminationRule removes redundant filters
 type RedundantFilterEliminationRule struct {
 	BaseRule
@@ -378,6 +378,100 @@ func TestTopNOptimizationRuleWithOffset(t *testing.T) {
 	assert.Len(t, topN.SortFields, 2)
 }
 
+func TestLimitPushdownRule(t *testing.T) {
+	// Create a plan: Limit(offset=20, limit=10) -> Scan
+	scan := &LogicalScan{
+		IndexName:     "products",
+		Shards:        []int32{0},
+		EstimatedRows: 10000,
+	}
+
+	limit := &LogicalLimit{
+		Limit:  10,
+		Offset: 20,
+		Child:  scan,
+	}
+
+	rule := NewLimitPushdownRule()
+	newPlan, applied := rule.Apply(limit)
+	assert.True(t, applied)
+	require.NotNil(t, newPlan)
+
+	// Limit stays on top and the scan fetches offset+limit rows
+	newLimit, ok := newPlan.(*LogicalLimit)
+	require.True(t, ok)
+	assert.Equal(t, int64(20), newLimit.Offset)
+	assert.Equal(t, int64(10), newLimit.Limit)
+
+	newScan, ok := newLimit.Child.(*LogicalScan)
+	require.True(t, ok)
+	assert.Equal(t, int64(30), newScan.Limit)
+	assert.Equal(t, int64(0), scan.Limit, "original scan should not be modified")
+
+	// Applying again is a no-op
+	_, applied = rule.Apply(newPlan)
+	assert.False(t, applied)
+}
+
+func TestLimitPushdownRuleThroughProject(t *testing.T) {
+	// Create a plan: Limit -> Project -> Scan
+	scan := &LogicalScan{
+		IndexName:     "products",
+		Shards:        []int32{0},
+		EstimatedRows: 10000,
+	}
+
+	project := &LogicalProject{
+		Fields: []string{"name"},
+		Child:  scan,
+	}
+
+	limit := &LogicalLimit{
+		Limit: 5,
+		Child: project,
+	}
+
+	rule := NewLimitPushdownRule()
+	newPlan, applied := rule.Apply(limit)
+	assert.True(t, applied)
+
+	newProject, ok := newPlan.(*LogicalLimit).Child.(*LogicalProject)
+	require.True(t, ok)
+	assert.Equal(t, []string{"name"}, newProject.Fields)
+	assert.Equal(t, int64(5), newProject.Child.(*LogicalScan).Limit)
+}
+
+func TestLimitPushdownRuleIntoSortedScan(t *testing.T) {
+	sortFields := []*SortField{{Field: "@timestamp", Descending: true}}
+
+	// TopN over a scan the shards sort the same way fetches offset+N rows
+	topN := &LogicalTopN{
+		N:          10,
+		Offset:     20,
+		SortFields: sortFields,
+		Child:      &LogicalScan{IndexName: "logs", Sort: sortFields},
+	}
+
+	rule := NewLimitPushdownRule()
+	newPlan, applied := rule.Apply(topN)
+	require.True(t, applied)
+	newTopN, ok := newPlan.(*LogicalTopN)
+	require.True(t, ok)
+	assert.Equal(t, int64(30), newTopN.Child.(*LogicalScan).Limit)
+
+	_, applied = rule.Apply(newPlan)
+	assert.False(t, applied)
+
+	// A scan in ranking order could drop rows the TopN needs
+	unsorted := &LogicalTopN{
+		N:          10,
+		SortFields: sortFields,
+		Child:      &LogicalScan{IndexName: "logs"},
+	}
+	_, applied = rule.Apply(unsorted)
+	assert.False(t, applied)
+}
+
 func TestTopNOptimizationRuleDoesNotApplyWithoutSort(t *testing.T) {
 	// Create a plan: Limit -> Scan (no sort)
 	scan := &LogicalScan{
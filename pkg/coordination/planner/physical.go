@@ -2,8 +2,10 @@ package planner
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 
+	"github.com/conjugate/conjugate/pkg/coordination/executor"
 	"go.uber.org/zap"
 )
 
@@ -50,6 +52,10 @@ type ExecutionResult struct {
 	MaxScore     float64                  // Maximum relevance score
 	Aggregations map[string]*AggregationResult // Aggregation results
 	TookMillis   int64                    // Execution time in milliseconds
+
+	// SortedByShards is set when the rows are already in the requested sort
+	// order because the shards sorted them (each row carries its "_sort" values)
+	SortedByShards bool
 }
 
 // AggregationResult represents the result of an aggregation
@@ -58,13 +64,32 @@ type AggregationResult struct {
 	Buckets []*Bucket // For terms, histogram, etc.
 	Value   float64   // For single-value aggregations (sum, avg, etc.)
 	Stats   *Stats    // For stats aggregations
+	Values  map[string]float64 // For percentiles (percent -> value)
+
+	// For terms: the most a bucket's count may be understated by the shards'
+	// shard_size cut (-1 if unbounded), and the count of documents in terms
+	// not returned
+	DocCountErrorUpperBound int64
+	SumOtherDocCount        int64
+
+	// For pipelines: a derivative per unit, and the keys of the buckets
+	// max_bucket or min_bucket selected. Value is NaN if a pipeline has none.
+	NormalizedValue *float64
+	Keys            []string
+
+	// For composite: the key of the last bucket, to request the next page
+	// after (nil if there are no buckets)
+	AfterKey map[string]interface{}
 }
 
 // Bucket represents a bucket in a bucketing aggregation
 type Bucket struct {
-	Key      interface{} // Bucket key
-	DocCount int64       // Number of documents in this bucket
-	SubAggs  map[string]*AggregationResult // Sub-aggregations
+	Key         interface{}                   // Bucket key
+	KeyAsString string                        // Formatted key (date_histogram)
+	DocCount    int64                         // Number of documents in this bucket
+	SubAggs     map[string]*AggregationResult // Sub-aggregations
+	From        *float64                      // Range bucket lower bound (nil if unbounded)
+	To          *float64                      // Range bucket upper bound (nil if unbounded)
 }
 
 // Stats represents statistics for a field
@@ -74,14 +99,28 @@ type Stats struct {
 	Max   float64
 	Avg   float64
 	Sum   float64
+
+	// Extended stats only
+	SumOfSquares            float64
+	Variance                float64
+	StdDeviation            float64
+	StdDeviationBoundsUpper float64
+	StdDeviationBoundsLower float64
 }
 
+// defaultScanSize is how many hits a scan fetches when no limit was pushed
+// down, which is the deepest page a search may request
+const defaultScanSize = executor.MaxResultWindow
+
 // PhysicalScan represents a physical scan operation
 type PhysicalScan struct {
 	IndexName   string
 	Shards      []int32
 	Filter      *Expression
 	Fields      []string // Fields to retrieve (projection)
+	Limit       int64    // Max hits to fetch (0 = up to defaultScanSize)
+	Aggregations map[string]interface{} // Raw aggs DSL evaluated by the shards
+	Sort         []*SortField           // Sort keys evaluated by the shards
 	OutputSchema *Schema
 	EstimatedCost *Cost
 }
@@ -104,14 +143,25 @@ func (s *PhysicalScan) Execute(ctx context.Context) (*ExecutionResult, error) {
 			zap.Bool("has_filter", s.Filter != nil))
 	}
 
+	// expr clauses travel separately as a serialized expression tree
+	query, filterExpression, err := splitFilterExpression(s.Filter)
+	if err != nil {
+		return nil, err
+	}
+
 	// Convert filter expression to JSON query
 	// If no filter, use match_all query
 	var queryBytes []byte
-	if s.Filter == nil {
+	if query == nil {
 		// Use match_all query when no filter is present
 		queryBytes = []byte(`{"match_all":{}}`)
+	} else if query.Type == ExprTypeHybrid {
+		queryBytes, err = hybridToJSON(query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert hybrid query to JSON: %w", err)
+		}
 	} else {
-		queryBytes, err = expressionToJSON(s.Filter)
+		queryBytes, err = expressionToJSON(query)
 		if err != nil {
 			return nil, fmt.Errorf("failed to convert filter to JSON: %w", err)
 		}
@@ -123,15 +173,40 @@ func (s *PhysicalScan) Execute(ctx context.Context) (*ExecutionResult, error) {
 			zap.String("query", string(queryBytes)))
 	}
 
+	var aggsBytes []byte
+	if len(s.Aggregations) > 0 {
+		aggsBytes, err = json.Marshal(s.Aggregations)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize aggregations: %w", err)
+		}
+	}
+
+	// Fetch only what a pushed-down limit needs; the limit above the scan
+	// applies the offset after the shards' hits are merged
+	size := defaultScanSize
+	if s.Limit > 0 && s.Limit < int64(defaultScanSize) {
+		size = int(s.Limit)
+	}
+
+	var sortFields []*executor.SortField
+	for _, sf := range s.Sort {
+		sortFields = append(sortFields, &executor.SortField{
+			Field:      sf.Field,
+			Descending: sf.Descending,
+			Missing:    sf.Missing,
+		})
+	}
+
 	// Execute distributed search via QueryExecutor
-	// Note: QueryExecutor handles pagination internally, but for scan we want all results
 	executorResult, err := execCtx.QueryExecutor.ExecuteSearch(
 		ctx,
 		s.IndexName,
 		queryBytes,
-		nil, // filterExpression (separate from query)
-		0,   // from
-		10000, // size (large enough to get all results for this node)
+		filterExpression,
+		aggsBytes,
+		sortFields,
+		0, // from
+		size,
 	)
 	if err != nil {
 		if execCtx.Logger != nil {
@@ -147,7 +222,9 @@ func (s *PhysicalScan) Execute(ctx context.Context) (*ExecutionResult, error) {
 	}
 
 	// Convert executor result to execution result
-	return convertExecutorResultToExecution(executorResult), nil
+	execResult := convertExecutorResultToExecution(executorResult)
+	execResult.SortedByShards = len(sortFields) > 0
+	return execResult, nil
 }
 func (s *PhysicalScan) String() string {
 	return fmt.Sprintf("PhysicalScan(index=%s, shards=%v, filter=%v)", s.IndexName, s.Shards, s.Filter)
@@ -290,8 +367,10 @@ func (s *PhysicalSort) Execute(ctx context.Context) (*ExecutionResult, error) {
 		return nil, err
 	}
 
-	// Apply sorting to result rows
-	childResult.Rows = sortRows(childResult.Rows, s.SortFields)
+	// Apply sorting to result rows, unless the shards already sorted them
+	if !childResult.SortedByShards {
+		childResult.Rows = sortRows(childResult.Rows, s.SortFields)
+	}
 
 	return childResult, nil
 }
@@ -350,8 +429,11 @@ func (t *PhysicalTopN) Execute(ctx context.Context) (*ExecutionResult, error) {
 		return nil, err
 	}
 
-	// Sort all rows first (in a real implementation, we'd use a heap for top-N)
-	childResult.Rows = sortRows(childResult.Rows, t.SortFields)
+	// Sort all rows first (in a real implementation, we'd use a heap for top-N),
+	// unless the shards already sorted them
+	if !childResult.SortedByShards {
+		childResult.Rows = sortRows(childResult.Rows, t.SortFields)
+	}
 
 	// Apply limit and offset
 	childResult.Rows = applyLimitToRows(childResult.Rows, t.Offset, t.N)
@@ -403,6 +485,9 @@ func (p *Planner) planScan(logical *LogicalScan) (PhysicalPlan, error) {
 		Shards:        logical.Shards,
 		Filter:        logical.Filter,
 		Fields:        []string{}, // TODO: Get from projection
+		Limit:         logical.Limit,
+		Aggregations:  logical.Aggregations,
+		Sort:          logical.Sort,
 		OutputSchema:  logical.Schema(),
 		EstimatedCost: cost,
 	}, nil
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/conjugate/conjugate/pkg/coordination/executor"
+	"github.com/conjugate/conjugate/pkg/coordination/parser"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
@@ -63,6 +64,73 @@ func TestPhysicalScanExecute(t *testing.T) {
 	assert.Equal(t, "Test Doc", result.Rows[0]["title"])
 }
 
+func TestPhysicalScanExecuteWithExprFilter(t *testing.T) {
+	var sentQuery, sentFilter []byte
+	mockExec := &mockQueryExecutor{
+		searchFunc: func(ctx context.Context, indexName string, query []byte, filterExpr []byte, from, size int) (*executor.SearchResult, error) {
+			sentQuery = query
+			sentFilter = filterExpr
+			return &executor.SearchResult{}, nil
+		},
+	}
+	ctx := WithExecutionContext(context.Background(), &ExecutionContext{QueryExecutor: mockExec})
+
+	serialized := []byte{1, 1, 1}
+	scan := &PhysicalScan{
+		IndexName: "products",
+		Filter: &Expression{
+			Type: ExprTypeBool,
+			Children: []*Expression{
+				{Type: ExprTypeTerm, Field: "status", Value: "active"},
+				{Type: ExprTypeExpr, Value: serialized},
+			},
+		},
+	}
+
+	_, err := scan.Execute(ctx)
+	require.NoError(t, err)
+
+	// The expr clause travels as the filter expression, not in the query
+	assert.Equal(t, serialized, sentFilter)
+	assert.JSONEq(t, `{"bool":{"should":[{"term":{"status":"active"}}]}}`, string(sentQuery))
+}
+
+func TestPhysicalScanExecuteHybrid(t *testing.T) {
+	var sentQuery, sentFilter []byte
+	mockExec := &mockQueryExecutor{
+		searchFunc: func(ctx context.Context, indexName string, query []byte, filterExpr []byte, from, size int) (*executor.SearchResult, error) {
+			sentQuery = query
+			sentFilter = filterExpr
+			return &executor.SearchResult{}, nil
+		},
+	}
+	ctx := WithExecutionContext(context.Background(), &ExecutionContext{QueryExecutor: mockExec})
+
+	serialized := []byte{1, 1, 1}
+	scan := &PhysicalScan{
+		IndexName: "products",
+		Filter: &Expression{
+			Type:  ExprTypeHybrid,
+			Value: map[string]interface{}{"fusion": parser.DefaultHybridFusion()},
+			Children: []*Expression{
+				{Type: ExprTypeMatch, Field: "title", Value: "laptop"},
+				{Type: ExprTypeExpr, Value: serialized},
+			},
+		},
+	}
+
+	_, err := scan.Execute(ctx)
+	require.NoError(t, err)
+
+	// Each sub-query keeps its own expr clauses
+	assert.Nil(t, sentFilter)
+	assert.JSONEq(t, `{"hybrid":{
+		"queries":[{"match":{"title":"laptop"}},{"match_all":{}}],
+		"filter_expressions":[null,"AQEB"],
+		"fusion":{"normalization":"min_max","combination":"arithmetic_mean"}
+	}}`, string(sentQuery))
+}
+
 func TestPhysicalFilterExecute(t *testing.T) {
 	logger := zap.NewNop()
 
@@ -226,6 +294,44 @@ func TestPhysicalSortExecute(t *testing.T) {
 	assert.Equal(t, "1", result.Rows[2]["_id"]) // Charlie
 }
 
+func TestPhysicalSortExecuteSortedByShards(t *testing.T) {
+	mockExec := &mockQueryExecutor{
+		searchFunc: func(ctx context.Context, indexName string, query []byte, filterExpr []byte, from, size int) (*executor.SearchResult, error) {
+			// Shards sorted by @timestamp desc; the second hit has no timestamp
+			return &executor.SearchResult{
+				TotalHits: 3,
+				Hits: []*executor.SearchHit{
+					{ID: "1", Source: map[string]interface{}{"@timestamp": "2024-01-02T00:00:00Z"}, Sort: []interface{}{1704153600000.0}},
+					{ID: "2", Source: map[string]interface{}{"@timestamp": "2024-01-01T00:00:00Z"}, Sort: []interface{}{1704067200000.0}},
+					{ID: "3", Source: map[string]interface{}{}, Sort: []interface{}{nil}},
+				},
+			}, nil
+		},
+	}
+	ctx := WithExecutionContext(context.Background(), &ExecutionContext{QueryExecutor: mockExec})
+
+	sortFields := []*SortField{{Field: "@timestamp", Descending: true, Missing: "_last"}}
+	sort := &PhysicalSort{
+		SortFields: sortFields,
+		Child: &PhysicalScan{
+			IndexName: "logs",
+			Sort:      sortFields,
+		},
+	}
+
+	result, err := sort.Execute(ctx)
+	require.NoError(t, err)
+
+	// The sort is forwarded to the shards and their order is kept
+	require.Len(t, mockExec.lastSort, 1)
+	assert.Equal(t, &executor.SortField{Field: "@timestamp", Descending: true, Missing: "_last"}, mockExec.lastSort[0])
+	require.Len(t, result.Rows, 3)
+	assert.Equal(t, "1", result.Rows[0]["_id"])
+	assert.Equal(t, "2", result.Rows[1]["_id"])
+	assert.Equal(t, "3", result.Rows[2]["_id"])
+	assert.Equal(t, []interface{}{nil}, result.Rows[2]["_sort"])
+}
+
 func TestPhysicalLimitExecute(t *testing.T) {
 	logger := zap.NewNop()
 
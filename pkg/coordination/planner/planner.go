@@ -164,7 +164,7 @@ func (qp *QueryPlanner) analyzeComplexity(query parser.Query) int {
 			complexity += qp.analyzeComplexity(filter)
 		}
 
-	case *parser.WildcardQuery, *parser.QueryStringQuery:
+	case *parser.WildcardQuery, *parser.RegexpQuery, *parser.QueryStringQuery:
 		complexity = 30 // Expensive operations
 
 	case *parser.FuzzyQuery:
@@ -176,6 +176,21 @@ func (qp *QueryPlanner) analyzeComplexity(query parser.Query) int {
 	case *parser.MatchPhraseQuery:
 		complexity = 25
 
+	case *parser.NeuralSparseQuery:
+		complexity = 20 + len(q.QueryTokens)
+
+	case *parser.KNNQuery:
+		complexity = 30 // Graph walk per shard
+		if q.Filter != nil {
+			complexity += qp.analyzeComplexity(q.Filter)
+		}
+
+	case *parser.HybridQuery:
+		complexity = 10 // Fusion on the coordinator
+		for _, subQuery := range q.Queries {
+			complexity += qp.analyzeComplexity(subQuery)
+		}
+
 	case *parser.MultiMatchQuery:
 		complexity = 15 * len(q.Fields)
 
@@ -351,7 +366,7 @@ func (qp *QueryPlanner) AnalyzeQuery(query parser.Query) []*OptimizationHint {
 
 	// Use type switch to check for different query types
 	switch q := query.(type) {
-	case *parser.WildcardQuery, *parser.QueryStringQuery:
+	case *parser.WildcardQuery, *parser.RegexpQuery, *parser.QueryStringQuery:
 		hints = append(hints, &OptimizationHint{
 			Type:        "expensive_query",
 			Description: "Wildcard and regexp queries are expensive. Consider using prefix or term queries.",
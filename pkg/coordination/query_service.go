@@ -82,7 +82,7 @@ type QueryService struct {
 
 // queryExecutorInterface defines the methods needed from query executor
 type queryExecutorInterface interface {
-	ExecuteSearch(ctx context.Context, indexName string, query []byte, filterExpr []byte, from, size int) (*executor.SearchResult, error)
+	ExecuteSearch(ctx context.Context, indexName string, query []byte, filterExpr []byte, aggs []byte, sort []*executor.SortField, from, size int) (*executor.SearchResult, error)
 }
 
 // masterClientInterface defines the methods needed from master client
@@ -155,6 +155,7 @@ type SearchHit struct {
 	ID     string
 	Score  float64
 	Source map[string]interface{}
+	Sort   []interface{} // Sort values of a sorted search
 }
 
 // AggregationResult represents an aggregation result
@@ -171,13 +172,39 @@ type AggregationResult struct {
 	Max   float64
 	Avg   float64
 	Sum   float64
+
+	// For extended_stats aggregations
+	SumOfSquares            float64
+	Variance                float64
+	StdDeviation            float64
+	StdDeviationBoundsUpper float64
+	StdDeviationBoundsLower float64
+
+	// For percentiles aggregations
+	Values map[string]float64
+
+	// For terms aggregations
+	DocCountErrorUpperBound int64
+	SumOtherDocCount        int64
+
+	// For pipeline aggregations (Value is NaN if a pipeline has none)
+	NormalizedValue *float64
+	Keys            []string
+
+	// For composite aggregations: the key to request the next page after
+	AfterKey map[string]interface{}
 }
 
 // AggregationBucket represents a bucket in a bucket aggregation
 type AggregationBucket struct {
-	Key      interface{}
-	DocCount int64
-	SubAggs  map[string]*AggregationResult
+	Key         interface{}
+	KeyAsString string // For date_histogram buckets
+	DocCount    int64
+	SubAggs     map[string]*AggregationResult
+
+	// For range aggregations (nil if unbounded)
+	From *float64
+	To   *float64
 }
 
 // ShardInfo represents shard execution information
@@ -248,6 +275,21 @@ func (qs *QueryService) ExecuteSearch(ctx context.Context, indexName string, req
 		queryPlanningTime.WithLabelValues(indexName, "query_pipeline").Observe(time.Since(queryPipelineStart).Seconds())
 	}
 
+	// A hybrid query without its own fusion takes the one configured by the
+	// index's result pipeline
+	if hybrid, ok := searchReq.ParsedQuery.(*parser.HybridQuery); ok && hybrid.Fusion == nil && qs.pipelineRegistry != nil {
+		fusion, err := qs.resultPipelineFusion(indexName)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hybrid fusion in result pipeline: %w", err)
+		}
+		if fusion != nil {
+			if err := fusion.Validate(len(hybrid.Queries)); err != nil {
+				return nil, fmt.Errorf("invalid hybrid fusion in result pipeline: %w", err)
+			}
+			hybrid.Fusion = fusion
+		}
+	}
+
 	// Step 2: Get shard routing for this index
 	routing, err := qs.masterClient.GetShardRouting(ctx, indexName)
 	if err != nil {
@@ -438,6 +480,10 @@ func (qs *QueryService) convertToSearchResult(execResult *planner.ExecutionResul
 			hit.Score = score
 			delete(row, "_score")
 		}
+		if sortValues, ok := row["_sort"].([]interface{}); ok {
+			hit.Sort = sortValues
+			delete(row, "_sort")
+		}
 
 		// Copy remaining fields to source
 		for k, v := range row {
@@ -461,14 +507,26 @@ func (qs *QueryService) convertAggregation(agg *planner.AggregationResult) *Aggr
 		Type:    string(agg.Type),
 		Buckets: make([]*AggregationBucket, len(agg.Buckets)),
 		Value:   agg.Value,
+		Values:  agg.Values,
+
+		DocCountErrorUpperBound: agg.DocCountErrorUpperBound,
+		SumOtherDocCount:        agg.SumOtherDocCount,
+
+		NormalizedValue: agg.NormalizedValue,
+		Keys:            agg.Keys,
+
+		AfterKey: agg.AfterKey,
 	}
 
 	// Convert buckets
 	for i, bucket := range agg.Buckets {
 		result.Buckets[i] = &AggregationBucket{
-			Key:      bucket.Key,
-			DocCount: bucket.DocCount,
-			SubAggs:  make(map[string]*AggregationResult),
+			Key:         bucket.Key,
+			KeyAsString: bucket.KeyAsString,
+			DocCount:    bucket.DocCount,
+			SubAggs:     make(map[string]*AggregationResult),
+			From:        bucket.From,
+			To:          bucket.To,
 		}
 
 		// Convert sub-aggregations recursively
@@ -484,6 +542,11 @@ func (qs *QueryService) convertAggregation(agg *planner.AggregationResult) *Aggr
 		result.Max = agg.Stats.Max
 		result.Avg = agg.Stats.Avg
 		result.Sum = agg.Stats.Sum
+		result.SumOfSquares = agg.Stats.SumOfSquares
+		result.Variance = agg.Stats.Variance
+		result.StdDeviation = agg.Stats.StdDeviation
+		result.StdDeviationBoundsUpper = agg.Stats.StdDeviationBoundsUpper
+		result.StdDeviationBoundsLower = agg.Stats.StdDeviationBoundsLower
 	}
 
 	return result
@@ -574,6 +637,34 @@ func (qs *QueryService) executeResultPipeline(ctx context.Context, indexName str
 	return modifiedResult, nil
 }
 
+// resultPipelineFusion returns the hybrid fusion configured by a native
+// normalization-processor or score-ranker-processor stage of the index's result
+// pipeline, or nil if it has none. Fusion needs every sub-query's ranked hits,
+// so the stage is applied by the executor rather than run on the results.
+func (qs *QueryService) resultPipelineFusion(indexName string) (*parser.HybridFusion, error) {
+	pipe, err := qs.pipelineRegistry.GetPipelineForIndex(indexName, pipeline.PipelineTypeResult)
+	if err != nil {
+		// No pipeline configured - not an error
+		return nil, nil
+	}
+
+	for _, def := range qs.pipelineRegistry.List(pipeline.PipelineTypeResult) {
+		if def.Name != pipe.Name() || !def.Enabled {
+			continue
+		}
+		for _, stage := range def.Stages {
+			function, _ := stage.Config["function"].(string)
+			if !stage.Enabled || stage.Type != pipeline.StageTypeNative ||
+				(function != parser.NormalizationProcessor && function != parser.ScoreRankerProcessor) {
+				continue
+			}
+			parameters, _ := stage.Config["parameters"].(map[string]interface{})
+			return parser.ParseFusionProcessor(function, parameters)
+		}
+	}
+	return nil, nil
+}
+
 // searchRequestToMap converts SearchRequest to map for pipeline
 func (qs *QueryService) searchRequestToMap(req *parser.SearchRequest) (map[string]interface{}, error) {
 	// Marshal to JSON then unmarshal to map (simple conversion)
@@ -616,11 +707,15 @@ func (qs *QueryService) searchResultToMap(result *SearchResult) map[string]inter
 	// Convert hits
 	hits := make([]interface{}, len(result.Hits))
 	for i, hit := range result.Hits {
-		hits[i] = map[string]interface{}{
+		hitMap := map[string]interface{}{
 			"_id":     hit.ID,
 			"_score":  hit.Score,
 			"_source": hit.Source,
 		}
+		if hit.Sort != nil {
+			hitMap["sort"] = hit.Sort
+		}
+		hits[i] = hitMap
 	}
 
 	return map[string]interface{}{
@@ -682,6 +777,9 @@ func (qs *QueryService) mapToSearchResult(m map[string]interface{}) (*SearchResu
 			if source, ok := hitMap["_source"].(map[string]interface{}); ok {
 				hit.Source = source
 			}
+			if sortValues, ok := hitMap["sort"].([]interface{}); ok {
+				hit.Sort = sortValues
+			}
 
 			result.Hits = append(result.Hits, hit)
 		}
@@ -2,11 +2,14 @@ package coordination
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 	"time"
 
 	pb "github.com/conjugate/conjugate/pkg/common/proto"
 	"github.com/conjugate/conjugate/pkg/coordination/executor"
+	"github.com/conjugate/conjugate/pkg/coordination/parser"
+	"github.com/conjugate/conjugate/pkg/coordination/pipeline"
 	"github.com/conjugate/conjugate/pkg/coordination/planner"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -51,12 +54,14 @@ func (m *mockMasterClient) GetIndexMetadata(ctx context.Context, indexName strin
 
 // Mock query executor for testing
 type mockQueryExecutor struct {
-	searchFunc func(ctx context.Context, indexName string, query []byte, filterExpr []byte, from, size int) (*executor.SearchResult, error)
+	searchFunc func(ctx context.Context, indexName string, query []byte, filterExpr []byte, aggs []byte, from, size int) (*executor.SearchResult, error)
+	lastSort   []*executor.SortField // Sort of the last search
 }
 
-func (m *mockQueryExecutor) ExecuteSearch(ctx context.Context, indexName string, query []byte, filterExpr []byte, from, size int) (*executor.SearchResult, error) {
+func (m *mockQueryExecutor) ExecuteSearch(ctx context.Context, indexName string, query []byte, filterExpr []byte, aggs []byte, sort []*executor.SortField, from, size int) (*executor.SearchResult, error) {
+	m.lastSort = sort
 	if m.searchFunc != nil {
-		return m.searchFunc(ctx, indexName, query, filterExpr, from, size)
+		return m.searchFunc(ctx, indexName, query, filterExpr, aggs, from, size)
 	}
 	return &executor.SearchResult{
 		TotalHits:  0,
@@ -84,7 +89,7 @@ func TestExecuteSearchMatchAll(t *testing.T) {
 	logger := zap.NewNop()
 
 	mockExec := &mockQueryExecutor{
-		searchFunc: func(ctx context.Context, indexName string, query []byte, filterExpr []byte, from, size int) (*executor.SearchResult, error) {
+		searchFunc: func(ctx context.Context, indexName string, query []byte, filterExpr []byte, aggs []byte, from, size int) (*executor.SearchResult, error) {
 			return &executor.SearchResult{
 				TotalHits:  100,
 				MaxScore:   1.0,
@@ -116,7 +121,7 @@ func TestExecuteSearchTermQuery(t *testing.T) {
 	logger := zap.NewNop()
 
 	mockExec := &mockQueryExecutor{
-		searchFunc: func(ctx context.Context, indexName string, query []byte, filterExpr []byte, from, size int) (*executor.SearchResult, error) {
+		searchFunc: func(ctx context.Context, indexName string, query []byte, filterExpr []byte, aggs []byte, from, size int) (*executor.SearchResult, error) {
 			return &executor.SearchResult{
 				TotalHits:  10,
 				MaxScore:   2.5,
@@ -146,8 +151,12 @@ func TestExecuteSearchTermQuery(t *testing.T) {
 func TestExecuteSearchWithAggregations(t *testing.T) {
 	logger := zap.NewNop()
 
+	var shardAggs map[string]interface{}
 	mockExec := &mockQueryExecutor{
-		searchFunc: func(ctx context.Context, indexName string, query []byte, filterExpr []byte, from, size int) (*executor.SearchResult, error) {
+		searchFunc: func(ctx context.Context, indexName string, query []byte, filterExpr []byte, aggs []byte, from, size int) (*executor.SearchResult, error) {
+			if err := json.Unmarshal(aggs, &shardAggs); err != nil {
+				return nil, err
+			}
 			return &executor.SearchResult{
 				TotalHits:  100,
 				MaxScore:   1.0,
@@ -189,6 +198,12 @@ func TestExecuteSearchWithAggregations(t *testing.T) {
 	assert.Equal(t, int64(100), result.TotalHits)
 	assert.Len(t, result.Aggregations, 2)
 
+	// The aggs section is sent to the shards as-is
+	assert.Equal(t, map[string]interface{}{
+		"categories": map[string]interface{}{"terms": map[string]interface{}{"field": "category"}},
+		"avg_price":  map[string]interface{}{"avg": map[string]interface{}{"field": "price"}},
+	}, shardAggs)
+
 	// Check terms aggregation
 	termsAgg, ok := result.Aggregations["categories"]
 	require.True(t, ok)
@@ -204,6 +219,106 @@ func TestExecuteSearchWithAggregations(t *testing.T) {
 	assert.Equal(t, 45.5, avgAgg.Value)
 }
 
+func TestExecuteSearchSorted(t *testing.T) {
+	logger := zap.NewNop()
+
+	mockExec := &mockQueryExecutor{
+		searchFunc: func(ctx context.Context, indexName string, query []byte, filterExpr []byte, aggs []byte, from, size int) (*executor.SearchResult, error) {
+			return &executor.SearchResult{
+				TotalHits: 2,
+				Hits: []*executor.SearchHit{
+					{ID: "b", Score: 1, Source: map[string]interface{}{"level": "warn"}, Sort: []interface{}{1704153600000.0, "warn"}},
+					{ID: "a", Score: 2, Source: map[string]interface{}{"level": "error"}, Sort: []interface{}{1704067200000.0, "error"}},
+				},
+			}, nil
+		},
+	}
+
+	service := NewQueryService(mockExec, &mockMasterClient{}, logger)
+
+	queryJSON := `{
+		"query": {"match_all": {}},
+		"sort": [{"@timestamp": "desc"}, {"level": {"order": "asc", "missing": "_first"}}],
+		"size": 2
+	}`
+
+	result, err := service.ExecuteSearch(context.Background(), "logs", []byte(queryJSON))
+	require.NoError(t, err)
+
+	// The sort is sent to the shards and their order and sort values kept
+	assert.Equal(t, []*executor.SortField{
+		{Field: "@timestamp", Descending: true},
+		{Field: "level", Missing: "_first"},
+	}, mockExec.lastSort)
+	require.Len(t, result.Hits, 2)
+	assert.Equal(t, "b", result.Hits[0].ID)
+	assert.Equal(t, []interface{}{1704153600000.0, "warn"}, result.Hits[0].Sort)
+	assert.NotContains(t, result.Hits[0].Source, "_sort")
+}
+
+func TestExecuteSearchHybridResultPipelineFusion(t *testing.T) {
+	logger := zap.NewNop()
+
+	var sentQuery []byte
+	mockExec := &mockQueryExecutor{
+		searchFunc: func(ctx context.Context, indexName string, query []byte, filterExpr []byte, aggs []byte, from, size int) (*executor.SearchResult, error) {
+			sentQuery = query
+			return &executor.SearchResult{Hits: []*executor.SearchHit{}}, nil
+		},
+	}
+	service := NewQueryService(mockExec, &mockMasterClient{}, logger)
+
+	registry := pipeline.NewRegistry(logger)
+	require.NoError(t, registry.Register(&pipeline.PipelineDefinition{
+		Name:    "hybrid-fusion",
+		Version: "1.0.0",
+		Type:    pipeline.PipelineTypeResult,
+		Stages: []pipeline.StageDefinition{{
+			Name:    "fusion",
+			Type:    pipeline.StageTypeNative,
+			Enabled: true,
+			Config: map[string]interface{}{
+				"function": parser.NormalizationProcessor,
+				"parameters": map[string]interface{}{
+					"normalization": map[string]interface{}{"technique": "l2"},
+					"combination": map[string]interface{}{
+						"technique":  "arithmetic_mean",
+						"parameters": map[string]interface{}{"weights": []interface{}{0.3, 0.7}},
+					},
+				},
+			},
+		}},
+		Enabled: true,
+	}))
+	require.NoError(t, registry.AssociatePipeline("products", pipeline.PipelineTypeResult, "hybrid-fusion"))
+	service.SetPipelineComponents(registry, pipeline.NewExecutor(registry, logger))
+
+	hybrid := func(fusion string) string {
+		return `{"query": {"hybrid": {"queries": [
+			{"match": {"title": "laptop"}},
+			{"term": {"brand": "acme"}}
+		]}}` + fusion + `}`
+	}
+
+	// The result pipeline configures the fusion of a hybrid query
+	_, err := service.ExecuteSearch(context.Background(), "products", []byte(hybrid("")))
+	require.NoError(t, err)
+	var sent map[string]map[string]interface{}
+	require.NoError(t, json.Unmarshal(sentQuery, &sent))
+	assert.Equal(t, map[string]interface{}{
+		"normalization": "l2",
+		"combination":   "arithmetic_mean",
+		"weights":       []interface{}{0.3, 0.7},
+	}, sent["hybrid"]["fusion"])
+
+	// A search pipeline in the request takes precedence
+	_, err = service.ExecuteSearch(context.Background(), "products", []byte(hybrid(`,
+		"search_pipeline": {"phase_results_processors": [{"score-ranker-processor": {"combination": {"technique": "rrf"}}}]}`)))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(sentQuery, &sent))
+	assert.Equal(t, map[string]interface{}{"combination": "rrf", "rank_constant": 60.0}, sent["hybrid"]["fusion"])
+}
+
 func TestExecuteSearchInvalidQuery(t *testing.T) {
 	logger := zap.NewNop()
 	mockExec := &mockQueryExecutor{}
@@ -221,7 +336,7 @@ func TestExecuteSearchMultipleShards(t *testing.T) {
 	logger := zap.NewNop()
 
 	mockExec := &mockQueryExecutor{
-		searchFunc: func(ctx context.Context, indexName string, query []byte, filterExpr []byte, from, size int) (*executor.SearchResult, error) {
+		searchFunc: func(ctx context.Context, indexName string, query []byte, filterExpr []byte, aggs []byte, from, size int) (*executor.SearchResult, error) {
 			return &executor.SearchResult{
 				TotalHits:  1000,
 				MaxScore:   3.0,
@@ -11,9 +11,9 @@ import (
 
 // DataNodeClient interface for communication with data nodes
 type DataNodeClient interface {
-	IndexDocument(ctx context.Context, indexName string, shardID int32, docID string, document map[string]interface{}) (*pb.IndexDocumentResponse, error)
+	IndexDocument(ctx context.Context, indexName string, shardID int32, docID string, document map[string]interface{}, refresh, waitForActiveShards string) (*pb.IndexDocumentResponse, error)
 	GetDocument(ctx context.Context, indexName string, shardID int32, docID string) (*pb.GetDocumentResponse, error)
-	DeleteDocument(ctx context.Context, indexName string, shardID int32, docID string) (*pb.DeleteDocumentResponse, error)
+	DeleteDocument(ctx context.Context, indexName string, shardID int32, docID string, refresh, waitForActiveShards string) (*pb.DeleteDocumentResponse, error)
 	IsConnected() bool
 	Connect(ctx context.Context) error
 	NodeID() string
@@ -41,8 +41,10 @@ func NewDocumentRouter(masterClient MasterClient, dataClients map[string]DataNod
 	}
 }
 
-// RouteIndexDocument routes an index document operation to the correct shard
-func (dr *DocumentRouter) RouteIndexDocument(ctx context.Context, indexName, docID string, document map[string]interface{}) (*pb.IndexDocumentResponse, error) {
+// RouteIndexDocument routes an index document operation to the correct shard.
+// refresh is passed through to the data node ("true", "wait_for" or ""), as
+// is waitForActiveShards ("all", a number of copies, or "" for the primary).
+func (dr *DocumentRouter) RouteIndexDocument(ctx context.Context, indexName, docID string, document map[string]interface{}, refresh, waitForActiveShards string) (*pb.IndexDocumentResponse, error) {
 	// Get index metadata to determine number of shards
 	metadata, err := dr.masterClient.GetIndexMetadata(ctx, indexName)
 	if err != nil {
@@ -70,7 +72,7 @@ func (dr *DocumentRouter) RouteIndexDocument(ctx context.Context, indexName, doc
 
 	// Find primary shard for writes
 	if shard.Allocation == nil || shard.Allocation.State != pb.ShardAllocation_SHARD_STATE_STARTED {
-		return nil, fmt.Errorf("shard %d is not available (state: %v)", shardID, shard.Allocation.State)
+		return nil, fmt.Errorf("shard %d is not available (state: %v)", shardID, shard.Allocation.GetState())
 	}
 
 	// Only write to primary shard
@@ -103,7 +105,7 @@ func (dr *DocumentRouter) RouteIndexDocument(ctx context.Context, indexName, doc
 		zap.Int32("shard_id", shardID),
 		zap.String("node_id", nodeID))
 
-	resp, err := client.IndexDocument(ctx, indexName, shardID, docID, document)
+	resp, err := client.IndexDocument(ctx, indexName, shardID, docID, document, refresh, waitForActiveShards)
 	if err != nil {
 		dr.logger.Error("IndexDocument call failed", zap.Error(err))
 		return nil, err
@@ -177,7 +179,7 @@ func (dr *DocumentRouter) RouteGetDocument(ctx context.Context, indexName, docID
 }
 
 // RouteDeleteDocument routes a delete document operation to the correct shard
-func (dr *DocumentRouter) RouteDeleteDocument(ctx context.Context, indexName, docID string) (*pb.DeleteDocumentResponse, error) {
+func (dr *DocumentRouter) RouteDeleteDocument(ctx context.Context, indexName, docID string, refresh, waitForActiveShards string) (*pb.DeleteDocumentResponse, error) {
 	// Get index metadata to determine number of shards
 	metadata, err := dr.masterClient.GetIndexMetadata(ctx, indexName)
 	if err != nil {
@@ -237,7 +239,7 @@ func (dr *DocumentRouter) RouteDeleteDocument(ctx context.Context, indexName, do
 		zap.Int32("shard_id", shardID),
 		zap.String("node_id", nodeID))
 
-	return client.DeleteDocument(ctx, indexName, shardID, docID)
+	return client.DeleteDocument(ctx, indexName, shardID, docID, refresh, waitForActiveShards)
 }
 
 // calculateShardID uses consistent hashing to determine which shard a document belongs to
@@ -0,0 +1,84 @@
+// Copyright 2026 CONJUGATE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+
+package coordination
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pb "github.com/conjugate/conjugate/pkg/common/proto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func writeParamsContext(query string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPut, "/products/_doc/1?"+query, nil)
+	return ctx
+}
+
+func TestWriteParams(t *testing.T) {
+	tests := []struct {
+		query               string
+		refresh             string
+		waitForActiveShards string
+		wantErr             bool
+	}{
+		{"", "", "", false},
+		{"refresh=wait_for&wait_for_active_shards=all", "wait_for", "all", false},
+		{"wait_for_active_shards=2", "", "2", false},
+		{"wait_for_active_shards=0", "", "0", false},
+		{"wait_for_active_shards=-1", "", "", true},
+		{"wait_for_active_shards=most", "", "", true},
+		{"refresh=later&wait_for_active_shards=1", "", "", true},
+	}
+
+	for _, tt := range tests {
+		refresh, waitForActiveShards, err := writeParams(writeParamsContext(tt.query))
+		if tt.wantErr {
+			assert.Error(t, err, tt.query)
+			continue
+		}
+		require.NoError(t, err, tt.query)
+		assert.Equal(t, tt.refresh, refresh, tt.query)
+		assert.Equal(t, tt.waitForActiveShards, waitForActiveShards, tt.query)
+	}
+}
+
+func TestWriteFailure(t *testing.T) {
+	// Data node statuses survive the client's error wrapping
+	err := fmt.Errorf("index document failed: %w", status.Error(codes.Unavailable, "not enough active shard copies"))
+	code, errorType := writeFailure(err, "index_failed_exception")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "unavailable_shards_exception", errorType)
+
+	code, errorType = writeFailure(status.Error(codes.InvalidArgument, "invalid wait_for_active_shards"), "index_failed_exception")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "illegal_argument_exception", errorType)
+
+	code, errorType = writeFailure(fmt.Errorf("connection refused"), "delete_failed_exception")
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Equal(t, "delete_failed_exception", errorType)
+}
+
+func TestAddWriteResult(t *testing.T) {
+	body := gin.H{}
+	addWriteResult(body, 7, 2, &pb.WriteShardsInfo{Total: 2, Successful: 1, Failed: 1})
+	assert.Equal(t, int64(7), body["_seq_no"])
+	assert.Equal(t, int64(2), body["_primary_term"])
+	assert.Equal(t, gin.H{"total": int32(2), "successful": int32(1), "failed": int32(1)}, body["_shards"])
+
+	// A delete of a missing document was not assigned a sequence number
+	body = gin.H{}
+	addWriteResult(body, 0, 0, nil)
+	assert.Empty(t, body)
+}
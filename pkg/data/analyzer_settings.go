@@ -143,3 +143,21 @@ func AnalyzeField(cache *AnalyzerCache, settings *AnalyzerSettings, fieldName, f
 
 	return tokens, nil
 }
+
+// AnalyzeFieldTokens analyzes a field value like AnalyzeField, keeping the
+// position and offsets of each token.
+func AnalyzeFieldTokens(cache *AnalyzerCache, settings *AnalyzerSettings, fieldName, fieldValue string) ([]diagon.Token, error) {
+	analyzerName := settings.GetAnalyzerForField(fieldName)
+
+	analyzer, err := cache.GetOrCreate(analyzerName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get analyzer %s: %w", analyzerName, err)
+	}
+
+	tokens, err := analyzer.Analyze(fieldValue)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze field %s: %w", fieldName, err)
+	}
+
+	return tokens, nil
+}
@@ -244,3 +244,38 @@ func TestBatchIndexing_BackgroundWorkers(t *testing.T) {
 		assert.False(t, stats["needs_refresh"].(bool))
 	})
 }
+
+func TestParseBatchSettings(t *testing.T) {
+	batch, err := ParseBatchSettings(nil)
+	require.NoError(t, err)
+	assert.Equal(t, BatchSettings{CommitBatchSize: 1000, CommitInterval: time.Second, RefreshInterval: time.Second}, batch)
+
+	batch, err = ParseBatchSettings(map[string]string{
+		"refresh_interval":  "30s",
+		"commit_interval":   "500ms",
+		"commit_batch_size": "5000",
+		"mappings":          "{}",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, BatchSettings{CommitBatchSize: 5000, CommitInterval: 500 * time.Millisecond, RefreshInterval: 30 * time.Second}, batch)
+
+	// OpenSearch time units, and -1 to disable refreshes
+	batch, err = ParseBatchSettings(map[string]string{
+		"refresh_interval": "-1",
+		"commit_interval":  "1d",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, refreshDisabled, batch.RefreshInterval)
+	assert.Equal(t, 24*time.Hour, batch.CommitInterval)
+
+	for _, settings := range []map[string]string{
+		{"refresh_interval": "soon"},
+		{"refresh_interval": "-2"},
+		{"commit_interval": "-1"},
+		{"commit_interval": "0s"},
+		{"commit_batch_size": "0"},
+	} {
+		_, err := ParseBatchSettings(settings)
+		assert.Error(t, err, "settings %v", settings)
+	}
+}
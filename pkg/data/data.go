@@ -25,6 +25,7 @@ type DataNode struct {
 	udfRegistry  *wasm.UDFRegistry
 	shards       *ShardManager
 	masterClient *MasterClient
+	replicator   *Replicator
 	mu           sync.RWMutex
 }
 
@@ -87,6 +88,7 @@ func NewDataNode(cfg *config.DataNodeConfig, logger *zap.Logger) (*DataNode, err
 		udfRegistry:  udfRegistry,
 		shards:       shardManager,
 		masterClient: masterClient,
+		replicator:   NewReplicator(cfg.NodeID, masterClient, shardManager, logger),
 	}
 
 	// Register gRPC service
@@ -129,9 +131,13 @@ func (d *DataNode) Start(ctx context.Context) error {
 	// Register with master node
 	go d.registerWithMaster(ctx)
 
-	// Start heartbeat (using master client)
+	// Start heartbeat (using master client), reporting stats and hosted shards
+	d.masterClient.SetHeartbeatSource(d.heartbeatData)
 	d.masterClient.StartHeartbeat(ctx, 10*time.Second)
 
+	// Follow the master's routing to replicate the writes of local primaries
+	d.replicator.Start(defaultRoutingRefreshInterval)
+
 	return nil
 }
 
@@ -142,6 +148,9 @@ func (d *DataNode) Stop(ctx context.Context) error {
 	// Stop heartbeat
 	d.masterClient.StopHeartbeat()
 
+	// Stop following the routing
+	d.replicator.Stop()
+
 	// Unregister from master
 	if err := d.masterClient.Unregister(ctx); err != nil {
 		d.logger.Warn("Failed to unregister from master", zap.Error(err))
@@ -193,7 +202,8 @@ func (d *DataNode) registerWithMaster(ctx context.Context) {
 			Version:     "1.0.0", // TODO: Get from build
 		}
 
-		if err := d.masterClient.Register(ctx, d.cfg.BindAddr, int32(d.cfg.GRPCPort), attributes); err != nil {
+		// Report shards recovered from disk so the master can mark them started
+		if err := d.masterClient.Register(ctx, d.cfg.BindAddr, int32(d.cfg.GRPCPort), attributes, d.shardReports()); err != nil {
 			d.logger.Error("Failed to register with master", zap.Error(err))
 			// Continue trying in heartbeat loop
 			return
@@ -259,6 +269,51 @@ func (d *DataNode) collectStats() *NodeStats {
 	return stats
 }
 
+// heartbeatData returns the node stats and shard reports sent with each heartbeat
+func (d *DataNode) heartbeatData() (*pb.NodeStats, []*pb.ShardReport) {
+	stats := d.collectStats()
+
+	return &pb.NodeStats{
+		TotalShards:    int64(stats.ActiveShards),
+		DocsCount:      stats.DocsCount,
+		StoreSizeBytes: stats.StoreSizeBytes,
+	}, d.shardReports()
+}
+
+// shardReports describes every shard hosted by this node for the master
+func (d *DataNode) shardReports() []*pb.ShardReport {
+	shards := d.shards.List()
+	reports := make([]*pb.ShardReport, 0, len(shards))
+
+	for _, shard := range shards {
+		stats := shard.Stats()
+		reports = append(reports, &pb.ShardReport{
+			IndexName: stats.IndexName,
+			ShardId:   stats.ShardID,
+			IsPrimary: stats.IsPrimary,
+			State:     convertShardStateToProto(stats.State),
+			DocsCount: stats.DocsCount,
+			SizeBytes: stats.SizeBytes,
+		})
+	}
+
+	return reports
+}
+
+// convertShardStateToProto converts a local shard state to the proto enum
+func convertShardStateToProto(state ShardState) pb.ShardAllocation_ShardState {
+	switch state {
+	case ShardStateInitializing:
+		return pb.ShardAllocation_SHARD_STATE_INITIALIZING
+	case ShardStateStarted:
+		return pb.ShardAllocation_SHARD_STATE_STARTED
+	case ShardStateRelocating:
+		return pb.ShardAllocation_SHARD_STATE_RELOCATING
+	default:
+		return pb.ShardAllocation_SHARD_STATE_UNKNOWN
+	}
+}
+
 // CreateShard creates a new shard on this node
 func (d *DataNode) CreateShard(ctx context.Context, indexName string, shardID int32, isPrimary bool) error {
 	d.logger.Info("Creating shard",
@@ -0,0 +1,1179 @@
+package diagon
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/conjugate/conjugate/pkg/common/aggparams"
+	"github.com/conjugate/conjugate/pkg/common/dates"
+	"github.com/conjugate/conjugate/pkg/common/sketch"
+)
+
+// maxBuckets caps the buckets a single aggregation may create, like
+// OpenSearch's search.max_buckets
+const maxBuckets = 65535
+
+// defaultPercents are the percentiles computed when a percentiles
+// aggregation does not list any
+var defaultPercents = []float64{1, 5, 25, 50, 75, 95, 99}
+
+// aggSpec is one parsed entry of the aggs section of a search request
+type aggSpec struct {
+	name    string
+	aggType string
+	field   string
+	body    map[string]interface{}
+	subAggs []*aggSpec // evaluated over the docs of each bucket
+
+	sources []compositeSource // of a composite aggregation
+}
+
+// aggDoc holds the stored values the aggregations read from one matching
+// document, keyed by field name
+type aggDoc struct {
+	id     int
+	fields map[string][]string
+}
+
+// aggContext is the doc set the aggregations are evaluated over
+type aggContext struct {
+	docs []aggDoc
+
+	// matchQuery returns the internal IDs of the live documents matching a
+	// query DSL object. Filters aggregations intersect it with their docs.
+	matchQuery func(query map[string]interface{}) (map[int]struct{}, error)
+
+	// fieldTerms returns the stored values of a field across all live
+	// documents, for terms aggregations that list terms without matches
+	fieldTerms func(field string) ([]string, error)
+
+	// matches caches matchQuery by serialized query, since a filters
+	// aggregation nested under a bucket aggregation runs once per bucket
+	matches map[string]map[int]struct{}
+}
+
+// match returns the IDs of the documents matching query, running each
+// distinct query only once
+func (c *aggContext) match(query map[string]interface{}) (map[int]struct{}, error) {
+	if c.matchQuery == nil {
+		return nil, fmt.Errorf("filter queries are not supported here")
+	}
+	encoded, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+	if ids, ok := c.matches[string(encoded)]; ok {
+		return ids, nil
+	}
+
+	ids, err := c.matchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if c.matches == nil {
+		c.matches = make(map[string]map[int]struct{})
+	}
+	c.matches[string(encoded)] = ids
+	return ids, nil
+}
+
+// parseAggregations parses the aggs section of a search request
+func parseAggregations(aggs []byte) ([]*aggSpec, error) {
+	var defs map[string]interface{}
+	if err := json.Unmarshal(aggs, &defs); err != nil {
+		return nil, fmt.Errorf("failed to parse aggregations: %w", err)
+	}
+	return parseAggregationDefs(defs)
+}
+
+// parseAggregationDefs parses a map of named aggregation definitions
+func parseAggregationDefs(defs map[string]interface{}) ([]*aggSpec, error) {
+	specs := make([]*aggSpec, 0, len(defs))
+	for name, def := range defs {
+		spec, err := parseAggregation(name, def)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+
+	sort.Slice(specs, func(i, j int) bool {
+		return specs[i].name < specs[j].name
+	})
+	return specs, nil
+}
+
+// parseAggregation parses a single named aggregation definition
+func parseAggregation(name string, def interface{}) (*aggSpec, error) {
+	defMap, ok := def.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("aggregation [%s] must be an object", name)
+	}
+
+	var spec *aggSpec
+	var subDefs map[string]interface{}
+	for key, body := range defMap {
+		switch key {
+		case "aggs", "aggregations":
+			if subDefs, ok = body.(map[string]interface{}); !ok {
+				return nil, fmt.Errorf("sub-aggregations of aggregation [%s] must be an object", name)
+			}
+			continue
+		case "meta":
+			continue
+		}
+		if spec != nil {
+			return nil, fmt.Errorf("aggregation [%s] defines more than one type: [%s] and [%s]",
+				name, spec.aggType, key)
+		}
+
+		bodyMap, ok := body.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("body of aggregation [%s] must be an object", name)
+		}
+		spec = &aggSpec{name: name, aggType: key, body: bodyMap}
+		spec.field, _ = bodyMap["field"].(string)
+	}
+	if spec == nil {
+		return nil, fmt.Errorf("aggregation [%s] has no type", name)
+	}
+
+	bucketing := false
+	switch spec.aggType {
+	case "terms", "histogram", "date_histogram", "range":
+		bucketing = true
+		if spec.field == "" {
+			return nil, fmt.Errorf("aggregation [%s] of type [%s] requires a field", name, spec.aggType)
+		}
+	case "filters":
+		bucketing = true
+	case "composite":
+		bucketing = true
+		sources, err := parseCompositeSources(name, spec.body)
+		if err != nil {
+			return nil, err
+		}
+		spec.sources = sources
+	case "stats", "extended_stats", "avg", "sum", "min", "max",
+		"value_count", "cardinality", "percentiles", "percentile_ranks":
+		if spec.field == "" {
+			return nil, fmt.Errorf("aggregation [%s] of type [%s] requires a field", name, spec.aggType)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported aggregation type [%s] for aggregation [%s]", spec.aggType, name)
+	}
+
+	if len(subDefs) > 0 {
+		if !bucketing {
+			return nil, fmt.Errorf("aggregation [%s] of type [%s] cannot accept sub-aggregations", name, spec.aggType)
+		}
+		subAggs, err := parseAggregationDefs(subDefs)
+		if err != nil {
+			return nil, err
+		}
+		for _, sub := range subAggs {
+			if sub.aggType == "composite" {
+				return nil, fmt.Errorf("composite aggregation [%s] cannot be a sub-aggregation of [%s]", sub.name, name)
+			}
+		}
+		spec.subAggs = subAggs
+	}
+
+	return spec, nil
+}
+
+// aggregationFields returns the stored fields the aggregations and all of
+// their sub-aggregations read
+func aggregationFields(specs []*aggSpec) []string {
+	seen := make(map[string]struct{})
+	var fields []string
+	var collect func(specs []*aggSpec)
+	collect = func(specs []*aggSpec) {
+		for _, spec := range specs {
+			collect(spec.subAggs)
+			specFields := []string{spec.field}
+			for _, source := range spec.sources {
+				specFields = append(specFields, source.field)
+			}
+			for _, field := range specFields {
+				if field == "" {
+					continue
+				}
+				if _, ok := seen[field]; ok {
+					continue
+				}
+				seen[field] = struct{}{}
+				fields = append(fields, field)
+			}
+		}
+	}
+	collect(specs)
+	return fields
+}
+
+// evaluateAggregations evaluates every aggregation over the context's docs
+func evaluateAggregations(specs []*aggSpec, ctx *aggContext) (map[string]AggregationResult, error) {
+	return evaluateOver(specs, ctx, ctx.docs)
+}
+
+// evaluateOver evaluates every aggregation over docs
+func evaluateOver(specs []*aggSpec, ctx *aggContext, docs []aggDoc) (map[string]AggregationResult, error) {
+	results := make(map[string]AggregationResult, len(specs))
+	for _, spec := range specs {
+		result, err := spec.evaluate(ctx, docs)
+		if err != nil {
+			return nil, err
+		}
+		results[spec.name] = result
+	}
+	return results, nil
+}
+
+// newBucket builds a bucket and evaluates the sub-aggregations over its docs
+func (a *aggSpec) newBucket(ctx *aggContext, key interface{}, docs []aggDoc) (map[string]interface{}, error) {
+	bucket := map[string]interface{}{
+		"key":       key,
+		"doc_count": int64(len(docs)),
+	}
+	if len(a.subAggs) > 0 {
+		subResults, err := evaluateOver(a.subAggs, ctx, docs)
+		if err != nil {
+			return nil, err
+		}
+		bucket["aggregations"] = subResults
+	}
+	return bucket, nil
+}
+
+// evaluate computes the aggregation over docs
+func (a *aggSpec) evaluate(ctx *aggContext, docs []aggDoc) (AggregationResult, error) {
+	switch a.aggType {
+	case "terms":
+		return a.terms(ctx, docs)
+	case "histogram":
+		return a.histogram(ctx, docs)
+	case "date_histogram":
+		return a.dateHistogram(ctx, docs)
+	case "range":
+		return a.rangeBuckets(ctx, docs)
+	case "filters":
+		return a.filters(ctx, docs)
+	case "composite":
+		return a.composite(ctx, docs)
+	case "stats", "extended_stats", "avg", "sum", "min", "max":
+		return a.stats(docs), nil
+	case "value_count":
+		return a.valueCount(docs), nil
+	case "cardinality":
+		return a.cardinality(docs)
+	case "percentiles", "percentile_ranks":
+		return a.percentiles(docs)
+	default:
+		return AggregationResult{}, fmt.Errorf("unsupported aggregation type [%s] for aggregation [%s]", a.aggType, a.name)
+	}
+}
+
+// terms buckets docs by distinct field value. A shard returns its top
+// shard_size buckets in the requested order, with the count of documents in
+// the terms it left out and a bound on how much the cut may understate a
+// term's count; the coordinator sums the buckets of all shards and applies
+// size and min_doc_count.
+func (a *aggSpec) terms(ctx *aggContext, docs []aggDoc) (AggregationResult, error) {
+	size := intParam(a.body, "size", aggparams.DefaultTermsSize)
+	if size <= 0 {
+		return AggregationResult{}, fmt.Errorf("[size] of terms aggregation [%s] must be greater than 0", a.name)
+	}
+	shardSize := intParam(a.body, "shard_size", defaultShardSize(size))
+	if shardSize < size {
+		shardSize = size
+	}
+	minDocCount := intParam(a.body, "min_doc_count", 1)
+	shardMinDocCount := intParam(a.body, "shard_min_doc_count", 0)
+	if minDocCount < 0 || shardMinDocCount < 0 {
+		return AggregationResult{}, fmt.Errorf("min_doc_count of terms aggregation [%s] must not be negative", a.name)
+	}
+	order, err := a.termsOrder()
+	if err != nil {
+		return AggregationResult{}, err
+	}
+	filter, err := a.termsFilter()
+	if err != nil {
+		return AggregationResult{}, err
+	}
+	missing, hasMissing := missingKey(a.body)
+
+	bucketDocs := make(map[string][]aggDoc)
+	for _, doc := range docs {
+		values := doc.fields[a.field]
+		if len(values) == 0 && hasMissing {
+			values = []string{missing}
+		}
+		seen := make(map[string]struct{})
+		for _, value := range values {
+			key := termKey(value)
+			if _, ok := seen[key]; ok || !filter.accepts(key) {
+				continue
+			}
+			seen[key] = struct{}{}
+			bucketDocs[key] = append(bucketDocs[key], doc)
+		}
+	}
+	if minDocCount == 0 {
+		// Terms of the field that no doc here holds get empty buckets
+		if ctx.fieldTerms == nil {
+			return AggregationResult{}, fmt.Errorf("min_doc_count 0 is not supported here")
+		}
+		values, err := ctx.fieldTerms(a.field)
+		if err != nil {
+			return AggregationResult{}, err
+		}
+		for _, value := range values {
+			key := termKey(value)
+			if _, ok := bucketDocs[key]; !ok && filter.accepts(key) {
+				bucketDocs[key] = nil
+			}
+		}
+	}
+
+	// Buckets are ranked by count and key alone unless the order needs
+	// their sub-aggregations; otherwise these are evaluated after the cut
+	bySubAggregation := order.BySubAggregation()
+	buckets := make([]map[string]interface{}, 0, len(bucketDocs))
+	for key, keyDocs := range bucketDocs {
+		if len(keyDocs) < shardMinDocCount {
+			continue
+		}
+		bucket := map[string]interface{}{"key": key, "doc_count": int64(len(keyDocs))}
+		if bySubAggregation {
+			if bucket, err = a.newBucket(ctx, key, keyDocs); err != nil {
+				return AggregationResult{}, err
+			}
+		}
+		buckets = append(buckets, bucket)
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return lessBucket(order, buckets[i], buckets[j])
+	})
+
+	result := AggregationResult{Type: a.aggType}
+	if len(buckets) > shardSize {
+		for _, bucket := range buckets[shardSize:] {
+			result.SumOtherDocCount += bucket["doc_count"].(int64)
+		}
+		buckets = buckets[:shardSize]
+		result.DocCountErrorUpperBound = shardError(order, buckets[len(buckets)-1]["doc_count"].(int64))
+	}
+	if !bySubAggregation {
+		for i, bucket := range buckets {
+			key := bucket["key"].(string)
+			if buckets[i], err = a.newBucket(ctx, key, bucketDocs[key]); err != nil {
+				return AggregationResult{}, err
+			}
+		}
+	}
+	result.Buckets = buckets
+	return result, nil
+}
+
+// defaultShardSize is the number of buckets a shard returns for a terms
+// aggregation of the given size unless the request sets shard_size. Shards
+// over-fetch so that terms just below the cut on some shards still reach the
+// coordinator, as in OpenSearch.
+func defaultShardSize(size int) int {
+	return size + size/2 + 10
+}
+
+// termsOrder parses the order of a terms aggregation and resolves its
+// metric paths against the sub-aggregations
+func (a *aggSpec) termsOrder() (aggparams.TermsOrder, error) {
+	order, err := aggparams.ParseTermsOrder(a.body["order"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid order of terms aggregation [%s]: %w", a.name, err)
+	}
+	for i, key := range order {
+		if key.Path == "_count" || key.Path == "_key" {
+			continue
+		}
+		if order[i].Metric, err = a.orderMetric(key.Path, key.Metric); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// orderMetric resolves the metric of an order path's sub-aggregation to the
+// metric of its result to order by
+func (a *aggSpec) orderMetric(name, metric string) (string, error) {
+	path := name
+	if metric != "" {
+		path += "." + metric
+	}
+	for _, sub := range a.subAggs {
+		if sub.name != name {
+			continue
+		}
+		if resolved, ok := orderableMetric(sub.aggType, metric); ok {
+			return resolved, nil
+		}
+		return "", fmt.Errorf("invalid order path [%s] of terms aggregation [%s]: [%s] is not a metric of a %s aggregation",
+			path, a.name, metric, sub.aggType)
+	}
+	return "", fmt.Errorf("invalid order path [%s] of terms aggregation [%s]: no sub-aggregation [%s]", path, a.name, name)
+}
+
+// orderableMetric returns the metric of an aggregation type's result an order
+// path selects. Single-value metrics need no metric name.
+func orderableMetric(aggType, metric string) (string, bool) {
+	switch aggType {
+	case "avg", "sum", "min", "max":
+		if metric == "" || metric == "value" {
+			return aggType, true
+		}
+	case "value_count":
+		if metric == "" || metric == "value" {
+			return "count", true
+		}
+	case "cardinality":
+		if metric == "" || metric == "value" {
+			return "value", true
+		}
+	case "stats", "extended_stats":
+		switch metric {
+		case "count", "min", "max", "avg", "sum":
+			return metric, true
+		case "sum_of_squares", "variance", "std_deviation":
+			return metric, aggType == "extended_stats"
+		}
+	case "percentiles", "percentile_ranks":
+		if point, err := strconv.ParseFloat(metric, 64); err == nil {
+			return formatDouble(point), true
+		}
+	}
+	return "", false
+}
+
+// metricValue returns the metric of a sub-aggregation result an order path
+// selects, or NaN if it has no value
+func metricValue(result AggregationResult, metric string) float64 {
+	switch result.Type {
+	case "percentiles", "percentile_ranks":
+		if value, ok := result.Values[metric]; ok {
+			return value
+		}
+		return math.NaN()
+	}
+	switch metric {
+	case "count":
+		return float64(result.Count)
+	case "value":
+		return float64(result.Value)
+	case "sum":
+		return result.Sum
+	}
+	if result.Count == 0 {
+		return math.NaN()
+	}
+	switch metric {
+	case "min":
+		return result.Min
+	case "max":
+		return result.Max
+	case "avg":
+		return result.Avg
+	case "sum_of_squares":
+		return result.SumOfSquares
+	case "variance":
+		return result.Variance
+	case "std_deviation":
+		return result.StdDeviation
+	}
+	return math.NaN()
+}
+
+// lessBucket reports whether bucket a sorts before bucket b in the order.
+// Metrics without a value sort last in either direction.
+func lessBucket(order aggparams.TermsOrder, a, b map[string]interface{}) bool {
+	for _, key := range order {
+		var c int
+		switch key.Path {
+		case "_count":
+			c = compareInts(a["doc_count"].(int64), b["doc_count"].(int64))
+		case "_key":
+			c = compareTermKeys(a["key"].(string), b["key"].(string))
+		default:
+			subA, _ := a["aggregations"].(map[string]AggregationResult)
+			subB, _ := b["aggregations"].(map[string]AggregationResult)
+			va, vb := metricValue(subA[key.Path], key.Metric), metricValue(subB[key.Path], key.Metric)
+			if math.IsNaN(va) || math.IsNaN(vb) {
+				if math.IsNaN(va) != math.IsNaN(vb) {
+					return math.IsNaN(vb)
+				}
+				continue
+			}
+			c = compareFloats(va, vb)
+		}
+		if c != 0 {
+			return (c < 0) == key.Asc
+		}
+	}
+	return false
+}
+
+// shardError bounds how much a shard that cut its buckets in the order after
+// one of lastCount docs may understate the count of a term it left out.
+// Under count descending order such a term has at most lastCount docs here;
+// under key order the cut is exact; under any other order it is unbounded
+// (-1).
+func shardError(order aggparams.TermsOrder, lastCount int64) int64 {
+	switch {
+	case order[0].Path == "_count" && !order[0].Asc:
+		return lastCount
+	case order[0].Path == "_key":
+		return 0
+	default:
+		return -1
+	}
+}
+
+// compareTermKeys orders bucket keys: numbers numerically, before any other
+// keys, which compare as strings
+func compareTermKeys(a, b string) int {
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	switch {
+	case errA == nil && errB == nil:
+		return compareFloats(fa, fb)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// termsFilter selects the terms a terms aggregation buckets
+type termsFilter struct {
+	include, exclude *regexp.Regexp
+	includeValues    map[string]struct{}
+	excludeValues    map[string]struct{}
+	partition        int
+	numPartitions    int
+}
+
+// termsFilter parses the include and exclude of a terms aggregation. Each is
+// either a regular expression matching whole terms or an array of exact
+// terms, and include may instead be {"partition": p, "num_partitions": n} to
+// keep the terms hashing to partition p of n. Exclude wins over include.
+func (a *aggSpec) termsFilter() (*termsFilter, error) {
+	filter := &termsFilter{}
+	var err error
+	switch include := a.body["include"].(type) {
+	case nil:
+	case map[string]interface{}:
+		filter.partition = intParam(include, "partition", -1)
+		filter.numPartitions = intParam(include, "num_partitions", 0)
+		if filter.numPartitions <= 0 || filter.partition < 0 || filter.partition >= filter.numPartitions {
+			return nil, fmt.Errorf("include of terms aggregation [%s] needs a partition in [0, num_partitions)", a.name)
+		}
+	default:
+		if filter.include, filter.includeValues, err = parseTermsPattern(include); err != nil {
+			return nil, fmt.Errorf("invalid include of terms aggregation [%s]: %w", a.name, err)
+		}
+	}
+	if exclude, ok := a.body["exclude"]; ok {
+		if filter.exclude, filter.excludeValues, err = parseTermsPattern(exclude); err != nil {
+			return nil, fmt.Errorf("invalid exclude of terms aggregation [%s]: %w", a.name, err)
+		}
+	}
+	return filter, nil
+}
+
+// parseTermsPattern parses an include or exclude: a regular expression, which
+// must match the whole term, or an array of exact terms
+func parseTermsPattern(pattern interface{}) (*regexp.Regexp, map[string]struct{}, error) {
+	switch p := pattern.(type) {
+	case string:
+		re, err := regexp.Compile("^(?:" + p + ")$")
+		return re, nil, err
+	case []interface{}:
+		values := make(map[string]struct{}, len(p))
+		for _, value := range p {
+			key, ok := termValueKey(value)
+			if !ok {
+				return nil, nil, fmt.Errorf("terms must be strings or numbers, got %v", value)
+			}
+			values[key] = struct{}{}
+		}
+		return nil, values, nil
+	}
+	return nil, nil, fmt.Errorf("expected a regular expression or an array of terms")
+}
+
+// accepts reports whether a term is bucketed
+func (f *termsFilter) accepts(key string) bool {
+	if f.exclude != nil && f.exclude.MatchString(key) {
+		return false
+	}
+	if _, ok := f.excludeValues[key]; ok {
+		return false
+	}
+	switch {
+	case f.include != nil:
+		return f.include.MatchString(key)
+	case f.includeValues != nil:
+		_, ok := f.includeValues[key]
+		return ok
+	case f.numPartitions > 0:
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		return int(h.Sum32()%uint32(f.numPartitions)) == f.partition
+	}
+	return true
+}
+
+// missingKey returns the bucket key of documents without a value for the
+// field, if the aggregation sets missing
+func missingKey(body map[string]interface{}) (string, bool) {
+	value, ok := body["missing"]
+	if !ok {
+		return "", false
+	}
+	return termValueKey(value)
+}
+
+// termValueKey renders a request value the way bucket keys are rendered
+func termValueKey(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
+
+// histogram buckets numeric values into fixed-width intervals. As for
+// date_histogram, min_doc_count is left to the coordinator.
+func (a *aggSpec) histogram(ctx *aggContext, docs []aggDoc) (AggregationResult, error) {
+	interval := floatParam(a.body, "interval", 0)
+	if interval <= 0 {
+		return AggregationResult{}, fmt.Errorf("[interval] must be greater than 0 for histogram aggregation [%s]", a.name)
+	}
+	offset := floatParam(a.body, "offset", 0)
+	minDocCount := intParam(a.body, "min_doc_count", 0)
+
+	// Buckets are keyed by interval index so that filling the gaps below
+	// yields exactly the same keys
+	bucketDocs := make(map[int64][]aggDoc)
+	for _, doc := range docs {
+		seen := make(map[int64]struct{})
+		for _, value := range numericValues(doc, a.field) {
+			index := int64(math.Floor((value - offset) / interval))
+			if _, ok := seen[index]; ok {
+				continue
+			}
+			seen[index] = struct{}{}
+			bucketDocs[index] = append(bucketDocs[index], doc)
+		}
+	}
+
+	indexes := make([]int64, 0, len(bucketDocs))
+	for index := range bucketDocs {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+
+	// With min_doc_count 0 the empty intervals between the first and last
+	// bucket are returned too
+	if minDocCount == 0 && len(indexes) > 1 {
+		first, last := indexes[0], indexes[len(indexes)-1]
+		if last-first >= maxBuckets {
+			return AggregationResult{}, fmt.Errorf("histogram aggregation [%s] would create more than %d buckets", a.name, maxBuckets)
+		}
+		indexes = indexes[:0]
+		for index := first; index <= last; index++ {
+			indexes = append(indexes, index)
+		}
+	}
+
+	buckets := make([]map[string]interface{}, 0, len(indexes))
+	for _, index := range indexes {
+		bucket, err := a.newBucket(ctx, float64(index)*interval+offset, bucketDocs[index])
+		if err != nil {
+			return AggregationResult{}, err
+		}
+		buckets = append(buckets, bucket)
+	}
+	return AggregationResult{Type: a.aggType, Buckets: buckets}, nil
+}
+
+// dateHistogram buckets date values into calendar or fixed intervals, in
+// the request's time_zone. min_doc_count is left to the coordinator, which
+// sums the shards' counts; with min_doc_count 0 the empty buckets between
+// the first and last bucket, and out to extended_bounds, are returned.
+// Buckets outside hard_bounds are not.
+func (a *aggSpec) dateHistogram(ctx *aggContext, docs []aggDoc) (AggregationResult, error) {
+	rounding, err := dates.ParseRounding(a.body)
+	if err != nil {
+		return AggregationResult{}, fmt.Errorf("date_histogram aggregation [%s]: %w", a.name, err)
+	}
+	extendedBounds, err := rounding.ParseBounds(a.body["extended_bounds"])
+	if err != nil {
+		return AggregationResult{}, fmt.Errorf("extended_bounds of date_histogram aggregation [%s]: %w", a.name, err)
+	}
+	hardBounds, err := rounding.ParseBounds(a.body["hard_bounds"])
+	if err != nil {
+		return AggregationResult{}, fmt.Errorf("hard_bounds of date_histogram aggregation [%s]: %w", a.name, err)
+	}
+	minDocCount := intParam(a.body, "min_doc_count", 0)
+
+	bucketDocs := make(map[int64][]aggDoc)
+	for _, doc := range docs {
+		seen := make(map[int64]struct{})
+		for _, value := range doc.fields[a.field] {
+			t, ok := parseDateValue(value)
+			if !ok {
+				continue
+			}
+			key := rounding.Round(t).UnixMilli()
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			bucketDocs[key] = append(bucketDocs[key], doc)
+		}
+	}
+
+	keys := make([]int64, 0, len(bucketDocs))
+	for key := range bucketDocs {
+		keys = append(keys, key)
+	}
+	keys, err = rounding.BucketKeys(keys, minDocCount == 0, extendedBounds, hardBounds, maxBuckets)
+	if err != nil {
+		return AggregationResult{}, fmt.Errorf("date_histogram aggregation [%s]: %w", a.name, err)
+	}
+
+	buckets := make([]map[string]interface{}, 0, len(keys))
+	for _, key := range keys {
+		bucket, err := a.newBucket(ctx, float64(key), bucketDocs[key])
+		if err != nil {
+			return AggregationResult{}, err
+		}
+		bucket["key_as_string"] = rounding.FormatKey(time.UnixMilli(key))
+		buckets = append(buckets, bucket)
+	}
+	return AggregationResult{Type: a.aggType, Buckets: buckets}, nil
+}
+
+// rangeBuckets counts docs per requested [from, to) range, in request order
+func (a *aggSpec) rangeBuckets(ctx *aggContext, docs []aggDoc) (AggregationResult, error) {
+	ranges, ok := a.body["ranges"].([]interface{})
+	if !ok || len(ranges) == 0 {
+		return AggregationResult{}, fmt.Errorf("[ranges] is required for range aggregation [%s]", a.name)
+	}
+
+	buckets := make([]map[string]interface{}, 0, len(ranges))
+	for _, r := range ranges {
+		rangeMap, ok := r.(map[string]interface{})
+		if !ok {
+			return AggregationResult{}, fmt.Errorf("each range of range aggregation [%s] must be an object", a.name)
+		}
+		from, hasFrom := rangeMap["from"].(float64)
+		to, hasTo := rangeMap["to"].(float64)
+
+		key, ok := rangeMap["key"].(string)
+		if !ok {
+			key = rangeKey(from, hasFrom, to, hasTo)
+		}
+
+		var rangeDocs []aggDoc
+		for _, doc := range docs {
+			for _, value := range numericValues(doc, a.field) {
+				if (!hasFrom || value >= from) && (!hasTo || value < to) {
+					rangeDocs = append(rangeDocs, doc)
+					break
+				}
+			}
+		}
+
+		bucket, err := a.newBucket(ctx, key, rangeDocs)
+		if err != nil {
+			return AggregationResult{}, err
+		}
+		if hasFrom {
+			bucket["from"] = from
+		}
+		if hasTo {
+			bucket["to"] = to
+		}
+		buckets = append(buckets, bucket)
+	}
+	return AggregationResult{Type: a.aggType, Buckets: buckets}, nil
+}
+
+// filters counts docs per named filter query
+func (a *aggSpec) filters(ctx *aggContext, docs []aggDoc) (AggregationResult, error) {
+	if ctx.matchQuery == nil {
+		return AggregationResult{}, fmt.Errorf("filters aggregation [%s] is not supported here", a.name)
+	}
+
+	// Named filters come back in key order; anonymous ones by position
+	var keys []string
+	queries := make(map[string]map[string]interface{})
+	switch filters := a.body["filters"].(type) {
+	case map[string]interface{}:
+		for key, q := range filters {
+			queryMap, ok := q.(map[string]interface{})
+			if !ok {
+				return AggregationResult{}, fmt.Errorf("filter [%s] of filters aggregation [%s] must be an object", key, a.name)
+			}
+			keys = append(keys, key)
+			queries[key] = queryMap
+		}
+		sort.Strings(keys)
+	case []interface{}:
+		for i, q := range filters {
+			queryMap, ok := q.(map[string]interface{})
+			if !ok {
+				return AggregationResult{}, fmt.Errorf("filter [%d] of filters aggregation [%s] must be an object", i, a.name)
+			}
+			key := strconv.Itoa(i)
+			keys = append(keys, key)
+			queries[key] = queryMap
+		}
+	default:
+		return AggregationResult{}, fmt.Errorf("[filters] is required for filters aggregation [%s]", a.name)
+	}
+
+	matchedAny := make(map[int]struct{})
+	buckets := make([]map[string]interface{}, 0, len(keys)+1)
+	for _, key := range keys {
+		matches, err := ctx.match(queries[key])
+		if err != nil {
+			return AggregationResult{}, fmt.Errorf("filter [%s] of filters aggregation [%s]: %w", key, a.name, err)
+		}
+		var filterDocs []aggDoc
+		for _, doc := range docs {
+			if _, ok := matches[doc.id]; ok {
+				filterDocs = append(filterDocs, doc)
+				matchedAny[doc.id] = struct{}{}
+			}
+		}
+		bucket, err := a.newBucket(ctx, key, filterDocs)
+		if err != nil {
+			return AggregationResult{}, err
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	if otherBucket, _ := a.body["other_bucket"].(bool); otherBucket {
+		otherKey, ok := a.body["other_bucket_key"].(string)
+		if !ok {
+			otherKey = "_other_"
+		}
+		var otherDocs []aggDoc
+		for _, doc := range docs {
+			if _, ok := matchedAny[doc.id]; !ok {
+				otherDocs = append(otherDocs, doc)
+			}
+		}
+		bucket, err := a.newBucket(ctx, otherKey, otherDocs)
+		if err != nil {
+			return AggregationResult{}, err
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	return AggregationResult{Type: a.aggType, Buckets: buckets}, nil
+}
+
+// stats computes the numeric metrics of the field. Single-value metrics
+// (avg, sum, min, max) carry the count too so they can be merged exactly.
+func (a *aggSpec) stats(docs []aggDoc) AggregationResult {
+	result := AggregationResult{Type: a.aggType}
+
+	lowest, highest := math.Inf(1), math.Inf(-1)
+	for _, doc := range docs {
+		for _, value := range numericValues(doc, a.field) {
+			result.Count++
+			result.Sum += value
+			result.SumOfSquares += value * value
+			lowest = math.Min(lowest, value)
+			highest = math.Max(highest, value)
+		}
+	}
+	if result.Count == 0 {
+		return result
+	}
+
+	result.Min = lowest
+	result.Max = highest
+	result.Avg = result.Sum / float64(result.Count)
+
+	if a.aggType == "extended_stats" {
+		sigma := floatParam(a.body, "sigma", 2)
+		result.Variance = math.Max(result.SumOfSquares/float64(result.Count)-result.Avg*result.Avg, 0)
+		result.StdDeviation = math.Sqrt(result.Variance)
+		result.StdDeviationBoundsUpper = result.Avg + sigma*result.StdDeviation
+		result.StdDeviationBoundsLower = result.Avg - sigma*result.StdDeviation
+	}
+	return result
+}
+
+// valueCount counts the field's values, numeric or not
+func (a *aggSpec) valueCount(docs []aggDoc) AggregationResult {
+	result := AggregationResult{Type: a.aggType}
+	for _, doc := range docs {
+		result.Count += int64(len(doc.fields[a.field]))
+	}
+	return result
+}
+
+// cardinality counts the field's distinct values with a HyperLogLog++
+// sketch, which is returned too so the coordinator can union the sketches of
+// all shards. Counts up to precision_threshold are exact.
+func (a *aggSpec) cardinality(docs []aggDoc) (AggregationResult, error) {
+	threshold := intParam(a.body, "precision_threshold", sketch.DefaultPrecisionThreshold)
+	if threshold < 0 {
+		return AggregationResult{}, fmt.Errorf("precision_threshold of cardinality aggregation [%s] must not be negative", a.name)
+	}
+
+	hll := sketch.NewHyperLogLog(sketch.PrecisionFromThreshold(threshold))
+	for _, doc := range docs {
+		for _, value := range doc.fields[a.field] {
+			hll.Add(termKey(value))
+		}
+	}
+
+	result := AggregationResult{Type: a.aggType, Value: hll.Cardinality()}
+	var err error
+	if result.Sketch, err = hll.MarshalBinary(); err != nil {
+		return AggregationResult{}, err
+	}
+	return result, nil
+}
+
+// percentiles estimates percentiles of the field's numeric values with a
+// t-digest, which is returned too so the coordinator can merge the digests of
+// all shards. Up to the digest's compression values it is exact,
+// interpolating linearly between the closest ranks. percentile_ranks reports
+// instead the percentage of values at or below each of its values.
+func (a *aggSpec) percentiles(docs []aggDoc) (AggregationResult, error) {
+	points, err := a.percentilePoints()
+	if err != nil {
+		return AggregationResult{}, err
+	}
+	compression, err := a.tdigestCompression()
+	if err != nil {
+		return AggregationResult{}, err
+	}
+
+	digest := sketch.NewTDigest(compression)
+	for _, doc := range docs {
+		for _, value := range numericValues(doc, a.field) {
+			digest.Add(value)
+		}
+	}
+
+	result := AggregationResult{Type: a.aggType, Count: digest.Count()}
+	if result.Sketch, err = digest.MarshalBinary(); err != nil {
+		return AggregationResult{}, err
+	}
+	if digest.Count() == 0 {
+		return result, nil
+	}
+
+	result.Values = make(map[string]float64, len(points))
+	for _, point := range points {
+		if a.aggType == "percentile_ranks" {
+			result.Values[formatDouble(point)] = digest.CDF(point) * 100
+		} else {
+			result.Values[formatDouble(point)] = digest.Quantile(point / 100)
+		}
+	}
+	return result, nil
+}
+
+// percentilePoints reads the percents of a percentiles aggregation or the
+// values of a percentile_ranks aggregation
+func (a *aggSpec) percentilePoints() ([]float64, error) {
+	if a.aggType == "percentile_ranks" {
+		raw, ok := a.body["values"].([]interface{})
+		if !ok || len(raw) == 0 {
+			return nil, fmt.Errorf("percentile_ranks aggregation [%s] requires values", a.name)
+		}
+		values := make([]float64, 0, len(raw))
+		for _, v := range raw {
+			value, ok := v.(float64)
+			if !ok {
+				return nil, fmt.Errorf("values of percentile_ranks aggregation [%s] must be numbers", a.name)
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+
+	raw, ok := a.body["percents"].([]interface{})
+	if !ok {
+		return defaultPercents, nil
+	}
+	percents := make([]float64, 0, len(raw))
+	for _, p := range raw {
+		percent, ok := p.(float64)
+		if !ok || percent < 0 || percent > 100 {
+			return nil, fmt.Errorf("percents of percentiles aggregation [%s] must be numbers between 0 and 100", a.name)
+		}
+		percents = append(percents, percent)
+	}
+	return percents, nil
+}
+
+// tdigestCompression reads the tdigest.compression of a percentiles or
+// percentile_ranks aggregation
+func (a *aggSpec) tdigestCompression() (float64, error) {
+	if _, ok := a.body["hdr"]; ok {
+		return 0, fmt.Errorf("aggregation [%s] supports only the tdigest method", a.name)
+	}
+	raw, ok := a.body["tdigest"]
+	if !ok {
+		return sketch.DefaultCompression, nil
+	}
+	tdigest, ok := raw.(map[string]interface{})
+	if !ok {
+		return 0, fmt.Errorf("tdigest of aggregation [%s] must be an object", a.name)
+	}
+	compression := floatParam(tdigest, "compression", sketch.DefaultCompression)
+	if compression <= 0 {
+		return 0, fmt.Errorf("tdigest compression of aggregation [%s] must be positive", a.name)
+	}
+	return compression, nil
+}
+
+// parseDateValue parses a stored date: epoch millis or a date string, in UTC
+// unless it has an offset
+func parseDateValue(value string) (time.Time, bool) {
+	return dates.Parse(value, time.UTC)
+}
+
+// numericValues returns the field's values that parse as numbers
+func numericValues(doc aggDoc, field string) []float64 {
+	raw := doc.fields[field]
+	values := make([]float64, 0, len(raw))
+	for _, value := range raw {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			values = append(values, f)
+		}
+	}
+	return values
+}
+
+// termKey normalizes a stored value into a bucket key. Doubles are stored
+// with six decimals, so they are trimmed back to their shortest form.
+func termKey(value string) string {
+	dot := strings.IndexByte(value, '.')
+	if dot < 0 || len(value)-dot-1 != 6 {
+		return value
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return value
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// splitStoredValue splits a stored JSON array into its elements; any other
+// stored value is a single value
+func splitStoredValue(value string) []string {
+	if !strings.HasPrefix(value, "[") {
+		return []string{value}
+	}
+	var elems []interface{}
+	if err := json.Unmarshal([]byte(value), &elems); err != nil {
+		return []string{value}
+	}
+
+	values := make([]string, 0, len(elems))
+	for _, elem := range elems {
+		switch v := elem.(type) {
+		case nil:
+		case string:
+			values = append(values, v)
+		case float64:
+			values = append(values, strconv.FormatFloat(v, 'f', -1, 64))
+		case bool:
+			values = append(values, strconv.FormatBool(v))
+		default:
+			encoded, err := json.Marshal(v)
+			if err == nil {
+				values = append(values, string(encoded))
+			}
+		}
+	}
+	return values
+}
+
+// rangeKey builds the default key of a range bucket, e.g. "*-100.0"
+func rangeKey(from float64, hasFrom bool, to float64, hasTo bool) string {
+	fromKey, toKey := "*", "*"
+	if hasFrom {
+		fromKey = formatDouble(from)
+	}
+	if hasTo {
+		toKey = formatDouble(to)
+	}
+	return fromKey + "-" + toKey
+}
+
+// formatDouble renders a double the way OpenSearch renders keys: 100.0, 2.5
+func formatDouble(v float64) string {
+	s := strconv.FormatFloat(v, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+// intParam reads an integer parameter of an aggregation body
+func intParam(body map[string]interface{}, name string, def int) int {
+	if v, ok := body[name].(float64); ok {
+		return int(v)
+	}
+	return def
+}
+
+// floatParam reads a numeric parameter of an aggregation body
+func floatParam(body map[string]interface{}, name string, def float64) float64 {
+	if v, ok := body[name].(float64); ok {
+		return v
+	}
+	return def
+}
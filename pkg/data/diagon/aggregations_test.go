@@ -0,0 +1,542 @@
+package diagon
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/conjugate/conjugate/pkg/common/sketch"
+)
+
+func aggTestDocs() []aggDoc {
+	return []aggDoc{
+		{id: 0, fields: map[string][]string{"tag": {"a", "b"}, "price": {"10.000000"}, "ts": {"2024-01-15T10:00:00Z"}}},
+		{id: 1, fields: map[string][]string{"tag": {"a"}, "price": {"20.500000"}, "ts": {"2024-01-20T08:30:00Z"}}},
+		{id: 2, fields: map[string][]string{"tag": {"c"}, "price": {"40"}, "ts": {"2024-03-02"}}},
+		{id: 3, fields: map[string][]string{"tag": {"a", "a"}}},
+	}
+}
+
+func evaluateTestAggregation(t *testing.T, aggs string, ctx *aggContext) AggregationResult {
+	t.Helper()
+	specs, err := parseAggregations([]byte(aggs))
+	if err != nil {
+		t.Fatalf("parseAggregations failed: %v", err)
+	}
+	if len(specs) != 1 {
+		t.Fatalf("expected one aggregation, got %d", len(specs))
+	}
+	if ctx == nil {
+		ctx = &aggContext{docs: aggTestDocs()}
+	}
+	results, err := evaluateAggregations(specs, ctx)
+	if err != nil {
+		t.Fatalf("evaluateAggregations failed: %v", err)
+	}
+	return results[specs[0].name]
+}
+
+func TestAggregations_Terms(t *testing.T) {
+	result := evaluateTestAggregation(t, `{"tags":{"terms":{"field":"tag","size":2,"shard_size":2}}}`, nil)
+
+	if len(result.Buckets) != 2 {
+		t.Fatalf("expected 2 buckets, got %d", len(result.Buckets))
+	}
+	// A document is counted once per distinct value
+	if result.Buckets[0]["key"] != "a" || result.Buckets[0]["doc_count"] != int64(3) {
+		t.Errorf("unexpected first bucket: %v", result.Buckets[0])
+	}
+	// Ties are broken by key
+	if result.Buckets[1]["key"] != "b" || result.Buckets[1]["doc_count"] != int64(1) {
+		t.Errorf("unexpected second bucket: %v", result.Buckets[1])
+	}
+	// Term "c" was cut; it has at most as many docs as the last bucket
+	if result.SumOtherDocCount != 1 || result.DocCountErrorUpperBound != 1 {
+		t.Errorf("expected 1 other doc and an error bound of 1, got %d and %d",
+			result.SumOtherDocCount, result.DocCountErrorUpperBound)
+	}
+
+	// Shards over-fetch size*1.5+10 buckets and leave min_doc_count to the
+	// coordinator, since a term rare on one shard may be common across all
+	result = evaluateTestAggregation(t, `{"tags":{"terms":{"field":"tag","size":2,"min_doc_count":2}}}`, nil)
+	if len(result.Buckets) != 3 || result.SumOtherDocCount != 0 || result.DocCountErrorUpperBound != 0 {
+		t.Errorf("expected all 3 buckets without error, got %+v", result)
+	}
+}
+
+func TestAggregations_TermsOrder(t *testing.T) {
+	keys := func(result AggregationResult) []string {
+		var keys []string
+		for _, bucket := range result.Buckets {
+			keys = append(keys, bucket["key"].(string))
+		}
+		return keys
+	}
+	for _, tc := range []struct {
+		order    string
+		expected []string
+	}{
+		{`{"_key":"desc"}`, []string{"c", "b", "a"}},
+		{`{"_count":"asc"}`, []string{"b", "c", "a"}},
+		{`[{"_count":"asc"},{"_key":"desc"}]`, []string{"c", "b", "a"}},
+		{`{"max_price":"desc"}`, []string{"c", "a", "b"}},
+		{`{"price_stats.min":"asc"}`, []string{"a", "b", "c"}},
+		{`{"p.50":"asc"}`, []string{"b", "a", "c"}},
+	} {
+		result := evaluateTestAggregation(t, `{"tags":{"terms":{"field":"tag","order":`+tc.order+`},"aggs":{
+			"max_price":{"max":{"field":"price"}},
+			"price_stats":{"stats":{"field":"price"}},
+			"p":{"percentiles":{"field":"price","percents":[50]}}}}}`, nil)
+		if got := keys(result); strings.Join(got, ",") != strings.Join(tc.expected, ",") {
+			t.Errorf("order %s: expected %v, got %v", tc.order, tc.expected, got)
+		}
+	}
+
+	// A cut under any order but count descending or key leaves the error unbounded
+	result := evaluateTestAggregation(t,
+		`{"tags":{"terms":{"field":"tag","size":1,"shard_size":1,"order":{"_count":"asc"}}}}`, nil)
+	if result.DocCountErrorUpperBound != -1 || result.SumOtherDocCount != 4 {
+		t.Errorf("expected an unbounded error and 4 other docs, got %+v", result)
+	}
+	result = evaluateTestAggregation(t,
+		`{"tags":{"terms":{"field":"tag","size":1,"shard_size":1,"order":{"_key":"asc"}}}}`, nil)
+	if result.DocCountErrorUpperBound != 0 {
+		t.Errorf("expected no error under key order, got %d", result.DocCountErrorUpperBound)
+	}
+
+	// Numeric keys compare as numbers, before other keys
+	if compareTermKeys("9", "10") >= 0 || compareTermKeys("10", "a") >= 0 || compareTermKeys("b", "a") <= 0 {
+		t.Error("unexpected term key order")
+	}
+}
+
+func TestAggregations_TermsFilterAndMissing(t *testing.T) {
+	for _, tc := range []struct {
+		body     string
+		expected map[string]int64
+	}{
+		{`"include":"a|c"`, map[string]int64{"a": 3, "c": 1}},
+		{`"include":["a","b"],"exclude":"b"`, map[string]int64{"a": 3}},
+		{`"exclude":["a"],"missing":"none"`, map[string]int64{"b": 1, "c": 1}},
+	} {
+		result := evaluateTestAggregation(t, `{"tags":{"terms":{"field":"tag",`+tc.body+`}}}`, nil)
+		got := make(map[string]int64)
+		for _, bucket := range result.Buckets {
+			got[bucket["key"].(string)] = bucket["doc_count"].(int64)
+		}
+		if len(got) != len(tc.expected) {
+			t.Errorf("%s: expected %v, got %v", tc.body, tc.expected, got)
+		}
+		for key, count := range tc.expected {
+			if got[key] != count {
+				t.Errorf("%s: expected %v, got %v", tc.body, tc.expected, got)
+			}
+		}
+	}
+
+	// Document 3 has no price and is bucketed under the missing value
+	result := evaluateTestAggregation(t, `{"prices":{"terms":{"field":"price","missing":0,"order":{"_key":"asc"}}}}`, nil)
+	if len(result.Buckets) != 4 || result.Buckets[0]["key"] != "0" || result.Buckets[1]["key"] != "10" {
+		t.Errorf("unexpected buckets: %v", result.Buckets)
+	}
+
+	// Partitions split the terms between them
+	seen := 0
+	for partition := 0; partition < 2; partition++ {
+		result := evaluateTestAggregation(t, fmt.Sprintf(
+			`{"tags":{"terms":{"field":"tag","include":{"partition":%d,"num_partitions":2}}}}`, partition), nil)
+		seen += len(result.Buckets)
+	}
+	if seen != 3 {
+		t.Errorf("expected the partitions to hold 3 terms, got %d", seen)
+	}
+}
+
+func TestAggregations_TermsMinDocCountZero(t *testing.T) {
+	ctx := &aggContext{
+		docs: aggTestDocs()[2:],
+		fieldTerms: func(field string) ([]string, error) {
+			return []string{"a", "b", "c", "d"}, nil
+		},
+	}
+	result := evaluateTestAggregation(t,
+		`{"tags":{"terms":{"field":"tag","min_doc_count":0,"exclude":"d"},"aggs":{"m":{"max":{"field":"price"}}}}}`, ctx)
+
+	// Terms without matches get empty buckets, after those with matches
+	expected := []struct {
+		key   string
+		count int64
+	}{{"a", 1}, {"c", 1}, {"b", 0}}
+	if len(result.Buckets) != len(expected) {
+		t.Fatalf("unexpected buckets: %v", result.Buckets)
+	}
+	for i, e := range expected {
+		if result.Buckets[i]["key"] != e.key || result.Buckets[i]["doc_count"] != e.count {
+			t.Errorf("bucket %d: expected %s with %d docs, got %v", i, e.key, e.count, result.Buckets[i])
+		}
+	}
+	if _, ok := result.Buckets[2]["aggregations"].(map[string]AggregationResult)["m"]; !ok {
+		t.Errorf("expected sub-aggregations in the empty bucket: %v", result.Buckets[2])
+	}
+}
+
+func TestAggregations_TermsErrors(t *testing.T) {
+	for name, aggs := range map[string]string{
+		"zero size":        `{"a":{"terms":{"field":"tag","size":0}}}`,
+		"bad direction":    `{"a":{"terms":{"field":"tag","order":{"_count":"up"}}}}`,
+		"two paths":        `{"a":{"terms":{"field":"tag","order":{"_count":"asc","_key":"asc"}}}}`,
+		"no sub-agg":       `{"a":{"terms":{"field":"tag","order":{"nope":"asc"}}}}`,
+		"no stats metric":  `{"a":{"terms":{"field":"tag","order":{"s":"asc"}},"aggs":{"s":{"stats":{"field":"price"}}}}}`,
+		"bucket sub-agg":   `{"a":{"terms":{"field":"tag","order":{"s":"asc"}},"aggs":{"s":{"terms":{"field":"price"}}}}}`,
+		"bad include":      `{"a":{"terms":{"field":"tag","include":"("}}}`,
+		"bad partition":    `{"a":{"terms":{"field":"tag","include":{"partition":2,"num_partitions":2}}}}`,
+		"no field terms":   `{"a":{"terms":{"field":"tag","min_doc_count":0}}}`,
+		"negative min doc": `{"a":{"terms":{"field":"tag","min_doc_count":-1}}}`,
+	} {
+		specs, err := parseAggregations([]byte(aggs))
+		if err != nil {
+			t.Fatalf("%s: parseAggregations failed: %v", name, err)
+		}
+		if _, err := evaluateAggregations(specs, &aggContext{docs: aggTestDocs()}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestAggregations_Histogram(t *testing.T) {
+	result := evaluateTestAggregation(t, `{"prices":{"histogram":{"field":"price","interval":10}}}`, nil)
+
+	// Empty intervals between the first and last bucket are filled in
+	expected := []struct {
+		key   float64
+		count int64
+	}{{10, 1}, {20, 1}, {30, 0}, {40, 1}}
+	if len(result.Buckets) != len(expected) {
+		t.Fatalf("expected %d buckets, got %v", len(expected), result.Buckets)
+	}
+	for i, e := range expected {
+		if result.Buckets[i]["key"] != e.key || result.Buckets[i]["doc_count"] != e.count {
+			t.Errorf("bucket %d: expected %v/%d, got %v", i, e.key, e.count, result.Buckets[i])
+		}
+	}
+}
+
+func TestAggregations_DateHistogram(t *testing.T) {
+	result := evaluateTestAggregation(t, `{"months":{"date_histogram":{"field":"ts","calendar_interval":"month"}}}`, nil)
+
+	expected := []struct {
+		key   string
+		count int64
+	}{{"2024-01-01T00:00:00.000Z", 2}, {"2024-02-01T00:00:00.000Z", 0}, {"2024-03-01T00:00:00.000Z", 1}}
+	if len(result.Buckets) != len(expected) {
+		t.Fatalf("expected %d buckets, got %v", len(expected), result.Buckets)
+	}
+	for i, e := range expected {
+		if result.Buckets[i]["key_as_string"] != e.key || result.Buckets[i]["doc_count"] != e.count {
+			t.Errorf("bucket %d: expected %s/%d, got %v", i, e.key, e.count, result.Buckets[i])
+		}
+	}
+}
+
+func TestAggregations_DateHistogramTimeZone(t *testing.T) {
+	docs := []aggDoc{
+		// Late evening of the 9th in New York
+		{id: 0, fields: map[string][]string{"ts": {"2024-03-10T03:00:00Z"}}},
+		// The 10th, the day DST starts, and the 11th
+		{id: 1, fields: map[string][]string{"ts": {"2024-03-10T12:00:00Z"}}},
+		{id: 2, fields: map[string][]string{"ts": {"2024-03-12T03:30:00Z"}}},
+	}
+	keys := func(result AggregationResult) string {
+		var keys []string
+		for _, bucket := range result.Buckets {
+			keys = append(keys, fmt.Sprintf("%s:%d", bucket["key_as_string"], bucket["doc_count"]))
+		}
+		return strings.Join(keys, " ")
+	}
+
+	result := evaluateTestAggregation(t, `{"days":{"date_histogram":{"field":"ts","calendar_interval":"day",
+		"time_zone":"America/New_York"}}}`, &aggContext{docs: docs})
+	expected := "2024-03-09T00:00:00.000-05:00:1 2024-03-10T00:00:00.000-05:00:1 2024-03-11T00:00:00.000-04:00:1"
+	if got := keys(result); got != expected {
+		t.Fatalf("expected buckets %s, got %s", expected, got)
+	}
+	// The 10th is 23 hours long
+	if length := result.Buckets[2]["key"].(float64) - result.Buckets[1]["key"].(float64); length != 23*60*60*1000 {
+		t.Errorf("expected a 23 hour day, got %v ms", length)
+	}
+
+	// Extended bounds add empty days, hard bounds drop the 9th
+	result = evaluateTestAggregation(t, `{"days":{"date_histogram":{"field":"ts","calendar_interval":"day",
+		"time_zone":"America/New_York","extended_bounds":{"max":"2024-03-12"},"hard_bounds":{"min":"2024-03-10"}}}}`,
+		&aggContext{docs: docs})
+	expected = "2024-03-10T00:00:00.000-05:00:1 2024-03-11T00:00:00.000-04:00:1 2024-03-12T00:00:00.000-04:00:0"
+	if got := keys(result); got != expected {
+		t.Errorf("expected buckets %s, got %s", expected, got)
+	}
+
+	// Days from 06:00 local time. min_doc_count is left to the coordinator,
+	// so every non-empty bucket is kept, without filling gaps.
+	result = evaluateTestAggregation(t, `{"days":{"date_histogram":{"field":"ts","calendar_interval":"day",
+		"time_zone":"America/New_York","offset":"+6h","min_doc_count":2}}}`, &aggContext{docs: docs})
+	expected = "2024-03-09T06:00:00.000-05:00:1 2024-03-10T06:00:00.000-04:00:1 2024-03-11T06:00:00.000-04:00:1"
+	if got := keys(result); got != expected {
+		t.Errorf("expected buckets %s, got %s", expected, got)
+	}
+
+	for name, aggs := range map[string]string{
+		"time zone": `{"d":{"date_histogram":{"field":"ts","calendar_interval":"day","time_zone":"Nowhere/Special"}}}`,
+		"bounds":    `{"d":{"date_histogram":{"field":"ts","calendar_interval":"day","hard_bounds":{"min":"soon"}}}}`,
+	} {
+		specs, err := parseAggregations([]byte(aggs))
+		if err != nil {
+			t.Fatalf("%s: parseAggregations failed: %v", name, err)
+		}
+		if _, err := evaluateAggregations(specs, &aggContext{docs: docs}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestAggregations_Range(t *testing.T) {
+	result := evaluateTestAggregation(t,
+		`{"prices":{"range":{"field":"price","ranges":[{"to":20},{"from":20,"to":40,"key":"mid"},{"from":40}]}}}`, nil)
+
+	if len(result.Buckets) != 3 {
+		t.Fatalf("expected 3 buckets, got %d", len(result.Buckets))
+	}
+	if result.Buckets[0]["key"] != "*-20.0" || result.Buckets[0]["doc_count"] != int64(1) {
+		t.Errorf("unexpected first bucket: %v", result.Buckets[0])
+	}
+	if _, ok := result.Buckets[0]["from"]; ok {
+		t.Errorf("unbounded range should have no from: %v", result.Buckets[0])
+	}
+	if result.Buckets[1]["key"] != "mid" || result.Buckets[1]["doc_count"] != int64(1) {
+		t.Errorf("unexpected second bucket: %v", result.Buckets[1])
+	}
+	if result.Buckets[2]["key"] != "40.0-*" || result.Buckets[2]["from"] != 40.0 {
+		t.Errorf("unexpected third bucket: %v", result.Buckets[2])
+	}
+}
+
+func TestAggregations_Filters(t *testing.T) {
+	ctx := &aggContext{
+		docs: aggTestDocs(),
+		matchQuery: func(query map[string]interface{}) (map[int]struct{}, error) {
+			// Every query matches documents 0 and 7; 7 is outside the doc set
+			return map[int]struct{}{0: {}, 7: {}}, nil
+		},
+	}
+	result := evaluateTestAggregation(t,
+		`{"f":{"filters":{"filters":{"x":{"match_all":{}}},"other_bucket":true}}}`, ctx)
+
+	if len(result.Buckets) != 2 {
+		t.Fatalf("expected 2 buckets, got %v", result.Buckets)
+	}
+	if result.Buckets[0]["key"] != "x" || result.Buckets[0]["doc_count"] != int64(1) {
+		t.Errorf("unexpected filter bucket: %v", result.Buckets[0])
+	}
+	if result.Buckets[1]["key"] != "_other_" || result.Buckets[1]["doc_count"] != int64(3) {
+		t.Errorf("unexpected other bucket: %v", result.Buckets[1])
+	}
+}
+
+func TestAggregations_Composite(t *testing.T) {
+	keys := func(result AggregationResult) []string {
+		var keys []string
+		for _, bucket := range result.Buckets {
+			key := bucket["key"].(map[string]interface{})
+			keys = append(keys, fmt.Sprintf("%v/%v:%d", key["tag"], key["price"], bucket["doc_count"]))
+		}
+		return keys
+	}
+
+	// Every combination of source values is a bucket, sorted by source;
+	// doc 3 has no price and is dropped
+	result := evaluateTestAggregation(t, `{"groups":{"composite":{"sources":[
+		{"tag":{"terms":{"field":"tag"}}},
+		{"price":{"histogram":{"field":"price","interval":20}}}]}}}`, nil)
+	if got := strings.Join(keys(result), " "); got != "a/0:1 a/20:1 b/0:1 c/40:1" {
+		t.Errorf("unexpected buckets: %s", got)
+	}
+
+	// A page is the first size keys after the after key, in source order
+	result = evaluateTestAggregation(t, `{"groups":{"composite":{"size":2,"after":{"tag":"a","price":0},"sources":[
+		{"tag":{"terms":{"field":"tag","order":"desc"}}},
+		{"price":{"histogram":{"field":"price","interval":20,"missing_bucket":true}}}]}}}`, nil)
+	if got := strings.Join(keys(result), " "); got != "a/20:1" {
+		t.Errorf("unexpected last page: %s", got)
+	}
+	result = evaluateTestAggregation(t, `{"groups":{"composite":{"size":2,"after":{"tag":"b","price":0},"sources":[
+		{"tag":{"terms":{"field":"tag","order":"desc"}}},
+		{"price":{"histogram":{"field":"price","interval":20,"missing_bucket":true}}}]}}}`, nil)
+	if got := strings.Join(keys(result), " "); got != "a/<nil>:1 a/0:1" {
+		t.Errorf("unexpected page: %s", got)
+	}
+
+	// Date keys are bucket start millis; sub-aggregations run per bucket
+	result = evaluateTestAggregation(t, `{"groups":{"composite":{"sources":[
+		{"month":{"date_histogram":{"field":"ts","calendar_interval":"month"}}}]},
+		"aggs":{"total":{"sum":{"field":"price"}}}}}`, nil)
+	if len(result.Buckets) != 2 {
+		t.Fatalf("expected 2 month buckets, got %v", result.Buckets)
+	}
+	first := result.Buckets[0]
+	if first["key"].(map[string]interface{})["month"] != float64(1704067200000) || first["doc_count"] != int64(2) {
+		t.Errorf("unexpected first month bucket: %v", first)
+	}
+	if sum := first["aggregations"].(map[string]AggregationResult)["total"].Sum; sum != 30.5 {
+		t.Errorf("expected a sum of 30.5, got %v", sum)
+	}
+}
+
+func TestAggregations_CompositeErrors(t *testing.T) {
+	for name, aggs := range map[string]string{
+		"no sources":     `{"a":{"composite":{}}}`,
+		"unknown source": `{"a":{"composite":{"sources":[{"x":{"avg":{"field":"x"}}}]}}}`,
+		"duplicate":      `{"a":{"composite":{"sources":[{"x":{"terms":{"field":"x"}}},{"x":{"terms":{"field":"y"}}}]}}}`,
+		"no interval":    `{"a":{"composite":{"sources":[{"x":{"histogram":{"field":"x"}}}]}}}`,
+		"bad order":      `{"a":{"composite":{"sources":[{"x":{"terms":{"field":"x","order":"up"}}}]}}}`,
+		"as sub-agg":     `{"a":{"terms":{"field":"x"},"aggs":{"b":{"composite":{"sources":[{"x":{"terms":{"field":"x"}}}]}}}}}`,
+	} {
+		if _, err := parseAggregations([]byte(aggs)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	for name, aggs := range map[string]string{
+		"partial after": `{"a":{"composite":{"after":{"y":"a"},"sources":[{"x":{"terms":{"field":"tag"}}}]}}}`,
+		"null after":    `{"a":{"composite":{"after":{"x":null},"sources":[{"x":{"terms":{"field":"tag"}}}]}}}`,
+		"zero size":     `{"a":{"composite":{"size":0,"sources":[{"x":{"terms":{"field":"tag"}}}]}}}`,
+	} {
+		specs, err := parseAggregations([]byte(aggs))
+		if err != nil {
+			t.Fatalf("%s: parseAggregations failed: %v", name, err)
+		}
+		if _, err := evaluateAggregations(specs, &aggContext{docs: aggTestDocs()}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestAggregations_Metrics(t *testing.T) {
+	stats := evaluateTestAggregation(t, `{"s":{"extended_stats":{"field":"price"}}}`, nil)
+	if stats.Count != 3 || stats.Min != 10 || stats.Max != 40 || stats.Sum != 70.5 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if math.Abs(stats.SumOfSquares-(100+420.25+1600)) > 1e-9 {
+		t.Errorf("unexpected sum of squares: %v", stats.SumOfSquares)
+	}
+	if stats.StdDeviation <= 0 || stats.StdDeviationBoundsUpper <= stats.Avg {
+		t.Errorf("unexpected deviation: %+v", stats)
+	}
+
+	cardinality := evaluateTestAggregation(t, `{"c":{"cardinality":{"field":"tag","precision_threshold":100}}}`, nil)
+	if cardinality.Value != 3 {
+		t.Errorf("expected cardinality 3, got %d", cardinality.Value)
+	}
+	// The sketch goes along for the coordinator to union
+	hll, err := sketch.UnmarshalHyperLogLog(cardinality.Sketch)
+	if err != nil || hll.Cardinality() != 3 || hll.Precision() != sketch.PrecisionFromThreshold(100) {
+		t.Errorf("unexpected cardinality sketch: %v", err)
+	}
+	if result := evaluateTestAggregation(t, `{"c":{"value_count":{"field":"tag"}}}`, nil); result.Count != 6 {
+		t.Errorf("expected value count 6, got %d", result.Count)
+	}
+
+	percentiles := evaluateTestAggregation(t, `{"p":{"percentiles":{"field":"price","percents":[0,50,100]}}}`, nil)
+	if percentiles.Values["0.0"] != 10 || percentiles.Values["50.0"] != 20.5 || percentiles.Values["100.0"] != 40 {
+		t.Errorf("unexpected percentiles: %v", percentiles.Values)
+	}
+	// The digest goes along for the coordinator to merge
+	digest, err := sketch.UnmarshalTDigest(percentiles.Sketch)
+	if err != nil || digest.Count() != 3 || digest.Quantile(0.5) != 20.5 {
+		t.Errorf("unexpected percentiles digest: %v", err)
+	}
+
+	ranks := evaluateTestAggregation(t, `{"r":{"percentile_ranks":{"field":"price","values":[5,20.5,30,40]}}}`, nil)
+	if ranks.Values["5.0"] != 0 || math.Abs(ranks.Values["20.5"]-200.0/3) > 1e-9 || ranks.Values["40.0"] != 100 {
+		t.Errorf("unexpected percentile ranks: %v", ranks.Values)
+	}
+	if ranks.Values["30.0"] <= ranks.Values["20.5"] || ranks.Values["30.0"] >= 100 {
+		t.Errorf("unexpected percentile rank of 30: %v", ranks.Values["30.0"])
+	}
+
+	for _, aggs := range []string{
+		`{"r":{"percentile_ranks":{"field":"price"}}}`,
+		`{"p":{"percentiles":{"field":"price","percents":[101]}}}`,
+		`{"p":{"percentiles":{"field":"price","tdigest":{"compression":0}}}}`,
+		`{"p":{"percentiles":{"field":"price","hdr":{"number_of_significant_value_digits":3}}}}`,
+	} {
+		specs, err := parseAggregations([]byte(aggs))
+		if err != nil {
+			t.Fatalf("parseAggregations failed: %v", err)
+		}
+		if _, err := evaluateAggregations(specs, &aggContext{docs: aggTestDocs()}); err == nil {
+			t.Errorf("expected error for %s", aggs)
+		}
+	}
+}
+
+func TestAggregations_SubAggregations(t *testing.T) {
+	result := evaluateTestAggregation(t, `{"tags":{"terms":{"field":"tag"},"aggs":{
+		"months":{"date_histogram":{"field":"ts","calendar_interval":"month"},"aggs":{"avg_price":{"avg":{"field":"price"}}}},
+		"max_price":{"max":{"field":"price"}}}}}`, nil)
+
+	if len(result.Buckets) != 3 || result.Buckets[0]["key"] != "a" {
+		t.Fatalf("unexpected buckets: %v", result.Buckets)
+	}
+	subAggs, ok := result.Buckets[0]["aggregations"].(map[string]AggregationResult)
+	if !ok {
+		t.Fatalf("expected sub-aggregations in bucket: %v", result.Buckets[0])
+	}
+	// Documents 0 and 1 carry the tag "a" and a price; document 3 has neither price nor ts
+	if subAggs["max_price"].Max != 20.5 {
+		t.Errorf("expected max price 20.5, got %+v", subAggs["max_price"])
+	}
+	months := subAggs["months"]
+	if len(months.Buckets) != 1 || months.Buckets[0]["doc_count"] != int64(2) {
+		t.Fatalf("unexpected month buckets: %v", months.Buckets)
+	}
+	avg := months.Buckets[0]["aggregations"].(map[string]AggregationResult)["avg_price"]
+	if avg.Avg != 15.25 || avg.Count != 2 {
+		t.Errorf("expected avg 15.25 over 2 values, got %+v", avg)
+	}
+}
+
+func TestAggregations_NestedFiltersRunQueryOnce(t *testing.T) {
+	calls := 0
+	ctx := &aggContext{
+		docs: aggTestDocs(),
+		matchQuery: func(query map[string]interface{}) (map[int]struct{}, error) {
+			calls++
+			return map[int]struct{}{0: {}, 2: {}}, nil
+		},
+	}
+	result := evaluateTestAggregation(t,
+		`{"tags":{"terms":{"field":"tag"},"aggs":{"f":{"filters":{"filters":{"x":{"match_all":{}}}}}}}}`, ctx)
+
+	if calls != 1 {
+		t.Errorf("expected the filter query to run once, ran %d times", calls)
+	}
+	// Bucket "a" holds documents 0, 1 and 3, of which only 0 matches
+	filters := result.Buckets[0]["aggregations"].(map[string]AggregationResult)["f"]
+	if filters.Buckets[0]["doc_count"] != int64(1) {
+		t.Errorf("unexpected filter bucket: %v", filters.Buckets[0])
+	}
+}
+
+func TestAggregations_ParseErrors(t *testing.T) {
+	for name, aggs := range map[string]string{
+		"unknown type":   `{"a":{"nope":{"field":"x"}}}`,
+		"missing field":  `{"a":{"terms":{}}}`,
+		"two types":      `{"a":{"terms":{"field":"x"},"avg":{"field":"x"}}}`,
+		"not an object":  `{"a":1}`,
+		"metric sub-agg": `{"a":{"avg":{"field":"x"},"aggs":{"b":{"max":{"field":"y"}}}}}`,
+		"bad sub-agg":    `{"a":{"terms":{"field":"x"},"aggs":{"b":{"nope":{}}}}}`,
+	} {
+		if _, err := parseAggregations([]byte(aggs)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
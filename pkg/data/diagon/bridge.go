@@ -9,10 +9,16 @@ package diagon
 import "C"
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"unsafe"
 
 	"go.uber.org/zap"
@@ -61,11 +67,15 @@ func (db *DiagonBridge) Start() error {
 func (db *DiagonBridge) Stop() error {
 	db.logger.Info("Stopping Diagon engine")
 
-	db.mu.Lock()
-	defer db.mu.Unlock()
-
-	// Close all shards
+	// Close all shards; closing removes them from the bridge
+	db.mu.RLock()
+	shards := make(map[string]*Shard, len(db.shards))
 	for path, shard := range db.shards {
+		shards[path] = shard
+	}
+	db.mu.RUnlock()
+
+	for path, shard := range shards {
 		db.logger.Info("Closing Diagon shard", zap.String("path", path))
 		if err := shard.Close(); err != nil {
 			db.logger.Error("Error closing shard", zap.String("path", path), zap.Error(err))
@@ -111,13 +121,24 @@ func (db *DiagonBridge) CreateShard(path string) (*Shard, error) {
 		return nil, fmt.Errorf("failed to create IndexWriter: %s", errMsg)
 	}
 
+	// Readers can only open a commit, and every write looks up its _id in
+	// one, so a new index starts with an empty commit
+	if !hasCommit(path) {
+		if !C.diagon_commit(writer) {
+			errMsg := C.GoString(C.diagon_last_error())
+			C.diagon_close_index_writer(writer)
+			C.diagon_close_directory(dir)
+			return nil, fmt.Errorf("failed to commit empty index: %s", errMsg)
+		}
+	}
+
 	shard := &Shard{
-		path:      path,
-		bridge:    db,
-		directory: dir,
-		writer:    writer,
-		reader:    nil, // Will be opened when needed
-		logger:    db.logger.With(zap.String("shard_path", path)),
+		path:        path,
+		bridge:      db,
+		directory:   dir,
+		writer:      writer,
+		unrefreshed: make(map[string]bool),
+		logger:      db.logger.With(zap.String("shard_path", path)),
 	}
 
 	db.shards[path] = shard
@@ -127,6 +148,20 @@ func (db *DiagonBridge) CreateShard(path string) (*Shard, error) {
 	return shard, nil
 }
 
+// hasCommit reports whether the index at path has a segments_N file
+func hasCommit(path string) bool {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "segments_") {
+			return true
+		}
+	}
+	return false
+}
+
 // GetShard retrieves an existing shard
 func (db *DiagonBridge) GetShard(path string) (*Shard, error) {
 	db.mu.RLock()
@@ -146,14 +181,166 @@ type Shard struct {
 	bridge    *DiagonBridge
 	directory C.DiagonDirectory
 	writer    C.DiagonIndexWriter
-	reader    C.DiagonIndexReader
-	searcher  C.DiagonIndexSearcher
 	logger    *zap.Logger
-	mu        sync.RWMutex
+	mu        sync.RWMutex // Guards the writer
+	analyzer  TextAnalyzer // Analyzes string fields and match query text (nil = whitespace)
+
+	// Most terms a prefix, wildcard or regexp query may expand to
+	maxExpansions int
+
+	// Fields mapped as sparse_vector, guarded by mu
+	sparseFields map[string]bool
+
+	// Vector graphs of the fields mapped as knn_vector, guarded by mu
+	knnFields map[string]*hnswIndex
+
+	// IDs written or deleted since the last refresh, mapped to whether the
+	// last operation left a live document. GetDocument uses it to stay
+	// real-time without refreshing on every call, and writes to tell creates
+	// from updates while the searcher lags behind.
+	unrefreshed map[string]bool
+
+	// Searcher shared by all queries until the next refresh swaps it
+	searcherMu sync.Mutex
+	current    *searcherRef
+	closed     bool
+	searches   sync.WaitGroup // Searchers handed out by acquireSearcher
+}
+
+// searcherRef is a reference-counted point-in-time view of the index.
+// Queries hold a reference while they run, so a refresh can swap in a new
+// searcher without closing one that is still in use.
+type searcherRef struct {
+	reader   C.DiagonIndexReader
+	searcher C.DiagonIndexSearcher
+	refs     int32
+}
+
+func (r *searcherRef) incRef() {
+	atomic.AddInt32(&r.refs, 1)
+}
+
+// decRef releases a reference and frees the searcher with the last one
+func (r *searcherRef) decRef() {
+	if atomic.AddInt32(&r.refs, -1) == 0 {
+		C.diagon_free_index_searcher(r.searcher)
+		C.diagon_close_index_reader(r.reader)
+	}
+}
+
+// SetAnalyzer sets the analyzer for string fields and match query text.
+// It must be called before the shard indexes or searches anything.
+func (s *Shard) SetAnalyzer(analyzer TextAnalyzer) {
+	s.analyzer = analyzer
+}
+
+// SetMaxExpansions limits the terms a prefix, wildcard or regexp query may
+// expand to, and caps fuzzy expansion. Zero restores DefaultMaxExpansions.
+func (s *Shard) SetMaxExpansions(n int) {
+	if n <= 0 {
+		n = DefaultMaxExpansions
+	}
+	s.maxExpansions = n
+}
+
+// SetSparseVectorFields sets the fields mapped as sparse_vector. Their values
+// must be objects of token weights; the tokens are indexed so neural_sparse
+// queries can find the documents holding them.
+func (s *Shard) SetSparseVectorFields(fields []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sparseFields = make(map[string]bool, len(fields))
+	for _, field := range fields {
+		s.sparseFields[field] = true
+	}
+}
+
+// knnDirName is the directory of a shard holding its vector graphs, one
+// per knn_vector field
+const knnDirName = "knn"
+
+// knnGraphPath returns where the vector graph of field is saved
+func (s *Shard) knnGraphPath(field string) string {
+	return filepath.Join(s.path, knnDirName, url.PathEscape(field)+".hnsw")
+}
+
+// SetKNNVectorFields sets the fields mapped as knn_vector. Their values must
+// be arrays of Dimension numbers, which are added to a per-field HNSW graph
+// searched by knn queries. Graphs saved by an earlier commit are loaded.
+func (s *Shard) SetKNNVectorFields(fields map[string]KNNField) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	knnFields := make(map[string]*hnswIndex, len(fields))
+	for name, field := range fields {
+		if err := field.validate(); err != nil {
+			return fmt.Errorf("invalid knn_vector field %s: %w", name, err)
+		}
+		if existing := s.knnFields[name]; existing != nil && existing.field == field {
+			knnFields[name] = existing
+			continue
+		}
+		index, err := loadHNSWIndex(s.knnGraphPath(name), field)
+		if err != nil {
+			return err
+		}
+		knnFields[name] = index
+	}
+	s.knnFields = knnFields
+	return nil
+}
+
+// saveKNNGraphs saves the vector graphs changed since they were last saved.
+// Callers hold mu and have just committed, so the graphs match the commit.
+func (s *Shard) saveKNNGraphs() error {
+	if len(s.knnFields) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Join(s.path, knnDirName), 0755); err != nil {
+		return fmt.Errorf("failed to create vector index directory: %w", err)
+	}
+	for name, index := range s.knnFields {
+		if err := index.save(s.knnGraphPath(name)); err != nil {
+			return fmt.Errorf("failed to save vector index of field %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// analyze runs text through the shard's analyzer for field
+func (s *Shard) analyze(field, text string) ([]Token, error) {
+	if s.analyzer == nil {
+		return whitespaceTokens(text), nil
+	}
+	tokens, err := s.analyzer(field, text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze field %s: %w", field, err)
+	}
+	return tokens, nil
 }
 
-// IndexDocument indexes a document using real Diagon IndexWriter
+// IndexDocument indexes a document using real Diagon IndexWriter, replacing
+// any previous version with the same _id
 func (s *Shard) IndexDocument(docID string, doc map[string]interface{}) error {
+	_, err := s.UpsertDocument(docID, doc)
+	return err
+}
+
+// LogFunc records a write once it is validated and before the IndexWriter
+// applies it, given whether a live document with its _id exists. The write
+// is not applied if it fails.
+type LogFunc func(exists bool) error
+
+// UpsertDocument indexes a document, hiding any previous version with the
+// same _id. It reports whether the document was newly created.
+func (s *Shard) UpsertDocument(docID string, doc map[string]interface{}) (bool, error) {
+	return s.UpsertDocumentLogged(docID, doc, nil)
+}
+
+// UpsertDocumentLogged is UpsertDocument, calling log (if not nil) before
+// the document is added
+func (s *Shard) UpsertDocumentLogged(docID string, doc map[string]interface{}, log LogFunc) (bool, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -181,6 +368,10 @@ func (s *Shard) IndexDocument(docID string, doc map[string]interface{}) error {
 	storedIDField := C.diagon_create_stored_field(cIDFieldName, cDocID)
 	C.diagon_document_add_field(diagonDoc, storedIDField)
 
+	// Vectors of knn_vector fields, added to their graphs once the
+	// document is indexed
+	vectors := make(map[string][]float32)
+
 	// Add other fields
 	for key, value := range doc {
 		cFieldName := C.CString(key)
@@ -191,14 +382,72 @@ func (s *Shard) IndexDocument(docID string, doc map[string]interface{}) error {
 			zap.String("type", fmt.Sprintf("%T", value)),
 			zap.Any("value", value))
 
+		if s.sparseFields[key] {
+			vector, err := parseSparseVector(value)
+			if err != nil {
+				return false, fmt.Errorf("failed to index field %s: %w", key, err)
+			}
+
+			// Index the tokens to find candidates; the weights are read
+			// back from the stored value when scoring
+			cTokens := C.CString(strings.Join(vector.tokens(), " "))
+			defer C.free(unsafe.Pointer(cTokens))
+			field := C.diagon_create_indexed_text_field(cFieldName, cTokens)
+			C.diagon_document_add_field(diagonDoc, field)
+
+			jsonBytes, err := json.Marshal(value)
+			if err != nil {
+				return false, fmt.Errorf("failed to store field %s: %w", key, err)
+			}
+			cValue := C.CString(string(jsonBytes))
+			defer C.free(unsafe.Pointer(cValue))
+			storedField := C.diagon_create_stored_field(cFieldName, cValue)
+			C.diagon_document_add_field(diagonDoc, storedField)
+			continue
+		}
+
+		if index := s.knnFields[key]; index != nil {
+			vector, err := parseKNNVector(value, index.field)
+			if err != nil {
+				return false, fmt.Errorf("failed to index field %s: %w", key, err)
+			}
+			vectors[key] = vector
+
+			jsonBytes, err := json.Marshal(value)
+			if err != nil {
+				return false, fmt.Errorf("failed to store field %s: %w", key, err)
+			}
+			cValue := C.CString(string(jsonBytes))
+			defer C.free(unsafe.Pointer(cValue))
+			storedField := C.diagon_create_stored_field(cFieldName, cValue)
+			C.diagon_document_add_field(diagonDoc, storedField)
+			continue
+		}
+
 		switch v := value.(type) {
 		case string:
-			// TextField for strings (analyzed, indexed, stored)
 			cValue := C.CString(v)
 			defer C.free(unsafe.Pointer(cValue))
-			field := C.diagon_create_text_field(cFieldName, cValue)
+			if s.analyzer == nil {
+				// TextField for strings (analyzed, indexed, stored)
+				field := C.diagon_create_text_field(cFieldName, cValue)
+				C.diagon_document_add_field(diagonDoc, field)
+				s.logger.Info("DEBUG: Created text field", zap.String("field", key))
+				break
+			}
+
+			// Index the analyzed terms, which TextField splits on
+			// whitespace, and store the original text
+			tokens, err := s.analyze(key, v)
+			if err != nil {
+				return false, err
+			}
+			cTerms := C.CString(strings.Join(tokenTerms(tokens), " "))
+			defer C.free(unsafe.Pointer(cTerms))
+			field := C.diagon_create_indexed_text_field(cFieldName, cTerms)
 			C.diagon_document_add_field(diagonDoc, field)
-			s.logger.Info("DEBUG: Created text field", zap.String("field", key))
+			storedField := C.diagon_create_stored_field(cFieldName, cValue)
+			C.diagon_document_add_field(diagonDoc, storedField)
 
 		case int, int32, int64:
 			// Create indexed numeric field for integers (searchable with range queries)
@@ -260,28 +509,119 @@ func (s *Shard) IndexDocument(docID string, doc map[string]interface{}) error {
 		}
 	}
 
-	// Add document to IndexWriter
-	s.logger.Info("Calling C.diagon_add_document", zap.String("doc_id", docID))
-	result := C.diagon_add_document(s.writer, diagonDoc)
-	s.logger.Info("C.diagon_add_document returned",
+	existed, err := s.liveLocked(docID)
+	if err != nil {
+		return false, err
+	}
+	created := !existed
+	if log != nil {
+		if err := log(existed); err != nil {
+			return false, err
+		}
+	}
+
+	if created {
+		// Nothing to replace, so skip the delete term entirely
+		if !C.diagon_add_document(s.writer, diagonDoc) {
+			errMsg := C.GoString(C.diagon_last_error())
+			s.logger.Error("C.diagon_add_document FAILED",
+				zap.String("doc_id", docID),
+				zap.String("error", errMsg))
+			return false, fmt.Errorf("failed to add document: %s", errMsg)
+		}
+	} else {
+		term := C.diagon_create_term(cIDFieldName, cDocID)
+		if term == nil {
+			errMsg := C.GoString(C.diagon_last_error())
+			return false, fmt.Errorf("failed to create term: %s", errMsg)
+		}
+		defer C.diagon_free_term(term)
+
+		// Replace document in IndexWriter. The delete by _id is buffered
+		// until the next flush and also hides a version still in the RAM
+		// buffer.
+		if !C.diagon_update_document(s.writer, term, diagonDoc) {
+			errMsg := C.GoString(C.diagon_last_error())
+			s.logger.Error("C.diagon_update_document FAILED",
+				zap.String("doc_id", docID),
+				zap.String("error", errMsg))
+			return false, fmt.Errorf("failed to add document: %s", errMsg)
+		}
+	}
+
+	for field, index := range s.knnFields {
+		if vector, ok := vectors[field]; ok {
+			index.upsert(docID, vector)
+		} else {
+			index.remove(docID)
+		}
+	}
+
+	s.unrefreshed[docID] = true
+
+	s.logger.Debug("Document added to IndexWriter RAM buffer",
 		zap.String("doc_id", docID),
-		zap.Bool("success", bool(result)))
+		zap.Int("fields", len(doc)),
+		zap.Bool("created", created))
+
+	return created, nil
+}
+
+// liveLocked reports whether a live document with the given _id exists,
+// counting writes not refreshed yet. Must be called with s.mu held.
+func (s *Shard) liveLocked(docID string) (bool, error) {
+	if live, ok := s.unrefreshed[docID]; ok {
+		return live, nil
+	}
+
+	ref, err := s.acquireSearcher()
+	if err != nil {
+		return false, err
+	}
+	defer s.releaseSearcher(ref)
 
-	if !result {
+	_, found, err := s.findDocument(ref, docID)
+	return found, err
+}
+
+// findDocument looks up the internal doc ID of the live document with the
+// given _id in a searcher
+func (s *Shard) findDocument(ref *searcherRef, docID string) (int, bool, error) {
+	cIDField := C.CString("_id")
+	defer C.free(unsafe.Pointer(cIDField))
+	cDocID := C.CString(docID)
+	defer C.free(unsafe.Pointer(cDocID))
+
+	term := C.diagon_create_term(cIDField, cDocID)
+	if term == nil {
 		errMsg := C.GoString(C.diagon_last_error())
-		s.logger.Error("C.diagon_add_document FAILED",
-			zap.String("doc_id", docID),
-			zap.String("error", errMsg))
-		return fmt.Errorf("failed to add document: %s", errMsg)
+		return 0, false, fmt.Errorf("failed to create term: %s", errMsg)
 	}
+	defer C.diagon_free_term(term)
 
-	s.logger.Info("Document added to IndexWriter RAM buffer (NOT YET COMMITTED)",
-		zap.String("doc_id", docID),
-		zap.Int("fields", len(doc)))
+	query := C.diagon_create_term_query(term)
+	if query == nil {
+		errMsg := C.GoString(C.diagon_last_error())
+		return 0, false, fmt.Errorf("failed to create query: %s", errMsg)
+	}
+	defer C.diagon_free_query(query)
 
-	s.logger.Warn("WARNING: Document is in RAM buffer but NOT committed to disk yet! Need to call Commit() or Flush()")
+	topDocs := C.diagon_search(ref.searcher, query, 1)
+	if topDocs == nil {
+		errMsg := C.GoString(C.diagon_last_error())
+		return 0, false, fmt.Errorf("search failed: %s", errMsg)
+	}
+	defer C.diagon_free_top_docs(topDocs)
 
-	return nil
+	if int64(C.diagon_top_docs_total_hits(topDocs)) == 0 {
+		return 0, false, nil
+	}
+
+	scoreDoc := C.diagon_top_docs_score_doc_at(topDocs, 0)
+	if scoreDoc == nil {
+		return 0, false, fmt.Errorf("failed to get score doc")
+	}
+	return int(C.diagon_score_doc_get_doc(scoreDoc)), true, nil
 }
 
 // Commit commits all pending changes
@@ -293,6 +633,9 @@ func (s *Shard) Commit() error {
 		errMsg := C.GoString(C.diagon_last_error())
 		return fmt.Errorf("commit failed: %s", errMsg)
 	}
+	if err := s.saveKNNGraphs(); err != nil {
+		return err
+	}
 
 	s.logger.Debug("Committed changes")
 	return nil
@@ -312,49 +655,116 @@ func (s *Shard) Flush() error {
 	return nil
 }
 
-// Refresh reopens the reader to see recent changes
+// Refresh commits pending changes and swaps in a new searcher that sees them.
+// Queries already running keep the previous searcher until they finish.
 func (s *Shard) Refresh() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Commit first to ensure changes are visible
+	return s.refreshLocked()
+}
+
+// refreshLocked implements Refresh. Must be called with s.mu held.
+func (s *Shard) refreshLocked() error {
+	if s.writer == nil {
+		return fmt.Errorf("shard is closed")
+	}
+
+	// Commit first so the new reader sees the changes
 	if !C.diagon_commit(s.writer) {
 		errMsg := C.GoString(C.diagon_last_error())
 		return fmt.Errorf("commit failed during refresh: %s", errMsg)
 	}
 
-	// Close old reader and searcher
-	if s.searcher != nil {
-		C.diagon_free_index_searcher(s.searcher)
-		s.searcher = nil
+	ref, err := s.openSearcher()
+	if err != nil {
+		return err
+	}
+
+	s.searcherMu.Lock()
+	if s.closed {
+		s.searcherMu.Unlock()
+		ref.decRef()
+		return fmt.Errorf("shard is closed")
 	}
-	if s.reader != nil {
-		C.diagon_close_index_reader(s.reader)
-		s.reader = nil
+	old := s.current
+	s.current = ref
+	s.searcherMu.Unlock()
+
+	if old != nil {
+		old.decRef()
 	}
+	s.unrefreshed = make(map[string]bool)
+
+	s.logger.Debug("Refreshed shard (swapped searcher)")
+	return nil
+}
 
-	// Open new reader
-	s.reader = C.diagon_open_index_reader(s.directory)
-	if s.reader == nil {
+// openSearcher opens a reader and searcher over the last commit. The
+// returned reference is owned by the caller.
+func (s *Shard) openSearcher() (*searcherRef, error) {
+	reader := C.diagon_open_index_reader(s.directory)
+	if reader == nil {
 		errMsg := C.GoString(C.diagon_last_error())
-		return fmt.Errorf("failed to reopen reader: %s", errMsg)
+		return nil, fmt.Errorf("failed to open reader: %s", errMsg)
 	}
 
-	// Create new searcher
-	s.searcher = C.diagon_create_index_searcher(s.reader)
-	if s.searcher == nil {
+	searcher := C.diagon_create_index_searcher(reader)
+	if searcher == nil {
 		errMsg := C.GoString(C.diagon_last_error())
-		return fmt.Errorf("failed to create searcher: %s", errMsg)
+		C.diagon_close_index_reader(reader)
+		return nil, fmt.Errorf("failed to create searcher: %s", errMsg)
 	}
 
-	s.logger.Debug("Refreshed shard (reopened reader)")
-	return nil
+	return &searcherRef{reader: reader, searcher: searcher, refs: 1}, nil
+}
+
+// acquireSearcher returns the current searcher with an extra reference that
+// the caller must give back with releaseSearcher. Until the first refresh the
+// searcher shows the last commit.
+func (s *Shard) acquireSearcher() (*searcherRef, error) {
+	s.searcherMu.Lock()
+	defer s.searcherMu.Unlock()
+
+	if s.closed {
+		return nil, fmt.Errorf("shard is closed")
+	}
+
+	if s.current == nil {
+		ref, err := s.openSearcher()
+		if err != nil {
+			return nil, err
+		}
+		s.current = ref
+	}
+
+	s.current.incRef()
+	s.searches.Add(1)
+	return s.current, nil
+}
+
+// releaseSearcher gives back a searcher obtained from acquireSearcher
+func (s *Shard) releaseSearcher(ref *searcherRef) {
+	ref.decRef()
+	s.searches.Done()
+}
+
+// NumDocs returns the number of live documents visible to searches
+func (s *Shard) NumDocs() (int64, error) {
+	ref, err := s.acquireSearcher()
+	if err != nil {
+		return 0, err
+	}
+	defer s.releaseSearcher(ref)
+
+	return int64(C.diagon_reader_num_docs(ref.reader)), nil
 }
 
 // convertQueryToDiagon converts a query object to a Diagon query
 // This is a helper function used by Search and for recursive bool query parsing
+// ref is the searcher the query will run on; match_phrase reads positions from it
 // Caller is responsible for freeing the returned query
-func (s *Shard) convertQueryToDiagon(queryObj map[string]interface{}) (C.DiagonQuery, error) {
+func (s *Shard) convertQueryToDiagon(ref *searcherRef, queryObj map[string]interface{}) (C.DiagonQuery, error) {
 	var diagonQuery C.DiagonQuery
 
 	// Handle different query types
@@ -390,38 +800,55 @@ func (s *Shard) convertQueryToDiagon(queryObj map[string]interface{}) (C.DiagonQ
 			}
 			break // Only support single term for now
 		}
-	} else if matchQuery, ok := queryObj["match"].(map[string]interface{}); ok {
-		// Match query: {"match": {"field_name": "query_text"}} or {"match": {"field_name": {"query": "text"}}}
-		// For now, treat match query as term query (no text analysis in Diagon Phase 4)
-		for field, value := range matchQuery {
-			cField := C.CString(field)
-			defer C.free(unsafe.Pointer(cField))
-
-			// Handle both simple and complex match query formats
-			var matchText string
-			switch v := value.(type) {
-			case string:
-				matchText = v
-			case map[string]interface{}:
-				if q, ok := v["query"].(string); ok {
-					matchText = q
-				}
-			default:
-				matchText = fmt.Sprintf("%v", v)
-			}
-
-			cValue := C.CString(matchText)
-			defer C.free(unsafe.Pointer(cValue))
-
-			term := C.diagon_create_term(cField, cValue)
-			defer C.diagon_free_term(term)
-
-			diagonQuery = C.diagon_create_term_query(term)
-			if diagonQuery == nil {
-				errMsg := C.GoString(C.diagon_last_error())
-				return nil, fmt.Errorf("failed to create match query: %s", errMsg)
-			}
-			break // Only support single field for now
+	} else if matchBody, ok := queryObj["match"].(map[string]interface{}); ok {
+		// Match query: {"match": {"field_name": "query_text"}} or
+		// {"match": {"field_name": {"query": "text", "operator": "and", "minimum_should_match": "75%"}}}
+		match, err := parseMatchQuery("match", matchBody)
+		if err != nil {
+			return nil, err
+		}
+		if diagonQuery, err = s.matchToDiagon(match); err != nil {
+			return nil, err
+		}
+	} else if phraseBody, ok := queryObj["match_phrase"].(map[string]interface{}); ok {
+		// Match phrase query: {"match_phrase": {"field_name": "query text"}} or
+		// {"match_phrase": {"field_name": {"query": "text", "slop": 1}}}
+		phrase, err := parseMatchQuery("match_phrase", phraseBody)
+		if err != nil {
+			return nil, err
+		}
+		if diagonQuery, err = s.phraseToDiagon(ref, phrase); err != nil {
+			return nil, err
+		}
+	} else if kind, body, ok := multiTermBody(queryObj); ok {
+		// Multi-term queries: {"prefix": {"field_name": "app"}},
+		// {"wildcard": {"field_name": "ki*y"}}, {"regexp": {"field_name": "k.*y"}},
+		// {"fuzzy": {"field_name": {"value": "quick", "fuzziness": "AUTO"}}}
+		multiTerm, err := parseMultiTermQuery(kind, body)
+		if err != nil {
+			return nil, err
+		}
+		if diagonQuery, err = s.multiTermToDiagon(ref, multiTerm); err != nil {
+			return nil, err
+		}
+	} else if sparseBody, ok := queryObj["neural_sparse"].(map[string]interface{}); ok {
+		// Sparse vector query: {"neural_sparse": {"field_name": {"query_tokens": {"token": 1.2}}}}
+		sparse, err := parseSparseQuery(sparseBody)
+		if err != nil {
+			return nil, err
+		}
+		if diagonQuery, err = s.sparseToDiagon(ref, sparse); err != nil {
+			return nil, err
+		}
+	} else if knnBody, ok := queryObj["knn"].(map[string]interface{}); ok {
+		// Dense vector query: {"knn": {"field": "field_name", "query_vector": [...], "k": 10,
+		// "num_candidates": 100, "filter": {...}}}
+		knn, err := parseKNNQuery(knnBody)
+		if err != nil {
+			return nil, err
+		}
+		if diagonQuery, err = s.knnToDiagon(ref, knn); err != nil {
+			return nil, err
 		}
 	} else if _, ok := queryObj["match_all"]; ok {
 		// Match all query: {"match_all": {}}
@@ -529,7 +956,7 @@ func (s *Shard) convertQueryToDiagon(queryObj map[string]interface{}) (C.DiagonQ
 				}
 
 				// Recursively parse sub-query
-				subQuery, err := s.convertQueryToDiagon(clauseMap)
+				subQuery, err := s.convertQueryToDiagon(ref, clauseMap)
 				if err != nil {
 					return nil, fmt.Errorf("failed to convert must sub-query: %w", err)
 				}
@@ -551,7 +978,7 @@ func (s *Shard) convertQueryToDiagon(queryObj map[string]interface{}) (C.DiagonQ
 					return nil, fmt.Errorf("clause must be an object")
 				}
 
-				subQuery, err := s.convertQueryToDiagon(clauseMap)
+				subQuery, err := s.convertQueryToDiagon(ref, clauseMap)
 				if err != nil {
 					return nil, fmt.Errorf("failed to convert should sub-query: %w", err)
 				}
@@ -573,7 +1000,7 @@ func (s *Shard) convertQueryToDiagon(queryObj map[string]interface{}) (C.DiagonQ
 					return nil, fmt.Errorf("clause must be an object")
 				}
 
-				subQuery, err := s.convertQueryToDiagon(clauseMap)
+				subQuery, err := s.convertQueryToDiagon(ref, clauseMap)
 				if err != nil {
 					return nil, fmt.Errorf("failed to convert filter sub-query: %w", err)
 				}
@@ -595,7 +1022,7 @@ func (s *Shard) convertQueryToDiagon(queryObj map[string]interface{}) (C.DiagonQ
 					return nil, fmt.Errorf("clause must be an object")
 				}
 
-				subQuery, err := s.convertQueryToDiagon(clauseMap)
+				subQuery, err := s.convertQueryToDiagon(ref, clauseMap)
 				if err != nil {
 					return nil, fmt.Errorf("failed to convert must_not sub-query: %w", err)
 				}
@@ -621,96 +1048,590 @@ func (s *Shard) convertQueryToDiagon(queryObj map[string]interface{}) (C.DiagonQ
 		for k := range queryObj {
 			queryTypes = append(queryTypes, k)
 		}
-		return nil, fmt.Errorf("unsupported query type: %v (currently supported: 'term', 'match', 'match_all', 'range', 'bool')", queryTypes)
+		return nil, fmt.Errorf("unsupported query type: %v (currently supported: 'term', 'match', 'match_phrase', 'prefix', 'wildcard', 'regexp', 'fuzzy', 'neural_sparse', 'knn', 'match_all', 'range', 'bool')", queryTypes)
 	}
 
 	return diagonQuery, nil
 }
 
-// Search executes a search query using real Diagon IndexSearcher
-func (s *Shard) Search(query []byte, filterExpression []byte) (*SearchResult, error) {
-	s.mu.Lock()
+// newTermQuery creates a Diagon term query for field:text
+func newTermQuery(field, text string) (C.DiagonQuery, error) {
+	cField := C.CString(field)
+	defer C.free(unsafe.Pointer(cField))
+	cValue := C.CString(text)
+	defer C.free(unsafe.Pointer(cValue))
 
-	// Commit any pending changes first to make them visible
-	if s.writer != nil {
-		if !C.diagon_commit(s.writer) {
-			s.mu.Unlock()
-			errMsg := C.GoString(C.diagon_last_error())
-			s.logger.Warn("Failed to commit before search", zap.String("error", errMsg))
-			// Continue anyway - might have no changes to commit
-		}
+	term := C.diagon_create_term(cField, cValue)
+	if term == nil {
+		errMsg := C.GoString(C.diagon_last_error())
+		return nil, fmt.Errorf("failed to create term: %s", errMsg)
+	}
+	defer C.diagon_free_term(term)
+
+	query := C.diagon_create_term_query(term)
+	if query == nil {
+		errMsg := C.GoString(C.diagon_last_error())
+		return nil, fmt.Errorf("failed to create term query: %s", errMsg)
+	}
+	return query, nil
+}
+
+// newTermsBoolQuery builds a bool query with one term query per text.
+// Clauses are must clauses if required is set, otherwise should clauses of
+// which at least minShouldMatch have to match.
+func newTermsBoolQuery(field string, texts []string, required bool, minShouldMatch int) (C.DiagonQuery, error) {
+	builder := C.diagon_create_bool_query()
+	if builder == nil {
+		errMsg := C.GoString(C.diagon_last_error())
+		return nil, fmt.Errorf("failed to create bool query: %s", errMsg)
 	}
 
-	// Always reopen reader/searcher to see latest changes
-	// Close existing reader/searcher if they exist
-	if s.searcher != nil {
-		C.diagon_free_index_searcher(s.searcher)
-		s.searcher = nil
+	for _, text := range texts {
+		termQuery, err := newTermQuery(field, text)
+		if err != nil {
+			C.diagon_free_query(C.diagon_bool_query_build(builder))
+			return nil, err
+		}
+		// Clauses are cloned into the bool query
+		if required {
+			C.diagon_bool_query_add_must(builder, termQuery)
+		} else {
+			C.diagon_bool_query_add_should(builder, termQuery)
+		}
+		C.diagon_free_query(termQuery)
 	}
-	if s.reader != nil {
-		C.diagon_close_index_reader(s.reader)
-		s.reader = nil
+	if !required {
+		C.diagon_bool_query_set_minimum_should_match(builder, C.int(minShouldMatch))
 	}
 
-	// Open fresh reader
-	s.reader = C.diagon_open_index_reader(s.directory)
-	if s.reader == nil {
-		s.mu.Unlock()
+	query := C.diagon_bool_query_build(builder)
+	if query == nil {
 		errMsg := C.GoString(C.diagon_last_error())
-		return nil, fmt.Errorf("failed to open reader: %s", errMsg)
+		return nil, fmt.Errorf("failed to build bool query: %s", errMsg)
 	}
+	return query, nil
+}
 
-	// Create fresh searcher
-	s.searcher = C.diagon_create_index_searcher(s.reader)
-	if s.searcher == nil {
-		s.mu.Unlock()
+// newMatchNoneQuery creates a query that matches no documents: a bool query
+// with only a must_not clause has nothing to select from
+func newMatchNoneQuery() (C.DiagonQuery, error) {
+	matchAll := C.diagon_create_match_all_query()
+	if matchAll == nil {
 		errMsg := C.GoString(C.diagon_last_error())
-		return nil, fmt.Errorf("failed to create searcher: %s", errMsg)
+		return nil, fmt.Errorf("failed to create match_all query: %s", errMsg)
 	}
+	defer C.diagon_free_query(matchAll)
 
-	s.mu.Unlock()
+	builder := C.diagon_create_bool_query()
+	if builder == nil {
+		errMsg := C.GoString(C.diagon_last_error())
+		return nil, fmt.Errorf("failed to create bool query: %s", errMsg)
+	}
+	C.diagon_bool_query_add_must_not(builder, matchAll)
 
-	// Parse query JSON
-	var queryObj map[string]interface{}
-	if err := json.Unmarshal(query, &queryObj); err != nil {
-		return nil, fmt.Errorf("failed to parse query: %w", err)
+	query := C.diagon_bool_query_build(builder)
+	if query == nil {
+		errMsg := C.GoString(C.diagon_last_error())
+		return nil, fmt.Errorf("failed to build bool query: %s", errMsg)
 	}
+	return query, nil
+}
 
-	// Convert to Diagon query
-	diagonQuery, err := s.convertQueryToDiagon(queryObj)
+// matchToDiagon converts a match query into a bool query over the analyzed
+// terms of its text. With operator "and" every term is required; otherwise
+// minimum_should_match of them (default 1) must match. Text without any
+// terms matches nothing.
+func (s *Shard) matchToDiagon(match *matchQuery) (C.DiagonQuery, error) {
+	tokens, err := s.analyze(match.field, match.text)
 	if err != nil {
 		return nil, err
 	}
-	defer C.diagon_free_query(diagonQuery)
+	terms := tokenTerms(tokens)
+	if len(terms) == 0 {
+		return newMatchNoneQuery()
+	}
+
+	if match.operator == "and" {
+		return newTermsBoolQuery(match.field, terms, true, 0)
+	}
+	minShouldMatch, err := minimumShouldMatch(match.minimumShouldMatch, len(terms))
+	if err != nil {
+		return nil, err
+	}
+	return newTermsBoolQuery(match.field, terms, false, minShouldMatch)
+}
+
+// phraseToDiagon converts a match_phrase query. Diagon postings carry no
+// positions, so the documents holding every term are found with a
+// conjunction and their stored text is re-analyzed to check the term
+// positions against the phrase and slop. The result is the conjunction,
+// which scores the match, filtered to the _ids of the documents that hold
+// the phrase.
+func (s *Shard) phraseToDiagon(ref *searcherRef, phrase *matchQuery) (C.DiagonQuery, error) {
+	tokens, err := s.analyze(phrase.field, phrase.text)
+	if err != nil {
+		return nil, err
+	}
+	terms := tokenTerms(tokens)
+	if len(terms) == 0 {
+		return newMatchNoneQuery()
+	}
+
+	candidates, err := newTermsBoolQuery(phrase.field, terms, true, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 1 {
+		// A single token is its own phrase
+		return candidates, nil
+	}
+	defer C.diagon_free_query(candidates)
+
+	if ref == nil {
+		return nil, fmt.Errorf("match_phrase query requires an open searcher")
+	}
+	docIDs, err := s.matchingDocIDs(ref, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	var matchedIDs []string
+	for _, docID := range docIDs {
+		values, err := s.storedFieldValues(ref, docID, []string{"_id", phrase.field})
+		if err != nil {
+			return nil, err
+		}
+		if len(values["_id"]) == 0 {
+			continue
+		}
+		for _, text := range values[phrase.field] {
+			docTokens, err := s.analyze(phrase.field, text)
+			if err != nil {
+				return nil, err
+			}
+			if phraseMatches(tokens, docTokens, phrase.slop) {
+				matchedIDs = append(matchedIDs, values["_id"][0])
+				break
+			}
+		}
+	}
+	if len(matchedIDs) == 0 {
+		return newMatchNoneQuery()
+	}
+
+	idFilter, err := newTermsBoolQuery("_id", matchedIDs, false, 1)
+	if err != nil {
+		return nil, err
+	}
+	defer C.diagon_free_query(idFilter)
+
+	builder := C.diagon_create_bool_query()
+	if builder == nil {
+		errMsg := C.GoString(C.diagon_last_error())
+		return nil, fmt.Errorf("failed to create bool query: %s", errMsg)
+	}
+	C.diagon_bool_query_add_must(builder, candidates)
+	C.diagon_bool_query_add_filter(builder, idFilter)
+
+	query := C.diagon_bool_query_build(builder)
+	if query == nil {
+		errMsg := C.GoString(C.diagon_last_error())
+		return nil, fmt.Errorf("failed to build bool query: %s", errMsg)
+	}
+	return query, nil
+}
+
+// multiTermBody returns the kind and body of a prefix, wildcard, regexp or
+// fuzzy query
+func multiTermBody(queryObj map[string]interface{}) (string, map[string]interface{}, bool) {
+	for _, kind := range []string{"prefix", "wildcard", "regexp", "fuzzy"} {
+		if body, ok := queryObj[kind].(map[string]interface{}); ok {
+			return kind, body, true
+		}
+	}
+	return "", nil, false
+}
+
+// multiTermToDiagon expands a multi-term query against the term dictionary
+// of the open searcher and matches any of the expanded terms. All matches
+// score the query's boost, as the expansion says nothing about relevance.
+func (s *Shard) multiTermToDiagon(ref *searcherRef, q *multiTermQuery) (C.DiagonQuery, error) {
+	if ref == nil {
+		return nil, fmt.Errorf("%s query requires an open searcher", q.kind)
+	}
+
+	var accepted []expansion
+	err := s.walkTerms(ref, q.field, func(term string) {
+		if ok, distance := q.accept(term); ok {
+			accepted = append(accepted, expansion{term: term, distance: distance})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	limit := s.maxExpansions
+	if limit <= 0 {
+		limit = DefaultMaxExpansions
+	}
+	terms, err := q.selectExpansions(accepted, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(terms) == 0 {
+		return newMatchNoneQuery()
+	}
+
+	disjunction, err := newTermsBoolQuery(q.field, terms, false, 1)
+	if err != nil {
+		return nil, err
+	}
+	defer C.diagon_free_query(disjunction)
+
+	query := C.diagon_create_constant_score_query(disjunction, C.float(q.boost))
+	if query == nil {
+		errMsg := C.GoString(C.diagon_last_error())
+		return nil, fmt.Errorf("failed to create constant score query: %s", errMsg)
+	}
+	return query, nil
+}
+
+// walkTerms calls fn with every term indexed for field, in term order
+func (s *Shard) walkTerms(ref *searcherRef, field string, fn func(term string)) error {
+	cField := C.CString(field)
+	defer C.free(unsafe.Pointer(cField))
+
+	termsEnum := C.diagon_reader_get_terms(ref.reader, cField)
+	if termsEnum == nil {
+		errMsg := C.GoString(C.diagon_last_error())
+		return fmt.Errorf("failed to read terms of field %s: %s", field, errMsg)
+	}
+	defer C.diagon_free_terms_enum(termsEnum)
+
+	buf := make([]byte, 65536)
+	for C.diagon_terms_enum_next(termsEnum) {
+		if !C.diagon_terms_enum_get_term(termsEnum, (*C.char)(unsafe.Pointer(&buf[0])), C.size_t(len(buf))) {
+			errMsg := C.GoString(C.diagon_last_error())
+			return fmt.Errorf("failed to read term of field %s: %s", field, errMsg)
+		}
+		fn(C.GoString((*C.char)(unsafe.Pointer(&buf[0]))))
+	}
+	return nil
+}
+
+// sparseToDiagon converts a neural_sparse query. The documents holding any
+// query token are found through the tokens indexed for the field, and their
+// stored vectors are scored against the query by Diagon's sparse index. The
+// result matches each document with a positive score by _id, scoring the dot
+// product times the boost.
+func (s *Shard) sparseToDiagon(ref *searcherRef, q *sparseQuery) (C.DiagonQuery, error) {
+	if ref == nil {
+		return nil, fmt.Errorf("neural_sparse query requires an open searcher")
+	}
+	tokens := q.tokens.tokens()
+	if len(tokens) == 0 {
+		return newMatchNoneQuery()
+	}
+
+	candidates, err := newTermsBoolQuery(q.field, tokens, false, 1)
+	if err != nil {
+		return nil, err
+	}
+	defer C.diagon_free_query(candidates)
+	docIDs, err := s.matchingDocIDs(ref, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := newSparseBatch()
+	var ids []string
+	for _, docID := range docIDs {
+		values, err := s.storedFieldValues(ref, docID, []string{"_id", q.field})
+		if err != nil {
+			return nil, err
+		}
+		if len(values["_id"]) == 0 || len(values[q.field]) == 0 {
+			continue
+		}
+		var raw interface{}
+		if err := json.Unmarshal([]byte(values[q.field][0]), &raw); err != nil {
+			return nil, fmt.Errorf("failed to read sparse vector of document %s: %w", values["_id"][0], err)
+		}
+		vector, err := parseSparseVector(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sparse vector of document %s: %w", values["_id"][0], err)
+		}
+		batch.add(vector)
+		ids = append(ids, values["_id"][0])
+	}
+
+	hits, err := scoreSparse(batch, q.tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	scores := make(map[string]float64, len(hits))
+	for _, hit := range hits {
+		scores[ids[hit.doc]] = float64(hit.score) * q.boost
+	}
+	return newScoredIDsQuery(scores)
+}
+
+// newScoredIDsQuery creates a query matching the documents with the given
+// _ids, each scoring its given score
+func newScoredIDsQuery(scores map[string]float64) (C.DiagonQuery, error) {
+	if len(scores) == 0 {
+		return newMatchNoneQuery()
+	}
+
+	// Each document matches exactly one clause, so it scores that clause
+	builder := C.diagon_create_bool_query()
+	if builder == nil {
+		errMsg := C.GoString(C.diagon_last_error())
+		return nil, fmt.Errorf("failed to create bool query: %s", errMsg)
+	}
+	for id, score := range scores {
+		idQuery, err := newTermQuery("_id", id)
+		if err != nil {
+			C.diagon_free_query(C.diagon_bool_query_build(builder))
+			return nil, err
+		}
+		scored := C.diagon_create_constant_score_query(idQuery, C.float(score))
+		C.diagon_free_query(idQuery)
+		if scored == nil {
+			errMsg := C.GoString(C.diagon_last_error())
+			C.diagon_free_query(C.diagon_bool_query_build(builder))
+			return nil, fmt.Errorf("failed to create constant score query: %s", errMsg)
+		}
+		C.diagon_bool_query_add_should(builder, scored)
+		C.diagon_free_query(scored)
+	}
+	C.diagon_bool_query_set_minimum_should_match(builder, 1)
+
+	query := C.diagon_bool_query_build(builder)
+	if query == nil {
+		errMsg := C.GoString(C.diagon_last_error())
+		return nil, fmt.Errorf("failed to build bool query: %s", errMsg)
+	}
+	return query, nil
+}
 
-	// Execute search
+// knnToDiagon converts a knn query. The field's graph yields the k nearest
+// documents, among those matching the filter if one is given, and the query
+// matches exactly those, each scoring its similarity to the query vector.
+// Documents indexed since the last refresh are not visible to the searcher,
+// so they do not match even if the graph returns them.
+func (s *Shard) knnToDiagon(ref *searcherRef, q *knnQuery) (C.DiagonQuery, error) {
+	if ref == nil {
+		return nil, fmt.Errorf("knn query requires an open searcher")
+	}
 	s.mu.RLock()
-	topDocs := C.diagon_search(s.searcher, diagonQuery, 10)
+	index := s.knnFields[q.field]
 	s.mu.RUnlock()
+	if index == nil {
+		return nil, fmt.Errorf("field [%s] of knn query is not mapped as knn_vector", q.field)
+	}
+	vector, err := parseKNNVector(q.vector, index.field)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query_vector in knn query for field [%s]: %w", q.field, err)
+	}
 
-	if topDocs == nil {
+	var allowed map[string]bool
+	if q.filter != nil {
+		filter, err := s.convertQueryToDiagon(ref, q.filter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter in knn query for field [%s]: %w", q.field, err)
+		}
+		defer C.diagon_free_query(filter)
+		docIDs, err := s.matchingDocIDs(ref, filter)
+		if err != nil {
+			return nil, err
+		}
+		allowed = make(map[string]bool, len(docIDs))
+		for _, docID := range docIDs {
+			values, err := s.storedFieldValues(ref, docID, []string{"_id"})
+			if err != nil {
+				return nil, err
+			}
+			if len(values["_id"]) > 0 {
+				allowed[values["_id"][0]] = true
+			}
+		}
+	}
+
+	hits := index.search(vector, q.k, q.numCandidates, allowed)
+	scores := make(map[string]float64, len(hits))
+	for _, hit := range hits {
+		scores[hit.id] = knnScore(index.field.SpaceType, hit.distance) * q.boost
+	}
+	return newScoredIDsQuery(scores)
+}
+
+// sparseHit is a document of a sparseBatch scored against a query
+type sparseHit struct {
+	doc   int
+	score float32
+}
+
+// scoreSparse builds a Diagon sparse index over the batch and returns the
+// documents with a positive dot product with query, best first
+func scoreSparse(batch *sparseBatch, query SparseVector) ([]sparseHit, error) {
+	indices, values := batch.encode(query)
+	if len(indices) == 0 || batch.numDocs() == 0 {
+		return nil, nil
+	}
+
+	index := C.diagon_create_sparse_index(
+		(*C.uint32_t)(unsafe.Pointer(&batch.indptr[0])),
+		(*C.uint32_t)(unsafe.Pointer(&batch.indices[0])),
+		(*C.float)(unsafe.Pointer(&batch.values[0])),
+		C.int(batch.numDocs()))
+	if index == nil {
 		errMsg := C.GoString(C.diagon_last_error())
-		return nil, fmt.Errorf("search failed: %s", errMsg)
+		return nil, fmt.Errorf("failed to build sparse index: %s", errMsg)
+	}
+	defer C.diagon_free_sparse_index(index)
+
+	k := batch.numDocs()
+	docs := make([]C.int, k)
+	scores := make([]C.float, k)
+	n := C.diagon_sparse_index_search(index,
+		(*C.uint32_t)(unsafe.Pointer(&indices[0])),
+		(*C.float)(unsafe.Pointer(&values[0])),
+		C.int(len(indices)), C.int(k), &docs[0], &scores[0])
+	if n < 0 {
+		errMsg := C.GoString(C.diagon_last_error())
+		return nil, fmt.Errorf("sparse search failed: %s", errMsg)
 	}
-	defer C.diagon_free_top_docs(topDocs)
 
-	// Extract results
-	totalHits := int64(C.diagon_top_docs_total_hits(topDocs))
-	maxScore := float64(C.diagon_top_docs_max_score(topDocs))
-	numResults := int(C.diagon_top_docs_score_docs_length(topDocs))
+	hits := make([]sparseHit, n)
+	for i := range hits {
+		hits[i] = sparseHit{doc: int(docs[i]), score: float32(scores[i])}
+	}
+	return hits, nil
+}
 
-	hits := make([]*Hit, 0, numResults)
-	for i := 0; i < numResults; i++ {
-		scoreDoc := C.diagon_top_docs_score_doc_at(topDocs, C.int(i))
-		if scoreDoc == nil {
-			continue
+// DefaultSearchSize is the number of hits returned when no size is given
+const DefaultSearchSize = 10
+
+// Search executes a search query using real Diagon IndexSearcher and returns
+// the top DefaultSearchSize hits
+func (s *Shard) Search(query []byte, filterExpression []byte) (*SearchResult, error) {
+	return s.SearchPage(query, filterExpression, nil, nil, 0, DefaultSearchSize)
+}
+
+// SearchPage executes a search query and returns hits [from, from+size) of
+// the ranking. TotalHits always counts every match. If filterExpression
+// holds a serialized expression tree, only matches it accepts are returned
+// and counted. If aggs holds the aggs section of the request, it is
+// evaluated over every matching document. If sortClauses is not empty, hits
+// are ordered by those keys instead of score and carry their sort values.
+func (s *Shard) SearchPage(query []byte, filterExpression []byte, aggs []byte, sortClauses []string, from, size int) (*SearchResult, error) {
+	if from < 0 || size < 0 {
+		return nil, fmt.Errorf("from and size must not be negative")
+	}
+
+	var sortSpecs []*sortSpec
+	if len(sortClauses) > 0 {
+		var err error
+		if sortSpecs, err = parseSortClauses(sortClauses); err != nil {
+			return nil, err
+		}
+	}
+
+	var aggSpecs []*aggSpec
+	if len(aggs) > 0 {
+		var err error
+		if aggSpecs, err = parseAggregations(aggs); err != nil {
+			return nil, err
+		}
+	}
+
+	var filter *expressionFilter
+	if len(filterExpression) > 0 {
+		var err error
+		if filter, err = newExpressionFilter(filterExpression); err != nil {
+			return nil, err
+		}
+	}
+
+	// Search the state of the last refresh without blocking on the writer
+	ref, err := s.acquireSearcher()
+	if err != nil {
+		return nil, err
+	}
+	defer s.releaseSearcher(ref)
+
+	// Parse query JSON
+	var queryObj map[string]interface{}
+	if err := json.Unmarshal(query, &queryObj); err != nil {
+		return nil, fmt.Errorf("failed to parse query: %w", err)
+	}
+
+	// Convert to Diagon query
+	diagonQuery, err := s.convertQueryToDiagon(ref, queryObj)
+	if err != nil {
+		return nil, err
+	}
+	defer C.diagon_free_query(diagonQuery)
+
+	var ranked []scoredDoc
+	var totalHits int64
+	var maxScore float64
+	// allRanked is set when ranked holds every (accepted) match
+	allRanked := filter != nil || len(sortSpecs) > 0
+	switch {
+	case filter != nil:
+		// The filter runs after the search, so every match is ranked and
+		// checked before the page is cut
+		ranked, err = s.filteredDocs(ref, diagonQuery, filter)
+	case len(sortSpecs) > 0:
+		// Any match may sort first, so every match is collected
+		ranked, err = s.allMatches(ref, diagonQuery)
+	default:
+		// Collect the top from+size hits; at least one so total hits are counted
+		numToCollect := from + size
+		if numToCollect < 1 {
+			numToCollect = 1
+		}
+		ranked, totalHits, maxScore, err = s.searchRanked(ref, diagonQuery, numToCollect)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if allRanked {
+		totalHits = int64(len(ranked))
+		if len(ranked) > 0 {
+			maxScore = ranked[0].score
+		}
+	}
+
+	var sorted []sortedDoc
+	if len(sortSpecs) > 0 {
+		if sorted, err = s.sortedDocs(ref, ranked, sortSpecs); err != nil {
+			return nil, err
 		}
+	}
 
-		internalDocID := int(C.diagon_score_doc_get_doc(scoreDoc))
-		score := float64(C.diagon_score_doc_get_score(scoreDoc))
+	numResults := len(ranked)
+	end := from + size
+	if end > numResults {
+		end = numResults
+	}
+
+	hits := make([]*Hit, 0, max(end-from, 0))
+	for i := from; i < end; i++ {
+		internalDocID := ranked[i].doc
+		score := ranked[i].score
+		var sortValues []interface{}
+		if sorted != nil {
+			internalDocID = sorted[i].doc
+			score = sorted[i].score
+			sortValues = sorted[i].values
+		}
 
 		// Retrieve the actual document with all stored fields
-		doc, docIDString, err := s.getDocumentByInternalID(internalDocID)
+		doc, docIDString, err := s.getDocumentByInternalID(ref, internalDocID)
 		if err != nil {
 			s.logger.Warn("Failed to retrieve document fields",
 				zap.Int("internal_doc_id", internalDocID),
@@ -722,6 +1643,7 @@ func (s *Shard) Search(query []byte, filterExpression []byte) (*SearchResult, er
 				Source: map[string]interface{}{
 					"_internal_doc_id": internalDocID,
 				},
+				Sort: sortValues,
 			})
 			continue
 		}
@@ -730,6 +1652,7 @@ func (s *Shard) Search(query []byte, filterExpression []byte) (*SearchResult, er
 			ID:     docIDString,
 			Score:  score,
 			Source: doc,
+			Sort:   sortValues,
 		})
 	}
 
@@ -740,6 +1663,26 @@ func (s *Shard) Search(query []byte, filterExpression []byte) (*SearchResult, er
 		Hits:      hits,
 	}
 
+	if len(aggSpecs) > 0 {
+		var docIDs []int
+		if allRanked {
+			docIDs = make([]int, len(ranked))
+			for i, doc := range ranked {
+				docIDs[i] = doc.doc
+			}
+		} else {
+			docIDs, err = s.matchingDocIDs(ref, diagonQuery)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		result.Aggregations, err = s.computeAggregations(ref, docIDs, aggSpecs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	s.logger.Debug("Executed search via real Diagon IndexSearcher",
 		zap.Int64("total_hits", totalHits),
 		zap.Float64("max_score", maxScore),
@@ -748,15 +1691,218 @@ func (s *Shard) Search(query []byte, filterExpression []byte) (*SearchResult, er
 	return result, nil
 }
 
+// computeAggregations evaluates aggregations over the documents in docIDs.
+// Field values come from stored fields, so each document is loaded once.
+func (s *Shard) computeAggregations(ref *searcherRef, docIDs []int, specs []*aggSpec) (map[string]AggregationResult, error) {
+	fields := aggregationFields(specs)
+	docs := make([]aggDoc, 0, len(docIDs))
+	for _, docID := range docIDs {
+		values, err := s.storedFieldValues(ref, docID, fields)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, aggDoc{id: docID, fields: values})
+	}
+
+	ctx := &aggContext{
+		docs: docs,
+		matchQuery: func(queryObj map[string]interface{}) (map[int]struct{}, error) {
+			filter, err := s.convertQueryToDiagon(ref, queryObj)
+			if err != nil {
+				return nil, err
+			}
+			defer C.diagon_free_query(filter)
+
+			ids, err := s.matchingDocIDs(ref, filter)
+			if err != nil {
+				return nil, err
+			}
+			matches := make(map[int]struct{}, len(ids))
+			for _, id := range ids {
+				matches[id] = struct{}{}
+			}
+			return matches, nil
+		},
+		fieldTerms: func(field string) ([]string, error) {
+			all, err := s.convertQueryToDiagon(ref, map[string]interface{}{"match_all": map[string]interface{}{}})
+			if err != nil {
+				return nil, err
+			}
+			defer C.diagon_free_query(all)
+
+			ids, err := s.matchingDocIDs(ref, all)
+			if err != nil {
+				return nil, err
+			}
+			var terms []string
+			for _, id := range ids {
+				values, err := s.storedFieldValues(ref, id, []string{field})
+				if err != nil {
+					return nil, err
+				}
+				terms = append(terms, values[field]...)
+			}
+			return terms, nil
+		},
+	}
+
+	return evaluateAggregations(specs, ctx)
+}
+
+// scoredDoc is a ranked search match
+type scoredDoc struct {
+	doc   int // internal Diagon doc ID
+	score float64
+}
+
+// searchRanked returns the top n matches of query in rank order, with the
+// total number of matches and the best score
+func (s *Shard) searchRanked(ref *searcherRef, query C.DiagonQuery, n int) ([]scoredDoc, int64, float64, error) {
+	topDocs := C.diagon_search(ref.searcher, query, C.int(n))
+	if topDocs == nil {
+		errMsg := C.GoString(C.diagon_last_error())
+		return nil, 0, 0, fmt.Errorf("search failed: %s", errMsg)
+	}
+	defer C.diagon_free_top_docs(topDocs)
+
+	totalHits := int64(C.diagon_top_docs_total_hits(topDocs))
+	maxScore := float64(C.diagon_top_docs_max_score(topDocs))
+	numResults := int(C.diagon_top_docs_score_docs_length(topDocs))
+
+	ranked := make([]scoredDoc, 0, numResults)
+	for i := 0; i < numResults; i++ {
+		scoreDoc := C.diagon_top_docs_score_doc_at(topDocs, C.int(i))
+		if scoreDoc == nil {
+			continue
+		}
+		ranked = append(ranked, scoredDoc{
+			doc:   int(C.diagon_score_doc_get_doc(scoreDoc)),
+			score: float64(C.diagon_score_doc_get_score(scoreDoc)),
+		})
+	}
+	return ranked, totalHits, maxScore, nil
+}
+
+// allMatches ranks every live document matching query
+func (s *Shard) allMatches(ref *searcherRef, query C.DiagonQuery) ([]scoredDoc, error) {
+	maxDoc := int(C.diagon_reader_max_doc(ref.reader))
+	if maxDoc == 0 {
+		return nil, nil
+	}
+
+	ranked, _, _, err := s.searchRanked(ref, query, maxDoc)
+	return ranked, err
+}
+
+// matchingDocIDs returns the internal IDs of every live document matching query
+func (s *Shard) matchingDocIDs(ref *searcherRef, query C.DiagonQuery) ([]int, error) {
+	ranked, err := s.allMatches(ref, query)
+	if err != nil {
+		return nil, err
+	}
+
+	docIDs := make([]int, len(ranked))
+	for i, doc := range ranked {
+		docIDs[i] = doc.doc
+	}
+	return docIDs, nil
+}
+
+// filteredDocs ranks every match of query that the filter expression
+// accepts, evaluating it over the document's stored fields
+func (s *Shard) filteredDocs(ref *searcherRef, query C.DiagonQuery, filter *expressionFilter) ([]scoredDoc, error) {
+	ranked, err := s.allMatches(ref, query)
+	if err != nil {
+		return nil, err
+	}
+
+	accepted := ranked[:0]
+	for _, doc := range ranked {
+		values, err := s.storedFieldValues(ref, doc.doc, filter.fields)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := filter.matches(values)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate filter expression: %w", err)
+		}
+		if ok {
+			accepted = append(accepted, doc)
+		}
+	}
+	return accepted, nil
+}
+
+// sortedDocs orders ranked matches by the sort keys, reading field values
+// from stored fields
+func (s *Shard) sortedDocs(ref *searcherRef, ranked []scoredDoc, specs []*sortSpec) ([]sortedDoc, error) {
+	fields := sortFields(specs)
+	docs := make([]sortedDoc, len(ranked))
+	for i, doc := range ranked {
+		var values map[string][]string
+		if len(fields) > 0 {
+			var err error
+			if values, err = s.storedFieldValues(ref, doc.doc, fields); err != nil {
+				return nil, err
+			}
+		}
+
+		keys := make([]interface{}, len(specs))
+		for k, spec := range specs {
+			keys[k] = spec.keyValue(doc, values)
+		}
+		docs[i] = sortedDoc{scoredDoc: doc, values: keys}
+	}
+
+	sortDocs(docs, specs)
+	return docs, nil
+}
+
+// storedFieldValues reads the stored values of fields from a document. A
+// "<field>.keyword" falls back to the stored "<field>".
+func (s *Shard) storedFieldValues(ref *searcherRef, internalDocID int, fields []string) (map[string][]string, error) {
+	diagonDoc := C.diagon_reader_get_document(ref.reader, C.int(internalDocID))
+	if diagonDoc == nil {
+		errMsg := C.GoString(C.diagon_last_error())
+		return nil, fmt.Errorf("failed to retrieve document: %s", errMsg)
+	}
+	defer C.diagon_free_document(diagonDoc)
+
+	values := make(map[string][]string, len(fields))
+	buf := make([]byte, 65536)
+	for _, field := range fields {
+		value, ok := storedFieldValue(diagonDoc, field, buf)
+		if !ok && strings.HasSuffix(field, ".keyword") {
+			value, ok = storedFieldValue(diagonDoc, strings.TrimSuffix(field, ".keyword"), buf)
+		}
+		if ok {
+			values[field] = splitStoredValue(value)
+		}
+	}
+	return values, nil
+}
+
+// storedFieldValue reads one stored field of a document into buf
+func storedFieldValue(diagonDoc C.DiagonDocument, field string, buf []byte) (string, bool) {
+	cFieldName := C.CString(field)
+	defer C.free(unsafe.Pointer(cFieldName))
+
+	if !C.diagon_document_get_field_value(diagonDoc, cFieldName,
+		(*C.char)(unsafe.Pointer(&buf[0])), C.size_t(len(buf))) {
+		return "", false
+	}
+	n := bytes.IndexByte(buf, 0)
+	if n < 0 {
+		n = len(buf)
+	}
+	return string(buf[:n]), true
+}
+
 // getDocumentByInternalID retrieves a document's stored fields given its internal Diagon doc ID
 // Returns the document fields map and the document's _id string
-func (s *Shard) getDocumentByInternalID(internalDocID int) (map[string]interface{}, string, error) {
-	// Retrieve stored fields using reader
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
+func (s *Shard) getDocumentByInternalID(ref *searcherRef, internalDocID int) (map[string]interface{}, string, error) {
 	// Debug: Check reader's maxDoc
-	maxDoc := int(C.diagon_reader_max_doc(s.reader))
+	maxDoc := int(C.diagon_reader_max_doc(ref.reader))
 	s.logger.Info("Attempting to retrieve document",
 		zap.Int("internal_doc_id", internalDocID),
 		zap.Int("reader_max_doc", maxDoc))
@@ -765,7 +1911,7 @@ func (s *Shard) getDocumentByInternalID(internalDocID int) (map[string]interface
 		return nil, "", fmt.Errorf("internal docID %d >= maxDoc %d", internalDocID, maxDoc)
 	}
 
-	diagonDoc := C.diagon_reader_get_document(s.reader, C.int(internalDocID))
+	diagonDoc := C.diagon_reader_get_document(ref.reader, C.int(internalDocID))
 	if diagonDoc == nil {
 		errMsg := C.GoString(C.diagon_last_error())
 		return nil, "", fmt.Errorf("failed to retrieve document: %s", errMsg)
@@ -862,96 +2008,39 @@ func (s *Shard) getDocumentByInternalID(internalDocID int) (map[string]interface
 	return doc, docIDString, nil
 }
 
-// GetDocument retrieves a document by ID
+// GetDocument retrieves a document by ID. Gets are real-time: if the
+// document changed since the last refresh, the shard is refreshed first.
 func (s *Shard) GetDocument(docID string) (map[string]interface{}, error) {
-	s.logger.Info(">>>>>> GetDocument ENTRY", zap.String("doc_id", docID))
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
 	s.logger.Debug("GetDocument called", zap.String("doc_id", docID))
 
-	// Ensure reader and searcher are initialized
-	if s.reader == nil || s.searcher == nil {
-		s.logger.Info("Reader not initialized, opening now", zap.String("doc_id", docID))
-
-		// Commit first to ensure changes are visible
-		if !C.diagon_commit(s.writer) {
-			errMsg := C.GoString(C.diagon_last_error())
-			return nil, fmt.Errorf("commit failed: %s", errMsg)
-		}
-
-		// Open reader
-		s.reader = C.diagon_open_index_reader(s.directory)
-		if s.reader == nil {
-			errMsg := C.GoString(C.diagon_last_error())
-			return nil, fmt.Errorf("failed to open reader: %s", errMsg)
-		}
-
-		// Create searcher
-		s.searcher = C.diagon_create_index_searcher(s.reader)
-		if s.searcher == nil {
-			errMsg := C.GoString(C.diagon_last_error())
-			return nil, fmt.Errorf("failed to create searcher: %s", errMsg)
+	s.mu.Lock()
+	if _, ok := s.unrefreshed[docID]; ok {
+		if err := s.refreshLocked(); err != nil {
+			s.mu.Unlock()
+			return nil, err
 		}
-
-		s.logger.Info("Reader and searcher initialized successfully")
-	}
-
-	// Search for the document by _id field to get internal doc ID
-	s.logger.Info("STEP 1: Creating term for _id search")
-	cIDField := C.CString("_id")
-	defer C.free(unsafe.Pointer(cIDField))
-
-	cDocID := C.CString(docID)
-	defer C.free(unsafe.Pointer(cDocID))
-
-	term := C.diagon_create_term(cIDField, cDocID)
-	if term == nil {
-		errMsg := C.GoString(C.diagon_last_error())
-		s.logger.Error("FAILED at create term", zap.String("error", errMsg))
-		return nil, fmt.Errorf("failed to create term: %s", errMsg)
 	}
-	defer C.diagon_free_term(term)
+	s.mu.Unlock()
 
-	s.logger.Info("STEP 2: Creating term query")
-	query := C.diagon_create_term_query(term)
-	if query == nil {
-		errMsg := C.GoString(C.diagon_last_error())
-		s.logger.Error("FAILED at create query", zap.String("error", errMsg))
-		return nil, fmt.Errorf("failed to create query: %s", errMsg)
+	ref, err := s.acquireSearcher()
+	if err != nil {
+		return nil, err
 	}
-	defer C.diagon_free_query(query)
-
-	s.logger.Info("STEP 3: Executing search", zap.String("doc_id", docID))
+	defer s.releaseSearcher(ref)
 
-	// Search to find the internal doc ID
-	topDocs := C.diagon_search(s.searcher, query, 1)
-	if topDocs == nil {
-		errMsg := C.GoString(C.diagon_last_error())
-		s.logger.Error("FAILED at search", zap.String("error", errMsg))
-		return nil, fmt.Errorf("search failed: %s", errMsg)
+	// Search for the document by _id field to get internal doc ID
+	internalDocID, found, err := s.findDocument(ref, docID)
+	if err != nil {
+		return nil, err
 	}
-	defer C.diagon_free_top_docs(topDocs)
-
-	totalHits := int64(C.diagon_top_docs_total_hits(topDocs))
-	s.logger.Debug("Search completed", zap.Int64("total_hits", totalHits))
-
-	if totalHits == 0 {
+	if !found {
 		return nil, fmt.Errorf("document not found")
 	}
-
-	// Get internal doc ID from search result
-	scoreDoc := C.diagon_top_docs_score_doc_at(topDocs, 0)
-	if scoreDoc == nil {
-		return nil, fmt.Errorf("failed to get score doc")
-	}
-
-	internalDocID := int(C.diagon_score_doc_get_doc(scoreDoc))
 	s.logger.Debug("Found document", zap.Int("internal_doc_id", internalDocID))
 
 	// Retrieve stored fields using reader
 	s.logger.Info("CALLING diagon_reader_get_document", zap.Int("internal_doc_id", internalDocID))
-	diagonDoc := C.diagon_reader_get_document(s.reader, C.int(internalDocID))
+	diagonDoc := C.diagon_reader_get_document(ref.reader, C.int(internalDocID))
 	s.logger.Info("RETURNED from diagon_reader_get_document", zap.Bool("is_nil", diagonDoc == nil))
 	if diagonDoc == nil {
 		errMsg := C.GoString(C.diagon_last_error())
@@ -1035,34 +2124,88 @@ func (s *Shard) GetDocument(docID string) (map[string]interface{}, error) {
 	return doc, nil
 }
 
-// DeleteDocument deletes a document (not yet implemented in Phase 4)
-func (s *Shard) DeleteDocument(docID string) error {
-	// TODO: Implement when document deletion is available in Diagon
-	s.logger.Warn("Document deletion not yet implemented in Diagon Phase 4", zap.String("doc_id", docID))
-	return fmt.Errorf("document deletion not yet implemented in Diagon Phase 4")
+// DeleteDocument deletes the document with the given _id by clearing it in
+// the live docs of its segment. It reports whether a live document was found.
+func (s *Shard) DeleteDocument(docID string) (bool, error) {
+	return s.DeleteDocumentLogged(docID, nil)
+}
+
+// DeleteDocumentLogged is DeleteDocument, calling log (if not nil) before
+// the delete is buffered, also when there is no document to delete
+func (s *Shard) DeleteDocumentLogged(docID string, log LogFunc) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found, err := s.liveLocked(docID)
+	if err != nil {
+		return false, err
+	}
+	if log != nil {
+		if err := log(found); err != nil {
+			return false, err
+		}
+	}
+	if !found {
+		return false, nil
+	}
+
+	cIDField := C.CString("_id")
+	defer C.free(unsafe.Pointer(cIDField))
+	cDocID := C.CString(docID)
+	defer C.free(unsafe.Pointer(cDocID))
+
+	term := C.diagon_create_term(cIDField, cDocID)
+	if term == nil {
+		errMsg := C.GoString(C.diagon_last_error())
+		return false, fmt.Errorf("failed to create term: %s", errMsg)
+	}
+	defer C.diagon_free_term(term)
+
+	// The delete is buffered and applied to the segments at the next flush
+	if !C.diagon_delete_documents(s.writer, term) {
+		errMsg := C.GoString(C.diagon_last_error())
+		return false, fmt.Errorf("failed to delete document: %s", errMsg)
+	}
+	s.unrefreshed[docID] = false
+	for _, index := range s.knnFields {
+		index.remove(docID)
+	}
+
+	s.logger.Debug("Deleted document", zap.String("doc_id", docID), zap.Bool("found", found))
+
+	return found, nil
 }
 
-// Close closes the shard and frees all resources
+// Close closes the shard and frees all resources. It waits for running
+// queries to release their searchers first.
 func (s *Shard) Close() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Close searcher
-	if s.searcher != nil {
-		C.diagon_free_index_searcher(s.searcher)
-		s.searcher = nil
+	// Stop handing out searchers and drop the shard's own reference
+	s.searcherMu.Lock()
+	alreadyClosed := s.closed
+	s.closed = true
+	current := s.current
+	s.current = nil
+	s.searcherMu.Unlock()
+
+	if alreadyClosed {
+		return nil
 	}
 
-	// Close reader
-	if s.reader != nil {
-		C.diagon_close_index_reader(s.reader)
-		s.reader = nil
+	s.searches.Wait()
+	if current != nil {
+		current.decRef()
 	}
 
-	// Close writer
+	// Close writer, which commits, and save the vector graphs to match
 	if s.writer != nil {
 		C.diagon_close_index_writer(s.writer)
 		s.writer = nil
+		if err := s.saveKNNGraphs(); err != nil {
+			s.logger.Error("Failed to save vector indexes", zap.Error(err))
+		}
 	}
 
 	// Close directory
@@ -1071,6 +2214,16 @@ func (s *Shard) Close() error {
 		s.directory = nil
 	}
 
+	// The path can be opened again, for instance once recovery replaced
+	// its files
+	if s.bridge != nil {
+		s.bridge.mu.Lock()
+		if s.bridge.shards[s.path] == s {
+			delete(s.bridge.shards, s.path)
+		}
+		s.bridge.mu.Unlock()
+	}
+
 	s.logger.Info("Closed real Diagon shard")
 
 	return nil
@@ -1090,17 +2243,26 @@ type Hit struct {
 	ID     string                 `json:"_id"`
 	Score  float64                `json:"_score"`
 	Source map[string]interface{} `json:"_source"`
+	Sort   []interface{}          `json:"sort,omitempty"` // Sort values (nil entries are missing), set for sorted searches
 }
 
 // AggregationResult represents an aggregation result
 type AggregationResult struct {
-	Type    string                   `json:"type"`
-	Buckets []map[string]interface{} `json:"buckets,omitempty"`
-	Count   int64                    `json:"count,omitempty"`
-	Min     float64                  `json:"min,omitempty"`
-	Max     float64                  `json:"max,omitempty"`
-	Avg     float64                  `json:"avg,omitempty"`
-	Sum     float64                  `json:"sum,omitempty"`
-	Value   int64                    `json:"value,omitempty"`
-	Values  map[string]float64       `json:"values,omitempty"`
+	Type                    string                   `json:"type"`
+	Buckets                 []map[string]interface{} `json:"buckets,omitempty"`
+	Count                   int64                    `json:"count,omitempty"`
+	Min                     float64                  `json:"min,omitempty"`
+	Max                     float64                  `json:"max,omitempty"`
+	Avg                     float64                  `json:"avg,omitempty"`
+	Sum                     float64                  `json:"sum,omitempty"`
+	SumOfSquares            float64                  `json:"sum_of_squares,omitempty"`
+	Variance                float64                  `json:"variance,omitempty"`
+	StdDeviation            float64                  `json:"std_deviation,omitempty"`
+	StdDeviationBoundsUpper float64                  `json:"std_deviation_bounds_upper,omitempty"`
+	StdDeviationBoundsLower float64                  `json:"std_deviation_bounds_lower,omitempty"`
+	Value                   int64                    `json:"value,omitempty"`
+	Values                  map[string]float64       `json:"values,omitempty"`
+	Sketch                  []byte                   `json:"sketch,omitempty"`                      // Serialized t-digest of percentiles and percentile_ranks, or HyperLogLog++ of cardinality
+	DocCountErrorUpperBound int64                    `json:"doc_count_error_upper_bound,omitempty"` // Terms: most a term's count may be understated by the shard_size cut, -1 if unbounded
+	SumOtherDocCount        int64                    `json:"sum_other_doc_count,omitempty"`         // Terms: docs in the terms cut
 }
@@ -0,0 +1,287 @@
+package diagon
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/conjugate/conjugate/pkg/common/aggparams"
+	"github.com/conjugate/conjugate/pkg/common/dates"
+)
+
+// compositeSource is one value source of a composite aggregation's key
+type compositeSource struct {
+	name          string
+	kind          string // terms, histogram or date_histogram
+	field         string
+	desc          bool
+	missingBucket bool
+
+	interval float64        // histogram
+	offset   float64        // histogram
+	rounding dates.Rounding // date_histogram
+}
+
+// parseCompositeSources reads the sources of a composite aggregation: an
+// array of single-entry objects such as {"host": {"terms": {"field": "host"}}}
+func parseCompositeSources(name string, body map[string]interface{}) ([]compositeSource, error) {
+	raw, ok := body["sources"].([]interface{})
+	if !ok || len(raw) == 0 {
+		return nil, fmt.Errorf("composite aggregation [%s] requires [sources]", name)
+	}
+
+	sources := make([]compositeSource, 0, len(raw))
+	seen := make(map[string]struct{}, len(raw))
+	for _, entry := range raw {
+		obj, ok := entry.(map[string]interface{})
+		if !ok || len(obj) != 1 {
+			return nil, fmt.Errorf("each source of composite aggregation [%s] must be an object with a single named source", name)
+		}
+		for sourceName, def := range obj {
+			if _, dup := seen[sourceName]; dup {
+				return nil, fmt.Errorf("composite aggregation [%s] has duplicate source [%s]", name, sourceName)
+			}
+			seen[sourceName] = struct{}{}
+
+			defMap, _ := def.(map[string]interface{})
+			if len(defMap) != 1 {
+				return nil, fmt.Errorf("source [%s] of composite aggregation [%s] must define a single type", sourceName, name)
+			}
+			for kind, sourceBody := range defMap {
+				bodyMap, _ := sourceBody.(map[string]interface{})
+				source, err := parseCompositeSource(sourceName, kind, bodyMap)
+				if err != nil {
+					return nil, fmt.Errorf("source [%s] of composite aggregation [%s]: %w", sourceName, name, err)
+				}
+				sources = append(sources, source)
+			}
+		}
+	}
+	return sources, nil
+}
+
+func parseCompositeSource(name, kind string, body map[string]interface{}) (compositeSource, error) {
+	source := compositeSource{name: name, kind: kind}
+	source.field, _ = body["field"].(string)
+	if source.field == "" {
+		return source, fmt.Errorf("[field] is required")
+	}
+	switch order, _ := body["order"].(string); order {
+	case "", "asc":
+	case "desc":
+		source.desc = true
+	default:
+		return source, fmt.Errorf("unknown order [%s]", order)
+	}
+	source.missingBucket, _ = body["missing_bucket"].(bool)
+
+	switch kind {
+	case "terms":
+	case "histogram":
+		source.interval = floatParam(body, "interval", 0)
+		if source.interval <= 0 {
+			return source, fmt.Errorf("[interval] must be greater than 0")
+		}
+		source.offset = floatParam(body, "offset", 0)
+	case "date_histogram":
+		rounding, err := dates.ParseRounding(body)
+		if err != nil {
+			return source, err
+		}
+		source.rounding = rounding
+	default:
+		return source, fmt.Errorf("unsupported source type [%s]", kind)
+	}
+	return source, nil
+}
+
+// values returns the distinct key values a doc has for the source: term
+// strings, histogram bucket keys, or date_histogram bucket start millis
+func (s compositeSource) values(doc aggDoc) []interface{} {
+	var values []interface{}
+	seen := make(map[interface{}]struct{})
+	add := func(v interface{}) {
+		if _, ok := seen[v]; !ok {
+			seen[v] = struct{}{}
+			values = append(values, v)
+		}
+	}
+	switch s.kind {
+	case "terms":
+		for _, value := range doc.fields[s.field] {
+			add(termKey(value))
+		}
+	case "histogram":
+		for _, value := range numericValues(doc, s.field) {
+			add(math.Floor((value-s.offset)/s.interval)*s.interval + s.offset)
+		}
+	case "date_histogram":
+		for _, value := range doc.fields[s.field] {
+			if t, ok := parseDateValue(value); ok {
+				add(float64(s.rounding.Round(t).UnixMilli()))
+			}
+		}
+	}
+	return values
+}
+
+// afterValue reads the source's value of an after key: a string for terms,
+// a number for histograms and dates (which may also be given as a date
+// string), or null for the missing bucket
+func (s compositeSource) afterValue(raw interface{}) (interface{}, error) {
+	if raw == nil {
+		if !s.missingBucket {
+			return nil, fmt.Errorf("after key of source [%s] is null but missing_bucket is not set", s.name)
+		}
+		return nil, nil
+	}
+	if s.kind == "terms" {
+		if key, ok := termValueKey(raw); ok {
+			return key, nil
+		}
+	} else {
+		switch v := raw.(type) {
+		case float64:
+			return v, nil
+		case string:
+			if t, ok := dates.Parse(v, s.rounding.Location()); ok && s.kind == "date_histogram" {
+				return float64(t.UnixMilli()), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("invalid after key [%v] for source [%s]", raw, s.name)
+}
+
+// compareCompositeKeys orders composite keys source by source. A missing
+// value sorts before any other, so first in ascending and last in
+// descending order.
+func compareCompositeKeys(sources []compositeSource, a, b []interface{}) int {
+	for i, source := range sources {
+		c := compareCompositeValues(a[i], b[i])
+		if source.desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func compareCompositeValues(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	fa, aNum := a.(float64)
+	fb, bNum := b.(float64)
+	switch {
+	case aNum && bNum:
+		return compareFloats(fa, fb)
+	case aNum:
+		return -1
+	case bNum:
+		return 1
+	}
+	return compareTermKeys(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// composite buckets docs by every combination of their source values. A
+// page is the first size keys in source order after the after key; the
+// coordinator merges the shards' pages, whose first size keys are exact.
+func (a *aggSpec) composite(ctx *aggContext, docs []aggDoc) (AggregationResult, error) {
+	size := intParam(a.body, "size", aggparams.DefaultCompositeSize)
+	if size <= 0 {
+		return AggregationResult{}, fmt.Errorf("[size] of composite aggregation [%s] must be greater than 0", a.name)
+	}
+
+	var after []interface{}
+	if raw, ok := a.body["after"]; ok {
+		afterMap, ok := raw.(map[string]interface{})
+		if !ok || len(afterMap) != len(a.sources) {
+			return AggregationResult{}, fmt.Errorf("[after] of composite aggregation [%s] must have a value for each source", a.name)
+		}
+		after = make([]interface{}, len(a.sources))
+		for i, source := range a.sources {
+			value, ok := afterMap[source.name]
+			if !ok {
+				return AggregationResult{}, fmt.Errorf("[after] of composite aggregation [%s] has no value for source [%s]", a.name, source.name)
+			}
+			parsed, err := source.afterValue(value)
+			if err != nil {
+				return AggregationResult{}, fmt.Errorf("composite aggregation [%s]: %w", a.name, err)
+			}
+			after[i] = parsed
+		}
+	}
+
+	keys := make(map[string][]interface{})
+	bucketDocs := make(map[string][]aggDoc)
+	for _, doc := range docs {
+		combos := [][]interface{}{nil}
+		for _, source := range a.sources {
+			values := source.values(doc)
+			if len(values) == 0 {
+				if !source.missingBucket {
+					combos = nil
+					break
+				}
+				values = []interface{}{nil}
+			}
+			next := make([][]interface{}, 0, len(combos)*len(values))
+			for _, combo := range combos {
+				for _, value := range values {
+					key := make([]interface{}, len(combo), len(combo)+1)
+					copy(key, combo)
+					next = append(next, append(key, value))
+				}
+			}
+			combos = next
+		}
+
+		for _, combo := range combos {
+			if after != nil && compareCompositeKeys(a.sources, combo, after) <= 0 {
+				continue
+			}
+			encoded, err := json.Marshal(combo)
+			if err != nil {
+				return AggregationResult{}, err
+			}
+			id := string(encoded)
+			if _, ok := keys[id]; !ok {
+				keys[id] = combo
+			}
+			bucketDocs[id] = append(bucketDocs[id], doc)
+		}
+	}
+
+	ids := make([]string, 0, len(keys))
+	for id := range keys {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return compareCompositeKeys(a.sources, keys[ids[i]], keys[ids[j]]) < 0
+	})
+	if len(ids) > size {
+		ids = ids[:size]
+	}
+
+	buckets := make([]map[string]interface{}, 0, len(ids))
+	for _, id := range ids {
+		key := make(map[string]interface{}, len(a.sources))
+		for i, source := range a.sources {
+			key[source.name] = keys[id][i]
+		}
+		bucket, err := a.newBucket(ctx, key, bucketDocs[id])
+		if err != nil {
+			return AggregationResult{}, err
+		}
+		buckets = append(buckets, bucket)
+	}
+	return AggregationResult{Type: a.aggType, Buckets: buckets}, nil
+}
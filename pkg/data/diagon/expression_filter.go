@@ -0,0 +1,43 @@
+package diagon
+
+import (
+	"fmt"
+
+	"github.com/conjugate/conjugate/pkg/coordination/expressions"
+)
+
+// expressionFilter post-filters candidate documents with a serialized
+// expression tree (SearchRequest.filter_expression) sent by the coordinator
+type expressionFilter struct {
+	evaluator *expressions.Evaluator
+	fields    []string // stored fields the expression reads
+}
+
+// newExpressionFilter decodes a serialized filter expression
+func newExpressionFilter(data []byte) (*expressionFilter, error) {
+	expr, err := expressions.NewDeserializer().Deserialize(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter expression: %w", err)
+	}
+	if expr.DataType() != expressions.DataTypeBool {
+		return nil, fmt.Errorf("filter expression must return bool, got %s", expr.DataType())
+	}
+
+	return &expressionFilter{
+		evaluator: expressions.NewEvaluator(expr),
+		fields:    expressions.Fields(expr),
+	}, nil
+}
+
+// matches reports whether a document passes the filter. values holds the
+// document's stored values per field; a multi-valued field is evaluated
+// with its first value.
+func (f *expressionFilter) matches(values map[string][]string) (bool, error) {
+	return f.evaluator.EvaluateBool(func(path string) (interface{}, bool) {
+		fieldValues := values[path]
+		if len(fieldValues) == 0 {
+			return nil, false
+		}
+		return fieldValues[0], true
+	})
+}
@@ -0,0 +1,656 @@
+package diagon
+
+import (
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Space types a knn_vector field can be searched by
+const (
+	SpaceTypeL2           = "l2"
+	SpaceTypeCosine       = "cosinesimil"
+	SpaceTypeInnerProduct = "innerproduct"
+)
+
+const (
+	// maxKNNDimension is the largest dimension a knn_vector field may have
+	maxKNNDimension = 4096
+
+	// maxKNNCandidates caps num_candidates of a knn query
+	maxKNNCandidates = 10000
+
+	// defaultKNNK is the number of neighbors a knn query returns unless it
+	// sets k
+	defaultKNNK = 10
+
+	// HNSW construction parameters: links per node on upper layers (twice
+	// that on the base layer) and the candidate list size used for inserts
+	hnswM              = 16
+	hnswEfConstruction = 100
+
+	hnswFileMagic   = 0x57534e48 // "HNSW"
+	hnswFileVersion = 1
+)
+
+// KNNField describes a field mapped as knn_vector
+type KNNField struct {
+	Dimension int
+	SpaceType string // l2 (default), cosinesimil or innerproduct
+}
+
+// validate checks the field's dimension and space type, defaulting the
+// space type to l2
+func (f *KNNField) validate() error {
+	if f.Dimension <= 0 || f.Dimension > maxKNNDimension {
+		return fmt.Errorf("knn_vector dimension must be between 1 and %d, got %d", maxKNNDimension, f.Dimension)
+	}
+	switch f.SpaceType {
+	case "":
+		f.SpaceType = SpaceTypeL2
+	case SpaceTypeL2, SpaceTypeCosine, SpaceTypeInnerProduct:
+	default:
+		return fmt.Errorf("unsupported knn_vector space_type [%s]", f.SpaceType)
+	}
+	return nil
+}
+
+// parseKNNVector validates a knn_vector value: an array of dimension numbers.
+// Cosine similarity is undefined for the zero vector, so it is rejected.
+func parseKNNVector(value interface{}, field KNNField) ([]float32, error) {
+	values, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("knn_vector value must be an array of numbers, got %T", value)
+	}
+	if len(values) != field.Dimension {
+		return nil, fmt.Errorf("knn_vector has dimension %d, expected %d", len(values), field.Dimension)
+	}
+
+	vector := make([]float32, len(values))
+	var norm float64
+	for i, raw := range values {
+		v, ok := raw.(float64)
+		if !ok || math.IsInf(v, 0) || math.IsNaN(v) {
+			return nil, fmt.Errorf("knn_vector element %d must be a finite number", i)
+		}
+		vector[i] = float32(v)
+		norm += v * v
+	}
+	if field.SpaceType == SpaceTypeCosine && norm == 0 {
+		return nil, fmt.Errorf("knn_vector of space_type cosinesimil must not be the zero vector")
+	}
+	return vector, nil
+}
+
+// knnDistance returns how far apart two vectors are in space; lower is
+// closer. l2 uses the squared euclidean distance, cosinesimil one minus the
+// cosine and innerproduct the negated dot product.
+func knnDistance(space string, a, b []float32) float32 {
+	switch space {
+	case SpaceTypeCosine:
+		var dot, na, nb float32
+		for i := range a {
+			dot += a[i] * b[i]
+			na += a[i] * a[i]
+			nb += b[i] * b[i]
+		}
+		if na == 0 || nb == 0 {
+			return 1
+		}
+		return 1 - dot/float32(math.Sqrt(float64(na))*math.Sqrt(float64(nb)))
+	case SpaceTypeInnerProduct:
+		var dot float32
+		for i := range a {
+			dot += a[i] * b[i]
+		}
+		return -dot
+	default:
+		var sum float32
+		for i := range a {
+			d := a[i] - b[i]
+			sum += d * d
+		}
+		return sum
+	}
+}
+
+// knnScore turns a distance into a positive score that grows as the
+// distance shrinks, so hits rank the same by either
+func knnScore(space string, distance float32) float64 {
+	d := float64(distance)
+	switch space {
+	case SpaceTypeCosine:
+		return (2 - d) / 2
+	case SpaceTypeInnerProduct:
+		if dot := -d; dot >= 0 {
+			return dot + 1
+		}
+		return 1 / (1 + d)
+	default:
+		return 1 / (1 + d)
+	}
+}
+
+// knnQuery is a parsed knn query
+type knnQuery struct {
+	field         string
+	vector        []interface{}
+	k             int
+	numCandidates int
+	filter        map[string]interface{} // Optional pre-filter query
+	boost         float64
+}
+
+// parseKNNQuery parses the body of a knn query: {"field": "embedding",
+// "query_vector": [...], "k": 10, "num_candidates": 100, "filter": {...}}.
+// The vector is checked against the field's mapping when the query runs.
+func parseKNNQuery(body map[string]interface{}) (*knnQuery, error) {
+	q := &knnQuery{k: defaultKNNK, boost: 1}
+
+	field, ok := body["field"].(string)
+	if !ok || field == "" {
+		return nil, fmt.Errorf("knn query requires [field]")
+	}
+	q.field = field
+
+	if q.vector, ok = body["query_vector"].([]interface{}); !ok {
+		return nil, fmt.Errorf("knn query for field [%s] requires [query_vector] as an array of numbers", field)
+	}
+
+	positiveInt := func(name string) (int, bool, error) {
+		raw, ok := body[name]
+		if !ok {
+			return 0, false, nil
+		}
+		n, ok := raw.(float64)
+		if !ok || n < 1 || n != math.Trunc(n) {
+			return 0, false, fmt.Errorf("[%s] in knn query for field [%s] must be a positive integer", name, field)
+		}
+		return int(n), true, nil
+	}
+
+	k, hasK, err := positiveInt("k")
+	if err != nil {
+		return nil, err
+	}
+	if hasK {
+		q.k = k
+	}
+	numCandidates, hasCandidates, err := positiveInt("num_candidates")
+	if err != nil {
+		return nil, err
+	}
+	if hasCandidates {
+		q.numCandidates = numCandidates
+	} else {
+		q.numCandidates = min(max(q.k*3/2, q.k), maxKNNCandidates)
+	}
+	if q.numCandidates > maxKNNCandidates {
+		return nil, fmt.Errorf("[num_candidates] in knn query for field [%s] cannot exceed %d", field, maxKNNCandidates)
+	}
+	if q.numCandidates < q.k {
+		return nil, fmt.Errorf("[num_candidates] in knn query for field [%s] cannot be less than [k]", field)
+	}
+
+	if raw, ok := body["filter"]; ok {
+		if q.filter, ok = raw.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("[filter] in knn query for field [%s] must be a query object", field)
+		}
+	}
+	if boost, ok := body["boost"]; ok {
+		if q.boost, ok = boost.(float64); !ok || q.boost < 0 {
+			return nil, fmt.Errorf("boost in knn query for field [%s] must be a non-negative number", field)
+		}
+	}
+	return q, nil
+}
+
+// knnHit is a document found by a knn search
+type knnHit struct {
+	id       string
+	distance float32
+}
+
+// hnswNode is a vector in the graph. Deleted nodes keep their links so the
+// graph stays connected, but are never returned.
+type hnswNode struct {
+	id        string
+	vector    []float32
+	neighbors [][]uint32 // Links per layer, from 0 up to the node's level
+	deleted   bool
+}
+
+// hnswIndex is a hierarchical navigable small world graph over the vectors
+// of one knn_vector field of a shard, keyed by document _id
+type hnswIndex struct {
+	mu       sync.RWMutex
+	field    KNNField
+	nodes    []*hnswNode
+	ids      map[string]uint32 // Live nodes by document _id
+	entry    int32             // Entry point, -1 while empty
+	maxLevel int
+	deleted  int
+	dirty    bool // Changed since it was last saved
+	rng      *rand.Rand
+}
+
+func newHNSWIndex(field KNNField) *hnswIndex {
+	return &hnswIndex{
+		field: field,
+		ids:   make(map[string]uint32),
+		entry: -1,
+		rng:   rand.New(rand.NewSource(1)), // Fixed so graphs are reproducible
+	}
+}
+
+// size returns the number of live vectors
+func (h *hnswIndex) size() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.ids)
+}
+
+// upsert adds the vector of document id, replacing any previous one
+func (h *hnswIndex) upsert(id string, vector []float32) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removeLocked(id)
+	h.insertLocked(id, vector)
+	h.dirty = true
+}
+
+// remove drops the vector of document id, if any
+func (h *hnswIndex) remove(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.removeLocked(id) {
+		h.dirty = true
+	}
+}
+
+func (h *hnswIndex) removeLocked(id string) bool {
+	n, ok := h.ids[id]
+	if !ok {
+		return false
+	}
+	h.nodes[n].deleted = true
+	delete(h.ids, id)
+	h.deleted++
+	return true
+}
+
+// maxLinks returns how many links a node keeps on a layer
+func maxLinks(level int) int {
+	if level == 0 {
+		return 2 * hnswM
+	}
+	return hnswM
+}
+
+// randomLevel draws the top layer of a new node from an exponentially
+// decaying distribution
+func (h *hnswIndex) randomLevel() int {
+	return int(math.Floor(-math.Log(1-h.rng.Float64()) / math.Log(hnswM)))
+}
+
+func (h *hnswIndex) distance(vector []float32, n uint32) float32 {
+	return knnDistance(h.field.SpaceType, vector, h.nodes[n].vector)
+}
+
+func (h *hnswIndex) insertLocked(id string, vector []float32) {
+	level := h.randomLevel()
+	n := uint32(len(h.nodes))
+	node := &hnswNode{id: id, vector: vector, neighbors: make([][]uint32, level+1)}
+	h.nodes = append(h.nodes, node)
+	h.ids[id] = n
+
+	if h.entry < 0 {
+		h.entry = int32(n)
+		h.maxLevel = level
+		return
+	}
+
+	ep := uint32(h.entry)
+	for l := h.maxLevel; l > level; l-- {
+		ep = h.greedyClosest(vector, ep, l)
+	}
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		candidates := h.searchLayer(vector, ep, hnswEfConstruction, l, nil)
+		limit := maxLinks(l)
+		for i := 0; i < len(candidates) && len(node.neighbors[l]) < limit; i++ {
+			if candidates[i].node != n {
+				node.neighbors[l] = append(node.neighbors[l], candidates[i].node)
+			}
+		}
+		for _, neighbor := range node.neighbors[l] {
+			h.link(neighbor, n, l)
+		}
+		ep = candidates[0].node
+	}
+
+	if level > h.maxLevel {
+		h.maxLevel = level
+		h.entry = int32(n)
+	}
+}
+
+// link adds a link from node from to node to on a layer, dropping the
+// farthest link when from has too many
+func (h *hnswIndex) link(from, to uint32, level int) {
+	links := append(h.nodes[from].neighbors[level], to)
+	if len(links) > maxLinks(level) {
+		vector := h.nodes[from].vector
+		sort.Slice(links, func(i, j int) bool {
+			return h.distance(vector, links[i]) < h.distance(vector, links[j])
+		})
+		links = links[:maxLinks(level)]
+	}
+	h.nodes[from].neighbors[level] = links
+}
+
+// greedyClosest walks a layer from ep towards vector until no neighbor is
+// closer
+func (h *hnswIndex) greedyClosest(vector []float32, ep uint32, level int) uint32 {
+	best := h.distance(vector, ep)
+	for changed := true; changed; {
+		changed = false
+		for _, neighbor := range h.nodes[ep].neighbors[level] {
+			if d := h.distance(vector, neighbor); d < best {
+				best, ep, changed = d, neighbor, true
+			}
+		}
+	}
+	return ep
+}
+
+// hnswCandidate is a node at a distance from the vector searched for
+type hnswCandidate struct {
+	node     uint32
+	distance float32
+}
+
+// candidateHeap is a heap of candidates, closest first unless farthest is set
+type candidateHeap struct {
+	items    []hnswCandidate
+	farthest bool
+}
+
+func (c *candidateHeap) Len() int { return len(c.items) }
+func (c *candidateHeap) Less(i, j int) bool {
+	if c.farthest {
+		return c.items[i].distance > c.items[j].distance
+	}
+	return c.items[i].distance < c.items[j].distance
+}
+func (c *candidateHeap) Swap(i, j int)      { c.items[i], c.items[j] = c.items[j], c.items[i] }
+func (c *candidateHeap) Push(x interface{}) { c.items = append(c.items, x.(hnswCandidate)) }
+func (c *candidateHeap) Pop() interface{} {
+	last := c.items[len(c.items)-1]
+	c.items = c.items[:len(c.items)-1]
+	return last
+}
+
+// searchLayer returns up to ef nodes of a layer closest to vector, closest
+// first. If accept is set, only nodes it accepts are returned, though the
+// search still walks through the others.
+func (h *hnswIndex) searchLayer(vector []float32, ep uint32, ef int, level int, accept func(n uint32) bool) []hnswCandidate {
+	visited := map[uint32]bool{ep: true}
+	start := hnswCandidate{node: ep, distance: h.distance(vector, ep)}
+	candidates := &candidateHeap{items: []hnswCandidate{start}}
+	results := &candidateHeap{farthest: true}
+	if accept == nil || accept(ep) {
+		heap.Push(results, start)
+	}
+
+	for candidates.Len() > 0 {
+		closest := heap.Pop(candidates).(hnswCandidate)
+		if results.Len() >= ef && closest.distance > results.items[0].distance {
+			break
+		}
+		for _, neighbor := range h.nodes[closest.node].neighbors[level] {
+			if visited[neighbor] {
+				continue
+			}
+			visited[neighbor] = true
+			d := h.distance(vector, neighbor)
+			if results.Len() < ef || d < results.items[0].distance {
+				heap.Push(candidates, hnswCandidate{node: neighbor, distance: d})
+				if accept == nil || accept(neighbor) {
+					heap.Push(results, hnswCandidate{node: neighbor, distance: d})
+					if results.Len() > ef {
+						heap.Pop(results)
+					}
+				}
+			}
+		}
+	}
+
+	found := results.items
+	sort.Slice(found, func(i, j int) bool { return found[i].distance < found[j].distance })
+	return found
+}
+
+// search returns the k live vectors closest to vector, closest first,
+// exploring numCandidates candidates on the base layer. If allowed is not
+// nil, only those documents are returned; when they are no more than
+// numCandidates they are compared exhaustively, which is exact.
+func (h *hnswIndex) search(vector []float32, k, numCandidates int, allowed map[string]bool) []knnHit {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.entry < 0 || k <= 0 {
+		return nil
+	}
+	if allowed != nil && len(allowed) <= numCandidates {
+		return h.exactSearch(vector, k, allowed)
+	}
+
+	ep := uint32(h.entry)
+	for l := h.maxLevel; l > 0; l-- {
+		ep = h.greedyClosest(vector, ep, l)
+	}
+	accept := func(n uint32) bool {
+		node := h.nodes[n]
+		return !node.deleted && (allowed == nil || allowed[node.id])
+	}
+	found := h.searchLayer(vector, ep, max(numCandidates, k), 0, accept)
+
+	hits := make([]knnHit, 0, min(k, len(found)))
+	for _, c := range found[:min(k, len(found))] {
+		hits = append(hits, knnHit{id: h.nodes[c.node].id, distance: c.distance})
+	}
+	return hits
+}
+
+// exactSearch compares vector with each allowed document's vector
+func (h *hnswIndex) exactSearch(vector []float32, k int, allowed map[string]bool) []knnHit {
+	hits := make([]knnHit, 0, len(allowed))
+	for id := range allowed {
+		if n, ok := h.ids[id]; ok {
+			hits = append(hits, knnHit{id: id, distance: h.distance(vector, n)})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].distance != hits[j].distance {
+			return hits[i].distance < hits[j].distance
+		}
+		return hits[i].id < hits[j].id
+	})
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits
+}
+
+// compactLocked rebuilds the graph from its live vectors once deleted
+// nodes outnumber them
+func (h *hnswIndex) compactLocked() {
+	if h.deleted <= len(h.ids) {
+		return
+	}
+	live := make([]*hnswNode, 0, len(h.ids))
+	for _, node := range h.nodes {
+		if !node.deleted {
+			live = append(live, node)
+		}
+	}
+	h.nodes, h.ids, h.entry, h.maxLevel, h.deleted = nil, make(map[string]uint32, len(live)), -1, 0, 0
+	for _, node := range live {
+		h.insertLocked(node.id, node.vector)
+	}
+}
+
+// save writes the graph to path if it changed since it was loaded or last
+// saved. The file is replaced atomically and ends with a CRC32 checksum.
+func (h *hnswIndex) save(path string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.dirty {
+		return nil
+	}
+	h.compactLocked()
+
+	var buf bytes.Buffer
+	w := func(v interface{}) { binary.Write(&buf, binary.LittleEndian, v) }
+	w(uint32(hnswFileMagic))
+	w(uint32(hnswFileVersion))
+	w(uint32(h.field.Dimension))
+	w(uint32(len(h.field.SpaceType)))
+	buf.WriteString(h.field.SpaceType)
+	w(uint32(len(h.nodes)))
+	w(h.entry)
+	w(int32(h.maxLevel))
+	for _, node := range h.nodes {
+		w(uint32(len(node.id)))
+		buf.WriteString(node.id)
+		w(node.deleted)
+		w(node.vector)
+		w(uint32(len(node.neighbors)))
+		for _, links := range node.neighbors {
+			w(uint32(len(links)))
+			w(links)
+		}
+	}
+	w(crc32.ChecksumIEEE(buf.Bytes()))
+
+	if err := os.WriteFile(path+".tmp", buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to save vector index: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to save vector index: %w", err)
+	}
+	h.dirty = false
+	return nil
+}
+
+// loadHNSWIndex reads a graph saved by save. A missing file yields an empty
+// graph; a graph saved for another dimension or space type is an error.
+func loadHNSWIndex(path string, field KNNField) (*hnswIndex, error) {
+	h := newHNSWIndex(field)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vector index: %w", err)
+	}
+	if len(data) < 4 {
+		return nil, fmt.Errorf("vector index %s is truncated", path)
+	}
+	payload := data[:len(data)-4]
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(data[len(data)-4:]) {
+		return nil, fmt.Errorf("vector index %s is corrupt: checksum mismatch", path)
+	}
+
+	r := bytes.NewReader(payload)
+	var readErr error
+	read := func(v interface{}) {
+		if readErr == nil {
+			readErr = binary.Read(r, binary.LittleEndian, v)
+		}
+	}
+	readString := func() string {
+		var n uint32
+		read(&n)
+		if readErr != nil || int(n) > r.Len() {
+			readErr = io.ErrUnexpectedEOF
+			return ""
+		}
+		b := make([]byte, n)
+		read(b)
+		return string(b)
+	}
+
+	var magic, version, dimension, numNodes uint32
+	var maxLevel int32
+	read(&magic)
+	read(&version)
+	if readErr == nil && (magic != hnswFileMagic || version != hnswFileVersion) {
+		return nil, fmt.Errorf("vector index %s has an unknown format", path)
+	}
+	read(&dimension)
+	space := readString()
+	if readErr == nil && (int(dimension) != field.Dimension || space != field.SpaceType) {
+		return nil, fmt.Errorf("vector index %s was built for dimension %d and space_type %s, mapping has %d and %s",
+			path, dimension, space, field.Dimension, field.SpaceType)
+	}
+	read(&numNodes)
+	read(&h.entry)
+	read(&maxLevel)
+	h.maxLevel = int(maxLevel)
+
+	for i := uint32(0); i < numNodes && readErr == nil; i++ {
+		node := &hnswNode{id: readString(), vector: make([]float32, dimension)}
+		read(&node.deleted)
+		read(node.vector)
+		var levels uint32
+		read(&levels)
+		if readErr == nil && levels > uint32(maxLevel)+1 {
+			readErr = fmt.Errorf("node has %d levels", levels)
+		}
+		for l := uint32(0); l < levels && readErr == nil; l++ {
+			var count uint32
+			read(&count)
+			if readErr == nil && (count > uint32(maxLinks(int(l))) || count > numNodes) {
+				readErr = fmt.Errorf("node has %d links", count)
+				break
+			}
+			links := make([]uint32, count)
+			read(links)
+			node.neighbors = append(node.neighbors, links)
+		}
+		h.nodes = append(h.nodes, node)
+		if node.deleted {
+			h.deleted++
+		} else {
+			h.ids[node.id] = i
+		}
+	}
+	if readErr != nil {
+		return nil, fmt.Errorf("failed to read vector index %s: %w", path, readErr)
+	}
+	// Links may only lead to nodes present on their layer
+	for _, node := range h.nodes {
+		for l, links := range node.neighbors {
+			for _, n := range links {
+				if n >= numNodes || len(h.nodes[n].neighbors) <= l {
+					return nil, fmt.Errorf("vector index %s is corrupt: bad link to node %d on layer %d", path, n, l)
+				}
+			}
+		}
+	}
+	if h.entry >= int32(numNodes) || (h.entry >= 0 && len(h.nodes[h.entry].neighbors) != h.maxLevel+1) {
+		return nil, fmt.Errorf("vector index %s is corrupt: entry point %d of %d", path, h.entry, numNodes)
+	}
+	return h, nil
+}
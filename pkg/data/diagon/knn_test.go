@@ -0,0 +1,290 @@
+package diagon
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestParseKNNVector(t *testing.T) {
+	field := KNNField{Dimension: 3, SpaceType: SpaceTypeCosine}
+
+	vector, err := parseKNNVector([]interface{}{1.0, 0.5, -2.0}, field)
+	if err != nil {
+		t.Fatalf("parseKNNVector failed: %v", err)
+	}
+	if !reflect.DeepEqual(vector, []float32{1, 0.5, -2}) {
+		t.Errorf("unexpected vector: %v", vector)
+	}
+
+	for _, value := range []interface{}{
+		"1,2,3",
+		[]interface{}{1.0, 2.0},
+		[]interface{}{1.0, "2", 3.0},
+		[]interface{}{0.0, 0.0, 0.0},
+	} {
+		if _, err := parseKNNVector(value, field); err == nil {
+			t.Errorf("expected error for %v", value)
+		}
+	}
+
+	// The zero vector is only rejected where cosine needs a magnitude
+	if _, err := parseKNNVector([]interface{}{0.0, 0.0, 0.0}, KNNField{Dimension: 3, SpaceType: SpaceTypeL2}); err != nil {
+		t.Errorf("zero vector should be valid for l2: %v", err)
+	}
+}
+
+func TestKNNFieldValidate(t *testing.T) {
+	field := KNNField{Dimension: 4}
+	if err := field.validate(); err != nil || field.SpaceType != SpaceTypeL2 {
+		t.Errorf("expected l2 default, got %q, %v", field.SpaceType, err)
+	}
+	for _, invalid := range []KNNField{
+		{Dimension: 0},
+		{Dimension: maxKNNDimension + 1},
+		{Dimension: 4, SpaceType: "hamming"},
+	} {
+		if err := invalid.validate(); err == nil {
+			t.Errorf("expected error for %+v", invalid)
+		}
+	}
+}
+
+func TestKNNScore(t *testing.T) {
+	a, b := []float32{1, 0}, []float32{0, 2}
+	tests := []struct {
+		space    string
+		distance float32
+		score    float64
+	}{
+		{SpaceTypeL2, 5, 1.0 / 6},
+		{SpaceTypeCosine, 1, 0.5},
+		{SpaceTypeInnerProduct, 0, 1},
+	}
+	for _, tt := range tests {
+		d := knnDistance(tt.space, a, b)
+		if math.Abs(float64(d-tt.distance)) > 1e-6 {
+			t.Errorf("%s: distance %v, want %v", tt.space, d, tt.distance)
+		}
+		if score := knnScore(tt.space, d); math.Abs(score-tt.score) > 1e-6 {
+			t.Errorf("%s: score %v, want %v", tt.space, score, tt.score)
+		}
+	}
+
+	// Negative dot products still score positively, below any positive one
+	if score := knnScore(SpaceTypeInnerProduct, 3); score <= 0 || score >= 1 {
+		t.Errorf("unexpected score for dot product -3: %v", score)
+	}
+}
+
+func TestParseKNNQuery(t *testing.T) {
+	parse := func(body string) (*knnQuery, error) {
+		t.Helper()
+		var obj map[string]interface{}
+		if err := json.Unmarshal([]byte(body), &obj); err != nil {
+			t.Fatalf("invalid test body %s: %v", body, err)
+		}
+		return parseKNNQuery(obj)
+	}
+
+	q, err := parse(`{"field":"embedding","query_vector":[1,2],"k":5,"num_candidates":50,"filter":{"term":{"tag":"a"}},"boost":2}`)
+	if err != nil {
+		t.Fatalf("parseKNNQuery failed: %v", err)
+	}
+	if q.field != "embedding" || q.k != 5 || q.numCandidates != 50 || q.boost != 2 || q.filter == nil {
+		t.Errorf("unexpected query: %+v", q)
+	}
+
+	q, err = parse(`{"field":"embedding","query_vector":[1,2],"k":20}`)
+	if err != nil {
+		t.Fatalf("parseKNNQuery failed: %v", err)
+	}
+	if q.numCandidates != 30 {
+		t.Errorf("expected num_candidates to default to 1.5k, got %d", q.numCandidates)
+	}
+
+	for _, body := range []string{
+		`{"query_vector":[1,2]}`,
+		`{"field":"embedding"}`,
+		`{"field":"embedding","query_vector":[1,2],"k":0}`,
+		`{"field":"embedding","query_vector":[1,2],"k":1.5}`,
+		`{"field":"embedding","query_vector":[1,2],"k":10,"num_candidates":5}`,
+		`{"field":"embedding","query_vector":[1,2],"num_candidates":20000}`,
+		`{"field":"embedding","query_vector":[1,2],"filter":"tag:a"}`,
+	} {
+		if _, err := parse(body); err == nil {
+			t.Errorf("expected error for %s", body)
+		}
+	}
+}
+
+// randomVectors returns n random vectors keyed doc-0..doc-(n-1)
+func randomVectors(n, dim int, seed int64) map[string][]float32 {
+	rng := rand.New(rand.NewSource(seed))
+	vectors := make(map[string][]float32, n)
+	for i := 0; i < n; i++ {
+		v := make([]float32, dim)
+		for j := range v {
+			v[j] = rng.Float32()*2 - 1
+		}
+		vectors["doc-"+strconv.Itoa(i)] = v
+	}
+	return vectors
+}
+
+func hitIDs(hits []knnHit) []string {
+	ids := make([]string, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.id
+	}
+	return ids
+}
+
+func TestHNSWSearch(t *testing.T) {
+	field := KNNField{Dimension: 8, SpaceType: SpaceTypeL2}
+	vectors := randomVectors(1000, field.Dimension, 7)
+
+	index := newHNSWIndex(field)
+	all := make(map[string]bool, len(vectors))
+	for id, v := range vectors {
+		index.upsert(id, v)
+		all[id] = true
+	}
+	if index.size() != len(vectors) {
+		t.Fatalf("expected %d vectors, got %d", len(vectors), index.size())
+	}
+
+	// The approximate search should find nearly all true nearest neighbors
+	queries := randomVectors(20, field.Dimension, 8)
+	found, total := 0, 0
+	for _, q := range queries {
+		exact := index.exactSearch(q, 10, all)
+		approx := index.search(q, 10, 100, nil)
+		if len(approx) != 10 {
+			t.Fatalf("expected 10 hits, got %d", len(approx))
+		}
+		for i := 1; i < len(approx); i++ {
+			if approx[i].distance < approx[i-1].distance {
+				t.Fatalf("hits are not ordered by distance: %v", approx)
+			}
+		}
+		want := make(map[string]bool)
+		for _, hit := range exact {
+			want[hit.id] = true
+		}
+		for _, hit := range approx {
+			if want[hit.id] {
+				found++
+			}
+		}
+		total += len(exact)
+	}
+	if recall := float64(found) / float64(total); recall < 0.9 {
+		t.Errorf("recall %.2f is too low", recall)
+	}
+
+	// A small filter is searched exactly
+	allowed := map[string]bool{"doc-1": true, "doc-2": true, "doc-3": true}
+	hits := index.search(queries["doc-0"], 2, 100, allowed)
+	if !reflect.DeepEqual(hitIDs(hits), hitIDs(index.exactSearch(queries["doc-0"], 2, allowed))) {
+		t.Errorf("unexpected filtered hits: %v", hits)
+	}
+
+	// A large filter is applied while walking the graph
+	even := make(map[string]bool)
+	for i := 0; i < len(vectors); i += 2 {
+		even["doc-"+strconv.Itoa(i)] = true
+	}
+	for _, hit := range index.search(queries["doc-1"], 10, 100, even) {
+		if !even[hit.id] {
+			t.Errorf("hit %s is not allowed by the filter", hit.id)
+		}
+	}
+
+	// Deleted and replaced vectors are not returned
+	target := vectors["doc-5"]
+	if hits := index.search(target, 1, 100, nil); len(hits) != 1 || hits[0].id != "doc-5" || hits[0].distance != 0 {
+		t.Fatalf("expected doc-5 to be its own nearest neighbor, got %v", hits)
+	}
+	index.remove("doc-5")
+	if hits := index.search(target, 1, 100, nil); len(hits) != 1 || hits[0].id == "doc-5" {
+		t.Errorf("deleted vector returned: %v", hits)
+	}
+	index.upsert("doc-6", target)
+	if hits := index.search(target, 1, 100, nil); len(hits) != 1 || hits[0].id != "doc-6" || hits[0].distance != 0 {
+		t.Errorf("expected replaced doc-6 to match, got %v", hits)
+	}
+	if index.size() != len(vectors)-1 {
+		t.Errorf("expected %d vectors, got %d", len(vectors)-1, index.size())
+	}
+}
+
+func TestHNSWSaveLoad(t *testing.T) {
+	field := KNNField{Dimension: 4, SpaceType: SpaceTypeCosine}
+	path := filepath.Join(t.TempDir(), "embedding.hnsw")
+
+	// A missing file is an empty graph
+	index, err := loadHNSWIndex(path, field)
+	if err != nil {
+		t.Fatalf("loadHNSWIndex failed: %v", err)
+	}
+	if index.size() != 0 || len(index.search([]float32{1, 0, 0, 0}, 3, 10, nil)) != 0 {
+		t.Fatalf("expected an empty graph")
+	}
+
+	for id, v := range randomVectors(300, field.Dimension, 3) {
+		index.upsert(id, v)
+	}
+	index.remove("doc-0")
+	if err := index.save(path); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	loaded, err := loadHNSWIndex(path, field)
+	if err != nil {
+		t.Fatalf("loadHNSWIndex failed: %v", err)
+	}
+	if loaded.size() != 299 {
+		t.Errorf("expected 299 vectors, got %d", loaded.size())
+	}
+	query := []float32{0.5, -0.25, 1, 0}
+	if got, want := loaded.search(query, 5, 50, nil), index.search(query, 5, 50, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded graph returned %v, want %v", got, want)
+	}
+
+	// Deleted vectors outnumbering live ones are dropped when saving
+	for i := 1; i < 200; i++ {
+		loaded.remove("doc-" + strconv.Itoa(i))
+	}
+	if err := loaded.save(path); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if len(loaded.nodes) != 100 || loaded.deleted != 0 {
+		t.Errorf("expected a compacted graph of 100 nodes, got %d with %d deleted", len(loaded.nodes), loaded.deleted)
+	}
+	reloaded, err := loadHNSWIndex(path, field)
+	if err != nil || reloaded.size() != 100 {
+		t.Fatalf("expected 100 vectors after reload, got %v", err)
+	}
+
+	if _, err := loadHNSWIndex(path, KNNField{Dimension: 8, SpaceType: SpaceTypeCosine}); err == nil {
+		t.Error("expected error loading a graph of another dimension")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)/2] ^= 0xff
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadHNSWIndex(path, field); err == nil {
+		t.Error("expected error loading a corrupt graph")
+	}
+}
@@ -0,0 +1,200 @@
+package diagon
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// TextAnalyzer turns the text of a field into tokens. It is used both to
+// index string fields and to analyze match and match_phrase query text, so
+// the two always agree.
+type TextAnalyzer func(field, text string) ([]Token, error)
+
+// whitespaceTokens splits text on whitespace, as Diagon's TextField does on
+// its own. Shards without a TextAnalyzer analyze text this way.
+func whitespaceTokens(text string) []Token {
+	words := strings.Fields(text)
+	tokens := make([]Token, len(words))
+	for i, word := range words {
+		tokens[i] = Token{Text: word, Position: i}
+	}
+	return tokens
+}
+
+// tokenTerms returns the indexed terms for tokens. Indexed text goes
+// through TextField, which splits on whitespace, so a token holding
+// whitespace (e.g. from the keyword analyzer) becomes several terms.
+func tokenTerms(tokens []Token) []string {
+	terms := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		terms = append(terms, strings.Fields(token.Text)...)
+	}
+	return terms
+}
+
+// matchQuery is a parsed match or match_phrase query
+type matchQuery struct {
+	field              string
+	text               string
+	operator           string      // "or" or "and" (match only)
+	minimumShouldMatch interface{} // integer or percentage, nil = 1 (match only)
+	slop               int         // match_phrase only
+}
+
+// parseMatchQuery parses the body of a match or match_phrase query:
+// {"field": "text"} or {"field": {"query": "text", ...options}}
+func parseMatchQuery(kind string, body map[string]interface{}) (*matchQuery, error) {
+	if len(body) != 1 {
+		return nil, fmt.Errorf("%s query must name exactly one field, got %d", kind, len(body))
+	}
+
+	for field, value := range body {
+		m := &matchQuery{field: field, operator: "or"}
+		opts, ok := value.(map[string]interface{})
+		if !ok {
+			m.text = fmt.Sprintf("%v", value)
+			return m, nil
+		}
+
+		query, ok := opts["query"]
+		if !ok {
+			return nil, fmt.Errorf("%s query for field [%s] requires [query]", kind, field)
+		}
+		m.text = fmt.Sprintf("%v", query)
+
+		if operator, ok := opts["operator"]; ok {
+			op, _ := operator.(string)
+			switch strings.ToLower(op) {
+			case "or", "and":
+				m.operator = strings.ToLower(op)
+			default:
+				return nil, fmt.Errorf("invalid operator [%v] in %s query for field [%s]", operator, kind, field)
+			}
+		}
+
+		if msm, ok := opts["minimum_should_match"]; ok {
+			// Validate the spec now; it is resolved once the terms are known
+			if _, err := minimumShouldMatch(msm, 1); err != nil {
+				return nil, err
+			}
+			m.minimumShouldMatch = msm
+		}
+
+		if slop, ok := opts["slop"]; ok {
+			n, isNum := slop.(float64)
+			if !isNum || n < 0 || n != math.Trunc(n) {
+				return nil, fmt.Errorf("slop in %s query for field [%s] must be a non-negative integer", kind, field)
+			}
+			m.slop = int(n)
+		}
+		return m, nil
+	}
+	return nil, nil
+}
+
+// minimumShouldMatch resolves a minimum_should_match spec against n optional
+// clauses, as Elasticsearch does: an integer is a count and a percentage a
+// share of n (rounded down), and negative values count the clauses that may
+// be missing. The result may exceed n, in which case nothing matches.
+func minimumShouldMatch(spec interface{}, n int) (int, error) {
+	var result int
+	switch v := spec.(type) {
+	case nil:
+		return 1, nil
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("invalid minimum_should_match [%v]", spec)
+		}
+		result = int(v)
+		if result < 0 {
+			result += n
+		}
+	case string:
+		s := strings.TrimSpace(v)
+		if pct, isPct := strings.CutSuffix(s, "%"); isPct {
+			percent, err := strconv.Atoi(pct)
+			if err != nil {
+				return 0, fmt.Errorf("invalid minimum_should_match [%s]", v)
+			}
+			calc := float64(n*percent) / 100
+			result = int(calc)
+			if calc < 0 {
+				result = n + int(calc)
+			}
+		} else {
+			count, err := strconv.Atoi(s)
+			if err != nil {
+				return 0, fmt.Errorf("invalid minimum_should_match [%s]", v)
+			}
+			result = count
+			if count < 0 {
+				result = n + count
+			}
+		}
+	default:
+		return 0, fmt.Errorf("invalid minimum_should_match [%v]", spec)
+	}
+
+	if result < 0 {
+		result = 0
+	}
+	return result, nil
+}
+
+// phraseMatches reports whether the query tokens occur in doc at the same
+// relative positions, give or take slop. As in Lucene's sloppy phrase
+// matching, each query token is placed on a distinct document position and
+// the spread of (document position - query offset) over all tokens must not
+// exceed slop; swapping two adjacent words therefore needs a slop of 2.
+func phraseMatches(query, doc []Token, slop int) bool {
+	if len(query) == 0 {
+		return false
+	}
+
+	positions := make(map[string][]int)
+	for _, token := range doc {
+		positions[token.Text] = append(positions[token.Text], token.Position)
+	}
+	for _, token := range query {
+		if len(positions[token.Text]) == 0 {
+			return false
+		}
+	}
+
+	base := query[0].Position
+	used := make(map[int]bool, len(query))
+	var place func(i, lo, hi int) bool
+	place = func(i, lo, hi int) bool {
+		if i == len(query) {
+			return true
+		}
+		offset := query[i].Position - base
+		for _, pos := range positions[query[i].Text] {
+			if used[pos] {
+				continue
+			}
+			norm := pos - offset
+			newLo, newHi := lo, hi
+			if i == 0 || norm < newLo {
+				newLo = norm
+			}
+			if i == 0 || norm > newHi {
+				newHi = norm
+			}
+			if newHi-newLo > slop {
+				continue
+			}
+
+			used[pos] = true
+			ok := place(i+1, newLo, newHi)
+			delete(used, pos)
+			if ok {
+				return true
+			}
+		}
+		return false
+	}
+	return place(0, 0, 0)
+}
@@ -0,0 +1,138 @@
+package diagon
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseMatchQuery(t *testing.T) {
+	parse := func(kind, body string) (*matchQuery, error) {
+		t.Helper()
+		var obj map[string]interface{}
+		if err := json.Unmarshal([]byte(body), &obj); err != nil {
+			t.Fatalf("invalid test body %s: %v", body, err)
+		}
+		return parseMatchQuery(kind, obj)
+	}
+
+	tests := []struct {
+		kind string
+		body string
+		want *matchQuery
+	}{
+		{"match", `{"title":"quick fox"}`, &matchQuery{field: "title", text: "quick fox", operator: "or"}},
+		{"match", `{"year":2024}`, &matchQuery{field: "year", text: "2024", operator: "or"}},
+		{"match", `{"title":{"query":"quick fox","operator":"AND"}}`,
+			&matchQuery{field: "title", text: "quick fox", operator: "and"}},
+		{"match", `{"title":{"query":"a b c","minimum_should_match":"75%"}}`,
+			&matchQuery{field: "title", text: "a b c", operator: "or", minimumShouldMatch: "75%"}},
+		{"match_phrase", `{"title":{"query":"quick fox","slop":2}}`,
+			&matchQuery{field: "title", text: "quick fox", operator: "or", slop: 2}},
+	}
+	for _, tt := range tests {
+		got, err := parse(tt.kind, tt.body)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.body, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.body, got, tt.want)
+		}
+	}
+
+	for _, body := range []string{
+		`{}`,
+		`{"a":"x","b":"y"}`,
+		`{"title":{"operator":"and"}}`,
+		`{"title":{"query":"x","operator":"xor"}}`,
+		`{"title":{"query":"x","minimum_should_match":"most"}}`,
+		`{"title":{"query":"x","slop":-1}}`,
+		`{"title":{"query":"x","slop":1.5}}`,
+	} {
+		if _, err := parse("match", body); err == nil {
+			t.Errorf("expected error for %s", body)
+		}
+	}
+}
+
+func TestMinimumShouldMatch(t *testing.T) {
+	tests := []struct {
+		spec interface{}
+		n    int
+		want int
+	}{
+		{nil, 4, 1},
+		{2.0, 4, 2},
+		{-1.0, 4, 3},
+		{"3", 4, 3},
+		{"-3", 2, 0},
+		{"75%", 4, 3},
+		{"75%", 3, 2},
+		{"-25%", 3, 3},
+		{"-25%", 4, 3},
+		{"100%", 3, 3},
+		{5.0, 3, 5},
+	}
+	for _, tt := range tests {
+		got, err := minimumShouldMatch(tt.spec, tt.n)
+		if err != nil {
+			t.Errorf("%v of %d: unexpected error: %v", tt.spec, tt.n, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%v of %d: got %d, want %d", tt.spec, tt.n, got, tt.want)
+		}
+	}
+
+	for _, spec := range []interface{}{1.5, "x%", "lots", true} {
+		if _, err := minimumShouldMatch(spec, 3); err == nil {
+			t.Errorf("expected error for %v", spec)
+		}
+	}
+}
+
+func TestTokenTerms(t *testing.T) {
+	tokens := []Token{{Text: "new york", Position: 0}, {Text: "city", Position: 1}}
+	if got := tokenTerms(tokens); !reflect.DeepEqual(got, []string{"new", "york", "city"}) {
+		t.Errorf("unexpected terms: %v", got)
+	}
+	if got := tokenTerms(whitespaceTokens("  Quick\tbrown  fox ")); !reflect.DeepEqual(got, []string{"Quick", "brown", "fox"}) {
+		t.Errorf("unexpected whitespace terms: %v", got)
+	}
+}
+
+func TestPhraseMatches(t *testing.T) {
+	doc := whitespaceTokens("the quick brown fox jumps over the lazy dog")
+
+	tests := []struct {
+		phrase string
+		slop   int
+		want   bool
+	}{
+		{"quick brown fox", 0, true},
+		{"brown fox jumps", 0, true},
+		{"quick fox", 0, false},
+		{"quick fox", 1, true},
+		{"fox quick", 1, false},
+		{"fox brown", 2, true},
+		{"the lazy dog", 0, true},
+		{"the the", 0, false},
+		{"the the", 6, true},
+		{"lazy cat", 10, false},
+	}
+	for _, tt := range tests {
+		if got := phraseMatches(whitespaceTokens(tt.phrase), doc, tt.slop); got != tt.want {
+			t.Errorf("%q slop %d: got %v, want %v", tt.phrase, tt.slop, got, tt.want)
+		}
+	}
+
+	// Positions left by removed stop words count as gaps
+	query := []Token{{Text: "quick", Position: 0}, {Text: "fox", Position: 2}}
+	if !phraseMatches(query, []Token{{Text: "quick", Position: 1}, {Text: "fox", Position: 3}}, 0) {
+		t.Error("expected phrase with a position gap to match")
+	}
+	if phraseMatches(query, []Token{{Text: "quick", Position: 1}, {Text: "fox", Position: 2}}, 0) {
+		t.Error("expected phrase without the gap not to match at slop 0")
+	}
+}
@@ -0,0 +1,292 @@
+package diagon
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultMaxExpansions is the most terms a prefix, wildcard or regexp query
+// may expand to on a shard unless the shard is configured otherwise
+const DefaultMaxExpansions = 1024
+
+// defaultFuzzyMaxExpansions is the number of terms a fuzzy query keeps
+// unless it sets max_expansions
+const defaultFuzzyMaxExpansions = 50
+
+// multiTermQuery is a parsed prefix, wildcard, regexp or fuzzy query. It
+// matches the indexed terms of field that accept, and is executed as a
+// constant-score disjunction of those terms.
+type multiTermQuery struct {
+	kind          string
+	field         string
+	boost         float64
+	maxExpansions int // fuzzy only: terms kept, closest first
+
+	// accept reports whether an indexed term matches, and for fuzzy queries
+	// its edit distance from the query term
+	accept func(term string) (bool, int)
+}
+
+// parseMultiTermQuery parses the body of a prefix, wildcard, regexp or fuzzy
+// query: {"field": "value"} or {"field": {"value": "value", ...options}}
+func parseMultiTermQuery(kind string, body map[string]interface{}) (*multiTermQuery, error) {
+	if len(body) != 1 {
+		return nil, fmt.Errorf("%s query must name exactly one field, got %d", kind, len(body))
+	}
+
+	for field, raw := range body {
+		opts, ok := raw.(map[string]interface{})
+		if !ok {
+			opts = map[string]interface{}{"value": raw}
+		}
+
+		value, ok := opts["value"]
+		if !ok && kind == "wildcard" {
+			value, ok = opts["wildcard"]
+		}
+		if !ok {
+			return nil, fmt.Errorf("%s query for field [%s] requires [value]", kind, field)
+		}
+
+		q := &multiTermQuery{kind: kind, field: field, boost: 1}
+		if boost, ok := opts["boost"]; ok {
+			if q.boost, ok = boost.(float64); !ok || q.boost < 0 {
+				return nil, fmt.Errorf("boost in %s query for field [%s] must be a non-negative number", kind, field)
+			}
+		}
+		caseInsensitive, _ := opts["case_insensitive"].(bool)
+
+		text := fmt.Sprintf("%v", value)
+		var err error
+		switch kind {
+		case "prefix":
+			q.accept, err = regexpMatcher(regexp.QuoteMeta(text)+"(?s:.*)", caseInsensitive)
+		case "wildcard":
+			q.accept, err = regexpMatcher(wildcardToRegexp(text), caseInsensitive)
+		case "regexp":
+			q.accept, err = regexpMatcher(text, caseInsensitive)
+		case "fuzzy":
+			err = q.parseFuzzy(text, opts)
+		default:
+			err = fmt.Errorf("unsupported multi-term query type [%s]", kind)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s query for field [%s]: %w", kind, field, err)
+		}
+		return q, nil
+	}
+	return nil, nil
+}
+
+// regexpMatcher accepts terms matched in full by pattern, which uses Go's
+// RE2 syntax
+func regexpMatcher(pattern string, caseInsensitive bool) (func(string) (bool, int), error) {
+	flags := ""
+	if caseInsensitive {
+		flags = "(?i)"
+	}
+	re, err := regexp.Compile(flags + "^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, err
+	}
+	return func(term string) (bool, int) {
+		return re.MatchString(term), 0
+	}, nil
+}
+
+// wildcardToRegexp translates a wildcard pattern, where * matches any
+// sequence, ? any single character and \ escapes the next character
+func wildcardToRegexp(pattern string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '*':
+			b.WriteString("(?s:.*)")
+		case r == '?':
+			b.WriteString("(?s:.)")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	if escaped {
+		b.WriteString(regexp.QuoteMeta(`\`))
+	}
+	return b.String()
+}
+
+// parseFuzzy sets up a fuzzy query for value from its options: fuzziness
+// (0, 1, 2, "AUTO" or "AUTO:low,high"), prefix_length, max_expansions and
+// transpositions
+func (q *multiTermQuery) parseFuzzy(value string, opts map[string]interface{}) error {
+	maxEdits, err := fuzzyMaxEdits(opts["fuzziness"], utf8.RuneCountInString(value))
+	if err != nil {
+		return err
+	}
+
+	prefixLength, err := nonNegativeInt(opts, "prefix_length", 0)
+	if err != nil {
+		return err
+	}
+	q.maxExpansions, err = nonNegativeInt(opts, "max_expansions", defaultFuzzyMaxExpansions)
+	if err != nil {
+		return err
+	}
+	if q.maxExpansions == 0 {
+		return fmt.Errorf("max_expansions must be positive")
+	}
+	transpositions := true
+	if t, ok := opts["transpositions"]; ok {
+		if transpositions, ok = t.(bool); !ok {
+			return fmt.Errorf("transpositions must be a boolean")
+		}
+	}
+
+	target := []rune(value)
+	if prefixLength > len(target) {
+		prefixLength = len(target)
+	}
+	prefix := string(target[:prefixLength])
+	q.accept = func(term string) (bool, int) {
+		if !strings.HasPrefix(term, prefix) {
+			return false, 0
+		}
+		distance := editDistance([]rune(term), target, maxEdits, transpositions)
+		return distance <= maxEdits, distance
+	}
+	return nil
+}
+
+// fuzzyMaxEdits resolves a fuzziness spec for a term of n characters. AUTO
+// allows no edits below 3 characters, one up to 5 and two from 6.
+func fuzzyMaxEdits(spec interface{}, n int) (int, error) {
+	switch v := spec.(type) {
+	case nil:
+		return autoFuzziness(n, 3, 6), nil
+	case float64:
+		if v != 0 && v != 1 && v != 2 {
+			return 0, fmt.Errorf("fuzziness must be 0, 1, 2 or AUTO, got [%v]", v)
+		}
+		return int(v), nil
+	case string:
+		s := strings.ToUpper(strings.TrimSpace(v))
+		if s == "AUTO" {
+			return autoFuzziness(n, 3, 6), nil
+		}
+		if bounds, ok := strings.CutPrefix(s, "AUTO:"); ok {
+			low, high, found := strings.Cut(bounds, ",")
+			lowN, errLow := strconv.Atoi(low)
+			highN, errHigh := strconv.Atoi(high)
+			if !found || errLow != nil || errHigh != nil || lowN < 0 || highN < lowN {
+				return 0, fmt.Errorf("invalid fuzziness [%s]", v)
+			}
+			return autoFuzziness(n, lowN, highN), nil
+		}
+		if edits, err := strconv.Atoi(s); err == nil {
+			return fuzzyMaxEdits(float64(edits), n)
+		}
+		return 0, fmt.Errorf("invalid fuzziness [%s]", v)
+	}
+	return 0, fmt.Errorf("invalid fuzziness [%v]", spec)
+}
+
+// autoFuzziness returns the edits AUTO:low,high allows for n characters
+func autoFuzziness(n, low, high int) int {
+	switch {
+	case n < low:
+		return 0
+	case n < high:
+		return 1
+	}
+	return 2
+}
+
+// nonNegativeInt reads an optional non-negative integer option
+func nonNegativeInt(opts map[string]interface{}, name string, def int) (int, error) {
+	raw, ok := opts[name]
+	if !ok {
+		return def, nil
+	}
+	v, isNum := raw.(float64)
+	if !isNum || v < 0 || v != math.Trunc(v) {
+		return 0, fmt.Errorf("%s must be a non-negative integer", name)
+	}
+	return int(v), nil
+}
+
+// editDistance returns the Levenshtein distance between a and b, counting a
+// swap of adjacent characters as one edit if transpositions is set. Any
+// distance above limit is reported as limit+1.
+func editDistance(a, b []rune, limit int, transpositions bool) int {
+	if d := len(a) - len(b); d > limit || -d > limit {
+		return limit + 1
+	}
+
+	// Three rows of the dynamic programming table: two back, previous, current
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if transpositions && i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return min(prev[len(b)], limit+1)
+}
+
+// expansion is an indexed term accepted by a multi-term query
+type expansion struct {
+	term     string
+	distance int
+}
+
+// selectExpansions picks the terms a query runs with. Fuzzy queries keep the
+// maxExpansions closest terms (ties in term order); other queries fail if
+// they accept more than limit terms.
+func (q *multiTermQuery) selectExpansions(accepted []expansion, limit int) ([]string, error) {
+	if q.kind == "fuzzy" {
+		sort.SliceStable(accepted, func(i, j int) bool {
+			return accepted[i].distance < accepted[j].distance
+		})
+		keep := min(q.maxExpansions, limit)
+		if len(accepted) > keep {
+			accepted = accepted[:keep]
+		}
+	} else if len(accepted) > limit {
+		return nil, fmt.Errorf("%s query on field [%s] expands to more than %d terms", q.kind, q.field, limit)
+	}
+
+	terms := make([]string, len(accepted))
+	for i, e := range accepted {
+		terms[i] = e.term
+	}
+	return terms, nil
+}
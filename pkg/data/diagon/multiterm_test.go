@@ -0,0 +1,129 @@
+package diagon
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func parseMultiTermBody(t *testing.T, kind, body string) (*multiTermQuery, error) {
+	t.Helper()
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &obj); err != nil {
+		t.Fatalf("invalid test body %s: %v", body, err)
+	}
+	return parseMultiTermQuery(kind, obj)
+}
+
+func TestMultiTermQueryAccept(t *testing.T) {
+	tests := []struct {
+		kind   string
+		body   string
+		accept []string
+		reject []string
+	}{
+		{"prefix", `{"name":"app"}`, []string{"app", "apple", "application"}, []string{"ap", "Apple", "map"}},
+		{"prefix", `{"name":{"value":"APP","case_insensitive":true}}`, []string{"apple", "App"}, []string{"ap"}},
+		{"prefix", `{"name":"a.b"}`, []string{"a.b", "a.bc"}, []string{"axb"}},
+		{"wildcard", `{"name":"ki*y"}`, []string{"kiy", "kitty", "kimchy"}, []string{"kit", "skimpy"}},
+		{"wildcard", `{"name":{"wildcard":"te?t"}}`, []string{"test", "text"}, []string{"tet", "toast"}},
+		{"wildcard", `{"name":{"value":"a\\*b"}}`, []string{"a*b"}, []string{"ab", "axb"}},
+		{"wildcard", `{"name":"(x)+"}`, []string{"(x)+"}, []string{"x", "xx"}},
+		{"regexp", `{"name":"k.*y"}`, []string{"ky", "kimchy"}, []string{"kimchys", "skimchy"}},
+		{"regexp", `{"name":{"value":"[a-c]+","case_insensitive":true}}`, []string{"abc", "CAB"}, []string{"abd"}},
+		{"fuzzy", `{"name":"quick"}`, []string{"quick", "quack", "quikc", "quic"}, []string{"qu", "quacks"}},
+		{"fuzzy", `{"name":{"value":"quick","fuzziness":2}}`, []string{"quacks", "qick"}, []string{"q"}},
+		{"fuzzy", `{"name":{"value":"quick","transpositions":false}}`, []string{"quack"}, []string{"quikc"}},
+		{"fuzzy", `{"name":{"value":"quick","prefix_length":2}}`, []string{"quack"}, []string{"xuick"}},
+		{"fuzzy", `{"name":{"value":"ab","fuzziness":"AUTO"}}`, []string{"ab"}, []string{"ac"}},
+		{"fuzzy", `{"name":{"value":"ab","fuzziness":"AUTO:3,4"}}`, []string{"ab"}, []string{"ac"}},
+		{"fuzzy", `{"name":{"value":"abc","fuzziness":"AUTO:1,3"}}`, []string{"abd", "bc"}, []string{"xyz"}},
+	}
+	for _, tt := range tests {
+		q, err := parseMultiTermBody(t, tt.kind, tt.body)
+		if err != nil {
+			t.Errorf("%s %s: unexpected error: %v", tt.kind, tt.body, err)
+			continue
+		}
+		if q.field != "name" {
+			t.Errorf("%s %s: unexpected field %q", tt.kind, tt.body, q.field)
+		}
+		for _, term := range tt.accept {
+			if ok, _ := q.accept(term); !ok {
+				t.Errorf("%s %s: expected %q to match", tt.kind, tt.body, term)
+			}
+		}
+		for _, term := range tt.reject {
+			if ok, _ := q.accept(term); ok {
+				t.Errorf("%s %s: expected %q not to match", tt.kind, tt.body, term)
+			}
+		}
+	}
+
+	for _, tt := range []struct{ kind, body string }{
+		{"prefix", `{}`},
+		{"prefix", `{"a":"x","b":"y"}`},
+		{"prefix", `{"name":{"boost":2}}`},
+		{"prefix", `{"name":{"value":"x","boost":-1}}`},
+		{"regexp", `{"name":"a(b"}`},
+		{"fuzzy", `{"name":{"value":"x","fuzziness":3}}`},
+		{"fuzzy", `{"name":{"value":"x","fuzziness":"AUTO:6,3"}}`},
+		{"fuzzy", `{"name":{"value":"x","max_expansions":0}}`},
+		{"fuzzy", `{"name":{"value":"x","prefix_length":1.5}}`},
+		{"fuzzy", `{"name":{"value":"x","transpositions":"yes"}}`},
+		{"range", `{"name":"x"}`},
+	} {
+		if _, err := parseMultiTermBody(t, tt.kind, tt.body); err == nil {
+			t.Errorf("expected error for %s %s", tt.kind, tt.body)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b           string
+		limit          int
+		transpositions bool
+		want           int
+	}{
+		{"kitten", "sitting", 5, true, 3},
+		{"quick", "quikc", 2, true, 1},
+		{"quick", "quikc", 2, false, 2},
+		{"ab", "ba", 2, true, 1},
+		{"", "abc", 5, true, 3},
+		{"kitten", "sitting", 2, true, 3},
+		{"a", "abcdef", 2, true, 3},
+		{"naïve", "naive", 1, true, 1},
+	}
+	for _, tt := range tests {
+		if got := editDistance([]rune(tt.a), []rune(tt.b), tt.limit, tt.transpositions); got != tt.want {
+			t.Errorf("%q/%q limit %d: got %d, want %d", tt.a, tt.b, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestSelectExpansions(t *testing.T) {
+	accepted := func() []expansion {
+		return []expansion{{"quack", 1}, {"quick", 0}, {"quacks", 2}, {"quick1", 1}}
+	}
+
+	prefix := &multiTermQuery{kind: "prefix", field: "name"}
+	terms, err := prefix.selectExpansions(accepted(), 4)
+	if err != nil || !reflect.DeepEqual(terms, []string{"quack", "quick", "quacks", "quick1"}) {
+		t.Errorf("unexpected prefix expansion: %v, %v", terms, err)
+	}
+	if _, err := prefix.selectExpansions(accepted(), 3); err == nil {
+		t.Error("expected error when expansions exceed the limit")
+	}
+
+	// Fuzzy queries keep the closest terms instead of failing
+	fuzzy := &multiTermQuery{kind: "fuzzy", field: "name", maxExpansions: 3}
+	terms, err = fuzzy.selectExpansions(accepted(), 10)
+	if err != nil || !reflect.DeepEqual(terms, []string{"quick", "quack", "quick1"}) {
+		t.Errorf("unexpected fuzzy expansion: %v, %v", terms, err)
+	}
+	terms, _ = fuzzy.selectExpansions(accepted(), 1)
+	if !reflect.DeepEqual(terms, []string{"quick"}) {
+		t.Errorf("expected the shard limit to cap fuzzy expansion, got %v", terms)
+	}
+}
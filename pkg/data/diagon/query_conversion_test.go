@@ -81,7 +81,7 @@ func TestRangeQueryConversion(t *testing.T) {
 
 			// Note: We can't actually execute this without a real Diagon index
 			// But we can test that the conversion doesn't crash
-			_, err := shard.convertQueryToDiagon(queryObj)
+			_, err := shard.convertQueryToDiagon(nil, queryObj)
 
 			if tt.shouldError && err == nil {
 				t.Errorf("%s: expected error but got none", tt.description)
@@ -186,7 +186,7 @@ func TestBoolQueryConversion(t *testing.T) {
 				t.Fatalf("Failed to parse test query JSON: %v", err)
 			}
 
-			_, err := shard.convertQueryToDiagon(queryObj)
+			_, err := shard.convertQueryToDiagon(nil, queryObj)
 
 			if tt.shouldError && err == nil {
 				t.Errorf("%s: expected error but got none", tt.description)
@@ -207,6 +207,9 @@ func TestQueryTypeSupport(t *testing.T) {
 	supportedQueries := []string{
 		`{"term": {"field": "value"}}`,
 		`{"match": {"field": "text"}}`,
+		`{"match": {"field": {"query": "two words", "operator": "and"}}}`,
+		`{"match": {"field": {"query": "three more words", "minimum_should_match": "-1"}}}`,
+		`{"match_phrase": {"field": "text"}}`,
 		// Note: match_all is parsed but returns stub error (not yet implemented in Diagon)
 		// `{"match_all": {}}`,
 		`{"range": {"price": {"gte": 100}}}`,
@@ -219,16 +222,25 @@ func TestQueryTypeSupport(t *testing.T) {
 			t.Fatalf("Failed to parse query: %v", err)
 		}
 
-		_, err := shard.convertQueryToDiagon(queryObj)
+		_, err := shard.convertQueryToDiagon(nil, queryObj)
 		if err != nil {
 			t.Errorf("Query should be supported but got error: %s\nQuery: %s", err, queryJSON)
 		}
 	}
 
 	unsupportedQueries := []string{
+		`{"geo_distance": {"distance": "10km", "location": "40,-70"}}`,
+		`{"more_like_this": {"like": "text"}}`,
+		`{"script": {"script": "doc['a'].value > 1"}}`,
+		// Multi-term queries expand against the index, so they need a searcher
 		`{"wildcard": {"field": "val*"}}`,
 		`{"fuzzy": {"field": "value"}}`,
 		`{"prefix": {"field": "pre"}}`,
+		`{"regexp": {"field": "val.*"}}`,
+		// So do neural_sparse queries, which score the stored vectors of matches
+		`{"neural_sparse": {"embedding": {"query_tokens": {"search": 1.0}}}}`,
+		// And knn queries, which resolve their filters and hits to documents
+		`{"knn": {"field": "embedding", "query_vector": [1.0, 2.0], "k": 5}}`,
 	}
 
 	for _, queryJSON := range unsupportedQueries {
@@ -237,7 +249,7 @@ func TestQueryTypeSupport(t *testing.T) {
 			t.Fatalf("Failed to parse query: %v", err)
 		}
 
-		_, err := shard.convertQueryToDiagon(queryObj)
+		_, err := shard.convertQueryToDiagon(nil, queryObj)
 		if err == nil {
 			t.Errorf("Query should be unsupported but got no error. Query: %s", queryJSON)
 		}
@@ -0,0 +1,217 @@
+package diagon
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sortSpec is one key of a sorted search, parsed from a SearchRequest.sort
+// clause
+type sortSpec struct {
+	field        string // stored field, or "_score" / "_doc"
+	descending   bool
+	missingFirst bool        // documents without a value sort first
+	missingValue interface{} // value used for documents without one (nil = none)
+}
+
+// sortedDoc is a match with its sort values, one per sortSpec
+type sortedDoc struct {
+	scoredDoc
+	values []interface{}
+}
+
+// parseSortClauses parses sort clauses. Each clause is a JSON field name
+// ("price"), or an object keyed by the field whose value is the order
+// ({"price": "desc"}) or an options object
+// ({"price": {"order": "desc", "missing": "_first"}}).
+func parseSortClauses(clauses []string) ([]*sortSpec, error) {
+	specs := make([]*sortSpec, 0, len(clauses))
+	for _, clause := range clauses {
+		var raw interface{}
+		if err := json.Unmarshal([]byte(clause), &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse sort clause: %w", err)
+		}
+		spec, err := parseSortClause(raw)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// parseSortClause parses a single decoded sort clause
+func parseSortClause(raw interface{}) (*sortSpec, error) {
+	switch clause := raw.(type) {
+	case string:
+		return newSortSpec(clause, "", nil)
+
+	case map[string]interface{}:
+		if len(clause) != 1 {
+			return nil, fmt.Errorf("sort clause must name exactly one field, got %d", len(clause))
+		}
+		for field, options := range clause {
+			switch opts := options.(type) {
+			case string:
+				return newSortSpec(field, opts, nil)
+			case map[string]interface{}:
+				order, _ := opts["order"].(string)
+				return newSortSpec(field, order, opts["missing"])
+			default:
+				return nil, fmt.Errorf("invalid sort options for field [%s]", field)
+			}
+		}
+	}
+	return nil, fmt.Errorf("invalid sort clause: %v", raw)
+}
+
+// newSortSpec builds a sort key. Scores sort descending and everything else
+// ascending unless order says otherwise; missing values sort last by default.
+func newSortSpec(field, order string, missing interface{}) (*sortSpec, error) {
+	if field == "" {
+		return nil, fmt.Errorf("sort field must not be empty")
+	}
+
+	spec := &sortSpec{field: field, descending: field == "_score"}
+	switch strings.ToLower(order) {
+	case "":
+	case "asc":
+		spec.descending = false
+	case "desc":
+		spec.descending = true
+	default:
+		return nil, fmt.Errorf("invalid sort order [%s] for field [%s]", order, field)
+	}
+
+	switch m := missing.(type) {
+	case nil:
+	case string:
+		switch m {
+		case "_last":
+		case "_first":
+			spec.missingFirst = true
+		default:
+			spec.missingValue = sortValue(m)
+		}
+	case float64:
+		spec.missingValue = m
+	default:
+		return nil, fmt.Errorf("invalid missing value for sort field [%s]", field)
+	}
+	return spec, nil
+}
+
+// sortFields returns the stored fields the sort keys read
+func sortFields(specs []*sortSpec) []string {
+	var fields []string
+	for _, spec := range specs {
+		if spec.field != "_score" && spec.field != "_doc" {
+			fields = append(fields, spec.field)
+		}
+	}
+	return fields
+}
+
+// keyValue returns a document's value for the sort key. Multi-valued
+// fields sort by their smallest value ascending and their largest
+// descending. Returns nil when the document has no value.
+func (s *sortSpec) keyValue(doc scoredDoc, fields map[string][]string) interface{} {
+	switch s.field {
+	case "_score":
+		return doc.score
+	case "_doc":
+		return float64(doc.doc)
+	}
+
+	var best interface{}
+	for _, raw := range fields[s.field] {
+		value := sortValue(raw)
+		if best == nil {
+			best = value
+			continue
+		}
+		cmp := compareSortValues(value, best)
+		if (s.descending && cmp > 0) || (!s.descending && cmp < 0) {
+			best = value
+		}
+	}
+	if best == nil {
+		return s.missingValue
+	}
+	return best
+}
+
+// sortValue converts a stored value into a sort value: numbers and dates
+// (as epoch millis) become float64, anything else sorts as a string
+func sortValue(raw string) interface{} {
+	if f, err := strconv.ParseFloat(raw, 64); err == nil {
+		return f
+	}
+	if t, ok := parseDateValue(raw); ok {
+		return float64(t.UnixMilli())
+	}
+	return raw
+}
+
+// compareSortValues orders two non-nil sort values. Numbers sort before
+// strings so mixed fields still have a total order.
+func compareSortValues(a, b interface{}) int {
+	af, aNum := a.(float64)
+	bf, bNum := b.(float64)
+	switch {
+	case aNum && bNum:
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		}
+		return 0
+	case aNum:
+		return -1
+	case bNum:
+		return 1
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// compareSortKeys orders two documents by their sort values, key by key
+func compareSortKeys(specs []*sortSpec, a, b []interface{}) int {
+	for i, spec := range specs {
+		av, bv := a[i], b[i]
+		var cmp int
+		switch {
+		case av == nil && bv == nil:
+			continue
+		case av == nil || bv == nil:
+			// Missing values keep their place whatever the order
+			cmp = 1
+			if spec.missingFirst {
+				cmp = -1
+			}
+			if bv == nil {
+				cmp = -cmp
+			}
+			return cmp
+		default:
+			cmp = compareSortValues(av, bv)
+		}
+		if cmp != 0 {
+			if spec.descending {
+				return -cmp
+			}
+			return cmp
+		}
+	}
+	return 0
+}
+
+// sortDocs orders docs by the sort keys. Ties keep their input order.
+func sortDocs(docs []sortedDoc, specs []*sortSpec) {
+	sort.SliceStable(docs, func(i, j int) bool {
+		return compareSortKeys(specs, docs[i].values, docs[j].values) < 0
+	})
+}
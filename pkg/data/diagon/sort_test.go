@@ -0,0 +1,105 @@
+package diagon
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSortClauses(t *testing.T) {
+	specs, err := parseSortClauses([]string{
+		`"_score"`,
+		`"name"`,
+		`{"price":"desc"}`,
+		`{"@timestamp":{"order":"asc","missing":"_first"}}`,
+		`{"rank":{"missing":5}}`,
+	})
+	if err != nil {
+		t.Fatalf("parseSortClauses failed: %v", err)
+	}
+
+	want := []*sortSpec{
+		{field: "_score", descending: true},
+		{field: "name"},
+		{field: "price", descending: true},
+		{field: "@timestamp", missingFirst: true},
+		{field: "rank", missingValue: 5.0},
+	}
+	if !reflect.DeepEqual(specs, want) {
+		t.Errorf("unexpected specs: %+v", specs)
+	}
+	if fields := sortFields(specs); !reflect.DeepEqual(fields, []string{"name", "price", "@timestamp", "rank"}) {
+		t.Errorf("unexpected sort fields: %v", fields)
+	}
+
+	for _, clause := range []string{
+		`not json`,
+		`""`,
+		`{"a":"asc","b":"asc"}`,
+		`{"price":"sideways"}`,
+		`{"price":42}`,
+		`{"price":{"missing":true}}`,
+		`[]`,
+	} {
+		if _, err := parseSortClauses([]string{clause}); err == nil {
+			t.Errorf("expected error for sort clause %s", clause)
+		}
+	}
+}
+
+func TestSortSpecKeyValue(t *testing.T) {
+	doc := scoredDoc{doc: 7, score: 1.5}
+	fields := map[string][]string{
+		"price":      {"30.000000", "10.000000", "20.000000"},
+		"@timestamp": {"2024-01-01T00:00:00Z"},
+		"tag":        {"beta", "alpha"},
+	}
+
+	tests := []struct {
+		name string
+		spec *sortSpec
+		want interface{}
+	}{
+		{"score", &sortSpec{field: "_score"}, 1.5},
+		{"doc", &sortSpec{field: "_doc"}, 7.0},
+		{"min value ascending", &sortSpec{field: "price"}, 10.0},
+		{"max value descending", &sortSpec{field: "price", descending: true}, 30.0},
+		{"keyword", &sortSpec{field: "tag"}, "alpha"},
+		{"date as epoch millis", &sortSpec{field: "@timestamp"}, float64(1704067200000)},
+		{"missing", &sortSpec{field: "missing"}, nil},
+		{"missing substitute", &sortSpec{field: "missing", missingValue: 3.0}, 3.0},
+	}
+	for _, tt := range tests {
+		if got := tt.spec.keyValue(doc, fields); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSortDocs(t *testing.T) {
+	docs := []sortedDoc{
+		{scoredDoc: scoredDoc{doc: 0}, values: []interface{}{nil, 1.0}},
+		{scoredDoc: scoredDoc{doc: 1}, values: []interface{}{"b", 2.0}},
+		{scoredDoc: scoredDoc{doc: 2}, values: []interface{}{5.0, 3.0}},
+		{scoredDoc: scoredDoc{doc: 3}, values: []interface{}{"b", 1.0}},
+		{scoredDoc: scoredDoc{doc: 4}, values: []interface{}{"a", 1.0}},
+	}
+	check := func(specs []*sortSpec, want []int) {
+		t.Helper()
+		sortDocs(docs, specs)
+		got := make([]int, len(docs))
+		for i, doc := range docs {
+			got[i] = doc.doc
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got order %v, want %v", got, want)
+		}
+	}
+
+	// Numbers sort before strings, missing values last, later keys break ties
+	check([]*sortSpec{{field: "k"}, {field: "n", descending: true}}, []int{2, 4, 1, 3, 0})
+
+	// Descending keeps missing values last
+	check([]*sortSpec{{field: "k", descending: true}, {field: "n"}}, []int{3, 1, 4, 2, 0})
+
+	check([]*sortSpec{{field: "k", missingFirst: true}, {field: "n"}}, []int{0, 2, 4, 3, 1})
+}
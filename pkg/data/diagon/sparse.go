@@ -0,0 +1,139 @@
+package diagon
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SparseVector maps tokens to weights, as produced by learned sparse
+// encoders such as SPLADE
+type SparseVector map[string]float64
+
+// parseSparseVector validates a sparse_vector field value: an object of
+// tokens to non-negative weights. Tokens are indexed as terms, so they may
+// not be empty or contain whitespace.
+func parseSparseVector(value interface{}) (SparseVector, error) {
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("sparse_vector value must be an object of token weights, got %T", value)
+	}
+
+	vector := make(SparseVector, len(obj))
+	for token, raw := range obj {
+		if token == "" || strings.ContainsFunc(token, unicode.IsSpace) {
+			return nil, fmt.Errorf("invalid sparse_vector token [%s]", token)
+		}
+		weight, ok := raw.(float64)
+		if !ok || weight < 0 || math.IsInf(weight, 0) || math.IsNaN(weight) {
+			return nil, fmt.Errorf("weight of sparse_vector token [%s] must be a non-negative number", token)
+		}
+		if weight > 0 {
+			vector[token] = weight
+		}
+	}
+	return vector, nil
+}
+
+// tokens returns the vector's tokens in order
+func (v SparseVector) tokens() []string {
+	tokens := make([]string, 0, len(v))
+	for token := range v {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+// sparseQuery is a parsed neural_sparse query
+type sparseQuery struct {
+	field  string
+	tokens SparseVector
+	boost  float64
+}
+
+// parseSparseQuery parses the body of a neural_sparse query:
+// {"field": {"query_tokens": {"token": weight, ...}, "boost": 1.0}}.
+// Query text needs a sparse encoder, which shards do not run, so the
+// tokens must be given.
+func parseSparseQuery(body map[string]interface{}) (*sparseQuery, error) {
+	if len(body) != 1 {
+		return nil, fmt.Errorf("neural_sparse query must name exactly one field, got %d", len(body))
+	}
+
+	for field, raw := range body {
+		opts, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("neural_sparse query for field [%s] must be an object", field)
+		}
+
+		rawTokens, ok := opts["query_tokens"]
+		if !ok {
+			if _, hasText := opts["query_text"]; hasText {
+				return nil, fmt.Errorf("neural_sparse query for field [%s]: query_text needs a sparse encoder, pass query_tokens instead", field)
+			}
+			return nil, fmt.Errorf("neural_sparse query for field [%s] requires [query_tokens]", field)
+		}
+		tokens, err := parseSparseVector(rawTokens)
+		if err != nil {
+			return nil, fmt.Errorf("invalid query_tokens in neural_sparse query for field [%s]: %w", field, err)
+		}
+
+		q := &sparseQuery{field: field, tokens: tokens, boost: 1}
+		if boost, ok := opts["boost"]; ok {
+			if q.boost, ok = boost.(float64); !ok || q.boost < 0 {
+				return nil, fmt.Errorf("boost in neural_sparse query for field [%s] must be a non-negative number", field)
+			}
+		}
+		return q, nil
+	}
+	return nil, nil
+}
+
+// sparseBatch lays out sparse vectors in the CSR form Diagon's sparse index
+// is built from, numbering tokens as they are first seen
+type sparseBatch struct {
+	dims    map[string]uint32
+	indptr  []uint32
+	indices []uint32
+	values  []float32
+}
+
+func newSparseBatch() *sparseBatch {
+	return &sparseBatch{dims: make(map[string]uint32), indptr: []uint32{0}}
+}
+
+// add appends a document vector
+func (b *sparseBatch) add(vector SparseVector) {
+	for _, token := range vector.tokens() {
+		dim, ok := b.dims[token]
+		if !ok {
+			dim = uint32(len(b.dims))
+			b.dims[token] = dim
+		}
+		b.indices = append(b.indices, dim)
+		b.values = append(b.values, float32(vector[token]))
+	}
+	b.indptr = append(b.indptr, uint32(len(b.indices)))
+}
+
+// numDocs returns the number of vectors added
+func (b *sparseBatch) numDocs() int {
+	return len(b.indptr) - 1
+}
+
+// encode returns the dimensions and weights of a query vector, leaving out
+// tokens no document holds
+func (b *sparseBatch) encode(query SparseVector) ([]uint32, []float32) {
+	var indices []uint32
+	var values []float32
+	for _, token := range query.tokens() {
+		if dim, ok := b.dims[token]; ok {
+			indices = append(indices, dim)
+			values = append(values, float32(query[token]))
+		}
+	}
+	return indices, values
+}
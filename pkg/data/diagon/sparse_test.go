@@ -0,0 +1,87 @@
+package diagon
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseSparseVector(t *testing.T) {
+	vector, err := parseSparseVector(map[string]interface{}{"search": 1.5, "engine": 0.25, "the": 0.0})
+	if err != nil {
+		t.Fatalf("parseSparseVector failed: %v", err)
+	}
+	if !reflect.DeepEqual(vector, SparseVector{"search": 1.5, "engine": 0.25}) {
+		t.Errorf("unexpected vector: %v", vector)
+	}
+	if !reflect.DeepEqual(vector.tokens(), []string{"engine", "search"}) {
+		t.Errorf("unexpected tokens: %v", vector.tokens())
+	}
+
+	for _, value := range []interface{}{
+		"search",
+		[]interface{}{1.0},
+		map[string]interface{}{"search": "high"},
+		map[string]interface{}{"search": -1.0},
+		map[string]interface{}{"two words": 1.0},
+		map[string]interface{}{"": 1.0},
+	} {
+		if _, err := parseSparseVector(value); err == nil {
+			t.Errorf("expected error for %v", value)
+		}
+	}
+}
+
+func TestParseSparseQuery(t *testing.T) {
+	parse := func(body string) (*sparseQuery, error) {
+		t.Helper()
+		var obj map[string]interface{}
+		if err := json.Unmarshal([]byte(body), &obj); err != nil {
+			t.Fatalf("invalid test body %s: %v", body, err)
+		}
+		return parseSparseQuery(obj)
+	}
+
+	q, err := parse(`{"embedding":{"query_tokens":{"fast":0.5,"search":1.2},"boost":2}}`)
+	if err != nil {
+		t.Fatalf("parseSparseQuery failed: %v", err)
+	}
+	want := &sparseQuery{field: "embedding", tokens: SparseVector{"fast": 0.5, "search": 1.2}, boost: 2}
+	if !reflect.DeepEqual(q, want) {
+		t.Errorf("got %+v, want %+v", q, want)
+	}
+
+	for _, body := range []string{
+		`{}`,
+		`{"embedding":"fast search"}`,
+		`{"embedding":{"query_text":"fast search"}}`,
+		`{"embedding":{"query_tokens":{"fast":-1}}}`,
+		`{"embedding":{"query_tokens":{"fast":1},"boost":"high"}}`,
+	} {
+		if _, err := parse(body); err == nil {
+			t.Errorf("expected error for %s", body)
+		}
+	}
+}
+
+func TestSparseBatch(t *testing.T) {
+	batch := newSparseBatch()
+	batch.add(SparseVector{"search": 1, "engine": 2})
+	batch.add(SparseVector{})
+	batch.add(SparseVector{"search": 3, "fast": 4})
+
+	if batch.numDocs() != 3 {
+		t.Errorf("expected 3 documents, got %d", batch.numDocs())
+	}
+	// Tokens are numbered in order of first appearance, sorted within a document
+	if !reflect.DeepEqual(batch.indptr, []uint32{0, 2, 2, 4}) ||
+		!reflect.DeepEqual(batch.indices, []uint32{0, 1, 2, 1}) ||
+		!reflect.DeepEqual(batch.values, []float32{2, 1, 4, 3}) {
+		t.Errorf("unexpected layout: %v %v %v", batch.indptr, batch.indices, batch.values)
+	}
+
+	indices, values := batch.encode(SparseVector{"search": 0.5, "missing": 9, "fast": 1})
+	if !reflect.DeepEqual(indices, []uint32{2, 1}) || !reflect.DeepEqual(values, []float32{1, 0.5}) {
+		t.Errorf("unexpected query encoding: %v %v", indices, values)
+	}
+}
@@ -38,6 +38,7 @@ typedef void* DiagonScoreDoc;
 typedef void* DiagonTerm;
 typedef void* DiagonTermsEnum;
 typedef void* DiagonPostingsEnum;
+typedef void* DiagonSparseIndex;
 
 // ==================== Error Handling ====================
 
@@ -139,6 +140,34 @@ DiagonIndexWriter diagon_create_index_writer(DiagonDirectory dir, DiagonIndexWri
  */
 bool diagon_add_document(DiagonIndexWriter writer, DiagonDocument doc);
 
+/**
+ * Replace documents matching term with a new document
+ * The delete is buffered and applied at the next flush or commit, to
+ * documents added before this call, including ones still in the RAM buffer.
+ * @param writer IndexWriter handle
+ * @param term Term identifying the documents to replace
+ * @param doc Document handle
+ * @return true on success, false on error
+ */
+bool diagon_update_document(DiagonIndexWriter writer, DiagonTerm term, DiagonDocument doc);
+
+/**
+ * Delete documents matching term
+ * The delete is buffered and applied at the next flush or commit, where it
+ * is recorded in the live docs of the matching segments.
+ * @param writer IndexWriter handle
+ * @param term Term identifying the documents to delete
+ * @return true on success, false on error
+ */
+bool diagon_delete_documents(DiagonIndexWriter writer, DiagonTerm term);
+
+/**
+ * Get number of deleted documents in flushed segments
+ * @param writer IndexWriter handle
+ * @return Number of deleted documents
+ */
+int64_t diagon_writer_num_deleted_docs(DiagonIndexWriter writer);
+
 /**
  * Flush buffered documents to disk
  * @param writer IndexWriter handle
@@ -197,6 +226,15 @@ void diagon_free_document(DiagonDocument doc);
  */
 DiagonField diagon_create_text_field(const char* name, const char* value);
 
+/**
+ * Create indexed text field (analyzed, indexed, not stored)
+ * Pair with a stored field when the original value must be retrievable.
+ * @param name Field name
+ * @param value Field value
+ * @return Field handle
+ */
+DiagonField diagon_create_indexed_text_field(const char* name, const char* value);
+
 /**
  * Create string field (not analyzed, indexed, stored)
  * @param name Field name
@@ -421,6 +459,16 @@ void diagon_bool_query_set_minimum_should_match(DiagonQuery bool_query, int mini
  */
 DiagonQuery diagon_bool_query_build(DiagonQuery bool_query_builder);
 
+/**
+ * Create constant-score query
+ * Matches the documents of query, each with the same score.
+ * The query is copied, so the caller still owns and frees it.
+ * @param query Query handle
+ * @param score Score given to every match
+ * @return Query handle or NULL on error
+ */
+DiagonQuery diagon_create_constant_score_query(DiagonQuery query, float score);
+
 /**
  * Free Query
  */
@@ -537,9 +585,11 @@ int64_t diagon_directory_get_size(DiagonDirectory dir);
 
 /**
  * Get terms enum for field
+ * Terms of all segments are merged and returned in term order; the enum is
+ * empty if no segment indexes the field.
  * @param reader IndexReader handle
  * @param field Field name
- * @return TermsEnum handle or NULL if field not found
+ * @return TermsEnum handle or NULL on error
  */
 DiagonTermsEnum diagon_reader_get_terms(DiagonIndexReader reader, const char* field);
 
@@ -560,7 +610,8 @@ bool diagon_terms_enum_next(DiagonTermsEnum terms_enum);
 bool diagon_terms_enum_get_term(DiagonTermsEnum terms_enum, char* out_term, size_t out_term_len);
 
 /**
- * Get document frequency of current term
+ * Get document frequency of current term, summed over segments
+ * (deleted documents included)
  * @param terms_enum TermsEnum handle
  * @return Document frequency
  */
@@ -599,6 +650,41 @@ int diagon_postings_freq(DiagonPostingsEnum postings);
  */
 void diagon_free_postings_enum(DiagonPostingsEnum postings);
 
+// ==================== Sparse Vector Search ====================
+
+/**
+ * Build an in-memory sparse vector (SINDI) index over documents in CSR
+ * form: document d holds dimensions indices[indptr[d]..indptr[d+1]) with
+ * the weights at the same positions in values. Documents are numbered
+ * 0..num_docs-1 in input order.
+ * @param indptr Offsets into indices/values, num_docs + 1 entries
+ * @param indices Dimensions of all documents
+ * @param values Weights of all documents
+ * @param num_docs Number of documents
+ * @return SparseIndex handle or NULL on error
+ */
+DiagonSparseIndex diagon_create_sparse_index(const uint32_t* indptr, const uint32_t* indices,
+                                             const float* values, int num_docs);
+
+/**
+ * Score documents against a sparse query vector by dot product
+ * @param index SparseIndex handle
+ * @param indices Query dimensions
+ * @param values Query weights
+ * @param num_terms Number of query dimensions
+ * @param k Maximum number of results
+ * @param out_docs Output document numbers, k entries
+ * @param out_scores Output scores, k entries
+ * @return Number of results (documents with a positive score, best first), or -1 on error
+ */
+int diagon_sparse_index_search(DiagonSparseIndex index, const uint32_t* indices, const float* values,
+                               int num_terms, int k, int* out_docs, float* out_scores);
+
+/**
+ * Free SparseIndex
+ */
+void diagon_free_sparse_index(DiagonSparseIndex index);
+
 #ifdef __cplusplus
 }
 #endif
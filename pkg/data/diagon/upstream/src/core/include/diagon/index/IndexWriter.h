@@ -7,6 +7,7 @@
 #include "diagon/index/DocumentsWriter.h"
 #include "diagon/index/MergePolicy.h"
 #include "diagon/index/SegmentInfo.h"
+#include "diagon/index/Term.h"
 #include "diagon/store/Directory.h"
 #include "diagon/store/Lock.h"
 #include "diagon/util/Exceptions.h"
@@ -15,6 +16,7 @@
 #include <memory>
 #include <mutex>
 #include <string>
+#include <vector>
 
 namespace diagon {
 namespace index {
@@ -22,7 +24,6 @@ namespace index {
 using namespace diagon::store;
 
 // Forward declarations
-class Term;  // Defined in Term.h
 class Query;
 
 // ==================== IndexWriterConfig ====================
@@ -174,6 +175,8 @@ public:
 
     /**
      * Delete all documents matching the given term
+     * The term is buffered and applied at the next flush, to documents
+     * added before this call only.
      * @param term Term to match for deletion
      * @return sequence number
      */
@@ -182,6 +185,7 @@ public:
     /**
      * Update document (delete by term, then add new document atomically)
      * Atomic at the segment level: all matching documents are deleted, then new document is added
+     * The delete is buffered like deleteDocuments, so it never hits the new document
      * @param term Term to match for deletion (identifies documents to replace)
      * @param doc New document to add
      * @return sequence number
@@ -245,6 +249,11 @@ public:
      */
     int getNumDocsAdded() const;
 
+    /**
+     * Get number of deleted documents across flushed segments
+     */
+    int getNumDeletedDocs() const;
+
     /**
      * Get segment infos (for testing)
      */
@@ -272,6 +281,20 @@ private:
     std::unique_ptr<DocumentsWriter> documentsWriter_;
     SegmentInfos segmentInfos_;
 
+    // Delete terms waiting for the next flush (Lucene's BufferedUpdates).
+    // docUpto is the number of documents added when the delete was made:
+    // documents added later are not deleted by it.
+    struct BufferedDelete {
+        Term term;
+        int docUpto;
+    };
+    std::vector<BufferedDelete> bufferedDeletes_;
+
+    // Segments of the DocumentsWriter already published to segmentInfos_,
+    // and the number of documents added when the last of them was flushed
+    size_t publishedSegments_ = 0;
+    int flushedDocUpto_ = 0;
+
     // Merge policy
     std::unique_ptr<MergePolicy> mergePolicy_;
 
@@ -288,7 +311,8 @@ private:
     int64_t nextSequenceNumber();
     void initializeIndex();
     void writeSegmentsFile();
-    void applyDeletes(const Term& term);
+    void publishFlushedSegments();
+    void applyBufferedDeletes(const std::vector<std::shared_ptr<SegmentInfo>>& newSegments);
     void deleteSegmentFiles(std::shared_ptr<SegmentInfo> segment);
     int64_t commitInternal();  // Internal commit (caller must hold commitLock_)
     void executeMerges(MergeSpecification* spec);  // Execute a set of merges
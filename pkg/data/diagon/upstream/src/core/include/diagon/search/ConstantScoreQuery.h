@@ -0,0 +1,53 @@
+// Copyright 2024 Diagon Project
+// Licensed under the Apache License, Version 2.0
+
+#pragma once
+
+#include "diagon/search/Query.h"
+#include "diagon/search/Weight.h"
+
+#include <memory>
+#include <string>
+
+namespace diagon {
+namespace search {
+
+/**
+ * ConstantScoreQuery - Matches the documents of a wrapped query with a
+ * constant score
+ *
+ * The wrapped query is run without scoring, so every match scores
+ * score * boost. Used for multi-term expansions (prefix, wildcard, fuzzy),
+ * where the BM25 scores of the expanded terms are not meaningful.
+ *
+ * Based on: org.apache.lucene.search.ConstantScoreQuery
+ */
+class ConstantScoreQuery : public Query {
+public:
+    ConstantScoreQuery(std::shared_ptr<Query> query, float score = 1.0f);
+
+    // ==================== Accessors ====================
+
+    const Query& getQuery() const { return *query_; }
+    float getScore() const { return score_; }
+
+    // ==================== Query Interface ====================
+
+    std::unique_ptr<Weight> createWeight(IndexSearcher& searcher, ScoreMode scoreMode,
+                                         float boost) const override;
+
+    std::string toString(const std::string& field) const override;
+
+    bool equals(const Query& other) const override;
+
+    size_t hashCode() const override;
+
+    std::unique_ptr<Query> clone() const override;
+
+private:
+    std::shared_ptr<Query> query_;
+    float score_;
+};
+
+}  // namespace search
+}  // namespace diagon
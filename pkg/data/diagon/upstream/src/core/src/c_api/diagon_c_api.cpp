@@ -14,6 +14,7 @@
 #include "diagon/store/FSDirectory.h"
 #include "diagon/store/MMapDirectory.h"
 #include "diagon/index/IndexWriter.h"
+#include "diagon/index/Term.h"
 #include "diagon/index/DirectoryReader.h"
 #include "diagon/document/Document.h"
 #include "diagon/document/Field.h"
@@ -23,11 +24,14 @@
 #include "diagon/search/DoubleRangeQuery.h"
 #include "diagon/search/BooleanQuery.h"
 #include "diagon/search/BooleanClause.h"
+#include "diagon/search/ConstantScoreQuery.h"
 #include "diagon/search/TopDocs.h"
 #include "diagon/search/MatchAllDocsQuery.h"
+#include "diagon/sparse/SindiIndex.h"
 
 #include <bit>
 #include <cstring>
+#include <map>
 #include <memory>
 #include <string>
 #include <exception>
@@ -175,6 +179,58 @@ bool diagon_add_document(DiagonIndexWriter writer, DiagonDocument doc) {
     }
 }
 
+bool diagon_update_document(DiagonIndexWriter writer, DiagonTerm term, DiagonDocument doc) {
+    if (!writer || !term || !doc) {
+        set_error("Invalid writer, term or document");
+        return false;
+    }
+
+    try {
+        auto* index_writer = static_cast<diagon::index::IndexWriter*>(writer);
+        auto* search_term = static_cast<diagon::search::Term*>(term);
+        auto* document = static_cast<diagon::document::Document*>(doc);
+
+        diagon::index::Term index_term(search_term->field(), search_term->text());
+        index_writer->updateDocument(index_term, *document);
+        return true;
+    } catch (const std::exception& e) {
+        set_error(e);
+        return false;
+    }
+}
+
+bool diagon_delete_documents(DiagonIndexWriter writer, DiagonTerm term) {
+    if (!writer || !term) {
+        set_error("Invalid writer or term");
+        return false;
+    }
+
+    try {
+        auto* index_writer = static_cast<diagon::index::IndexWriter*>(writer);
+        auto* search_term = static_cast<diagon::search::Term*>(term);
+
+        diagon::index::Term index_term(search_term->field(), search_term->text());
+        index_writer->deleteDocuments(index_term);
+        return true;
+    } catch (const std::exception& e) {
+        set_error(e);
+        return false;
+    }
+}
+
+int64_t diagon_writer_num_deleted_docs(DiagonIndexWriter writer) {
+    if (!writer) {
+        return 0;
+    }
+
+    try {
+        return static_cast<diagon::index::IndexWriter*>(writer)->getNumDeletedDocs();
+    } catch (const std::exception& e) {
+        set_error(e);
+        return 0;
+    }
+}
+
 bool diagon_flush(DiagonIndexWriter writer) {
     if (!writer) {
         set_error("Invalid writer");
@@ -284,6 +340,22 @@ DiagonField diagon_create_text_field(const char* name, const char* value) {
     }
 }
 
+DiagonField diagon_create_indexed_text_field(const char* name, const char* value) {
+    if (!name || !value) {
+        set_error("Invalid field name or value");
+        return nullptr;
+    }
+
+    try {
+        // TextField: analyzed, indexed, not stored
+        auto field = std::make_unique<diagon::document::TextField>(name, value, false);
+        return static_cast<DiagonField>(field.release());
+    } catch (const std::exception& e) {
+        set_error(e);
+        return nullptr;
+    }
+}
+
 DiagonField diagon_create_string_field(const char* name, const char* value) {
     if (!name || !value) {
         set_error("Invalid field name or value");
@@ -750,6 +822,23 @@ DiagonQuery diagon_bool_query_build(DiagonQuery bool_query_builder) {
     }
 }
 
+DiagonQuery diagon_create_constant_score_query(DiagonQuery query, float score) {
+    if (!query) {
+        set_error("query is required");
+        return nullptr;
+    }
+
+    try {
+        auto* inner = static_cast<diagon::search::Query*>(query);
+        std::shared_ptr<diagon::search::Query> inner_shared(inner->clone().release());
+        auto constant = std::make_unique<diagon::search::ConstantScoreQuery>(inner_shared, score);
+        return constant.release();
+    } catch (const std::exception& e) {
+        set_error(e);
+        return nullptr;
+    }
+}
+
 void diagon_free_query(DiagonQuery query) {
     if (query) {
         // Note: This could be either a Query or a Builder
@@ -1076,29 +1165,101 @@ int64_t diagon_directory_get_size(DiagonDirectory dir) {
 
 // ==================== Advanced: Terms/Postings ====================
 
+namespace {
+
+// Terms of one field merged across all segments, in term order, with their
+// document frequencies summed
+struct MergedTermsEnum {
+    std::map<std::string, int> terms;
+    std::map<std::string, int>::const_iterator current;
+    bool started = false;
+};
+
+}  // namespace
+
 DiagonTermsEnum diagon_reader_get_terms(DiagonIndexReader reader, const char* field) {
-    // TODO: Implement when Terms/TermsEnum API available
-    set_error("Terms enumeration not yet implemented in Diagon Phase 4");
-    return nullptr;
+    if (!reader || !field) {
+        set_error("Invalid reader or field");
+        return nullptr;
+    }
+
+    try {
+        auto* reader_ptr = static_cast<std::shared_ptr<diagon::index::DirectoryReader>*>(reader);
+        auto merged = std::make_unique<MergedTermsEnum>();
+
+        for (const auto& ctx : (*reader_ptr)->leaves()) {
+            auto* terms = ctx.reader->terms(field);
+            if (!terms) {
+                continue;  // Field not indexed in this segment
+            }
+            auto it = terms->iterator();
+            while (it->next()) {
+                auto term = it->term();
+                std::string text(reinterpret_cast<const char*>(term.data()), term.length());
+                merged->terms[text] += it->docFreq();
+            }
+        }
+
+        merged->current = merged->terms.cend();
+        return static_cast<DiagonTermsEnum>(merged.release());
+    } catch (const std::exception& e) {
+        set_error(e);
+        return nullptr;
+    }
 }
 
 bool diagon_terms_enum_next(DiagonTermsEnum terms_enum) {
-    set_error("Terms enumeration not yet implemented in Diagon Phase 4");
-    return false;
+    if (!terms_enum) {
+        set_error("Invalid terms enum");
+        return false;
+    }
+
+    auto* merged = static_cast<MergedTermsEnum*>(terms_enum);
+    if (!merged->started) {
+        merged->current = merged->terms.cbegin();
+        merged->started = true;
+    } else if (merged->current != merged->terms.cend()) {
+        ++merged->current;
+    }
+    return merged->current != merged->terms.cend();
 }
 
 bool diagon_terms_enum_get_term(DiagonTermsEnum terms_enum, char* out_term, size_t out_term_len) {
-    set_error("Terms enumeration not yet implemented in Diagon Phase 4");
-    return false;
+    if (!terms_enum || !out_term) {
+        set_error("Invalid terms enum or output buffer");
+        return false;
+    }
+
+    auto* merged = static_cast<MergedTermsEnum*>(terms_enum);
+    if (!merged->started || merged->current == merged->terms.cend()) {
+        set_error("Terms enum is not positioned on a term");
+        return false;
+    }
+
+    const std::string& text = merged->current->first;
+    if (text.size() + 1 > out_term_len) {
+        set_error("Output buffer too small for term");
+        return false;
+    }
+    std::memcpy(out_term, text.data(), text.size());
+    out_term[text.size()] = '\0';
+    return true;
 }
 
 int diagon_terms_enum_doc_freq(DiagonTermsEnum terms_enum) {
-    set_error("Terms enumeration not yet implemented in Diagon Phase 4");
-    return 0;
+    if (!terms_enum) {
+        return 0;
+    }
+
+    auto* merged = static_cast<MergedTermsEnum*>(terms_enum);
+    if (!merged->started || merged->current == merged->terms.cend()) {
+        return 0;
+    }
+    return merged->current->second;
 }
 
 void diagon_free_terms_enum(DiagonTermsEnum terms_enum) {
-    // No-op
+    delete static_cast<MergedTermsEnum*>(terms_enum);
 }
 
 DiagonPostingsEnum diagon_terms_enum_get_postings(DiagonTermsEnum terms_enum) {
@@ -1120,4 +1281,66 @@ void diagon_free_postings_enum(DiagonPostingsEnum postings) {
     // No-op
 }
 
+// ==================== Sparse Vector Search ====================
+
+DiagonSparseIndex diagon_create_sparse_index(const uint32_t* indptr, const uint32_t* indices,
+                                             const float* values, int num_docs) {
+    if (!indptr || num_docs < 0) {
+        set_error("indptr and a non-negative document count are required");
+        return nullptr;
+    }
+
+    try {
+        std::vector<diagon::sparse::SparseVector> documents;
+        documents.reserve(num_docs);
+        for (int doc = 0; doc < num_docs; ++doc) {
+            diagon::sparse::SparseVector vector;
+            for (uint32_t i = indptr[doc]; i < indptr[doc + 1]; ++i) {
+                vector.set(indices[i], values[i]);
+            }
+            documents.push_back(std::move(vector));
+        }
+
+        // Built per request and searched in memory, never saved
+        diagon::sparse::SindiIndex::Config config;
+        config.use_mmap = false;
+        auto index = std::make_unique<diagon::sparse::SindiIndex>(config);
+        index->build(documents);
+        return index.release();
+    } catch (const std::exception& e) {
+        set_error(e);
+        return nullptr;
+    }
+}
+
+int diagon_sparse_index_search(DiagonSparseIndex index, const uint32_t* indices, const float* values,
+                               int num_terms, int k, int* out_docs, float* out_scores) {
+    if (!index || !out_docs || !out_scores) {
+        set_error("index and output buffers are required");
+        return -1;
+    }
+
+    try {
+        diagon::sparse::SparseVector query;
+        for (int i = 0; i < num_terms; ++i) {
+            query.set(indices[i], values[i]);
+        }
+
+        auto* sindi = static_cast<diagon::sparse::SindiIndex*>(index);
+        auto results = sindi->search(query, k);
+        for (size_t i = 0; i < results.size(); ++i) {
+            out_docs[i] = static_cast<int>(results[i].doc_id);
+            out_scores[i] = results[i].score;
+        }
+        return static_cast<int>(results.size());
+    } catch (const std::exception& e) {
+        set_error(e);
+        return -1;
+    }
+}
+
+void diagon_free_sparse_index(DiagonSparseIndex index) {
+    delete static_cast<diagon::sparse::SindiIndex*>(index);
+}
+
 } // extern "C"
@@ -16,6 +16,7 @@
 #include "diagon/store/IndexOutput.h"
 #include "diagon/util/BitSet.h"
 
+#include <algorithm>
 #include <filesystem>
 #include <fstream>
 #include <iostream>
@@ -87,19 +88,7 @@ int64_t IndexWriter::addDocument(const document::Document& doc) {
 
     // If segments were created, add them to SegmentInfos
     if (segmentsCreated > 0) {
-        for (const auto& segmentInfo : documentsWriter_->getSegmentInfos()) {
-            // Check if this segment is new (not already in segmentInfos_)
-            bool isNew = true;
-            for (int i = 0; i < segmentInfos_.size(); i++) {
-                if (segmentInfos_.info(i)->name() == segmentInfo->name()) {
-                    isNew = false;
-                    break;
-                }
-            }
-            if (isNew) {
-                segmentInfos_.add(segmentInfo);
-            }
-        }
+        publishFlushedSegments();
     }
 
     return nextSequenceNumber();
@@ -108,8 +97,8 @@ int64_t IndexWriter::addDocument(const document::Document& doc) {
 int64_t IndexWriter::deleteDocuments(const Term& term) {
     ensureOpen();
 
-    // Apply deletions to all existing segments
-    applyDeletes(term);
+    // Buffer the term; it is applied to all segments at the next flush
+    bufferedDeletes_.push_back({term, documentsWriter_->getNumDocsAdded()});
 
     return nextSequenceNumber();
 }
@@ -117,27 +106,16 @@ int64_t IndexWriter::deleteDocuments(const Term& term) {
 int64_t IndexWriter::updateDocument(const Term& term, const document::Document& doc) {
     ensureOpen();
 
-    // Delete old documents matching term
-    applyDeletes(term);
+    // Buffer the delete of old documents matching term. It only covers
+    // documents added so far, so the new document survives it.
+    bufferedDeletes_.push_back({term, documentsWriter_->getNumDocsAdded()});
 
     // Add new document
     int segmentsCreated = documentsWriter_->addDocument(doc);
 
     // If segments were created, add them to SegmentInfos
     if (segmentsCreated > 0) {
-        for (const auto& segmentInfo : documentsWriter_->getSegmentInfos()) {
-            // Check if this segment is new (not already in segmentInfos_)
-            bool isNew = true;
-            for (int i = 0; i < segmentInfos_.size(); i++) {
-                if (segmentInfos_.info(i)->name() == segmentInfo->name()) {
-                    isNew = false;
-                    break;
-                }
-            }
-            if (isNew) {
-                segmentInfos_.add(segmentInfo);
-            }
-        }
+        publishFlushedSegments();
     }
 
     return nextSequenceNumber();
@@ -180,24 +158,26 @@ void IndexWriter::flush() {
     ensureOpen();
 
     // Flush DocumentsWriter (creates segment files)
-    int segmentsCreated = documentsWriter_->flush();
+    documentsWriter_->flush();
 
-    // Add new segments to SegmentInfos
-    if (segmentsCreated > 0) {
-        for (const auto& segmentInfo : documentsWriter_->getSegmentInfos()) {
-            // Check if this segment is new
-            bool isNew = true;
-            for (int i = 0; i < segmentInfos_.size(); i++) {
-                if (segmentInfos_.info(i)->name() == segmentInfo->name()) {
-                    isNew = false;
-                    break;
-                }
-            }
-            if (isNew) {
-                segmentInfos_.add(segmentInfo);
-            }
-        }
+    // Add new segments to SegmentInfos. Buffered deletes are applied even
+    // if nothing was flushed.
+    publishFlushedSegments();
+}
+
+void IndexWriter::publishFlushedSegments() {
+    auto segments = documentsWriter_->getSegmentInfos();
+    std::vector<std::shared_ptr<SegmentInfo>> newSegments(
+        segments.begin() + std::min(publishedSegments_, segments.size()), segments.end());
+    publishedSegments_ = segments.size();
+
+    // Apply deletes before publishing, while the new segments are told apart
+    applyBufferedDeletes(newSegments);
+
+    for (const auto& segmentInfo : newSegments) {
+        segmentInfos_.add(segmentInfo);
     }
+    flushedDocUpto_ = documentsWriter_->getNumDocsAdded();
 }
 
 void IndexWriter::rollback() {
@@ -207,6 +187,9 @@ void IndexWriter::rollback() {
 
     // 1. Discard all pending documents in DocumentsWriter
     documentsWriter_->reset();
+    bufferedDeletes_.clear();
+    publishedSegments_ = 0;
+    flushedDocUpto_ = 0;
 
     // 2. Reset to last committed state (if exists)
     try {
@@ -320,6 +303,14 @@ int IndexWriter::getNumDocsAdded() const {
     return documentsWriter_ ? documentsWriter_->getNumDocsAdded() : 0;
 }
 
+int IndexWriter::getNumDeletedDocs() const {
+    int deleted = 0;
+    for (int i = 0; i < segmentInfos_.size(); i++) {
+        deleted += segmentInfos_.info(i)->delCount();
+    }
+    return deleted;
+}
+
 void IndexWriter::initializeIndex() {
     // Determine if index exists and find max generation
     auto files = directory_.listAll();
@@ -464,64 +455,80 @@ void IndexWriter::writeSegmentsFile() {
     directory_.sync({filename});
 }
 
-void IndexWriter::applyDeletes(const Term& term) {
-    // Apply deletions to all existing segments
+void IndexWriter::applyBufferedDeletes(
+    const std::vector<std::shared_ptr<SegmentInfo>>& newSegments) {
+    if (bufferedDeletes_.empty()) {
+        return;
+    }
+
+    // Segments flushed before the deletes were buffered are deleted from in
+    // full (docBase -1). New segments hold the documents numbered from
+    // flushedDocUpto_ on, and a delete only hits those added before it.
+    std::vector<std::pair<std::shared_ptr<SegmentInfo>, int>> targets;
     for (int i = 0; i < segmentInfos_.size(); i++) {
-        auto segmentInfo = segmentInfos_.info(i);
+        targets.emplace_back(segmentInfos_.info(i), -1);
+    }
+    int nextBase = flushedDocUpto_;
+    for (const auto& segmentInfo : newSegments) {
+        targets.emplace_back(segmentInfo, nextBase);
+        nextBase += segmentInfo->maxDoc();
+    }
 
+    for (const auto& [segmentInfo, docBase] : targets) {
         try {
-            // Open segment reader
+            // Open each segment once and seek all buffered terms in it
             auto reader = SegmentReader::open(directory_, segmentInfo);
 
-            // Get terms for the field
-            Terms* terms = reader->terms(term.field());
-            if (!terms) {
-                continue;  // Field doesn't exist in this segment
-            }
-
-            // Get terms enum and seek to term
-            std::unique_ptr<TermsEnum> termsEnum = terms->iterator();
-            if (!termsEnum->seekExact(term.bytes())) {
-                continue;  // Term doesn't exist in this segment
-            }
-
-            // Get postings enum to iterate over matching documents
-            std::unique_ptr<PostingsEnum> postings = termsEnum->postings();
-            if (!postings) {
-                continue;
-            }
-
-            // Load or create live docs bitset
             std::unique_ptr<util::BitSet> liveDocs;
             int maxDoc = segmentInfo->maxDoc();
+            int deletedCount = 0;
 
-            // Check if segment already has deletions
-            if (segmentInfo->hasDeletions()) {
-                // Load existing live docs
-                codecs::LiveDocsFormat format;
-                liveDocs = format.readLiveDocs(directory_, segmentInfo->name(), maxDoc);
-                if (!liveDocs) {
-                    // Failed to load - create new one
-                    liveDocs = std::make_unique<util::BitSet>(maxDoc);
-                    for (int d = 0; d < maxDoc; d++) {
-                        liveDocs->set(d);  // All live initially
-                    }
+            for (const auto& buffered : bufferedDeletes_) {
+                if (docBase >= 0 && buffered.docUpto <= docBase) {
+                    continue;  // Whole segment was added after this delete
                 }
-            } else {
-                // No deletions yet - create new bitset with all docs live
-                liveDocs = std::make_unique<util::BitSet>(maxDoc);
-                for (int d = 0; d < maxDoc; d++) {
-                    liveDocs->set(d);
+
+                // Get terms for the field
+                Terms* terms = reader->terms(buffered.term.field());
+                if (!terms) {
+                    continue;  // Field doesn't exist in this segment
                 }
-            }
 
-            // Collect documents to delete
-            int deletedCount = 0;
-            int docID;
-            while ((docID = postings->nextDoc()) != PostingsEnum::NO_MORE_DOCS) {
-                if (liveDocs->get(docID)) {
-                    liveDocs->clear(docID);  // Mark as deleted
-                    deletedCount++;
+                // Get terms enum and seek to term
+                std::unique_ptr<TermsEnum> termsEnum = terms->iterator();
+                if (!termsEnum->seekExact(buffered.term.bytes())) {
+                    continue;  // Term doesn't exist in this segment
+                }
+
+                std::unique_ptr<PostingsEnum> postings = termsEnum->postings();
+                if (!postings) {
+                    continue;
+                }
+
+                int docID;
+                while ((docID = postings->nextDoc()) != PostingsEnum::NO_MORE_DOCS) {
+                    if (docBase >= 0 && docBase + docID >= buffered.docUpto) {
+                        break;  // Added after the delete
+                    }
+
+                    // Load or create live docs bitset on the first hit
+                    if (!liveDocs) {
+                        if (segmentInfo->hasDeletions()) {
+                            codecs::LiveDocsFormat format;
+                            liveDocs = format.readLiveDocs(directory_, segmentInfo->name(), maxDoc);
+                        }
+                        if (!liveDocs) {
+                            liveDocs = std::make_unique<util::BitSet>(maxDoc);
+                            for (int d = 0; d < maxDoc; d++) {
+                                liveDocs->set(d);  // All live initially
+                            }
+                        }
+                    }
+
+                    if (liveDocs->get(docID)) {
+                        liveDocs->clear(docID);  // Mark as deleted
+                        deletedCount++;
+                    }
                 }
             }
 
@@ -530,7 +537,7 @@ void IndexWriter::applyDeletes(const Term& term) {
                 int newDelCount = segmentInfo->delCount() + deletedCount;
                 segmentInfo->setDelCount(newDelCount);
 
-                // Write updated .liv file
+                // Write updated .liv file once per segment
                 codecs::LiveDocsFormat format;
                 format.writeLiveDocs(directory_, segmentInfo->name(), *liveDocs, newDelCount);
             }
@@ -543,6 +550,8 @@ void IndexWriter::applyDeletes(const Term& term) {
             continue;
         }
     }
+
+    bufferedDeletes_.clear();
 }
 
 void IndexWriter::executeMerges(MergeSpecification* spec) {
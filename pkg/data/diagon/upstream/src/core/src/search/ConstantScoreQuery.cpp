@@ -0,0 +1,123 @@
+// Copyright 2024 Diagon Project
+// Licensed under the Apache License, Version 2.0
+
+#include "diagon/search/ConstantScoreQuery.h"
+
+#include "diagon/index/LeafReaderContext.h"
+#include "diagon/search/IndexSearcher.h"
+#include "diagon/search/Scorer.h"
+
+#include <sstream>
+#include <stdexcept>
+
+namespace diagon {
+namespace search {
+
+// ==================== ConstantScoreScorer ====================
+
+/**
+ * ConstantScoreScorer - Iterates the wrapped scorer's matches and returns a
+ * fixed score for each
+ */
+class ConstantScoreScorer : public Scorer {
+public:
+    ConstantScoreScorer(const Weight& weight, std::unique_ptr<Scorer> inner, float score)
+        : weight_(weight)
+        , inner_(std::move(inner))
+        , score_(score) {}
+
+    int docID() const override { return inner_->docID(); }
+
+    int nextDoc() override { return inner_->nextDoc(); }
+
+    int advance(int target) override { return inner_->advance(target); }
+
+    int64_t cost() const override { return inner_->cost(); }
+
+    float score() const override { return score_; }
+
+    float getMaxScore(int upTo) const override { return score_; }
+
+    const Weight& getWeight() const override { return weight_; }
+
+private:
+    const Weight& weight_;
+    std::unique_ptr<Scorer> inner_;
+    float score_;
+};
+
+// ==================== ConstantScoreWeight ====================
+
+class ConstantScoreWeight : public Weight {
+public:
+    ConstantScoreWeight(const ConstantScoreQuery& query, std::unique_ptr<Weight> inner, float score)
+        : query_(query)
+        , inner_(std::move(inner))
+        , score_(score) {}
+
+    std::unique_ptr<Scorer> scorer(const index::LeafReaderContext& context) const override {
+        auto inner = inner_->scorer(context);
+        if (!inner) {
+            return nullptr;
+        }
+        return std::make_unique<ConstantScoreScorer>(*this, std::move(inner), score_);
+    }
+
+    const Query& getQuery() const override { return query_; }
+
+    std::string toString() const override {
+        std::ostringstream oss;
+        oss << "weight(" << query_.toString("") << ")";
+        return oss.str();
+    }
+
+private:
+    const ConstantScoreQuery& query_;
+    std::unique_ptr<Weight> inner_;
+    float score_;
+};
+
+// ==================== ConstantScoreQuery ====================
+
+ConstantScoreQuery::ConstantScoreQuery(std::shared_ptr<Query> query, float score)
+    : query_(std::move(query))
+    , score_(score) {
+    if (!query_) {
+        throw std::invalid_argument("ConstantScoreQuery requires a query");
+    }
+}
+
+std::unique_ptr<Weight> ConstantScoreQuery::createWeight(IndexSearcher& searcher,
+                                                          ScoreMode scoreMode, float boost) const {
+    auto inner = query_->createWeight(searcher, ScoreMode::COMPLETE_NO_SCORES, 1.0f);
+    return std::make_unique<ConstantScoreWeight>(*this, std::move(inner), score_ * boost);
+}
+
+std::string ConstantScoreQuery::toString(const std::string& field) const {
+    std::ostringstream oss;
+    oss << "ConstantScore(" << query_->toString(field) << ")";
+    if (score_ != 1.0f) {
+        oss << "^" << score_;
+    }
+    return oss.str();
+}
+
+bool ConstantScoreQuery::equals(const Query& other) const {
+    if (auto* csq = dynamic_cast<const ConstantScoreQuery*>(&other)) {
+        return score_ == csq->score_ && query_->equals(*csq->query_);
+    }
+    return false;
+}
+
+size_t ConstantScoreQuery::hashCode() const {
+    size_t h = query_->hashCode();
+    h ^= std::hash<float>{}(score_) + 0x9e3779b9 + (h << 6) + (h >> 2);
+    return h;
+}
+
+std::unique_ptr<Query> ConstantScoreQuery::clone() const {
+    return std::make_unique<ConstantScoreQuery>(std::shared_ptr<Query>(query_->clone()), score_);
+}
+
+}  // namespace search
+}  // namespace diagon
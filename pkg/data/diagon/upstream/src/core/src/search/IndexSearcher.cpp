@@ -9,6 +9,7 @@
 #include "diagon/search/TopDocs.h"
 #include "diagon/search/TopScoreDocCollector.h"
 #include "diagon/search/Weight.h"
+#include "diagon/util/Bits.h"
 
 namespace diagon {
 namespace search {
@@ -72,9 +73,15 @@ void IndexSearcher::search(const Query& query, Collector* collector) {
         ScorerScorable scorable(scorer.get());
         leafCollector->setScorer(&scorable);
 
+        // Deleted documents are cleared in the segment's live docs
+        const util::Bits* liveDocs = ctx.reader->getLiveDocs();
+
         // Iterate matching documents
         int doc;
         while ((doc = scorer->nextDoc()) != DocIdSetIterator::NO_MORE_DOCS) {
+            if (liveDocs && !liveDocs->get(doc)) {
+                continue;
+            }
             leafCollector->collect(doc);
         }
     }
@@ -3,6 +3,7 @@ package data
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"time"
 
 	pb "github.com/conjugate/conjugate/pkg/common/proto"
@@ -29,6 +30,10 @@ func NewDataService(node *DataNode, logger *zap.Logger) *DataService {
 	}
 }
 
+// createShardMappingsSetting is the CreateShard setting holding the index's
+// field mappings as JSON, as sent by the master
+const createShardMappingsSetting = "mappings"
+
 // CreateShard creates a new shard on this data node
 func (s *DataService) CreateShard(ctx context.Context, req *pb.CreateShardRequest) (*pb.CreateShardResponse, error) {
 	s.logger.Info("CreateShard request",
@@ -44,10 +49,40 @@ func (s *DataService) CreateShard(ctx context.Context, req *pb.CreateShardReques
 		return nil, status.Error(codes.InvalidArgument, "shard_id must be non-negative")
 	}
 
+	var mappings map[string]*pb.FieldMapping
+	if encoded, ok := req.Settings[createShardMappingsSetting]; ok {
+		if err := json.Unmarshal([]byte(encoded), &mappings); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid mappings: %v", err)
+		}
+	}
+	batch, err := ParseBatchSettings(req.Settings)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
 	// Create shard
-	if err := s.node.shards.CreateShard(ctx, req.IndexName, req.ShardId, req.IsPrimary); err != nil {
+	if err := s.node.shards.CreateShardWithMappings(ctx, req.IndexName, req.ShardId, req.IsPrimary, mappings); err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to create shard: %v", err)
 	}
+	shard, err := s.node.shards.GetShard(req.IndexName, req.ShardId)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get created shard: %v", err)
+	}
+	shard.SetBatchConfig(batch.CommitBatchSize, batch.CommitInterval, batch.RefreshInterval)
+	shard.UpdatePrimaryTerm(req.PrimaryTerm, req.IsPrimary)
+
+	// A replica stays initializing until it has recovered from its
+	// primary, which starts on the next routing refresh
+	if !req.IsPrimary {
+		shard.setState(ShardStateInitializing)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), defaultRoutingRefreshInterval)
+			defer cancel()
+			if err := s.node.replicator.Refresh(ctx); err != nil {
+				s.logger.Warn("Failed to refresh replication groups", zap.Error(err))
+			}
+		}()
+	}
 
 	shardKey := shardKey(req.IndexName, req.ShardId)
 
@@ -125,6 +160,28 @@ func (s *DataService) RefreshShard(ctx context.Context, req *pb.RefreshShardRequ
 	}, nil
 }
 
+// UpdateShardSettings applies an index's dynamic settings to a shard
+func (s *DataService) UpdateShardSettings(ctx context.Context, req *pb.UpdateShardSettingsRequest) (*pb.UpdateShardSettingsResponse, error) {
+	s.logger.Info("UpdateShardSettings request",
+		zap.String("index", req.IndexName),
+		zap.Int32("shard_id", req.ShardId),
+		zap.Any("settings", req.Settings))
+
+	// Get shard
+	shard, err := s.node.shards.GetShard(req.IndexName, req.ShardId)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "shard not found: %v", err)
+	}
+
+	if err := shard.ApplyBatchSettings(req.Settings); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return &pb.UpdateShardSettingsResponse{
+		Acknowledged: true,
+	}, nil
+}
+
 // FlushShard flushes shard data to disk
 func (s *DataService) FlushShard(ctx context.Context, req *pb.FlushShardRequest) (*pb.FlushShardResponse, error) {
 	s.logger.Debug("FlushShard request",
@@ -142,13 +199,14 @@ func (s *DataService) FlushShard(ctx context.Context, req *pb.FlushShardRequest)
 		return nil, status.Errorf(codes.Internal, "failed to flush pending documents: %v", err)
 	}
 
-	// Flush Diagon translog
+	// Commit Diagon and truncate the translog
 	if err := shard.FlushDiagon(); err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to flush shard: %v", err)
 	}
 
 	return &pb.FlushShardResponse{
-		Acknowledged: true,
+		Acknowledged:       true,
+		TranslogGeneration: shard.TranslogGeneration(),
 	}, nil
 }
 
@@ -172,6 +230,9 @@ func (s *DataService) IndexDocument(ctx context.Context, req *pb.IndexDocumentRe
 		s.logger.Error("IndexDocument validation failed: document is required")
 		return nil, status.Error(codes.InvalidArgument, "document is required")
 	}
+	if !validRefreshPolicy(req.Refresh) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid refresh policy: %q", req.Refresh)
+	}
 
 	s.logger.Info("IndexDocument validation passed", zap.String("doc_id", req.DocId))
 
@@ -190,6 +251,14 @@ func (s *DataService) IndexDocument(ctx context.Context, req *pb.IndexDocumentRe
 
 	s.logger.Info("Got shard successfully", zap.String("doc_id", req.DocId))
 
+	group, err := s.node.replicator.group(ctx, shard)
+	if err != nil {
+		return nil, replicationStatus(err)
+	}
+	if err := group.checkActiveShards(req.WaitForActiveShards); err != nil {
+		return nil, writeShardsStatus(err)
+	}
+
 	// Convert protobuf Struct to map
 	doc := req.Document.AsMap()
 	s.logger.Info("Converted document to map",
@@ -198,7 +267,8 @@ func (s *DataService) IndexDocument(ctx context.Context, req *pb.IndexDocumentRe
 
 	// Index document
 	s.logger.Info("Calling shard.IndexDocument", zap.String("doc_id", req.DocId))
-	if err := shard.IndexDocument(ctx, req.DocId, doc); err != nil {
+	op, err := shard.IndexDocumentOp(ctx, req.DocId, doc)
+	if err != nil {
 		s.logger.Error("shard.IndexDocument FAILED",
 			zap.String("doc_id", req.DocId),
 			zap.Error(err))
@@ -207,6 +277,16 @@ func (s *DataService) IndexDocument(ctx context.Context, req *pb.IndexDocumentRe
 
 	s.logger.Info("shard.IndexDocument SUCCESS", zap.String("doc_id", req.DocId))
 
+	// Acknowledge only once the in-sync replicas have the write too
+	shards, err := s.node.replicator.replicate(ctx, shard, group, []*TranslogOperation{op})
+	if err != nil {
+		return nil, replicationStatus(err)
+	}
+
+	if err := applyRefreshPolicy(ctx, shard, req.Refresh); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to refresh shard: %v", err)
+	}
+
 	s.logger.Info("Returning IndexDocumentResponse",
 		zap.String("doc_id", req.DocId),
 		zap.Int64("version", 1))
@@ -215,6 +295,9 @@ func (s *DataService) IndexDocument(ctx context.Context, req *pb.IndexDocumentRe
 		Acknowledged: true,
 		DocId:        req.DocId,
 		Version:      1, // TODO: Implement versioning
+		SeqNo:        op.SeqNo,
+		PrimaryTerm:  op.PrimaryTerm,
+		Shards:       shards,
 	}, nil
 }
 
@@ -282,6 +365,9 @@ func (s *DataService) DeleteDocument(ctx context.Context, req *pb.DeleteDocument
 	if req.DocId == "" {
 		return nil, status.Error(codes.InvalidArgument, "doc_id is required")
 	}
+	if !validRefreshPolicy(req.Refresh) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid refresh policy: %q", req.Refresh)
+	}
 
 	// Get shard
 	shard, err := s.node.shards.GetShard(req.IndexName, req.ShardId)
@@ -289,15 +375,42 @@ func (s *DataService) DeleteDocument(ctx context.Context, req *pb.DeleteDocument
 		return nil, status.Errorf(codes.NotFound, "shard not found: %v", err)
 	}
 
+	group, err := s.node.replicator.group(ctx, shard)
+	if err != nil {
+		return nil, replicationStatus(err)
+	}
+	if err := group.checkActiveShards(req.WaitForActiveShards); err != nil {
+		return nil, writeShardsStatus(err)
+	}
+
 	// Delete document
-	if err := shard.DeleteDocument(ctx, req.DocId); err != nil {
+	op, err := shard.DeleteDocumentOp(ctx, req.DocId)
+	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to delete document: %v", err)
 	}
 
-	return &pb.DeleteDocumentResponse{
+	resp := &pb.DeleteDocumentResponse{
 		Acknowledged: true,
-		Found:        true, // TODO: Check if document existed
-	}, nil
+		Found:        op != nil,
+	}
+	if op == nil {
+		return resp, nil
+	}
+
+	// Acknowledge only once the in-sync replicas have the delete too
+	shards, err := s.node.replicator.replicate(ctx, shard, group, []*TranslogOperation{op})
+	if err != nil {
+		return nil, replicationStatus(err)
+	}
+
+	if err := applyRefreshPolicy(ctx, shard, req.Refresh); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to refresh shard: %v", err)
+	}
+
+	resp.SeqNo = op.SeqNo
+	resp.PrimaryTerm = op.PrimaryTerm
+	resp.Shards = shards
+	return resp, nil
 }
 
 // BulkIndex indexes multiple documents in a single request
@@ -314,6 +427,9 @@ func (s *DataService) BulkIndex(ctx context.Context, req *pb.BulkIndexRequest) (
 	if len(req.Items) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "items are required")
 	}
+	if !validRefreshPolicy(req.Refresh) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid refresh policy: %q", req.Refresh)
+	}
 
 	// Get shard
 	shard, err := s.node.shards.GetShard(req.IndexName, req.ShardId)
@@ -321,14 +437,26 @@ func (s *DataService) BulkIndex(ctx context.Context, req *pb.BulkIndexRequest) (
 		return nil, status.Errorf(codes.NotFound, "shard not found: %v", err)
 	}
 
+	group, err := s.node.replicator.group(ctx, shard)
+	if err != nil {
+		return nil, replicationStatus(err)
+	}
+	if err := group.checkActiveShards(req.WaitForActiveShards); err != nil {
+		return nil, writeShardsStatus(err)
+	}
+
 	startTime := time.Now()
 	hasErrors := false
 	items := make([]*pb.BulkIndexItemResponse, 0, len(req.Items))
+	ops := make([]*TranslogOperation, 0, len(req.Items))
 
 	// Index each document
 	for _, item := range req.Items {
 		doc := item.Document.AsMap()
-		err := shard.IndexDocument(ctx, item.DocId, doc)
+		op, err := shard.IndexDocumentOp(ctx, item.DocId, doc)
+		if err == nil {
+			ops = append(ops, op)
+		}
 
 		itemResp := &pb.BulkIndexItemResponse{
 			DocId: item.DocId,
@@ -345,6 +473,16 @@ func (s *DataService) BulkIndex(ctx context.Context, req *pb.BulkIndexRequest) (
 		items = append(items, itemResp)
 	}
 
+	// One replication request carries the whole batch
+	if _, err := s.node.replicator.replicate(ctx, shard, group, ops); err != nil {
+		return nil, replicationStatus(err)
+	}
+
+	// One refresh covers the whole batch
+	if err := applyRefreshPolicy(ctx, shard, req.Refresh); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to refresh shard: %v", err)
+	}
+
 	tookMillis := time.Since(startTime).Milliseconds()
 
 	return &pb.BulkIndexResponse{
@@ -354,6 +492,70 @@ func (s *DataService) BulkIndex(ctx context.Context, req *pb.BulkIndexRequest) (
 	}, nil
 }
 
+// ReplicateOperations applies the writes a primary forwards to this replica
+func (s *DataService) ReplicateOperations(ctx context.Context, req *pb.ReplicateOperationsRequest) (*pb.ReplicateOperationsResponse, error) {
+	s.logger.Debug("ReplicateOperations request",
+		zap.String("index", req.IndexName),
+		zap.Int32("shard_id", req.ShardId),
+		zap.Int64("primary_term", req.PrimaryTerm),
+		zap.Int("operations", len(req.Operations)))
+
+	// Validate request
+	if req.IndexName == "" {
+		return nil, status.Error(codes.InvalidArgument, "index name is required")
+	}
+
+	// Apply, or hold while the shard recovers its files
+	checkpoint, err := s.node.replicator.ApplyOperations(ctx, req.IndexName, req.ShardId, req.PrimaryTerm, replicationOperationsFromProto(req.Operations))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrShardNotFound):
+			return nil, status.Errorf(codes.NotFound, "shard not found: %v", err)
+		case errors.Is(err, ErrStalePrimaryTerm):
+			return nil, status.Error(codes.Aborted, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, "failed to apply replicated operations: %v", err)
+	}
+
+	return &pb.ReplicateOperationsResponse{
+		LocalCheckpoint: checkpoint,
+	}, nil
+}
+
+// RecoverShard streams what a replica recovering from this primary misses:
+// a plan, then the committed files unless the translog holds every
+// operation after the replica's local checkpoint, then those operations
+func (s *DataService) RecoverShard(req *pb.RecoverShardRequest, stream pb.DataService_RecoverShardServer) error {
+	s.logger.Info("RecoverShard request",
+		zap.String("index", req.IndexName),
+		zap.Int32("shard_id", req.ShardId),
+		zap.String("target", req.TargetNodeId),
+		zap.Int64("local_checkpoint", req.LocalCheckpoint))
+
+	// Validate request
+	if req.IndexName == "" {
+		return status.Error(codes.InvalidArgument, "index name is required")
+	}
+	if req.TargetNodeId == "" {
+		return status.Error(codes.InvalidArgument, "target node id is required")
+	}
+
+	err := s.node.replicator.RecoverReplica(stream.Context(), req, stream.Send)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrShardNotFound):
+		return status.Errorf(codes.NotFound, "shard not found: %v", err)
+	case errors.Is(err, ErrNotPrimary), errors.Is(err, ErrNotRecovering):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, ErrRoutingUnknown):
+		return status.Error(codes.Unavailable, err.Error())
+	case status.Code(err) != codes.Unknown:
+		return err
+	}
+	return status.Errorf(codes.Internal, "failed to recover replica: %v", err)
+}
+
 // Search executes a search query on a shard
 func (s *DataService) Search(ctx context.Context, req *pb.SearchRequest) (*pb.SearchResponse, error) {
 	s.logger.Info("==> DataService.Search ENTRY",
@@ -370,6 +572,9 @@ func (s *DataService) Search(ctx context.Context, req *pb.SearchRequest) (*pb.Se
 		s.logger.Error("Search failed: query is required")
 		return nil, status.Error(codes.InvalidArgument, "query is required")
 	}
+	if req.From < 0 || req.Size < 0 {
+		return nil, status.Error(codes.InvalidArgument, "from and size must not be negative")
+	}
 
 	// Get shard
 	shard, err := s.node.shards.GetShard(req.IndexName, req.ShardId)
@@ -384,7 +589,7 @@ func (s *DataService) Search(ctx context.Context, req *pb.SearchRequest) (*pb.Se
 		zap.Int32("shard_id", req.ShardId))
 
 	// Execute search (UDF queries are embedded in req.Query JSON)
-	result, err := shard.Search(ctx, req.Query)
+	result, err := shard.SearchPage(ctx, req.Query, req.FilterExpression, req.Aggregations, req.Sort, int(req.From), int(req.Size))
 
 	s.logger.Info("DEBUG: shard.Search returned",
 		zap.Bool("has_result", result != nil),
@@ -413,11 +618,19 @@ func (s *DataService) Search(ctx context.Context, req *pb.SearchRequest) (*pb.Se
 			continue
 		}
 
-		hits = append(hits, &pb.SearchHit{
+		pbHit := &pb.SearchHit{
 			Id:     hit.ID,
 			Score:  hit.Score,
 			Source: docStruct,
-		})
+		}
+		for _, value := range hit.Sort {
+			sortValue, err := structpb.NewValue(value)
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "invalid sort value: %v", err)
+			}
+			pbHit.Sort = append(pbHit.Sort, sortValue)
+		}
+		hits = append(hits, pbHit)
 	}
 
 	// Convert aggregations
@@ -551,6 +764,37 @@ func (s *DataService) GetNodeStats(ctx context.Context, req *pb.GetNodeStatsRequ
 
 // Helper functions
 
+// validRefreshPolicy reports whether policy is a refresh value accepted on
+// writes. An empty policy means "false".
+func validRefreshPolicy(policy string) bool {
+	switch policy {
+	case "", RefreshPolicyFalse, RefreshPolicyTrue, RefreshPolicyWaitFor:
+		return true
+	}
+	return false
+}
+
+// writeShardsStatus converts a wait_for_active_shards failure to a gRPC
+// status
+func writeShardsStatus(err error) error {
+	if errors.Is(err, ErrNotEnoughActiveShards) {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+// applyRefreshPolicy makes an acknowledged write searchable as requested:
+// "true" refreshes the shard now and "wait_for" waits for the next refresh
+func applyRefreshPolicy(ctx context.Context, shard *Shard, policy string) error {
+	switch policy {
+	case RefreshPolicyTrue:
+		return shard.Refresh()
+	case RefreshPolicyWaitFor:
+		return shard.WaitForRefresh(ctx)
+	}
+	return nil
+}
+
 func (s *DataService) convertShardStateToProto(state ShardState) pb.ShardInfo_ShardState {
 	switch state {
 	case ShardStateInitializing:
@@ -594,9 +838,11 @@ func convertAggregations(aggs map[string]diagon.AggregationResult) map[string]*p
 
 		// Convert based on aggregation type
 		switch agg.Type {
-		case "terms", "histogram", "date_histogram", "range", "filters":
-			// Bucket aggregations
+		case "terms", "histogram", "date_histogram", "range", "filters", "composite":
+			// Bucket aggregations; composite buckets carry their keys as JSON
 			pbAgg.Buckets = convertBuckets(agg.Buckets)
+			pbAgg.DocCountErrorUpperBound = agg.DocCountErrorUpperBound
+			pbAgg.SumOtherDocCount = agg.SumOtherDocCount
 
 		case "stats", "extended_stats":
 			// Stats aggregations
@@ -607,45 +853,53 @@ func convertAggregations(aggs map[string]diagon.AggregationResult) map[string]*p
 			pbAgg.Sum = agg.Sum
 
 			if agg.Type == "extended_stats" {
-// 				pbAgg.SumOfSquares = agg.SumOfSquares
-// 				pbAgg.Variance = agg.Variance
-// 				pbAgg.StdDeviation = agg.StdDeviation
-// 				pbAgg.StdDeviationBoundsUpper = agg.StdDeviationBoundsUpper
-// 				pbAgg.StdDeviationBoundsLower = agg.StdDeviationBoundsLower
+				pbAgg.SumOfSquares = agg.SumOfSquares
+				pbAgg.Variance = agg.Variance
+				pbAgg.StdDeviation = agg.StdDeviation
+				pbAgg.StdDeviationBoundsUpper = agg.StdDeviationBoundsUpper
+				pbAgg.StdDeviationBoundsLower = agg.StdDeviationBoundsLower
 			}
 
 		case "avg":
 			// Average aggregation
+			pbAgg.Count = agg.Count
 			pbAgg.Avg = agg.Avg
+			pbAgg.Sum = agg.Sum
 
 		case "min":
 			// Minimum aggregation
+			pbAgg.Count = agg.Count
 			pbAgg.Min = agg.Min
 
 		case "max":
 			// Maximum aggregation
+			pbAgg.Count = agg.Count
 			pbAgg.Max = agg.Max
 
 		case "sum":
 			// Sum aggregation
+			pbAgg.Count = agg.Count
 			pbAgg.Sum = agg.Sum
 
 		case "value_count":
 			// Value count aggregation
 			pbAgg.Count = agg.Count
 
-		case "percentiles":
-			// Percentiles aggregation
+		case "percentiles", "percentile_ranks":
+			// Percentiles aggregation, with the digest the coordinator merges
 			if agg.Values != nil {
 				pbAgg.Values = make(map[string]float64, len(agg.Values))
 				for k, v := range agg.Values {
 					pbAgg.Values[k] = v
 				}
 			}
+			pbAgg.Count = agg.Count
+			pbAgg.Sketch = agg.Sketch
 
 		case "cardinality":
-			// Cardinality aggregation
+			// Cardinality aggregation, with the sketch the coordinator unions
 			pbAgg.Value = agg.Value
+			pbAgg.Sketch = agg.Sketch
 		}
 
 		result[name] = pbAgg
@@ -678,6 +932,19 @@ func convertBuckets(buckets []map[string]interface{}) []*pb.AggregationBucket {
 			pbBucket.Key = keyAsString
 		}
 
+		// Composite keys are objects of source name to value
+		if key, ok := bucket["key"].(map[string]interface{}); ok {
+			pbBucket.CompositeKey, _ = json.Marshal(key)
+		}
+
+		// Extract range bounds (omitted when unbounded)
+		if from, ok := bucket["from"].(float64); ok {
+			pbBucket.From = &from
+		}
+		if to, ok := bucket["to"].(float64); ok {
+			pbBucket.To = &to
+		}
+
 		// Extract doc_count
 		if docCount, ok := bucket["doc_count"].(int64); ok {
 			pbBucket.DocCount = docCount
@@ -685,8 +952,10 @@ func convertBuckets(buckets []map[string]interface{}) []*pb.AggregationBucket {
 			pbBucket.DocCount = int64(docCount)
 		}
 
-		// TODO: Handle sub-aggregations if needed
-		// pbBucket.SubAggregations = convertAggregations(bucket["sub_aggs"])
+		// Convert sub-aggregations computed over the bucket's documents
+		if subAggs, ok := bucket["aggregations"].(map[string]diagon.AggregationResult); ok {
+			pbBucket.SubAggregations = convertAggregations(subAggs)
+		}
 
 		result = append(result, pbBucket)
 	}
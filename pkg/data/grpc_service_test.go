@@ -0,0 +1,49 @@
+package data
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/conjugate/conjugate/pkg/data/diagon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertAggregationsComposite(t *testing.T) {
+	aggs := map[string]diagon.AggregationResult{
+		"by_brand": {
+			Type: "composite",
+			Buckets: []map[string]interface{}{
+				{
+					"key":       map[string]interface{}{"brand": "acme", "year": float64(2024)},
+					"doc_count": int64(3),
+					"aggregations": map[string]diagon.AggregationResult{
+						"total": {Type: "sum", Count: 3, Sum: 42},
+					},
+				},
+				{
+					"key":       map[string]interface{}{"brand": "zenith", "year": nil},
+					"doc_count": int64(1),
+				},
+			},
+		},
+	}
+
+	result := convertAggregations(aggs)
+	composite := result["by_brand"]
+	require.NotNil(t, composite)
+	assert.Equal(t, "composite", composite.Type)
+	require.Len(t, composite.Buckets, 2)
+
+	var key map[string]interface{}
+	require.NoError(t, json.Unmarshal(composite.Buckets[0].CompositeKey, &key))
+	assert.Equal(t, map[string]interface{}{"brand": "acme", "year": float64(2024)}, key)
+	assert.Equal(t, int64(3), composite.Buckets[0].DocCount)
+	require.Contains(t, composite.Buckets[0].SubAggregations, "total")
+	assert.Equal(t, float64(42), composite.Buckets[0].SubAggregations["total"].Sum)
+
+	// A missing bucket keeps its null value
+	require.NoError(t, json.Unmarshal(composite.Buckets[1].CompositeKey, &key))
+	assert.Equal(t, map[string]interface{}{"brand": "zenith", "year": nil}, key)
+	assert.Equal(t, int64(1), composite.Buckets[1].DocCount)
+}
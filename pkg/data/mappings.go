@@ -0,0 +1,121 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	pb "github.com/conjugate/conjugate/pkg/common/proto"
+	"github.com/conjugate/conjugate/pkg/data/diagon"
+)
+
+// mappingsFile holds a shard's field mappings next to its index, so they
+// apply again when the shard is reloaded and its translog replayed
+const mappingsFile = "mappings.json"
+
+// FieldTypeSparseVector maps a field to token→weight objects scored by
+// neural_sparse queries
+const FieldTypeSparseVector = "sparse_vector"
+
+// FieldTypeKNNVector maps a field to dense vectors of a fixed dimension,
+// searched by knn queries
+const FieldTypeKNNVector = "knn_vector"
+
+// SetMappings applies an index's field mappings to the shard and saves them
+// with the shard. Mappings only affect documents indexed afterwards.
+func (s *Shard) SetMappings(mappings map[string]*pb.FieldMapping) error {
+	if err := s.applyMappings(mappings); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(mappings)
+	if err != nil {
+		return fmt.Errorf("failed to encode mappings: %w", err)
+	}
+	path := filepath.Join(s.Path, mappingsFile)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("failed to save mappings: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to save mappings: %w", err)
+	}
+	return nil
+}
+
+// GetMappings returns the shard's field mappings
+func (s *Shard) GetMappings() map[string]*pb.FieldMapping {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.mappings
+}
+
+// loadMappings applies the mappings saved with the shard, if any
+func (s *Shard) loadMappings() error {
+	data, err := os.ReadFile(filepath.Join(s.Path, mappingsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read mappings: %w", err)
+	}
+
+	var mappings map[string]*pb.FieldMapping
+	if err := json.Unmarshal(data, &mappings); err != nil {
+		return fmt.Errorf("failed to decode mappings: %w", err)
+	}
+	return s.applyMappings(mappings)
+}
+
+// isVectorField reports whether a field mapping is for one of the vector
+// types, which the Diagon shard indexes itself
+func isVectorField(mapping *pb.FieldMapping) bool {
+	return mapping.GetType() == FieldTypeSparseVector || mapping.GetType() == FieldTypeKNNVector
+}
+
+// nestedVectorField returns the path and type of a vector field among the
+// properties of the object field at prefix, or "" if there is none
+func nestedVectorField(prefix string, properties map[string]*pb.FieldMapping) (string, string) {
+	for field, mapping := range properties {
+		path := prefix + "." + field
+		if isVectorField(mapping) {
+			return path, mapping.GetType()
+		}
+		if nested, fieldType := nestedVectorField(path, mapping.GetProperties()); nested != "" {
+			return nested, fieldType
+		}
+	}
+	return "", ""
+}
+
+// applyMappings configures the Diagon shard for the mapped field types
+func (s *Shard) applyMappings(mappings map[string]*pb.FieldMapping) error {
+	var sparseFields []string
+	knnFields := make(map[string]diagon.KNNField)
+	for field, mapping := range mappings {
+		switch mapping.GetType() {
+		case FieldTypeSparseVector:
+			sparseFields = append(sparseFields, field)
+		case FieldTypeKNNVector:
+			knnFields[field] = diagon.KNNField{
+				Dimension: int(mapping.GetDimension()),
+				SpaceType: mapping.GetSpaceType(),
+			}
+		}
+		// Object fields are stored whole, so their sub-fields cannot be indexed
+		if path, fieldType := nestedVectorField(field, mapping.GetProperties()); path != "" {
+			return fmt.Errorf("%s field %s must be a top-level field", fieldType, path)
+		}
+	}
+
+	if err := s.DiagonShard.SetKNNVectorFields(knnFields); err != nil {
+		return err
+	}
+	s.DiagonShard.SetSparseVectorFields(sparseFields)
+
+	s.mu.Lock()
+	s.mappings = mappings
+	s.mu.Unlock()
+	return nil
+}
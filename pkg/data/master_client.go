@@ -25,8 +25,12 @@ type MasterClient struct {
 	connected      bool
 	heartbeatStop  chan struct{}
 	heartbeatDone  chan struct{}
+	heartbeatSource HeartbeatSource
 }
 
+// HeartbeatSource collects the node stats and shard reports sent with each heartbeat
+type HeartbeatSource func() (*pb.NodeStats, []*pb.ShardReport)
+
 // NewMasterClient creates a new master client
 func NewMasterClient(nodeID, masterAddr string, logger *zap.Logger) *MasterClient {
 	return &MasterClient{
@@ -92,8 +96,17 @@ func (mc *MasterClient) Disconnect() error {
 	return nil
 }
 
-// Register registers this data node with the master
-func (mc *MasterClient) Register(ctx context.Context, bindAddr string, grpcPort int32, attributes *pb.NodeAttributes) error {
+// SetHeartbeatSource sets the function used to collect node stats and shard
+// reports for each heartbeat
+func (mc *MasterClient) SetHeartbeatSource(source HeartbeatSource) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.heartbeatSource = source
+}
+
+// Register registers this data node with the master, reporting the shards
+// it already hosts (e.g. shards recovered from disk after a restart)
+func (mc *MasterClient) Register(ctx context.Context, bindAddr string, grpcPort int32, attributes *pb.NodeAttributes, shards []*pb.ShardReport) error {
 	mc.mu.RLock()
 	if !mc.connected {
 		mc.mu.RUnlock()
@@ -105,7 +118,8 @@ func (mc *MasterClient) Register(ctx context.Context, bindAddr string, grpcPort
 	mc.logger.Info("Registering with master",
 		zap.String("node_id", mc.nodeID),
 		zap.String("bind_addr", bindAddr),
-		zap.Int32("grpc_port", grpcPort))
+		zap.Int32("grpc_port", grpcPort),
+		zap.Int("shards", len(shards)))
 
 	req := &pb.RegisterNodeRequest{
 		NodeId:     mc.nodeID,
@@ -113,6 +127,7 @@ func (mc *MasterClient) Register(ctx context.Context, bindAddr string, grpcPort
 		BindAddr:   bindAddr,
 		GrpcPort:   grpcPort,
 		Attributes: attributes,
+		Shards:     shards,
 	}
 
 	// Try to register, handle leader redirection
@@ -145,12 +160,12 @@ func (mc *MasterClient) Register(ctx context.Context, bindAddr string, grpcPort
 func (mc *MasterClient) StartHeartbeat(ctx context.Context, interval time.Duration) {
 	mc.logger.Info("Starting heartbeat", zap.Duration("interval", interval))
 
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
 	go func() {
 		defer close(mc.heartbeatDone)
 
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
 		for {
 			select {
 			case <-mc.heartbeatStop:
@@ -181,23 +196,19 @@ func (mc *MasterClient) sendHeartbeat(ctx context.Context) error {
 		return fmt.Errorf("not connected to master")
 	}
 	client := mc.client
+	source := mc.heartbeatSource
 	mc.mu.RUnlock()
 
-	// TODO: Collect actual node statistics
-	stats := &pb.NodeStats{
-		TotalShards:         0,
-		DocsCount:           0,
-		StoreSizeBytes:      0,
-		CpuUsagePercent:     0.0,
-		MemoryUsagePercent:  0.0,
-		DiskUsagePercent:    0.0,
-		SearchQueriesPerSec: 0,
-		IndexingRatePerSec:  0,
+	stats := &pb.NodeStats{}
+	var shards []*pb.ShardReport
+	if source != nil {
+		stats, shards = source()
 	}
 
 	req := &pb.NodeHeartbeatRequest{
 		NodeId: mc.nodeID,
 		Stats:  stats,
+		Shards: shards,
 	}
 
 	hbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
@@ -261,6 +272,52 @@ func (mc *MasterClient) GetIndexMetadata(ctx context.Context, indexName string)
 	return resp, nil
 }
 
+// FailShard asks the master to take a replica that failed a replicated
+// write out of the in-sync set. The master answers codes.Aborted when
+// primaryTerm is stale, that is when this node is no longer the primary.
+func (mc *MasterClient) FailShard(ctx context.Context, indexName string, shardID int32, nodeID string, primaryTerm int64, reason string) error {
+	mc.mu.RLock()
+	if !mc.connected {
+		mc.mu.RUnlock()
+		return fmt.Errorf("not connected to master")
+	}
+	client := mc.client
+	mc.mu.RUnlock()
+
+	req := &pb.FailShardRequest{
+		IndexName:   indexName,
+		ShardId:     shardID,
+		NodeId:      nodeID,
+		PrimaryTerm: primaryTerm,
+		Reason:      reason,
+	}
+
+	if _, err := client.FailShard(ctx, req); err != nil {
+		return fmt.Errorf("failed to fail shard: %w", err)
+	}
+
+	return nil
+}
+
+// ReportShardRecovery reports the progress of a replica on this node
+// recovering from its primary. The master answers codes.NotFound once the
+// copy is no longer allocated to this node.
+func (mc *MasterClient) ReportShardRecovery(ctx context.Context, req *pb.ReportShardRecoveryRequest) error {
+	mc.mu.RLock()
+	if !mc.connected {
+		mc.mu.RUnlock()
+		return fmt.Errorf("not connected to master")
+	}
+	client := mc.client
+	mc.mu.RUnlock()
+
+	if _, err := client.ReportShardRecovery(ctx, req); err != nil {
+		return fmt.Errorf("failed to report shard recovery: %w", err)
+	}
+
+	return nil
+}
+
 // Unregister removes this node from the master
 func (mc *MasterClient) Unregister(ctx context.Context) error {
 	mc.mu.RLock()
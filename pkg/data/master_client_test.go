@@ -150,6 +150,9 @@ func TestMasterClient_Register(t *testing.T) {
 			registerFunc: func(req *pb.RegisterNodeRequest) (*pb.RegisterNodeResponse, error) {
 				assert.Equal(t, "node-1", req.NodeId)
 				assert.Equal(t, pb.NodeType_NODE_TYPE_DATA, req.NodeType)
+				require.Len(t, req.Shards, 1)
+				assert.Equal(t, "products", req.Shards[0].IndexName)
+				assert.Equal(t, pb.ShardAllocation_SHARD_STATE_STARTED, req.Shards[0].State)
 				return &pb.RegisterNodeResponse{
 					Acknowledged:   true,
 					ClusterVersion: 1,
@@ -219,7 +222,11 @@ func TestMasterClient_Register(t *testing.T) {
 				Version:     "1.0.0",
 			}
 
-			err = client.Register(ctx, "localhost", 9090, attributes)
+			shards := []*pb.ShardReport{
+				{IndexName: "products", ShardId: 0, State: pb.ShardAllocation_SHARD_STATE_STARTED, DocsCount: 42},
+			}
+
+			err = client.Register(ctx, "localhost", 9090, attributes, shards)
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -232,9 +239,11 @@ func TestMasterClient_Register(t *testing.T) {
 
 func TestMasterClient_Heartbeat(t *testing.T) {
 	heartbeatCount := 0
+	var lastReq *pb.NodeHeartbeatRequest
 	mock := &mockMasterServer{
 		heartbeatFunc: func(req *pb.NodeHeartbeatRequest) (*pb.NodeHeartbeatResponse, error) {
 			heartbeatCount++
+			lastReq = req
 			assert.Equal(t, "node-1", req.NodeId)
 			return &pb.NodeHeartbeatResponse{
 				Acknowledged:   true,
@@ -263,10 +272,22 @@ func TestMasterClient_Heartbeat(t *testing.T) {
 	client.client = pb.NewMasterServiceClient(conn)
 	client.connected = true
 
+	client.SetHeartbeatSource(func() (*pb.NodeStats, []*pb.ShardReport) {
+		return &pb.NodeStats{TotalShards: 1, DocsCount: 42}, []*pb.ShardReport{
+			{IndexName: "products", ShardId: 0, State: pb.ShardAllocation_SHARD_STATE_STARTED, DocsCount: 42},
+		}
+	})
+
 	// Send single heartbeat
 	err = client.sendHeartbeat(ctx)
 	assert.NoError(t, err)
 	assert.Equal(t, 1, heartbeatCount)
+
+	// Verify stats and shard reports came from the heartbeat source
+	require.NotNil(t, lastReq)
+	assert.Equal(t, int64(42), lastReq.Stats.DocsCount)
+	require.Len(t, lastReq.Shards, 1)
+	assert.Equal(t, "products", lastReq.Shards[0].IndexName)
 }
 
 func TestMasterClient_StartStopHeartbeat(t *testing.T) {
@@ -440,7 +461,7 @@ func TestMasterClient_NotConnected(t *testing.T) {
 	ctx := context.Background()
 
 	// Test operations when not connected
-	err := client.Register(ctx, "localhost", 9090, nil)
+	err := client.Register(ctx, "localhost", 9090, nil, nil)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "not connected")
 
@@ -0,0 +1,854 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	pb "github.com/conjugate/conjugate/pkg/common/proto"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Peer recovery defaults
+const (
+	recoveryChunkSize      = 512 * 1024 // Bytes of a file sent per message
+	recoveryBatchSize      = 500        // Operations sent per message
+	recoveryReportTimeout  = 10 * time.Second
+	recoverySnapshotPrefix = ".snapshot_" // Links to the files a primary sends, next to the shard directory
+	recoveryStagingPrefix  = ".recovery_" // Files a replica receives, next to the shard directory
+	diagonWriteLockName    = "write.lock" // Held by the Diagon writer of the shard, never copied
+)
+
+// Stages a replica reports to the master while it recovers, as in
+// ReportShardRecoveryRequest
+const (
+	recoveryStageIndex    = "index"
+	recoveryStageTranslog = "translog"
+	recoveryStageDone     = "done"
+	recoveryStageFailed   = "failed"
+)
+
+// ErrNotRecovering is returned by a primary asked to recover a replica that
+// the routing does not have initializing
+var ErrNotRecovering = errors.New("shard copy is not recovering")
+
+// peerRecovery is a replica on this node recovering from its primary. The
+// primary forwards its writes from the start of the recovery; they are held
+// until the shard the recovery builds is open, then applied to it.
+type peerRecovery struct {
+	mu      sync.Mutex
+	shard   *Shard // Nil until the recovered shard is open
+	pending []forwardedOperations
+}
+
+// forwardedOperations are writes a primary forwarded to a recovering replica
+type forwardedOperations struct {
+	primaryTerm int64
+	ops         []*TranslogOperation
+}
+
+// apply applies forwarded operations to the recovering shard, or holds them
+// while it is not open yet
+func (rec *peerRecovery) apply(ctx context.Context, primaryTerm int64, ops []*TranslogOperation) (int64, error) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	if rec.shard == nil {
+		rec.pending = append(rec.pending, forwardedOperations{primaryTerm: primaryTerm, ops: ops})
+		return 0, nil
+	}
+	return rec.shard.ApplyReplicaOperations(ctx, primaryTerm, ops)
+}
+
+// open applies the held operations to the recovering shard, and every
+// operation forwarded from then on
+func (rec *peerRecovery) open(ctx context.Context, shard *Shard) error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	pending := rec.pending
+	rec.shard = shard
+	rec.pending = nil
+	for _, forwarded := range pending {
+		if _, err := shard.ApplyReplicaOperations(ctx, forwarded.primaryTerm, forwarded.ops); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close stops holding operations: the recovery ended without a shard
+func (rec *peerRecovery) close() {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.pending = nil
+}
+
+// isRecovering reports whether the routing has the copy on a node
+// initializing, that is recovering from the primary
+func (g *replicationGroup) isRecovering(nodeID string) bool {
+	_, exists := g.recoveringTarget(nodeID)
+	return exists
+}
+
+// recoveringTarget returns the initializing copy on a node
+func (g *replicationGroup) recoveringTarget(nodeID string) (replicaTarget, bool) {
+	for _, target := range g.recovering {
+		if target.nodeID == nodeID {
+			return target, true
+		}
+	}
+	return replicaTarget{}, false
+}
+
+// recoveryTargets returns the replicas recovering from a primary on this
+// node that are still initializing in the group
+func (r *Replicator) recoveryTargets(key string, group *replicationGroup) []replicaTarget {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var targets []replicaTarget
+	for nodeID, target := range r.targets[key] {
+		if group.isRecovering(nodeID) {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// ApplyOperations applies the writes a primary forwards to a shard on this
+// node, holding them while the shard is recovering its files
+func (r *Replicator) ApplyOperations(ctx context.Context, indexName string, shardID int32, primaryTerm int64, ops []*TranslogOperation) (int64, error) {
+	r.mu.RLock()
+	rec := r.recoveries[shardKey(indexName, shardID)]
+	r.mu.RUnlock()
+	if rec != nil {
+		return rec.apply(ctx, primaryTerm, ops)
+	}
+
+	shard, err := r.shards.lookupShard(indexName, shardID)
+	if err != nil {
+		return 0, err
+	}
+	return shard.ApplyReplicaOperations(ctx, primaryTerm, ops)
+}
+
+// RecoverReplica sends a replica recovering from a primary on this node what
+// it misses: the primary's committed files unless the operations after the
+// replica's local checkpoint are all in the translog, then those operations.
+// The primary forwards its writes to the replica from the start, so that
+// the replica misses none of the writes made while it recovers.
+func (r *Replicator) RecoverReplica(ctx context.Context, req *pb.RecoverShardRequest, send func(*pb.RecoverShardResponse) error) error {
+	shard, err := r.shards.lookupShard(req.IndexName, req.ShardId)
+	if err != nil {
+		return err
+	}
+	key := shardKey(req.IndexName, req.ShardId)
+
+	group, err := r.group(ctx, shard)
+	if err != nil {
+		return err
+	}
+	target, exists := group.recoveringTarget(req.TargetNodeId)
+	if !exists {
+		// The routing may predate the replica's allocation
+		if err := r.Refresh(ctx); err != nil {
+			r.logger.Warn("Failed to refresh replication groups", zap.Error(err))
+		}
+		if group, err = r.group(ctx, shard); err != nil {
+			return err
+		}
+		if target, exists = group.recoveringTarget(req.TargetNodeId); !exists {
+			return fmt.Errorf("%w: %s on %s", ErrNotRecovering, key, req.TargetNodeId)
+		}
+	}
+
+	// The translog keeps its operations while they are read, and the writes
+	// applied from now on are forwarded to the replica
+	release := shard.acquireRecovery()
+	defer release()
+	r.addRecoveryTarget(key, target)
+
+	err = r.sendRecovery(ctx, shard, group, req, send)
+	if err != nil {
+		r.mu.Lock()
+		delete(r.targets[key], target.nodeID)
+		r.mu.Unlock()
+	}
+	return err
+}
+
+// addRecoveryTarget starts forwarding writes to a recovering replica
+func (r *Replicator) addRecoveryTarget(key string, target replicaTarget) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.targets[key] == nil {
+		r.targets[key] = make(map[string]replicaTarget)
+	}
+	r.targets[key][target.nodeID] = target
+}
+
+// sendRecovery sends the plan, the files and the operations of a recovery
+func (r *Replicator) sendRecovery(ctx context.Context, shard *Shard, group *replicationGroup, req *pb.RecoverShardRequest, send func(*pb.RecoverShardResponse) error) error {
+	plan := &pb.RecoveryPlan{
+		StartingSeqNo: req.LocalCheckpoint,
+		PrimaryTerm:   group.primaryTerm,
+	}
+
+	var ops []*TranslogOperation
+	complete := false
+	if req.LocalCheckpoint > 0 {
+		var err error
+		if ops, complete, err = shard.operationsAfter(req.LocalCheckpoint); err != nil {
+			return err
+		}
+	}
+
+	var snapshot string
+	if !complete {
+		snapshot = filepath.Join(filepath.Dir(shard.Path), fmt.Sprintf("%s%s_%d", recoverySnapshotPrefix, filepath.Base(shard.Path), time.Now().UnixNano()))
+		defer os.RemoveAll(snapshot)
+
+		files, checkpoint, err := shard.snapshotFiles(snapshot)
+		if err != nil {
+			return fmt.Errorf("failed to snapshot shard files: %w", err)
+		}
+		plan.Files = files
+		plan.StartingSeqNo = checkpoint
+
+		if ops, _, err = shard.operationsAfter(checkpoint); err != nil {
+			return err
+		}
+	}
+	plan.TotalOperations = int64(len(ops))
+
+	r.logger.Info("Recovering replica",
+		zap.String("index", shard.IndexName),
+		zap.Int32("shard_id", shard.ShardID),
+		zap.String("target", req.TargetNodeId),
+		zap.Int("files", len(plan.Files)),
+		zap.Int64("starting_seq_no", plan.StartingSeqNo),
+		zap.Int("operations", len(ops)))
+
+	if err := send(&pb.RecoverShardResponse{Plan: plan}); err != nil {
+		return err
+	}
+
+	buf := make([]byte, recoveryChunkSize)
+	for _, file := range plan.Files {
+		if err := sendRecoveryFile(ctx, filepath.Join(snapshot, filepath.FromSlash(file.Name)), file.Name, buf, send); err != nil {
+			return err
+		}
+	}
+
+	for start := 0; start < len(ops); start += recoveryBatchSize {
+		end := start + recoveryBatchSize
+		if end > len(ops) {
+			end = len(ops)
+		}
+		operations, err := replicationOperationsToProto(ops[start:end])
+		if err != nil {
+			return err
+		}
+		if err := send(&pb.RecoverShardResponse{Operations: operations}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sendRecoveryFile sends a file in chunks
+func sendRecoveryFile(ctx context.Context, path, name string, buf []byte, send func(*pb.RecoverShardResponse) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer file.Close()
+
+	var offset int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := file.Read(buf)
+		if n > 0 {
+			chunk := &pb.RecoveryFileChunk{
+				Name:   name,
+				Offset: offset,
+				Data:   append([]byte(nil), buf[:n]...),
+			}
+			if err := send(&pb.RecoverShardResponse{FileChunk: chunk}); err != nil {
+				return err
+			}
+			offset += int64(n)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+	}
+}
+
+// startRecovery starts recovering a replica on this node from its primary,
+// unless it is already recovering
+func (r *Replicator) startRecovery(shard *Shard, group *replicationGroup) {
+	if group.primaryNodeID == r.nodeID || group.primaryAddress == "" {
+		return
+	}
+	key := shardKey(shard.IndexName, shard.ShardID)
+
+	r.mu.Lock()
+	if _, running := r.recoveries[key]; running || r.ctx.Err() != nil {
+		r.mu.Unlock()
+		return
+	}
+	rec := &peerRecovery{}
+	r.recoveries[key] = rec
+	r.recovering.Add(1)
+	r.mu.Unlock()
+
+	go func() {
+		defer r.recovering.Done()
+
+		if err := r.recoverReplica(r.ctx, rec, shard, group); err != nil {
+			r.logger.Warn("Replica recovery failed",
+				zap.String("index", shard.IndexName),
+				zap.Int32("shard_id", shard.ShardID),
+				zap.String("source", group.primaryNodeID),
+				zap.Error(err))
+		}
+
+		rec.close()
+		r.mu.Lock()
+		delete(r.recoveries, key)
+		r.mu.Unlock()
+	}()
+}
+
+// recoverReplica recovers a replica on this node from its primary and
+// reports its progress to the master, which starts the copy once it is done.
+// A recovery that fails before the primary sent its plan is retried on a
+// later refresh without being reported.
+func (r *Replicator) recoverReplica(ctx context.Context, rec *peerRecovery, shard *Shard, group *replicationGroup) error {
+	shard.setState(ShardStateInitializing)
+
+	conn, err := r.conn(group.primaryAddress)
+	if err != nil {
+		return err
+	}
+	stream, err := pb.NewDataServiceClient(conn).RecoverShard(ctx, &pb.RecoverShardRequest{
+		IndexName:       shard.IndexName,
+		ShardId:         shard.ShardID,
+		TargetNodeId:    r.nodeID,
+		LocalCheckpoint: shard.LocalCheckpoint(),
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	plan := resp.GetPlan()
+	if plan == nil {
+		return fmt.Errorf("recovery from %s did not start with a plan", group.primaryNodeID)
+	}
+
+	progress := &pb.ReportShardRecoveryRequest{
+		IndexName:    shard.IndexName,
+		ShardId:      shard.ShardID,
+		NodeId:       r.nodeID,
+		SourceNodeId: group.primaryNodeID,
+		FilesTotal:   int32(len(plan.Files)),
+	}
+	for _, file := range plan.Files {
+		progress.BytesTotal += file.Size
+	}
+
+	if err := r.receiveRecovery(ctx, rec, shard, plan, stream, progress); err != nil {
+		if status.Code(err) != codes.NotFound {
+			progress.Stage = recoveryStageFailed
+			progress.Failure = err.Error()
+			r.reportRecovery(progress)
+		}
+		return err
+	}
+	return nil
+}
+
+// receiveRecovery builds the replica from the files and operations the
+// primary sends after the plan
+func (r *Replicator) receiveRecovery(ctx context.Context, rec *peerRecovery, shard *Shard, plan *pb.RecoveryPlan, stream pb.DataService_RecoverShardClient, progress *pb.ReportShardRecoveryRequest) error {
+	staging := filepath.Join(filepath.Dir(shard.Path), recoveryStagingPrefix+filepath.Base(shard.Path))
+	opened := len(plan.Files) == 0
+	if !opened {
+		if err := os.RemoveAll(staging); err != nil {
+			return fmt.Errorf("failed to clear recovery directory: %w", err)
+		}
+		if err := os.MkdirAll(staging, 0755); err != nil {
+			return fmt.Errorf("failed to create recovery directory: %w", err)
+		}
+		defer os.RemoveAll(staging)
+
+		progress.Stage = recoveryStageIndex
+		if err := r.reportRecovery(progress); err != nil {
+			return err
+		}
+	}
+
+	// openShard installs the received files, and starts applying operations
+	openShard := func() error {
+		if !opened {
+			if err := verifyRecoveryFiles(staging, plan.Files); err != nil {
+				return err
+			}
+			recovered, err := r.shards.replaceShardFiles(shard, staging, plan.StartingSeqNo, plan.PrimaryTerm)
+			if err != nil {
+				return err
+			}
+			shard = recovered
+			opened = true
+		}
+		if err := rec.open(ctx, shard); err != nil {
+			return err
+		}
+		progress.Stage = recoveryStageTranslog
+		return r.reportRecovery(progress)
+	}
+	if opened {
+		if err := openShard(); err != nil {
+			return err
+		}
+	}
+
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if chunk := resp.GetFileChunk(); chunk != nil {
+			if opened {
+				return fmt.Errorf("file chunk %s after the recovered files were installed", chunk.Name)
+			}
+			if err := writeRecoveryChunk(staging, chunk); err != nil {
+				return err
+			}
+			progress.BytesRecovered += int64(len(chunk.Data))
+			continue
+		}
+
+		if progress.Stage != recoveryStageTranslog {
+			if err := openShard(); err != nil {
+				return err
+			}
+		}
+		ops := replicationOperationsFromProto(resp.GetOperations())
+		if _, err := rec.apply(ctx, plan.PrimaryTerm, ops); err != nil {
+			return err
+		}
+		progress.OperationsRecovered += int64(len(ops))
+	}
+
+	if progress.Stage != recoveryStageTranslog {
+		if err := openShard(); err != nil {
+			return err
+		}
+	}
+	if err := shard.finishRecovery(); err != nil {
+		return err
+	}
+
+	progress.Stage = recoveryStageDone
+	if err := r.reportRecovery(progress); err != nil {
+		return err
+	}
+
+	r.logger.Info("Replica recovered",
+		zap.String("index", shard.IndexName),
+		zap.Int32("shard_id", shard.ShardID),
+		zap.String("source", progress.SourceNodeId),
+		zap.Int32("files", progress.FilesTotal),
+		zap.Int64("bytes", progress.BytesRecovered),
+		zap.Int64("operations", progress.OperationsRecovered))
+
+	return nil
+}
+
+// reportRecovery reports a recovery's progress to the master. Only a
+// NotFound answer, the copy was deallocated, fails the recovery; the master
+// may miss other stages, but not the last report.
+func (r *Replicator) reportRecovery(progress *pb.ReportShardRecoveryRequest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), recoveryReportTimeout)
+	defer cancel()
+
+	err := r.master.ReportShardRecovery(ctx, progress)
+	if err == nil {
+		return nil
+	}
+	if status.Code(err) == codes.NotFound || progress.Stage == recoveryStageDone {
+		return err
+	}
+	r.logger.Warn("Failed to report recovery progress",
+		zap.String("index", progress.IndexName),
+		zap.Int32("shard_id", progress.ShardId),
+		zap.String("stage", progress.Stage),
+		zap.Error(err))
+	return nil
+}
+
+// writeRecoveryChunk writes a received file chunk into the staging directory
+func writeRecoveryChunk(staging string, chunk *pb.RecoveryFileChunk) error {
+	name := filepath.FromSlash(chunk.Name)
+	if !filepath.IsLocal(name) {
+		return fmt.Errorf("invalid recovery file name %q", chunk.Name)
+	}
+	path := filepath.Join(staging, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", chunk.Name, err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", chunk.Name, err)
+	}
+	if _, err := file.WriteAt(chunk.Data, chunk.Offset); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", chunk.Name, err)
+	}
+	return file.Close()
+}
+
+// verifyRecoveryFiles checks that every planned file was received in full
+func verifyRecoveryFiles(staging string, files []*pb.RecoveryFile) error {
+	for _, file := range files {
+		info, err := os.Stat(filepath.Join(staging, filepath.FromSlash(file.Name)))
+		if err != nil {
+			return fmt.Errorf("recovery file %s missing: %w", file.Name, err)
+		}
+		if info.Size() != file.Size {
+			return fmt.Errorf("recovery file %s has %d bytes, expected %d", file.Name, info.Size(), file.Size)
+		}
+	}
+	return nil
+}
+
+// lookupShard returns a shard on this node in any state but closed
+func (sm *ShardManager) lookupShard(indexName string, shardID int32) (*Shard, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	key := shardKey(indexName, shardID)
+	shard, exists := sm.shards[key]
+	if !exists || shard.State == ShardStateClosed {
+		return nil, fmt.Errorf("%w: %s", ErrShardNotFound, key)
+	}
+	return shard, nil
+}
+
+// replaceShardFiles replaces a recovering shard with the files received
+// from its primary, which hold every operation up to checkpoint, and returns
+// the shard opened on them. The shard keeps its settings. If the files can't
+// be installed once the shard is closed, an empty shard takes its place so
+// the next routing refresh recovers it again; the caller reports the
+// failure to the master.
+func (sm *ShardManager) replaceShardFiles(old *Shard, staging string, checkpoint, primaryTerm int64) (*Shard, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	key := shardKey(old.IndexName, old.ShardID)
+	if sm.shards[key] != old {
+		return nil, fmt.Errorf("%w: %s was replaced", ErrShardNotFound, key)
+	}
+
+	old.mu.RLock()
+	analyzerSettings := old.analyzerSettings
+	mappings := old.mappings
+	batchSize, commitInterval, refreshInterval := old.commitBatchSize, old.commitInterval, old.refreshInterval
+	old.mu.RUnlock()
+	configure := func(shard *Shard) {
+		shard.State = ShardStateInitializing
+		shard.analyzerSettings = analyzerSettings
+		shard.commitBatchSize = batchSize
+		shard.commitInterval = commitInterval
+		shard.refreshInterval = refreshInterval
+	}
+
+	if err := old.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close shard: %w", err)
+	}
+	delete(sm.shards, key)
+
+	shard, err := sm.installRecoveredFiles(old, staging, checkpoint, primaryTerm, configure)
+	if err != nil {
+		sm.logger.Error("Failed to install recovered shard files, recovering it again from empty",
+			zap.String("index", old.IndexName),
+			zap.Int32("shard_id", old.ShardID),
+			zap.Error(err))
+		if resetErr := sm.resetShard(old, mappings, configure); resetErr != nil {
+			sm.logger.Error("Failed to reset shard, it is no longer on this node",
+				zap.String("index", old.IndexName),
+				zap.Int32("shard_id", old.ShardID),
+				zap.Error(resetErr))
+		}
+		return nil, err
+	}
+	sm.shards[key] = shard
+
+	sm.logger.Info("Installed recovered shard files",
+		zap.String("index", old.IndexName),
+		zap.Int32("shard_id", old.ShardID),
+		zap.Int64("checkpoint", checkpoint),
+		zap.Int64("docs_count", shard.DocsCount))
+
+	return shard, nil
+}
+
+// installRecoveredFiles moves the recovered files in place of the closed
+// shard's and opens a shard on them
+func (sm *ShardManager) installRecoveredFiles(old *Shard, staging string, checkpoint, primaryTerm int64, configure func(*Shard)) (*Shard, error) {
+	if err := os.RemoveAll(old.Path); err != nil {
+		return nil, fmt.Errorf("failed to remove shard files: %w", err)
+	}
+	if err := os.Rename(staging, old.Path); err != nil {
+		return nil, fmt.Errorf("failed to install recovered files: %w", err)
+	}
+
+	diagonShard, err := sm.diagon.CreateShard(old.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recovered Diagon shard: %w", err)
+	}
+
+	shard := sm.newShard(old.IndexName, old.ShardID, false, old.Path, diagonShard)
+	configure(shard)
+	if err := shard.loadMappings(); err != nil {
+		diagonShard.Close()
+		return nil, err
+	}
+	if err := sm.openTranslog(shard); err != nil {
+		diagonShard.Close()
+		return nil, err
+	}
+
+	// The recovered files start the shard's history at the checkpoint
+	if err := shard.translog.StartHistory(checkpoint, primaryTerm); err != nil {
+		shard.translog.Close()
+		diagonShard.Close()
+		return nil, fmt.Errorf("failed to start translog history: %w", err)
+	}
+	shard.seqNos = newSeqNoTracker(checkpoint, checkpoint)
+	shard.primaryTerm = primaryTerm
+
+	if err := shard.countDocs(); err != nil {
+		sm.logger.Warn("Failed to count documents in recovered shard",
+			zap.String("index", old.IndexName),
+			zap.Int32("shard_id", old.ShardID),
+			zap.Error(err))
+	}
+	shard.SizeBytes = dirSize(old.Path)
+
+	shard.startBackgroundCommitter()
+	shard.startBackgroundRefresher()
+	return shard, nil
+}
+
+// resetShard opens an empty initializing shard in place of the closed one,
+// with its mappings and settings
+func (sm *ShardManager) resetShard(old *Shard, mappings map[string]*pb.FieldMapping, configure func(*Shard)) error {
+	if err := os.RemoveAll(old.Path); err != nil {
+		return fmt.Errorf("failed to remove shard files: %w", err)
+	}
+	if err := os.MkdirAll(old.Path, 0755); err != nil {
+		return fmt.Errorf("failed to create shard directory: %w", err)
+	}
+
+	diagonShard, err := sm.diagon.CreateShard(old.Path)
+	if err != nil {
+		return fmt.Errorf("failed to create Diagon shard: %w", err)
+	}
+
+	shard := sm.newShard(old.IndexName, old.ShardID, false, old.Path, diagonShard)
+	configure(shard)
+	if mappings != nil {
+		if err := shard.SetMappings(mappings); err != nil {
+			diagonShard.Close()
+			return err
+		}
+	}
+	if err := sm.openTranslog(shard); err != nil {
+		diagonShard.Close()
+		return err
+	}
+
+	shard.startBackgroundCommitter()
+	shard.startBackgroundRefresher()
+	sm.shards[shardKey(old.IndexName, old.ShardID)] = shard
+	return nil
+}
+
+// setState moves the shard to a state
+func (s *Shard) setState(state ShardState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.State != ShardStateClosed {
+		s.State = state
+	}
+}
+
+// acquireRecovery keeps the translog generation while a peer recovery reads
+// it, until the returned function is called
+func (s *Shard) acquireRecovery() func() {
+	s.mu.Lock()
+	s.recoveries++
+	s.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			s.recoveries--
+			s.mu.Unlock()
+		})
+	}
+}
+
+// operationsAfter returns the translog operations after seqNo, and whether
+// they are all the operations after it
+func (s *Shard) operationsAfter(seqNo int64) ([]*TranslogOperation, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.State != ShardStateStarted {
+		return nil, false, fmt.Errorf("shard is not ready")
+	}
+	ops, complete, err := s.translog.OperationsAfter(seqNo)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read translog: %w", err)
+	}
+	return ops, complete, nil
+}
+
+// snapshotFiles commits the shard and links its committed files into dir,
+// which must not exist. It returns the files, by slash-separated path
+// relative to dir, and the local checkpoint the commit holds.
+func (s *Shard) snapshotFiles(dir string) ([]*pb.RecoveryFile, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.State != ShardStateStarted {
+		return nil, 0, fmt.Errorf("shard is not ready")
+	}
+
+	if err := s.DiagonShard.Flush(); err != nil {
+		return nil, 0, fmt.Errorf("failed to flush shard: %w", err)
+	}
+	if err := s.DiagonShard.Commit(); err != nil {
+		return nil, 0, fmt.Errorf("failed to commit shard: %w", err)
+	}
+	if s.pendingDocs > 0 || s.needsCommit {
+		s.needsRefresh = true
+	}
+	s.pendingDocs = 0
+	s.needsCommit = false
+	s.lastCommitTime = time.Now()
+	if err := s.translog.MarkCommitted(); err != nil {
+		return nil, 0, err
+	}
+
+	var files []*pb.RecoveryFile
+	err := filepath.WalkDir(s.Path, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.Path, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if rel == translogDirName {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(filepath.Join(dir, rel), 0755)
+		}
+		if rel == diagonWriteLockName {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if err := linkOrCopyFile(path, filepath.Join(dir, rel)); err != nil {
+			return err
+		}
+		files = append(files, &pb.RecoveryFile{Name: filepath.ToSlash(rel), Size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return files, s.seqNos.checkpoint, nil
+}
+
+// finishRecovery commits a recovered replica and starts it
+func (s *Shard) finishRecovery() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.State != ShardStateInitializing {
+		return fmt.Errorf("shard is %s, not recovering", s.State)
+	}
+	if err := s.DiagonShard.Commit(); err != nil {
+		return fmt.Errorf("failed to commit recovered shard: %w", err)
+	}
+	s.pendingDocs = 0
+	s.needsCommit = false
+	s.needsRefresh = true
+	s.lastCommitTime = time.Now()
+	if err := s.translog.MarkCommitted(); err != nil {
+		return err
+	}
+
+	s.State = ShardStateStarted
+	return nil
+}
+
+// linkOrCopyFile hard links src to dst, or copies it where links are not
+// supported
+func linkOrCopyFile(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,95 @@
+package data
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/conjugate/conjugate/pkg/common/config"
+	pb "github.com/conjugate/conjugate/pkg/common/proto"
+	"github.com/conjugate/conjugate/pkg/data/diagon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestPeerRecoveryHoldsOperations(t *testing.T) {
+	rec := &peerRecovery{}
+
+	// Operations forwarded before the shard is open are held
+	checkpoint, err := rec.apply(context.Background(), 2, []*TranslogOperation{{Type: TranslogOpIndex, DocID: "1", SeqNo: 5}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), checkpoint)
+	_, err = rec.apply(context.Background(), 2, []*TranslogOperation{{Type: TranslogOpDelete, DocID: "2", SeqNo: 6}})
+	require.NoError(t, err)
+	require.Len(t, rec.pending, 2)
+	assert.Equal(t, int64(2), rec.pending[0].primaryTerm)
+	assert.Equal(t, "2", rec.pending[1].ops[0].DocID)
+
+	// A recovery that ends without a shard drops them
+	rec.close()
+	assert.Empty(t, rec.pending)
+}
+
+func TestRecoveryFileChunks(t *testing.T) {
+	staging := t.TempDir()
+
+	chunks := []*pb.RecoveryFileChunk{
+		{Name: "knn/title.hnsw", Offset: 4, Data: []byte("5678")},
+		{Name: "knn/title.hnsw", Offset: 0, Data: []byte("1234")},
+		{Name: "segments_1", Offset: 0, Data: []byte("commit")},
+	}
+	for _, chunk := range chunks {
+		require.NoError(t, writeRecoveryChunk(staging, chunk))
+	}
+
+	data, err := os.ReadFile(filepath.Join(staging, "knn", "title.hnsw"))
+	require.NoError(t, err)
+	assert.Equal(t, "12345678", string(data))
+
+	files := []*pb.RecoveryFile{{Name: "knn/title.hnsw", Size: 8}, {Name: "segments_1", Size: 6}}
+	assert.NoError(t, verifyRecoveryFiles(staging, files))
+	assert.Error(t, verifyRecoveryFiles(staging, []*pb.RecoveryFile{{Name: "segments_1", Size: 7}}))
+	assert.Error(t, verifyRecoveryFiles(staging, []*pb.RecoveryFile{{Name: "_0.cfs", Size: 1}}))
+
+	// Files outside the staging directory are refused
+	assert.Error(t, writeRecoveryChunk(staging, &pb.RecoveryFileChunk{Name: "../escape", Data: []byte("x")}))
+	assert.Error(t, writeRecoveryChunk(staging, &pb.RecoveryFileChunk{Name: "/etc/escape", Data: []byte("x")}))
+}
+
+func TestReplaceShardFilesFailureResetsShard(t *testing.T) {
+	cfg := &config.DataNodeConfig{
+		NodeID:    "node-1",
+		DataDir:   t.TempDir(),
+		MaxShards: 10,
+	}
+	logger := zap.NewNop()
+	diagonBridge, err := diagon.NewDiagonBridge(&diagon.Config{
+		DataDir: cfg.DataDir,
+		Logger:  logger,
+	})
+	require.NoError(t, err)
+
+	sm := NewShardManager(cfg, logger, diagonBridge, nil)
+	ctx := context.Background()
+	require.NoError(t, sm.Start(ctx))
+	defer sm.Stop(ctx)
+
+	require.NoError(t, sm.CreateShard(ctx, "test-index", 0, false))
+	old, err := sm.GetShard("test-index", 0)
+	require.NoError(t, err)
+	require.NoError(t, old.IndexDocument(ctx, "doc-1", map[string]interface{}{"title": "stale"}))
+
+	// The recovered files can't be installed
+	missing := filepath.Join(cfg.DataDir, "missing")
+	_, err = sm.replaceShardFiles(old, missing, 10, 1)
+	require.Error(t, err)
+
+	// An empty shard takes its place, to be recovered again
+	shard, err := sm.lookupShard("test-index", 0)
+	require.NoError(t, err)
+	assert.NotSame(t, old, shard)
+	assert.Equal(t, ShardStateInitializing, shard.State)
+	assert.Equal(t, int64(0), shard.DocsCount)
+}
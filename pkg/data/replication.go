@@ -0,0 +1,635 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	pb "github.com/conjugate/conjugate/pkg/common/proto"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Replication defaults
+const (
+	defaultRoutingRefreshInterval = 5 * time.Second  // How often primaries poll the master's routing
+	replicationTimeout            = 30 * time.Second // How long a primary waits for a replica
+)
+
+var (
+	// ErrStalePrimaryTerm is returned for writes from a primary that was
+	// replaced by a newer one
+	ErrStalePrimaryTerm = errors.New("stale primary term")
+
+	// ErrNotPrimary is returned for writes to a shard copy that the routing
+	// does not make the primary
+	ErrNotPrimary = errors.New("not the primary shard")
+
+	// ErrRoutingUnknown is returned for writes to a shard before the node
+	// got the routing from the master, which alone decides the primary
+	ErrRoutingUnknown = errors.New("shard routing not known yet")
+
+	// ErrNotEnoughActiveShards is returned for writes that wait for more
+	// active shard copies than there are
+	ErrNotEnoughActiveShards = errors.New("not enough active shard copies")
+
+	// ErrShardNotFound is returned for operations on a shard that is not on
+	// this node
+	ErrShardNotFound = errors.New("shard not found")
+)
+
+// ApplyReplicaOperations applies operations replicated by the shard's
+// primary, which is in primaryTerm, and returns the local checkpoint.
+// Operations may arrive out of order or more than once: those already
+// processed, or superseded by a later write to their document, are skipped.
+// A primary behind the shard's term gets ErrStalePrimaryTerm.
+func (s *Shard) ApplyReplicaOperations(ctx context.Context, primaryTerm int64, ops []*TranslogOperation) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// A recovering replica takes writes too, so that it misses none
+	if s.State != ShardStateStarted && s.State != ShardStateInitializing {
+		return 0, fmt.Errorf("shard is not ready")
+	}
+	if primaryTerm < s.primaryTerm {
+		return 0, fmt.Errorf("%w: %d, the shard is in term %d", ErrStalePrimaryTerm, primaryTerm, s.primaryTerm)
+	}
+	s.setPrimaryTerm(primaryTerm)
+
+	for _, op := range ops {
+		if op.SeqNo <= 0 {
+			return s.seqNos.checkpoint, fmt.Errorf("replicated operation for %s has no sequence number", op.DocID)
+		}
+		if s.seqNos.stale(op.SeqNo, op.DocID) {
+			s.seqNos.markProcessed(op.SeqNo, op.DocID)
+			continue
+		}
+
+		// Each operation is in the translog before Diagon applies it
+		record := func(bool) error { return s.recordOperation(op) }
+		switch op.Type {
+		case TranslogOpIndex:
+			created, err := s.DiagonShard.UpsertDocumentLogged(op.DocID, op.Source, record)
+			if err != nil {
+				return s.seqNos.checkpoint, fmt.Errorf("failed to index document: %w", err)
+			}
+			if created {
+				s.DocsCount++
+			}
+		case TranslogOpDelete:
+			found, err := s.DiagonShard.DeleteDocumentLogged(op.DocID, record)
+			if err != nil {
+				return s.seqNos.checkpoint, fmt.Errorf("failed to delete document: %w", err)
+			}
+			if found {
+				s.DocsCount--
+			}
+		default:
+			return s.seqNos.checkpoint, fmt.Errorf("unknown operation type: %s", op.Type)
+		}
+	}
+	s.translog.SetLocalCheckpoint(s.seqNos.checkpoint)
+
+	if s.pendingDocs >= s.commitBatchSize || time.Since(s.lastCommitTime) >= s.commitInterval {
+		if err := s.commitBatch(); err != nil {
+			return s.seqNos.checkpoint, err
+		}
+	}
+
+	return s.seqNos.checkpoint, nil
+}
+
+// UpdatePrimaryTerm moves the shard to the primary term and role of the
+// master's routing. Terms only grow. A replica promoted to primary fills the
+// gaps in its history: an in-sync replica only misses operations that were
+// never acknowledged.
+func (s *Shard) UpdatePrimaryTerm(term int64, isPrimary bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if term < s.primaryTerm {
+		return
+	}
+	s.setPrimaryTerm(term)
+
+	if isPrimary && !s.IsPrimary {
+		s.seqNos.fillGaps()
+		if s.translog != nil {
+			s.translog.SetLocalCheckpoint(s.seqNos.checkpoint)
+		}
+		s.logger.Info("Promoted to primary",
+			zap.Int64("primary_term", term),
+			zap.Int64("max_seq_no", s.seqNos.maxSeqNo))
+	}
+	s.IsPrimary = isPrimary
+}
+
+// setPrimaryTerm raises the shard's primary term. Must be called with s.mu
+// held.
+func (s *Shard) setPrimaryTerm(term int64) {
+	if term <= s.primaryTerm {
+		return
+	}
+	s.primaryTerm = term
+	if s.translog != nil {
+		s.translog.SetPrimaryTerm(term)
+	}
+}
+
+// PrimaryTerm returns the latest primary term the shard knows of
+func (s *Shard) PrimaryTerm() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.primaryTerm
+}
+
+// LocalCheckpoint returns the sequence number up to which the shard
+// processed every operation
+func (s *Shard) LocalCheckpoint() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.seqNos.checkpoint
+}
+
+// replicaTarget is a replica that a primary forwards writes to
+type replicaTarget struct {
+	nodeID  string
+	address string
+}
+
+// replicationGroup is the master's routing of a shard's copies, as a
+// primary sees it
+type replicationGroup struct {
+	primaryNodeID  string
+	primaryAddress string
+	primaryTerm    int64
+	copies         int             // The primary and the replicas the index is configured with
+	replicas       []replicaTarget // In-sync replicas, which receive every write
+	recovering     []replicaTarget // Initializing replicas, which recover from the primary
+}
+
+// activeCopies returns how many copies of the shard take writes
+func (g *replicationGroup) activeCopies() int {
+	return 1 + len(g.replicas)
+}
+
+// checkActiveShards fails unless the group has as many active copies as
+// waitForActiveShards asks for
+func (g *replicationGroup) checkActiveShards(waitForActiveShards string) error {
+	required, err := parseWaitForActiveShards(waitForActiveShards, g.copies)
+	if err != nil {
+		return err
+	}
+	if g.activeCopies() < required {
+		return fmt.Errorf("%w: %d of %d required copies are active", ErrNotEnoughActiveShards, g.activeCopies(), required)
+	}
+	return nil
+}
+
+// parseWaitForActiveShards returns how many active copies a write waits for:
+// 1 by default, every copy for "all", or a count up to the number of copies
+func parseWaitForActiveShards(value string, copies int) (int, error) {
+	switch value {
+	case "":
+		return 1, nil
+	case "all":
+		return copies, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n > copies {
+		return 0, fmt.Errorf("invalid wait_for_active_shards [%s]: expected [all] or a number between 0 and %d", value, copies)
+	}
+	return n, nil
+}
+
+// buildReplicationGroups returns the replication group of every shard in the
+// cluster state, by shard key
+func buildReplicationGroups(state *pb.ClusterStateResponse) map[string]*replicationGroup {
+	groups := make(map[string]*replicationGroup)
+	if state.GetRoutingTable() == nil {
+		return groups
+	}
+
+	addresses := make(map[string]string, len(state.Nodes))
+	for _, node := range state.Nodes {
+		addresses[node.NodeId] = fmt.Sprintf("%s:%d", node.BindAddr, node.GrpcPort)
+	}
+	replicaCounts := make(map[string]int, len(state.Indices))
+	for _, index := range state.Indices {
+		replicaCounts[index.IndexName] = int(index.GetSettings().GetNumberOfReplicas())
+	}
+
+	for indexName, index := range state.RoutingTable.Indices {
+		for shardID, routing := range index.Shards {
+			primary := routing.GetAllocation()
+			if primary == nil || primary.NodeId == "" || primary.State == pb.ShardAllocation_SHARD_STATE_UNASSIGNED {
+				continue
+			}
+
+			group := &replicationGroup{
+				primaryNodeID:  primary.NodeId,
+				primaryAddress: addresses[primary.NodeId],
+				primaryTerm:    primary.PrimaryTerm,
+				copies:         1 + replicaCounts[indexName],
+			}
+			for _, replica := range routing.Replicas {
+				address, known := addresses[replica.NodeId]
+				if !known {
+					continue
+				}
+				target := replicaTarget{nodeID: replica.NodeId, address: address}
+				switch {
+				case replica.InSync && replica.State == pb.ShardAllocation_SHARD_STATE_STARTED:
+					group.replicas = append(group.replicas, target)
+				case replica.State == pb.ShardAllocation_SHARD_STATE_INITIALIZING:
+					group.recovering = append(group.recovering, target)
+				}
+			}
+			if group.activeCopies() > group.copies {
+				group.copies = group.activeCopies()
+			}
+			groups[shardKey(indexName, shardID)] = group
+		}
+	}
+
+	return groups
+}
+
+// Replicator forwards the writes applied by the primaries on this node to
+// their in-sync replicas. It learns the replication groups by polling the
+// master's routing, and on demand when a shard is missing from it. A replica
+// that fails a write is reported to the master, which takes it out of the
+// in-sync set; until the master has done so the write is not acknowledged.
+// The Replicator also runs the peer recoveries of the shards on this node,
+// as the primary and as the recovering replica.
+type Replicator struct {
+	nodeID string
+	master *MasterClient
+	shards *ShardManager
+	logger *zap.Logger
+
+	mu         sync.RWMutex
+	groups     map[string]*replicationGroup        // By shard key
+	routed     bool                                // The groups come from the master's routing
+	targets    map[string]map[string]replicaTarget // Replicas recovering from primaries on this node, by shard key and node
+	recoveries map[string]*peerRecovery            // Replicas on this node recovering from their primary, by shard key
+	refreshMu  sync.Mutex                          // One routing refresh at a time
+
+	connMu sync.Mutex
+	conns  map[string]*grpc.ClientConn // By replica address
+
+	ctx        context.Context // Cancelled on Stop, ends recoveries
+	cancel     context.CancelFunc
+	recovering sync.WaitGroup
+
+	started bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewReplicator creates a replicator for the shards of a data node
+func NewReplicator(nodeID string, master *MasterClient, shards *ShardManager, logger *zap.Logger) *Replicator {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Replicator{
+		nodeID:     nodeID,
+		master:     master,
+		shards:     shards,
+		logger:     logger,
+		groups:     make(map[string]*replicationGroup),
+		targets:    make(map[string]map[string]replicaTarget),
+		recoveries: make(map[string]*peerRecovery),
+		conns:      make(map[string]*grpc.ClientConn),
+		ctx:        ctx,
+		cancel:     cancel,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Start polls the master's routing every interval until Stop
+func (r *Replicator) Start(interval time.Duration) {
+	r.mu.Lock()
+	r.started = true
+	r.mu.Unlock()
+
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				if !r.master.IsConnected() {
+					continue
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				if err := r.Refresh(ctx); err != nil {
+					r.logger.Warn("Failed to refresh replication groups", zap.Error(err))
+				}
+				cancel()
+			}
+		}
+	}()
+}
+
+// Stop stops polling, ends recoveries and closes the connections to
+// replicas
+func (r *Replicator) Stop() {
+	r.mu.Lock()
+	started := r.started
+	r.started = false
+	r.mu.Unlock()
+
+	if started {
+		close(r.stop)
+		<-r.done
+	}
+
+	// Recoveries start under r.mu unless cancelled
+	r.mu.Lock()
+	r.cancel()
+	r.mu.Unlock()
+	r.recovering.Wait()
+
+	r.connMu.Lock()
+	defer r.connMu.Unlock()
+	for address, conn := range r.conns {
+		conn.Close()
+		delete(r.conns, address)
+	}
+}
+
+// Refresh reloads the replication groups from the master's routing and
+// moves the shards on this node to their routed primary term and role. The
+// replicas the routing has initializing on this node start recovering.
+func (r *Replicator) Refresh(ctx context.Context) error {
+	r.refreshMu.Lock()
+	defer r.refreshMu.Unlock()
+
+	state, err := r.master.GetClusterState(ctx)
+	if err != nil {
+		return err
+	}
+	groups := buildReplicationGroups(state)
+
+	for _, shard := range r.shards.List() {
+		group, exists := groups[shardKey(shard.IndexName, shard.ShardID)]
+		if !exists {
+			continue
+		}
+		shard.UpdatePrimaryTerm(group.primaryTerm, group.primaryNodeID == r.nodeID)
+		if group.isRecovering(r.nodeID) {
+			r.startRecovery(shard, group)
+		}
+	}
+
+	r.mu.Lock()
+	r.groups = groups
+	r.routed = true
+	for key, targets := range r.targets {
+		group, exists := groups[key]
+		for nodeID := range targets {
+			if !exists || !group.isRecovering(nodeID) {
+				delete(targets, nodeID)
+			}
+		}
+		if len(targets) == 0 {
+			delete(r.targets, key)
+		}
+	}
+	r.mu.Unlock()
+
+	return nil
+}
+
+// lookup returns a copy of a shard's replication group, and whether the
+// groups come from the master's routing
+func (r *Replicator) lookup(key string) (*replicationGroup, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	group, exists := r.groups[key]
+	if !exists {
+		return nil, r.routed
+	}
+	copied := *group
+	copied.replicas = append([]replicaTarget(nil), group.replicas...)
+	copied.recovering = append([]replicaTarget(nil), group.recovering...)
+	return &copied, r.routed
+}
+
+// group returns the replication group of a shard the node writes to as its
+// primary. Without routing from the master no copy may act as the primary,
+// since a replica could then acknowledge writes the real primary never sees.
+func (r *Replicator) group(ctx context.Context, shard *Shard) (*replicationGroup, error) {
+	key := shardKey(shard.IndexName, shard.ShardID)
+
+	group, routed := r.lookup(key)
+	if (!routed && r.master.IsConnected()) || (routed && (group == nil || group.primaryNodeID != r.nodeID)) {
+		// The routing may predate the shard's allocation or promotion
+		if err := r.Refresh(ctx); err != nil {
+			r.logger.Warn("Failed to refresh replication groups", zap.Error(err))
+		}
+		group, routed = r.lookup(key)
+	}
+
+	if !routed {
+		return nil, fmt.Errorf("%w: %s", ErrRoutingUnknown, key)
+	}
+	if group == nil || group.primaryNodeID != r.nodeID {
+		return nil, fmt.Errorf("%w: %s", ErrNotPrimary, key)
+	}
+	return group, nil
+}
+
+// replicate forwards operations the primary applied to the group's replicas
+// in parallel and returns how many copies applied them. An error means the
+// write must not be acknowledged: this primary was replaced, or a failed
+// replica could not be taken out of the in-sync set. Replicas recovering
+// from the primary receive the operations too, but are not counted.
+func (r *Replicator) replicate(ctx context.Context, shard *Shard, group *replicationGroup, ops []*TranslogOperation) (*pb.WriteShardsInfo, error) {
+	info := &pb.WriteShardsInfo{Total: int32(group.activeCopies()), Successful: 1}
+
+	// Recovery targets are read after the operations were applied, so a
+	// recovery that starts in between reads them from the translog
+	recovering := r.recoveryTargets(shardKey(shard.IndexName, shard.ShardID), group)
+	if (len(group.replicas) == 0 && len(recovering) == 0) || len(ops) == 0 {
+		info.Successful = info.Total
+		return info, nil
+	}
+
+	operations, err := replicationOperationsToProto(ops)
+	if err != nil {
+		return nil, err
+	}
+	req := &pb.ReplicateOperationsRequest{
+		IndexName:   shard.IndexName,
+		ShardId:     shard.ShardID,
+		PrimaryTerm: group.primaryTerm,
+		Operations:  operations,
+	}
+
+	targets := append(append([]replicaTarget(nil), group.replicas...), recovering...)
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target replicaTarget) {
+			defer wg.Done()
+			errs[i] = r.sendToReplica(ctx, target, req)
+		}(i, target)
+	}
+	wg.Wait()
+
+	for i, target := range targets {
+		active := i < len(group.replicas)
+		if errs[i] == nil {
+			if active {
+				info.Successful++
+			}
+			continue
+		}
+		if active {
+			info.Failed++
+		}
+		if err := r.failReplica(ctx, shard, group, target, errs[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return info, nil
+}
+
+// sendToReplica sends a replication request to a replica
+func (r *Replicator) sendToReplica(ctx context.Context, target replicaTarget, req *pb.ReplicateOperationsRequest) error {
+	conn, err := r.conn(target.address)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, replicationTimeout)
+	defer cancel()
+
+	_, err = pb.NewDataServiceClient(conn).ReplicateOperations(ctx, req)
+	return err
+}
+
+// failReplica has the master take a replica that failed a write out of the
+// in-sync set, and drops it from the cached group
+func (r *Replicator) failReplica(ctx context.Context, shard *Shard, group *replicationGroup, target replicaTarget, cause error) error {
+	if status.Code(cause) == codes.Aborted {
+		return fmt.Errorf("%w: the replica on %s is in a newer term", ErrStalePrimaryTerm, target.nodeID)
+	}
+
+	r.logger.Warn("Replica failed a replicated write",
+		zap.String("index", shard.IndexName),
+		zap.Int32("shard_id", shard.ShardID),
+		zap.String("node", target.nodeID),
+		zap.Error(cause))
+
+	if err := r.master.FailShard(ctx, shard.IndexName, shard.ShardID, target.nodeID, group.primaryTerm, cause.Error()); err != nil {
+		if status.Code(err) == codes.Aborted {
+			return fmt.Errorf("%w: %v", ErrStalePrimaryTerm, err)
+		}
+		return fmt.Errorf("failed to fail the replica on %s: %w", target.nodeID, err)
+	}
+
+	key := shardKey(shard.IndexName, shard.ShardID)
+	r.mu.Lock()
+	if cached, exists := r.groups[key]; exists {
+		replicas := make([]replicaTarget, 0, len(cached.replicas))
+		for _, replica := range cached.replicas {
+			if replica.nodeID != target.nodeID {
+				replicas = append(replicas, replica)
+			}
+		}
+		cached.replicas = replicas
+	}
+	delete(r.targets[key], target.nodeID)
+	r.mu.Unlock()
+
+	return nil
+}
+
+// conn returns the pooled connection to a replica's node
+func (r *Replicator) conn(address string) (*grpc.ClientConn, error) {
+	r.connMu.Lock()
+	defer r.connMu.Unlock()
+
+	if conn, exists := r.conns[address]; exists {
+		return conn, nil
+	}
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to replica at %s: %w", address, err)
+	}
+	r.conns[address] = conn
+	return conn, nil
+}
+
+// replicationStatus converts a replication error to a gRPC status
+func replicationStatus(err error) error {
+	switch {
+	case errors.Is(err, ErrStalePrimaryTerm):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, ErrNotPrimary):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, ErrRoutingUnknown):
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.Errorf(codes.Internal, "failed to replicate: %v", err)
+}
+
+// replicationOperationsToProto converts translog operations for a
+// replication request
+func replicationOperationsToProto(ops []*TranslogOperation) ([]*pb.ReplicationOperation, error) {
+	operations := make([]*pb.ReplicationOperation, 0, len(ops))
+	for _, op := range ops {
+		operation := &pb.ReplicationOperation{
+			SeqNo:       op.SeqNo,
+			PrimaryTerm: op.PrimaryTerm,
+			Type:        string(op.Type),
+			DocId:       op.DocID,
+		}
+		if op.Source != nil {
+			document, err := structpb.NewStruct(op.Source)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert document %s: %w", op.DocID, err)
+			}
+			operation.Document = document
+		}
+		operations = append(operations, operation)
+	}
+	return operations, nil
+}
+
+// replicationOperationsFromProto converts the operations of a replication
+// request to translog operations
+func replicationOperationsFromProto(operations []*pb.ReplicationOperation) []*TranslogOperation {
+	ops := make([]*TranslogOperation, 0, len(operations))
+	for _, operation := range operations {
+		op := &TranslogOperation{
+			Type:        TranslogOpType(operation.Type),
+			DocID:       operation.DocId,
+			SeqNo:       operation.SeqNo,
+			PrimaryTerm: operation.PrimaryTerm,
+		}
+		if operation.Document != nil {
+			op.Source = operation.Document.AsMap()
+		}
+		ops = append(ops, op)
+	}
+	return ops
+}
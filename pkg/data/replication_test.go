@@ -0,0 +1,108 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/conjugate/conjugate/pkg/common/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseWaitForActiveShards(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int
+		wantErr  bool
+	}{
+		{"", 1, false},
+		{"all", 3, false},
+		{"0", 0, false},
+		{"2", 2, false},
+		{"4", 0, true},
+		{"-1", 0, true},
+		{"most", 0, true},
+	}
+
+	for _, tt := range tests {
+		required, err := parseWaitForActiveShards(tt.value, 3)
+		if tt.wantErr {
+			assert.Error(t, err, tt.value)
+			continue
+		}
+		require.NoError(t, err, tt.value)
+		assert.Equal(t, tt.expected, required, tt.value)
+	}
+}
+
+func TestBuildReplicationGroups(t *testing.T) {
+	state := &pb.ClusterStateResponse{
+		Indices: []*pb.IndexMetadata{
+			{IndexName: "products", Settings: &pb.IndexSettings{NumberOfShards: 2, NumberOfReplicas: 2}},
+		},
+		Nodes: []*pb.NodeInfo{
+			{NodeId: "node-1", BindAddr: "10.0.0.1", GrpcPort: 9300},
+			{NodeId: "node-2", BindAddr: "10.0.0.2", GrpcPort: 9300},
+			{NodeId: "node-3", BindAddr: "10.0.0.3", GrpcPort: 9300},
+		},
+		RoutingTable: &pb.RoutingTable{
+			Indices: map[string]*pb.IndexRoutingTable{
+				"products": {
+					IndexName: "products",
+					Shards: map[int32]*pb.ShardRouting{
+						0: {
+							ShardId:    0,
+							IsPrimary:  true,
+							Allocation: &pb.ShardAllocation{NodeId: "node-1", State: pb.ShardAllocation_SHARD_STATE_STARTED, InSync: true, PrimaryTerm: 2},
+							Replicas: []*pb.ShardAllocation{
+								{NodeId: "node-2", State: pb.ShardAllocation_SHARD_STATE_STARTED, InSync: true},
+								{NodeId: "node-3", State: pb.ShardAllocation_SHARD_STATE_INITIALIZING},
+							},
+						},
+						1: {
+							ShardId:    1,
+							IsPrimary:  true,
+							Allocation: &pb.ShardAllocation{NodeId: "node-2", State: pb.ShardAllocation_SHARD_STATE_UNASSIGNED, PrimaryTerm: 1},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	groups := buildReplicationGroups(state)
+
+	// Only the in-sync replica receives writes
+	group := groups[shardKey("products", 0)]
+	require.NotNil(t, group)
+	assert.Equal(t, "node-1", group.primaryNodeID)
+	assert.Equal(t, int64(2), group.primaryTerm)
+	assert.Equal(t, 3, group.copies)
+	assert.Equal(t, []replicaTarget{{nodeID: "node-2", address: "10.0.0.2:9300"}}, group.replicas)
+
+	// The initializing replica recovers from the primary instead
+	assert.Equal(t, "10.0.0.1:9300", group.primaryAddress)
+	assert.Equal(t, []replicaTarget{{nodeID: "node-3", address: "10.0.0.3:9300"}}, group.recovering)
+	assert.True(t, group.isRecovering("node-3"))
+	assert.False(t, group.isRecovering("node-2"))
+
+	assert.NoError(t, group.checkActiveShards("2"))
+	assert.ErrorIs(t, group.checkActiveShards("all"), ErrNotEnoughActiveShards)
+
+	// A shard without a primary has no group
+	assert.Nil(t, groups[shardKey("products", 1)])
+}
+
+func TestReplicatorGroupWithoutRouting(t *testing.T) {
+	// The master is unreachable, so the routing never arrives
+	master := NewMasterClient("node-1", "127.0.0.1:1", zap.NewNop())
+	r := NewReplicator("node-1", master, nil, zap.NewNop())
+
+	// No copy may accept writes as the primary in the meantime
+	_, err := r.group(context.Background(), &Shard{IndexName: "products", ShardID: 0})
+	assert.ErrorIs(t, err, ErrRoutingUnknown)
+	assert.Equal(t, codes.Unavailable, status.Code(replicationStatus(err)))
+}
@@ -0,0 +1,85 @@
+package data
+
+// seqNoTracker tracks the sequence numbers a shard copy has processed. The
+// primary assigns them in the order it applies writes, from 1; a replica may
+// receive them out of order when writes are replicated concurrently. The
+// local checkpoint is the highest sequence number below which every
+// operation was processed.
+type seqNoTracker struct {
+	maxSeqNo   int64
+	checkpoint int64
+	processed  map[int64]bool   // Processed sequence numbers above the checkpoint
+	docSeqNos  map[string]int64 // Last sequence number applied to documents written above the checkpoint
+}
+
+// newSeqNoTracker creates a tracker for a history that was processed up to
+// checkpoint and saw sequence numbers up to maxSeqNo
+func newSeqNoTracker(maxSeqNo, checkpoint int64) *seqNoTracker {
+	if maxSeqNo < checkpoint {
+		maxSeqNo = checkpoint
+	}
+	return &seqNoTracker{
+		maxSeqNo:   maxSeqNo,
+		checkpoint: checkpoint,
+		processed:  make(map[int64]bool),
+		docSeqNos:  make(map[string]int64),
+	}
+}
+
+// next assigns the next sequence number, on the primary
+func (t *seqNoTracker) next() int64 {
+	t.maxSeqNo++
+	return t.maxSeqNo
+}
+
+// stale reports whether an operation on docID must not be applied: it was
+// processed already, or the document was written by a later operation
+func (t *seqNoTracker) stale(seqNo int64, docID string) bool {
+	if seqNo <= t.checkpoint || t.processed[seqNo] {
+		return true
+	}
+	last, exists := t.docSeqNos[docID]
+	return exists && last > seqNo
+}
+
+// markProcessed records an operation on docID as processed and advances the
+// checkpoint over every contiguous processed sequence number
+func (t *seqNoTracker) markProcessed(seqNo int64, docID string) {
+	if seqNo <= t.checkpoint {
+		return
+	}
+	if seqNo > t.maxSeqNo {
+		t.maxSeqNo = seqNo
+	}
+	t.processed[seqNo] = true
+	if last, exists := t.docSeqNos[docID]; !exists || last < seqNo {
+		t.docSeqNos[docID] = seqNo
+	}
+
+	advanced := false
+	for t.processed[t.checkpoint+1] {
+		t.checkpoint++
+		delete(t.processed, t.checkpoint)
+		advanced = true
+	}
+	if !advanced {
+		return
+	}
+
+	// Operations at or below the checkpoint are stale by sequence number
+	// alone, so their documents need not be remembered
+	for docID, last := range t.docSeqNos {
+		if last <= t.checkpoint {
+			delete(t.docSeqNos, docID)
+		}
+	}
+}
+
+// fillGaps marks every sequence number up to the highest seen as processed,
+// when a replica becomes the primary. The operations it never received were
+// never acknowledged and are not coming.
+func (t *seqNoTracker) fillGaps() {
+	t.checkpoint = t.maxSeqNo
+	t.processed = make(map[int64]bool)
+	t.docSeqNos = make(map[string]int64)
+}
@@ -0,0 +1,59 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeqNoTracker_InOrder(t *testing.T) {
+	tracker := newSeqNoTracker(0, 0)
+
+	for i := int64(1); i <= 3; i++ {
+		seqNo := tracker.next()
+		assert.Equal(t, i, seqNo)
+		tracker.markProcessed(seqNo, "doc")
+	}
+
+	assert.Equal(t, int64(3), tracker.checkpoint)
+	assert.Empty(t, tracker.processed)
+	assert.Empty(t, tracker.docSeqNos)
+}
+
+func TestSeqNoTracker_OutOfOrder(t *testing.T) {
+	tracker := newSeqNoTracker(0, 0)
+
+	tracker.markProcessed(2, "a")
+	tracker.markProcessed(4, "b")
+	assert.Equal(t, int64(0), tracker.checkpoint)
+	assert.Equal(t, int64(4), tracker.maxSeqNo)
+
+	// Processed operations and older writes to a document are stale
+	assert.True(t, tracker.stale(2, "a"))
+	assert.True(t, tracker.stale(1, "a"))
+	assert.True(t, tracker.stale(3, "b"))
+	assert.False(t, tracker.stale(3, "c"))
+
+	tracker.markProcessed(1, "c")
+	assert.Equal(t, int64(2), tracker.checkpoint)
+	tracker.markProcessed(3, "c")
+	assert.Equal(t, int64(4), tracker.checkpoint)
+	assert.Empty(t, tracker.docSeqNos)
+
+	// Anything at or below the checkpoint was processed
+	assert.True(t, tracker.stale(1, "z"))
+	assert.False(t, tracker.stale(5, "b"))
+}
+
+func TestSeqNoTracker_Restored(t *testing.T) {
+	tracker := newSeqNoTracker(7, 5)
+	assert.True(t, tracker.stale(5, "a"))
+	assert.Equal(t, int64(8), tracker.next())
+
+	// A replica that becomes the primary gives up on the operations it missed
+	tracker = newSeqNoTracker(0, 0)
+	tracker.markProcessed(3, "a")
+	tracker.fillGaps()
+	assert.Equal(t, int64(3), tracker.checkpoint)
+	assert.Equal(t, int64(4), tracker.next())
+}
@@ -11,6 +11,8 @@ import (
 	"time"
 
 	"github.com/conjugate/conjugate/pkg/common/config"
+	"github.com/conjugate/conjugate/pkg/common/dates"
+	pb "github.com/conjugate/conjugate/pkg/common/proto"
 	"github.com/conjugate/conjugate/pkg/data/diagon"
 	"github.com/conjugate/conjugate/pkg/wasm"
 	"go.uber.org/zap"
@@ -72,6 +74,12 @@ func (sm *ShardManager) Stop(ctx context.Context) error {
 
 // CreateShard creates a new shard
 func (sm *ShardManager) CreateShard(ctx context.Context, indexName string, shardID int32, isPrimary bool) error {
+	return sm.CreateShardWithMappings(ctx, indexName, shardID, isPrimary, nil)
+}
+
+// CreateShardWithMappings creates a new shard with the index's field
+// mappings. Without mappings, any saved in the shard directory apply.
+func (sm *ShardManager) CreateShardWithMappings(ctx context.Context, indexName string, shardID int32, isPrimary bool, mappings map[string]*pb.FieldMapping) error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
@@ -99,35 +107,29 @@ func (sm *ShardManager) CreateShard(ctx context.Context, indexName string, shard
 		return fmt.Errorf("failed to create Diagon shard: %w", err)
 	}
 
-	// Create shard wrapper with default analyzer settings
-	shard := &Shard{
-		IndexName:        indexName,
-		ShardID:          shardID,
-		IsPrimary:        isPrimary,
-		Path:             shardPath,
-		State:            ShardStateInitializing,
-		DiagonShard:      diagonShard,
-		udfFilter:        sm.udfFilter,
-		DocsCount:        0,
-		SizeBytes:        0,
-		logger:           sm.logger.With(zap.String("shard", key)),
-		analyzerSettings: DefaultAnalyzerSettings(), // Use default analyzer settings
-		analyzerCache:    NewAnalyzerCache(),        // Create analyzer cache
-
-		// Batch indexing configuration
-		pendingDocs:      0,
-		lastCommitTime:   time.Now(),
-		lastRefreshTime:  time.Now(),
-		commitBatchSize:  1000,               // Default: commit every 1000 docs
-		commitInterval:   1 * time.Second,    // Default: commit every 1 second
-		refreshInterval:  1 * time.Second,    // Default: refresh every 1 second
-		stopCommitter:    make(chan struct{}),
-		stopRefresher:    make(chan struct{}),
-		needsCommit:      false,
-		needsRefresh:     false,
-	}
-
-	// Start background committer and refresher
+	// Create shard wrapper and start background committer and refresher
+	shard := sm.newShard(indexName, shardID, isPrimary, shardPath, diagonShard)
+	shard.State = ShardStateInitializing
+	if mappings != nil {
+		err = shard.SetMappings(mappings)
+	} else {
+		err = shard.loadMappings()
+	}
+	if err != nil {
+		diagonShard.Close()
+		return err
+	}
+	if err := sm.openTranslog(shard); err != nil {
+		diagonShard.Close()
+		return err
+	}
+	if err := shard.countDocs(); err != nil {
+		sm.logger.Warn("Failed to count documents in shard",
+			zap.String("index", indexName),
+			zap.Int32("shard_id", shardID),
+			zap.Error(err))
+	}
+	shard.SizeBytes = dirSize(shardPath)
 	shard.startBackgroundCommitter()
 	shard.startBackgroundRefresher()
 
@@ -280,8 +282,11 @@ func (sm *ShardManager) loadShards() error {
 				continue
 			}
 
-			// Create/open the Diagon shard
-			diagonShard, err := sm.diagon.CreateShard(shardPath)
+			// Reopen the Diagon shard, reusing it if the bridge still holds it
+			diagonShard, err := sm.diagon.GetShard(shardPath)
+			if err != nil {
+				diagonShard, err = sm.diagon.CreateShard(shardPath)
+			}
 			if err != nil {
 				sm.logger.Error("Failed to load shard from disk",
 					zap.String("index", indexName),
@@ -291,33 +296,36 @@ func (sm *ShardManager) loadShards() error {
 				continue
 			}
 
-			// Create shard wrapper
-			shard := &Shard{
-				IndexName:        indexName,
-				ShardID:          int32(shardID),
-				IsPrimary:        false, // Will be set by master during registration
-				Path:             shardPath,
-				State:            ShardStateStarted,
-				DiagonShard:      diagonShard,
-				udfFilter:        sm.udfFilter,
-				DocsCount:        0, // TODO: Could load actual count from Diagon
-				SizeBytes:        0, // TODO: Could calculate from disk
-				logger:           sm.logger.With(zap.String("shard", key)),
-				analyzerSettings: DefaultAnalyzerSettings(), // Use default analyzer settings
-				analyzerCache:    NewAnalyzerCache(),        // Create analyzer cache
-
-				// Batch indexing configuration
-				pendingDocs:      0,
-				lastCommitTime:   time.Now(),
-				lastRefreshTime:  time.Now(),
-				commitBatchSize:  1000,               // Default: commit every 1000 docs
-				commitInterval:   1 * time.Second,    // Default: commit every 1 second
-				refreshInterval:  1 * time.Second,    // Default: refresh every 1 second
-				stopCommitter:    make(chan struct{}),
-				stopRefresher:    make(chan struct{}),
-				needsCommit:      false,
-				needsRefresh:     false,
+			// Primary/replica role is owned by the master's routing table
+			shard := sm.newShard(indexName, int32(shardID), false, shardPath, diagonShard)
+
+			// Mappings decide how replayed writes are indexed
+			if err := shard.loadMappings(); err != nil {
+				diagonShard.Close()
+				sm.logger.Error("Failed to load shard mappings",
+					zap.String("index", indexName),
+					zap.Int64("shard_id", shardID),
+					zap.Error(err))
+				continue
+			}
+
+			// Replay writes acknowledged after the last Diagon commit
+			if err := sm.openTranslog(shard); err != nil {
+				diagonShard.Close()
+				sm.logger.Error("Failed to recover shard from translog",
+					zap.String("index", indexName),
+					zap.Int64("shard_id", shardID),
+					zap.Error(err))
+				continue
+			}
+
+			if err := shard.countDocs(); err != nil {
+				sm.logger.Warn("Failed to count documents in recovered shard",
+					zap.String("index", indexName),
+					zap.Int64("shard_id", shardID),
+					zap.Error(err))
 			}
+			shard.SizeBytes = dirSize(shardPath)
 
 			// Start background committer and refresher
 			shard.startBackgroundCommitter()
@@ -331,6 +339,7 @@ func (sm *ShardManager) loadShards() error {
 			sm.logger.Info("Loaded shard from disk",
 				zap.String("index", indexName),
 				zap.Int32("shard_id", int32(shardID)),
+				zap.Int64("docs_count", shard.DocsCount),
 				zap.String("path", shardPath))
 		}
 	}
@@ -341,6 +350,105 @@ func (sm *ShardManager) loadShards() error {
 	return nil
 }
 
+// newShard builds a shard wrapper with default analyzer and batching settings.
+// The shard starts in the started state; background workers are not running yet.
+func (sm *ShardManager) newShard(indexName string, shardID int32, isPrimary bool, path string, diagonShard *diagon.Shard) *Shard {
+	shard := &Shard{
+		IndexName:        indexName,
+		ShardID:          shardID,
+		IsPrimary:        isPrimary,
+		Path:             path,
+		State:            ShardStateStarted,
+		DiagonShard:      diagonShard,
+		udfFilter:        sm.udfFilter,
+		logger:           sm.logger.With(zap.String("shard", shardKey(indexName, shardID))),
+		analyzerSettings: DefaultAnalyzerSettings(), // Use default analyzer settings
+		analyzerCache:    NewAnalyzerCache(),        // Create analyzer cache
+
+		// Batch indexing configuration
+		lastCommitTime:  time.Now(),
+		lastRefreshTime: time.Now(),
+		commitBatchSize: defaultCommitBatchSize,
+		commitInterval:  defaultCommitInterval,
+		refreshInterval: defaultRefreshInterval,
+		stopCommitter:   make(chan struct{}),
+		stopRefresher:   make(chan struct{}),
+		refreshed:       make(chan struct{}),
+		seqNos:          newSeqNoTracker(0, 0),
+	}
+
+	// Diagon indexes string fields and analyzes match queries with the
+	// shard's analyzers, so both sides produce the same terms
+	diagonShard.SetAnalyzer(shard.AnalyzeTokens)
+	diagonShard.SetMaxExpansions(sm.cfg.MaxTermExpansions)
+	return shard
+}
+
+// translogConfig returns the translog configuration for new shards
+func (sm *ShardManager) translogConfig() *TranslogConfig {
+	return &TranslogConfig{
+		Durability:   TranslogDurability(sm.cfg.TranslogDurability),
+		SyncInterval: sm.cfg.TranslogSyncInterval,
+	}
+}
+
+// openTranslog opens the shard's translog and replays any operations that
+// were acknowledged but not committed to Diagon before the node stopped
+func (sm *ShardManager) openTranslog(shard *Shard) error {
+	translog, err := OpenTranslog(filepath.Join(shard.Path, translogDirName), sm.translogConfig(), shard.logger)
+	if err != nil {
+		return fmt.Errorf("failed to open translog: %w", err)
+	}
+	shard.translog = translog
+
+	stats := translog.SeqNoStats()
+	shard.seqNos = newSeqNoTracker(stats.MaxSeqNo, stats.LocalCheckpoint)
+	if stats.PrimaryTerm > shard.primaryTerm {
+		shard.primaryTerm = stats.PrimaryTerm
+	}
+
+	if err := shard.replayTranslog(); err != nil {
+		translog.Close()
+		shard.translog = nil
+		return err
+	}
+
+	return nil
+}
+
+// countDocs sets DocsCount from the shard's reader, refreshed first so it
+// sees the operations replayed from the translog
+func (s *Shard) countDocs() error {
+	if err := s.refreshReader(); err != nil {
+		return err
+	}
+	docsCount, err := s.DiagonShard.NumDocs()
+	if err != nil {
+		return fmt.Errorf("failed to count documents: %w", err)
+	}
+	s.mu.Lock()
+	s.DocsCount = docsCount
+	s.mu.Unlock()
+	return nil
+}
+
+// dirSize returns the total size in bytes of all files under path
+func dirSize(path string) int64 {
+	var size int64
+	filepath.WalkDir(path, func(_ string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
 // shardKey generates a unique key for a shard
 func shardKey(indexName string, shardID int32) string {
 	return fmt.Sprintf("%s:%d", indexName, shardID)
@@ -361,6 +469,14 @@ type Shard struct {
 	mu               sync.RWMutex
 	analyzerSettings *AnalyzerSettings // Analyzer configuration for this shard
 	analyzerCache    *AnalyzerCache    // Cached analyzer instances
+	analyzeMu        sync.Mutex        // Guards the analyzer cache and settings during analysis
+	translog         *Translog         // Write-ahead log of acknowledged writes
+	seqNos           *seqNoTracker     // Sequence numbers processed by this copy
+	primaryTerm      int64             // Latest primary term this copy knows of
+	recoveries       int               // Peer recoveries reading the translog, which keep its generation
+
+	// Field mappings of the index
+	mappings map[string]*pb.FieldMapping
 
 	// Batch indexing optimization
 	pendingDocs       int
@@ -374,9 +490,21 @@ type Shard struct {
 	stopCommitter     chan struct{} // Signal to stop background committer
 	stopRefresher     chan struct{} // Signal to stop background refresher
 	needsCommit       bool          // Flag indicating pending changes need commit
-	needsRefresh      bool          // Flag indicating committed changes need refresh
+	needsRefresh      bool          // Flag indicating writes need a refresh to be searchable
+
+	// Refresh tracking for refresh=wait_for
+	writeSeq     int64         // Sequence number of the last acknowledged write
+	refreshedSeq int64         // Last write sequence number made searchable
+	refreshed    chan struct{} // Closed and replaced after every refresh
 }
 
+// Refresh policies accepted on writes, as in the OpenSearch refresh parameter
+const (
+	RefreshPolicyFalse   = "false"    // Visible after the next scheduled refresh
+	RefreshPolicyTrue    = "true"     // Refresh the shard before responding
+	RefreshPolicyWaitFor = "wait_for" // Wait for a refresh before responding
+)
+
 // ShardState represents the state of a shard
 type ShardState string
 
@@ -391,6 +519,8 @@ const (
 func (s *Shard) SetAnalyzerSettings(settings *AnalyzerSettings) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.analyzeMu.Lock()
+	defer s.analyzeMu.Unlock()
 	s.analyzerSettings = settings
 }
 
@@ -403,8 +533,8 @@ func (s *Shard) GetAnalyzerSettings() *AnalyzerSettings {
 
 // AnalyzeText analyzes text using the configured analyzer for a field
 func (s *Shard) AnalyzeText(fieldName, text string) ([]string, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.analyzeMu.Lock()
+	defer s.analyzeMu.Unlock()
 
 	if s.analyzerSettings == nil || s.analyzerCache == nil {
 		return nil, fmt.Errorf("analyzer settings not initialized")
@@ -413,8 +543,30 @@ func (s *Shard) AnalyzeText(fieldName, text string) ([]string, error) {
 	return AnalyzeField(s.analyzerCache, s.analyzerSettings, fieldName, text)
 }
 
+// AnalyzeTokens analyzes text using the configured analyzer for a field,
+// keeping token positions. It does not take the shard lock, as Diagon calls
+// it while indexing under that lock.
+func (s *Shard) AnalyzeTokens(fieldName, text string) ([]diagon.Token, error) {
+	s.analyzeMu.Lock()
+	defer s.analyzeMu.Unlock()
+
+	if s.analyzerSettings == nil || s.analyzerCache == nil {
+		return nil, fmt.Errorf("analyzer settings not initialized")
+	}
+
+	return AnalyzeFieldTokens(s.analyzerCache, s.analyzerSettings, fieldName, text)
+}
+
 // IndexDocument indexes a document in the shard with batch commit optimization
 func (s *Shard) IndexDocument(ctx context.Context, docID string, doc map[string]interface{}) error {
+	_, err := s.IndexDocumentOp(ctx, docID, doc)
+	return err
+}
+
+// IndexDocumentOp indexes a document as the shard's primary and returns the
+// operation as recorded in the translog, with the sequence number and
+// primary term to replicate it with
+func (s *Shard) IndexDocumentOp(ctx context.Context, docID string, doc map[string]interface{}) (*TranslogOperation, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -424,18 +576,28 @@ func (s *Shard) IndexDocument(ctx context.Context, docID string, doc map[string]
 		zap.String("doc_id", docID))
 
 	if s.State != ShardStateStarted {
-		return fmt.Errorf("shard is not ready")
+		return nil, fmt.Errorf("shard is not ready")
 	}
 
-	// Index document to memory buffer (fast operation)
-	if err := s.DiagonShard.IndexDocument(docID, doc); err != nil {
+	// Index document to memory buffer, replacing any previous version,
+	// once the operation is in the translog
+	op := &TranslogOperation{
+		Type:   TranslogOpIndex,
+		DocID:  docID,
+		Source: doc,
+	}
+	created, err := s.DiagonShard.UpsertDocumentLogged(docID, doc, func(bool) error {
+		op.SeqNo = s.seqNos.next()
+		op.PrimaryTerm = s.primaryTerm
+		return s.recordOperation(op)
+	})
+	if err != nil {
 		s.logger.Error("Failed to index document", zap.Error(err))
-		return fmt.Errorf("failed to index document: %w", err)
+		return nil, fmt.Errorf("failed to index document: %w", err)
+	}
+	if created {
+		s.DocsCount++
 	}
-
-	s.pendingDocs++
-	s.DocsCount++
-	s.needsCommit = true
 
 	// Commit only when batch threshold reached (refresh happens separately)
 	shouldCommit := s.pendingDocs >= s.commitBatchSize ||
@@ -443,15 +605,34 @@ func (s *Shard) IndexDocument(ctx context.Context, docID string, doc map[string]
 
 	if shouldCommit {
 		if err := s.commitBatch(); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	s.logger.Debug("Document indexed successfully",
 		zap.String("doc_id", docID),
+		zap.Int64("seq_no", op.SeqNo),
 		zap.Int("pending_docs", s.pendingDocs),
 		zap.Int64("total_docs", s.DocsCount))
 
+	return op, nil
+}
+
+// recordOperation marks an operation as processed and records it in the
+// translog before Diagon applies it, so that a write Diagon holds is never
+// missing from the translog. Must be called with s.mu held.
+func (s *Shard) recordOperation(op *TranslogOperation) error {
+	s.seqNos.markProcessed(op.SeqNo, op.DocID)
+
+	if err := s.translog.Add(op); err != nil {
+		s.logger.Error("Failed to write translog", zap.Error(err))
+		return fmt.Errorf("failed to write translog: %w", err)
+	}
+	s.translog.SetLocalCheckpoint(s.seqNos.checkpoint)
+
+	s.pendingDocs++
+	s.needsCommit = true
+	s.markWritten()
 	return nil
 }
 
@@ -490,23 +671,17 @@ func (s *Shard) startBackgroundCommitter() {
 
 // startBackgroundRefresher starts a goroutine that periodically refreshes the reader
 func (s *Shard) startBackgroundRefresher() {
-	s.refreshTicker = time.NewTicker(s.refreshInterval)
+	s.refreshTicker = time.NewTicker(defaultRefreshInterval)
+	s.resetRefreshTicker()
 
 	go func() {
 		for {
 			select {
 			case <-s.refreshTicker.C:
-				// Check if refresh needed
-				s.mu.Lock()
-				if s.needsRefresh {
-					s.logger.Debug("Background refresh triggered",
-						zap.Duration("since_last", time.Since(s.lastRefreshTime)))
-
-					if err := s.refreshReader(); err != nil {
-						s.logger.Error("Background refresh failed", zap.Error(err))
-					}
+				// Refreshes without holding s.mu, so searches keep running
+				if err := s.refreshReader(); err != nil {
+					s.logger.Error("Background refresh failed", zap.Error(err))
 				}
-				s.mu.Unlock()
 
 			case <-s.stopRefresher:
 				s.logger.Debug("Stopping background refresher")
@@ -519,6 +694,17 @@ func (s *Shard) startBackgroundRefresher() {
 		zap.Duration("refresh_interval", s.refreshInterval))
 }
 
+// resetRefreshTicker applies refreshInterval to the refresh ticker, which
+// stops while refreshes are disabled. Writes then become searchable on
+// explicit refreshes only.
+func (s *Shard) resetRefreshTicker() {
+	if s.refreshInterval <= 0 {
+		s.refreshTicker.Stop()
+		return
+	}
+	s.refreshTicker.Reset(s.refreshInterval)
+}
+
 // commitBatch commits pending documents to disk
 // Must be called with s.mu held
 func (s *Shard) commitBatch() error {
@@ -539,7 +725,11 @@ func (s *Shard) commitBatch() error {
 	s.pendingDocs = 0
 	s.lastCommitTime = time.Now()
 	s.needsCommit = false
-	s.needsRefresh = true // Mark that refresh is needed
+
+	// Committed operations no longer need to be replayed
+	if err := s.translog.MarkCommitted(); err != nil {
+		s.logger.Error("Failed to checkpoint translog", zap.Error(err))
+	}
 
 	s.logger.Info("Batch committed to disk",
 		zap.Int("docs", pendingCount),
@@ -549,12 +739,37 @@ func (s *Shard) commitBatch() error {
 	return nil
 }
 
-// refreshReader refreshes the reader to make committed documents searchable
-// Must be called with s.mu held
+// markWritten records an acknowledged write that the next refresh must make
+// searchable. Must be called with s.mu held.
+func (s *Shard) markWritten() {
+	s.writeSeq++
+	s.needsRefresh = true
+}
+
+// markRefreshed records that writes up to seq are searchable and wakes
+// writes waiting for a refresh. Must be called with s.mu held.
+func (s *Shard) markRefreshed(seq int64) {
+	if seq > s.refreshedSeq {
+		s.refreshedSeq = seq
+	}
+	s.needsRefresh = s.writeSeq > s.refreshedSeq
+	s.lastRefreshTime = time.Now()
+
+	close(s.refreshed)
+	s.refreshed = make(chan struct{})
+}
+
+// refreshReader swaps in a Diagon searcher that sees every write acknowledged
+// so far. It must be called without s.mu held: searches and gets keep using
+// the previous searcher while Diagon commits.
 func (s *Shard) refreshReader() error {
+	s.mu.Lock()
 	if !s.needsRefresh {
+		s.mu.Unlock()
 		return nil
 	}
+	seq := s.writeSeq
+	s.mu.Unlock()
 
 	startTime := time.Now()
 
@@ -565,12 +780,42 @@ func (s *Shard) refreshReader() error {
 	}
 
 	duration := time.Since(startTime)
-	s.lastRefreshTime = time.Now()
-	s.needsRefresh = false
 
-	s.logger.Info("Reader refreshed",
+	s.mu.Lock()
+	s.markRefreshed(seq)
+	sinceCommit := time.Since(s.lastCommitTime)
+	s.mu.Unlock()
+
+	s.logger.Debug("Reader refreshed",
 		zap.Duration("duration", duration),
-		zap.Duration("since_last_commit", time.Since(s.lastCommitTime)))
+		zap.Duration("since_last_commit", sinceCommit))
+
+	return nil
+}
+
+// WaitForRefresh blocks until a refresh has made every write acknowledged
+// before the call searchable. It backs refresh=wait_for on writes.
+func (s *Shard) WaitForRefresh(ctx context.Context) error {
+	s.mu.Lock()
+	seq := s.writeSeq
+	for s.refreshedSeq < seq {
+		if s.State == ShardStateClosed {
+			s.mu.Unlock()
+			return fmt.Errorf("shard is closed")
+		}
+
+		refreshed := s.refreshed
+		s.mu.Unlock()
+
+		select {
+		case <-refreshed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		s.mu.Lock()
+	}
+	s.mu.Unlock()
 
 	return nil
 }
@@ -579,9 +824,9 @@ func (s *Shard) refreshReader() error {
 // This can be called to force immediate visibility of documents
 func (s *Shard) Flush(ctx context.Context) error {
 	s.mu.Lock()
-	defer s.mu.Unlock()
 
 	if s.State != ShardStateStarted {
+		s.mu.Unlock()
 		return fmt.Errorf("shard is not ready")
 	}
 
@@ -593,17 +838,84 @@ func (s *Shard) Flush(ctx context.Context) error {
 	// Commit pending docs if any
 	if s.needsCommit && s.pendingDocs > 0 {
 		if err := s.commitBatch(); err != nil {
+			s.mu.Unlock()
 			return err
 		}
 	}
+	s.mu.Unlock()
 
 	// Refresh to make documents searchable
-	if s.needsRefresh {
-		if err := s.refreshReader(); err != nil {
-			return err
+	return s.refreshReader()
+}
+
+// Dynamic index settings applied to the shard's batching, as sent by the
+// master with CreateShard and UpdateShardSettings. An absent setting takes
+// its default.
+const (
+	settingRefreshInterval = "refresh_interval"
+	settingCommitInterval  = "commit_interval"
+	settingCommitBatchSize = "commit_batch_size"
+
+	defaultCommitBatchSize = 1000            // Commit every 1000 docs
+	defaultCommitInterval  = 1 * time.Second // Commit every second
+	defaultRefreshInterval = 1 * time.Second // Refresh every second
+
+	// refreshDisabled is the refresh interval of refresh_interval -1, which
+	// stops background refreshes as in OpenSearch
+	refreshDisabled = -time.Millisecond
+)
+
+// BatchSettings is the batch commit and refresh configuration of a shard
+type BatchSettings struct {
+	CommitBatchSize int
+	CommitInterval  time.Duration
+	RefreshInterval time.Duration
+}
+
+// ParseBatchSettings reads the batch configuration from index settings
+func ParseBatchSettings(settings map[string]string) (BatchSettings, error) {
+	batch := BatchSettings{
+		CommitBatchSize: defaultCommitBatchSize,
+		CommitInterval:  defaultCommitInterval,
+		RefreshInterval: defaultRefreshInterval,
+	}
+
+	if value, ok := settings[settingCommitBatchSize]; ok {
+		size, err := strconv.Atoi(value)
+		if err != nil || size <= 0 {
+			return batch, fmt.Errorf("invalid %s [%s]: expected a positive number", settingCommitBatchSize, value)
+		}
+		batch.CommitBatchSize = size
+	}
+	for name, interval := range map[string]*time.Duration{
+		settingCommitInterval:  &batch.CommitInterval,
+		settingRefreshInterval: &batch.RefreshInterval,
+	} {
+		value, ok := settings[name]
+		if !ok {
+			continue
+		}
+		if name == settingRefreshInterval && value == "-1" {
+			*interval = refreshDisabled
+			continue
+		}
+		parsed, err := dates.ParseTimeValue(value)
+		if err != nil {
+			return batch, fmt.Errorf("invalid %s [%s]: expected a positive time value", name, value)
 		}
+		*interval = parsed
 	}
 
+	return batch, nil
+}
+
+// ApplyBatchSettings updates the batch configuration from index settings
+func (s *Shard) ApplyBatchSettings(settings map[string]string) error {
+	batch, err := ParseBatchSettings(settings)
+	if err != nil {
+		return err
+	}
+	s.SetBatchConfig(batch.CommitBatchSize, batch.CommitInterval, batch.RefreshInterval)
 	return nil
 }
 
@@ -616,16 +928,13 @@ func (s *Shard) SetBatchConfig(batchSize int, commitInterval, refreshInterval ti
 	s.commitInterval = commitInterval
 	s.refreshInterval = refreshInterval
 
-	// Restart commit ticker with new interval
+	// Reset the tickers in place: the background loops keep receiving from
+	// the same channels, which a replaced ticker would leave them blocked on
 	if s.commitTicker != nil {
-		s.commitTicker.Stop()
-		s.commitTicker = time.NewTicker(commitInterval)
+		s.commitTicker.Reset(commitInterval)
 	}
-
-	// Restart refresh ticker with new interval
 	if s.refreshTicker != nil {
-		s.refreshTicker.Stop()
-		s.refreshTicker = time.NewTicker(refreshInterval)
+		s.resetRefreshTicker()
 	}
 
 	s.logger.Info("Batch configuration updated",
@@ -652,17 +961,24 @@ func (s *Shard) GetBatchStats() map[string]interface{} {
 	}
 }
 
-// Search executes a search query on the shard
+// Search executes a search query on the shard and returns the top hits
 func (s *Shard) Search(ctx context.Context, query []byte) (*diagon.SearchResult, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	return s.SearchPage(ctx, query, nil, nil, nil, 0, diagon.DefaultSearchSize)
+}
 
-	if s.State != ShardStateStarted {
+// SearchPage executes a search query on the shard and returns hits
+// [from, from+size) of the shard's ranking, plus the shard's results for
+// the aggs section in aggs (if any). A serialized filterExpression drops
+// the matches it rejects before hits, totals and aggregations are computed.
+// Non-empty sortClauses order the hits by field values instead of score.
+func (s *Shard) SearchPage(ctx context.Context, query []byte, filterExpression []byte, aggs []byte, sortClauses []string, from, size int) (*diagon.SearchResult, error) {
+	if !s.isStarted() {
 		return nil, fmt.Errorf("shard is not ready")
 	}
 
-	// Execute search using Diagon (pass empty filterExpression)
-	result, err := s.DiagonShard.Search(query, nil)
+	// Execute search using Diagon. The shard lock is not held, so searches
+	// never wait for a commit or refresh.
+	result, err := s.DiagonShard.SearchPage(query, filterExpression, aggs, sortClauses, from, size)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute search: %w", err)
 	}
@@ -691,12 +1007,16 @@ func (s *Shard) Search(ctx context.Context, query []byte) (*diagon.SearchResult,
 	return result, nil
 }
 
-// GetDocument retrieves a document by ID
-func (s *Shard) GetDocument(ctx context.Context, docID string) (map[string]interface{}, error) {
+// isStarted reports whether the shard accepts requests
+func (s *Shard) isStarted() bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	return s.State == ShardStateStarted
+}
 
-	if s.State != ShardStateStarted {
+// GetDocument retrieves a document by ID
+func (s *Shard) GetDocument(ctx context.Context, docID string) (map[string]interface{}, error) {
+	if !s.isStarted() {
 		return nil, fmt.Errorf("shard is not ready")
 	}
 
@@ -709,39 +1029,66 @@ func (s *Shard) GetDocument(ctx context.Context, docID string) (map[string]inter
 	return doc, nil
 }
 
-// DeleteDocument deletes a document by ID
-func (s *Shard) DeleteDocument(ctx context.Context, docID string) error {
+// DeleteDocument deletes a document by ID and reports whether it existed
+func (s *Shard) DeleteDocument(ctx context.Context, docID string) (bool, error) {
+	op, err := s.DeleteDocumentOp(ctx, docID)
+	return op != nil, err
+}
+
+// DeleteDocumentOp deletes a document as the shard's primary and returns the
+// operation to replicate, or nil when the document did not exist
+func (s *Shard) DeleteDocumentOp(ctx context.Context, docID string) (*TranslogOperation, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if s.State != ShardStateStarted {
-		return fmt.Errorf("shard is not ready")
+		return nil, fmt.Errorf("shard is not ready")
 	}
 
-	// Delete document using Diagon
-	if err := s.DiagonShard.DeleteDocument(docID); err != nil {
-		return fmt.Errorf("failed to delete document: %w", err)
+	// Delete document using Diagon once the operation is in the translog.
+	// Deleting a missing document is not an operation.
+	var op *TranslogOperation
+	found, err := s.DiagonShard.DeleteDocumentLogged(docID, func(found bool) error {
+		if !found {
+			return nil
+		}
+		op = &TranslogOperation{
+			Type:        TranslogOpDelete,
+			DocID:       docID,
+			SeqNo:       s.seqNos.next(),
+			PrimaryTerm: s.primaryTerm,
+		}
+		return s.recordOperation(op)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete document: %w", err)
+	}
+	if !found {
+		return nil, nil
 	}
 
 	s.DocsCount--
 
-	s.logger.Debug("Deleted document", zap.String("doc_id", docID))
+	s.logger.Debug("Deleted document",
+		zap.String("doc_id", docID),
+		zap.Int64("seq_no", op.SeqNo))
 
-	return nil
+	return op, nil
 }
 
-// Refresh refreshes the shard (makes recent changes visible)
+// Refresh makes every acknowledged write searchable, like the _refresh API.
+// Searches are not blocked while it runs.
 func (s *Shard) Refresh() error {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-
 	if s.State != ShardStateStarted {
+		s.mu.Unlock()
 		return fmt.Errorf("shard is not ready")
 	}
+	s.needsRefresh = true
+	s.mu.Unlock()
 
-	// Refresh using Diagon
-	if err := s.DiagonShard.Refresh(); err != nil {
-		return fmt.Errorf("failed to refresh shard: %w", err)
+	if err := s.refreshReader(); err != nil {
+		return err
 	}
 
 	s.logger.Debug("Refreshed shard")
@@ -749,8 +1096,9 @@ func (s *Shard) Refresh() error {
 	return nil
 }
 
-// FlushDiagon flushes the Diagon shard (persists translog to disk)
-// This is different from Flush() which commits pending batch documents
+// FlushDiagon durably commits the Diagon shard and truncates the translog.
+// This is different from Flush() which commits pending batch documents and
+// refreshes for visibility.
 func (s *Shard) FlushDiagon() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -759,12 +1107,81 @@ func (s *Shard) FlushDiagon() error {
 		return fmt.Errorf("shard is not ready")
 	}
 
-	// Flush using Diagon
+	// Flush buffered documents and commit using Diagon
 	if err := s.DiagonShard.Flush(); err != nil {
 		return fmt.Errorf("failed to flush shard: %w", err)
 	}
+	if err := s.DiagonShard.Commit(); err != nil {
+		return fmt.Errorf("failed to commit shard: %w", err)
+	}
+
+	if s.pendingDocs > 0 || s.needsCommit {
+		s.needsRefresh = true
+	}
+	s.pendingDocs = 0
+	s.needsCommit = false
+	s.lastCommitTime = time.Now()
+
+	// Everything in the translog is now in the Diagon commit, but peer
+	// recoveries still read the operations from it
+	if s.recoveries > 0 {
+		return s.translog.MarkCommitted()
+	}
+	if err := s.translog.Truncate(); err != nil {
+		return fmt.Errorf("failed to truncate translog: %w", err)
+	}
+
+	s.logger.Debug("Flushed Diagon shard",
+		zap.Int64("translog_generation", s.translog.Generation()))
+
+	return nil
+}
+
+// TranslogGeneration returns the shard's current translog generation
+func (s *Shard) TranslogGeneration() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.translog.Generation()
+}
+
+// replayTranslog re-applies translog operations that are not part of the
+// last Diagon commit, then commits them
+func (s *Shard) replayTranslog() error {
+	ops, err := s.translog.ReadOperations()
+	if err != nil {
+		return fmt.Errorf("failed to read translog: %w", err)
+	}
+	if len(ops) == 0 {
+		return nil
+	}
 
-	s.logger.Debug("Flushed Diagon shard")
+	for _, op := range ops {
+		switch op.Type {
+		case TranslogOpIndex:
+			err = s.DiagonShard.IndexDocument(op.DocID, op.Source)
+		case TranslogOpDelete:
+			_, err = s.DiagonShard.DeleteDocument(op.DocID)
+		default:
+			err = fmt.Errorf("unknown translog operation type: %s", op.Type)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to replay translog operation for %s: %w", op.DocID, err)
+		}
+		s.seqNos.markProcessed(op.SeqNo, op.DocID)
+	}
+	s.translog.SetLocalCheckpoint(s.seqNos.checkpoint)
+
+	if err := s.DiagonShard.Commit(); err != nil {
+		return fmt.Errorf("failed to commit replayed operations: %w", err)
+	}
+	if err := s.translog.MarkCommitted(); err != nil {
+		return err
+	}
+	s.needsRefresh = true
+
+	s.logger.Info("Replayed translog",
+		zap.Int("operations", len(ops)),
+		zap.Int64("generation", s.translog.Generation()))
 
 	return nil
 }
@@ -808,6 +1225,9 @@ func (s *Shard) Close() error {
 			s.logger.Error("Failed to commit pending docs on close", zap.Error(err))
 		} else {
 			s.needsRefresh = true
+			if err := s.translog.MarkCommitted(); err != nil {
+				s.logger.Error("Failed to checkpoint translog on close", zap.Error(err))
+			}
 		}
 	}
 
@@ -816,17 +1236,32 @@ func (s *Shard) Close() error {
 		s.logger.Info("Refreshing reader before close")
 		if err := s.DiagonShard.Refresh(); err != nil {
 			s.logger.Error("Failed to refresh on close", zap.Error(err))
+		} else {
+			s.markRefreshed(s.writeSeq)
 		}
 	}
 
+	// Wake writes still waiting for a refresh; they see the closed state
+	close(s.refreshed)
+	s.refreshed = make(chan struct{})
+
 	// Close Diagon shard
 	if err := s.DiagonShard.Close(); err != nil {
 		return fmt.Errorf("failed to close Diagon shard: %w", err)
 	}
 
+	// Close translog
+	if s.translog != nil {
+		if err := s.translog.Close(); err != nil {
+			s.logger.Error("Failed to close translog", zap.Error(err))
+		}
+	}
+
 	// Close analyzer cache
 	if s.analyzerCache != nil {
+		s.analyzeMu.Lock()
 		s.analyzerCache.Close()
+		s.analyzeMu.Unlock()
 	}
 
 	// State already set to ShardStateClosed at the beginning
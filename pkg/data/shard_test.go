@@ -3,9 +3,13 @@ package data
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/conjugate/conjugate/pkg/common/config"
+	pb "github.com/conjugate/conjugate/pkg/common/proto"
+	"github.com/conjugate/conjugate/pkg/coordination/expressions"
 	"github.com/conjugate/conjugate/pkg/data/diagon"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -323,11 +327,167 @@ func TestShard_DeleteDocument(t *testing.T) {
 	assert.Equal(t, int64(1), shard.DocsCount)
 
 	// Delete the document
-	err = shard.DeleteDocument(ctx, "doc-1")
+	found, err := shard.DeleteDocument(ctx, "doc-1")
 	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, int64(0), shard.DocsCount)
+
+	// Deleted document is no longer visible
+	_, err = shard.GetDocument(ctx, "doc-1")
+	assert.Error(t, err)
+
+	// Deleting again reports not found
+	found, err = shard.DeleteDocument(ctx, "doc-1")
+	assert.NoError(t, err)
+	assert.False(t, found)
+	assert.Equal(t, int64(0), shard.DocsCount)
+}
+
+func TestShard_WritesOnFreshShard(t *testing.T) {
+	cfg := &config.DataNodeConfig{
+		NodeID:    "node-1",
+		DataDir:   t.TempDir(),
+		MaxShards: 10,
+	}
+	logger := zap.NewNop()
+	diagonBridge, err := diagon.NewDiagonBridge(&diagon.Config{
+		DataDir: cfg.DataDir,
+		Logger:  logger,
+	})
+	require.NoError(t, err)
+
+	sm := NewShardManager(cfg, logger, diagonBridge, nil)
+
+	ctx := context.Background()
+	sm.Start(ctx)
+	defer sm.Stop(ctx)
+
+	// A shard that was never written to or refreshed
+	require.NoError(t, sm.CreateShard(ctx, "test-index", 0, true))
+	shard, err := sm.GetShard("test-index", 0)
+	require.NoError(t, err)
+
+	// Deleting from it reports not found rather than failing
+	found, err := shard.DeleteDocument(ctx, "doc-1")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	// The first document indexed into it is created
+	err = shard.IndexDocument(ctx, "doc-1", map[string]interface{}{"title": "First"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), shard.DocsCount)
+
+	found, err = shard.DeleteDocument(ctx, "doc-1")
+	require.NoError(t, err)
+	assert.True(t, found)
 	assert.Equal(t, int64(0), shard.DocsCount)
 }
 
+func TestShard_ReindexReplacesDocument(t *testing.T) {
+	cfg := &config.DataNodeConfig{
+		NodeID:    "node-1",
+		DataDir:   t.TempDir(),
+		MaxShards: 10,
+	}
+	logger := zap.NewNop()
+	diagonBridge, err := diagon.NewDiagonBridge(&diagon.Config{
+		DataDir: cfg.DataDir,
+		Logger:  logger,
+	})
+	require.NoError(t, err)
+
+	sm := NewShardManager(cfg, logger, diagonBridge, nil)
+
+	ctx := context.Background()
+	sm.Start(ctx)
+	defer sm.Stop(ctx)
+
+	require.NoError(t, sm.CreateShard(ctx, "test-index", 0, true))
+	shard, err := sm.GetShard("test-index", 0)
+	require.NoError(t, err)
+
+	// Index the same _id twice, before and after a refresh
+	require.NoError(t, shard.IndexDocument(ctx, "doc-1", map[string]interface{}{"title": "first"}))
+	require.NoError(t, shard.IndexDocument(ctx, "doc-1", map[string]interface{}{"title": "second"}))
+	require.NoError(t, shard.Refresh())
+	require.NoError(t, shard.IndexDocument(ctx, "doc-1", map[string]interface{}{"title": "third"}))
+	assert.Equal(t, int64(1), shard.DocsCount)
+
+	// Only the latest version is returned
+	doc, err := shard.GetDocument(ctx, "doc-1")
+	require.NoError(t, err)
+	assert.Equal(t, "third", doc["title"])
+
+	result, err := shard.Search(ctx, []byte(`{"match_all":{}}`))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.TotalHits)
+}
+
+func TestShard_ReplicaOperations(t *testing.T) {
+	cfg := &config.DataNodeConfig{
+		NodeID:    "node-1",
+		DataDir:   t.TempDir(),
+		MaxShards: 10,
+	}
+	logger := zap.NewNop()
+	diagonBridge, err := diagon.NewDiagonBridge(&diagon.Config{
+		DataDir: cfg.DataDir,
+		Logger:  logger,
+	})
+	require.NoError(t, err)
+
+	sm := NewShardManager(cfg, logger, diagonBridge, nil)
+
+	ctx := context.Background()
+	sm.Start(ctx)
+	defer sm.Stop(ctx)
+
+	require.NoError(t, sm.CreateShard(ctx, "primary", 0, true))
+	require.NoError(t, sm.CreateShard(ctx, "replica", 0, false))
+	primary, err := sm.GetShard("primary", 0)
+	require.NoError(t, err)
+	replica, err := sm.GetShard("replica", 0)
+	require.NoError(t, err)
+	primary.UpdatePrimaryTerm(1, true)
+
+	// The primary assigns sequence numbers in its term
+	indexOp, err := primary.IndexDocumentOp(ctx, "doc-1", map[string]interface{}{"title": "first"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), indexOp.SeqNo)
+	assert.Equal(t, int64(1), indexOp.PrimaryTerm)
+	updateOp, err := primary.IndexDocumentOp(ctx, "doc-1", map[string]interface{}{"title": "second"})
+	require.NoError(t, err)
+	deleteOp, err := primary.DeleteDocumentOp(ctx, "missing")
+	require.NoError(t, err)
+	assert.Nil(t, deleteOp)
+	assert.Equal(t, int64(2), primary.LocalCheckpoint())
+
+	// Out of order, the older write does not overwrite the newer one
+	checkpoint, err := replica.ApplyReplicaOperations(ctx, 1, []*TranslogOperation{updateOp})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), checkpoint)
+	checkpoint, err = replica.ApplyReplicaOperations(ctx, 1, []*TranslogOperation{indexOp, updateOp})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), checkpoint)
+
+	doc, err := replica.GetDocument(ctx, "doc-1")
+	require.NoError(t, err)
+	assert.Equal(t, "second", doc["title"])
+	assert.Equal(t, int64(1), replica.DocsCount)
+
+	// Once the replica is in a newer term the old primary is rejected
+	replica.UpdatePrimaryTerm(2, false)
+	_, err = replica.ApplyReplicaOperations(ctx, 1, []*TranslogOperation{{Type: TranslogOpDelete, DocID: "doc-1", SeqNo: 3, PrimaryTerm: 1}})
+	assert.ErrorIs(t, err, ErrStalePrimaryTerm)
+
+	// Promoted, the replica continues the history in its own term
+	replica.UpdatePrimaryTerm(2, true)
+	op, err := replica.DeleteDocumentOp(ctx, "doc-1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), op.SeqNo)
+	assert.Equal(t, int64(2), op.PrimaryTerm)
+}
+
 func TestShard_Search(t *testing.T) {
 	cfg := &config.DataNodeConfig{
 		NodeID:    "node-1",
@@ -369,6 +529,650 @@ func TestShard_Search(t *testing.T) {
 	assert.NotNil(t, result)
 }
 
+func TestShard_SearchPage(t *testing.T) {
+	cfg := &config.DataNodeConfig{
+		NodeID:    "node-1",
+		DataDir:   t.TempDir(),
+		MaxShards: 10,
+	}
+	logger := zap.NewNop()
+	diagonBridge, err := diagon.NewDiagonBridge(&diagon.Config{
+		DataDir: cfg.DataDir,
+		Logger:  logger,
+	})
+	require.NoError(t, err)
+
+	sm := NewShardManager(cfg, logger, diagonBridge, nil)
+
+	ctx := context.Background()
+	sm.Start(ctx)
+	defer sm.Stop(ctx)
+
+	require.NoError(t, sm.CreateShard(ctx, "test-index", 0, true))
+	shard, err := sm.GetShard("test-index", 0)
+	require.NoError(t, err)
+
+	for i := 0; i < 25; i++ {
+		err = shard.IndexDocument(ctx, fmt.Sprintf("doc-%d", i), map[string]interface{}{"title": "Paged Document"})
+		require.NoError(t, err)
+	}
+	require.NoError(t, shard.Refresh())
+
+	query := []byte(`{"match_all":{}}`)
+
+	// More than the default ten hits
+	result, err := shard.SearchPage(ctx, query, nil, nil, nil, 0, 20)
+	require.NoError(t, err)
+	assert.Equal(t, int64(25), result.TotalHits)
+	assert.Len(t, result.Hits, 20)
+
+	// Pages do not overlap
+	seen := make(map[string]bool)
+	for from := 0; from < 25; from += 10 {
+		page, err := shard.SearchPage(ctx, query, nil, nil, nil, from, 10)
+		require.NoError(t, err)
+		for _, hit := range page.Hits {
+			assert.False(t, seen[hit.ID], "hit %s returned twice", hit.ID)
+			seen[hit.ID] = true
+		}
+	}
+	assert.Len(t, seen, 25)
+
+	// Past the end and size zero return no hits but still count matches
+	result, err = shard.SearchPage(ctx, query, nil, nil, nil, 30, 10)
+	require.NoError(t, err)
+	assert.Empty(t, result.Hits)
+	assert.Equal(t, int64(25), result.TotalHits)
+
+	result, err = shard.SearchPage(ctx, query, nil, nil, nil, 0, 0)
+	require.NoError(t, err)
+	assert.Empty(t, result.Hits)
+	assert.Equal(t, int64(25), result.TotalHits)
+}
+
+func TestShard_SearchAggregations(t *testing.T) {
+	cfg := &config.DataNodeConfig{
+		NodeID:    "node-1",
+		DataDir:   t.TempDir(),
+		MaxShards: 10,
+	}
+	logger := zap.NewNop()
+	diagonBridge, err := diagon.NewDiagonBridge(&diagon.Config{
+		DataDir: cfg.DataDir,
+		Logger:  logger,
+	})
+	require.NoError(t, err)
+
+	sm := NewShardManager(cfg, logger, diagonBridge, nil)
+
+	ctx := context.Background()
+	sm.Start(ctx)
+	defer sm.Stop(ctx)
+
+	require.NoError(t, sm.CreateShard(ctx, "test-index", 0, true))
+	shard, err := sm.GetShard("test-index", 0)
+	require.NoError(t, err)
+
+	categories := []string{"electronics", "books", "electronics", "toys", "books", "electronics"}
+	for i, category := range categories {
+		err = shard.IndexDocument(ctx, fmt.Sprintf("doc-%d", i), map[string]interface{}{
+			"category": category,
+			"price":    float64(10 * (i + 1)),
+		})
+		require.NoError(t, err)
+	}
+	require.NoError(t, shard.Refresh())
+
+	aggs := []byte(`{
+		"by_category": {"terms": {"field": "category"}},
+		"price_stats": {"stats": {"field": "price"}},
+		"price_ranges": {"range": {"field": "price", "ranges": [{"to": 30}, {"from": 30}]}},
+		"price_histogram": {"histogram": {"field": "price", "interval": 25}},
+		"book_filter": {"filters": {"filters": {"books": {"term": {"category": "books"}}}}}
+	}`)
+
+	// Aggregations cover every match, not just the returned page
+	result, err := shard.SearchPage(ctx, []byte(`{"match_all":{}}`), nil, aggs, nil, 0, 1)
+	require.NoError(t, err)
+	assert.Len(t, result.Hits, 1)
+	require.Len(t, result.Aggregations, 5)
+
+	byCategory := result.Aggregations["by_category"]
+	assert.Equal(t, "terms", byCategory.Type)
+	assert.Equal(t, []map[string]interface{}{
+		{"key": "electronics", "doc_count": int64(3)},
+		{"key": "books", "doc_count": int64(2)},
+		{"key": "toys", "doc_count": int64(1)},
+	}, byCategory.Buckets)
+
+	stats := result.Aggregations["price_stats"]
+	assert.Equal(t, int64(6), stats.Count)
+	assert.Equal(t, 10.0, stats.Min)
+	assert.Equal(t, 60.0, stats.Max)
+	assert.Equal(t, 210.0, stats.Sum)
+	assert.Equal(t, 35.0, stats.Avg)
+
+	ranges := result.Aggregations["price_ranges"]
+	require.Len(t, ranges.Buckets, 2)
+	assert.Equal(t, "*-30.0", ranges.Buckets[0]["key"])
+	assert.Equal(t, int64(2), ranges.Buckets[0]["doc_count"])
+	assert.Equal(t, "30.0-*", ranges.Buckets[1]["key"])
+	assert.Equal(t, int64(4), ranges.Buckets[1]["doc_count"])
+
+	histogram := result.Aggregations["price_histogram"]
+	assert.Equal(t, []map[string]interface{}{
+		{"key": 0.0, "doc_count": int64(2)},
+		{"key": 25.0, "doc_count": int64(2)},
+		{"key": 50.0, "doc_count": int64(2)},
+	}, histogram.Buckets)
+
+	filters := result.Aggregations["book_filter"]
+	require.Len(t, filters.Buckets, 1)
+	assert.Equal(t, "books", filters.Buckets[0]["key"])
+	assert.Equal(t, int64(2), filters.Buckets[0]["doc_count"])
+
+	// Only the query's matches are aggregated
+	result, err = shard.SearchPage(ctx, []byte(`{"term":{"category":"electronics"}}`), nil,
+		[]byte(`{"total":{"sum":{"field":"price"}}}`), nil, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, result.Aggregations["total"].Sum)
+
+	// Sub-aggregations are computed over each bucket's documents
+	result, err = shard.SearchPage(ctx, []byte(`{"match_all":{}}`), nil,
+		[]byte(`{"by_category":{"terms":{"field":"category"},"aggs":{"avg_price":{"avg":{"field":"price"}}}}}`), nil, 0, 10)
+	require.NoError(t, err)
+	buckets := result.Aggregations["by_category"].Buckets
+	require.Len(t, buckets, 3)
+	assert.Equal(t, "books", buckets[1]["key"])
+	subAggs, ok := buckets[1]["aggregations"].(map[string]diagon.AggregationResult)
+	require.True(t, ok)
+	assert.Equal(t, 35.0, subAggs["avg_price"].Avg)
+
+	// Malformed aggregations are rejected
+	_, err = shard.SearchPage(ctx, []byte(`{"match_all":{}}`), nil, []byte(`{"bad":{"nope":{"field":"price"}}}`), nil, 0, 10)
+	assert.Error(t, err)
+}
+
+func TestShard_SearchFilterExpression(t *testing.T) {
+	cfg := &config.DataNodeConfig{
+		NodeID:    "node-1",
+		DataDir:   t.TempDir(),
+		MaxShards: 10,
+	}
+	logger := zap.NewNop()
+	diagonBridge, err := diagon.NewDiagonBridge(&diagon.Config{
+		DataDir: cfg.DataDir,
+		Logger:  logger,
+	})
+	require.NoError(t, err)
+
+	sm := NewShardManager(cfg, logger, diagonBridge, nil)
+
+	ctx := context.Background()
+	sm.Start(ctx)
+	defer sm.Stop(ctx)
+
+	require.NoError(t, sm.CreateShard(ctx, "test-index", 0, true))
+	shard, err := sm.GetShard("test-index", 0)
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		err = shard.IndexDocument(ctx, fmt.Sprintf("doc-%d", i), map[string]interface{}{
+			"category": "books",
+			"price":    float64(10 * (i + 1)),
+		})
+		require.NoError(t, err)
+	}
+	require.NoError(t, shard.Refresh())
+
+	// price * 2 > 120, i.e. the four documents priced 70 to 100
+	expr, err := expressions.NewParser().Parse(map[string]interface{}{
+		"op": ">",
+		"left": map[string]interface{}{
+			"op":    "*",
+			"left":  map[string]interface{}{"field": "price", "type": "float64"},
+			"right": map[string]interface{}{"const": 2.0},
+		},
+		"right": map[string]interface{}{"const": 120.0},
+	})
+	require.NoError(t, err)
+	filter, err := expressions.NewSerializer().Serialize(expr)
+	require.NoError(t, err)
+
+	// Totals, pages and aggregations only see the accepted documents
+	result, err := shard.SearchPage(ctx, []byte(`{"match_all":{}}`), filter,
+		[]byte(`{"total":{"sum":{"field":"price"}}}`), nil, 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), result.TotalHits)
+	assert.Len(t, result.Hits, 2)
+	for _, hit := range result.Hits {
+		assert.Contains(t, []string{"doc-6", "doc-7", "doc-8", "doc-9"}, hit.ID)
+	}
+	assert.Equal(t, 340.0, result.Aggregations["total"].Sum)
+
+	// Malformed expressions are rejected
+	_, err = shard.SearchPage(ctx, []byte(`{"match_all":{}}`), []byte{0xff}, nil, nil, 0, 10)
+	assert.Error(t, err)
+}
+
+func TestShard_SearchSort(t *testing.T) {
+	cfg := &config.DataNodeConfig{
+		NodeID:    "node-1",
+		DataDir:   t.TempDir(),
+		MaxShards: 10,
+	}
+	logger := zap.NewNop()
+	diagonBridge, err := diagon.NewDiagonBridge(&diagon.Config{
+		DataDir: cfg.DataDir,
+		Logger:  logger,
+	})
+	require.NoError(t, err)
+
+	sm := NewShardManager(cfg, logger, diagonBridge, nil)
+
+	ctx := context.Background()
+	sm.Start(ctx)
+	defer sm.Stop(ctx)
+
+	require.NoError(t, sm.CreateShard(ctx, "test-index", 0, true))
+	shard, err := sm.GetShard("test-index", 0)
+	require.NoError(t, err)
+
+	docs := map[string]map[string]interface{}{
+		"doc-1": {"level": "warn", "@timestamp": "2024-01-01T10:00:00Z", "bytes": float64(300)},
+		"doc-2": {"level": "error", "@timestamp": "2024-01-01T12:00:00Z", "bytes": float64(100)},
+		"doc-3": {"level": "warn", "@timestamp": "2024-01-01T11:00:00Z"},
+		"doc-4": {"level": "info", "@timestamp": "2024-01-01T09:00:00Z", "bytes": float64(100)},
+	}
+	for id, doc := range docs {
+		require.NoError(t, shard.IndexDocument(ctx, id, doc))
+	}
+	require.NoError(t, shard.Refresh())
+
+	hitIDs := func(result *diagon.SearchResult) []string {
+		ids := make([]string, len(result.Hits))
+		for i, hit := range result.Hits {
+			ids[i] = hit.ID
+		}
+		return ids
+	}
+	matchAll := []byte(`{"match_all":{}}`)
+
+	// Dates sort by epoch millis, newest first
+	result, err := shard.SearchPage(ctx, matchAll, nil, nil, []string{`{"@timestamp":"desc"}`}, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), result.TotalHits)
+	assert.Equal(t, []string{"doc-2", "doc-3", "doc-1", "doc-4"}, hitIDs(result))
+	assert.Equal(t, []interface{}{float64(1704110400000)}, result.Hits[0].Sort)
+
+	// Keyword ascending, with a second key breaking ties
+	result, err = shard.SearchPage(ctx, matchAll, nil, nil,
+		[]string{`"level"`, `{"@timestamp":{"order":"asc"}}`}, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"doc-2", "doc-4", "doc-1", "doc-3"}, hitIDs(result))
+	assert.Equal(t, "warn", result.Hits[2].Sort[0])
+
+	// Missing values sort last in either order unless asked otherwise
+	result, err = shard.SearchPage(ctx, matchAll, nil, nil,
+		[]string{`{"bytes":"desc"}`, `{"@timestamp":"asc"}`}, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"doc-1", "doc-4", "doc-2", "doc-3"}, hitIDs(result))
+	assert.Nil(t, result.Hits[3].Sort[0])
+
+	result, err = shard.SearchPage(ctx, matchAll, nil, nil,
+		[]string{`{"bytes":{"order":"asc","missing":"_first"}}`}, 0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"doc-3"}, hitIDs(result))
+
+	result, err = shard.SearchPage(ctx, matchAll, nil, nil,
+		[]string{`{"bytes":{"order":"asc","missing":200}}`, `{"@timestamp":"asc"}`}, 2, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"doc-3", "doc-1"}, hitIDs(result))
+	assert.Equal(t, float64(200), result.Hits[0].Sort[0])
+
+	// Malformed sort clauses are rejected
+	_, err = shard.SearchPage(ctx, matchAll, nil, nil, []string{`{"bytes":"sideways"}`}, 0, 10)
+	assert.Error(t, err)
+}
+
+func TestShard_SearchMatch(t *testing.T) {
+	cfg := &config.DataNodeConfig{
+		NodeID:    "node-1",
+		DataDir:   t.TempDir(),
+		MaxShards: 10,
+	}
+	logger := zap.NewNop()
+	diagonBridge, err := diagon.NewDiagonBridge(&diagon.Config{
+		DataDir: cfg.DataDir,
+		Logger:  logger,
+	})
+	require.NoError(t, err)
+
+	sm := NewShardManager(cfg, logger, diagonBridge, nil)
+
+	ctx := context.Background()
+	sm.Start(ctx)
+	defer sm.Stop(ctx)
+
+	require.NoError(t, sm.CreateShard(ctx, "test-index", 0, true))
+	shard, err := sm.GetShard("test-index", 0)
+	require.NoError(t, err)
+
+	docs := map[string]map[string]interface{}{
+		"doc-1": {"title": "The Quick Brown Fox"},
+		"doc-2": {"title": "A quick red fox jumps"},
+		"doc-3": {"title": "Brown bears are quick"},
+		"doc-4": {"title": "Slow green turtle"},
+	}
+	for id, doc := range docs {
+		require.NoError(t, shard.IndexDocument(ctx, id, doc))
+	}
+	require.NoError(t, shard.Refresh())
+
+	search := func(query string) []string {
+		t.Helper()
+		result, err := shard.SearchPage(ctx, []byte(query), nil, nil, nil, 0, 10)
+		require.NoError(t, err)
+		ids := make([]string, len(result.Hits))
+		for i, hit := range result.Hits {
+			ids[i] = hit.ID
+		}
+		return ids
+	}
+
+	// Query text is analyzed like the indexed text, so case does not matter
+	// and any term matches by default
+	assert.ElementsMatch(t, []string{"doc-1", "doc-2", "doc-3"}, search(`{"match":{"title":"QUICK fox"}}`))
+	assert.ElementsMatch(t, []string{"doc-1", "doc-2"},
+		search(`{"match":{"title":{"query":"quick fox","operator":"and"}}}`))
+	assert.ElementsMatch(t, []string{"doc-1", "doc-3"},
+		search(`{"match":{"title":{"query":"quick brown fox bears","minimum_should_match":"75%"}}}`))
+	assert.Empty(t, search(`{"match":{"title":"elephant"}}`))
+
+	// The original text is still returned
+	result, err := shard.SearchPage(ctx, []byte(`{"match":{"title":"turtle"}}`), nil, nil, nil, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, result.Hits, 1)
+	assert.Equal(t, "Slow green turtle", result.Hits[0].Source["title"])
+
+	// Phrases need their terms in order, give or take slop
+	assert.Equal(t, []string{"doc-1"}, search(`{"match_phrase":{"title":"quick brown"}}`))
+	assert.Empty(t, search(`{"match_phrase":{"title":"brown quick"}}`))
+	assert.ElementsMatch(t, []string{"doc-1", "doc-2"},
+		search(`{"match_phrase":{"title":{"query":"quick fox","slop":1}}}`))
+	assert.Equal(t, []string{"doc-3"},
+		search(`{"bool":{"must":[{"match_phrase":{"title":{"query":"brown quick","slop":3}}}],"must_not":[{"match":{"title":"fox"}}]}}`))
+
+	_, err = shard.SearchPage(ctx, []byte(`{"match":{"title":{"query":"fox","operator":"xor"}}}`), nil, nil, nil, 0, 10)
+	assert.Error(t, err)
+}
+
+func TestShard_SearchMultiTerm(t *testing.T) {
+	cfg := &config.DataNodeConfig{
+		NodeID:            "node-1",
+		DataDir:           t.TempDir(),
+		MaxShards:         10,
+		MaxTermExpansions: 5,
+	}
+	logger := zap.NewNop()
+	diagonBridge, err := diagon.NewDiagonBridge(&diagon.Config{
+		DataDir: cfg.DataDir,
+		Logger:  logger,
+	})
+	require.NoError(t, err)
+
+	sm := NewShardManager(cfg, logger, diagonBridge, nil)
+
+	ctx := context.Background()
+	sm.Start(ctx)
+	defer sm.Stop(ctx)
+
+	require.NoError(t, sm.CreateShard(ctx, "test-index", 0, true))
+	shard, err := sm.GetShard("test-index", 0)
+	require.NoError(t, err)
+
+	docs := map[string]map[string]interface{}{
+		"doc-1": {"title": "The Quick Brown Fox"},
+		"doc-2": {"title": "A quick red fox jumps"},
+		"doc-3": {"title": "Brown bears are quick"},
+		"doc-4": {"title": "Slow green turtle"},
+	}
+	for id, doc := range docs {
+		require.NoError(t, shard.IndexDocument(ctx, id, doc))
+	}
+	require.NoError(t, shard.Refresh())
+
+	search := func(query string) []string {
+		t.Helper()
+		result, err := shard.SearchPage(ctx, []byte(query), nil, nil, nil, 0, 10)
+		require.NoError(t, err)
+		ids := make([]string, len(result.Hits))
+		for i, hit := range result.Hits {
+			ids[i] = hit.ID
+		}
+		return ids
+	}
+
+	assert.ElementsMatch(t, []string{"doc-1", "doc-2", "doc-3"}, search(`{"prefix":{"title":"qu"}}`))
+	assert.ElementsMatch(t, []string{"doc-1", "doc-3"}, search(`{"wildcard":{"title":"b?o*"}}`))
+	assert.ElementsMatch(t, []string{"doc-2", "doc-3"}, search(`{"regexp":{"title":"jumps|bears?"}}`))
+	assert.ElementsMatch(t, []string{"doc-1", "doc-3"},
+		search(`{"regexp":{"title":{"value":"BR.*","case_insensitive":true}}}`))
+	assert.Equal(t, []string{"doc-4"}, search(`{"fuzzy":{"title":"turtel"}}`))
+	assert.Empty(t, search(`{"fuzzy":{"title":{"value":"turtel","transpositions":false,"fuzziness":1}}}`))
+	assert.Empty(t, search(`{"prefix":{"title":"zebra"}}`))
+
+	// Every match scores the boost, however many terms it holds
+	result, err := shard.SearchPage(ctx, []byte(`{"regexp":{"title":{"value":"quick|fox","boost":2}}}`), nil, nil, nil, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, result.Hits, 3)
+	for _, hit := range result.Hits {
+		assert.InDelta(t, 2.0, hit.Score, 1e-6, hit.ID)
+	}
+
+	// Expanding past the configured limit is an error
+	_, err = shard.SearchPage(ctx, []byte(`{"wildcard":{"title":"*"}}`), nil, nil, nil, 0, 10)
+	assert.Error(t, err)
+}
+
+func TestShard_SearchSparseVector(t *testing.T) {
+	cfg := &config.DataNodeConfig{
+		NodeID:    "node-1",
+		DataDir:   t.TempDir(),
+		MaxShards: 10,
+	}
+	logger := zap.NewNop()
+	diagonBridge, err := diagon.NewDiagonBridge(&diagon.Config{
+		DataDir: cfg.DataDir,
+		Logger:  logger,
+	})
+	require.NoError(t, err)
+
+	sm := NewShardManager(cfg, logger, diagonBridge, nil)
+
+	ctx := context.Background()
+	sm.Start(ctx)
+	defer sm.Stop(ctx)
+
+	mappings := map[string]*pb.FieldMapping{
+		"title":     {Type: "text", Index: true},
+		"embedding": {Type: FieldTypeSparseVector, Index: true},
+	}
+	require.NoError(t, sm.CreateShardWithMappings(ctx, "test-index", 0, true, mappings))
+	shard, err := sm.GetShard("test-index", 0)
+	require.NoError(t, err)
+
+	// Mappings are saved with the shard so they apply again on reload
+	assert.FileExists(t, filepath.Join(shard.Path, mappingsFile))
+	assert.Equal(t, FieldTypeSparseVector, shard.GetMappings()["embedding"].GetType())
+
+	docs := map[string]map[string]interface{}{
+		"doc-1": {"title": "one", "embedding": map[string]interface{}{"search": 1.0, "engine": 0.5}},
+		"doc-2": {"title": "two", "embedding": map[string]interface{}{"search": 0.2, "fast": 2.0}},
+		"doc-3": {"title": "three", "embedding": map[string]interface{}{"cooking": 1.0}},
+	}
+	for id, doc := range docs {
+		require.NoError(t, shard.IndexDocument(ctx, id, doc))
+	}
+	require.NoError(t, shard.Refresh())
+
+	// Scores are dot products with the query tokens
+	result, err := shard.SearchPage(ctx,
+		[]byte(`{"neural_sparse":{"embedding":{"query_tokens":{"search":2.0,"fast":0.5}}}}`), nil, nil, nil, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, result.Hits, 2)
+	assert.Equal(t, "doc-1", result.Hits[0].ID)
+	assert.InDelta(t, 2.0, result.Hits[0].Score, 1e-5)
+	assert.Equal(t, "doc-2", result.Hits[1].ID)
+	assert.InDelta(t, 1.4, result.Hits[1].Score, 1e-5)
+
+	result, err = shard.SearchPage(ctx,
+		[]byte(`{"neural_sparse":{"embedding":{"query_tokens":{"engine":1.0},"boost":2}}}`), nil, nil, nil, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, result.Hits, 1)
+	assert.InDelta(t, 1.0, result.Hits[0].Score, 1e-5)
+
+	result, err = shard.SearchPage(ctx,
+		[]byte(`{"neural_sparse":{"embedding":{"query_tokens":{"unknown":1.0}}}}`), nil, nil, nil, 0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, result.Hits)
+
+	// Values of sparse_vector fields must be token weights
+	err = shard.IndexDocument(ctx, "doc-4", map[string]interface{}{"embedding": "search engine"})
+	assert.Error(t, err)
+}
+
+func TestShard_SearchKNN(t *testing.T) {
+	cfg := &config.DataNodeConfig{
+		NodeID:    "node-1",
+		DataDir:   t.TempDir(),
+		MaxShards: 10,
+	}
+	logger := zap.NewNop()
+	diagonBridge, err := diagon.NewDiagonBridge(&diagon.Config{
+		DataDir: cfg.DataDir,
+		Logger:  logger,
+	})
+	require.NoError(t, err)
+
+	sm := NewShardManager(cfg, logger, diagonBridge, nil)
+
+	ctx := context.Background()
+	sm.Start(ctx)
+	defer sm.Stop(ctx)
+
+	mappings := map[string]*pb.FieldMapping{
+		"category":  {Type: "keyword", Index: true},
+		"embedding": {Type: FieldTypeKNNVector, Index: true, Dimension: 2, SpaceType: "l2"},
+	}
+	require.NoError(t, sm.CreateShardWithMappings(ctx, "test-index", 0, true, mappings))
+	shard, err := sm.GetShard("test-index", 0)
+	require.NoError(t, err)
+
+	docs := map[string]map[string]interface{}{
+		"doc-1": {"category": "a", "embedding": []interface{}{1.0, 0.0}},
+		"doc-2": {"category": "b", "embedding": []interface{}{2.0, 0.0}},
+		"doc-3": {"category": "a", "embedding": []interface{}{4.0, 0.0}},
+		"doc-4": {"category": "b", "embedding": []interface{}{8.0, 0.0}},
+	}
+	for id, doc := range docs {
+		require.NoError(t, shard.IndexDocument(ctx, id, doc))
+	}
+	require.NoError(t, shard.Refresh())
+
+	// The k nearest match, scoring 1/(1+d²)
+	result, err := shard.SearchPage(ctx,
+		[]byte(`{"knn":{"field":"embedding","query_vector":[1.5,0],"k":2}}`), nil, nil, nil, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, result.Hits, 2)
+	assert.ElementsMatch(t, []string{"doc-1", "doc-2"}, []string{result.Hits[0].ID, result.Hits[1].ID})
+	assert.InDelta(t, 0.8, result.Hits[0].Score, 1e-5)
+
+	// The filter applies before picking the nearest
+	result, err = shard.SearchPage(ctx,
+		[]byte(`{"knn":{"field":"embedding","query_vector":[1.5,0],"k":2,"filter":{"term":{"category":"b"}}}}`), nil, nil, nil, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, result.Hits, 2)
+	assert.Equal(t, "doc-2", result.Hits[0].ID)
+	assert.Equal(t, "doc-4", result.Hits[1].ID)
+
+	// Query vectors must match the field's dimension
+	_, err = shard.SearchPage(ctx,
+		[]byte(`{"knn":{"field":"embedding","query_vector":[1,0,0],"k":2}}`), nil, nil, nil, 0, 10)
+	assert.Error(t, err)
+
+	// So must indexed vectors
+	err = shard.IndexDocument(ctx, "doc-5", map[string]interface{}{"embedding": []interface{}{1.0}})
+	assert.Error(t, err)
+
+	// The graph is saved in the shard directory when the shard commits
+	require.NoError(t, shard.FlushDiagon())
+	assert.FileExists(t, filepath.Join(shard.Path, "knn", "embedding.hnsw"))
+}
+
+func TestShard_SearchVisibilityFollowsRefresh(t *testing.T) {
+	cfg := &config.DataNodeConfig{
+		NodeID:    "node-1",
+		DataDir:   t.TempDir(),
+		MaxShards: 10,
+	}
+	logger := zap.NewNop()
+	diagonBridge, err := diagon.NewDiagonBridge(&diagon.Config{
+		DataDir: cfg.DataDir,
+		Logger:  logger,
+	})
+	require.NoError(t, err)
+
+	sm := NewShardManager(cfg, logger, diagonBridge, nil)
+
+	ctx := context.Background()
+	sm.Start(ctx)
+	defer sm.Stop(ctx)
+
+	require.NoError(t, sm.CreateShard(ctx, "test-index", 0, true))
+	shard, err := sm.GetShard("test-index", 0)
+	require.NoError(t, err)
+
+	// Keep the background refresher out of the way
+	shard.SetBatchConfig(1000, time.Hour, time.Hour)
+
+	query := []byte(`{"match_all":{}}`)
+
+	// Writes are not searchable until a refresh
+	require.NoError(t, shard.IndexDocument(ctx, "doc-1", map[string]interface{}{"title": "first"}))
+	result, err := shard.Search(ctx, query)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), result.TotalHits)
+
+	require.NoError(t, shard.Refresh())
+	result, err = shard.Search(ctx, query)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.TotalHits)
+
+	// wait_for returns once the next refresh has run
+	require.NoError(t, shard.IndexDocument(ctx, "doc-2", map[string]interface{}{"title": "second"}))
+	waited := make(chan error, 1)
+	go func() {
+		waited <- shard.WaitForRefresh(ctx)
+	}()
+
+	select {
+	case err := <-waited:
+		t.Fatalf("WaitForRefresh returned before a refresh: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, shard.Refresh())
+	require.NoError(t, <-waited)
+
+	result, err = shard.Search(ctx, query)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), result.TotalHits)
+
+	// Nothing left to wait for
+	require.NoError(t, shard.WaitForRefresh(ctx))
+}
+
 func TestShard_RefreshAndFlush(t *testing.T) {
 	cfg := &config.DataNodeConfig{
 		NodeID:    "node-1",
@@ -402,6 +1206,116 @@ func TestShard_RefreshAndFlush(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestShard_TranslogRecoveryAndTruncate(t *testing.T) {
+	cfg := &config.DataNodeConfig{
+		NodeID:    "node-1",
+		DataDir:   t.TempDir(),
+		MaxShards: 10,
+	}
+	logger := zap.NewNop()
+	diagonBridge, err := diagon.NewDiagonBridge(&diagon.Config{
+		DataDir: cfg.DataDir,
+		Logger:  logger,
+	})
+	require.NoError(t, err)
+
+	sm := NewShardManager(cfg, logger, diagonBridge, nil)
+
+	ctx := context.Background()
+	require.NoError(t, sm.Start(ctx))
+	defer sm.Stop(ctx)
+
+	require.NoError(t, sm.CreateShard(ctx, "test-index", 0, true))
+	shard, err := sm.GetShard("test-index", 0)
+	require.NoError(t, err)
+
+	// Acknowledged writes are recorded in the translog
+	err = shard.IndexDocument(ctx, "doc-1", map[string]interface{}{"title": "hello"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, shard.translog.Operations())
+
+	// Flushing commits Diagon and rolls the translog to a new generation
+	generation := shard.TranslogGeneration()
+	require.NoError(t, shard.FlushDiagon())
+	assert.Equal(t, generation+1, shard.TranslogGeneration())
+	assert.Equal(t, 0, shard.translog.Operations())
+
+	ops, err := shard.translog.ReadOperations()
+	require.NoError(t, err)
+	assert.Empty(t, ops)
+}
+
+func TestShardManager_LoadShardsCountsDocs(t *testing.T) {
+	cfg := &config.DataNodeConfig{
+		NodeID:    "node-1",
+		DataDir:   t.TempDir(),
+		MaxShards: 10,
+	}
+	logger := zap.NewNop()
+	diagonBridge, err := diagon.NewDiagonBridge(&diagon.Config{
+		DataDir: cfg.DataDir,
+		Logger:  logger,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	sm := NewShardManager(cfg, logger, diagonBridge, nil)
+	require.NoError(t, sm.Start(ctx))
+	require.NoError(t, sm.CreateShard(ctx, "test-index", 0, true))
+	shard, err := sm.GetShard("test-index", 0)
+	require.NoError(t, err)
+	for _, id := range []string{"doc-1", "doc-2"} {
+		require.NoError(t, shard.IndexDocument(ctx, id, map[string]interface{}{"title": id}))
+	}
+	require.NoError(t, sm.Stop(ctx))
+
+	// The reloaded shard counts the documents it holds, not 0
+	sm = NewShardManager(cfg, logger, diagonBridge, nil)
+	require.NoError(t, sm.Start(ctx))
+	defer sm.Stop(ctx)
+	shard, err = sm.GetShard("test-index", 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), shard.Stats().DocsCount)
+}
+
+func TestShard_TranslogFailureLeavesDiagonUnchanged(t *testing.T) {
+	cfg := &config.DataNodeConfig{
+		NodeID:    "node-1",
+		DataDir:   t.TempDir(),
+		MaxShards: 10,
+	}
+	logger := zap.NewNop()
+	diagonBridge, err := diagon.NewDiagonBridge(&diagon.Config{
+		DataDir: cfg.DataDir,
+		Logger:  logger,
+	})
+	require.NoError(t, err)
+
+	sm := NewShardManager(cfg, logger, diagonBridge, nil)
+
+	ctx := context.Background()
+	require.NoError(t, sm.Start(ctx))
+	defer sm.Stop(ctx)
+
+	require.NoError(t, sm.CreateShard(ctx, "test-index", 0, true))
+	shard, err := sm.GetShard("test-index", 0)
+	require.NoError(t, err)
+	require.NoError(t, shard.IndexDocument(ctx, "doc-1", map[string]interface{}{"title": "kept"}))
+
+	// Writes that can't be logged are not applied
+	require.NoError(t, shard.translog.Close())
+	assert.Error(t, shard.IndexDocument(ctx, "doc-2", map[string]interface{}{"title": "lost"}))
+	_, err = shard.DeleteDocument(ctx, "doc-1")
+	assert.Error(t, err)
+
+	assert.Equal(t, int64(1), shard.DocsCount)
+	_, err = shard.DiagonShard.GetDocument("doc-2")
+	assert.Error(t, err)
+	doc, err := shard.DiagonShard.GetDocument("doc-1")
+	require.NoError(t, err)
+	assert.Equal(t, "kept", doc["title"])
+}
+
 func TestShard_Stats(t *testing.T) {
 	cfg := &config.DataNodeConfig{
 		NodeID:    "node-1",
@@ -506,7 +1420,7 @@ func TestShard_OperationsOnClosedShard(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "not ready")
 
-	err = shard.DeleteDocument(ctx, "doc-1")
+	_, err = shard.DeleteDocument(ctx, "doc-1")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "not ready")
 
@@ -0,0 +1,647 @@
+package data
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// TranslogDurability controls when translog writes are fsynced to disk
+type TranslogDurability string
+
+const (
+	// TranslogDurabilityRequest fsyncs every write before it is acknowledged
+	TranslogDurabilityRequest TranslogDurability = "request"
+	// TranslogDurabilityAsync fsyncs in the background every sync interval
+	TranslogDurabilityAsync TranslogDurability = "async"
+)
+
+const (
+	translogDirName        = "translog"
+	translogCheckpointName = "translog.ckp"
+	translogFilePrefix     = "translog-"
+	translogFileSuffix     = ".tlog"
+	translogHeaderSize     = 8 // uint32 payload length + uint32 CRC32 checksum
+
+	defaultTranslogSyncInterval = 5 * time.Second
+)
+
+// TranslogOpType is the type of operation recorded in the translog
+type TranslogOpType string
+
+const (
+	TranslogOpIndex  TranslogOpType = "index"
+	TranslogOpDelete TranslogOpType = "delete"
+)
+
+// TranslogOperation is a single write operation recorded in the translog.
+// Operations written before sequence numbers were introduced have none.
+type TranslogOperation struct {
+	Type        TranslogOpType         `json:"type"`
+	DocID       string                 `json:"id"`
+	Source      map[string]interface{} `json:"source,omitempty"`
+	SeqNo       int64                  `json:"seq_no,omitempty"`       // Position in the shard's history, from 1
+	PrimaryTerm int64                  `json:"primary_term,omitempty"` // Term of the primary that assigned the sequence number
+}
+
+// TranslogConfig holds translog configuration
+type TranslogConfig struct {
+	Durability   TranslogDurability
+	SyncInterval time.Duration
+}
+
+// translogCheckpoint is persisted next to the generation files and records
+// the generation currently being written, how many of its operations are
+// already part of a Diagon commit, and the sequence number bounds of the
+// shard's history, which outlive truncated generations
+type translogCheckpoint struct {
+	Generation      int64 `json:"generation"`
+	CommittedOps    int   `json:"committed_ops"`
+	MaxSeqNo        int64 `json:"max_seq_no,omitempty"`
+	LocalCheckpoint int64 `json:"local_checkpoint,omitempty"`
+	PrimaryTerm     int64 `json:"primary_term,omitempty"`
+	GenerationSeqNo int64 `json:"generation_seq_no,omitempty"`
+}
+
+// SeqNoStats are the sequence number bounds of a shard copy's history
+type SeqNoStats struct {
+	MaxSeqNo        int64 // Highest sequence number recorded
+	LocalCheckpoint int64 // Every operation up to this one was processed
+	PrimaryTerm     int64 // Highest primary term recorded
+}
+
+// Translog is an append-only, checksummed write-ahead log for a single shard.
+// Each record is framed as [length uint32][crc32 uint32][JSON payload].
+// Commits only advance the checkpoint; the log is truncated by rolling to a
+// new generation when the shard is flushed.
+type Translog struct {
+	dir          string
+	durability   TranslogDurability
+	syncInterval time.Duration
+	logger       *zap.Logger
+	mu           sync.Mutex
+	generation   int64
+	file         *os.File
+	operations   int  // operations in the current generation
+	committedOps int  // operations in the current generation already committed to Diagon
+	seqNoStats   SeqNoStats
+	genSeqNo     int64 // every operation above this sequence number is in the current generation
+	dirty        bool  // unsynced writes pending (async durability)
+	closed       bool
+	stopSync     chan struct{}
+	syncDone     chan struct{}
+}
+
+// OpenTranslog opens (or creates) the translog stored in dir
+func OpenTranslog(dir string, cfg *TranslogConfig, logger *zap.Logger) (*Translog, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create translog directory: %w", err)
+	}
+
+	durability := TranslogDurabilityRequest
+	syncInterval := defaultTranslogSyncInterval
+	if cfg != nil {
+		if cfg.Durability != "" {
+			durability = cfg.Durability
+		}
+		if cfg.SyncInterval > 0 {
+			syncInterval = cfg.SyncInterval
+		}
+	}
+	if durability != TranslogDurabilityRequest && durability != TranslogDurabilityAsync {
+		return nil, fmt.Errorf("invalid translog durability: %s", durability)
+	}
+
+	ckp, err := readTranslogCheckpoint(dir)
+	if err != nil {
+		return nil, err
+	}
+	generation := ckp.Generation
+
+	t := &Translog{
+		dir:          dir,
+		durability:   durability,
+		syncInterval: syncInterval,
+		logger:       logger,
+		generation:   generation,
+		committedOps: ckp.CommittedOps,
+		genSeqNo:     ckp.GenerationSeqNo,
+		seqNoStats: SeqNoStats{
+			MaxSeqNo:        ckp.MaxSeqNo,
+			LocalCheckpoint: ckp.LocalCheckpoint,
+			PrimaryTerm:     ckp.PrimaryTerm,
+		},
+	}
+
+	// Count operations already in the current generation
+	ops, validSize, err := t.readGeneration(generation, true)
+	if err != nil {
+		return nil, err
+	}
+	t.operations = len(ops)
+	if t.committedOps > t.operations {
+		t.committedOps = t.operations
+	}
+	for _, op := range ops {
+		t.recordSeqNo(op)
+	}
+
+	file, err := os.OpenFile(t.generationPath(generation), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open translog generation %d: %w", generation, err)
+	}
+	t.file = file
+
+	// Drop a torn tail so new records are appended after the last valid one
+	if info, err := file.Stat(); err == nil && info.Size() > validSize {
+		if err := file.Truncate(validSize); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to truncate torn translog tail: %w", err)
+		}
+	}
+
+	if err := t.writeCheckpoint(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// Clean up generations left behind by a crash during truncation
+	t.removeOldGenerations()
+
+	if durability == TranslogDurabilityAsync {
+		t.stopSync = make(chan struct{})
+		t.syncDone = make(chan struct{})
+		go t.syncLoop()
+	}
+
+	logger.Debug("Opened translog",
+		zap.String("dir", dir),
+		zap.Int64("generation", generation),
+		zap.String("durability", string(durability)))
+
+	return t, nil
+}
+
+// Add appends an operation to the translog. With request durability the
+// operation is fsynced before Add returns.
+func (t *Translog) Add(op *TranslogOperation) error {
+	payload, err := json.Marshal(op)
+	if err != nil {
+		return fmt.Errorf("failed to marshal translog operation: %w", err)
+	}
+
+	record := make([]byte, translogHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	copy(record[translogHeaderSize:], payload)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return fmt.Errorf("translog is closed")
+	}
+
+	if _, err := t.file.Write(record); err != nil {
+		return fmt.Errorf("failed to write translog operation: %w", err)
+	}
+	t.operations++
+	t.recordSeqNo(op)
+
+	if t.durability == TranslogDurabilityRequest {
+		if err := t.file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync translog: %w", err)
+		}
+		return nil
+	}
+
+	t.dirty = true
+	return nil
+}
+
+// recordSeqNo raises the sequence number bounds to cover op. Must be called
+// with t.mu held.
+func (t *Translog) recordSeqNo(op *TranslogOperation) {
+	if op.SeqNo > t.seqNoStats.MaxSeqNo {
+		t.seqNoStats.MaxSeqNo = op.SeqNo
+	}
+	if op.PrimaryTerm > t.seqNoStats.PrimaryTerm {
+		t.seqNoStats.PrimaryTerm = op.PrimaryTerm
+	}
+}
+
+// SetLocalCheckpoint records the shard's local checkpoint. It is persisted
+// with the next translog checkpoint, which only covers committed operations,
+// so a restarted shard never claims operations it lost.
+func (t *Translog) SetLocalCheckpoint(checkpoint int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if checkpoint > t.seqNoStats.LocalCheckpoint {
+		t.seqNoStats.LocalCheckpoint = checkpoint
+	}
+}
+
+// SetPrimaryTerm records a primary term the shard learned of before writing
+// any operation in it
+func (t *Translog) SetPrimaryTerm(term int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if term > t.seqNoStats.PrimaryTerm {
+		t.seqNoStats.PrimaryTerm = term
+	}
+}
+
+// SeqNoStats returns the sequence number bounds of the shard's history
+func (t *Translog) SeqNoStats() SeqNoStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.seqNoStats
+}
+
+// Sync fsyncs any unsynced operations
+func (t *Translog) Sync() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.syncLocked()
+}
+
+func (t *Translog) syncLocked() error {
+	if t.closed || !t.dirty {
+		return nil
+	}
+	if err := t.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync translog: %w", err)
+	}
+	t.dirty = false
+	return nil
+}
+
+// ReadOperations returns the operations that are not yet part of a Diagon
+// commit, oldest first. A torn record at the tail (from a crash mid-write)
+// is ignored.
+func (t *Translog) ReadOperations() ([]*TranslogOperation, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ops, _, err := t.readGeneration(t.generation, true)
+	if err != nil {
+		return nil, err
+	}
+	if t.committedOps >= len(ops) {
+		return []*TranslogOperation{}, nil
+	}
+	return ops[t.committedOps:], nil
+}
+
+// OperationsAfter returns the operations of the current generation with a
+// sequence number above seqNo, oldest first, and whether they are all the
+// operations after seqNo: older generations, and operations written without
+// sequence numbers, may hold the others.
+func (t *Translog) OperationsAfter(seqNo int64) ([]*TranslogOperation, bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ops, _, err := t.readGeneration(t.generation, true)
+	if err != nil {
+		return nil, false, err
+	}
+
+	complete := seqNo >= t.genSeqNo
+	after := make([]*TranslogOperation, 0, len(ops))
+	for _, op := range ops {
+		if op.SeqNo <= 0 {
+			complete = false
+			continue
+		}
+		if op.SeqNo > seqNo {
+			after = append(after, op)
+		}
+	}
+	return after, complete, nil
+}
+
+// StartHistory starts the sequence number history of a shard whose Diagon
+// files were copied from another copy and hold every operation up to
+// checkpoint. The translog must be empty.
+func (t *Translog) StartHistory(checkpoint, primaryTerm int64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return fmt.Errorf("translog is closed")
+	}
+	if t.operations > 0 {
+		return fmt.Errorf("translog has %d operations", t.operations)
+	}
+
+	t.seqNoStats = SeqNoStats{
+		MaxSeqNo:        checkpoint,
+		LocalCheckpoint: checkpoint,
+		PrimaryTerm:     primaryTerm,
+	}
+	t.genSeqNo = checkpoint
+	return t.writeCheckpoint()
+}
+
+// MarkCommitted records that every operation added so far is part of a
+// Diagon commit, so it is skipped on replay
+func (t *Translog) MarkCommitted() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return fmt.Errorf("translog is closed")
+	}
+	if t.committedOps == t.operations {
+		return nil
+	}
+
+	t.committedOps = t.operations
+	return t.writeCheckpoint()
+}
+
+// Truncate rolls the translog to a new generation and removes all older
+// generations. It must only be called once every operation in the translog
+// has been durably committed to Diagon.
+func (t *Translog) Truncate() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return fmt.Errorf("translog is closed")
+	}
+
+	nextGen := t.generation + 1
+	file, err := os.OpenFile(t.generationPath(nextGen), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create translog generation %d: %w", nextGen, err)
+	}
+
+	if err := t.file.Close(); err != nil {
+		t.logger.Warn("Failed to close translog generation",
+			zap.Int64("generation", t.generation),
+			zap.Error(err))
+	}
+
+	t.file = file
+	t.generation = nextGen
+	t.operations = 0
+	t.committedOps = 0
+	t.genSeqNo = t.seqNoStats.MaxSeqNo
+	t.dirty = false
+
+	// Persist the new generation before deleting the old ones so a crash in
+	// between never leaves the checkpoint pointing at a deleted file
+	if err := t.writeCheckpoint(); err != nil {
+		return err
+	}
+
+	t.removeOldGenerations()
+
+	t.logger.Debug("Truncated translog", zap.Int64("generation", t.generation))
+
+	return nil
+}
+
+// Generation returns the current translog generation
+func (t *Translog) Generation() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.generation
+}
+
+// Operations returns the number of operations in the current generation
+func (t *Translog) Operations() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.operations
+}
+
+// Close syncs and closes the translog
+func (t *Translog) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	syncErr := t.syncLocked()
+	t.closed = true
+	closeErr := t.file.Close()
+	t.mu.Unlock()
+
+	if t.stopSync != nil {
+		close(t.stopSync)
+		<-t.syncDone
+	}
+
+	if syncErr != nil {
+		return syncErr
+	}
+	if closeErr != nil {
+		return fmt.Errorf("failed to close translog: %w", closeErr)
+	}
+	return nil
+}
+
+// syncLoop periodically fsyncs the translog when durability is async
+func (t *Translog) syncLoop() {
+	defer close(t.syncDone)
+
+	ticker := time.NewTicker(t.syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := t.Sync(); err != nil {
+				t.logger.Error("Background translog sync failed", zap.Error(err))
+			}
+		case <-t.stopSync:
+			return
+		}
+	}
+}
+
+// removeOldGenerations deletes generation files older than the current one.
+// Their operations were committed to Diagon before the translog was rolled.
+func (t *Translog) removeOldGenerations() {
+	generations, err := t.listGenerations()
+	if err != nil {
+		t.logger.Warn("Failed to list translog generations", zap.Error(err))
+		return
+	}
+
+	for _, gen := range generations {
+		if gen >= t.generation {
+			continue
+		}
+		if err := os.Remove(t.generationPath(gen)); err != nil && !os.IsNotExist(err) {
+			t.logger.Warn("Failed to remove old translog generation",
+				zap.Int64("generation", gen),
+				zap.Error(err))
+		}
+	}
+}
+
+// readGeneration reads all operations from a generation file and returns
+// them with the size of the valid prefix of the file. When allowTornTail is
+// true a partial or corrupt final record is dropped.
+func (t *Translog) readGeneration(gen int64, allowTornTail bool) ([]*TranslogOperation, int64, error) {
+	data, err := os.ReadFile(t.generationPath(gen))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, nil
+		}
+		return nil, 0, fmt.Errorf("failed to read translog generation %d: %w", gen, err)
+	}
+
+	ops := make([]*TranslogOperation, 0)
+	offset := 0
+	for offset < len(data) {
+		op, n, err := decodeTranslogRecord(data[offset:])
+		if err != nil {
+			// Only the very last record may be torn
+			if allowTornTail && offset+n >= len(data) {
+				t.logger.Warn("Ignoring torn record at end of translog",
+					zap.Int64("generation", gen),
+					zap.Int("offset", offset),
+					zap.Error(err))
+				break
+			}
+			return nil, 0, fmt.Errorf("corrupt translog generation %d at offset %d: %w", gen, offset, err)
+		}
+		ops = append(ops, op)
+		offset += n
+	}
+
+	return ops, int64(offset), nil
+}
+
+// decodeTranslogRecord decodes a single record and returns the number of
+// bytes it occupies. On error the returned size covers the rest of the
+// buffer when the record is truncated.
+func decodeTranslogRecord(data []byte) (*TranslogOperation, int, error) {
+	if len(data) < translogHeaderSize {
+		return nil, len(data), io.ErrUnexpectedEOF
+	}
+
+	length := int(binary.LittleEndian.Uint32(data[0:4]))
+	checksum := binary.LittleEndian.Uint32(data[4:8])
+	size := translogHeaderSize + length
+
+	if size > len(data) {
+		return nil, len(data), io.ErrUnexpectedEOF
+	}
+
+	payload := data[translogHeaderSize:size]
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return nil, size, fmt.Errorf("checksum mismatch")
+	}
+
+	var op TranslogOperation
+	if err := json.Unmarshal(payload, &op); err != nil {
+		return nil, size, fmt.Errorf("failed to unmarshal operation: %w", err)
+	}
+
+	return &op, size, nil
+}
+
+// listGenerations returns the generations present on disk in ascending order
+func (t *Translog) listGenerations() ([]int64, error) {
+	entries, err := os.ReadDir(t.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read translog directory: %w", err)
+	}
+
+	generations := make([]int64, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, translogFilePrefix) || !strings.HasSuffix(name, translogFileSuffix) {
+			continue
+		}
+		gen, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(name, translogFilePrefix), translogFileSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		generations = append(generations, gen)
+	}
+
+	sort.Slice(generations, func(i, j int) bool { return generations[i] < generations[j] })
+	return generations, nil
+}
+
+func (t *Translog) generationPath(gen int64) string {
+	return filepath.Join(t.dir, fmt.Sprintf("%s%d%s", translogFilePrefix, gen, translogFileSuffix))
+}
+
+// writeCheckpoint atomically persists the current generation and sequence
+// number bounds
+func (t *Translog) writeCheckpoint() error {
+	data, err := json.Marshal(translogCheckpoint{
+		Generation:      t.generation,
+		CommittedOps:    t.committedOps,
+		MaxSeqNo:        t.seqNoStats.MaxSeqNo,
+		LocalCheckpoint: t.seqNoStats.LocalCheckpoint,
+		PrimaryTerm:     t.seqNoStats.PrimaryTerm,
+		GenerationSeqNo: t.genSeqNo,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal translog checkpoint: %w", err)
+	}
+
+	tmpPath := filepath.Join(t.dir, translogCheckpointName+".tmp")
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write translog checkpoint: %w", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write translog checkpoint: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync translog checkpoint: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write translog checkpoint: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, filepath.Join(t.dir, translogCheckpointName)); err != nil {
+		return fmt.Errorf("failed to install translog checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// readTranslogCheckpoint returns the persisted checkpoint, or generation 1
+// for a new translog
+func readTranslogCheckpoint(dir string) (*translogCheckpoint, error) {
+	data, err := os.ReadFile(filepath.Join(dir, translogCheckpointName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &translogCheckpoint{Generation: 1}, nil
+		}
+		return nil, fmt.Errorf("failed to read translog checkpoint: %w", err)
+	}
+
+	var ckp translogCheckpoint
+	if err := json.Unmarshal(data, &ckp); err != nil {
+		return nil, fmt.Errorf("failed to parse translog checkpoint: %w", err)
+	}
+	if ckp.Generation < 1 {
+		return nil, fmt.Errorf("invalid translog generation in checkpoint: %d", ckp.Generation)
+	}
+
+	return &ckp, nil
+}
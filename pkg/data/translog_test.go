@@ -0,0 +1,267 @@
+package data
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestTranslog_AddAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	logger := zap.NewNop()
+
+	tlog, err := OpenTranslog(dir, nil, logger)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), tlog.Generation())
+
+	require.NoError(t, tlog.Add(&TranslogOperation{Type: TranslogOpIndex, DocID: "1", Source: map[string]interface{}{"title": "first"}}))
+	require.NoError(t, tlog.Add(&TranslogOperation{Type: TranslogOpDelete, DocID: "2"}))
+	assert.Equal(t, 2, tlog.Operations())
+	require.NoError(t, tlog.Close())
+
+	// Reopen and read back
+	tlog, err = OpenTranslog(dir, nil, logger)
+	require.NoError(t, err)
+	defer tlog.Close()
+
+	ops, err := tlog.ReadOperations()
+	require.NoError(t, err)
+	require.Len(t, ops, 2)
+	assert.Equal(t, TranslogOpIndex, ops[0].Type)
+	assert.Equal(t, "1", ops[0].DocID)
+	assert.Equal(t, "first", ops[0].Source["title"])
+	assert.Equal(t, TranslogOpDelete, ops[1].Type)
+	assert.Equal(t, "2", ops[1].DocID)
+}
+
+func TestTranslog_MarkCommitted(t *testing.T) {
+	dir := t.TempDir()
+	logger := zap.NewNop()
+
+	tlog, err := OpenTranslog(dir, nil, logger)
+	require.NoError(t, err)
+
+	require.NoError(t, tlog.Add(&TranslogOperation{Type: TranslogOpIndex, DocID: "1"}))
+	require.NoError(t, tlog.MarkCommitted())
+	require.NoError(t, tlog.Add(&TranslogOperation{Type: TranslogOpIndex, DocID: "2"}))
+	require.NoError(t, tlog.Close())
+
+	tlog, err = OpenTranslog(dir, nil, logger)
+	require.NoError(t, err)
+	defer tlog.Close()
+
+	// Only the operation after the commit needs replay
+	ops, err := tlog.ReadOperations()
+	require.NoError(t, err)
+	require.Len(t, ops, 1)
+	assert.Equal(t, "2", ops[0].DocID)
+}
+
+func TestTranslog_Truncate(t *testing.T) {
+	dir := t.TempDir()
+	logger := zap.NewNop()
+
+	tlog, err := OpenTranslog(dir, nil, logger)
+	require.NoError(t, err)
+
+	require.NoError(t, tlog.Add(&TranslogOperation{Type: TranslogOpIndex, DocID: "1"}))
+	require.NoError(t, tlog.Truncate())
+	assert.Equal(t, int64(2), tlog.Generation())
+	assert.Equal(t, 0, tlog.Operations())
+
+	// Old generation is removed
+	_, err = os.Stat(filepath.Join(dir, "translog-1.tlog"))
+	assert.True(t, os.IsNotExist(err))
+
+	require.NoError(t, tlog.Close())
+
+	// Generation survives reopen and nothing is left to replay
+	tlog, err = OpenTranslog(dir, nil, logger)
+	require.NoError(t, err)
+	defer tlog.Close()
+
+	assert.Equal(t, int64(2), tlog.Generation())
+	ops, err := tlog.ReadOperations()
+	require.NoError(t, err)
+	assert.Empty(t, ops)
+}
+
+func TestTranslog_TornTail(t *testing.T) {
+	dir := t.TempDir()
+	logger := zap.NewNop()
+
+	tlog, err := OpenTranslog(dir, nil, logger)
+	require.NoError(t, err)
+	require.NoError(t, tlog.Add(&TranslogOperation{Type: TranslogOpIndex, DocID: "1"}))
+	require.NoError(t, tlog.Close())
+
+	// Simulate a crash in the middle of writing a record
+	f, err := os.OpenFile(filepath.Join(dir, "translog-1.tlog"), os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0x20, 0x00, 0x00, 0x00, 0x01})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	tlog, err = OpenTranslog(dir, nil, logger)
+	require.NoError(t, err)
+	defer tlog.Close()
+
+	// New writes land after the last valid record
+	require.NoError(t, tlog.Add(&TranslogOperation{Type: TranslogOpIndex, DocID: "2"}))
+
+	ops, err := tlog.ReadOperations()
+	require.NoError(t, err)
+	require.Len(t, ops, 2)
+	assert.Equal(t, "1", ops[0].DocID)
+	assert.Equal(t, "2", ops[1].DocID)
+}
+
+func TestTranslog_ChecksumMismatch(t *testing.T) {
+	dir := t.TempDir()
+	logger := zap.NewNop()
+
+	tlog, err := OpenTranslog(dir, nil, logger)
+	require.NoError(t, err)
+	require.NoError(t, tlog.Add(&TranslogOperation{Type: TranslogOpIndex, DocID: "1"}))
+	require.NoError(t, tlog.Add(&TranslogOperation{Type: TranslogOpIndex, DocID: "2"}))
+	require.NoError(t, tlog.Close())
+
+	// Corrupt the payload of the first record
+	path := filepath.Join(dir, "translog-1.tlog")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	data[translogHeaderSize+1] ^= 0xFF
+	require.NoError(t, os.WriteFile(path, data, 0644))
+
+	_, err = OpenTranslog(dir, nil, logger)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "corrupt translog")
+}
+
+func TestTranslog_AsyncDurability(t *testing.T) {
+	dir := t.TempDir()
+	logger := zap.NewNop()
+
+	tlog, err := OpenTranslog(dir, &TranslogConfig{
+		Durability:   TranslogDurabilityAsync,
+		SyncInterval: 10 * time.Millisecond,
+	}, logger)
+	require.NoError(t, err)
+
+	require.NoError(t, tlog.Add(&TranslogOperation{Type: TranslogOpIndex, DocID: "1"}))
+	require.NoError(t, tlog.Sync())
+	require.NoError(t, tlog.Close())
+
+	_, err = OpenTranslog(dir, &TranslogConfig{Durability: "sometimes"}, logger)
+	assert.Error(t, err)
+}
+
+func TestTranslog_SeqNoStats(t *testing.T) {
+	dir := t.TempDir()
+	logger := zap.NewNop()
+
+	tlog, err := OpenTranslog(dir, nil, logger)
+	require.NoError(t, err)
+
+	require.NoError(t, tlog.Add(&TranslogOperation{Type: TranslogOpIndex, DocID: "1", SeqNo: 1, PrimaryTerm: 1}))
+	require.NoError(t, tlog.Add(&TranslogOperation{Type: TranslogOpIndex, DocID: "2", SeqNo: 2, PrimaryTerm: 2}))
+	tlog.SetLocalCheckpoint(2)
+	assert.Equal(t, SeqNoStats{MaxSeqNo: 2, LocalCheckpoint: 2, PrimaryTerm: 2}, tlog.SeqNoStats())
+
+	// The bounds outlive the generations that held the operations
+	require.NoError(t, tlog.MarkCommitted())
+	require.NoError(t, tlog.Truncate())
+	require.NoError(t, tlog.Close())
+
+	tlog, err = OpenTranslog(dir, nil, logger)
+	require.NoError(t, err)
+	defer tlog.Close()
+	assert.Equal(t, SeqNoStats{MaxSeqNo: 2, LocalCheckpoint: 2, PrimaryTerm: 2}, tlog.SeqNoStats())
+}
+
+func TestTranslog_SeqNoStatsFromUncommittedOperations(t *testing.T) {
+	dir := t.TempDir()
+	logger := zap.NewNop()
+
+	tlog, err := OpenTranslog(dir, nil, logger)
+	require.NoError(t, err)
+	require.NoError(t, tlog.Add(&TranslogOperation{Type: TranslogOpIndex, DocID: "1", SeqNo: 1, PrimaryTerm: 3}))
+	tlog.SetLocalCheckpoint(1)
+	require.NoError(t, tlog.Close())
+
+	// The local checkpoint is only persisted with commits, the operations
+	// themselves are replayed
+	tlog, err = OpenTranslog(dir, nil, logger)
+	require.NoError(t, err)
+	defer tlog.Close()
+	assert.Equal(t, SeqNoStats{MaxSeqNo: 1, PrimaryTerm: 3}, tlog.SeqNoStats())
+}
+
+func TestTranslog_OperationsAfter(t *testing.T) {
+	dir := t.TempDir()
+	logger := zap.NewNop()
+
+	tlog, err := OpenTranslog(dir, nil, logger)
+	require.NoError(t, err)
+	defer tlog.Close()
+
+	for seqNo := int64(1); seqNo <= 3; seqNo++ {
+		require.NoError(t, tlog.Add(&TranslogOperation{Type: TranslogOpIndex, DocID: "1", SeqNo: seqNo, PrimaryTerm: 1}))
+	}
+
+	// Committed operations are still read, for peer recoveries
+	require.NoError(t, tlog.MarkCommitted())
+	ops, complete, err := tlog.OperationsAfter(1)
+	require.NoError(t, err)
+	assert.True(t, complete)
+	require.Len(t, ops, 2)
+	assert.Equal(t, int64(2), ops[0].SeqNo)
+	assert.Equal(t, int64(3), ops[1].SeqNo)
+
+	// Operations up to the truncation are in no generation anymore
+	require.NoError(t, tlog.Truncate())
+	require.NoError(t, tlog.Add(&TranslogOperation{Type: TranslogOpIndex, DocID: "2", SeqNo: 4, PrimaryTerm: 1}))
+
+	ops, complete, err = tlog.OperationsAfter(1)
+	require.NoError(t, err)
+	assert.False(t, complete)
+	require.Len(t, ops, 1)
+
+	ops, complete, err = tlog.OperationsAfter(3)
+	require.NoError(t, err)
+	assert.True(t, complete)
+	require.Len(t, ops, 1)
+	assert.Equal(t, "2", ops[0].DocID)
+}
+
+func TestTranslog_StartHistory(t *testing.T) {
+	dir := t.TempDir()
+	logger := zap.NewNop()
+
+	tlog, err := OpenTranslog(dir, nil, logger)
+	require.NoError(t, err)
+
+	// Recovered files hold every operation up to the checkpoint
+	require.NoError(t, tlog.StartHistory(7, 2))
+	assert.Equal(t, SeqNoStats{MaxSeqNo: 7, LocalCheckpoint: 7, PrimaryTerm: 2}, tlog.SeqNoStats())
+	_, complete, err := tlog.OperationsAfter(6)
+	require.NoError(t, err)
+	assert.False(t, complete)
+
+	require.NoError(t, tlog.Add(&TranslogOperation{Type: TranslogOpIndex, DocID: "1", SeqNo: 8, PrimaryTerm: 2}))
+	assert.Error(t, tlog.StartHistory(9, 2))
+	require.NoError(t, tlog.Close())
+
+	tlog, err = OpenTranslog(dir, nil, logger)
+	require.NoError(t, err)
+	defer tlog.Close()
+	ops, complete, err := tlog.OperationsAfter(7)
+	require.NoError(t, err)
+	assert.True(t, complete)
+	require.Len(t, ops, 1)
+}
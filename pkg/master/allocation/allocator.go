@@ -37,14 +37,16 @@ func (a *Allocator) AllocateShards(state *raft.ClusterState, indexName string, n
 		return nil, fmt.Errorf("no healthy data nodes available")
 	}
 
+	placement := newPlacement(state, dataNodes, indexName)
 	decisions := make([]AllocationDecision, 0)
 
 	// Allocate primary shards
 	for shardID := int32(0); shardID < numShards; shardID++ {
-		node := a.selectNodeForShard(dataNodes, state, indexName, shardID, true)
+		node := placement.selectNode(shardID)
 		if node == nil {
 			return nil, fmt.Errorf("failed to allocate primary shard %d", shardID)
 		}
+		placement.add(shardID, node.NodeID)
 
 		decisions = append(decisions, AllocationDecision{
 			IndexName: indexName,
@@ -61,19 +63,32 @@ func (a *Allocator) AllocateShards(state *raft.ClusterState, indexName string, n
 	}
 
 	// Allocate replica shards
+	decisions = append(decisions, a.allocateReplicas(placement, indexName, numShards, numReplicas)...)
+
+	return decisions, nil
+}
+
+// AllocateReplicas returns the allocations that bring each shard of an
+// existing index up to numReplicas replicas. Replicas that no node can take
+// are left unallocated.
+func (a *Allocator) AllocateReplicas(state *raft.ClusterState, indexName string, numShards, numReplicas int32) []AllocationDecision {
+	placement := newPlacement(state, a.getHealthyDataNodes(state), indexName)
+	return a.allocateReplicas(placement, indexName, numShards, numReplicas)
+}
+
+// allocateReplicas allocates the missing replicas round by round, so that
+// every shard gets its first replica before any gets its second
+func (a *Allocator) allocateReplicas(placement *placement, indexName string, numShards, numReplicas int32) []AllocationDecision {
+	decisions := make([]AllocationDecision, 0)
+
 	for replica := int32(0); replica < numReplicas; replica++ {
 		for shardID := int32(0); shardID < numShards; shardID++ {
-			// Find primary shard allocation
-			var primaryNode string
-			for _, decision := range decisions {
-				if decision.ShardID == shardID && decision.IsPrimary {
-					primaryNode = decision.NodeID
-					break
-				}
+			if placement.replicas[shardID] > replica {
+				continue
 			}
 
-			// Select different node for replica
-			node := a.selectNodeForReplica(dataNodes, state, indexName, shardID, primaryNode)
+			// Select a node without a copy of the shard
+			node := placement.selectNode(shardID)
 			if node == nil {
 				a.logger.Warn("Failed to allocate replica shard",
 					zap.String("index", indexName),
@@ -81,6 +96,8 @@ func (a *Allocator) AllocateShards(state *raft.ClusterState, indexName string, n
 					zap.Int32("replica", replica))
 				continue
 			}
+			placement.add(shardID, node.NodeID)
+			placement.replicas[shardID]++
 
 			decisions = append(decisions, AllocationDecision{
 				IndexName: indexName,
@@ -98,7 +115,97 @@ func (a *Allocator) AllocateShards(state *raft.ClusterState, indexName string, n
 		}
 	}
 
-	return decisions, nil
+	return decisions
+}
+
+// ExcessReplicas returns the replicas of an index beyond numReplicas per
+// shard. Replicas that have not started go first, then those on the nodes
+// holding the most shards.
+func (a *Allocator) ExcessReplicas(state *raft.ClusterState, indexName string, numReplicas int32) []*raft.ShardRouting {
+	shardCounts := make(map[string]int)
+	replicas := make(map[int32][]*raft.ShardRouting)
+	for _, shard := range state.ShardRouting {
+		shardCounts[shard.NodeID]++
+		if shard.IndexName == indexName && !shard.IsPrimary {
+			replicas[shard.ShardID] = append(replicas[shard.ShardID], shard)
+		}
+	}
+
+	excess := make([]*raft.ShardRouting, 0)
+	for _, shardReplicas := range replicas {
+		if int32(len(shardReplicas)) <= numReplicas {
+			continue
+		}
+
+		sort.Slice(shardReplicas, func(i, j int) bool {
+			a, b := shardReplicas[i], shardReplicas[j]
+			if (a.State == "started") != (b.State == "started") {
+				return b.State == "started"
+			}
+			if shardCounts[a.NodeID] != shardCounts[b.NodeID] {
+				return shardCounts[a.NodeID] > shardCounts[b.NodeID]
+			}
+			return a.NodeID < b.NodeID
+		})
+		excess = append(excess, shardReplicas[:int32(len(shardReplicas))-numReplicas]...)
+	}
+
+	sort.Slice(excess, func(i, j int) bool {
+		if excess[i].ShardID != excess[j].ShardID {
+			return excess[i].ShardID < excess[j].ShardID
+		}
+		return excess[i].NodeID < excess[j].NodeID
+	})
+	return excess
+}
+
+// SelectPromotion returns the replica to promote when a shard loses its
+// primary: a started, in-sync replica, preferring those on healthy nodes, or
+// nil if the shard has none on a node that is not offline. A replica out of
+// the in-sync set may miss acknowledged writes and is never promoted.
+func (a *Allocator) SelectPromotion(state *raft.ClusterState, indexName string, shardID int32) *raft.ShardRouting {
+	var selected *raft.ShardRouting
+	selectedHealthy := false
+	for _, shard := range state.ShardRouting {
+		if shard.IndexName != indexName || shard.ShardID != shardID || shard.IsPrimary ||
+			shard.State != "started" || !shard.InSync {
+			continue
+		}
+		node, exists := state.Nodes[shard.NodeID]
+		if !exists || node.Status == "offline" {
+			continue
+		}
+
+		healthy := node.Status == "healthy"
+		if selected == nil ||
+			(healthy && !selectedHealthy) ||
+			(healthy == selectedHealthy && shard.NodeID < selected.NodeID) {
+			selected = shard
+			selectedHealthy = healthy
+		}
+	}
+	return selected
+}
+
+// AllocatePrimary returns the allocation of a new, empty primary for a shard
+// that lost every copy, on the healthy data node with the fewest shards that
+// holds no copy of it. It returns false if there is no such node. The
+// documents of the lost copies are gone, so this is only for an operator
+// who chose to accept that, never for automatic recovery.
+func (a *Allocator) AllocatePrimary(state *raft.ClusterState, indexName string, shardID int32) (AllocationDecision, bool) {
+	placement := newPlacement(state, a.getHealthyDataNodes(state), indexName)
+	node := placement.selectNode(shardID)
+	if node == nil {
+		return AllocationDecision{}, false
+	}
+
+	return AllocationDecision{
+		IndexName: indexName,
+		ShardID:   shardID,
+		IsPrimary: true,
+		NodeID:    node.NodeID,
+		Reason:    "primary_reallocation",
+	}, true
 }
 
 // RebalanceShards rebalances shards across nodes
@@ -191,63 +298,65 @@ func (a *Allocator) getHealthyDataNodes(state *raft.ClusterState) []*raft.NodeMe
 	return nodes
 }
 
-func (a *Allocator) selectNodeForShard(nodes []*raft.NodeMeta, state *raft.ClusterState, indexName string, shardID int32, isPrimary bool) *raft.NodeMeta {
-	// Count shards per node
-	shardCounts := make(map[string]int)
-	for _, node := range nodes {
-		shardCounts[node.NodeID] = 0
+// placement tracks, while allocating the shards of an index, the shards on
+// each node and the nodes holding a copy of each shard
+type placement struct {
+	nodes       []*raft.NodeMeta
+	shardCounts map[string]int
+	copies      map[int32]map[string]bool // shard -> nodes with a copy
+	replicas    map[int32]int32           // shard -> replicas
+}
+
+func newPlacement(state *raft.ClusterState, nodes []*raft.NodeMeta, indexName string) *placement {
+	p := &placement{
+		nodes:       nodes,
+		shardCounts: make(map[string]int),
+		copies:      make(map[int32]map[string]bool),
+		replicas:    make(map[int32]int32),
 	}
 
 	for _, shard := range state.ShardRouting {
-		if count, exists := shardCounts[shard.NodeID]; exists {
-			shardCounts[shard.NodeID] = count + 1
+		p.shardCounts[shard.NodeID]++
+		if shard.IndexName != indexName {
+			continue
+		}
+		p.addCopy(shard.ShardID, shard.NodeID)
+		if !shard.IsPrimary {
+			p.replicas[shard.ShardID]++
 		}
 	}
 
-	// Sort nodes by shard count (ascending)
-	sort.Slice(nodes, func(i, j int) bool {
-		return shardCounts[nodes[i].NodeID] < shardCounts[nodes[j].NodeID]
-	})
-
-	// Return node with fewest shards
-	if len(nodes) > 0 {
-		return nodes[0]
-	}
-
-	return nil
+	return p
 }
 
-func (a *Allocator) selectNodeForReplica(nodes []*raft.NodeMeta, state *raft.ClusterState, indexName string, shardID int32, primaryNode string) *raft.NodeMeta {
-	// Count shards per node
-	shardCounts := make(map[string]int)
-	for _, node := range nodes {
-		shardCounts[node.NodeID] = 0
-	}
-
-	for _, shard := range state.ShardRouting {
-		if count, exists := shardCounts[shard.NodeID]; exists {
-			shardCounts[shard.NodeID] = count + 1
+// selectNode returns the node with the fewest shards that holds no copy of
+// the shard, or nil if every node holds one
+func (p *placement) selectNode(shardID int32) *raft.NodeMeta {
+	var selected *raft.NodeMeta
+	for _, node := range p.nodes {
+		if p.copies[shardID][node.NodeID] {
+			continue
 		}
-	}
-
-	// Filter out primary node
-	candidateNodes := make([]*raft.NodeMeta, 0)
-	for _, node := range nodes {
-		if node.NodeID != primaryNode {
-			candidateNodes = append(candidateNodes, node)
+		if selected == nil ||
+			p.shardCounts[node.NodeID] < p.shardCounts[selected.NodeID] ||
+			(p.shardCounts[node.NodeID] == p.shardCounts[selected.NodeID] && node.NodeID < selected.NodeID) {
+			selected = node
 		}
 	}
+	return selected
+}
 
-	if len(candidateNodes) == 0 {
-		return nil
-	}
-
-	// Sort candidates by shard count
-	sort.Slice(candidateNodes, func(i, j int) bool {
-		return shardCounts[candidateNodes[i].NodeID] < shardCounts[candidateNodes[j].NodeID]
-	})
+// add records a shard allocated to a node
+func (p *placement) add(shardID int32, nodeID string) {
+	p.addCopy(shardID, nodeID)
+	p.shardCounts[nodeID]++
+}
 
-	return candidateNodes[0]
+func (p *placement) addCopy(shardID int32, nodeID string) {
+	if p.copies[shardID] == nil {
+		p.copies[shardID] = make(map[string]bool)
+	}
+	p.copies[shardID][nodeID] = true
 }
 
 func (a *Allocator) findOverloadedNode(shardCounts map[string]int, avgShards int) string {
@@ -0,0 +1,345 @@
+package master
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/conjugate/conjugate/pkg/common/config"
+	"github.com/conjugate/conjugate/pkg/master/allocation"
+	"github.com/conjugate/conjugate/pkg/master/raft"
+	"go.uber.org/zap"
+)
+
+// Node statuses
+const (
+	nodeStatusHealthy  = "healthy"
+	nodeStatusDegraded = "degraded"
+	nodeStatusOffline  = "offline"
+)
+
+// Failure detection defaults, for the MasterConfig settings left unset
+const (
+	defaultFailureCheckInterval     = 5 * time.Second
+	defaultNodeDegradedTimeout      = 30 * time.Second
+	defaultNodeOfflineTimeout       = 60 * time.Second
+	defaultDelayedAllocationTimeout = time.Minute
+)
+
+// failureDetectorConfig holds the failure detection timeouts
+type failureDetectorConfig struct {
+	checkInterval     time.Duration
+	degradedTimeout   time.Duration
+	offlineTimeout    time.Duration
+	delayedAllocation time.Duration
+}
+
+func newFailureDetectorConfig(cfg *config.MasterConfig) failureDetectorConfig {
+	c := failureDetectorConfig{
+		checkInterval:     cfg.FailureCheckInterval,
+		degradedTimeout:   cfg.NodeDegradedTimeout,
+		offlineTimeout:    cfg.NodeOfflineTimeout,
+		delayedAllocation: cfg.DelayedAllocationTimeout,
+	}
+	if c.checkInterval <= 0 {
+		c.checkInterval = defaultFailureCheckInterval
+	}
+	if c.degradedTimeout <= 0 {
+		c.degradedTimeout = defaultNodeDegradedTimeout
+	}
+	if c.offlineTimeout <= 0 {
+		c.offlineTimeout = defaultNodeOfflineTimeout
+	}
+	if c.offlineTimeout < c.degradedTimeout {
+		c.offlineTimeout = c.degradedTimeout
+	}
+	if c.delayedAllocation < 0 {
+		c.delayedAllocation = defaultDelayedAllocationTimeout
+	}
+	return c
+}
+
+// nodeStatus returns the status of a node last seen at lastSeen (unix
+// seconds)
+func (c failureDetectorConfig) nodeStatus(lastSeen int64, now time.Time) string {
+	silence := now.Sub(time.Unix(lastSeen, 0))
+	switch {
+	case silence >= c.offlineTimeout:
+		return nodeStatusOffline
+	case silence >= c.degradedTimeout:
+		return nodeStatusDegraded
+	default:
+		return nodeStatusHealthy
+	}
+}
+
+// FailureDetector runs on the leader and reacts to data nodes that stop
+// heartbeating. A node is marked degraded, then offline, as its heartbeats
+// stay away. When a node goes offline each primary it held fails over to an
+// in-sync replica, and the copies that cannot fail over become unassigned.
+// The node keeps its unassigned copies for the delayed allocation timeout,
+// so that they start again if it returns; after that replicas are
+// reallocated through the Allocator. A primary is never allocated empty: it
+// stays unassigned until a replica can take over or its node returns.
+type FailureDetector struct {
+	master    *MasterNode
+	cfg       failureDetectorConfig
+	allocator *allocation.Allocator
+	logger    *zap.Logger
+}
+
+// NewFailureDetector creates a failure detector for a master node
+func NewFailureDetector(master *MasterNode, cfg *config.MasterConfig, logger *zap.Logger) *FailureDetector {
+	return &FailureDetector{
+		master:    master,
+		cfg:       newFailureDetectorConfig(cfg),
+		allocator: allocation.NewAllocator(logger),
+		logger:    logger,
+	}
+}
+
+// Run checks the cluster every check interval until ctx is done. A newly
+// elected leader waits for the degraded timeout before its first check,
+// giving the data nodes time to find it.
+func (d *FailureDetector) Run(ctx context.Context) {
+	d.logger.Info("Starting failure detector",
+		zap.Duration("degraded_timeout", d.cfg.degradedTimeout),
+		zap.Duration("offline_timeout", d.cfg.offlineTimeout),
+		zap.Duration("delayed_allocation_timeout", d.cfg.delayedAllocation))
+
+	ticker := time.NewTicker(d.cfg.checkInterval)
+	defer ticker.Stop()
+
+	var leaderSince time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			d.logger.Info("Stopping failure detector")
+			return
+		case now := <-ticker.C:
+			if !d.master.IsLeader() {
+				leaderSince = time.Time{}
+				continue
+			}
+			if leaderSince.IsZero() {
+				leaderSince = now
+			}
+			if now.Sub(leaderSince) < d.cfg.degradedTimeout {
+				continue
+			}
+			d.Check(ctx, now)
+		}
+	}
+}
+
+// failureActions are the changes a check of the cluster state decided on
+type failureActions struct {
+	nodes      []*raft.NodeMeta     // nodes with their new status
+	promotions []*raft.ShardRouting // replicas to promote over a lost primary
+	unassigned []*raft.ShardRouting // copies lost with their node
+	expired    []*raft.ShardRouting // unassigned copies to reallocate
+}
+
+// plan decides what a check at now changes in the cluster state
+func (d *FailureDetector) plan(state *raft.ClusterState, now time.Time) failureActions {
+	var actions failureActions
+
+	nodeIDs := make([]string, 0, len(state.Nodes))
+	for nodeID := range state.Nodes {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Strings(nodeIDs)
+
+	// Take the new node statuses into account for the shards below
+	for _, nodeID := range nodeIDs {
+		node := state.Nodes[nodeID]
+		status := d.cfg.nodeStatus(node.LastSeen, now)
+		if status == node.Status {
+			continue
+		}
+		updated := *node
+		updated.Status = status
+		state.Nodes[nodeID] = &updated
+		actions.nodes = append(actions.nodes, &updated)
+	}
+
+	keys := make([]string, 0, len(state.ShardRouting))
+	for key := range state.ShardRouting {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		shard := state.ShardRouting[key]
+		if shard.State == "unassigned" {
+			if now.Sub(time.Unix(shard.UnassignedAt, 0)) < d.cfg.delayedAllocation {
+				continue
+			}
+			// Without a replica to promote the documents only exist on the
+			// lost node, so the primary waits for it
+			if shard.IsPrimary && d.allocator.SelectPromotion(state, shard.IndexName, shard.ShardID) == nil {
+				continue
+			}
+			actions.expired = append(actions.expired, shard)
+			continue
+		}
+
+		if node, exists := state.Nodes[shard.NodeID]; exists && node.Status != nodeStatusOffline {
+			continue
+		}
+		if shard.IsPrimary {
+			if replica := d.allocator.SelectPromotion(state, shard.IndexName, shard.ShardID); replica != nil {
+				actions.promotions = append(actions.promotions, replica)
+				continue
+			}
+		}
+		actions.unassigned = append(actions.unassigned, shard)
+	}
+
+	return actions
+}
+
+// Check applies the changes a check of the cluster state at now decides on
+func (d *FailureDetector) Check(ctx context.Context, now time.Time) {
+	actions := d.plan(d.master.fsm.GetState(), now)
+
+	for _, node := range actions.nodes {
+		if err := d.apply(raft.CommandUpdateNode, node); err != nil {
+			d.logger.Error("Failed to update node status",
+				zap.String("node_id", node.NodeID),
+				zap.String("status", node.Status),
+				zap.Error(err))
+			continue
+		}
+		if node.Status == nodeStatusHealthy {
+			d.logger.Info("Node is healthy again", zap.String("node_id", node.NodeID))
+		} else {
+			d.logger.Warn("Node missed its heartbeats",
+				zap.String("node_id", node.NodeID),
+				zap.String("status", node.Status),
+				zap.Time("last_seen", time.Unix(node.LastSeen, 0)))
+		}
+	}
+
+	for _, replica := range actions.promotions {
+		d.promote(replica, now.Unix())
+	}
+
+	for _, shard := range actions.unassigned {
+		lost := *shard
+		lost.State = "unassigned"
+		lost.UnassignedAt = now.Unix()
+		lost.Version++
+		if err := d.apply(raft.CommandUpdateShard, &lost); err != nil {
+			d.logger.Error("Failed to unassign shard",
+				zap.String("index", lost.IndexName),
+				zap.Int32("shard_id", lost.ShardID),
+				zap.String("node", lost.NodeID),
+				zap.Error(err))
+			continue
+		}
+		d.logger.Warn("Shard copy lost with its node",
+			zap.String("index", lost.IndexName),
+			zap.Int32("shard_id", lost.ShardID),
+			zap.Bool("is_primary", lost.IsPrimary),
+			zap.String("node", lost.NodeID))
+	}
+
+	d.reallocate(ctx, actions.expired)
+}
+
+// promote makes a replica its shard's primary. The former primary becomes an
+// unassigned replica, lost at unassignedAt.
+func (d *FailureDetector) promote(replica *raft.ShardRouting, unassignedAt int64) {
+	req := struct {
+		IndexName    string `json:"index_name"`
+		ShardID      int32  `json:"shard_id"`
+		NodeID       string `json:"node_id"`
+		UnassignedAt int64  `json:"unassigned_at"`
+	}{
+		IndexName:    replica.IndexName,
+		ShardID:      replica.ShardID,
+		NodeID:       replica.NodeID,
+		UnassignedAt: unassignedAt,
+	}
+
+	if err := d.apply(raft.CommandPromoteReplica, req); err != nil {
+		d.logger.Error("Failed to promote replica",
+			zap.String("index", replica.IndexName),
+			zap.Int32("shard_id", replica.ShardID),
+			zap.String("node", replica.NodeID),
+			zap.Error(err))
+		return
+	}
+
+	d.logger.Warn("Promoted replica over a lost primary",
+		zap.String("index", replica.IndexName),
+		zap.Int32("shard_id", replica.ShardID),
+		zap.String("node", replica.NodeID))
+}
+
+// reallocate replaces the unassigned copies whose node did not return in
+// time. Replicas are dropped and allocated afresh per index. A primary fails
+// over to a replica that started since it was lost, or else stays
+// unassigned: allocating it empty would lose the documents on its node.
+func (d *FailureDetector) reallocate(ctx context.Context, expired []*raft.ShardRouting) {
+	var indices []string
+
+	for _, shard := range expired {
+		if !shard.IsPrimary {
+			req := struct {
+				IndexName     string `json:"index_name"`
+				ShardID       int32  `json:"shard_id"`
+				ReplicaNodeID string `json:"replica_node_id"`
+			}{
+				IndexName:     shard.IndexName,
+				ShardID:       shard.ShardID,
+				ReplicaNodeID: shard.NodeID,
+			}
+			if err := d.apply(raft.CommandDeallocateShard, req); err != nil {
+				d.logger.Error("Failed to deallocate unassigned replica",
+					zap.String("index", shard.IndexName),
+					zap.Int32("shard_id", shard.ShardID),
+					zap.String("node", shard.NodeID),
+					zap.Error(err))
+				continue
+			}
+			indices = append(indices, shard.IndexName)
+			continue
+		}
+
+		state := d.master.fsm.GetState()
+		if replica := d.allocator.SelectPromotion(state, shard.IndexName, shard.ShardID); replica != nil {
+			d.promote(replica, shard.UnassignedAt)
+			continue
+		}
+		d.logger.Warn("Unassigned primary has no replica to promote and waits for its node",
+			zap.String("index", shard.IndexName),
+			zap.Int32("shard_id", shard.ShardID),
+			zap.String("node", shard.NodeID))
+	}
+
+	sort.Strings(indices)
+	for i, indexName := range indices {
+		if i > 0 && indices[i-1] == indexName {
+			continue
+		}
+		state := d.master.fsm.GetState()
+		index, exists := state.Indices[indexName]
+		if !exists {
+			continue
+		}
+		for _, decision := range d.allocator.AllocateReplicas(state, indexName, index.NumShards, index.NumReplicas) {
+			d.master.allocateShard(ctx, decision)
+		}
+	}
+}
+
+func (d *FailureDetector) apply(cmdType raft.CommandType, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return d.master.raftNode.Apply(raft.Command{Type: cmdType, Payload: data}, 5*time.Second)
+}
@@ -0,0 +1,172 @@
+package master
+
+import (
+	"testing"
+	"time"
+
+	"github.com/conjugate/conjugate/pkg/common/config"
+	"github.com/conjugate/conjugate/pkg/master/allocation"
+	"github.com/conjugate/conjugate/pkg/master/raft"
+	"go.uber.org/zap"
+)
+
+func TestFailureDetectorConfigDefaults(t *testing.T) {
+	cfg := newFailureDetectorConfig(&config.MasterConfig{})
+	if cfg.checkInterval != defaultFailureCheckInterval ||
+		cfg.degradedTimeout != defaultNodeDegradedTimeout ||
+		cfg.offlineTimeout != defaultNodeOfflineTimeout {
+		t.Errorf("Expected the default timeouts, got %+v", cfg)
+	}
+
+	// The offline timeout is never shorter than the degraded one
+	cfg = newFailureDetectorConfig(&config.MasterConfig{
+		NodeDegradedTimeout: time.Minute,
+		NodeOfflineTimeout:  time.Second,
+	})
+	if cfg.offlineTimeout != time.Minute {
+		t.Errorf("Expected an offline timeout of 1m, got %s", cfg.offlineTimeout)
+	}
+}
+
+func TestFailureDetectorNodeStatus(t *testing.T) {
+	cfg := failureDetectorConfig{degradedTimeout: 30 * time.Second, offlineTimeout: time.Minute}
+	now := time.Unix(1000, 0)
+
+	tests := []struct {
+		lastSeen int64
+		expected string
+	}{
+		{1000, nodeStatusHealthy},
+		{971, nodeStatusHealthy},
+		{970, nodeStatusDegraded},
+		{941, nodeStatusDegraded},
+		{940, nodeStatusOffline},
+	}
+	for _, tt := range tests {
+		if status := cfg.nodeStatus(tt.lastSeen, now); status != tt.expected {
+			t.Errorf("Last seen at %d: expected %s, got %s", tt.lastSeen, tt.expected, status)
+		}
+	}
+}
+
+func testFailureDetector() *FailureDetector {
+	logger := zap.NewNop()
+	return &FailureDetector{
+		cfg: failureDetectorConfig{
+			degradedTimeout:   30 * time.Second,
+			offlineTimeout:    time.Minute,
+			delayedAllocation: time.Minute,
+		},
+		allocator: allocation.NewAllocator(logger),
+		logger:    logger,
+	}
+}
+
+func testRoutingState(nodes []*raft.NodeMeta, shards []*raft.ShardRouting) *raft.ClusterState {
+	state := &raft.ClusterState{
+		Indices:      map[string]*raft.IndexMeta{"products": {Name: "products", NumShards: 2, NumReplicas: 1}},
+		Nodes:        make(map[string]*raft.NodeMeta),
+		ShardRouting: make(map[string]*raft.ShardRouting),
+	}
+	for _, node := range nodes {
+		state.Nodes[node.NodeID] = node
+	}
+	for _, shard := range shards {
+		state.ShardRouting[shard.Key()] = shard
+	}
+	return state
+}
+
+func TestFailureDetectorPlan(t *testing.T) {
+	d := testFailureDetector()
+	now := time.Unix(1000, 0)
+
+	state := testRoutingState(
+		[]*raft.NodeMeta{
+			{NodeID: "data-1", NodeType: "data", Status: nodeStatusDegraded, LastSeen: 900},
+			{NodeID: "data-2", NodeType: "data", Status: nodeStatusHealthy, LastSeen: 995},
+			{NodeID: "data-3", NodeType: "data", Status: nodeStatusHealthy, LastSeen: 960},
+		},
+		[]*raft.ShardRouting{
+			// data-1 goes offline: shard 0 fails over, shard 1 has no
+			// started replica to fail over to
+			{IndexName: "products", ShardID: 0, IsPrimary: true, NodeID: "data-1", State: "started"},
+			{IndexName: "products", ShardID: 0, NodeID: "data-2", State: "started", InSync: true},
+			{IndexName: "products", ShardID: 1, IsPrimary: true, NodeID: "data-1", State: "started"},
+			{IndexName: "products", ShardID: 1, NodeID: "data-3", State: "initializing"},
+		},
+	)
+
+	actions := d.plan(state, now)
+
+	if len(actions.nodes) != 2 ||
+		actions.nodes[0].NodeID != "data-1" || actions.nodes[0].Status != nodeStatusOffline ||
+		actions.nodes[1].NodeID != "data-3" || actions.nodes[1].Status != nodeStatusDegraded {
+		t.Errorf("Expected data-1 offline and data-3 degraded, got %v", actions.nodes)
+	}
+	if len(actions.promotions) != 1 || actions.promotions[0].ShardID != 0 || actions.promotions[0].NodeID != "data-2" {
+		t.Errorf("Expected shard 0 to fail over to data-2, got %v", actions.promotions)
+	}
+	if len(actions.unassigned) != 1 || actions.unassigned[0].ShardID != 1 || !actions.unassigned[0].IsPrimary {
+		t.Errorf("Expected primary 1 to be unassigned, got %v", actions.unassigned)
+	}
+	if len(actions.expired) != 0 {
+		t.Errorf("Expected nothing to reallocate, got %v", actions.expired)
+	}
+}
+
+func TestFailureDetectorPlanDelayedAllocation(t *testing.T) {
+	d := testFailureDetector()
+	now := time.Unix(1000, 0)
+
+	state := testRoutingState(
+		[]*raft.NodeMeta{
+			{NodeID: "data-1", NodeType: "data", Status: nodeStatusOffline, LastSeen: 800},
+			{NodeID: "data-2", NodeType: "data", Status: nodeStatusHealthy, LastSeen: 995},
+		},
+		[]*raft.ShardRouting{
+			{IndexName: "products", ShardID: 0, IsPrimary: true, NodeID: "data-2", State: "started"},
+			{IndexName: "products", ShardID: 0, NodeID: "data-1", State: "unassigned", UnassignedAt: 930},
+			{IndexName: "products", ShardID: 1, IsPrimary: true, NodeID: "data-1", State: "unassigned", UnassignedAt: 950},
+		},
+	)
+
+	// Only the copy lost over a minute ago is reallocated
+	actions := d.plan(state, now)
+	if len(actions.nodes) != 0 || len(actions.promotions) != 0 || len(actions.unassigned) != 0 {
+		t.Errorf("Expected no changes but reallocation, got %+v", actions)
+	}
+	if len(actions.expired) != 1 || actions.expired[0].ShardID != 0 || actions.expired[0].IsPrimary {
+		t.Errorf("Expected the replica of shard 0 to be reallocated, got %v", actions.expired)
+	}
+
+	// A primary with no replica to promote is never reallocated, as that
+	// would lose its documents
+	later := time.Unix(1100, 0)
+	state.Nodes["data-2"].LastSeen = 1095
+	actions = d.plan(state, later)
+	for _, shard := range actions.expired {
+		if shard.IsPrimary {
+			t.Errorf("Expected the primary of shard 1 to wait for its node, got %v", actions.expired)
+		}
+	}
+
+	// It fails over once a replica is in sync
+	replica := &raft.ShardRouting{IndexName: "products", ShardID: 1, NodeID: "data-2", State: "started", InSync: true}
+	state.ShardRouting[replica.Key()] = replica
+	actions = d.plan(state, later)
+	found := false
+	for _, shard := range actions.expired {
+		found = found || (shard.IsPrimary && shard.ShardID == 1)
+	}
+	if !found {
+		t.Errorf("Expected the primary of shard 1 to fail over, got %v", actions.expired)
+	}
+
+	// A node that returns is healthy again, and keeps its copies
+	state.Nodes["data-1"] = &raft.NodeMeta{NodeID: "data-1", NodeType: "data", Status: nodeStatusOffline, LastSeen: 999}
+	actions = d.plan(state, time.Unix(1000, 0))
+	if len(actions.nodes) != 1 || actions.nodes[0].Status != nodeStatusHealthy {
+		t.Errorf("Expected data-1 to be healthy again, got %v", actions.nodes)
+	}
+}
@@ -3,6 +3,10 @@ package master
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
 	"time"
 
 	pb "github.com/conjugate/conjugate/pkg/common/proto"
@@ -10,6 +14,7 @@ import (
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -92,8 +97,8 @@ func (s *MasterService) CreateIndex(ctx context.Context, req *pb.CreateIndexRequ
 		return nil, status.Errorf(codes.FailedPrecondition, "not the leader, redirect to %s", s.node.Leader())
 	}
 
-	// Use MasterNode.CreateIndex which includes shard allocation
-	if err := s.node.CreateIndex(ctx, req.IndexName, req.Settings.NumberOfShards, req.Settings.NumberOfReplicas); err != nil {
+	// Use MasterNode.CreateIndexWithMappings which includes shard allocation
+	if err := s.node.CreateIndexWithMappings(ctx, req.IndexName, req.Settings.NumberOfShards, req.Settings.NumberOfReplicas, req.Mappings); err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to create index: %v", err)
 	}
 
@@ -157,8 +162,25 @@ func (s *MasterService) UpdateIndexSettings(ctx context.Context, req *pb.UpdateI
 		return nil, status.Errorf(codes.FailedPrecondition, "not the leader, redirect to %s", s.node.Leader())
 	}
 
-	// TODO: Implement update index settings
-	return nil, status.Error(codes.Unimplemented, "UpdateIndexSettings not yet implemented")
+	// Validate request
+	if req.IndexName == "" {
+		return nil, status.Error(codes.InvalidArgument, "index name is required")
+	}
+
+	if err := s.node.UpdateIndexSettings(ctx, req.IndexName, req.Settings); err != nil {
+		switch {
+		case errors.Is(err, ErrIndexNotFound):
+			return nil, status.Errorf(codes.NotFound, "index not found: %s", req.IndexName)
+		case errors.Is(err, ErrInvalidSettings):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		default:
+			return nil, status.Errorf(codes.Internal, "failed to update index settings: %v", err)
+		}
+	}
+
+	return &pb.UpdateIndexSettingsResponse{
+		Acknowledged: true,
+	}, nil
 }
 
 // GetIndexMetadata returns metadata for an index
@@ -183,21 +205,8 @@ func (s *MasterService) GetIndexMetadata(ctx context.Context, req *pb.GetIndexMe
 		return nil, status.Errorf(codes.NotFound, "index not found: %s", req.IndexName)
 	}
 
-	// Convert to proto
-	metadata := &pb.IndexMetadata{
-		IndexName: indexMeta.Name,
-		IndexUuid: indexMeta.UUID,
-		Version:   indexMeta.Version,
-		Settings: &pb.IndexSettings{
-			NumberOfShards:   indexMeta.NumShards,
-			NumberOfReplicas: indexMeta.NumReplicas,
-		},
-		State:     s.convertIndexStateToProto(indexMeta.State),
-		CreatedAt: timestamppb.New(time.Unix(indexMeta.CreatedAt, 0)),
-	}
-
 	return &pb.IndexMetadataResponse{
-		Metadata: metadata,
+		Metadata: s.convertIndexToProto(indexMeta),
 	}, nil
 }
 
@@ -251,6 +260,93 @@ func (s *MasterService) RebalanceShards(ctx context.Context, req *pb.RebalanceSh
 	}, nil
 }
 
+// FailShard takes a replica that failed a replicated write out of the
+// in-sync set. A primary that was replaced gets Aborted.
+func (s *MasterService) FailShard(ctx context.Context, req *pb.FailShardRequest) (*pb.FailShardResponse, error) {
+	s.logger.Info("FailShard request",
+		zap.String("index", req.IndexName),
+		zap.Int32("shard", req.ShardId),
+		zap.String("node", req.NodeId),
+		zap.Int64("primary_term", req.PrimaryTerm))
+
+	// Check if not leader
+	if !s.node.IsLeader() {
+		return nil, status.Errorf(codes.FailedPrecondition, "not the leader, redirect to %s", s.node.Leader())
+	}
+
+	// Validate request
+	if req.IndexName == "" {
+		return nil, status.Error(codes.InvalidArgument, "index name is required")
+	}
+	if req.NodeId == "" {
+		return nil, status.Error(codes.InvalidArgument, "node_id is required")
+	}
+
+	if err := s.node.FailShard(ctx, req.IndexName, req.ShardId, req.NodeId, req.PrimaryTerm, req.Reason); err != nil {
+		switch {
+		case errors.Is(err, ErrShardNotFound):
+			return nil, status.Error(codes.NotFound, err.Error())
+		case errors.Is(err, ErrStalePrimaryTerm):
+			return nil, status.Error(codes.Aborted, err.Error())
+		default:
+			return nil, status.Errorf(codes.Internal, "failed to fail shard: %v", err)
+		}
+	}
+
+	return &pb.FailShardResponse{
+		Acknowledged: true,
+	}, nil
+}
+
+// ReportShardRecovery records a recovering replica's progress, and starts the
+// replica once it has recovered
+func (s *MasterService) ReportShardRecovery(ctx context.Context, req *pb.ReportShardRecoveryRequest) (*pb.ReportShardRecoveryResponse, error) {
+	s.logger.Debug("ReportShardRecovery request",
+		zap.String("index", req.IndexName),
+		zap.Int32("shard", req.ShardId),
+		zap.String("node", req.NodeId),
+		zap.String("stage", req.Stage))
+
+	// Check if not leader
+	if !s.node.IsLeader() {
+		return nil, status.Errorf(codes.FailedPrecondition, "not the leader, redirect to %s", s.node.Leader())
+	}
+
+	// Validate request
+	if req.IndexName == "" {
+		return nil, status.Error(codes.InvalidArgument, "index name is required")
+	}
+	if req.NodeId == "" {
+		return nil, status.Error(codes.InvalidArgument, "node_id is required")
+	}
+
+	recovery := raft.RecoveryState{
+		Stage:               req.Stage,
+		SourceNodeID:        req.SourceNodeId,
+		FilesTotal:          req.FilesTotal,
+		BytesTotal:          req.BytesTotal,
+		BytesRecovered:      req.BytesRecovered,
+		OperationsRecovered: req.OperationsRecovered,
+		Failure:             req.Failure,
+	}
+	if err := s.node.ReportShardRecovery(ctx, req.IndexName, req.ShardId, req.NodeId, recovery); err != nil {
+		switch {
+		case errors.Is(err, ErrShardNotFound):
+			return nil, status.Error(codes.NotFound, err.Error())
+		case errors.Is(err, ErrShardNotRecovering):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		case errors.Is(err, ErrUnknownRecoveryStage):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		default:
+			return nil, status.Errorf(codes.Internal, "failed to record shard recovery: %v", err)
+		}
+	}
+
+	return &pb.ReportShardRecoveryResponse{
+		Acknowledged: true,
+	}, nil
+}
+
 // RegisterNode registers a new node in the cluster
 func (s *MasterService) RegisterNode(ctx context.Context, req *pb.RegisterNodeRequest) (*pb.RegisterNodeResponse, error) {
 	s.logger.Info("RegisterNode request",
@@ -291,6 +387,13 @@ func (s *MasterService) RegisterNode(ctx context.Context, req *pb.RegisterNodeRe
 		return nil, status.Errorf(codes.Internal, "failed to register node: %v", err)
 	}
 
+	// Bring shards the node recovered from disk back to started
+	if err := s.node.ReconcileShardReports(req.NodeId, req.Shards); err != nil {
+		s.logger.Warn("Failed to reconcile shard reports",
+			zap.String("node_id", req.NodeId),
+			zap.Error(err))
+	}
+
 	// Get updated cluster version
 	state, _ := s.node.GetClusterState(ctx)
 
@@ -366,6 +469,12 @@ func (s *MasterService) NodeHeartbeat(ctx context.Context, req *pb.NodeHeartbeat
 		return nil, status.Errorf(codes.Internal, "failed to process heartbeat: %v", err)
 	}
 
+	if err := s.node.ReconcileShardReports(req.NodeId, req.Shards); err != nil {
+		s.logger.Warn("Failed to reconcile shard reports",
+			zap.String("node_id", req.NodeId),
+			zap.Error(err))
+	}
+
 	// Get current cluster version
 	state, _ := s.node.GetClusterState(ctx)
 
@@ -375,13 +484,43 @@ func (s *MasterService) NodeHeartbeat(ctx context.Context, req *pb.NodeHeartbeat
 	}, nil
 }
 
-// WatchClusterState streams cluster state changes
+// WatchClusterState streams the cluster state changes after from_version,
+// in version order. If they are no longer known, or the watcher falls
+// behind, the stream ends with OutOfRange: the client reloads the state
+// with GetClusterState and watches from its version.
 func (s *MasterService) WatchClusterState(req *pb.WatchClusterStateRequest, stream pb.MasterService_WatchClusterStateServer) error {
 	s.logger.Info("WatchClusterState request", zap.Int64("from_version", req.FromVersion))
 
-	// TODO: Implement cluster state watching
-	// This would involve subscribing to FSM updates and streaming changes
-	return status.Error(codes.Unimplemented, "WatchClusterState not yet implemented")
+	watcher, err := s.node.WatchClusterState(req.FromVersion)
+	if err != nil {
+		if errors.Is(err, raft.ErrVersionUnavailable) {
+			return status.Error(codes.OutOfRange, err.Error())
+		}
+		return status.Errorf(codes.Internal, "failed to watch cluster state: %v", err)
+	}
+	defer watcher.Close()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-watcher.Events():
+			if !ok {
+				if err := watcher.Err(); err != nil {
+					return status.Error(codes.OutOfRange, err.Error())
+				}
+				return nil
+			}
+
+			pbEvent, err := s.convertEventToProto(event)
+			if err != nil {
+				return status.Errorf(codes.Internal, "failed to convert event: %v", err)
+			}
+			if err := stream.Send(pbEvent); err != nil {
+				return err
+			}
+		}
+	}
 }
 
 // Helper functions for conversions
@@ -400,21 +539,38 @@ func (s *MasterService) calculateClusterStatus(state *raft.ClusterState) pb.Clus
 func (s *MasterService) convertIndicesToProto(indices map[string]*raft.IndexMeta) []*pb.IndexMetadata {
 	result := make([]*pb.IndexMetadata, 0, len(indices))
 	for _, idx := range indices {
-		result = append(result, &pb.IndexMetadata{
-			IndexName: idx.Name,
-			IndexUuid: idx.UUID,
-			Version:   idx.Version,
-			Settings: &pb.IndexSettings{
-				NumberOfShards:   idx.NumShards,
-				NumberOfReplicas: idx.NumReplicas,
-			},
-			State:     s.convertIndexStateToProto(idx.State),
-			CreatedAt: timestamppb.New(time.Unix(idx.CreatedAt, 0)),
-		})
+		result = append(result, s.convertIndexToProto(idx))
 	}
 	return result
 }
 
+func (s *MasterService) convertIndexToProto(idx *raft.IndexMeta) *pb.IndexMetadata {
+	return &pb.IndexMetadata{
+		IndexName: idx.Name,
+		IndexUuid: idx.UUID,
+		Version:   idx.Version,
+		Settings:  s.convertIndexSettingsToProto(idx),
+		Mappings:  idx.Mappings,
+		State:     s.convertIndexStateToProto(idx.State),
+		CreatedAt: timestamppb.New(time.Unix(idx.CreatedAt, 0)),
+	}
+}
+
+func (s *MasterService) convertIndexSettingsToProto(idx *raft.IndexMeta) *pb.IndexSettings {
+	settings := &pb.IndexSettings{
+		NumberOfShards:   idx.NumShards,
+		NumberOfReplicas: idx.NumReplicas,
+		RefreshInterval:  idx.Settings[settingRefreshInterval],
+		CommitInterval:   idx.Settings[settingCommitInterval],
+	}
+	if batchSize, err := strconv.Atoi(idx.Settings[settingCommitBatchSize]); err == nil {
+		settings.CommitBatchSize = int32(batchSize)
+	}
+	return settings
+}
+
+// convertRoutingTableToProto groups the routing by index and shard. Each
+// shard's replicas are listed on its routing, sorted by node.
 func (s *MasterService) convertRoutingTableToProto(routing map[string]*raft.ShardRouting) *pb.RoutingTable {
 	indices := make(map[string]*pb.IndexRoutingTable)
 
@@ -430,14 +586,31 @@ func (s *MasterService) convertRoutingTableToProto(routing map[string]*raft.Shar
 			}
 		}
 
-		// Add shard routing
-		indices[indexName].Shards[shard.ShardID] = &pb.ShardRouting{
-			ShardId:   shard.ShardID,
-			IsPrimary: shard.IsPrimary,
-			Allocation: &pb.ShardAllocation{
-				NodeId: shard.NodeID,
-				State:  pb.ShardAllocation_SHARD_STATE_STARTED, // Assume started for now
-			},
+		// Add shard routing, keeping replicas found before the primary
+		shards := indices[indexName].Shards
+		current, exists := shards[shard.ShardID]
+		if shard.IsPrimary {
+			primary := s.convertShardToProto(shard)
+			if exists {
+				primary.Replicas = current.Replicas
+			}
+			shards[shard.ShardID] = primary
+			continue
+		}
+		if !exists {
+			// Placeholder until the primary is seen; an unassigned primary
+			// has no allocation
+			current = &pb.ShardRouting{ShardId: shard.ShardID, IsPrimary: true}
+			shards[shard.ShardID] = current
+		}
+		current.Replicas = append(current.Replicas, s.convertShardToProto(shard).Allocation)
+	}
+
+	for _, table := range indices {
+		for _, shard := range table.Shards {
+			sort.Slice(shard.Replicas, func(i, j int) bool {
+				return shard.Replicas[i].NodeId < shard.Replicas[j].NodeId
+			})
 		}
 	}
 
@@ -447,23 +620,102 @@ func (s *MasterService) convertRoutingTableToProto(routing map[string]*raft.Shar
 	}
 }
 
+func (s *MasterService) convertShardToProto(shard *raft.ShardRouting) *pb.ShardRouting {
+	return &pb.ShardRouting{
+		ShardId:   shard.ShardID,
+		IsPrimary: shard.IsPrimary,
+		Allocation: &pb.ShardAllocation{
+			NodeId:      shard.NodeID,
+			State:       s.convertShardStateToProto(shard.State),
+			InSync:      shard.InSync,
+			PrimaryTerm: shard.PrimaryTerm,
+		},
+	}
+}
+
+func (s *MasterService) convertShardStateToProto(state string) pb.ShardAllocation_ShardState {
+	switch state {
+	case "initializing":
+		return pb.ShardAllocation_SHARD_STATE_INITIALIZING
+	case "started":
+		return pb.ShardAllocation_SHARD_STATE_STARTED
+	case "relocating":
+		return pb.ShardAllocation_SHARD_STATE_RELOCATING
+	case "unassigned":
+		return pb.ShardAllocation_SHARD_STATE_UNASSIGNED
+	default:
+		return pb.ShardAllocation_SHARD_STATE_UNKNOWN
+	}
+}
+
 func (s *MasterService) convertNodesToProto(nodes map[string]*raft.NodeMeta) []*pb.NodeInfo {
 	result := make([]*pb.NodeInfo, 0, len(nodes))
 	for _, node := range nodes {
-		result = append(result, &pb.NodeInfo{
-			NodeId:    node.NodeID,
-			NodeName:  node.NodeID,
-			NodeType:  s.convertNodeTypeToProto(node.NodeType),
-			BindAddr:  node.BindAddr,
-			GrpcPort:  node.GRPCPort,
-			Status:    s.convertNodeStatusToProto(node.Status),
-			JoinedAt:  timestamppb.New(time.Unix(node.JoinedAt, 0)),
-			LastSeen:  timestamppb.New(time.Unix(node.LastSeen, 0)),
-		})
+		result = append(result, s.convertNodeToProto(node))
 	}
 	return result
 }
 
+func (s *MasterService) convertNodeToProto(node *raft.NodeMeta) *pb.NodeInfo {
+	return &pb.NodeInfo{
+		NodeId:   node.NodeID,
+		NodeName: node.NodeID,
+		NodeType: s.convertNodeTypeToProto(node.NodeType),
+		BindAddr: node.BindAddr,
+		GrpcPort: node.GRPCPort,
+		Status:   s.convertNodeStatusToProto(node.Status),
+		JoinedAt: timestamppb.New(time.Unix(node.JoinedAt, 0)),
+		LastSeen: timestamppb.New(time.Unix(node.LastSeen, 0)),
+	}
+}
+
+var shardEventTypes = map[raft.EventType]pb.ClusterStateEvent_EventType{
+	raft.EventShardAllocated:   pb.ClusterStateEvent_EVENT_TYPE_SHARD_ALLOCATED,
+	raft.EventShardUpdated:     pb.ClusterStateEvent_EVENT_TYPE_SHARD_UPDATED,
+	raft.EventShardRelocated:   pb.ClusterStateEvent_EVENT_TYPE_SHARD_RELOCATED,
+	raft.EventShardDeallocated: pb.ClusterStateEvent_EVENT_TYPE_SHARD_DEALLOCATED,
+}
+
+// convertEventToProto converts a cluster event. The payload is the changed
+// IndexMetadata or NodeInfo, or for a shard an IndexRoutingTable holding
+// just that shard.
+func (s *MasterService) convertEventToProto(event raft.ClusterEvent) (*pb.ClusterStateEvent, error) {
+	var eventType pb.ClusterStateEvent_EventType
+	var payload proto.Message
+	switch event.Type {
+	case raft.EventIndexCreated:
+		eventType, payload = pb.ClusterStateEvent_EVENT_TYPE_INDEX_CREATED, s.convertIndexToProto(event.Index)
+	case raft.EventIndexUpdated:
+		eventType, payload = pb.ClusterStateEvent_EVENT_TYPE_INDEX_UPDATED, s.convertIndexToProto(event.Index)
+	case raft.EventIndexDeleted:
+		eventType, payload = pb.ClusterStateEvent_EVENT_TYPE_INDEX_DELETED, s.convertIndexToProto(event.Index)
+	case raft.EventNodeJoined:
+		eventType, payload = pb.ClusterStateEvent_EVENT_TYPE_NODE_JOINED, s.convertNodeToProto(event.Node)
+	case raft.EventNodeUpdated:
+		eventType, payload = pb.ClusterStateEvent_EVENT_TYPE_NODE_UPDATED, s.convertNodeToProto(event.Node)
+	case raft.EventNodeLeft:
+		eventType, payload = pb.ClusterStateEvent_EVENT_TYPE_NODE_LEFT, s.convertNodeToProto(event.Node)
+	case raft.EventShardAllocated, raft.EventShardUpdated, raft.EventShardRelocated, raft.EventShardDeallocated:
+		eventType = shardEventTypes[event.Type]
+		payload = &pb.IndexRoutingTable{
+			IndexName: event.Shard.IndexName,
+			Shards:    map[int32]*pb.ShardRouting{event.Shard.ShardID: s.convertShardToProto(event.Shard)},
+		}
+	default:
+		return nil, fmt.Errorf("unknown event type: %s", event.Type)
+	}
+
+	data, err := proto.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ClusterStateEvent{
+		Version: event.Version,
+		Type:    eventType,
+		Payload: data,
+	}, nil
+}
+
 func (s *MasterService) convertIndexStateToProto(state string) pb.IndexMetadata_IndexState {
 	switch state {
 	case "creating":
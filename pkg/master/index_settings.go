@@ -0,0 +1,254 @@
+package master
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/conjugate/conjugate/pkg/common/dates"
+	pb "github.com/conjugate/conjugate/pkg/common/proto"
+	"github.com/conjugate/conjugate/pkg/master/allocation"
+	"github.com/conjugate/conjugate/pkg/master/raft"
+	"go.uber.org/zap"
+)
+
+// Dynamic index settings, kept in IndexMeta.Settings and sent to the data
+// nodes as shard settings under the same names
+const (
+	settingRefreshInterval = "refresh_interval"
+	settingCommitInterval  = "commit_interval"
+	settingCommitBatchSize = "commit_batch_size"
+)
+
+var (
+	// ErrIndexNotFound is returned for an index the cluster state does not have
+	ErrIndexNotFound = errors.New("index not found")
+
+	// ErrInvalidSettings is returned for index settings that are malformed or
+	// cannot change on an existing index
+	ErrInvalidSettings = errors.New("invalid index settings")
+)
+
+// dynamicIndexSettings validates the settings an existing index is updated
+// to and returns its new Settings map. Unset string settings and a zero batch
+// size fall back to the data node defaults; number_of_shards is fixed at
+// creation and may only be 0 or the current value.
+func dynamicIndexSettings(current *raft.IndexMeta, settings *pb.IndexSettings) (map[string]string, error) {
+	if settings == nil {
+		return nil, fmt.Errorf("%w: settings are required", ErrInvalidSettings)
+	}
+	if settings.NumberOfShards != 0 && settings.NumberOfShards != current.NumShards {
+		return nil, fmt.Errorf("%w: number_of_shards cannot change from %d to %d",
+			ErrInvalidSettings, current.NumShards, settings.NumberOfShards)
+	}
+	if settings.NumberOfReplicas < 0 {
+		return nil, fmt.Errorf("%w: number_of_replicas must not be negative", ErrInvalidSettings)
+	}
+	if settings.Compression != nil {
+		return nil, fmt.Errorf("%w: compression cannot change on an existing index", ErrInvalidSettings)
+	}
+	if settings.Tiering != nil {
+		return nil, fmt.Errorf("%w: tiering cannot change on an existing index", ErrInvalidSettings)
+	}
+	if settings.CommitBatchSize < 0 {
+		return nil, fmt.Errorf("%w: %s must not be negative", ErrInvalidSettings, settingCommitBatchSize)
+	}
+
+	result := make(map[string]string)
+	for name, value := range map[string]string{
+		settingRefreshInterval: settings.RefreshInterval,
+		settingCommitInterval:  settings.CommitInterval,
+	} {
+		if value == "" {
+			continue
+		}
+		// refresh_interval -1 disables background refreshes
+		if name == settingRefreshInterval && value == "-1" {
+			result[name] = value
+			continue
+		}
+		if _, err := dates.ParseTimeValue(value); err != nil {
+			return nil, fmt.Errorf("%w: %s must be a positive time value, got %q", ErrInvalidSettings, name, value)
+		}
+		result[name] = value
+	}
+	if settings.CommitBatchSize > 0 {
+		result[settingCommitBatchSize] = strconv.Itoa(int(settings.CommitBatchSize))
+	}
+
+	return result, nil
+}
+
+// UpdateIndexSettings changes the dynamic settings of an index. The new
+// metadata is applied through Raft, then replicas are allocated or removed
+// to match number_of_replicas and the batch settings are pushed to the
+// started copies of the index's shards. Failures after the metadata is
+// applied are logged rather than returned.
+func (m *MasterNode) UpdateIndexSettings(ctx context.Context, indexName string, settings *pb.IndexSettings) error {
+	if !m.raftNode.IsLeader() {
+		return fmt.Errorf("not the leader, redirect to %s", m.raftNode.Leader())
+	}
+
+	current, exists := m.fsm.GetState().Indices[indexName]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrIndexNotFound, indexName)
+	}
+
+	dynamic, err := dynamicIndexSettings(current, settings)
+	if err != nil {
+		return err
+	}
+
+	index := *current
+	index.NumReplicas = settings.NumberOfReplicas
+	index.Settings = dynamic
+	index.Version++
+
+	payload, err := json.Marshal(&index)
+	if err != nil {
+		return fmt.Errorf("failed to marshal index: %w", err)
+	}
+
+	cmd := raft.Command{
+		Type:    raft.CommandUpdateIndex,
+		Payload: payload,
+	}
+
+	if err := m.raftNode.Apply(cmd, 5*time.Second); err != nil {
+		return fmt.Errorf("failed to apply update index command: %w", err)
+	}
+
+	m.logger.Info("Updated index settings",
+		zap.String("index", indexName),
+		zap.Int32("num_replicas", index.NumReplicas),
+		zap.Any("settings", index.Settings))
+
+	if index.NumReplicas != current.NumReplicas {
+		m.reconcileReplicas(ctx, &index)
+	}
+	m.pushShardSettings(ctx, &index)
+
+	return nil
+}
+
+// reconcileReplicas allocates the replicas an index is missing and removes
+// those beyond its number_of_replicas
+func (m *MasterNode) reconcileReplicas(ctx context.Context, index *raft.IndexMeta) {
+	state := m.fsm.GetState()
+	allocator := allocation.NewAllocator(m.logger)
+
+	for _, decision := range allocator.AllocateReplicas(state, index.Name, index.NumShards, index.NumReplicas) {
+		m.allocateShard(ctx, decision)
+	}
+
+	for _, replica := range allocator.ExcessReplicas(state, index.Name, index.NumReplicas) {
+		req := struct {
+			IndexName     string `json:"index_name"`
+			ShardID       int32  `json:"shard_id"`
+			ReplicaNodeID string `json:"replica_node_id"`
+		}{
+			IndexName:     replica.IndexName,
+			ShardID:       replica.ShardID,
+			ReplicaNodeID: replica.NodeID,
+		}
+
+		payload, err := json.Marshal(req)
+		if err != nil {
+			m.logger.Error("Failed to marshal replica deallocation", zap.Error(err))
+			continue
+		}
+
+		cmd := raft.Command{
+			Type:    raft.CommandDeallocateShard,
+			Payload: payload,
+		}
+
+		if err := m.raftNode.Apply(cmd, 5*time.Second); err != nil {
+			m.logger.Error("Failed to apply replica deallocation",
+				zap.String("index", replica.IndexName),
+				zap.Int32("shard_id", replica.ShardID),
+				zap.String("node", replica.NodeID),
+				zap.Error(err))
+			continue
+		}
+
+		m.logger.Info("Deallocated replica shard",
+			zap.String("index", replica.IndexName),
+			zap.Int32("shard_id", replica.ShardID),
+			zap.String("node", replica.NodeID))
+
+		go m.deleteShardOnDataNode(replica.NodeID, replica.IndexName, replica.ShardID)
+	}
+}
+
+// pushShardSettings sends an index's dynamic settings to the data nodes
+// holding its started shards. Initializing shards are created with the
+// settings already.
+func (m *MasterNode) pushShardSettings(ctx context.Context, index *raft.IndexMeta) {
+	state := m.fsm.GetState()
+	for _, shard := range state.ShardRouting {
+		if shard.IndexName != index.Name || shard.State != "started" {
+			continue
+		}
+
+		if err := m.updateShardSettingsOnDataNode(ctx, state, shard, index.Settings); err != nil {
+			m.logger.Warn("Failed to update shard settings",
+				zap.String("index", shard.IndexName),
+				zap.Int32("shard_id", shard.ShardID),
+				zap.String("node", shard.NodeID),
+				zap.Error(err))
+		}
+	}
+}
+
+func (m *MasterNode) updateShardSettingsOnDataNode(ctx context.Context, state *raft.ClusterState, shard *raft.ShardRouting, settings map[string]string) error {
+	client, conn, err := m.dialDataNode(state, shard.NodeID)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err = client.UpdateShardSettings(ctx, &pb.UpdateShardSettingsRequest{
+		IndexName: shard.IndexName,
+		ShardId:   shard.ShardID,
+		Settings:  settings,
+	})
+	return err
+}
+
+// deleteShardOnDataNode deletes a deallocated shard copy from a data node
+func (m *MasterNode) deleteShardOnDataNode(nodeID, indexName string, shardID int32) {
+	client, conn, err := m.dialDataNode(m.fsm.GetState(), nodeID)
+	if err != nil {
+		m.logger.Error("Failed to connect to data node", zap.Error(err))
+		return
+	}
+	defer conn.Close()
+
+	// Outlives the request that deallocated the shard
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if _, err := client.DeleteShard(ctx, &pb.DeleteShardRequest{
+		IndexName: indexName,
+		ShardId:   shardID,
+	}); err != nil {
+		m.logger.Error("Failed to delete shard on data node",
+			zap.String("node_id", nodeID),
+			zap.String("index", indexName),
+			zap.Int32("shard_id", shardID),
+			zap.Error(err))
+		return
+	}
+
+	m.logger.Info("Deleted shard on data node",
+		zap.String("node_id", nodeID),
+		zap.String("index", indexName),
+		zap.Int32("shard_id", shardID))
+}
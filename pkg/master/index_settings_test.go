@@ -0,0 +1,78 @@
+package master
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	pb "github.com/conjugate/conjugate/pkg/common/proto"
+	"github.com/conjugate/conjugate/pkg/master/raft"
+)
+
+func TestDynamicIndexSettings(t *testing.T) {
+	current := &raft.IndexMeta{Name: "products", NumShards: 3, NumReplicas: 1}
+
+	settings, err := dynamicIndexSettings(current, &pb.IndexSettings{
+		NumberOfShards:   3,
+		NumberOfReplicas: 2,
+		RefreshInterval:  "5s",
+		CommitBatchSize:  500,
+	})
+	if err != nil {
+		t.Fatalf("dynamicIndexSettings failed: %v", err)
+	}
+	expected := map[string]string{
+		"refresh_interval":  "5s",
+		"commit_batch_size": "500",
+	}
+	if !reflect.DeepEqual(settings, expected) {
+		t.Errorf("Expected %v, got %v", expected, settings)
+	}
+
+	// OpenSearch time values, and -1 to disable refreshes
+	settings, err = dynamicIndexSettings(current, &pb.IndexSettings{RefreshInterval: "-1", CommitInterval: "1d"})
+	if err != nil {
+		t.Fatalf("dynamicIndexSettings failed: %v", err)
+	}
+	expected = map[string]string{"refresh_interval": "-1", "commit_interval": "1d"}
+	if !reflect.DeepEqual(settings, expected) {
+		t.Errorf("Expected %v, got %v", expected, settings)
+	}
+
+	// Unset settings are dropped, falling back to the defaults
+	settings, err = dynamicIndexSettings(current, &pb.IndexSettings{})
+	if err != nil {
+		t.Fatalf("dynamicIndexSettings failed: %v", err)
+	}
+	if len(settings) != 0 {
+		t.Errorf("Expected no settings, got %v", settings)
+	}
+}
+
+func TestDynamicIndexSettingsRejectsInvalid(t *testing.T) {
+	current := &raft.IndexMeta{Name: "products", NumShards: 3, NumReplicas: 1}
+
+	tests := []struct {
+		name     string
+		settings *pb.IndexSettings
+	}{
+		{"missing", nil},
+		{"number_of_shards", &pb.IndexSettings{NumberOfShards: 5}},
+		{"negative replicas", &pb.IndexSettings{NumberOfReplicas: -1}},
+		{"compression", &pb.IndexSettings{Compression: &pb.CompressionSettings{}}},
+		{"tiering", &pb.IndexSettings{Tiering: &pb.TieringSettings{}}},
+		{"malformed refresh_interval", &pb.IndexSettings{RefreshInterval: "soon"}},
+		{"negative commit_interval", &pb.IndexSettings{CommitInterval: "-1s"}},
+		{"disabled commit_interval", &pb.IndexSettings{CommitInterval: "-1"}},
+		{"negative commit_batch_size", &pb.IndexSettings{CommitBatchSize: -10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := dynamicIndexSettings(current, tt.settings)
+			if !errors.Is(err, ErrInvalidSettings) {
+				t.Errorf("Expected ErrInvalidSettings, got %v", err)
+			}
+		})
+	}
+}
@@ -23,6 +23,9 @@ type MasterNode struct {
 	raftNode   *raft.RaftNode
 	grpcServer *grpc.Server
 	fsm        *raft.FSM
+
+	failureDetector     *FailureDetector
+	stopFailureDetector context.CancelFunc
 }
 
 // NewMasterNode creates a new master node
@@ -59,6 +62,7 @@ func NewMasterNode(cfg *config.MasterConfig, logger *zap.Logger) (*MasterNode, e
 		grpcServer: grpcServer,
 		fsm:        fsm,
 	}
+	node.failureDetector = NewFailureDetector(node, cfg, logger)
 
 	// Register gRPC service
 	masterService := NewMasterService(node, logger)
@@ -102,6 +106,11 @@ func (m *MasterNode) Start(ctx context.Context) error {
 		}
 	}()
 
+	// Watch the data nodes for failures while this node leads
+	detectorCtx, cancel := context.WithCancel(ctx)
+	m.stopFailureDetector = cancel
+	go m.failureDetector.Run(detectorCtx)
+
 	return nil
 }
 
@@ -109,6 +118,10 @@ func (m *MasterNode) Start(ctx context.Context) error {
 func (m *MasterNode) Stop(ctx context.Context) error {
 	m.logger.Info("Stopping master node")
 
+	if m.stopFailureDetector != nil {
+		m.stopFailureDetector()
+	}
+
 	// Stop gRPC server
 	m.grpcServer.GracefulStop()
 
@@ -145,6 +158,12 @@ func (m *MasterNode) initializeCluster() error {
 
 // CreateIndex creates a new index in the cluster
 func (m *MasterNode) CreateIndex(ctx context.Context, indexName string, numShards, numReplicas int32) error {
+	return m.CreateIndexWithMappings(ctx, indexName, numShards, numReplicas, nil)
+}
+
+// CreateIndexWithMappings creates a new index with field mappings, which are
+// sent to the data nodes with every shard of the index
+func (m *MasterNode) CreateIndexWithMappings(ctx context.Context, indexName string, numShards, numReplicas int32, mappings map[string]*pb.FieldMapping) error {
 	if !m.raftNode.IsLeader() {
 		return fmt.Errorf("not the leader, redirect to %s", m.raftNode.Leader())
 	}
@@ -157,6 +176,7 @@ func (m *MasterNode) CreateIndex(ctx context.Context, indexName string, numShard
 		NumShards:   numShards,
 		NumReplicas: numReplicas,
 		Settings:    make(map[string]string),
+		Mappings:    mappings,
 		State:       "open",
 		CreatedAt:   time.Now().Unix(),
 	}
@@ -266,46 +286,53 @@ func (m *MasterNode) allocateShards(ctx context.Context, indexName string, numSh
 
 	// Apply each shard allocation through Raft
 	for _, decision := range decisions {
-		shardRouting := raft.ShardRouting{
-			IndexName: decision.IndexName,
-			ShardID:   decision.ShardID,
-			IsPrimary: decision.IsPrimary,
-			NodeID:    decision.NodeID,
-			State:     "initializing",
-			Version:   1,
-		}
+		m.allocateShard(ctx, decision)
+	}
 
-		payload, err := json.Marshal(shardRouting)
-		if err != nil {
-			return fmt.Errorf("failed to marshal shard routing: %w", err)
-		}
+	return nil
+}
 
-		cmd := raft.Command{
-			Type:    raft.CommandAllocateShard,
-			Payload: payload,
-		}
+// allocateShard applies an allocation decision through Raft, then has the
+// data node create the shard. A failure is logged, leaving the shard for a
+// later allocation.
+func (m *MasterNode) allocateShard(ctx context.Context, decision allocation.AllocationDecision) {
+	shardRouting := raft.ShardRouting{
+		IndexName: decision.IndexName,
+		ShardID:   decision.ShardID,
+		IsPrimary: decision.IsPrimary,
+		NodeID:    decision.NodeID,
+		State:     "initializing",
+		Version:   1,
+	}
 
-		if err := m.raftNode.Apply(cmd, 5*time.Second); err != nil {
-			m.logger.Error("Failed to apply shard allocation",
-				zap.String("index", indexName),
-				zap.Int32("shard_id", decision.ShardID),
-				zap.String("node", decision.NodeID),
-				zap.Error(err))
-			// Continue with other shards even if one fails
-			continue
-		}
+	payload, err := json.Marshal(shardRouting)
+	if err != nil {
+		m.logger.Error("Failed to marshal shard routing", zap.Error(err))
+		return
+	}
 
-		m.logger.Info("Allocated shard",
-			zap.String("index", indexName),
-			zap.Int32("shard_id", decision.ShardID),
-			zap.Bool("is_primary", decision.IsPrimary),
-			zap.String("node", decision.NodeID))
+	cmd := raft.Command{
+		Type:    raft.CommandAllocateShard,
+		Payload: payload,
+	}
 
-		// After allocation in Raft, tell the data node to actually create the shard
-		go m.createShardOnDataNode(ctx, decision.NodeID, indexName, decision.ShardID)
+	if err := m.raftNode.Apply(cmd, 5*time.Second); err != nil {
+		m.logger.Error("Failed to apply shard allocation",
+			zap.String("index", decision.IndexName),
+			zap.Int32("shard_id", decision.ShardID),
+			zap.String("node", decision.NodeID),
+			zap.Error(err))
+		return
 	}
 
-	return nil
+	m.logger.Info("Allocated shard",
+		zap.String("index", decision.IndexName),
+		zap.Int32("shard_id", decision.ShardID),
+		zap.Bool("is_primary", decision.IsPrimary),
+		zap.String("node", decision.NodeID))
+
+	// After allocation in Raft, tell the data node to actually create the shard
+	go m.createShardOnDataNode(ctx, decision.NodeID, decision.IndexName, decision.ShardID, decision.IsPrimary)
 }
 
 // RegisterNode registers a new node in the cluster
@@ -343,40 +370,147 @@ func (m *MasterNode) RegisterNode(ctx context.Context, nodeID, nodeType, bindAdd
 	return nil
 }
 
+// ReconcileShardReports marks shards reported as started by a data node as
+// started in the routing table. This lets a restarted data node that recovered
+// its shards from disk rejoin without the shards being reassigned by hand.
+func (m *MasterNode) ReconcileShardReports(nodeID string, reports []*pb.ShardReport) error {
+	if len(reports) == 0 {
+		return nil
+	}
+
+	updates := startedShardUpdates(m.fsm.GetState(), nodeID, reports)
+	for _, update := range updates {
+		payload, err := json.Marshal(update)
+		if err != nil {
+			return fmt.Errorf("failed to marshal shard update: %w", err)
+		}
+
+		cmd := raft.Command{
+			Type:    raft.CommandUpdateShard,
+			Payload: payload,
+		}
+
+		if err := m.raftNode.Apply(cmd, 5*time.Second); err != nil {
+			return fmt.Errorf("failed to update shard %s:%d: %w", update.IndexName, update.ShardID, err)
+		}
+
+		m.logger.Info("Shard reported started by data node",
+			zap.String("node_id", nodeID),
+			zap.String("index", update.IndexName),
+			zap.Int32("shard_id", update.ShardID),
+			zap.String("state", update.State))
+	}
+
+	return nil
+}
+
+// startedShardUpdates returns the routing updates needed to move the shards a
+// node reports as started into the started state. Only shards the routing
+// table assigns to that node are considered. A replica may have missed
+// writes while it was away, so it only moves to initializing: it starts once
+// it has recovered from its primary.
+func startedShardUpdates(state *raft.ClusterState, nodeID string, reports []*pb.ShardReport) []raft.ShardRouting {
+	updates := make([]raft.ShardRouting, 0)
+
+	for _, report := range reports {
+		if report.State != pb.ShardAllocation_SHARD_STATE_STARTED {
+			continue
+		}
+
+		current, exists := state.FindShard(report.IndexName, report.ShardId, nodeID)
+		if !exists || current.State == "started" {
+			continue
+		}
+
+		target := "started"
+		if !current.IsPrimary {
+			if current.State == "initializing" {
+				continue
+			}
+			target = "initializing"
+		}
+
+		// Preserve IsPrimary from the routing table, it is the source of truth
+		updates = append(updates, raft.ShardRouting{
+			IndexName: current.IndexName,
+			ShardID:   current.ShardID,
+			IsPrimary: current.IsPrimary,
+			NodeID:    nodeID,
+			State:     target,
+			Version:   current.Version + 1,
+		})
+	}
+
+	return updates
+}
+
 // GetClusterState returns the current cluster state
 func (m *MasterNode) GetClusterState(ctx context.Context) (*raft.ClusterState, error) {
 	return m.fsm.GetState(), nil
 }
 
-// createShardOnDataNode creates a shard on the specified data node
-func (m *MasterNode) createShardOnDataNode(ctx context.Context, nodeID, indexName string, shardID int32) {
-	// Get node information from cluster state
-	state := m.fsm.GetState()
+// WatchClusterState returns a watcher of the cluster state changes after
+// fromVersion. Any master can serve it, as every master applies the log.
+func (m *MasterNode) WatchClusterState(fromVersion int64) (*raft.Watcher, error) {
+	return m.fsm.Watch(fromVersion)
+}
+
+// createShardMappingsSetting is the CreateShard setting the index's field
+// mappings are sent in, as JSON
+const createShardMappingsSetting = "mappings"
+
+// dialDataNode connects to a data node of the cluster state. The caller
+// closes the connection.
+func (m *MasterNode) dialDataNode(state *raft.ClusterState, nodeID string) (pb.DataServiceClient, *grpc.ClientConn, error) {
 	node, exists := state.Nodes[nodeID]
 	if !exists {
-		m.logger.Error("Node not found in cluster state",
-			zap.String("node_id", nodeID))
-		return
+		return nil, nil, fmt.Errorf("node %s not found in cluster state", nodeID)
 	}
 
-	// Connect to data node
 	addr := fmt.Sprintf("%s:%d", node.BindAddr, node.GRPCPort)
 	conn, err := grpc.Dial(addr, grpc.WithInsecure())
 	if err != nil {
-		m.logger.Error("Failed to connect to data node",
-			zap.String("node_id", nodeID),
-			zap.String("address", addr),
-			zap.Error(err))
+		return nil, nil, fmt.Errorf("failed to connect to data node %s at %s: %w", nodeID, addr, err)
+	}
+
+	return pb.NewDataServiceClient(conn), conn, nil
+}
+
+// createShardOnDataNode creates a shard on the specified data node
+func (m *MasterNode) createShardOnDataNode(ctx context.Context, nodeID, indexName string, shardID int32, isPrimary bool) {
+	state := m.fsm.GetState()
+	client, conn, err := m.dialDataNode(state, nodeID)
+	if err != nil {
+		m.logger.Error("Failed to connect to data node", zap.Error(err))
 		return
 	}
 	defer conn.Close()
 
-	client := pb.NewDataServiceClient(conn)
-
-	// Create shard on data node
+	// Create shard on data node, with the index's mappings and dynamic
+	// settings, in the term of the shard's primary
 	req := &pb.CreateShardRequest{
 		IndexName: indexName,
 		ShardId:   shardID,
+		IsPrimary: isPrimary,
+		Settings:  make(map[string]string),
+	}
+	if primary, ok := state.ShardRouting[raft.ShardKey(indexName, shardID)]; ok {
+		req.PrimaryTerm = primary.PrimaryTerm
+	}
+	if index, ok := state.Indices[indexName]; ok {
+		for name, value := range index.Settings {
+			req.Settings[name] = value
+		}
+		if len(index.Mappings) > 0 {
+			encoded, err := json.Marshal(index.Mappings)
+			if err != nil {
+				m.logger.Error("Failed to encode index mappings",
+					zap.String("index", indexName),
+					zap.Error(err))
+				return
+			}
+			req.Settings[createShardMappingsSetting] = string(encoded)
+		}
 	}
 
 	m.logger.Info("Creating shard on data node",
@@ -405,6 +539,16 @@ func (m *MasterNode) createShardOnDataNode(ctx context.Context, nodeID, indexNam
 			zap.Int32("shard_id", shardID),
 			zap.String("shard_key", resp.ShardKey))
 
+		// A replica starts once it has recovered from its primary, which
+		// the data node reports through ReportShardRecovery
+		if !isPrimary {
+			m.logger.Info("Replica shard recovering from its primary",
+				zap.String("index", indexName),
+				zap.Int32("shard_id", shardID),
+				zap.String("node_id", nodeID))
+			return
+		}
+
 		// CRITICAL FIX: Update shard state to STARTED so executor can query it
 		m.logger.Info("Updating shard state to STARTED",
 			zap.String("index", indexName),
@@ -413,8 +557,7 @@ func (m *MasterNode) createShardOnDataNode(ctx context.Context, nodeID, indexNam
 
 		// Get current shard routing to preserve IsPrimary field
 		state := m.fsm.GetState()
-		key := fmt.Sprintf("%s:%d", indexName, shardID)
-		currentShard, exists := state.ShardRouting[key]
+		currentShard, exists := state.FindShard(indexName, shardID, nodeID)
 		if !exists {
 			m.logger.Error("Shard not found in routing table during state update",
 				zap.String("index", indexName),
@@ -8,7 +8,10 @@ import (
 	"time"
 
 	"github.com/conjugate/conjugate/pkg/common/config"
+	pb "github.com/conjugate/conjugate/pkg/common/proto"
+	"github.com/conjugate/conjugate/pkg/master/raft"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestNewMasterNode(t *testing.T) {
@@ -473,6 +476,84 @@ func TestMasterNodeRegisterNodeAsLeader(t *testing.T) {
 	}
 }
 
+func TestStartedShardUpdates(t *testing.T) {
+	state := &raft.ClusterState{
+		ShardRouting: map[string]*raft.ShardRouting{
+			"products:0": {IndexName: "products", ShardID: 0, IsPrimary: true, NodeID: "data-1", State: "initializing", Version: 1},
+			"products:1": {IndexName: "products", ShardID: 1, IsPrimary: true, NodeID: "data-1", State: "started", Version: 2},
+			"products:2": {IndexName: "products", ShardID: 2, IsPrimary: true, NodeID: "data-2", State: "initializing", Version: 1},
+		},
+	}
+
+	reports := []*pb.ShardReport{
+		{IndexName: "products", ShardId: 0, State: pb.ShardAllocation_SHARD_STATE_STARTED},
+		{IndexName: "products", ShardId: 1, State: pb.ShardAllocation_SHARD_STATE_STARTED},
+		{IndexName: "products", ShardId: 2, State: pb.ShardAllocation_SHARD_STATE_STARTED},
+		{IndexName: "orders", ShardId: 0, State: pb.ShardAllocation_SHARD_STATE_STARTED},
+	}
+
+	updates := startedShardUpdates(state, "data-1", reports)
+
+	// Only products:0 is assigned to data-1 and not yet started
+	if len(updates) != 1 {
+		t.Fatalf("Expected 1 update, got %d", len(updates))
+	}
+
+	update := updates[0]
+	if update.IndexName != "products" || update.ShardID != 0 {
+		t.Errorf("Unexpected shard update %s:%d", update.IndexName, update.ShardID)
+	}
+	if update.State != "started" {
+		t.Errorf("Expected state 'started', got '%s'", update.State)
+	}
+	if !update.IsPrimary {
+		t.Error("Expected IsPrimary to be preserved from routing table")
+	}
+	if update.Version != 2 {
+		t.Errorf("Expected version 2, got %d", update.Version)
+	}
+}
+
+func TestStartedShardUpdatesRecoversReplicas(t *testing.T) {
+	state := &raft.ClusterState{
+		ShardRouting: map[string]*raft.ShardRouting{
+			"products:0:data-1": {IndexName: "products", ShardID: 0, NodeID: "data-1", State: "unassigned", Version: 3},
+			"products:1:data-1": {IndexName: "products", ShardID: 1, NodeID: "data-1", State: "initializing", Version: 1},
+		},
+	}
+
+	reports := []*pb.ShardReport{
+		{IndexName: "products", ShardId: 0, State: pb.ShardAllocation_SHARD_STATE_STARTED},
+		{IndexName: "products", ShardId: 1, State: pb.ShardAllocation_SHARD_STATE_STARTED},
+	}
+
+	// A returning replica recovers before it starts; one already
+	// recovering is left alone
+	updates := startedShardUpdates(state, "data-1", reports)
+	if len(updates) != 1 {
+		t.Fatalf("Expected 1 update, got %d", len(updates))
+	}
+	if update := updates[0]; update.ShardID != 0 || update.State != "initializing" || update.IsPrimary || update.Version != 4 {
+		t.Errorf("Expected replica 0 to move to initializing, got %+v", update)
+	}
+}
+
+func TestStartedShardUpdatesIgnoresNonStartedReports(t *testing.T) {
+	state := &raft.ClusterState{
+		ShardRouting: map[string]*raft.ShardRouting{
+			"products:0": {IndexName: "products", ShardID: 0, NodeID: "data-1", State: "initializing"},
+		},
+	}
+
+	reports := []*pb.ShardReport{
+		{IndexName: "products", ShardId: 0, State: pb.ShardAllocation_SHARD_STATE_INITIALIZING},
+	}
+
+	if updates := startedShardUpdates(state, "data-1", reports); len(updates) != 0 {
+		t.Errorf("Expected no updates, got %d", len(updates))
+	}
+}
+
 func BenchmarkGetClusterState(b *testing.B) {
 	logger, _ := zap.NewDevelopment()
 	tmpDir := b.TempDir()
@@ -516,3 +597,87 @@ func BenchmarkIsLeader(b *testing.B) {
 		_ = node.IsLeader()
 	}
 }
+
+func TestConvertEventToProto(t *testing.T) {
+	s := NewMasterService(nil, zap.NewNop())
+
+	event, err := s.convertEventToProto(raft.ClusterEvent{
+		Version: 7,
+		Type:    raft.EventShardRelocated,
+		Shard:   &raft.ShardRouting{IndexName: "products", ShardID: 2, IsPrimary: true, NodeID: "data-2", State: "started"},
+	})
+	if err != nil {
+		t.Fatalf("convertEventToProto failed: %v", err)
+	}
+	if event.Version != 7 || event.Type != pb.ClusterStateEvent_EVENT_TYPE_SHARD_RELOCATED {
+		t.Errorf("Expected a relocation at version 7, got %s at version %d", event.Type, event.Version)
+	}
+
+	table := &pb.IndexRoutingTable{}
+	if err := proto.Unmarshal(event.Payload, table); err != nil {
+		t.Fatalf("Failed to unmarshal payload: %v", err)
+	}
+	shard, exists := table.Shards[2]
+	if table.IndexName != "products" || !exists || len(table.Shards) != 1 {
+		t.Fatalf("Expected the one shard of products in the payload, got %v", table)
+	}
+	if shard.Allocation.NodeId != "data-2" || shard.Allocation.State != pb.ShardAllocation_SHARD_STATE_STARTED {
+		t.Errorf("Unexpected shard allocation %v", shard.Allocation)
+	}
+
+	event, err = s.convertEventToProto(raft.ClusterEvent{
+		Version: 8,
+		Type:    raft.EventIndexDeleted,
+		Index:   &raft.IndexMeta{Name: "products", NumShards: 3},
+	})
+	if err != nil {
+		t.Fatalf("convertEventToProto failed: %v", err)
+	}
+	index := &pb.IndexMetadata{}
+	if err := proto.Unmarshal(event.Payload, index); err != nil {
+		t.Fatalf("Failed to unmarshal payload: %v", err)
+	}
+	if event.Type != pb.ClusterStateEvent_EVENT_TYPE_INDEX_DELETED || index.IndexName != "products" {
+		t.Errorf("Expected the deleted index in the event, got %s of %q", event.Type, index.IndexName)
+	}
+
+	if _, err := s.convertEventToProto(raft.ClusterEvent{Type: "bogus"}); err == nil {
+		t.Error("Expected an error for an unknown event type")
+	}
+}
+
+func TestConvertRoutingTableToProto(t *testing.T) {
+	s := NewMasterService(nil, zap.NewNop())
+
+	routing := map[string]*raft.ShardRouting{
+		raft.ShardKey("products", 0):             {IndexName: "products", ShardID: 0, IsPrimary: true, NodeID: "data-1", State: "started", InSync: true, PrimaryTerm: 2},
+		raft.ReplicaKey("products", 0, "data-3"): {IndexName: "products", ShardID: 0, NodeID: "data-3", State: "initializing"},
+		raft.ReplicaKey("products", 0, "data-2"): {IndexName: "products", ShardID: 0, NodeID: "data-2", State: "started", InSync: true},
+		raft.ReplicaKey("products", 1, "data-1"): {IndexName: "products", ShardID: 1, NodeID: "data-1", State: "started"},
+	}
+
+	table := s.convertRoutingTableToProto(routing).Indices["products"]
+	if table == nil || len(table.Shards) != 2 {
+		t.Fatalf("Expected two shards of products, got %v", table)
+	}
+
+	shard := table.Shards[0]
+	if !shard.IsPrimary || shard.Allocation.GetNodeId() != "data-1" || shard.Allocation.GetPrimaryTerm() != 2 {
+		t.Errorf("Expected the primary on data-1 in term 2, got %v", shard)
+	}
+	if len(shard.Replicas) != 2 || shard.Replicas[0].NodeId != "data-2" || shard.Replicas[1].NodeId != "data-3" {
+		t.Errorf("Expected replicas on data-2 and data-3, got %v", shard.Replicas)
+	}
+	if shard.Replicas[1].State != pb.ShardAllocation_SHARD_STATE_INITIALIZING || shard.Replicas[1].InSync {
+		t.Errorf("Expected the replica on data-3 to be initializing, got %v", shard.Replicas[1])
+	}
+	if !shard.Replicas[0].InSync {
+		t.Errorf("Expected the replica on data-2 to be in sync, got %v", shard.Replicas[0])
+	}
+
+	// A replica without a primary is listed on a routing with no allocation
+	shard = table.Shards[1]
+	if shard.Allocation != nil || len(shard.Replicas) != 1 || shard.Replicas[0].NodeId != "data-1" {
+		t.Errorf("Expected an unassigned primary with a replica on data-1, got %v", shard)
+	}
+}
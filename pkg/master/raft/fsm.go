@@ -6,6 +6,7 @@ import (
 	"io"
 	"sync"
 
+	pb "github.com/conjugate/conjugate/pkg/common/proto"
 	"github.com/hashicorp/raft"
 	"go.uber.org/zap"
 )
@@ -29,6 +30,7 @@ const (
 	CommandAllocateShard   CommandType = "allocate_shard"
 	CommandDeallocateShard CommandType = "deallocate_shard"
 	CommandUpdateShard     CommandType = "update_shard"
+	CommandPromoteReplica  CommandType = "promote_replica"
 )
 
 // Command represents a state change command
@@ -43,7 +45,7 @@ type ClusterState struct {
 	ClusterUUID  string                  `json:"cluster_uuid"`
 	Indices      map[string]*IndexMeta   `json:"indices"`       // index_name -> metadata
 	Nodes        map[string]*NodeMeta    `json:"nodes"`         // node_id -> metadata
-	ShardRouting map[string]*ShardRouting `json:"shard_routing"` // "index:shard_id" -> routing
+	ShardRouting map[string]*ShardRouting `json:"shard_routing"` // ShardRouting.Key() -> routing
 }
 
 // IndexMeta stores index metadata
@@ -56,6 +58,9 @@ type IndexMeta struct {
 	Settings         map[string]string `json:"settings"`
 	State            string            `json:"state"` // open, closed, deleting
 	CreatedAt        int64             `json:"created_at"`
+
+	// Field mappings, sent to the data nodes with the index's shards
+	Mappings map[string]*pb.FieldMapping `json:"mappings,omitempty"`
 }
 
 // NodeMeta stores node metadata
@@ -79,6 +84,64 @@ type ShardRouting struct {
 	NodeID    string `json:"node_id"`
 	State     string `json:"state"` // initializing, started, relocating, unassigned
 	Version   int64  `json:"version"`
+
+	// When the copy became unassigned (unix seconds). An unassigned copy
+	// keeps the node it was lost with, so that it can start again if the
+	// node returns before it is reallocated.
+	UnassignedAt int64 `json:"unassigned_at,omitempty"`
+
+	// InSync marks the copies that have every write the primary
+	// acknowledged: a copy joins the in-sync set when it starts and leaves
+	// it when it is lost or fails a replicated write. Only in-sync replicas
+	// are promoted.
+	InSync bool `json:"in_sync,omitempty"`
+
+	// PrimaryTerm is set on a primary and grows each time the shard gets a
+	// new primary, so that copies can reject writes from a stale one
+	PrimaryTerm int64 `json:"primary_term,omitempty"`
+
+	// Recovery is the progress a replica last reported while recovering
+	// from its primary
+	Recovery *RecoveryState `json:"recovery,omitempty"`
+}
+
+// RecoveryState is the progress of a replica's recovery from its primary
+type RecoveryState struct {
+	Stage               string `json:"stage"`
+	SourceNodeID        string `json:"source_node_id,omitempty"`
+	FilesTotal          int32  `json:"files_total,omitempty"`
+	BytesTotal          int64  `json:"bytes_total,omitempty"`
+	BytesRecovered      int64  `json:"bytes_recovered,omitempty"`
+	OperationsRecovered int64  `json:"operations_recovered,omitempty"`
+	Failure             string `json:"failure,omitempty"`
+}
+
+// ShardKey returns the routing table key of a shard's primary
+func ShardKey(indexName string, shardID int32) string {
+	return fmt.Sprintf("%s:%d", indexName, shardID)
+}
+
+// ReplicaKey returns the routing table key of a shard's replica on a node
+func ReplicaKey(indexName string, shardID int32, nodeID string) string {
+	return fmt.Sprintf("%s:%d:%s", indexName, shardID, nodeID)
+}
+
+// Key returns the routing table key of the shard: "index:shard_id" for a
+// primary, and "index:shard_id:node_id" for a replica
+func (s *ShardRouting) Key() string {
+	if s.IsPrimary {
+		return ShardKey(s.IndexName, s.ShardID)
+	}
+	return ReplicaKey(s.IndexName, s.ShardID, s.NodeID)
+}
+
+// FindShard returns the copy of a shard, primary or replica, on a node
+func (cs *ClusterState) FindShard(indexName string, shardID int32, nodeID string) (*ShardRouting, bool) {
+	if shard, exists := cs.ShardRouting[ShardKey(indexName, shardID)]; exists && shard.NodeID == nodeID {
+		return shard, true
+	}
+	shard, exists := cs.ShardRouting[ReplicaKey(indexName, shardID, nodeID)]
+	return shard, exists
 }
 
 // FSM (Finite State Machine) implements raft.FSM interface
@@ -86,6 +149,12 @@ type FSM struct {
 	mu     sync.RWMutex
 	state  *ClusterState
 	logger *zap.Logger
+
+	// Applied changes, for watchers (see watch.go). The history holds every
+	// event after version historyStart.
+	events       []ClusterEvent
+	historyStart int64
+	watchers     map[*Watcher]struct{}
 }
 
 // NewFSM creates a new FSM
@@ -97,7 +166,8 @@ func NewFSM(logger *zap.Logger) *FSM {
 			Nodes:        make(map[string]*NodeMeta),
 			ShardRouting: make(map[string]*ShardRouting),
 		},
-		logger: logger,
+		logger:   logger,
+		watchers: make(map[*Watcher]struct{}),
 	}
 }
 
@@ -135,6 +205,8 @@ func (f *FSM) Apply(log *raft.Log) interface{} {
 		return f.applyDeallocateShard(cmd.Payload)
 	case CommandUpdateShard:
 		return f.applyUpdateShard(cmd.Payload)
+	case CommandPromoteReplica:
+		return f.applyPromoteReplica(cmd.Payload)
 	default:
 		return fmt.Errorf("unknown command type: %s", cmd.Type)
 	}
@@ -180,6 +252,7 @@ func (f *FSM) Restore(rc io.ReadCloser) error {
 	defer f.mu.Unlock()
 
 	f.state = &state
+	f.resetHistory(ErrStateRestored)
 	f.logger.Info("Restored FSM from snapshot", zap.Int64("version", state.Version))
 
 	return nil
@@ -225,6 +298,7 @@ func (f *FSM) applyCreateIndex(payload json.RawMessage) error {
 	}
 
 	f.state.Indices[index.Name] = &index
+	f.publish(ClusterEvent{Type: EventIndexCreated, Index: copyIndex(&index)})
 	f.logger.Info("Created index", zap.String("index", index.Name))
 
 	return nil
@@ -238,7 +312,10 @@ func (f *FSM) applyDeleteIndex(payload json.RawMessage) error {
 		return fmt.Errorf("failed to unmarshal request: %w", err)
 	}
 
-	delete(f.state.Indices, req.IndexName)
+	if index, exists := f.state.Indices[req.IndexName]; exists {
+		delete(f.state.Indices, req.IndexName)
+		f.publish(ClusterEvent{Type: EventIndexDeleted, Index: copyIndex(index)})
+	}
 	f.logger.Info("Deleted index", zap.String("index", req.IndexName))
 
 	return nil
@@ -255,6 +332,7 @@ func (f *FSM) applyUpdateIndex(payload json.RawMessage) error {
 	}
 
 	f.state.Indices[index.Name] = &index
+	f.publish(ClusterEvent{Type: EventIndexUpdated, Index: copyIndex(&index)})
 	f.logger.Info("Updated index", zap.String("index", index.Name))
 
 	return nil
@@ -267,6 +345,7 @@ func (f *FSM) applyRegisterNode(payload json.RawMessage) error {
 	}
 
 	f.state.Nodes[node.NodeID] = &node
+	f.publish(ClusterEvent{Type: EventNodeJoined, Node: copyNode(&node)})
 	f.logger.Info("Registered node", zap.String("node_id", node.NodeID))
 
 	return nil
@@ -280,7 +359,10 @@ func (f *FSM) applyUnregisterNode(payload json.RawMessage) error {
 		return fmt.Errorf("failed to unmarshal request: %w", err)
 	}
 
-	delete(f.state.Nodes, req.NodeID)
+	if node, exists := f.state.Nodes[req.NodeID]; exists {
+		delete(f.state.Nodes, req.NodeID)
+		f.publish(ClusterEvent{Type: EventNodeLeft, Node: copyNode(node)})
+	}
 	f.logger.Info("Unregistered node", zap.String("node_id", req.NodeID))
 
 	return nil
@@ -292,11 +374,17 @@ func (f *FSM) applyUpdateNode(payload json.RawMessage) error {
 		return fmt.Errorf("failed to unmarshal node: %w", err)
 	}
 
-	if _, exists := f.state.Nodes[node.NodeID]; !exists {
+	existing, exists := f.state.Nodes[node.NodeID]
+	if !exists {
 		return fmt.Errorf("node %s does not exist", node.NodeID)
 	}
+	// A heartbeat applied after the update was built is not undone
+	if existing.LastSeen > node.LastSeen {
+		node.LastSeen = existing.LastSeen
+	}
 
 	f.state.Nodes[node.NodeID] = &node
+	f.publish(ClusterEvent{Type: EventNodeUpdated, Node: copyNode(&node)})
 	f.logger.Info("Updated node", zap.String("node_id", node.NodeID))
 
 	return nil
@@ -327,11 +415,21 @@ func (f *FSM) applyAllocateShard(payload json.RawMessage) error {
 		return fmt.Errorf("failed to unmarshal shard: %w", err)
 	}
 
-	key := fmt.Sprintf("%s:%d", shard.IndexName, shard.ShardID)
-	f.state.ShardRouting[key] = &shard
+	// A new primary, empty or not, starts a new term
+	if shard.IsPrimary {
+		shard.PrimaryTerm = 1
+		if previous, exists := f.state.ShardRouting[shard.Key()]; exists {
+			shard.PrimaryTerm = previous.PrimaryTerm + 1
+		}
+	}
+	shard.InSync = false
+
+	f.state.ShardRouting[shard.Key()] = &shard
+	f.publish(ClusterEvent{Type: EventShardAllocated, Shard: copyShard(&shard)})
 	f.logger.Info("Allocated shard",
 		zap.String("index", shard.IndexName),
 		zap.Int32("shard_id", shard.ShardID),
+		zap.Bool("is_primary", shard.IsPrimary),
 		zap.String("node", shard.NodeID))
 
 	return nil
@@ -339,18 +437,26 @@ func (f *FSM) applyAllocateShard(payload json.RawMessage) error {
 
 func (f *FSM) applyDeallocateShard(payload json.RawMessage) error {
 	var req struct {
-		IndexName string `json:"index_name"`
-		ShardID   int32  `json:"shard_id"`
+		IndexName     string `json:"index_name"`
+		ShardID       int32  `json:"shard_id"`
+		ReplicaNodeID string `json:"replica_node_id,omitempty"` // set to deallocate a replica
 	}
 	if err := json.Unmarshal(payload, &req); err != nil {
 		return fmt.Errorf("failed to unmarshal request: %w", err)
 	}
 
-	key := fmt.Sprintf("%s:%d", req.IndexName, req.ShardID)
-	delete(f.state.ShardRouting, key)
+	key := ShardKey(req.IndexName, req.ShardID)
+	if req.ReplicaNodeID != "" {
+		key = ReplicaKey(req.IndexName, req.ShardID, req.ReplicaNodeID)
+	}
+	if shard, exists := f.state.ShardRouting[key]; exists {
+		delete(f.state.ShardRouting, key)
+		f.publish(ClusterEvent{Type: EventShardDeallocated, Shard: copyShard(shard)})
+	}
 	f.logger.Info("Deallocated shard",
 		zap.String("index", req.IndexName),
-		zap.Int32("shard_id", req.ShardID))
+		zap.Int32("shard_id", req.ShardID),
+		zap.String("replica_node", req.ReplicaNodeID))
 
 	return nil
 }
@@ -361,8 +467,28 @@ func (f *FSM) applyUpdateShard(payload json.RawMessage) error {
 		return fmt.Errorf("failed to unmarshal shard: %w", err)
 	}
 
-	key := fmt.Sprintf("%s:%d", shard.IndexName, shard.ShardID)
+	key := shard.Key()
+	eventType := EventShardUpdated
+	previous, exists := f.state.ShardRouting[key]
+	if exists && previous.NodeID != "" && previous.NodeID != shard.NodeID {
+		eventType = EventShardRelocated
+	}
+
+	// The term and in-sync flag are kept by the FSM, not by the updates
+	if exists && shard.PrimaryTerm == 0 {
+		shard.PrimaryTerm = previous.PrimaryTerm
+	}
+	switch shard.State {
+	case "started":
+		shard.InSync = true
+	case "unassigned":
+		shard.InSync = false
+	default:
+		shard.InSync = exists && previous.InSync
+	}
+
 	f.state.ShardRouting[key] = &shard
+	f.publish(ClusterEvent{Type: eventType, Shard: copyShard(&shard)})
 	f.logger.Info("Updated shard",
 		zap.String("index", shard.IndexName),
 		zap.Int32("shard_id", shard.ShardID))
@@ -370,6 +496,89 @@ func (f *FSM) applyUpdateShard(payload json.RawMessage) error {
 	return nil
 }
 
+// applyPromoteReplica makes a shard's replica on a node its primary, in the
+// next primary term. The former primary stays in the routing as an
+// unassigned replica on its node, out of the in-sync set. Each of the three
+// changes is published at its own version.
+func (f *FSM) applyPromoteReplica(payload json.RawMessage) error {
+	var req struct {
+		IndexName    string `json:"index_name"`
+		ShardID      int32  `json:"shard_id"`
+		NodeID       string `json:"node_id"`       // the replica's node
+		UnassignedAt int64  `json:"unassigned_at"` // when the former primary was lost
+	}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return fmt.Errorf("failed to unmarshal request: %w", err)
+	}
+
+	replicaKey := ReplicaKey(req.IndexName, req.ShardID, req.NodeID)
+	replica, exists := f.state.ShardRouting[replicaKey]
+	if !exists {
+		return fmt.Errorf("shard %s:%d has no replica on node %s", req.IndexName, req.ShardID, req.NodeID)
+	}
+
+	primaryKey := ShardKey(req.IndexName, req.ShardID)
+	previous := f.state.ShardRouting[primaryKey]
+
+	primary := *replica
+	primary.IsPrimary = true
+	primary.PrimaryTerm = 1
+	primary.Version++
+	if previous != nil {
+		primary.PrimaryTerm = previous.PrimaryTerm + 1
+		if previous.Version >= primary.Version {
+			primary.Version = previous.Version + 1
+		}
+	}
+
+	delete(f.state.ShardRouting, replicaKey)
+	f.publish(ClusterEvent{Type: EventShardDeallocated, Shard: copyShard(replica)})
+
+	f.state.Version++
+	f.state.ShardRouting[primaryKey] = &primary
+	f.publish(ClusterEvent{Type: EventShardRelocated, Shard: copyShard(&primary)})
+
+	if previous != nil && previous.NodeID != "" && previous.NodeID != req.NodeID {
+		lost := *previous
+		lost.IsPrimary = false
+		lost.State = "unassigned"
+		lost.UnassignedAt = req.UnassignedAt
+		lost.InSync = false
+		lost.PrimaryTerm = 0
+		f.state.Version++
+		f.state.ShardRouting[lost.Key()] = &lost
+		f.publish(ClusterEvent{Type: EventShardAllocated, Shard: copyShard(&lost)})
+	}
+
+	f.logger.Info("Promoted replica to primary",
+		zap.String("index", req.IndexName),
+		zap.Int32("shard_id", req.ShardID),
+		zap.String("node", req.NodeID))
+
+	return nil
+}
+
+// Events carry copies, as heartbeats update nodes in place
+
+func copyIndex(index *IndexMeta) *IndexMeta {
+	c := *index
+	return &c
+}
+
+func copyNode(node *NodeMeta) *NodeMeta {
+	c := *node
+	return &c
+}
+
+func copyShard(shard *ShardRouting) *ShardRouting {
+	c := *shard
+	if shard.Recovery != nil {
+		recovery := *shard.Recovery
+		c.Recovery = &recovery
+	}
+	return &c
+}
+
 // fsmSnapshot implements raft.FSMSnapshot
 type fsmSnapshot struct {
 	state *ClusterState
@@ -237,6 +237,141 @@ func TestFSMApplyAllocateShard(t *testing.T) {
 	}
 }
 
+func TestFSMApplyReplicaShards(t *testing.T) {
+	fsm := NewFSM(zap.NewNop())
+
+	applyCommand(t, fsm, CommandAllocateShard, &ShardRouting{IndexName: "test-index", ShardID: 0, IsPrimary: true, NodeID: "node-1", State: "started"})
+	applyCommand(t, fsm, CommandAllocateShard, &ShardRouting{IndexName: "test-index", ShardID: 0, NodeID: "node-2", State: "initializing"})
+	applyCommand(t, fsm, CommandAllocateShard, &ShardRouting{IndexName: "test-index", ShardID: 0, NodeID: "node-3", State: "initializing"})
+
+	// Replicas are keyed by node, beside their primary
+	state := fsm.GetState()
+	if len(state.ShardRouting) != 3 {
+		t.Fatalf("Expected 3 shard copies, got %d", len(state.ShardRouting))
+	}
+	if _, exists := state.ShardRouting[ReplicaKey("test-index", 0, "node-2")]; !exists {
+		t.Error("Replica on node-2 was not allocated")
+	}
+	if shard, exists := state.FindShard("test-index", 0, "node-1"); !exists || !shard.IsPrimary {
+		t.Errorf("Expected the primary on node-1, got %+v", shard)
+	}
+
+	applyCommand(t, fsm, CommandUpdateShard, &ShardRouting{IndexName: "test-index", ShardID: 0, NodeID: "node-2", State: "started"})
+	if shard, exists := fsm.GetState().FindShard("test-index", 0, "node-2"); !exists || shard.State != "started" {
+		t.Errorf("Expected the replica on node-2 to be started, got %+v", shard)
+	}
+
+	applyCommand(t, fsm, CommandDeallocateShard, map[string]interface{}{"index_name": "test-index", "shard_id": 0, "replica_node_id": "node-3"})
+	state = fsm.GetState()
+	if _, exists := state.FindShard("test-index", 0, "node-3"); exists {
+		t.Error("Replica on node-3 was not deallocated")
+	}
+	if _, exists := state.FindShard("test-index", 0, "node-1"); !exists {
+		t.Error("Deallocating a replica removed the primary")
+	}
+}
+
+func TestFSMApplyPromoteReplica(t *testing.T) {
+	fsm := NewFSM(zap.NewNop())
+
+	applyCommand(t, fsm, CommandAllocateShard, &ShardRouting{IndexName: "test-index", ShardID: 0, IsPrimary: true, NodeID: "node-1", State: "started", Version: 3})
+	applyCommand(t, fsm, CommandAllocateShard, &ShardRouting{IndexName: "test-index", ShardID: 0, NodeID: "node-2", State: "started", Version: 1})
+
+	w, err := fsm.Watch(fsm.GetState().Version)
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	defer w.Close()
+
+	applyCommand(t, fsm, CommandPromoteReplica, map[string]interface{}{
+		"index_name": "test-index", "shard_id": 0, "node_id": "node-2", "unassigned_at": 100,
+	})
+
+	state := fsm.GetState()
+	primary := state.ShardRouting[ShardKey("test-index", 0)]
+	if primary == nil || primary.NodeID != "node-2" || !primary.IsPrimary || primary.Version != 4 || primary.PrimaryTerm != 2 {
+		t.Errorf("Expected the primary on node-2 at version 4 in term 2, got %+v", primary)
+	}
+	if _, exists := state.ShardRouting[ReplicaKey("test-index", 0, "node-2")]; exists {
+		t.Error("The promoted replica is still a replica")
+	}
+	lost := state.ShardRouting[ReplicaKey("test-index", 0, "node-1")]
+	if lost == nil || lost.IsPrimary || lost.State != "unassigned" || lost.UnassignedAt != 100 || lost.InSync {
+		t.Errorf("Expected the former primary as an unassigned replica, got %+v", lost)
+	}
+
+	// Each change is published at its own version
+	events := receiveEvents(t, w, 3)
+	for i, eventType := range []EventType{EventShardDeallocated, EventShardRelocated, EventShardAllocated} {
+		if events[i].Type != eventType {
+			t.Errorf("Event %d: expected %s, got %s", i, eventType, events[i].Type)
+		}
+		if i > 0 && events[i].Version != events[i-1].Version+1 {
+			t.Errorf("Event %d: expected version %d, got %d", i, events[i-1].Version+1, events[i].Version)
+		}
+	}
+	if state.Version != events[2].Version {
+		t.Errorf("Expected state version %d, got %d", events[2].Version, state.Version)
+	}
+
+	// Promoting a replica that does not exist fails
+	data, _ := json.Marshal(map[string]interface{}{"index_name": "test-index", "shard_id": 0, "node_id": "node-3"})
+	cmdData, _ := json.Marshal(Command{Type: CommandPromoteReplica, Payload: data})
+	if err, ok := fsm.Apply(&raft.Log{Type: raft.LogCommand, Data: cmdData}).(error); !ok || err == nil {
+		t.Error("Expected an error promoting a missing replica")
+	}
+}
+
+func TestFSMInSyncAndPrimaryTerm(t *testing.T) {
+	fsm := NewFSM(zap.NewNop())
+
+	applyCommand(t, fsm, CommandAllocateShard, &ShardRouting{IndexName: "test-index", ShardID: 0, IsPrimary: true, NodeID: "node-1", State: "initializing"})
+	applyCommand(t, fsm, CommandAllocateShard, &ShardRouting{IndexName: "test-index", ShardID: 0, NodeID: "node-2", State: "initializing"})
+
+	state := fsm.GetState()
+	primary := state.ShardRouting[ShardKey("test-index", 0)]
+	replica := state.ShardRouting[ReplicaKey("test-index", 0, "node-2")]
+	if primary.PrimaryTerm != 1 || primary.InSync || replica.PrimaryTerm != 0 || replica.InSync {
+		t.Fatalf("Expected new copies out of sync in term 1, got %+v and %+v", primary, replica)
+	}
+
+	// Starting copies puts them in sync; updates keep the term
+	applyCommand(t, fsm, CommandUpdateShard, &ShardRouting{IndexName: "test-index", ShardID: 0, IsPrimary: true, NodeID: "node-1", State: "started"})
+	applyCommand(t, fsm, CommandUpdateShard, &ShardRouting{IndexName: "test-index", ShardID: 0, NodeID: "node-2", State: "started"})
+
+	state = fsm.GetState()
+	primary = state.ShardRouting[ShardKey("test-index", 0)]
+	replica = state.ShardRouting[ReplicaKey("test-index", 0, "node-2")]
+	if primary.PrimaryTerm != 1 || !primary.InSync || !replica.InSync {
+		t.Errorf("Expected started copies in sync in term 1, got %+v and %+v", primary, replica)
+	}
+
+	// A lost copy leaves the in-sync set
+	applyCommand(t, fsm, CommandUpdateShard, &ShardRouting{IndexName: "test-index", ShardID: 0, NodeID: "node-2", State: "unassigned", UnassignedAt: 100})
+	if replica := fsm.GetState().ShardRouting[ReplicaKey("test-index", 0, "node-2")]; replica.InSync {
+		t.Errorf("Expected the unassigned replica out of sync, got %+v", replica)
+	}
+
+	// Allocating the primary again starts a new term
+	applyCommand(t, fsm, CommandAllocateShard, &ShardRouting{IndexName: "test-index", ShardID: 0, IsPrimary: true, NodeID: "node-3", State: "initializing"})
+	if primary := fsm.GetState().ShardRouting[ShardKey("test-index", 0)]; primary.PrimaryTerm != 2 || primary.InSync {
+		t.Errorf("Expected the new primary out of sync in term 2, got %+v", primary)
+	}
+}
+
+func TestFSMApplyUpdateNodeKeepsLastSeen(t *testing.T) {
+	fsm := NewFSM(zap.NewNop())
+
+	applyCommand(t, fsm, CommandRegisterNode, &NodeMeta{NodeID: "node-1", Status: "healthy", LastSeen: 10})
+	applyCommand(t, fsm, CommandHeartbeat, map[string]interface{}{"node_id": "node-1", "last_seen": 20})
+	applyCommand(t, fsm, CommandUpdateNode, &NodeMeta{NodeID: "node-1", Status: "degraded", LastSeen: 10})
+
+	node := fsm.GetState().Nodes["node-1"]
+	if node.Status != "degraded" || node.LastSeen != 20 {
+		t.Errorf("Expected the degraded node last seen at 20, got %+v", node)
+	}
+}
+
 func TestFSMSnapshot(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
 	fsm := NewFSM(logger)
@@ -0,0 +1,165 @@
+package raft
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// eventHistorySize is the number of past events kept for watchers that
+	// resume from an earlier version
+	eventHistorySize = 1024
+
+	// watchBufferSize is the number of events a watcher may fall behind by
+	// before it is closed
+	watchBufferSize = 256
+)
+
+var (
+	// ErrVersionUnavailable is returned by Watch when the events after the
+	// requested version are no longer (or not yet) known. The watcher must
+	// reload the full state and watch from its version.
+	ErrVersionUnavailable = errors.New("cluster state version is not available")
+
+	// ErrWatcherLagged closes a watcher that fell too far behind
+	ErrWatcherLagged = errors.New("watcher fell behind the cluster state")
+
+	// ErrStateRestored closes the watchers when the state is restored from
+	// a snapshot
+	ErrStateRestored = errors.New("cluster state was restored from a snapshot")
+)
+
+// EventType is the kind of change a ClusterEvent describes
+type EventType string
+
+const (
+	// Index events
+	EventIndexCreated EventType = "index_created"
+	EventIndexUpdated EventType = "index_updated"
+	EventIndexDeleted EventType = "index_deleted"
+
+	// Node events
+	EventNodeJoined  EventType = "node_joined"
+	EventNodeUpdated EventType = "node_updated"
+	EventNodeLeft    EventType = "node_left"
+
+	// Shard events
+	EventShardAllocated   EventType = "shard_allocated"
+	EventShardUpdated     EventType = "shard_updated"
+	EventShardRelocated   EventType = "shard_relocated" // updated onto another node
+	EventShardDeallocated EventType = "shard_deallocated"
+)
+
+// ClusterEvent is a change to the cluster state, published to watchers once
+// applied. One of Index, Node and Shard is set, by Type; for a removal it is
+// the removed entry. Heartbeats change no watched state and publish nothing.
+type ClusterEvent struct {
+	Version int64
+	Type    EventType
+	Index   *IndexMeta
+	Node    *NodeMeta
+	Shard   *ShardRouting
+}
+
+// Watcher receives the cluster events after a version, in order
+type Watcher struct {
+	fsm    *FSM
+	events chan ClusterEvent
+	err    error // why the FSM closed events; set before they are closed
+	closed bool  // guarded by fsm.mu
+}
+
+// Events returns the watcher's events. The channel is closed when the
+// watcher is closed; Err then tells why.
+func (w *Watcher) Events() <-chan ClusterEvent {
+	return w.events
+}
+
+// Err returns why the FSM closed the watcher: ErrWatcherLagged or
+// ErrStateRestored, or nil if it was closed by Close. It is only meaningful
+// once Events is closed.
+func (w *Watcher) Err() error {
+	return w.err
+}
+
+// Close stops the watcher
+func (w *Watcher) Close() {
+	w.fsm.mu.Lock()
+	defer w.fsm.mu.Unlock()
+	w.fsm.closeWatcher(w, nil)
+}
+
+// Watch returns a watcher of the events after fromVersion: first those
+// already applied, then each as it is applied. It fails with
+// ErrVersionUnavailable if the history no longer reaches back to
+// fromVersion, or fromVersion is ahead of the state.
+func (f *FSM) Watch(fromVersion int64) (*Watcher, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if fromVersion < f.historyStart || fromVersion > f.state.Version {
+		return nil, fmt.Errorf("%w: events are known after version %d up to %d, requested after %d",
+			ErrVersionUnavailable, f.historyStart, f.state.Version, fromVersion)
+	}
+
+	var backlog []ClusterEvent
+	for i, event := range f.events {
+		if event.Version > fromVersion {
+			backlog = f.events[i:]
+			break
+		}
+	}
+
+	w := &Watcher{
+		fsm:    f,
+		events: make(chan ClusterEvent, len(backlog)+watchBufferSize),
+	}
+	for _, event := range backlog {
+		w.events <- event
+	}
+	f.watchers[w] = struct{}{}
+
+	return w, nil
+}
+
+// publish records an event at the current version and sends it to the
+// watchers. A watcher whose buffer is full is closed rather than blocking
+// Apply. Called with f.mu held.
+func (f *FSM) publish(event ClusterEvent) {
+	event.Version = f.state.Version
+
+	f.events = append(f.events, event)
+	if len(f.events) > eventHistorySize {
+		f.historyStart = f.events[0].Version
+		f.events = append([]ClusterEvent(nil), f.events[1:]...)
+	}
+
+	for w := range f.watchers {
+		select {
+		case w.events <- event:
+		default:
+			f.closeWatcher(w, ErrWatcherLagged)
+		}
+	}
+}
+
+// resetHistory drops the event history, which now starts at the current
+// version, and closes the watchers with err. Called with f.mu held.
+func (f *FSM) resetHistory(err error) {
+	f.events = nil
+	f.historyStart = f.state.Version
+	for w := range f.watchers {
+		f.closeWatcher(w, err)
+	}
+}
+
+// closeWatcher closes w's events with err. Called with f.mu held.
+func (f *FSM) closeWatcher(w *Watcher, err error) {
+	if w.closed {
+		return
+	}
+	w.closed = true
+	w.err = err
+	close(w.events)
+	delete(f.watchers, w)
+}
@@ -0,0 +1,211 @@
+package raft
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/raft"
+	"go.uber.org/zap"
+)
+
+func applyCommand(t *testing.T, fsm *FSM, cmdType CommandType, payload interface{}) {
+	t.Helper()
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("Failed to marshal payload: %v", err)
+	}
+	cmdData, err := json.Marshal(Command{Type: cmdType, Payload: data})
+	if err != nil {
+		t.Fatalf("Failed to marshal command: %v", err)
+	}
+
+	if result := fsm.Apply(&raft.Log{Type: raft.LogCommand, Data: cmdData}); result != nil {
+		if err, ok := result.(error); ok {
+			t.Fatalf("Apply returned error: %v", err)
+		}
+	}
+}
+
+func receiveEvents(t *testing.T, w *Watcher, n int) []ClusterEvent {
+	t.Helper()
+
+	events := make([]ClusterEvent, 0, n)
+	for len(events) < n {
+		select {
+		case event, ok := <-w.Events():
+			if !ok {
+				t.Fatalf("Watcher closed after %d of %d events: %v", len(events), n, w.Err())
+			}
+			events = append(events, event)
+		default:
+			t.Fatalf("Expected %d events, got %d", n, len(events))
+		}
+	}
+	return events
+}
+
+func TestFSMWatchEvents(t *testing.T) {
+	fsm := NewFSM(zap.NewNop())
+
+	w, err := fsm.Watch(0)
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	defer w.Close()
+
+	applyCommand(t, fsm, CommandCreateIndex, &IndexMeta{Name: "logs", NumShards: 2})
+	applyCommand(t, fsm, CommandRegisterNode, &NodeMeta{NodeID: "data-1", NodeType: "data"})
+	applyCommand(t, fsm, CommandHeartbeat, map[string]interface{}{"node_id": "data-1", "last_seen": 42})
+	applyCommand(t, fsm, CommandAllocateShard, &ShardRouting{IndexName: "logs", ShardID: 0, IsPrimary: true, NodeID: "data-1", State: "initializing"})
+	applyCommand(t, fsm, CommandUpdateShard, &ShardRouting{IndexName: "logs", ShardID: 0, IsPrimary: true, NodeID: "data-1", State: "started"})
+	applyCommand(t, fsm, CommandDeallocateShard, map[string]interface{}{"index_name": "logs", "shard_id": 0})
+	applyCommand(t, fsm, CommandDeleteIndex, map[string]interface{}{"index_name": "logs"})
+	applyCommand(t, fsm, CommandUnregisterNode, map[string]interface{}{"node_id": "data-1"})
+
+	// The heartbeat bumps the version but publishes nothing
+	events := receiveEvents(t, w, 7)
+	expected := []struct {
+		version   int64
+		eventType EventType
+	}{
+		{1, EventIndexCreated},
+		{2, EventNodeJoined},
+		{4, EventShardAllocated},
+		{5, EventShardUpdated},
+		{6, EventShardDeallocated},
+		{7, EventIndexDeleted},
+		{8, EventNodeLeft},
+	}
+	for i, want := range expected {
+		if events[i].Version != want.version || events[i].Type != want.eventType {
+			t.Errorf("Event %d: expected %s at version %d, got %s at version %d",
+				i, want.eventType, want.version, events[i].Type, events[i].Version)
+		}
+	}
+
+	if events[0].Index == nil || events[0].Index.NumShards != 2 {
+		t.Errorf("Expected the created index in the event, got %+v", events[0].Index)
+	}
+	if events[3].Shard == nil || events[3].Shard.State != "started" {
+		t.Errorf("Expected the updated shard in the event, got %+v", events[3].Shard)
+	}
+	if events[6].Node == nil || events[6].Node.NodeID != "data-1" {
+		t.Errorf("Expected the removed node in the event, got %+v", events[6].Node)
+	}
+
+	// Moving a shard to another node relocates it
+	applyCommand(t, fsm, CommandAllocateShard, &ShardRouting{IndexName: "logs", ShardID: 1, IsPrimary: true, NodeID: "data-1", State: "started"})
+	applyCommand(t, fsm, CommandUpdateShard, &ShardRouting{IndexName: "logs", ShardID: 1, IsPrimary: true, NodeID: "data-2", State: "started"})
+	if events := receiveEvents(t, w, 2); events[1].Type != EventShardRelocated {
+		t.Errorf("Expected %s, got %s", EventShardRelocated, events[1].Type)
+	}
+
+	// Removing what does not exist changes nothing
+	applyCommand(t, fsm, CommandDeleteIndex, map[string]interface{}{"index_name": "logs"})
+	select {
+	case event := <-w.Events():
+		t.Errorf("Expected no event, got %+v", event)
+	default:
+	}
+}
+
+func TestFSMWatchResume(t *testing.T) {
+	fsm := NewFSM(zap.NewNop())
+
+	for _, name := range []string{"a", "b", "c"} {
+		applyCommand(t, fsm, CommandCreateIndex, &IndexMeta{Name: name})
+	}
+
+	// Resuming replays the events after the version, then continues live
+	w, err := fsm.Watch(1)
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	defer w.Close()
+
+	applyCommand(t, fsm, CommandCreateIndex, &IndexMeta{Name: "d"})
+	events := receiveEvents(t, w, 3)
+	for i, name := range []string{"b", "c", "d"} {
+		if events[i].Index.Name != name || events[i].Version != int64(i+2) {
+			t.Errorf("Event %d: expected index %s at version %d, got %s at version %d",
+				i, name, i+2, events[i].Index.Name, events[i].Version)
+		}
+	}
+
+	// A version ahead of the state cannot be resumed
+	if _, err := fsm.Watch(10); !errors.Is(err, ErrVersionUnavailable) {
+		t.Errorf("Expected ErrVersionUnavailable, got %v", err)
+	}
+}
+
+func TestFSMWatchHistoryLimit(t *testing.T) {
+	fsm := NewFSM(zap.NewNop())
+
+	for i := 0; i < eventHistorySize+10; i++ {
+		applyCommand(t, fsm, CommandRegisterNode, &NodeMeta{NodeID: "node"})
+	}
+
+	if _, err := fsm.Watch(5); !errors.Is(err, ErrVersionUnavailable) {
+		t.Errorf("Expected ErrVersionUnavailable for a version before the history, got %v", err)
+	}
+
+	w, err := fsm.Watch(10)
+	if err != nil {
+		t.Fatalf("Watch from the start of the history failed: %v", err)
+	}
+	defer w.Close()
+	events := receiveEvents(t, w, eventHistorySize)
+	if events[0].Version != 11 {
+		t.Errorf("Expected the first event at version 11, got %d", events[0].Version)
+	}
+}
+
+func TestFSMWatchClose(t *testing.T) {
+	fsm := NewFSM(zap.NewNop())
+
+	// A watcher that falls behind is closed rather than blocking Apply
+	lagging, err := fsm.Watch(0)
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	for i := 0; i < watchBufferSize+1; i++ {
+		applyCommand(t, fsm, CommandRegisterNode, &NodeMeta{NodeID: "node"})
+	}
+	for range lagging.Events() {
+	}
+	if !errors.Is(lagging.Err(), ErrWatcherLagged) {
+		t.Errorf("Expected ErrWatcherLagged, got %v", lagging.Err())
+	}
+
+	// Restoring a snapshot closes the watchers and restarts the history
+	w, err := fsm.Watch(fsm.GetState().Version)
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	data, _ := json.Marshal(&ClusterState{Version: 1000})
+	if err := fsm.Restore(&mockReadCloser{data: data}); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if _, ok := <-w.Events(); ok {
+		t.Error("Expected the watcher to be closed")
+	}
+	if !errors.Is(w.Err(), ErrStateRestored) {
+		t.Errorf("Expected ErrStateRestored, got %v", w.Err())
+	}
+	if _, err := fsm.Watch(500); !errors.Is(err, ErrVersionUnavailable) {
+		t.Errorf("Expected ErrVersionUnavailable before the snapshot, got %v", err)
+	}
+
+	// Close is idempotent and stops delivery
+	w, err = fsm.Watch(1000)
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	w.Close()
+	w.Close()
+	if _, ok := <-w.Events(); ok || w.Err() != nil {
+		t.Errorf("Expected a closed watcher without error, got %v", w.Err())
+	}
+}
@@ -0,0 +1,107 @@
+package master
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/conjugate/conjugate/pkg/master/raft"
+	"go.uber.org/zap"
+)
+
+// Stages a replica reports while it recovers from its primary
+const (
+	RecoveryStageIndex    = "index"    // Copying the primary's committed files
+	RecoveryStageTranslog = "translog" // Replaying the operations after them
+	RecoveryStageDone     = "done"
+	RecoveryStageFailed   = "failed"
+)
+
+var (
+	// ErrShardNotRecovering is returned for recovery reports about a copy
+	// that the routing does not have initializing on the reporting node
+	ErrShardNotRecovering = errors.New("shard copy is not recovering")
+
+	// ErrUnknownRecoveryStage is returned for recovery reports in a stage
+	// other than the RecoveryStage constants
+	ErrUnknownRecoveryStage = errors.New("unknown recovery stage")
+)
+
+// ReportShardRecovery records the progress of a replica recovering from its
+// primary. The copy moves from initializing to started, and joins the
+// in-sync set, only once the replica reports the done stage. A copy that was
+// deallocated meanwhile gets ErrShardNotFound, so that the replica gives up.
+func (m *MasterNode) ReportShardRecovery(ctx context.Context, indexName string, shardID int32, nodeID string, recovery raft.RecoveryState) error {
+	if !m.raftNode.IsLeader() {
+		return fmt.Errorf("not the leader, redirect to %s", m.raftNode.Leader())
+	}
+
+	switch recovery.Stage {
+	case RecoveryStageIndex, RecoveryStageTranslog, RecoveryStageDone, RecoveryStageFailed:
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownRecoveryStage, recovery.Stage)
+	}
+
+	update, err := recoveryUpdate(m.fsm.GetState(), indexName, shardID, nodeID, recovery)
+	if err != nil || update == nil {
+		return err
+	}
+
+	payload, err := json.Marshal(update)
+	if err != nil {
+		return fmt.Errorf("failed to marshal shard update: %w", err)
+	}
+
+	cmd := raft.Command{
+		Type:    raft.CommandUpdateShard,
+		Payload: payload,
+	}
+
+	if err := m.raftNode.Apply(cmd, 5*time.Second); err != nil {
+		return fmt.Errorf("failed to update shard %s:%d: %w", indexName, shardID, err)
+	}
+
+	fields := []zap.Field{
+		zap.String("index", indexName),
+		zap.Int32("shard_id", shardID),
+		zap.String("node_id", nodeID),
+		zap.String("source_node_id", recovery.SourceNodeID),
+		zap.String("stage", recovery.Stage),
+	}
+	switch recovery.Stage {
+	case RecoveryStageDone:
+		m.logger.Info("Replica recovered, shard started",
+			append(fields, zap.Int64("bytes", recovery.BytesRecovered), zap.Int64("operations", recovery.OperationsRecovered))...)
+	case RecoveryStageFailed:
+		m.logger.Warn("Replica recovery failed", append(fields, zap.String("failure", recovery.Failure))...)
+	default:
+		m.logger.Debug("Replica recovery progress", fields...)
+	}
+
+	return nil
+}
+
+// recoveryUpdate returns the routing update recording a replica's recovery
+// progress, or nil when the copy already started
+func recoveryUpdate(state *raft.ClusterState, indexName string, shardID int32, nodeID string, recovery raft.RecoveryState) (*raft.ShardRouting, error) {
+	current, exists := state.FindShard(indexName, shardID, nodeID)
+	if !exists || current.IsPrimary {
+		return nil, fmt.Errorf("%w: no replica of %s:%d on %s", ErrShardNotFound, indexName, shardID, nodeID)
+	}
+	if current.State == "started" {
+		return nil, nil
+	}
+	if current.State != "initializing" {
+		return nil, fmt.Errorf("%w: %s:%d on %s is %s", ErrShardNotRecovering, indexName, shardID, nodeID, current.State)
+	}
+
+	update := *current
+	update.Version++
+	update.Recovery = &recovery
+	if recovery.Stage == RecoveryStageDone {
+		update.State = "started"
+	}
+	return &update, nil
+}
@@ -0,0 +1,58 @@
+package master
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/conjugate/conjugate/pkg/master/raft"
+)
+
+func TestRecoveryUpdate(t *testing.T) {
+	state := &raft.ClusterState{
+		ShardRouting: map[string]*raft.ShardRouting{
+			"products:0":        {IndexName: "products", ShardID: 0, IsPrimary: true, NodeID: "data-1", State: "started", PrimaryTerm: 2},
+			"products:0:data-2": {IndexName: "products", ShardID: 0, NodeID: "data-2", State: "initializing", Version: 1},
+			"products:0:data-3": {IndexName: "products", ShardID: 0, NodeID: "data-3", State: "started", InSync: true},
+			"products:0:data-4": {IndexName: "products", ShardID: 0, NodeID: "data-4", State: "unassigned"},
+		},
+	}
+
+	// Progress is recorded while the copy stays initializing
+	progress := raft.RecoveryState{Stage: RecoveryStageIndex, SourceNodeID: "data-1", FilesTotal: 3, BytesTotal: 1024}
+	update, err := recoveryUpdate(state, "products", 0, "data-2", progress)
+	if err != nil {
+		t.Fatalf("Failed to record progress: %v", err)
+	}
+	if update.State != "initializing" || update.Version != 2 || update.Recovery == nil || update.Recovery.FilesTotal != 3 {
+		t.Errorf("Expected the progress on an initializing copy, got %+v", update)
+	}
+
+	// The done stage starts the copy
+	done := raft.RecoveryState{Stage: RecoveryStageDone, SourceNodeID: "data-1", BytesRecovered: 1024, OperationsRecovered: 7}
+	update, err = recoveryUpdate(state, "products", 0, "data-2", done)
+	if err != nil {
+		t.Fatalf("Failed to record completion: %v", err)
+	}
+	if update.State != "started" || update.IsPrimary || update.Recovery.Stage != RecoveryStageDone {
+		t.Errorf("Expected the replica to start, got %+v", update)
+	}
+	if state.ShardRouting["products:0:data-2"].Recovery != nil {
+		t.Error("Expected the cluster state to be left unchanged")
+	}
+
+	// A started copy needs no update
+	if update, err := recoveryUpdate(state, "products", 0, "data-3", done); err != nil || update != nil {
+		t.Errorf("Expected no update for a started copy, got %+v, %v", update, err)
+	}
+
+	// A copy that was lost, or is the primary, is not recovering
+	if _, err := recoveryUpdate(state, "products", 0, "data-4", done); !errors.Is(err, ErrShardNotRecovering) {
+		t.Errorf("Expected ErrShardNotRecovering, got %v", err)
+	}
+	if _, err := recoveryUpdate(state, "products", 0, "data-1", done); !errors.Is(err, ErrShardNotFound) {
+		t.Errorf("Expected ErrShardNotFound for the primary, got %v", err)
+	}
+	if _, err := recoveryUpdate(state, "products", 0, "data-5", done); !errors.Is(err, ErrShardNotFound) {
+		t.Errorf("Expected ErrShardNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package master
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/conjugate/conjugate/pkg/master/raft"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrShardNotFound is returned for a shard without a primary in the
+	// routing table
+	ErrShardNotFound = errors.New("shard not found")
+
+	// ErrStalePrimaryTerm is returned to a primary that was replaced by a
+	// newer one
+	ErrStalePrimaryTerm = errors.New("stale primary term")
+)
+
+// FailShard takes a replica that failed a replicated write out of the
+// in-sync set, as reported by the shard's primary in primaryTerm. The
+// replica is deallocated, then deleted from its node and replaced in the
+// background. A primary whose term is behind the routing's is no longer the
+// primary and gets ErrStalePrimaryTerm, so that it does not acknowledge the
+// write.
+func (m *MasterNode) FailShard(ctx context.Context, indexName string, shardID int32, nodeID string, primaryTerm int64, reason string) error {
+	if !m.raftNode.IsLeader() {
+		return fmt.Errorf("not the leader, redirect to %s", m.raftNode.Leader())
+	}
+
+	state := m.fsm.GetState()
+	primary, exists := state.ShardRouting[raft.ShardKey(indexName, shardID)]
+	if !exists {
+		return fmt.Errorf("%w: %s:%d", ErrShardNotFound, indexName, shardID)
+	}
+	if primaryTerm < primary.PrimaryTerm {
+		return fmt.Errorf("%w: %d, the primary is in term %d", ErrStalePrimaryTerm, primaryTerm, primary.PrimaryTerm)
+	}
+
+	// A replica already out of the routing is out of the in-sync set too
+	if _, exists := state.ShardRouting[raft.ReplicaKey(indexName, shardID, nodeID)]; !exists {
+		return nil
+	}
+
+	req := struct {
+		IndexName     string `json:"index_name"`
+		ShardID       int32  `json:"shard_id"`
+		ReplicaNodeID string `json:"replica_node_id"`
+	}{
+		IndexName:     indexName,
+		ShardID:       shardID,
+		ReplicaNodeID: nodeID,
+	}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal replica deallocation: %w", err)
+	}
+
+	cmd := raft.Command{
+		Type:    raft.CommandDeallocateShard,
+		Payload: payload,
+	}
+
+	if err := m.raftNode.Apply(cmd, 5*time.Second); err != nil {
+		return fmt.Errorf("failed to apply replica deallocation: %w", err)
+	}
+
+	m.logger.Warn("Failed replica shard",
+		zap.String("index", indexName),
+		zap.Int32("shard_id", shardID),
+		zap.String("node", nodeID),
+		zap.Int64("primary_term", primaryTerm),
+		zap.String("reason", reason))
+
+	go m.replaceFailedReplica(nodeID, indexName, shardID)
+
+	return nil
+}
+
+// replaceFailedReplica deletes a failed replica from its node, then
+// allocates the replicas its index is missing. The delete comes first so
+// that the node can take a new copy of the shard.
+func (m *MasterNode) replaceFailedReplica(nodeID, indexName string, shardID int32) {
+	m.deleteShardOnDataNode(nodeID, indexName, shardID)
+
+	index, exists := m.fsm.GetState().Indices[indexName]
+	if !exists {
+		return
+	}
+	m.reconcileReplicas(context.Background(), index)
+}
@@ -5,8 +5,10 @@ package dsl
 
 import (
 	"fmt"
+	"math"
 	"strings"
 
+	"github.com/conjugate/conjugate/pkg/common/dates"
 	"github.com/conjugate/conjugate/pkg/ppl/ast"
 	"github.com/conjugate/conjugate/pkg/ppl/physical"
 )
@@ -114,12 +116,13 @@ func (ab *AggregationBuilder) BuildBinAggregations(bin *physical.PhysicalBin) (m
 
 	if bin.Span != nil {
 		// Time-based binning - use date_histogram
-		interval := ab.timeSpanToInterval(bin.Span)
+		dateHistogram, err := ab.spanInterval(bin.Span)
+		if err != nil {
+			return nil, err
+		}
+		dateHistogram["field"] = fieldRef.Name
 		agg = map[string]interface{}{
-			"date_histogram": map[string]interface{}{
-				"field":             fieldRef.Name,
-				"calendar_interval": interval,
-			},
+			"date_histogram": dateHistogram,
 		}
 	} else {
 		// Numeric binning - use histogram
@@ -147,56 +150,18 @@ func (ab *AggregationBuilder) BuildBinAggregations(bin *physical.PhysicalBin) (m
 	}, nil
 }
 
-// timeSpanToInterval converts TimeSpan to OpenSearch interval
-func (ab *AggregationBuilder) timeSpanToInterval(span *ast.TimeSpan) string {
-	unit := strings.ToLower(span.Unit)
-	value := span.Value
-
-	switch unit {
-	case "s", "sec", "second", "seconds":
-		if value == 1 {
-			return "1s"
-		}
-		return fmt.Sprintf("%ds", value)
-	case "m", "min", "minute", "minutes":
-		if value == 1 {
-			return "1m"
-		}
-		return fmt.Sprintf("%dm", value)
-	case "h", "hr", "hour", "hours":
-		if value == 1 {
-			return "1h"
-		}
-		return fmt.Sprintf("%dh", value)
-	case "d", "day", "days":
-		if value == 1 {
-			return "1d"
-		}
-		return fmt.Sprintf("%dd", value)
-	case "w", "week", "weeks":
-		if value == 1 {
-			return "1w"
-		}
-		return fmt.Sprintf("%dw", value)
-	case "mon", "month", "months":
-		if value == 1 {
-			return "1M"
-		}
-		return fmt.Sprintf("%dM", value)
-	case "q", "quarter", "quarters":
-		if value == 1 {
-			return "1q"
-		}
-		return fmt.Sprintf("%dq", value)
-	case "y", "year", "years":
-		if value == 1 {
-			return "1y"
-		}
-		return fmt.Sprintf("%dy", value)
-	default:
-		// Default to minutes
-		return fmt.Sprintf("%dm", value)
+// spanInterval converts a TimeSpan to date_histogram interval parameters,
+// rounded as the data nodes and the bin command round it
+func (ab *AggregationBuilder) spanInterval(span *ast.TimeSpan) (map[string]interface{}, error) {
+	rounding, err := dates.ParseSpan(span.Value, span.Unit)
+	if err != nil {
+		return nil, fmt.Errorf("invalid span %s: %w", span, err)
+	}
+	params, err := rounding.Params()
+	if err != nil {
+		return nil, fmt.Errorf("span %s cannot be pushed down: %w", span, err)
 	}
+	return params, nil
 }
 
 // wrapWithGroupBy wraps an aggregation in group-by terms
@@ -261,7 +226,25 @@ func (ab *AggregationBuilder) BuildAggregations(agg *physical.PhysicalAggregate)
 	return aggs, nil
 }
 
-// buildGroupByAggregations builds nested aggregations for GROUP BY
+// CompositeAggName is the name of the composite aggregation a GROUP BY
+// pushes down
+const CompositeAggName = "composite_buckets"
+
+// compositePageSize is the number of group-by buckets fetched per request.
+// PPL execution reads a single page, so it keeps the 10000 groups of the
+// terms aggregations composite replaced.
+const compositePageSize = 10000
+
+// timechartTimeField is the field timechart buckets into its _time group
+const timechartTimeField = "@timestamp"
+
+// buildGroupByAggregations builds a composite aggregation for GROUP BY, with
+// a terms source per group field and the metrics as sub-aggregations. For
+// GROUP BY field1, field2 we get:
+// composite(field1, field2) -> metrics
+// The _time group of a timechart is a date_histogram source on @timestamp,
+// rounded by the span. Callers reach the groups past the first page with
+// NextCompositePage.
 func (ab *AggregationBuilder) buildGroupByAggregations(agg *physical.PhysicalAggregate) (map[string]interface{}, error) {
 	if len(agg.GroupBy) == 0 {
 		return nil, fmt.Errorf("GROUP BY requires at least one field")
@@ -283,38 +266,70 @@ func (ab *AggregationBuilder) buildGroupByAggregations(agg *physical.PhysicalAgg
 		subAggs[aggName] = metricAgg
 	}
 
-	// Build nested terms aggregations from innermost to outermost
-	// For GROUP BY field1, field2, field3 we get:
-	// terms(field1) -> terms(field2) -> terms(field3) -> metrics
-	currentAggs := subAggs
-
-	for i := len(agg.GroupBy) - 1; i >= 0; i-- {
-		groupField, ok := agg.GroupBy[i].(*ast.FieldReference)
+	// One source per group field, in GROUP BY order. Rows with a null group
+	// field form their own group, as in PPL.
+	sources := make([]interface{}, 0, len(agg.GroupBy))
+	for _, expr := range agg.GroupBy {
+		groupField, ok := expr.(*ast.FieldReference)
 		if !ok {
 			return nil, fmt.Errorf("GROUP BY field must be a simple field reference")
 		}
 
-		termsAgg := map[string]interface{}{
-			"terms": map[string]interface{}{
-				"field": groupField.Name,
-				"size":  10000, // Default size - could be configurable
-			},
-		}
-
-		// If there are sub-aggregations (metrics or nested groups), add them
-		if len(currentAggs) > 0 {
-			termsAgg["aggs"] = currentAggs
+		if agg.Span != nil && groupField.Name == "_time" {
+			dateHistogram, err := ab.spanInterval(agg.Span)
+			if err != nil {
+				return nil, err
+			}
+			dateHistogram["field"] = timechartTimeField
+			sources = append(sources, map[string]interface{}{
+				groupField.Name: map[string]interface{}{"date_histogram": dateHistogram},
+			})
+			continue
 		}
 
-		groupName := fmt.Sprintf("group_by_%s", groupField.Name)
+		sources = append(sources, map[string]interface{}{
+			groupField.Name: map[string]interface{}{
+				"terms": map[string]interface{}{
+					"field":          groupField.Name,
+					"missing_bucket": true,
+				},
+			},
+		})
+	}
 
-		// This becomes the current aggregation for the next (outer) level
-		currentAggs = map[string]interface{}{
-			groupName: termsAgg,
-		}
+	compositeAgg := map[string]interface{}{
+		"composite": map[string]interface{}{
+			"size":    compositePageSize,
+			"sources": sources,
+		},
+	}
+	if len(subAggs) > 0 {
+		compositeAgg["aggs"] = subAggs
 	}
 
-	return currentAggs, nil
+	return map[string]interface{}{
+		CompositeAggName: compositeAgg,
+	}, nil
+}
+
+// NextCompositePage sets the GROUP BY aggregations to request the page after
+// afterKey, the after_key of the previous response. It returns false when
+// there is no next page: afterKey is empty or aggs has no composite
+// aggregation.
+func NextCompositePage(aggs map[string]interface{}, afterKey map[string]interface{}) bool {
+	if len(afterKey) == 0 {
+		return false
+	}
+	compositeAgg, ok := aggs[CompositeAggName].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	composite, ok := compositeAgg["composite"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	composite["after"] = afterKey
+	return true
 }
 
 // buildMetricAggregation builds a single metric aggregation
@@ -409,10 +424,11 @@ func (ab *AggregationBuilder) buildMetricAggregation(agg *ast.Aggregation) (map[
 			},
 		}, nil
 
-	case "cardinality", "dc", "distinct_count":
-		// Distinct count
-		if len(agg.Func.Arguments) != 1 {
-			return nil, fmt.Errorf("%s() requires exactly one argument", funcName)
+	case "cardinality", "dc", "distinct_count", "approx_count_distinct":
+		// Distinct count, from the HyperLogLog++ sketches the shards return.
+		// An optional second argument sets the precision threshold.
+		if len(agg.Func.Arguments) != 1 && len(agg.Func.Arguments) != 2 {
+			return nil, fmt.Errorf("%s() requires a field and an optional precision threshold", funcName)
 		}
 
 		fieldRef, ok := agg.Func.Arguments[0].(*ast.FieldReference)
@@ -420,10 +436,19 @@ func (ab *AggregationBuilder) buildMetricAggregation(agg *ast.Aggregation) (map[
 			return nil, fmt.Errorf("%s() argument must be a field reference", funcName)
 		}
 
+		cardinality := map[string]interface{}{
+			"field": fieldRef.Name,
+		}
+		if len(agg.Func.Arguments) == 2 {
+			threshold, err := numericLiteral(agg.Func.Arguments[1])
+			if err != nil || threshold < 0 || threshold != math.Trunc(threshold) {
+				return nil, fmt.Errorf("%s() precision threshold must be a non-negative integer", funcName)
+			}
+			cardinality["precision_threshold"] = int(threshold)
+		}
+
 		return map[string]interface{}{
-			"cardinality": map[string]interface{}{
-				"field": fieldRef.Name,
-			},
+			"cardinality": cardinality,
 		}, nil
 
 	case "stats":
@@ -459,11 +484,71 @@ func (ab *AggregationBuilder) buildMetricAggregation(agg *ast.Aggregation) (map[
 			},
 		}, nil
 
+	case "percentile", "percentile_approx", "median":
+		// Single percentiles, estimated from the t-digests the shards merge
+		// like a percentiles aggregation
+		if funcName == "median" && len(agg.Func.Arguments) != 1 {
+			return nil, fmt.Errorf("median() requires exactly one argument")
+		}
+		if funcName == "percentile" && len(agg.Func.Arguments) != 2 {
+			return nil, fmt.Errorf("percentile() requires a field and a percent")
+		}
+		if funcName == "percentile_approx" && len(agg.Func.Arguments) != 2 && len(agg.Func.Arguments) != 3 {
+			return nil, fmt.Errorf("percentile_approx() requires a field, a percent and an optional compression")
+		}
+
+		fieldRef, ok := agg.Func.Arguments[0].(*ast.FieldReference)
+		if !ok {
+			return nil, fmt.Errorf("%s() argument must be a field reference", funcName)
+		}
+
+		percent := 50.0
+		if len(agg.Func.Arguments) > 1 {
+			var err error
+			percent, err = numericLiteral(agg.Func.Arguments[1])
+			if err != nil || percent < 0 || percent > 100 {
+				return nil, fmt.Errorf("%s() percent must be a number between 0 and 100", funcName)
+			}
+		}
+
+		percentiles := map[string]interface{}{
+			"field":    fieldRef.Name,
+			"percents": []interface{}{percent},
+		}
+		if len(agg.Func.Arguments) == 3 {
+			compression, err := numericLiteral(agg.Func.Arguments[2])
+			if err != nil || compression <= 0 {
+				return nil, fmt.Errorf("percentile_approx() compression must be a positive number")
+			}
+			percentiles["tdigest"] = map[string]interface{}{"compression": compression}
+		}
+
+		return map[string]interface{}{
+			"percentiles": percentiles,
+		}, nil
+
 	default:
 		return nil, fmt.Errorf("unsupported aggregation function: %s", funcName)
 	}
 }
 
+// numericLiteral returns the value of a numeric literal
+func numericLiteral(expr ast.Expression) (float64, error) {
+	lit, ok := expr.(*ast.Literal)
+	if !ok {
+		return 0, fmt.Errorf("expected a numeric literal")
+	}
+	switch v := lit.Value.(type) {
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	}
+	return 0, fmt.Errorf("expected a numeric literal, got %T", lit.Value)
+}
+
 // isWildcard checks if an expression is a wildcard (*)
 func (ab *AggregationBuilder) isWildcard(expr ast.Expression) bool {
 	if fieldRef, ok := expr.(*ast.FieldReference); ok {
@@ -384,14 +384,10 @@ func TestTranslator_AggregationGroupBy(t *testing.T) {
 	// Should have aggregations
 	require.NotNil(t, dsl.Aggregations)
 
-	// Should have group_by_host aggregation
-	groupAgg, ok := dsl.Aggregations["group_by_host"].(map[string]interface{})
+	// Should be a composite aggregation with a terms source on host
+	groupAgg, ok := dsl.Aggregations[CompositeAggName].(map[string]interface{})
 	require.True(t, ok)
-
-	// Should be a terms aggregation
-	termsAgg, ok := groupAgg["terms"].(map[string]interface{})
-	require.True(t, ok)
-	assert.Equal(t, "host", termsAgg["field"])
+	assert.Equal(t, []string{"host"}, compositeSourceFields(t, groupAgg))
 
 	// Should have sub-aggregations
 	subAggs, ok := groupAgg["aggs"].(map[string]interface{})
@@ -449,7 +445,7 @@ func TestTranslator_AggregationMultipleMetrics(t *testing.T) {
 	require.NoError(t, err)
 
 	// Get the group aggregation
-	groupAgg, ok := dsl.Aggregations["group_by_host"].(map[string]interface{})
+	groupAgg, ok := dsl.Aggregations[CompositeAggName].(map[string]interface{})
 	require.True(t, ok)
 
 	// Get sub-aggregations
@@ -536,7 +532,7 @@ func TestTranslator_ComplexAggregationWithFilter(t *testing.T) {
 
 	// Should have aggregations
 	require.NotNil(t, dsl.Aggregations)
-	assert.Contains(t, dsl.Aggregations, "group_by_host")
+	assert.Contains(t, dsl.Aggregations, CompositeAggName)
 }
 
 // =====================================================================
@@ -572,31 +568,123 @@ func TestTranslator_MultiFieldGroupBy(t *testing.T) {
 	dsl, err := translator.Translate(agg)
 	require.NoError(t, err)
 
-	// Should have nested aggregations
 	require.NotNil(t, dsl.Aggregations)
 
-	// Should have group_by_host at outer level
-	assert.Contains(t, dsl.Aggregations, "group_by_host")
-
-	hostAgg, ok := dsl.Aggregations["group_by_host"].(map[string]interface{})
+	// Should have one composite aggregation with a source per field, in
+	// GROUP BY order
+	groupAgg, ok := dsl.Aggregations[CompositeAggName].(map[string]interface{})
 	require.True(t, ok)
+	assert.Equal(t, []string{"host", "status"}, compositeSourceFields(t, groupAgg))
+
+	// Each source keeps null values as their own group
+	composite := groupAgg["composite"].(map[string]interface{})
+	for _, source := range composite["sources"].([]interface{}) {
+		for _, def := range source.(map[string]interface{}) {
+			terms := def.(map[string]interface{})["terms"].(map[string]interface{})
+			assert.Equal(t, true, terms["missing_bucket"])
+		}
+	}
+}
 
-	// Verify it's a terms aggregation
-	termsAgg, ok := hostAgg["terms"].(map[string]interface{})
+// compositeSourceFields returns the fields of a composite aggregation's terms
+// sources, in order
+func compositeSourceFields(t *testing.T, agg map[string]interface{}) []string {
+	t.Helper()
+	composite, ok := agg["composite"].(map[string]interface{})
 	require.True(t, ok)
-	assert.Equal(t, "host", termsAgg["field"])
-
-	// Verify nested aggregation exists
-	nestedAggs, ok := hostAgg["aggs"].(map[string]interface{})
+	sources, ok := composite["sources"].([]interface{})
 	require.True(t, ok)
-	assert.Contains(t, nestedAggs, "group_by_status")
 
-	// Verify the inner terms aggregation
-	statusAgg, ok := nestedAggs["group_by_status"].(map[string]interface{})
-	require.True(t, ok)
-	statusTerms, ok := statusAgg["terms"].(map[string]interface{})
-	require.True(t, ok)
-	assert.Equal(t, "status", statusTerms["field"])
+	var fields []string
+	for _, source := range sources {
+		for name, def := range source.(map[string]interface{}) {
+			terms, ok := def.(map[string]interface{})["terms"].(map[string]interface{})
+			require.True(t, ok)
+			assert.Equal(t, name, terms["field"])
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+func TestTranslator_GroupByCompositePaging(t *testing.T) {
+	schema := createTestSchema()
+	translator := NewTranslator()
+
+	agg := &physical.PhysicalAggregate{
+		GroupBy: []ast.Expression{
+			&ast.FieldReference{Name: "host"},
+		},
+		Aggregations: []*ast.Aggregation{
+			{
+				Func: &ast.FunctionCall{
+					Name:      "count",
+					Arguments: []ast.Expression{},
+				},
+				Alias: "total",
+			},
+		},
+		OutputSchema: schema,
+		Input: &physical.PhysicalScan{
+			Source:       "logs",
+			OutputSchema: schema,
+		},
+	}
+
+	dsl, err := translator.Translate(agg)
+	require.NoError(t, err)
+
+	composite := dsl.Aggregations[CompositeAggName].(map[string]interface{})["composite"].(map[string]interface{})
+	assert.Equal(t, 10000, composite["size"])
+	assert.NotContains(t, composite, "after")
+
+	// The next page continues after the previous response's after_key
+	afterKey := map[string]interface{}{"host": "web-7"}
+	assert.True(t, NextCompositePage(dsl.Aggregations, afterKey))
+	assert.Equal(t, afterKey, composite["after"])
+
+	// No after_key, no more pages
+	assert.False(t, NextCompositePage(dsl.Aggregations, nil))
+	assert.False(t, NextCompositePage(map[string]interface{}{}, afterKey))
+}
+
+func TestTranslator_TimechartSpan(t *testing.T) {
+	schema := createTestSchema()
+	translator := NewTranslator()
+
+	// timechart span=1d count() by host
+	agg := &physical.PhysicalAggregate{
+		GroupBy: []ast.Expression{
+			&ast.FieldReference{Name: "_time"},
+			&ast.FieldReference{Name: "host"},
+		},
+		Aggregations: []*ast.Aggregation{
+			{Func: &ast.FunctionCall{Name: "count", Arguments: []ast.Expression{}}},
+		},
+		Span:         &ast.TimeSpan{Value: 1, Unit: "d"},
+		OutputSchema: schema,
+		Input: &physical.PhysicalScan{
+			Source:       "logs",
+			OutputSchema: schema,
+		},
+	}
+
+	dsl, err := translator.Translate(agg)
+	require.NoError(t, err)
+
+	composite := dsl.Aggregations[CompositeAggName].(map[string]interface{})["composite"].(map[string]interface{})
+	sources := composite["sources"].([]interface{})
+	require.Len(t, sources, 2)
+	assert.Equal(t, map[string]interface{}{
+		"_time": map[string]interface{}{
+			"date_histogram": map[string]interface{}{"field": "@timestamp", "calendar_interval": "day"},
+		},
+	}, sources[0])
+	assert.Contains(t, sources[1], "host")
+
+	agg.Span = &ast.TimeSpan{Value: 2, Unit: "mon"}
+	_, err = translator.Translate(agg)
+	assert.Error(t, err)
 }
 
 func TestTranslator_TopAggregation(t *testing.T) {
@@ -727,7 +815,7 @@ func TestTranslator_BinDateHistogram(t *testing.T) {
 	dateHist, ok := binAgg["date_histogram"].(map[string]interface{})
 	require.True(t, ok)
 	assert.Equal(t, "timestamp", dateHist["field"])
-	assert.Equal(t, "1h", dateHist["calendar_interval"])
+	assert.Equal(t, "hour", dateHist["calendar_interval"])
 }
 
 func TestTranslator_BinNumericHistogram(t *testing.T) {
@@ -800,32 +888,36 @@ func TestTranslator_TopWithMultipleFields(t *testing.T) {
 	assert.Contains(t, nestedAggs, "by_status")
 }
 
-func TestAggregationBuilder_TimeSpanToInterval(t *testing.T) {
+func TestAggregationBuilder_SpanInterval(t *testing.T) {
 	ab := NewAggregationBuilder()
 
 	tests := []struct {
 		span     *ast.TimeSpan
-		expected string
+		expected map[string]interface{}
 	}{
-		{&ast.TimeSpan{Value: 1, Unit: "s"}, "1s"},
-		{&ast.TimeSpan{Value: 30, Unit: "s"}, "30s"},
-		{&ast.TimeSpan{Value: 1, Unit: "m"}, "1m"},
-		{&ast.TimeSpan{Value: 5, Unit: "min"}, "5m"},
-		{&ast.TimeSpan{Value: 1, Unit: "h"}, "1h"},
-		{&ast.TimeSpan{Value: 24, Unit: "hour"}, "24h"},
-		{&ast.TimeSpan{Value: 1, Unit: "d"}, "1d"},
-		{&ast.TimeSpan{Value: 7, Unit: "day"}, "7d"},
-		{&ast.TimeSpan{Value: 1, Unit: "w"}, "1w"},
-		{&ast.TimeSpan{Value: 1, Unit: "mon"}, "1M"},
-		{&ast.TimeSpan{Value: 1, Unit: "y"}, "1y"},
+		{&ast.TimeSpan{Value: 1, Unit: "s"}, map[string]interface{}{"fixed_interval": "1s"}},
+		{&ast.TimeSpan{Value: 30, Unit: "s"}, map[string]interface{}{"fixed_interval": "30s"}},
+		{&ast.TimeSpan{Value: 1, Unit: "m"}, map[string]interface{}{"calendar_interval": "minute"}},
+		{&ast.TimeSpan{Value: 5, Unit: "min"}, map[string]interface{}{"fixed_interval": "5m"}},
+		{&ast.TimeSpan{Value: 1, Unit: "h"}, map[string]interface{}{"calendar_interval": "hour"}},
+		{&ast.TimeSpan{Value: 24, Unit: "hour"}, map[string]interface{}{"fixed_interval": "1d"}},
+		{&ast.TimeSpan{Value: 1, Unit: "d"}, map[string]interface{}{"calendar_interval": "day"}},
+		{&ast.TimeSpan{Value: 7, Unit: "day"}, map[string]interface{}{"fixed_interval": "7d"}},
+		{&ast.TimeSpan{Value: 1, Unit: "w"}, map[string]interface{}{"calendar_interval": "week"}},
+		{&ast.TimeSpan{Value: 1, Unit: "mon"}, map[string]interface{}{"calendar_interval": "month"}},
+		{&ast.TimeSpan{Value: 1, Unit: "y"}, map[string]interface{}{"calendar_interval": "year"}},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.span.String(), func(t *testing.T) {
-			result := ab.timeSpanToInterval(tt.span)
+			result, err := ab.spanInterval(tt.span)
+			require.NoError(t, err)
 			assert.Equal(t, tt.expected, result)
 		})
 	}
+
+	_, err := ab.spanInterval(&ast.TimeSpan{Value: 2, Unit: "y"})
+	assert.Error(t, err)
 }
 
 func TestTranslator_TopWithFilter(t *testing.T) {
@@ -897,33 +989,16 @@ func TestTranslator_ThreeFieldGroupBy(t *testing.T) {
 	dsl, err := translator.Translate(agg)
 	require.NoError(t, err)
 
-	// Should have nested aggregations 3 levels deep
+	// Should have one composite aggregation over all three fields
 	require.NotNil(t, dsl.Aggregations)
-
-	// Navigate the nested structure
-	// Level 1: group_by_host
-	hostAgg, ok := dsl.Aggregations["group_by_host"].(map[string]interface{})
-	require.True(t, ok)
-	require.NotNil(t, hostAgg["terms"])
-	require.NotNil(t, hostAgg["aggs"])
-
-	// Level 2: group_by_status
-	hostSubAggs := hostAgg["aggs"].(map[string]interface{})
-	statusAgg, ok := hostSubAggs["group_by_status"].(map[string]interface{})
+	groupAgg, ok := dsl.Aggregations[CompositeAggName].(map[string]interface{})
 	require.True(t, ok)
-	require.NotNil(t, statusAgg["terms"])
-	require.NotNil(t, statusAgg["aggs"])
+	assert.Equal(t, []string{"host", "status", "region"}, compositeSourceFields(t, groupAgg))
 
-	// Level 3: group_by_region (with metric)
-	statusSubAggs := statusAgg["aggs"].(map[string]interface{})
-	regionAgg, ok := statusSubAggs["group_by_region"].(map[string]interface{})
+	// The metric is computed per bucket combination
+	subAggs, ok := groupAgg["aggs"].(map[string]interface{})
 	require.True(t, ok)
-	require.NotNil(t, regionAgg["terms"])
-
-	// Metric should be at the innermost level
-	regionSubAggs := regionAgg["aggs"].(map[string]interface{})
-	_, hasTotal := regionSubAggs["total"]
-	assert.True(t, hasTotal)
+	assert.Contains(t, subAggs, "total")
 }
 
 func TestAggregationBuilder_CardinalityAggregation(t *testing.T) {
@@ -1042,3 +1117,72 @@ func TestAggregationBuilder_PercentilesAggregation(t *testing.T) {
 	require.True(t, ok)
 	assert.Equal(t, "latency", percentiles["field"])
 }
+
+func TestAggregationBuilder_PercentileAggregation(t *testing.T) {
+	builder := NewAggregationBuilder()
+
+	build := func(name string, args ...ast.Expression) (map[string]interface{}, error) {
+		return builder.buildMetricAggregation(&ast.Aggregation{
+			Func: &ast.FunctionCall{Name: name, Arguments: args},
+		})
+	}
+	latency := &ast.FieldReference{Name: "latency"}
+
+	// stats percentile(latency, 99)
+	agg, err := build("percentile", latency, &ast.Literal{Value: int64(99), LiteralTyp: ast.LiteralTypeInt})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"percentiles": map[string]interface{}{"field": "latency", "percents": []interface{}{99.0}},
+	}, agg)
+
+	// stats percentile_approx(latency, 95.5, 200)
+	agg, err = build("percentile_approx", latency,
+		&ast.Literal{Value: 95.5, LiteralTyp: ast.LiteralTypeFloat},
+		&ast.Literal{Value: int64(200), LiteralTyp: ast.LiteralTypeInt})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"percentiles": map[string]interface{}{
+			"field":    "latency",
+			"percents": []interface{}{95.5},
+			"tdigest":  map[string]interface{}{"compression": 200.0},
+		},
+	}, agg)
+
+	agg, err = build("median", latency)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{50.0}, agg["percentiles"].(map[string]interface{})["percents"])
+
+	_, err = build("percentile", latency)
+	assert.Error(t, err)
+	_, err = build("percentile", latency, &ast.Literal{Value: int64(101), LiteralTyp: ast.LiteralTypeInt})
+	assert.Error(t, err)
+}
+
+func TestAggregationBuilder_ApproxCountDistinct(t *testing.T) {
+	builder := NewAggregationBuilder()
+	user := &ast.FieldReference{Name: "user_id"}
+
+	// stats approx_count_distinct(user_id, 10000)
+	agg, err := builder.buildMetricAggregation(&ast.Aggregation{
+		Func: &ast.FunctionCall{Name: "approx_count_distinct", Arguments: []ast.Expression{
+			user, &ast.Literal{Value: int64(10000), LiteralTyp: ast.LiteralTypeInt},
+		}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"cardinality": map[string]interface{}{"field": "user_id", "precision_threshold": 10000},
+	}, agg)
+
+	agg, err = builder.buildMetricAggregation(&ast.Aggregation{
+		Func: &ast.FunctionCall{Name: "distinct_count", Arguments: []ast.Expression{user}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"cardinality": map[string]interface{}{"field": "user_id"}}, agg)
+
+	_, err = builder.buildMetricAggregation(&ast.Aggregation{
+		Func: &ast.FunctionCall{Name: "dc", Arguments: []ast.Expression{
+			user, &ast.Literal{Value: 1.5, LiteralTyp: ast.LiteralTypeFloat},
+		}},
+	})
+	assert.Error(t, err)
+}
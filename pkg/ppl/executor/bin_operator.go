@@ -5,9 +5,11 @@ package executor
 
 import (
 	"context"
+	"fmt"
 	"math"
 	"time"
 
+	"github.com/conjugate/conjugate/pkg/common/dates"
 	"github.com/conjugate/conjugate/pkg/ppl/ast"
 	"go.uber.org/zap"
 )
@@ -20,6 +22,11 @@ type binOperator struct {
 	bins   int
 	logger *zap.Logger
 
+	// rounding is the span's date rounding, shared with date_histogram
+	rounding *dates.Rounding
+	// spanErr is why the span can't be parsed, returned from Open
+	spanErr error
+
 	ctx       context.Context
 	fieldName string
 	stats     *IteratorStats
@@ -40,7 +47,7 @@ func NewBinOperator(
 		fieldName = ref.Name
 	}
 
-	return &binOperator{
+	b := &binOperator{
 		input:     input,
 		field:     field,
 		span:      span,
@@ -49,6 +56,15 @@ func NewBinOperator(
 		fieldName: fieldName,
 		stats:     &IteratorStats{},
 	}
+	if span != nil {
+		rounding, err := dates.ParseSpan(span.Value, span.Unit)
+		if err != nil {
+			b.spanErr = fmt.Errorf("invalid bin span %s: %w", span, err)
+		} else {
+			b.rounding = &rounding
+		}
+	}
+	return b
 }
 
 // Open initializes the operator
@@ -62,6 +78,10 @@ func (b *binOperator) Open(ctx context.Context) error {
 		zap.String("field", b.fieldName),
 		zap.Int("bins", b.bins))
 
+	if b.spanErr != nil {
+		return b.spanErr
+	}
+
 	if err := b.input.Open(ctx); err != nil {
 		return err
 	}
@@ -117,71 +137,29 @@ func (b *binOperator) binValue(val interface{}) interface{} {
 	return val
 }
 
-// binByTimeSpan bins a time value by the specified span
+// binByTimeSpan bins a time value by the specified span, to the start of
+// its bucket as date_histogram computes it: calendar spans follow the
+// calendar, so 1mon bins to the first of the month
 func (b *binOperator) binByTimeSpan(val interface{}) interface{} {
-	// Try to parse as time
+	if b.rounding == nil {
+		return val
+	}
+
 	var t time.Time
 	switch v := val.(type) {
 	case time.Time:
 		t = v
 	case string:
-		// Try common time formats
-		formats := []string{
-			time.RFC3339,
-			"2006-01-02T15:04:05",
-			"2006-01-02 15:04:05",
-			"2006-01-02",
-		}
-		var err error
-		for _, format := range formats {
-			t, err = time.Parse(format, v)
-			if err == nil {
-				break
-			}
-		}
-		if err != nil {
+		parsed, ok := dates.Parse(v, time.UTC)
+		if !ok {
 			return val // Can't parse, return unchanged
 		}
+		t = parsed
 	default:
 		return val // Not a time value
 	}
 
-	// Compute bucket based on span
-	spanDuration := b.spanToDuration()
-	if spanDuration == 0 {
-		return val
-	}
-
-	// Truncate to bucket
-	unixNanos := t.UnixNano()
-	bucketNanos := (unixNanos / int64(spanDuration)) * int64(spanDuration)
-	return time.Unix(0, bucketNanos).UTC()
-}
-
-// spanToDuration converts TimeSpan to time.Duration
-func (b *binOperator) spanToDuration() time.Duration {
-	if b.span == nil {
-		return 0
-	}
-
-	switch b.span.Unit {
-	case "s", "sec", "second", "seconds":
-		return time.Duration(b.span.Value) * time.Second
-	case "m", "min", "minute", "minutes":
-		return time.Duration(b.span.Value) * time.Minute
-	case "h", "hr", "hour", "hours":
-		return time.Duration(b.span.Value) * time.Hour
-	case "d", "day", "days":
-		return time.Duration(b.span.Value) * 24 * time.Hour
-	case "w", "week", "weeks":
-		return time.Duration(b.span.Value) * 7 * 24 * time.Hour
-	case "mon", "month", "months":
-		return time.Duration(b.span.Value) * 30 * 24 * time.Hour // Approximate
-	case "y", "year", "years":
-		return time.Duration(b.span.Value) * 365 * 24 * time.Hour // Approximate
-	default:
-		return 0
-	}
+	return b.rounding.Round(t).UTC()
 }
 
 // binByNumericRange bins a numeric value into one of n buckets
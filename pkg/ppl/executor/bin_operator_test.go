@@ -0,0 +1,56 @@
+// Copyright 2024 CONJUGATE Project
+// Licensed under the Apache License, Version 2.0
+
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/conjugate/conjugate/pkg/ppl/ast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestBinOperator_TimeSpan(t *testing.T) {
+	logger := zap.NewNop()
+	ctx := context.Background()
+
+	bin := func(span *ast.TimeSpan, value interface{}) interface{} {
+		input := NewSliceIterator([]*Row{NewRow(map[string]interface{}{"ts": value})})
+		op := NewBinOperator(input, &ast.FieldReference{Name: "ts"}, span, 0, logger)
+		require.NoError(t, op.Open(ctx))
+		defer op.Close()
+
+		row, err := op.Next(ctx)
+		require.NoError(t, err)
+		binned, _ := row.Get("ts")
+		return binned
+	}
+	utc := func(value string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		require.NoError(t, err)
+		return parsed
+	}
+
+	assert.Equal(t, utc("2024-03-15T10:00:00Z"), bin(&ast.TimeSpan{Value: 1, Unit: "h"}, "2024-03-15T10:42:00Z"))
+	assert.Equal(t, utc("2024-03-15T10:30:00Z"), bin(&ast.TimeSpan{Value: 15, Unit: "m"}, utc("2024-03-15T10:42:00Z")))
+
+	// Calendar months, not 30 days
+	assert.Equal(t, utc("2024-03-01T00:00:00Z"), bin(&ast.TimeSpan{Value: 1, Unit: "mon"}, "2024-03-31"))
+	assert.Equal(t, utc("2024-01-01T00:00:00Z"), bin(&ast.TimeSpan{Value: 1, Unit: "y"}, "2024-12-31 23:00:00"))
+
+	// Several months count from January 1970
+	assert.Equal(t, utc("2024-07-01T00:00:00Z"), bin(&ast.TimeSpan{Value: 6, Unit: "mon"}, "2024-09-30"))
+	assert.Equal(t, utc("2024-01-01T00:00:00Z"), bin(&ast.TimeSpan{Value: 2, Unit: "y"}, "2025-03-15"))
+
+	// Unparseable values are left as is
+	assert.Equal(t, "soon", bin(&ast.TimeSpan{Value: 1, Unit: "d"}, "soon"))
+
+	// An invalid span fails the query rather than leaving values unbinned
+	input := NewSliceIterator([]*Row{NewRow(map[string]interface{}{"ts": "2024-03-15"})})
+	op := NewBinOperator(input, &ast.FieldReference{Name: "ts"}, &ast.TimeSpan{Value: 1, Unit: "fortnight"}, 0, logger)
+	assert.Error(t, op.Open(ctx))
+}
@@ -153,6 +153,7 @@ func (h *HepOptimizer) rebuildWithChildren(plan planner.LogicalPlan, newChildren
 		return &planner.LogicalAggregate{
 			GroupBy:      p.GroupBy,
 			Aggregations: p.Aggregations,
+			Span:         p.Span,
 			OutputSchema: p.OutputSchema,
 			Input:        newChildren[0],
 		}
@@ -190,6 +190,7 @@ func (a AggregationAlgorithm) String() string {
 type PhysicalAggregate struct {
 	GroupBy      []ast.Expression
 	Aggregations []*ast.Aggregation
+	Span         *ast.TimeSpan // Buckets the _time group by @timestamp (timechart)
 	OutputSchema *analyzer.Schema
 	Input        PhysicalPlan
 	Algorithm    AggregationAlgorithm
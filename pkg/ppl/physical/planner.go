@@ -424,6 +424,7 @@ func (pp *PhysicalPlanner) planCoordinatorOnly(logicalPlan planner.LogicalPlan)
 		return &PhysicalAggregate{
 			GroupBy:      p.GroupBy,
 			Aggregations: p.Aggregations,
+			Span:         p.Span,
 			OutputSchema: p.OutputSchema,
 			Input:        input,
 			Algorithm:    algorithm,
@@ -700,6 +701,7 @@ func (pp *PhysicalPlanner) planCoordinatorOp(logicalOp planner.LogicalPlan, inpu
 		return &PhysicalAggregate{
 			GroupBy:      p.GroupBy,
 			Aggregations: p.Aggregations,
+			Span:         p.Span,
 			OutputSchema: p.OutputSchema,
 			Input:        input,
 			Algorithm:    algorithm,
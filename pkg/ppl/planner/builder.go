@@ -569,10 +569,10 @@ func (b *PlanBuilder) buildTimechartCommand(cmd *ast.TimechartCommand, input Log
 	}
 
 	// Build GROUP BY including the time bucket
-	// Note: In physical planning, we'll add a time bucket expression
 	groupBy := make([]ast.Expression, 0, len(cmd.GroupBy)+1)
 
-	// Add time bucket as first group (we'll use a special marker for now)
+	// The time bucket is the first group: @timestamp rounded by the span,
+	// as a date_histogram rounds it
 	groupBy = append(groupBy, &ast.FieldReference{Name: "_time"})
 
 	// Add user-specified GROUP BY fields
@@ -581,6 +581,7 @@ func (b *PlanBuilder) buildTimechartCommand(cmd *ast.TimechartCommand, input Log
 	return &LogicalAggregate{
 		GroupBy:      groupBy,
 		Aggregations: cmd.Aggregations,
+		Span:         cmd.Span,
 		OutputSchema: outputSchema,
 		Input:        input,
 	}, nil
@@ -105,6 +105,7 @@ func (l *LogicalLimit) String() string {
 type LogicalAggregate struct {
 	GroupBy      []ast.Expression
 	Aggregations []*ast.Aggregation
+	Span         *ast.TimeSpan // Buckets the _time group by @timestamp (timechart)
 	OutputSchema *analyzer.Schema
 	Input        LogicalPlan
 }
@@ -200,6 +201,7 @@ func ReplaceChild(plan LogicalPlan, oldChild, newChild LogicalPlan) LogicalPlan
 			return &LogicalAggregate{
 				GroupBy:      p.GroupBy,
 				Aggregations: p.Aggregations,
+				Span:         p.Span,
 				OutputSchema: p.OutputSchema,
 				Input:        newChild,
 			}